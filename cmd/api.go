@@ -26,11 +26,14 @@ import (
 	"github.com/Laisky/laisky-blog-graphql/internal/mcp"
 	"github.com/Laisky/laisky-blog-graphql/internal/mcp/askuser"
 	"github.com/Laisky/laisky-blog-graphql/internal/mcp/calllog"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/crawljobs"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/federation"
 	"github.com/Laisky/laisky-blog-graphql/internal/mcp/files"
 	mcpmemory "github.com/Laisky/laisky-blog-graphql/internal/mcp/memory"
 	mcpplugin "github.com/Laisky/laisky-blog-graphql/internal/mcp/memory/plugin"
 	pageindexplugin "github.com/Laisky/laisky-blog-graphql/internal/mcp/memory/plugins/pageindex"
 	ragplugin "github.com/Laisky/laisky-blog-graphql/internal/mcp/memory/plugins/rag"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/piperuns"
 	"github.com/Laisky/laisky-blog-graphql/internal/mcp/rag"
 	"github.com/Laisky/laisky-blog-graphql/internal/mcp/userrequests"
 	"github.com/Laisky/laisky-blog-graphql/internal/web"
@@ -289,15 +292,26 @@ func runAPI() error {
 	mcpStart := time.Now()
 	ragSettings := rag.LoadSettingsFromConfig()
 	args.RAGSettings = ragSettings
+	embeddingSettings, err := rag.LoadEmbeddingSettingsFromConfig()
+	if err != nil {
+		return errors.Wrap(err, "invalid embedding provider configuration")
+	}
+	embedders, err := rag.NewEmbedderRegistry(embeddingSettings, nil, logger.Named("embedder"))
+	if err != nil {
+		return errors.Wrap(err, "build embedding providers")
+	}
+	args.Embedders = embedders
 	filePluginSettings := mcpplugin.LoadSettingsFromConfig()
 	shadowSettings := mcpplugin.LoadShadowSettingsFromConfig()
 	if mcpDB != nil {
 		var (
-			askSvc  *askuser.Service
-			callSvc *calllog.Service
-			userSvc *userrequests.Service
-			ragSvc  *rag.Service
-			svcMu   sync.Mutex
+			askSvc   *askuser.Service
+			callSvc  *calllog.Service
+			pipeSvc  *piperuns.Service
+			crawlSvc *crawljobs.Service
+			userSvc  *userrequests.Service
+			ragSvc   *rag.Service
+			svcMu    sync.Mutex
 		)
 
 		egServices, _ := errgroup.WithContext(ctx) //nolint:contextcheck // service constructors do not accept context
@@ -336,6 +350,44 @@ func runAPI() error {
 			return nil
 		})
 
+		egServices.Go(func() error {
+			start := time.Now()
+			if mcpPGXPool == nil {
+				logger.Warn("skip mcp_pipe run service initialization because pgx pool is unavailable")
+				return nil
+			}
+			svc, err := piperuns.NewService(mcpPGXPool, logger.Named("mcp_pipe_runs"), nil)
+			if err != nil {
+				logger.Error("init mcp_pipe run service", zap.Error(err))
+				return nil
+			}
+			svcMu.Lock()
+			pipeSvc = svc
+			svcMu.Unlock()
+			logger.Debug("mcp_pipe run service initialization completed",
+				zap.Duration("duration", time.Since(start)))
+			return nil
+		})
+
+		egServices.Go(func() error {
+			start := time.Now()
+			if mcpPGXPool == nil {
+				logger.Warn("skip web_crawl job service initialization because pgx pool is unavailable")
+				return nil
+			}
+			svc, err := crawljobs.NewService(mcpPGXPool, logger.Named("web_crawl_jobs"), nil)
+			if err != nil {
+				logger.Error("init web_crawl job service", zap.Error(err))
+				return nil
+			}
+			svcMu.Lock()
+			crawlSvc = svc
+			svcMu.Unlock()
+			logger.Debug("web_crawl job service initialization completed",
+				zap.Duration("duration", time.Since(start)))
+			return nil
+		})
+
 		egServices.Go(func() error {
 			start := time.Now()
 			svc, err := userrequests.NewService(mcpDB.DB, logger.Named("user_requests"), nil, userrequests.LoadSettingsFromConfig()) //nolint:contextcheck // service factory does not require request context
@@ -353,8 +405,10 @@ func runAPI() error {
 		})
 
 		if ragSettings.Enabled {
-			embedder := rag.NewOpenAIEmbedder(ragSettings.OpenAIBaseURL, ragSettings.EmbeddingModel, nil,
-				rag.WithLogger(logger.Named("rag_embedder")))
+			embedder, err := embedders.Provider("")
+			if err != nil {
+				return errors.Wrap(err, "load default embedding provider")
+			}
 			chunker := rag.ParagraphChunker{}
 			egServices.Go(func() error {
 				start := time.Now()
@@ -363,6 +417,7 @@ func runAPI() error {
 					logger.Error("init extract_key_info service", zap.Error(err))
 					return nil
 				}
+				svc.SetEmbedderRegistry(embedders)
 				svcMu.Lock()
 				ragSvc = svc
 				svcMu.Unlock()
@@ -391,6 +446,18 @@ func runAPI() error {
 			logger.Warn("call_log service unavailable")
 		}
 
+		if pipeSvc != nil {
+			args.PipeRunService = pipeSvc
+		} else {
+			logger.Warn("mcp_pipe run service unavailable; async mode disabled")
+		}
+
+		if crawlSvc != nil {
+			args.CrawlJobService = crawlSvc
+		} else {
+			logger.Warn("web_crawl job service unavailable; web_crawl disabled")
+		}
+
 		if userSvc != nil {
 			args.UserRequestService = userSvc
 			if imageManager, imgErr := buildUserRequestImageManager(ctx, userSvc, logger.Named("user_requests_images")); imgErr != nil {
@@ -426,14 +493,21 @@ func runAPI() error {
 			if credErr != nil {
 				return errors.Wrap(credErr, "invalid mcp files credential configuration")
 			}
-			embedder := rag.NewOpenAIEmbedder(filesSettings.EmbeddingBaseURL, filesSettings.EmbeddingModel, nil,
-				rag.WithLogger(logger.Named("files_embedder")))
+			embedder, embeddingErr := embedders.Provider("")
+			if embeddingErr != nil {
+				return errors.Wrap(embeddingErr, "load default embedding provider")
+			}
 			rerankClient := files.NewCohereRerankClient(filesSettings.Search.RerankEndpoint, filesSettings.Search.RerankModel, filesSettings.Search.RerankTimeout)
 			fileSvc, err := files.NewService(mcpDB.DB, filesSettings, embedder, rerankClient, credential, credStore, logger.Named("mcp_files"), nil, nil)
 			if err != nil {
 				logger.Warn("file service unavailable", zap.Error(err))
 			} else {
+				fileSvc.SetEmbeddingResolver(func(name string) (files.EmbeddingProvider, error) {
+					return embedders.Provider(name)
+				})
 				args.FilesService = fileSvc
+				fileSvc.StartBlobGCWorker(ctx)
+				fileSvc.StartSnapshotWorker(ctx)
 
 				ragFilePlugin, pluginErr := ragplugin.New(fileSvc)
 				if pluginErr != nil {
@@ -572,6 +646,24 @@ func runAPI() error {
 		zap.Bool("memory_enabled", args.MCPToolsSettings.MemoryEnabled),
 	)
 
+	// Connect upstream MCP servers whose tools are re-exported by this server
+	federationSettings, err := federation.LoadSettingsFromConfig()
+	if err != nil {
+		return errors.Wrap(err, "load mcp federation settings")
+	}
+	if len(federationSettings.Upstreams) > 0 {
+		manager, err := federation.NewManager(logger.Named("mcp_federation"))
+		if err != nil {
+			return errors.Wrap(err, "new mcp federation manager")
+		}
+		connected := manager.ConnectAll(ctx, federationSettings)
+		logger.Info("connected upstream MCP servers",
+			zap.Int("configured", len(federationSettings.Upstreams)),
+			zap.Int("connected", connected),
+		)
+		args.MCPFederation = manager
+	}
+
 	logger.Info("total startup initialization completed",
 		zap.Duration("total_duration", time.Since(startTime)))
 
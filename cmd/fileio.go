@@ -0,0 +1,336 @@
+// Package cmd command line
+package cmd
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Laisky/errors/v2"
+	gconfig "github.com/Laisky/go-config/v2"
+	gcmd "github.com/Laisky/go-utils/v6/cmd"
+	"github.com/Laisky/zap"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/cobra"
+
+	mcpauth "github.com/Laisky/laisky-blog-graphql/internal/mcp/auth"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/files"
+	"github.com/Laisky/laisky-blog-graphql/library/db/postgres"
+	rlibs "github.com/Laisky/laisky-blog-graphql/library/db/redis"
+	"github.com/Laisky/laisky-blog-graphql/library/log"
+)
+
+var fileioCMD = &cobra.Command{
+	Use:   "fileio",
+	Short: "manage FileIO projects",
+	Long:  `Operator tools for FileIO projects stored in the MCP database`,
+	Args:  gcmd.NoExtraArgs,
+}
+
+var fileioExportCMD = &cobra.Command{
+	Use:   "export",
+	Short: "export a FileIO project to an archive",
+	Long: `Export a FileIO project, or the files below --prefix, as a tar.gz or zip archive.
+
+The archive holds a manifest.json and the file contents, and can be applied to any
+project of any API key with "fileio import".
+
+Example usage:
+  go run main.go fileio export -c settings.yml --api_key=sk-xxx --project=notes --file=notes.tar.gz --include_versions`,
+	Args:   gcmd.NoExtraArgs,
+	PreRun: preRunFileIO,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		if err := runFileIOExport(ctx, cmd); err != nil {
+			log.Logger.Panic("export fileio project", zap.Error(err))
+		}
+	},
+}
+
+var fileioImportCMD = &cobra.Command{
+	Use:   "import",
+	Short: "import a FileIO project from an archive",
+	Long: `Import an archive written by "fileio export" into a FileIO project.
+
+Files are written through the FileIO service, so path validation and project quotas
+apply and the imported files are queued for indexing. The import fails without
+writing anything if an archived path already holds a file, unless --overwrite is set.
+
+Example usage:
+  go run main.go fileio import -c settings.yml --api_key=sk-yyy --project=notes --file=notes.tar.gz`,
+	Args:   gcmd.NoExtraArgs,
+	PreRun: preRunFileIO,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		if err := runFileIOImport(ctx, cmd); err != nil {
+			log.Logger.Panic("import fileio project", zap.Error(err))
+		}
+	},
+}
+
+var fileioReindexCMD = &cobra.Command{
+	Use:   "reindex",
+	Short: "re-index FileIO files, e.g. after changing the embedding model",
+	Long: `Start a background re-index run for the files of an API key, one of its projects,
+or every stored file, or report the progress of runs.
+
+Jobs go through the index job outbox and are processed by the index workers of the
+API servers behind live writes. Each file keeps its previous index rows until its job
+replaces them, so search keeps serving the old index while the run is in progress.
+Providers billed to the caller's API key need --api_key. A --api_key_hash run over
+them is refused; an --all run skips their projects and reports them as skipped.
+
+Example usage:
+  go run main.go fileio reindex -c settings.yml --api_key=sk-xxx --project=notes
+  go run main.go fileio reindex -c settings.yml --all
+  go run main.go fileio reindex -c settings.yml --status=12`,
+	Args:   gcmd.NoExtraArgs,
+	PreRun: preRunFileIO,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		if err := runFileIOReindex(ctx, cmd); err != nil {
+			log.Logger.Panic("reindex fileio files", zap.Error(err))
+		}
+	},
+}
+
+func init() {
+	rootCMD.AddCommand(fileioCMD)
+	fileioCMD.AddCommand(fileioExportCMD, fileioImportCMD, fileioReindexCMD)
+
+	for _, command := range []*cobra.Command{fileioExportCMD, fileioImportCMD} {
+		command.Flags().String("api_key", "", "API key owning the project (required)")
+		command.Flags().String("project", "", "FileIO project name (required)")
+		command.Flags().String("file", "", "archive path (required)")
+		command.Flags().String("format", "", "archive format, tar.gz or zip; guessed from --file when empty")
+		for _, name := range []string{"api_key", "project", "file"} {
+			if err := command.MarkFlagRequired(name); err != nil {
+				log.Logger.Panic("mark flag required", zap.Error(err))
+			}
+		}
+	}
+	fileioExportCMD.Flags().String("prefix", "", "only export the files below this directory")
+	fileioExportCMD.Flags().Bool("include_versions", false, "include the retained version history")
+	fileioExportCMD.Flags().Bool("include_deleted", false, "include deleted files not yet purged")
+	fileioImportCMD.Flags().Bool("overwrite", false, "replace files that already exist")
+	fileioReindexCMD.Flags().String("api_key", "", "re-index the files of this API key")
+	fileioReindexCMD.Flags().String("api_key_hash", "", "re-index the files of this API key hash, without its credential")
+	fileioReindexCMD.Flags().String("project", "", "only re-index this project of the API key")
+	fileioReindexCMD.Flags().Bool("all", false, "re-index the files of every API key")
+	fileioReindexCMD.Flags().Int64("status", 0, "report the progress of this run instead of starting one")
+}
+
+// preRunFileIO loads settings and logging without starting the web modules.
+func preRunFileIO(cmd *cobra.Command, _ []string) {
+	ctx := context.Background()
+	if err := gconfig.Shared.BindPFlags(cmd.Flags()); err != nil {
+		log.Logger.Panic("bind pflags", zap.Error(err))
+	}
+	setupSettings(ctx)
+	setupLogger(ctx)
+}
+
+// runFileIOExport writes the requested project to the archive file.
+func runFileIOExport(ctx context.Context, cmd *cobra.Command) error {
+	svc, auth, format, err := prepareFileIOCommand(ctx, cmd)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	path := cmd.Flag("file").Value.String()
+	out, err := os.Create(path)
+	if err != nil {
+		return errors.Wrapf(err, "create %s", path)
+	}
+	result, err := svc.ExportProject(ctx, auth, cmd.Flag("project").Value.String(), out, files.ExportOptions{
+		Format:          format,
+		Prefix:          cmd.Flag("prefix").Value.String(),
+		IncludeVersions: cmd.Flag("include_versions").Value.String() == "true",
+		IncludeDeleted:  cmd.Flag("include_deleted").Value.String() == "true",
+	})
+	if closeErr := out.Close(); err == nil && closeErr != nil {
+		err = errors.Wrapf(closeErr, "close %s", path)
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return errors.Wrap(err, "export project")
+	}
+
+	log.Logger.Info("fileio project exported",
+		zap.String("file", path),
+		zap.Int("files", result.Files),
+		zap.Int("versions", result.Versions),
+		zap.Int("deleted", result.Deleted),
+	)
+	return nil
+}
+
+// runFileIOImport applies the archive file to the requested project.
+func runFileIOImport(ctx context.Context, cmd *cobra.Command) error {
+	svc, auth, format, err := prepareFileIOCommand(ctx, cmd)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	path := cmd.Flag("file").Value.String()
+	in, err := os.Open(path)
+	if err != nil {
+		return errors.Wrapf(err, "open %s", path)
+	}
+	defer func() { _ = in.Close() }()
+
+	result, err := svc.ImportProject(ctx, auth, cmd.Flag("project").Value.String(), in, files.ImportOptions{
+		Format:    format,
+		Overwrite: cmd.Flag("overwrite").Value.String() == "true",
+	})
+	if err != nil {
+		return errors.Wrap(err, "import project")
+	}
+
+	log.Logger.Info("fileio project imported",
+		zap.String("file", path),
+		zap.Int("files", len(result.Files)),
+		zap.Int("versions", result.Versions),
+		zap.Int("deleted", result.Deleted),
+	)
+	return nil
+}
+
+// runFileIOReindex starts a re-index run, or prints the progress of the run given by --status.
+func runFileIOReindex(ctx context.Context, cmd *cobra.Command) error {
+	svc, err := newFileIOService(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if rawID := cmd.Flag("status").Value.String(); rawID != "0" {
+		id, err := strconv.ParseInt(rawID, 10, 64)
+		if err != nil {
+			return errors.Wrap(err, "parse status")
+		}
+		run, err := svc.ReindexRunStatus(ctx, id)
+		if err != nil {
+			return errors.Wrap(err, "read reindex run")
+		}
+		logReindexRun("fileio reindex run status", run)
+		return nil
+	}
+
+	scope := files.ReindexScope{
+		APIKeyHash: strings.TrimSpace(cmd.Flag("api_key_hash").Value.String()),
+		Project:    cmd.Flag("project").Value.String(),
+	}
+	if apiKey := cmd.Flag("api_key").Value.String(); apiKey != "" {
+		derived, err := mcpauth.DeriveFromAPIKey(apiKey)
+		if err != nil {
+			return errors.Wrap(err, "parse api key")
+		}
+		if scope.APIKeyHash != "" && scope.APIKeyHash != derived.APIKeyHash {
+			return errors.New("--api_key does not match --api_key_hash")
+		}
+		scope.APIKey = derived.APIKey
+		scope.APIKeyHash = derived.APIKeyHash
+	}
+	if scope.APIKeyHash == "" && cmd.Flag("all").Value.String() != "true" {
+		return errors.New("one of --api_key, --api_key_hash or --all is required")
+	}
+
+	run, err := svc.StartReindex(ctx, scope)
+	if err != nil {
+		return errors.Wrap(err, "start reindex")
+	}
+	logReindexRun("fileio reindex run started", run)
+	return nil
+}
+
+// logReindexRun logs the progress of run.
+func logReindexRun(msg string, run files.ReindexRun) {
+	log.Logger.Info(msg,
+		zap.Int64("run_id", run.ID),
+		zap.String("status", run.Status),
+		zap.String("project", run.Project),
+		zap.Int64("total", run.TotalJobs),
+		zap.Int64("pending", run.Pending+run.Processing),
+		zap.Int64("done", run.Done),
+		zap.Int64("failed", run.Failed),
+		zap.Float64("live_p95_seconds", run.Freshness.P95Seconds),
+		zap.Float64("slo_seconds", run.Freshness.SLOSeconds),
+		zap.Bool("within_slo", run.Freshness.WithinSLO),
+	)
+	for _, skipped := range run.Skipped {
+		log.Logger.Warn("fileio reindex skipped project, rerun it with its --api_key",
+			zap.Int64("run_id", run.ID),
+			zap.String("apikey_hash", skipped.APIKeyHash),
+			zap.String("project", skipped.Project),
+			zap.String("model", skipped.Model),
+			zap.Int64("files", skipped.Files),
+		)
+	}
+}
+
+// prepareFileIOCommand connects the FileIO service to the MCP database and resolves
+// the API key and archive format flags shared by the export and import subcommands.
+func prepareFileIOCommand(ctx context.Context, cmd *cobra.Command) (*files.Service, files.AuthContext, files.ArchiveFormat, error) {
+	derived, err := mcpauth.DeriveFromAPIKey(cmd.Flag("api_key").Value.String())
+	if err != nil {
+		return nil, files.AuthContext{}, "", errors.Wrap(err, "parse api key")
+	}
+	auth := files.AuthContext{
+		APIKey:       derived.APIKey,
+		APIKeyHash:   derived.APIKeyHash,
+		UserID:       derived.UserID,
+		UserIdentity: derived.UserIdentity,
+	}
+
+	rawFormat := cmd.Flag("format").Value.String()
+	if rawFormat == "" && strings.HasSuffix(strings.ToLower(cmd.Flag("file").Value.String()), ".zip") {
+		rawFormat = string(files.ArchiveFormatZip)
+	}
+	format, err := files.ParseArchiveFormat(rawFormat)
+	if err != nil {
+		return nil, files.AuthContext{}, "", errors.WithStack(err)
+	}
+
+	svc, err := newFileIOService(ctx)
+	if err != nil {
+		return nil, files.AuthContext{}, "", errors.WithStack(err)
+	}
+	return svc, auth, format, nil
+}
+
+// newFileIOService connects the FileIO service to the MCP database.
+func newFileIOService(ctx context.Context) (*files.Service, error) {
+	dial := postgres.DialInfo{
+		Addr:   gconfig.S.GetString("settings.db.mcp.addr"),
+		DBName: gconfig.S.GetString("settings.db.mcp.db"),
+		User:   gconfig.S.GetString("settings.db.mcp.user"),
+		Pwd:    gconfig.S.GetString("settings.db.mcp.pwd"),
+	}
+	if dial.Addr == "" || dial.DBName == "" || dial.User == "" {
+		return nil, errors.New("mcp database configuration incomplete")
+	}
+	mcpDB, err := postgres.NewDB(ctx, dial)
+	if err != nil {
+		return nil, errors.Wrap(err, "new mcp postgres")
+	}
+
+	filesSettings := files.LoadSettingsFromConfig()
+	credential, err := buildFileCredentialProtector(filesSettings)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid mcp files credential configuration")
+	}
+	// Imports and re-index runs enqueue index jobs, which need the same credential
+	// envelope the API server stores for its index workers.
+	rdb := rlibs.NewDB(&redis.Options{
+		Addr: gconfig.S.GetString("settings.db.redis.addr"),
+		DB:   gconfig.S.GetInt("settings.db.redis.db"),
+	})
+	credStore := files.NewRedisCredentialStore(rdb.GetDB())
+
+	svc, err := files.NewService(mcpDB.DB, filesSettings, nil, nil, credential, credStore, log.Logger.Named("fileio_cmd"), nil, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "new files service")
+	}
+	return svc, nil
+}
@@ -17,19 +17,28 @@ import (
 	searchlib "github.com/Laisky/laisky-blog-graphql/library/search"
 )
 
+// BillingReporter charges price to the owner of apiKey for the named reason.
+type BillingReporter func(ctx context.Context, apiKey string, price oneapi.Price, reason string) error
+
 // MutationResolver is the resolver for mutation.
 type MutationResolver struct {
 	provider   searchlib.Provider
 	rdb        *rlibs.DB
 	calllogger *calllog.Service
+	billing    BillingReporter
 }
 
 // NewMutationResolver is the constructor for MutationResolver.
-func NewMutationResolver(provider searchlib.Provider, rdb *rlibs.DB, calllogger *calllog.Service) *MutationResolver {
+// A nil billing reporter charges through oneapi.CheckUserExternalBilling.
+func NewMutationResolver(provider searchlib.Provider, rdb *rlibs.DB, calllogger *calllog.Service, billing BillingReporter) *MutationResolver {
+	if billing == nil {
+		billing = oneapi.CheckUserExternalBilling
+	}
 	return &MutationResolver{
 		provider:   provider,
 		rdb:        rdb,
 		calllogger: calllogger,
+		billing:    billing,
 	}
 }
 
@@ -53,7 +62,7 @@ func (r *MutationResolver) WebFetch(ctx context.Context, url string) (*models.We
 		return nil, errors.New("cannot get apikey")
 	}
 
-	err := oneapi.CheckUserExternalBilling(ctx, apikey, oneapi.PriceWebFetch, "web_fetch")
+	err := r.billing(ctx, apikey, oneapi.PriceWebFetch, "web_fetch")
 	if err != nil {
 		return nil, errors.Wrap(err, "check user external billing")
 	}
@@ -97,7 +106,10 @@ func (r *MutationResolver) WebFetch(ctx context.Context, url string) (*models.We
 }
 
 // WebSearch is the resolver for webSearch field.
-func (r *MutationResolver) WebSearch(ctx context.Context, query string) (*searchlib.SearchResult, error) {
+//
+// When fanout is true the query is sent to up to maxEngines engines in parallel and
+// their results are fused; every engine that contributed results is billed.
+func (r *MutationResolver) WebSearch(ctx context.Context, query string, fanout *bool, maxEngines *int) (*searchlib.SearchResult, error) {
 	startAt := time.Now()
 	logger := gmw.GetLogger(ctx).
 		Named("web_search").
@@ -112,7 +124,7 @@ func (r *MutationResolver) WebSearch(ctx context.Context, query string) (*search
 		return nil, errors.New("cannot get apikey")
 	}
 
-	err := oneapi.CheckUserExternalBilling(ctx, apikey, oneapi.PriceWebSearch, "web_search")
+	err := r.billing(ctx, apikey, oneapi.PriceWebSearch, "web_search")
 	if err != nil {
 		return nil, errors.Wrap(err, "check user external billing")
 	}
@@ -121,7 +133,33 @@ func (r *MutationResolver) WebSearch(ctx context.Context, query string) (*search
 		return nil, errors.New("web search provider is not configured")
 	}
 
-	output, err := r.provider.Search(ctx, query)
+	var (
+		output *searchlib.SearchOutput
+		cost   = oneapi.PriceWebSearch
+	)
+	if fanout != nil && *fanout {
+		fanOutProvider, ok := r.provider.(searchlib.FanOutProvider)
+		if !ok {
+			return nil, errors.New("fanout search is not supported by the configured search provider")
+		}
+
+		engines := 0
+		if maxEngines != nil {
+			engines = *maxEngines
+		}
+		output, err = fanOutProvider.SearchFanOut(ctx, query, searchlib.ClampFanOutEngines(engines))
+		// The upfront check covers the first engine; every further contributing engine is billed here.
+		if err == nil && len(output.Engines) > 1 {
+			extraCost := oneapi.PriceWebSearch * oneapi.Price(len(output.Engines)-1)
+			if err = r.billing(ctx, apikey, extraCost, "web_search"); err != nil {
+				err = errors.Wrap(err, "check user external billing for fanout engines")
+			} else {
+				cost += extraCost
+			}
+		}
+	} else {
+		output, err = r.provider.Search(ctx, query)
+	}
 	status := calllog.StatusSuccess
 	var errMsg string
 	if err != nil {
@@ -131,6 +169,9 @@ func (r *MutationResolver) WebSearch(ctx context.Context, query string) (*search
 
 	if r.calllogger != nil {
 		params := map[string]any{"query": query}
+		if fanout != nil && *fanout {
+			params["fanout"] = true
+		}
 		if output != nil {
 			params["engine_name"] = output.EngineName
 			params["engine_type"] = output.EngineType
@@ -139,7 +180,7 @@ func (r *MutationResolver) WebSearch(ctx context.Context, query string) (*search
 			ToolName:     "web_search",
 			APIKey:       apikey,
 			Status:       status,
-			Cost:         oneapi.PriceWebSearch.Int(),
+			Cost:         cost.Int(),
 			Duration:     time.Since(startAt),
 			Parameters:   params,
 			ErrorMessage: errMsg,
@@ -158,6 +199,7 @@ func (r *MutationResolver) WebSearch(ctx context.Context, query string) (*search
 		CreatedAt:  time.Now(),
 		EngineName: output.EngineName,
 		EngineType: output.EngineType,
+		Engines:    output.Engines,
 	}
 
 	result.Results = append(result.Results, output.Items...)
@@ -151,3 +151,42 @@ func TestServiceRecordWithCancelledContext(t *testing.T) {
 	require.NoError(t, err)
 	require.NoError(t, db.ExpectationsWereMet())
 }
+
+func TestServiceToolStats(t *testing.T) {
+	db, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	t.Cleanup(db.Close)
+
+	db.ExpectExec("CREATE TABLE IF NOT EXISTS mcp_call_logs").WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	db.ExpectExec("CREATE INDEX IF NOT EXISTS idx_mcp_call_logs_tool_name").WillReturnResult(pgxmock.NewResult("CREATE INDEX", 0))
+	db.ExpectExec("CREATE INDEX IF NOT EXISTS idx_mcp_call_logs_api_key_hash").WillReturnResult(pgxmock.NewResult("CREATE INDEX", 0))
+	db.ExpectExec("CREATE INDEX IF NOT EXISTS idx_mcp_call_logs_key_prefix").WillReturnResult(pgxmock.NewResult("CREATE INDEX", 0))
+	db.ExpectExec("CREATE INDEX IF NOT EXISTS idx_mcp_call_logs_status").WillReturnResult(pgxmock.NewResult("CREATE INDEX", 0))
+	db.ExpectExec("CREATE INDEX IF NOT EXISTS idx_mcp_call_logs_occurred_at").WillReturnResult(pgxmock.NewResult("CREATE INDEX", 0))
+	svc, err := NewService(db, nil, nil)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	alphaHash, _ := normalizeAPIKey("token-alpha")
+
+	db.ExpectQuery(`SELECT tool_name, COUNT\(\*\), COUNT\(\*\) FILTER \(WHERE status = \$1\)\s+FROM mcp_call_logs WHERE occurred_at >= \$2 AND api_key_hash = \$3\s+GROUP BY tool_name`).
+		WithArgs(StatusSuccess, since, alphaHash).
+		WillReturnRows(pgxmock.NewRows([]string{"tool_name", "count", "count"}).
+			AddRow("web_search", int64(10), int64(9)).
+			AddRow("file_read", int64(4), int64(1)))
+	stats, err := svc.ToolStats(ctx, ToolStatsOptions{APIKey: "token-alpha", Since: since})
+	require.NoError(t, err)
+	require.Equal(t, []ToolStat{
+		{ToolName: "web_search", Calls: 10, Successes: 9},
+		{ToolName: "file_read", Calls: 4, Successes: 1},
+	}, stats)
+
+	db.ExpectQuery(`FROM mcp_call_logs\s+GROUP BY tool_name`).
+		WithArgs(StatusSuccess).
+		WillReturnRows(pgxmock.NewRows([]string{"tool_name", "count", "count"}))
+	stats, err = svc.ToolStats(ctx, ToolStatsOptions{})
+	require.NoError(t, err)
+	require.Empty(t, stats)
+	require.NoError(t, db.ExpectationsWereMet())
+}
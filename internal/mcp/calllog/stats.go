@@ -0,0 +1,70 @@
+package calllog
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	errors "github.com/Laisky/errors/v2"
+)
+
+// ToolStat summarizes the recorded calls of one tool.
+type ToolStat struct {
+	ToolName  string
+	Calls     int64
+	Successes int64
+}
+
+// ToolStatsOptions filters the calls aggregated by ToolStats.
+type ToolStatsOptions struct {
+	// APIKey restricts the stats to one caller when set. It is hashed like in Record.
+	APIKey string
+	// Since restricts the stats to calls that occurred at or after this time when set.
+	Since time.Time
+}
+
+// ToolStats aggregates the number of calls and successful calls per tool.
+func (s *Service) ToolStats(ctx context.Context, opts ToolStatsOptions) ([]ToolStat, error) {
+	if s == nil {
+		return nil, errors.New("call log service is nil")
+	}
+
+	clauses := make([]string, 0, 2)
+	args := []any{StatusSuccess}
+	if !opts.Since.IsZero() {
+		args = append(args, opts.Since)
+		clauses = append(clauses, fmt.Sprintf("occurred_at >= $%d", len(args)))
+	}
+	if hash, _ := normalizeAPIKey(opts.APIKey); hash != "" {
+		args = append(args, hash)
+		clauses = append(clauses, fmt.Sprintf("api_key_hash = $%d", len(args)))
+	}
+	whereSQL := ""
+	if len(clauses) > 0 {
+		whereSQL = " WHERE " + strings.Join(clauses, " AND ")
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT tool_name, COUNT(*), COUNT(*) FILTER (WHERE status = $1)
+		FROM mcp_call_logs`+whereSQL+`
+		GROUP BY tool_name`, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "query call log tool stats")
+	}
+	defer rows.Close()
+
+	var stats []ToolStat
+	for rows.Next() {
+		var stat ToolStat
+		if err := rows.Scan(&stat.ToolName, &stat.Calls, &stat.Successes); err != nil {
+			return nil, errors.Wrap(err, "scan call log tool stats")
+		}
+		stats = append(stats, stat)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate call log tool stats")
+	}
+
+	return stats, nil
+}
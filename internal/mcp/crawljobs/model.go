@@ -0,0 +1,56 @@
+package crawljobs
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Status enumerations for web_crawl jobs.
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCanceled  = "canceled"
+)
+
+const (
+	// MaxActiveJobsPerKey caps the number of pending or running crawls a single API key can own.
+	MaxActiveJobsPerKey = 3
+	// DefaultStaleAfter is how long a running crawl may go without a checkpoint or
+	// heartbeat before it is reported as interrupted.
+	DefaultStaleAfter = 3 * time.Minute
+)
+
+// Job persists the spec and the resumable progress of a single web_crawl job.
+type Job struct {
+	ID         uuid.UUID
+	APIKeyHash string
+	Status     string
+	Spec       []byte
+	// State is the crawl frontier and counters stored at the last checkpoint.
+	State           []byte
+	ErrorMessage    string
+	CancelRequested bool
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	StartedAt       *time.Time
+	FinishedAt      *time.Time
+	HeartbeatAt     *time.Time
+}
+
+// Terminal reports whether the job has reached a final status.
+func (j *Job) Terminal() bool {
+	switch j.Status {
+	case StatusSucceeded, StatusFailed, StatusCanceled:
+		return true
+	default:
+		return false
+	}
+}
+
+// Resumable reports whether the job stopped before finishing and can continue from its checkpoint.
+func (j *Job) Resumable() bool {
+	return j.Status == StatusFailed || j.Status == StatusCanceled
+}
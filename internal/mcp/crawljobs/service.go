@@ -0,0 +1,436 @@
+package crawljobs
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	errors "github.com/Laisky/errors/v2"
+	gutils "github.com/Laisky/go-utils/v6"
+	logSDK "github.com/Laisky/go-utils/v6/log"
+	"github.com/Laisky/zap"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Laisky/laisky-blog-graphql/library/log"
+)
+
+var (
+	// ErrJobNotFound indicates that the job does not exist or belongs to another API key.
+	ErrJobNotFound = errors.New("crawl job not found")
+	// ErrActiveJobLimit indicates that the API key already owns too many active crawls.
+	ErrActiveJobLimit = errors.New("too many active crawl jobs")
+	// ErrMissingAPIKeyHash indicates that a job was requested without a caller identity.
+	ErrMissingAPIKeyHash = errors.New("api key hash is required")
+	// ErrJobNotResumable indicates that the job is still active or already succeeded.
+	ErrJobNotResumable = errors.New("crawl job is not resumable")
+)
+
+// Clock provides the current time in UTC.
+type Clock func() time.Time
+
+// DB defines the database capabilities required by the crawl job service.
+type DB interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// Service persists web_crawl jobs and their checkpoints.
+type Service struct {
+	db         DB
+	logger     logSDK.Logger
+	clock      Clock
+	staleAfter time.Duration
+}
+
+const jobColumns = `id, api_key_hash, status, spec, state, error_message, cancel_requested,
+	created_at, updated_at, started_at, finished_at, heartbeat_at`
+
+// NewService constructs a Service backed by the supplied PostgreSQL connection.
+func NewService(db DB, logger logSDK.Logger, clock Clock) (*Service, error) {
+	if db == nil {
+		return nil, errors.New("database is required")
+	}
+	if logger == nil {
+		logger = log.Logger.Named("crawl_job_service")
+	}
+	if clock == nil {
+		clock = func() time.Time {
+			return time.Now().UTC()
+		}
+	}
+
+	if err := runMigrations(context.Background(), db); err != nil {
+		return nil, errors.Wrap(err, "migrate web_crawl jobs")
+	}
+
+	return &Service{db: db, logger: logger, clock: clock, staleAfter: DefaultStaleAfter}, nil
+}
+
+// Create stores a new pending job owned by apiKeyHash.
+func (s *Service) Create(ctx context.Context, apiKeyHash string, spec any) (*Job, error) {
+	if s == nil {
+		return nil, errors.New("crawl job service is nil")
+	}
+	apiKeyHash = strings.TrimSpace(apiKeyHash)
+	if apiKeyHash == "" {
+		return nil, ErrMissingAPIKeyHash
+	}
+
+	payload, err := json.Marshal(spec)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal crawl job spec")
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "begin crawl job transaction")
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := s.checkActiveLimit(ctx, tx, apiKeyHash); err != nil {
+		return nil, err
+	}
+
+	now := s.clock()
+	job := &Job{
+		ID:         gutils.UUID7Bytes(),
+		APIKeyHash: apiKeyHash,
+		Status:     StatusPending,
+		Spec:       payload,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO mcp_crawl_jobs (id, api_key_hash, status, spec, created_at, updated_at)
+		VALUES ($1, $2, $3, $4::jsonb, $5, $6)
+	`,
+		job.ID,
+		job.APIKeyHash,
+		job.Status,
+		string(job.Spec),
+		job.CreatedAt,
+		job.UpdatedAt,
+	); err != nil {
+		return nil, errors.Wrap(err, "create crawl job")
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, errors.Wrap(err, "commit crawl job")
+	}
+
+	s.logger.Debug("created crawl job", zap.String("job_id", job.ID.String()))
+	return job, nil
+}
+
+// MarkRunning transitions a pending job to running. It returns false when the
+// job was canceled before the worker picked it up.
+func (s *Service) MarkRunning(ctx context.Context, jobID uuid.UUID) (bool, error) {
+	if s == nil {
+		return false, errors.New("crawl job service is nil")
+	}
+
+	now := s.clock()
+	tag, err := s.db.Exec(ctx, `
+		UPDATE mcp_crawl_jobs
+		SET status = $2, started_at = COALESCE(started_at, $3), heartbeat_at = $3, updated_at = $3
+		WHERE id = $1 AND status = $4 AND NOT cancel_requested
+	`, jobID, StatusRunning, now, StatusPending)
+	if err != nil {
+		return false, errors.Wrap(err, "mark crawl job running")
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// Checkpoint stores the crawl state of a running job and refreshes its liveness.
+// It reports whether the owner has requested cancellation.
+func (s *Service) Checkpoint(ctx context.Context, jobID uuid.UUID, state any) (bool, error) {
+	if s == nil {
+		return false, errors.New("crawl job service is nil")
+	}
+
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return false, errors.Wrap(err, "marshal crawl job state")
+	}
+
+	now := s.clock()
+	var cancelRequested bool
+	err = s.db.QueryRow(ctx, `
+		UPDATE mcp_crawl_jobs
+		SET state = $2::jsonb, heartbeat_at = $3, updated_at = $3
+		WHERE id = $1 AND status = $4
+		RETURNING cancel_requested
+	`, jobID, string(payload), now, StatusRunning).Scan(&cancelRequested)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, ErrJobNotFound
+		}
+		return false, errors.Wrap(err, "checkpoint crawl job")
+	}
+	return cancelRequested, nil
+}
+
+// Heartbeat refreshes the liveness of a running job without touching its state, so
+// steps that take long between checkpoints do not get the job reported as stale.
+// It reports whether the owner has requested cancellation.
+func (s *Service) Heartbeat(ctx context.Context, jobID uuid.UUID) (bool, error) {
+	if s == nil {
+		return false, errors.New("crawl job service is nil")
+	}
+
+	now := s.clock()
+	var cancelRequested bool
+	err := s.db.QueryRow(ctx, `
+		UPDATE mcp_crawl_jobs
+		SET heartbeat_at = $2, updated_at = $2
+		WHERE id = $1 AND status = $3
+		RETURNING cancel_requested
+	`, jobID, now, StatusRunning).Scan(&cancelRequested)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, ErrJobNotFound
+		}
+		return false, errors.Wrap(err, "heartbeat crawl job")
+	}
+	return cancelRequested, nil
+}
+
+// Finish records the final status and crawl state of a job.
+func (s *Service) Finish(ctx context.Context, jobID uuid.UUID, status string, state any, errMsg string) error {
+	if s == nil {
+		return errors.New("crawl job service is nil")
+	}
+	switch status {
+	case StatusSucceeded, StatusFailed, StatusCanceled:
+	default:
+		return errors.Errorf("invalid terminal status %q", status)
+	}
+
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return errors.Wrap(err, "marshal crawl job state")
+	}
+
+	// Use a detached context so the final state is stored even when the job was canceled.
+	ctx = context.WithoutCancel(ctx)
+	now := s.clock()
+	if _, err := s.db.Exec(ctx, `
+		UPDATE mcp_crawl_jobs
+		SET status = $2, state = $3::jsonb, error_message = $4,
+			finished_at = $5, heartbeat_at = $5, updated_at = $5
+		WHERE id = $1
+	`, jobID, status, string(payload), strings.TrimSpace(errMsg), now); err != nil {
+		return errors.Wrap(err, "finish crawl job")
+	}
+
+	s.logger.Debug("finished crawl job", zap.String("job_id", jobID.String()), zap.String("status", status))
+	return nil
+}
+
+// Get loads a job owned by apiKeyHash. Jobs whose worker stopped checkpointing
+// are reported as failed, which makes them resumable.
+func (s *Service) Get(ctx context.Context, apiKeyHash string, jobID string) (*Job, error) {
+	if s == nil {
+		return nil, errors.New("crawl job service is nil")
+	}
+	id, err := parseJobID(apiKeyHash, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := s.load(ctx, apiKeyHash, id)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if job.Terminal() {
+		return job, nil
+	}
+
+	lastSeen := job.CreatedAt
+	if job.HeartbeatAt != nil {
+		lastSeen = *job.HeartbeatAt
+	}
+	if s.clock().Sub(lastSeen) <= s.staleAfter {
+		return job, nil
+	}
+
+	now := s.clock()
+	if _, err := s.db.Exec(ctx, `
+		UPDATE mcp_crawl_jobs
+		SET status = $2, error_message = $3, finished_at = $4, updated_at = $4
+		WHERE id = $1 AND status IN ('pending', 'running')
+	`, id, StatusFailed, "crawl interrupted: worker stopped reporting progress", now); err != nil {
+		return nil, errors.Wrap(err, "mark stale crawl job failed")
+	}
+	s.logger.Info("marked stale crawl job failed", zap.String("job_id", id.String()))
+
+	return s.load(ctx, apiKeyHash, id)
+}
+
+// RequestCancel flags a job owned by apiKeyHash for cancellation. Pending jobs
+// are canceled immediately; running jobs stop at the worker's next checkpoint.
+// Jobs that already finished are returned unchanged.
+func (s *Service) RequestCancel(ctx context.Context, apiKeyHash string, jobID string) (*Job, error) {
+	if s == nil {
+		return nil, errors.New("crawl job service is nil")
+	}
+	id, err := parseJobID(apiKeyHash, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.clock()
+	if _, err := s.db.Exec(ctx, `
+		UPDATE mcp_crawl_jobs
+		SET cancel_requested = TRUE,
+			status = CASE WHEN status = 'pending' THEN 'canceled' ELSE status END,
+			finished_at = CASE WHEN status = 'pending' THEN $3 ELSE finished_at END,
+			updated_at = $3
+		WHERE id = $1 AND api_key_hash = $2 AND status IN ('pending', 'running')
+	`, id, apiKeyHash, now); err != nil {
+		return nil, errors.Wrap(err, "request crawl job cancel")
+	}
+
+	return s.load(ctx, apiKeyHash, id)
+}
+
+// Resume moves a failed or canceled job owned by apiKeyHash back to pending, keeping
+// its last checkpoint so that a worker continues where the crawl stopped.
+// It returns ErrJobNotResumable for jobs that are still active or already succeeded.
+func (s *Service) Resume(ctx context.Context, apiKeyHash string, jobID string) (*Job, error) {
+	job, err := s.Get(ctx, apiKeyHash, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if !job.Resumable() {
+		return nil, ErrJobNotResumable
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "begin crawl job transaction")
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := s.checkActiveLimit(ctx, tx, apiKeyHash); err != nil {
+		return nil, err
+	}
+
+	now := s.clock()
+	tag, err := tx.Exec(ctx, `
+		UPDATE mcp_crawl_jobs
+		SET status = $3, cancel_requested = FALSE, error_message = '',
+			finished_at = NULL, heartbeat_at = $4, updated_at = $4
+		WHERE id = $1 AND api_key_hash = $2 AND status IN ('failed', 'canceled')
+	`, job.ID, apiKeyHash, StatusPending, now)
+	if err != nil {
+		return nil, errors.Wrap(err, "resume crawl job")
+	}
+	if tag.RowsAffected() == 0 {
+		// Another request resumed the job first.
+		return nil, ErrJobNotResumable
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, errors.Wrap(err, "commit crawl job resume")
+	}
+
+	s.logger.Debug("resumed crawl job", zap.String("job_id", job.ID.String()))
+	return s.load(ctx, apiKeyHash, job.ID)
+}
+
+// checkActiveLimit returns ErrActiveJobLimit when apiKeyHash owns too many live jobs.
+// It holds a per-key advisory lock until tx ends, so concurrent submissions of one key
+// count each other's jobs once they commit.
+func (s *Service) checkActiveLimit(ctx context.Context, tx pgx.Tx, apiKeyHash string) error {
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, "mcp_crawl_jobs:"+apiKeyHash); err != nil {
+		return errors.Wrap(err, "lock crawl jobs of api key")
+	}
+	var active int64
+	if err := tx.QueryRow(ctx, `
+		SELECT COUNT(*) FROM mcp_crawl_jobs
+		WHERE api_key_hash = $1
+			AND status IN ('pending', 'running')
+			AND COALESCE(heartbeat_at, created_at) > $2
+	`, apiKeyHash, s.clock().Add(-s.staleAfter)).Scan(&active); err != nil {
+		return errors.Wrap(err, "count active crawl jobs")
+	}
+	if active >= MaxActiveJobsPerKey {
+		return ErrActiveJobLimit
+	}
+	return nil
+}
+
+// load reads a job scoped to apiKeyHash.
+func (s *Service) load(ctx context.Context, apiKeyHash string, id uuid.UUID) (*Job, error) {
+	var job Job
+	err := s.db.QueryRow(ctx, `SELECT `+jobColumns+` FROM mcp_crawl_jobs WHERE id = $1 AND api_key_hash = $2`,
+		id, apiKeyHash,
+	).Scan(
+		&job.ID,
+		&job.APIKeyHash,
+		&job.Status,
+		&job.Spec,
+		&job.State,
+		&job.ErrorMessage,
+		&job.CancelRequested,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+		&job.StartedAt,
+		&job.FinishedAt,
+		&job.HeartbeatAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrJobNotFound
+		}
+		return nil, errors.Wrap(err, "load crawl job")
+	}
+	return &job, nil
+}
+
+// parseJobID validates the caller identity and job identifier.
+func parseJobID(apiKeyHash string, jobID string) (uuid.UUID, error) {
+	if strings.TrimSpace(apiKeyHash) == "" {
+		return uuid.Nil, ErrMissingAPIKeyHash
+	}
+	id, err := uuid.Parse(strings.TrimSpace(jobID))
+	if err != nil {
+		return uuid.Nil, ErrJobNotFound
+	}
+	return id, nil
+}
+
+// runMigrations creates the crawl job table and indexes when absent.
+func runMigrations(ctx context.Context, db DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS mcp_crawl_jobs (
+			id UUID PRIMARY KEY,
+			api_key_hash CHAR(64) NOT NULL,
+			status VARCHAR(16) NOT NULL,
+			spec JSONB NOT NULL,
+			state JSONB,
+			error_message TEXT NOT NULL DEFAULT '',
+			cancel_requested BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMPTZ NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL,
+			started_at TIMESTAMPTZ,
+			finished_at TIMESTAMPTZ,
+			heartbeat_at TIMESTAMPTZ
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_mcp_crawl_jobs_api_key_status ON mcp_crawl_jobs (api_key_hash, status)`,
+		`CREATE INDEX IF NOT EXISTS idx_mcp_crawl_jobs_created_at ON mcp_crawl_jobs (created_at DESC)`,
+	}
+
+	for _, stmt := range statements {
+		if _, err := db.Exec(ctx, stmt); err != nil {
+			return errors.Wrap(err, "execute crawl job migration")
+		}
+	}
+
+	return nil
+}
+
+var _ DB = (*pgxpool.Pool)(nil)
@@ -0,0 +1,175 @@
+package crawljobs
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestService builds a Service over pgxmock with migrations satisfied.
+func newTestService(t *testing.T, now time.Time) (*Service, pgxmock.PgxPoolIface) {
+	t.Helper()
+
+	db, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	t.Cleanup(db.Close)
+
+	db.ExpectExec("CREATE TABLE IF NOT EXISTS mcp_crawl_jobs").WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	db.ExpectExec("CREATE INDEX IF NOT EXISTS idx_mcp_crawl_jobs_api_key_status").WillReturnResult(pgxmock.NewResult("CREATE INDEX", 0))
+	db.ExpectExec("CREATE INDEX IF NOT EXISTS idx_mcp_crawl_jobs_created_at").WillReturnResult(pgxmock.NewResult("CREATE INDEX", 0))
+
+	svc, err := NewService(db, nil, func() time.Time { return now })
+	require.NoError(t, err)
+	return svc, db
+}
+
+// jobRows returns a single-row result shaped like jobColumns.
+func jobRows(id uuid.UUID, status string, created time.Time, heartbeat *time.Time) *pgxmock.Rows {
+	return pgxmock.NewRows([]string{
+		"id", "api_key_hash", "status", "spec", "state", "error_message", "cancel_requested",
+		"created_at", "updated_at", "started_at", "finished_at", "heartbeat_at",
+	}).AddRow(
+		id, "hash", status, []byte(`{}`), []byte(`{"fetched":3}`), "", false,
+		created, created, heartbeat, (*time.Time)(nil), heartbeat,
+	)
+}
+
+func TestServiceCreate(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	svc, db := newTestService(t, now)
+	ctx := context.Background()
+
+	db.ExpectBegin()
+	db.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock")).
+		WithArgs("mcp_crawl_jobs:hash").
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	db.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM mcp_crawl_jobs")).
+		WithArgs("hash", now.Add(-DefaultStaleAfter)).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(0)))
+	db.ExpectExec(regexp.QuoteMeta("INSERT INTO mcp_crawl_jobs")).
+		WithArgs(pgxmock.AnyArg(), "hash", StatusPending, `{"project":"docs"}`, now, now).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	db.ExpectCommit()
+
+	job, err := svc.Create(ctx, "hash", map[string]any{"project": "docs"})
+	require.NoError(t, err)
+	require.Equal(t, StatusPending, job.Status)
+	require.NotEqual(t, uuid.Nil, job.ID)
+
+	db.ExpectBegin()
+	db.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock")).
+		WithArgs("mcp_crawl_jobs:hash").
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	db.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM mcp_crawl_jobs")).
+		WithArgs("hash", now.Add(-DefaultStaleAfter)).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(MaxActiveJobsPerKey)))
+	db.ExpectRollback()
+	_, err = svc.Create(ctx, "hash", map[string]any{})
+	require.ErrorIs(t, err, ErrActiveJobLimit)
+
+	_, err = svc.Create(ctx, " ", map[string]any{})
+	require.ErrorIs(t, err, ErrMissingAPIKeyHash)
+
+	require.NoError(t, db.ExpectationsWereMet())
+}
+
+func TestServiceCheckpointReportsCancel(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	svc, db := newTestService(t, now)
+	id := uuid.New()
+
+	db.ExpectQuery(regexp.QuoteMeta("UPDATE mcp_crawl_jobs")).
+		WithArgs(id, `{"fetched":1}`, now, StatusRunning).
+		WillReturnRows(pgxmock.NewRows([]string{"cancel_requested"}).AddRow(true))
+
+	canceled, err := svc.Checkpoint(context.Background(), id, map[string]any{"fetched": 1})
+	require.NoError(t, err)
+	require.True(t, canceled)
+
+	require.NoError(t, db.ExpectationsWereMet())
+}
+
+func TestServiceHeartbeat(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	svc, db := newTestService(t, now)
+	id := uuid.New()
+
+	db.ExpectQuery(regexp.QuoteMeta("UPDATE mcp_crawl_jobs")).
+		WithArgs(id, now, StatusRunning).
+		WillReturnRows(pgxmock.NewRows([]string{"cancel_requested"}).AddRow(false))
+	canceled, err := svc.Heartbeat(context.Background(), id)
+	require.NoError(t, err)
+	require.False(t, canceled)
+
+	db.ExpectQuery(regexp.QuoteMeta("UPDATE mcp_crawl_jobs")).
+		WithArgs(id, now, StatusRunning).
+		WillReturnRows(pgxmock.NewRows([]string{"cancel_requested"}))
+	_, err = svc.Heartbeat(context.Background(), id)
+	require.ErrorIs(t, err, ErrJobNotFound)
+
+	require.NoError(t, db.ExpectationsWereMet())
+}
+
+func TestServiceResumeStaleJob(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	svc, db := newTestService(t, now)
+	ctx := context.Background()
+	id := uuid.New()
+	heartbeat := now.Add(-DefaultStaleAfter - time.Second)
+
+	// The stale running job is first reported as failed ...
+	db.ExpectQuery(regexp.QuoteMeta("FROM mcp_crawl_jobs WHERE id = $1 AND api_key_hash = $2")).
+		WithArgs(id, "hash").
+		WillReturnRows(jobRows(id, StatusRunning, heartbeat, &heartbeat))
+	db.ExpectExec(regexp.QuoteMeta("UPDATE mcp_crawl_jobs")).
+		WithArgs(id, StatusFailed, pgxmock.AnyArg(), now).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	db.ExpectQuery(regexp.QuoteMeta("FROM mcp_crawl_jobs WHERE id = $1 AND api_key_hash = $2")).
+		WithArgs(id, "hash").
+		WillReturnRows(jobRows(id, StatusFailed, heartbeat, &heartbeat))
+	// ... then moved back to pending with its checkpoint intact.
+	db.ExpectBegin()
+	db.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock")).
+		WithArgs("mcp_crawl_jobs:hash").
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	db.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM mcp_crawl_jobs")).
+		WithArgs("hash", now.Add(-DefaultStaleAfter)).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(0)))
+	db.ExpectExec(regexp.QuoteMeta("UPDATE mcp_crawl_jobs")).
+		WithArgs(id, "hash", StatusPending, now).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	db.ExpectCommit()
+	db.ExpectQuery(regexp.QuoteMeta("FROM mcp_crawl_jobs WHERE id = $1 AND api_key_hash = $2")).
+		WithArgs(id, "hash").
+		WillReturnRows(jobRows(id, StatusPending, heartbeat, &now))
+
+	job, err := svc.Resume(ctx, "hash", id.String())
+	require.NoError(t, err)
+	require.Equal(t, StatusPending, job.Status)
+	require.JSONEq(t, `{"fetched":3}`, string(job.State))
+
+	require.NoError(t, db.ExpectationsWereMet())
+}
+
+func TestServiceResumeRejectsSucceededJob(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	svc, db := newTestService(t, now)
+	id := uuid.New()
+
+	db.ExpectQuery(regexp.QuoteMeta("FROM mcp_crawl_jobs WHERE id = $1 AND api_key_hash = $2")).
+		WithArgs(id, "hash").
+		WillReturnRows(jobRows(id, StatusSucceeded, now, &now))
+
+	_, err := svc.Resume(context.Background(), "hash", id.String())
+	require.ErrorIs(t, err, ErrJobNotResumable)
+
+	_, err = svc.Resume(context.Background(), "hash", "not-a-uuid")
+	require.ErrorIs(t, err, ErrJobNotFound)
+
+	require.NoError(t, db.ExpectationsWereMet())
+}
@@ -8,4 +8,6 @@ const (
 	Logger Key = "mcp_logger"
 	// AuthContext stores the normalized authorization context.
 	AuthContext Key = "mcp_auth_context"
+	// PipeStepAttempt stores the mcp_pipe step attempt that issued a tool call.
+	PipeStepAttempt Key = "mcp_pipe_step_attempt"
 )
@@ -0,0 +1,430 @@
+package federation
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	errors "github.com/Laisky/errors/v2"
+	logSDK "github.com/Laisky/go-utils/v6/log"
+	"github.com/Laisky/zap"
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	mcp "github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/Laisky/laisky-blog-graphql/library/billing/oneapi"
+)
+
+const (
+	// maxToolNameLength is the longest tool name accepted by common MCP clients.
+	maxToolNameLength = 64
+
+	defaultReconnectMinBackoff = time.Second
+	defaultReconnectMaxBackoff = time.Minute
+)
+
+// toolNamePattern matches the tool names that can be re-exported unchanged after the prefix.
+var toolNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Client is the subset of the mcp-go client used to talk to an upstream.
+type Client interface {
+	Initialize(ctx context.Context, request mcp.InitializeRequest) (*mcp.InitializeResult, error)
+	ListTools(ctx context.Context, request mcp.ListToolsRequest) (*mcp.ListToolsResult, error)
+	CallTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error)
+	Close() error
+}
+
+// Dialer starts a new client for an upstream, used to re-establish a dropped session.
+type Dialer func(ctx context.Context) (Client, error)
+
+// AuthorizationProvider returns the caller's Authorization header for the request in ctx.
+type AuthorizationProvider func(ctx context.Context) string
+
+// Tool is an upstream tool re-exported under a namespaced name.
+type Tool struct {
+	// Definition is the upstream definition renamed to the namespaced name.
+	Definition mcp.Tool
+	// Upstream is the name of the upstream that serves the tool.
+	Upstream string
+	// RemoteName is the tool name on the upstream.
+	RemoteName string
+	// Price is billed per successful call.
+	Price oneapi.Price
+}
+
+// upstream is one connected upstream MCP server.
+type upstream struct {
+	settings UpstreamSettings
+	client   Client
+	// dial reconnects the upstream; nil when the client was attached by the caller.
+	dial Dialer
+	// reconnecting is set while a reconnect loop runs for the upstream.
+	reconnecting bool
+}
+
+// Manager keeps the connections to the upstream MCP servers and routes calls to them.
+type Manager struct {
+	logger        logSDK.Logger
+	authorization AuthorizationProvider
+
+	mu        sync.RWMutex
+	upstreams map[string]*upstream
+	tools     map[string]*Tool
+
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	closed     chan struct{}
+	closeOnce  sync.Once
+}
+
+// NewManager creates a Manager without upstreams.
+func NewManager(logger logSDK.Logger) (*Manager, error) {
+	if logger == nil {
+		return nil, errors.New("logger is required")
+	}
+
+	return &Manager{
+		logger:     logger,
+		upstreams:  make(map[string]*upstream),
+		tools:      make(map[string]*Tool),
+		minBackoff: defaultReconnectMinBackoff,
+		maxBackoff: defaultReconnectMaxBackoff,
+		closed:     make(chan struct{}),
+	}, nil
+}
+
+// WithAuthorization sets how the caller's Authorization header is read for upstreams
+// configured with forward_authorization.
+func (m *Manager) WithAuthorization(provider AuthorizationProvider) *Manager {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.authorization = provider
+	return m
+}
+
+// ConnectAll connects every configured upstream. An upstream that fails to connect is
+// logged and skipped so one unavailable server does not take the others down.
+// It returns the number of connected upstreams.
+func (m *Manager) ConnectAll(ctx context.Context, settings Settings) int {
+	connected := 0
+	for _, cfg := range settings.Upstreams {
+		if err := m.Connect(ctx, cfg); err != nil {
+			m.logger.Warn("connect upstream mcp server", zap.String("upstream", cfg.Name), zap.Error(err))
+			continue
+		}
+		connected++
+	}
+	return connected
+}
+
+// Connect dials one upstream over its configured transport and registers its tools.
+func (m *Manager) Connect(ctx context.Context, cfg UpstreamSettings) error {
+	cfg, err := cfg.normalize()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	dial := m.dialer(cfg)
+	client, err := dial(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := m.attach(ctx, cfg, client, dial); err != nil {
+		if closeErr := client.Close(); closeErr != nil {
+			m.logger.Debug("close failed upstream", zap.String("upstream", cfg.Name), zap.Error(closeErr))
+		}
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// dialer returns the Dialer that starts a client over the configured transport.
+func (m *Manager) dialer(cfg UpstreamSettings) Dialer {
+	return func(ctx context.Context) (Client, error) {
+		if cfg.Transport == TransportStdio {
+			client, err := mcpclient.NewStdioMCPClient(cfg.Command, cfg.Env, cfg.Args...)
+			if err != nil {
+				return nil, errors.Wrapf(err, "start stdio upstream %s", cfg.Name)
+			}
+			return client, nil
+		}
+
+		client, err := mcpclient.NewStreamableHttpClient(cfg.URL,
+			transport.WithHTTPHeaders(cfg.Headers),
+			transport.WithHTTPHeaderFunc(m.forwardedHeaders(cfg)),
+		)
+		if err != nil {
+			return nil, errors.Wrapf(err, "create http upstream %s", cfg.Name)
+		}
+		if err := client.Start(ctx); err != nil {
+			return nil, errors.Wrapf(err, "start http upstream %s", cfg.Name)
+		}
+		return client, nil
+	}
+}
+
+// forwardedHeaders returns the per-request headers of an HTTP upstream.
+func (m *Manager) forwardedHeaders(cfg UpstreamSettings) transport.HTTPHeaderFunc {
+	return func(ctx context.Context) map[string]string {
+		if !cfg.ForwardAuthorization {
+			return nil
+		}
+		m.mu.RLock()
+		provider := m.authorization
+		m.mu.RUnlock()
+		if provider == nil {
+			return nil
+		}
+		if header := provider(ctx); header != "" {
+			return map[string]string{"Authorization": header}
+		}
+		return nil
+	}
+}
+
+// Attach initializes an already started client and registers the tools it lists.
+// The manager cannot re-establish the session of a client attached this way.
+func (m *Manager) Attach(ctx context.Context, cfg UpstreamSettings, client Client) error {
+	return m.attach(ctx, cfg, client, nil)
+}
+
+// attach initializes client and registers its tools; dial, when set, reconnects the upstream.
+func (m *Manager) attach(ctx context.Context, cfg UpstreamSettings, client Client, dial Dialer) error {
+	if client == nil {
+		return errors.New("client is required")
+	}
+	cfg, err := cfg.normalize()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	m.mu.RLock()
+	_, exists := m.upstreams[cfg.Name]
+	m.mu.RUnlock()
+	if exists {
+		return errors.Errorf("upstream %s is already connected", cfg.Name)
+	}
+
+	initCtx, cancel := context.WithTimeout(ctx, cfg.timeout())
+	defer cancel()
+
+	if err := initialize(initCtx, cfg, client); err != nil {
+		return errors.WithStack(err)
+	}
+
+	listed, err := client.ListTools(initCtx, mcp.ListToolsRequest{})
+	if err != nil {
+		return errors.Wrapf(err, "list tools of upstream %s", cfg.Name)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	registered := 0
+	for _, remote := range listed.Tools {
+		name := cfg.Prefix + "_" + remote.Name
+		switch {
+		case !toolNamePattern.MatchString(remote.Name) || len(name) > maxToolNameLength:
+			m.logger.Warn("skip upstream tool with unsupported name",
+				zap.String("upstream", cfg.Name), zap.String("tool", remote.Name))
+			continue
+		case m.tools[name] != nil:
+			m.logger.Warn("skip upstream tool with duplicate name",
+				zap.String("upstream", cfg.Name), zap.String("tool", name))
+			continue
+		}
+
+		definition := remote
+		definition.Name = name
+		definition.Description = fmt.Sprintf("[%s] %s", cfg.Name, strings.TrimSpace(remote.Description))
+		m.tools[name] = &Tool{
+			Definition: definition,
+			Upstream:   cfg.Name,
+			RemoteName: remote.Name,
+			Price:      oneapi.Price(cfg.Price),
+		}
+		registered++
+	}
+	m.upstreams[cfg.Name] = &upstream{settings: cfg, client: client, dial: dial}
+
+	m.logger.Info("connected upstream mcp server",
+		zap.String("upstream", cfg.Name),
+		zap.String("transport", cfg.Transport),
+		zap.Int("tools", registered),
+	)
+	return nil
+}
+
+// initialize performs the MCP handshake with an upstream client.
+func initialize(ctx context.Context, cfg UpstreamSettings, client Client) error {
+	initReq := mcp.InitializeRequest{}
+	initReq.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initReq.Params.ClientInfo = mcp.Implementation{Name: "laisky-blog-graphql", Version: "1.0.0"}
+	if _, err := client.Initialize(ctx, initReq); err != nil {
+		return errors.Wrapf(err, "initialize upstream %s", cfg.Name)
+	}
+	return nil
+}
+
+// Tools returns the re-exported tools sorted by name.
+func (m *Manager) Tools() []Tool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tools := make([]Tool, 0, len(m.tools))
+	for _, tool := range m.tools {
+		tools = append(tools, *tool)
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Definition.Name < tools[j].Definition.Name })
+	return tools
+}
+
+// Tool returns the re-exported tool with the namespaced name.
+func (m *Manager) Tool(name string) (Tool, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tool, ok := m.tools[name]
+	if !ok {
+		return Tool{}, false
+	}
+	return *tool, true
+}
+
+// Call forwards a call of the namespaced tool name to its upstream.
+// Upstream tool errors come back as error results; transport failures are returned as errors
+// and start reconnecting the upstream in the background.
+func (m *Manager) Call(ctx context.Context, name string, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	m.mu.RLock()
+	tool, ok := m.tools[name]
+	var (
+		up           *upstream
+		client       Client
+		reconnecting bool
+	)
+	if ok {
+		if up = m.upstreams[tool.Upstream]; up != nil {
+			client, reconnecting = up.client, up.reconnecting
+		}
+	}
+	m.mu.RUnlock()
+	if !ok || up == nil {
+		return nil, errors.Errorf("unknown federated tool: %s", name)
+	}
+	if reconnecting {
+		return nil, errors.Errorf("upstream %s is reconnecting", tool.Upstream)
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, up.settings.timeout())
+	defer cancel()
+
+	forwarded := mcp.CallToolRequest{}
+	forwarded.Params.Name = tool.RemoteName
+	forwarded.Params.Arguments = req.Params.Arguments
+	forwarded.Params.Meta = req.Params.Meta
+	result, err := client.CallTool(callCtx, forwarded)
+	if err != nil {
+		// A slow tool or a canceled caller says nothing about the session, anything else
+		// (closed transport, expired HTTP session, exited subprocess) means it was dropped.
+		if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+			m.reconnect(up, client)
+		}
+		return nil, errors.Wrapf(err, "call %s on upstream %s", tool.RemoteName, tool.Upstream)
+	}
+	return result, nil
+}
+
+// reconnect starts a background loop that re-establishes the session of up, unless one
+// is already running or another caller already replaced the failed client.
+func (m *Manager) reconnect(up *upstream, failed Client) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if up.dial == nil || up.reconnecting || up.client != failed || m.upstreams[up.settings.Name] != up {
+		return
+	}
+	up.reconnecting = true
+	go m.reconnectLoop(up)
+}
+
+// reconnectLoop dials the upstream with exponential backoff until it succeeds or the
+// manager is closed. The tool list registered at attach time is kept.
+func (m *Manager) reconnectLoop(up *upstream) {
+	cfg := up.settings
+	backoff := m.minBackoff
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-m.closed:
+			return
+		case <-time.After(backoff):
+		}
+
+		client, err := m.redial(cfg, up.dial)
+		if err != nil {
+			m.logger.Warn("reconnect upstream mcp server",
+				zap.String("upstream", cfg.Name), zap.Int("attempt", attempt), zap.Error(err))
+			backoff = min(backoff*2, m.maxBackoff)
+			continue
+		}
+
+		m.mu.Lock()
+		if m.upstreams[cfg.Name] != up {
+			// Closed while dialing.
+			m.mu.Unlock()
+			m.closeClient(cfg.Name, client)
+			return
+		}
+		old := up.client
+		up.client = client
+		up.reconnecting = false
+		m.mu.Unlock()
+
+		m.closeClient(cfg.Name, old)
+		m.logger.Info("reconnected upstream mcp server", zap.String("upstream", cfg.Name), zap.Int("attempt", attempt))
+		return
+	}
+}
+
+// redial starts and initializes a fresh client for cfg.
+func (m *Manager) redial(cfg UpstreamSettings, dial Dialer) (Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.timeout())
+	defer cancel()
+
+	client, err := dial(ctx)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := initialize(ctx, cfg, client); err != nil {
+		m.closeClient(cfg.Name, client)
+		return nil, errors.WithStack(err)
+	}
+	return client, nil
+}
+
+// closeClient closes a client that is no longer used, logging failures.
+func (m *Manager) closeClient(name string, client Client) {
+	if err := client.Close(); err != nil {
+		m.logger.Debug("close upstream client", zap.String("upstream", name), zap.Error(err))
+	}
+}
+
+// Close disconnects all upstreams and stops pending reconnects.
+func (m *Manager) Close() error {
+	m.closeOnce.Do(func() { close(m.closed) })
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errs []error
+	for name, up := range m.upstreams {
+		if err := up.client.Close(); err != nil {
+			errs = append(errs, errors.Wrapf(err, "close upstream %s", name))
+		}
+	}
+	m.upstreams = make(map[string]*upstream)
+	m.tools = make(map[string]*Tool)
+	return errors.Join(errs...)
+}
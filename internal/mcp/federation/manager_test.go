@@ -0,0 +1,188 @@
+package federation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	errors "github.com/Laisky/errors/v2"
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	mcp "github.com/mark3labs/mcp-go/mcp"
+	srv "github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Laisky/laisky-blog-graphql/library/billing/oneapi"
+	"github.com/Laisky/laisky-blog-graphql/library/log"
+)
+
+type authKey struct{}
+
+// newUpstreamServer returns an MCP server with an echo tool that also reports the
+// Authorization header it received, and a tool whose name cannot be re-exported.
+func newUpstreamServer() *srv.MCPServer {
+	server := srv.NewMCPServer("upstream", "1.0.0", srv.WithToolCapabilities(true))
+	server.AddTool(
+		mcp.NewTool("echo", mcp.WithDescription("Echo the message."), mcp.WithString("msg", mcp.Required())),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			auth, _ := ctx.Value(authKey{}).(string)
+			return mcp.NewToolResultStructuredOnly(map[string]any{"msg": req.GetString("msg", ""), "auth": auth}), nil
+		},
+	)
+	server.AddTool(mcp.NewTool("has space"), func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("unreachable"), nil
+	})
+	return server
+}
+
+// TestManagerAttach verifies namespacing, pricing and routing of in-process upstream tools.
+func TestManagerAttach(t *testing.T) {
+	manager, err := NewManager(log.Logger.Named("federation_test"))
+	require.NoError(t, err)
+
+	client, err := mcpclient.NewInProcessClient(newUpstreamServer())
+	require.NoError(t, err)
+	require.NoError(t, client.Start(context.Background()))
+
+	cfg := UpstreamSettings{Name: "docs", Prefix: "kb", URL: "inprocess", Price: 100}
+	require.NoError(t, manager.Attach(context.Background(), cfg, client))
+	require.ErrorContains(t, manager.Attach(context.Background(), cfg, client), "already connected")
+
+	tools := manager.Tools()
+	require.Len(t, tools, 1)
+	require.Equal(t, "kb_echo", tools[0].Definition.Name)
+	require.Equal(t, "[docs] Echo the message.", tools[0].Definition.Description)
+	require.Equal(t, "echo", tools[0].RemoteName)
+	require.Equal(t, oneapi.Price(100), tools[0].Price)
+	require.Contains(t, tools[0].Definition.InputSchema.Required, "msg")
+
+	req := mcp.CallToolRequest{}
+	req.Params.Name = "kb_echo"
+	req.Params.Arguments = map[string]any{"msg": "hi"}
+	result, err := manager.Call(context.Background(), "kb_echo", req)
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+	require.Equal(t, "hi", result.StructuredContent.(map[string]any)["msg"])
+
+	_, err = manager.Call(context.Background(), "kb_missing", req)
+	require.ErrorContains(t, err, "unknown federated tool")
+
+	require.NoError(t, manager.Close())
+	require.Empty(t, manager.Tools())
+}
+
+// TestManagerConnectHTTP verifies streamable HTTP upstreams, static headers and
+// forwarding of the caller's Authorization header.
+func TestManagerConnectHTTP(t *testing.T) {
+	var staticHeader string
+	upstream := srv.NewStreamableHTTPServer(newUpstreamServer(),
+		srv.WithHTTPContextFunc(func(ctx context.Context, r *http.Request) context.Context {
+			if token := r.Header.Get("X-Upstream-Token"); token != "" {
+				staticHeader = token
+			}
+			return context.WithValue(ctx, authKey{}, r.Header.Get("Authorization"))
+		}),
+	)
+	ts := httptest.NewServer(upstream)
+	defer ts.Close()
+
+	manager, err := NewManager(log.Logger.Named("federation_test"))
+	require.NoError(t, err)
+	manager.WithAuthorization(func(context.Context) string { return "Bearer sk-caller" })
+
+	connected := manager.ConnectAll(context.Background(), Settings{Upstreams: []UpstreamSettings{
+		{Name: "remote", URL: ts.URL, Headers: map[string]string{"X-Upstream-Token": "svc"}, ForwardAuthorization: true},
+		{Name: "broken", Transport: "grpc", URL: ts.URL},
+		{Name: "Bad Name", URL: ts.URL},
+	}})
+	require.Equal(t, 1, connected)
+	defer func() { require.NoError(t, manager.Close()) }()
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"msg": "over http"}
+	result, err := manager.Call(context.Background(), "remote_echo", req)
+	require.NoError(t, err)
+	structured := result.StructuredContent.(map[string]any)
+	require.Equal(t, "over http", structured["msg"])
+	require.Equal(t, "Bearer sk-caller", structured["auth"])
+	require.Equal(t, "svc", staticHeader)
+}
+
+// TestUpstreamSettingsNormalize verifies defaults and validation of upstream settings.
+func TestUpstreamSettingsNormalize(t *testing.T) {
+	cfg, err := UpstreamSettings{Name: "tools", Command: "mcp-tools"}.normalize()
+	require.NoError(t, err)
+	require.Equal(t, TransportStdio, cfg.Transport)
+	require.Equal(t, "tools", cfg.Prefix)
+	require.Equal(t, defaultCallTimeout, cfg.timeout())
+
+	cfg, err = UpstreamSettings{Name: "web", URL: "http://upstream/mcp", TimeoutMs: 1500}.normalize()
+	require.NoError(t, err)
+	require.Equal(t, TransportHTTP, cfg.Transport)
+	require.EqualValues(t, 1_500_000_000, cfg.timeout())
+
+	for _, bad := range []UpstreamSettings{
+		{Name: ""},
+		{Name: "web", Prefix: "Web!", URL: "http://upstream"},
+		{Name: "web", Transport: TransportHTTP},
+		{Name: "web", Transport: TransportStdio},
+		{Name: "web", URL: "http://upstream", Price: -1},
+	} {
+		_, err := bad.normalize()
+		require.Error(t, err, bad)
+	}
+}
+
+// droppedClient is an upstream client whose session was lost after attaching.
+type droppedClient struct {
+	Client
+	calls int
+}
+
+func (c *droppedClient) CallTool(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	c.calls++
+	return nil, errors.New("transport has been closed")
+}
+
+// TestManagerReconnectsDroppedUpstream verifies that a transport failure re-dials the upstream
+// in the background and later calls use the new session.
+func TestManagerReconnectsDroppedUpstream(t *testing.T) {
+	manager, err := NewManager(log.Logger.Named("federation_test"))
+	require.NoError(t, err)
+	manager.minBackoff, manager.maxBackoff = time.Millisecond, 10*time.Millisecond
+	defer func() { require.NoError(t, manager.Close()) }()
+
+	newClient := func(ctx context.Context) (Client, error) {
+		client, err := mcpclient.NewInProcessClient(newUpstreamServer())
+		if err != nil {
+			return nil, err
+		}
+		return client, client.Start(ctx)
+	}
+	first, err := newClient(context.Background())
+	require.NoError(t, err)
+	dropped := &droppedClient{Client: first}
+
+	dials := 0
+	failing := func(ctx context.Context) (Client, error) {
+		dials++
+		if dials == 1 {
+			return nil, errors.New("connection refused")
+		}
+		return newClient(ctx)
+	}
+	require.NoError(t, manager.attach(context.Background(), UpstreamSettings{Name: "docs", URL: "inprocess"}, dropped, failing))
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"msg": "again"}
+	_, err = manager.Call(context.Background(), "docs_echo", req)
+	require.ErrorContains(t, err, "transport has been closed")
+
+	require.Eventually(t, func() bool {
+		result, err := manager.Call(context.Background(), "docs_echo", req)
+		return err == nil && result.StructuredContent.(map[string]any)["msg"] == "again"
+	}, 5*time.Second, 5*time.Millisecond)
+	require.Equal(t, 1, dropped.calls)
+	require.Equal(t, 2, dials)
+}
@@ -0,0 +1,117 @@
+// Package federation connects to upstream MCP servers and re-exports their tools.
+package federation
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	errors "github.com/Laisky/errors/v2"
+	gconfig "github.com/Laisky/go-config/v2"
+)
+
+const (
+	// TransportHTTP connects to an upstream over streamable HTTP.
+	TransportHTTP = "http"
+	// TransportStdio launches an upstream as a subprocess and talks to it over stdio.
+	TransportStdio = "stdio"
+
+	defaultCallTimeout = 60 * time.Second
+	upstreamsConfigKey = "settings.mcp.federation.upstreams"
+)
+
+// upstreamNamePattern keeps namespaced tool names within the MCP tool name charset.
+var upstreamNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// UpstreamSettings describes one upstream MCP server.
+type UpstreamSettings struct {
+	// Name identifies the upstream in logs and tool descriptions.
+	Name string `mapstructure:"name"`
+	// Prefix namespaces the upstream tools as "<prefix>_<tool>". Defaults to Name.
+	Prefix string `mapstructure:"prefix"`
+	// Transport is TransportHTTP or TransportStdio. Defaults to http when URL is set.
+	Transport string `mapstructure:"transport"`
+	// URL is the streamable HTTP endpoint of the upstream.
+	URL string `mapstructure:"url"`
+	// Headers are sent with every HTTP request, e.g. a service token for the upstream.
+	Headers map[string]string `mapstructure:"headers"`
+	// ForwardAuthorization passes the caller's Authorization header to an HTTP upstream.
+	ForwardAuthorization bool `mapstructure:"forward_authorization"`
+	// Command, Args and Env launch a stdio upstream.
+	Command string   `mapstructure:"command"`
+	Args    []string `mapstructure:"args"`
+	Env     []string `mapstructure:"env"`
+	// TimeoutMs bounds each call to the upstream. Defaults to 60s.
+	TimeoutMs int `mapstructure:"timeout_ms"`
+	// Price is the oneapi quota billed per successful call. Zero means the tools are free.
+	Price int `mapstructure:"price"`
+}
+
+// Settings lists the upstream MCP servers to federate.
+type Settings struct {
+	Upstreams []UpstreamSettings
+}
+
+// LoadSettingsFromConfig reads settings.mcp.federation.upstreams.
+// A malformed list is reported as an error so a typo does not silently drop upstreams.
+func LoadSettingsFromConfig() (Settings, error) {
+	var settings Settings
+	if gconfig.S.Get(upstreamsConfigKey) == nil {
+		return settings, nil
+	}
+	if err := gconfig.S.UnmarshalKey(upstreamsConfigKey, &settings.Upstreams); err != nil {
+		return settings, errors.Wrap(err, "parse federation upstreams")
+	}
+	return settings, nil
+}
+
+// normalize applies defaults and validates the upstream settings.
+func (u UpstreamSettings) normalize() (UpstreamSettings, error) {
+	u.Name = strings.TrimSpace(u.Name)
+	if !upstreamNamePattern.MatchString(u.Name) {
+		return u, errors.Errorf("upstream name %q must match %s", u.Name, upstreamNamePattern)
+	}
+	u.Prefix = strings.TrimSpace(u.Prefix)
+	if u.Prefix == "" {
+		u.Prefix = u.Name
+	}
+	if !upstreamNamePattern.MatchString(u.Prefix) {
+		return u, errors.Errorf("upstream %s: prefix %q must match %s", u.Name, u.Prefix, upstreamNamePattern)
+	}
+
+	u.Transport = strings.ToLower(strings.TrimSpace(u.Transport))
+	if u.Transport == "" {
+		u.Transport = TransportHTTP
+		if u.URL == "" && u.Command != "" {
+			u.Transport = TransportStdio
+		}
+	}
+	switch u.Transport {
+	case TransportHTTP:
+		if strings.TrimSpace(u.URL) == "" {
+			return u, errors.Errorf("upstream %s: url is required for http transport", u.Name)
+		}
+	case TransportStdio:
+		if strings.TrimSpace(u.Command) == "" {
+			return u, errors.Errorf("upstream %s: command is required for stdio transport", u.Name)
+		}
+	default:
+		return u, errors.Errorf("upstream %s: unsupported transport %q", u.Name, u.Transport)
+	}
+
+	if u.TimeoutMs < 0 {
+		return u, errors.Errorf("upstream %s: timeout_ms must not be negative", u.Name)
+	}
+	if u.Price < 0 {
+		return u, errors.Errorf("upstream %s: price must not be negative", u.Name)
+	}
+	return u, nil
+}
+
+// timeout returns the per-call timeout of the upstream.
+func (u UpstreamSettings) timeout() time.Duration {
+	if u.TimeoutMs <= 0 {
+		return defaultCallTimeout
+	}
+	return time.Duration(u.TimeoutMs) * time.Millisecond
+}
@@ -1,6 +1,10 @@
 package files
 
-import "unicode/utf8"
+import (
+	"path"
+	"strings"
+	"unicode/utf8"
+)
 
 // Chunk captures a slice of content with stable byte offsets.
 type Chunk struct {
@@ -8,6 +12,10 @@ type Chunk struct {
 	StartByte int64
 	EndByte   int64
 	Content   string
+	// Context locates the chunk inside the document, such as a Markdown heading path
+	// or a JSON key path. It is prepended to Content when the chunk is indexed but is
+	// not part of the byte range.
+	Context string
 }
 
 // Chunker splits content into stable byte ranges.
@@ -57,3 +65,139 @@ func (c DefaultChunker) Split(content string) []Chunk {
 	}
 	return chunks
 }
+
+// ChunkerRegistry picks a Chunker by file extension and falls back to a default one
+// for everything else.
+type ChunkerRegistry struct {
+	byExt    map[string]Chunker
+	fallback Chunker
+}
+
+// NewChunkerRegistry returns a registry with the structure-aware chunkers for
+// Markdown, Go, Python, TypeScript/JavaScript, JSON and YAML, falling back to
+// DefaultChunker. Every chunker packs content into chunks of at most maxBytes.
+func NewChunkerRegistry(maxBytes int) *ChunkerRegistry {
+	registry := &ChunkerRegistry{
+		byExt:    make(map[string]Chunker),
+		fallback: DefaultChunker{MaxBytes: maxBytes},
+	}
+	registry.Register(MarkdownChunker{MaxBytes: maxBytes}, ".md", ".markdown", ".mdx")
+	registry.Register(CodeChunker{MaxBytes: maxBytes, Language: CodeLanguageGo}, ".go")
+	registry.Register(CodeChunker{MaxBytes: maxBytes, Language: CodeLanguagePython}, ".py", ".pyi")
+	registry.Register(CodeChunker{MaxBytes: maxBytes, Language: CodeLanguageTypeScript},
+		".ts", ".tsx", ".mts", ".cts", ".js", ".jsx", ".mjs", ".cjs")
+	registry.Register(JSONChunker{MaxBytes: maxBytes}, ".json")
+	registry.Register(YAMLChunker{MaxBytes: maxBytes}, ".yaml", ".yml")
+	return registry
+}
+
+// Register routes files with any of the given extensions, such as ".md", to chunker.
+// Extensions are matched case-insensitively and replace earlier registrations.
+func (r *ChunkerRegistry) Register(chunker Chunker, exts ...string) {
+	for _, ext := range exts {
+		r.byExt[strings.ToLower(ext)] = chunker
+	}
+}
+
+// ForPath returns the chunker registered for the extension of filePath.
+func (r *ChunkerRegistry) ForPath(filePath string) Chunker {
+	if chunker, ok := r.byExt[strings.ToLower(path.Ext(filePath))]; ok {
+		return chunker
+	}
+	return r.fallback
+}
+
+// section is a structural unit of a document covering content[Start:End]. Path names
+// it from the outermost enclosing unit inward.
+type section struct {
+	Start int
+	End   int
+	Path  []string
+}
+
+// packSections turns contiguous sections covering content into chunks of at most
+// maxBytes. Neighboring sections are merged while they fit, keeping only the part of
+// their paths they share, and a section larger than maxBytes is cut at line breaks.
+// Every chunk carries the path rendered by join as its Context, except that with headerInBody
+// the first chunk of a section goes without, because its body already starts with
+// the line the path was taken from.
+func packSections(content string, sections []section, maxBytes int, join func([]string) string, headerInBody bool) []Chunk {
+	maxBytes = chunkBytesOrDefault(maxBytes)
+	if content == "" {
+		return nil
+	}
+
+	var groups []section
+	for _, sec := range sections {
+		if sec.End <= sec.Start {
+			continue
+		}
+		if n := len(groups); n > 0 && sec.End-groups[n-1].Start <= maxBytes {
+			groups[n-1].End = sec.End
+			groups[n-1].Path = commonPathPrefix(groups[n-1].Path, sec.Path)
+			continue
+		}
+		groups = append(groups, sec)
+	}
+
+	chunks := make([]Chunk, 0, len(groups))
+	for _, group := range groups {
+		context := join(group.Path)
+		for i, span := range splitAtLines(content, group.Start, group.End, maxBytes) {
+			chunk := Chunk{
+				Index:     len(chunks),
+				StartByte: int64(span[0]),
+				EndByte:   int64(span[1]),
+				Content:   content[span[0]:span[1]],
+			}
+			if i > 0 || !headerInBody {
+				chunk.Context = context
+			}
+			chunks = append(chunks, chunk)
+		}
+	}
+	return chunks
+}
+
+// chunkBytesOrDefault returns maxBytes, or the 500-byte default when it is unset.
+func chunkBytesOrDefault(maxBytes int) int {
+	if maxBytes <= 0 {
+		return 500
+	}
+	return maxBytes
+}
+
+// splitAtLines cuts content[start:end] into spans of at most maxBytes, ending each
+// span after its last line break when it has one and never inside a UTF-8 sequence.
+func splitAtLines(content string, start, end, maxBytes int) [][2]int {
+	var spans [][2]int
+	for start < end {
+		stop := start + maxBytes
+		if stop >= end {
+			spans = append(spans, [2]int{start, end})
+			break
+		}
+		if nl := strings.LastIndexByte(content[start:stop], '\n'); nl >= 0 {
+			stop = start + nl + 1
+		} else {
+			for stop > start && !utf8.RuneStart(content[stop]) {
+				stop--
+			}
+			if stop == start {
+				stop = start + maxBytes
+			}
+		}
+		spans = append(spans, [2]int{start, stop})
+		start = stop
+	}
+	return spans
+}
+
+// commonPathPrefix returns the leading elements a and b share.
+func commonPathPrefix(a, b []string) []string {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return a[:n:n]
+}
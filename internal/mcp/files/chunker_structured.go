@@ -0,0 +1,448 @@
+package files
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MarkdownChunker splits Markdown at ATX headings. Each chunk's Context is the heading
+// path leading to it, such as "# Guide > ## Install".
+type MarkdownChunker struct {
+	MaxBytes int
+}
+
+// Split implements Chunker.
+func (c MarkdownChunker) Split(content string) []Chunk {
+	var (
+		sections []section
+		stack    []markdownHeading
+		fence    string
+		start    int
+	)
+	for _, line := range lineSpans(content, 0, len(content)) {
+		text := strings.TrimRight(content[line[0]:line[1]], "\r\n")
+		if marker := markdownFence(text); marker != "" {
+			switch {
+			case fence == "":
+				fence = marker
+			case strings.HasPrefix(marker, fence) && strings.TrimSpace(text) == marker:
+				fence = ""
+			}
+			continue
+		}
+		if fence != "" {
+			continue
+		}
+		level, ok := markdownHeadingLevel(text)
+		if !ok {
+			continue
+		}
+
+		sections = append(sections, section{Start: start, End: line[0], Path: markdownPath(stack)})
+		for len(stack) > 0 && stack[len(stack)-1].level >= level {
+			stack = stack[:len(stack)-1]
+		}
+		stack = append(stack, markdownHeading{level: level, title: strings.TrimSpace(text)})
+		start = line[0]
+	}
+	sections = append(sections, section{Start: start, End: len(content), Path: markdownPath(stack)})
+
+	return packSections(content, sections, c.MaxBytes, joinHeadingPath, false)
+}
+
+type markdownHeading struct {
+	level int
+	title string
+}
+
+// markdownPath returns the titles of the open headings, outermost first.
+func markdownPath(stack []markdownHeading) []string {
+	path := make([]string, 0, len(stack))
+	for _, heading := range stack {
+		path = append(path, heading.title)
+	}
+	return path
+}
+
+// markdownHeadingLevel reports the level of an ATX heading line such as "## Install".
+func markdownHeadingLevel(line string) (int, bool) {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return 0, false
+	}
+	level := 0
+	for level < len(trimmed) && trimmed[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 {
+		return 0, false
+	}
+	if level < len(trimmed) && trimmed[level] != ' ' && trimmed[level] != '\t' {
+		return 0, false
+	}
+	return level, true
+}
+
+// markdownFence returns the ``` or ~~~ run opening or closing a fenced code block.
+func markdownFence(line string) string {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 || len(trimmed) < 3 {
+		return ""
+	}
+	ch := trimmed[0]
+	if ch != '`' && ch != '~' {
+		return ""
+	}
+	n := 0
+	for n < len(trimmed) && trimmed[n] == ch {
+		n++
+	}
+	if n < 3 {
+		return ""
+	}
+	return trimmed[:n]
+}
+
+func joinHeadingPath(path []string) string {
+	return strings.Join(path, " > ")
+}
+
+// CodeLanguage selects the declaration syntax CodeChunker recognizes.
+type CodeLanguage string
+
+const (
+	// CodeLanguageGo splits at top-level func, type, var and const declarations.
+	CodeLanguageGo CodeLanguage = "go"
+	// CodeLanguagePython splits at top-level def and class statements.
+	CodeLanguagePython CodeLanguage = "python"
+	// CodeLanguageTypeScript splits at top-level functions, classes, interfaces, types,
+	// enums, namespaces and variable declarations; it also covers JavaScript.
+	CodeLanguageTypeScript CodeLanguage = "typescript"
+)
+
+// CodeChunker splits source code at top-level declarations. The comments, decorators
+// and attributes directly above a declaration stay with it, and the package clause and
+// imports form the first section. A declaration too large for one chunk is cut at line
+// breaks, and the continuation chunks carry the declaration line as their Context.
+type CodeChunker struct {
+	MaxBytes int
+	Language CodeLanguage
+}
+
+// Split implements Chunker.
+func (c CodeChunker) Split(content string) []Chunk {
+	lines := lineSpans(content, 0, len(content))
+	var sections []section
+	start := 0
+	var path []string
+	for i, line := range lines {
+		text := strings.TrimRight(content[line[0]:line[1]], "\r\n")
+		if !c.isDeclaration(text) {
+			continue
+		}
+
+		first := i
+		for first > 0 && c.isLead(strings.TrimRight(content[lines[first-1][0]:lines[first-1][1]], "\r\n")) {
+			first--
+		}
+		if lines[first][0] < start {
+			first = i
+		}
+		sections = append(sections, section{Start: start, End: lines[first][0], Path: path})
+		start = lines[first][0]
+		path = []string{strings.TrimSpace(text)}
+	}
+	sections = append(sections, section{Start: start, End: len(content), Path: path})
+
+	return packSections(content, sections, c.MaxBytes, joinHeadingPath, true)
+}
+
+// typeScriptModifiers precede a TypeScript declaration keyword.
+var typeScriptModifiers = []string{"export default ", "export ", "declare ", "async ", "abstract "}
+
+// isDeclaration reports whether line opens a top-level declaration.
+func (c CodeChunker) isDeclaration(line string) bool {
+	switch c.Language {
+	case CodeLanguageGo:
+		return hasAnyPrefix(line, "func ", "func(", "type ", "var ", "const ")
+	case CodeLanguagePython:
+		return hasAnyPrefix(line, "def ", "async def ", "class ")
+	case CodeLanguageTypeScript:
+		for stripped := true; stripped; {
+			stripped = false
+			for _, modifier := range typeScriptModifiers {
+				if strings.HasPrefix(line, modifier) {
+					line, stripped = line[len(modifier):], true
+				}
+			}
+		}
+		return hasAnyPrefix(line, "function ", "function*", "class ", "interface ", "type ",
+			"enum ", "const ", "let ", "var ", "namespace ")
+	default:
+		return false
+	}
+}
+
+// isLead reports whether line belongs to the declaration below it.
+func (c CodeChunker) isLead(line string) bool {
+	switch c.Language {
+	case CodeLanguageGo:
+		return strings.HasPrefix(line, "//")
+	case CodeLanguagePython:
+		return hasAnyPrefix(line, "@", "#")
+	case CodeLanguageTypeScript:
+		return hasAnyPrefix(line, "//", "/*", "@") || strings.HasPrefix(strings.TrimSpace(line), "*")
+	default:
+		return false
+	}
+}
+
+func hasAnyPrefix(s string, prefixes ...string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// JSONChunker splits JSON at object members and array elements, descending into values
+// too large for one chunk. Each chunk's Context is the key path of its content, such as
+// "servers[2].tls". Invalid JSON falls back to DefaultChunker.
+type JSONChunker struct {
+	MaxBytes int
+}
+
+// Split implements Chunker.
+func (c JSONChunker) Split(content string) []Chunk {
+	if !json.Valid([]byte(content)) {
+		return DefaultChunker{MaxBytes: c.MaxBytes}.Split(content)
+	}
+	open := skipJSONSpace(content, 0)
+	sections := jsonSections(content, 0, len(content), open, nil, chunkBytesOrDefault(c.MaxBytes))
+	return packSections(content, sections, c.MaxBytes, joinKeyPath, false)
+}
+
+// jsonSections covers content[start:end] with one section per member of the object or
+// array opening at content[open]. Bytes before the first member and after the last one
+// join the nearest member.
+func jsonSections(content string, start, end, open int, prefix []string, maxBytes int) []section {
+	whole := []section{{Start: start, End: end, Path: prefix}}
+	if open >= len(content) || (content[open] != '{' && content[open] != '[') {
+		return whole
+	}
+
+	type member struct {
+		start      int
+		valueStart int
+		key        string
+	}
+	var members []member
+	i := skipJSONSpace(content, open+1)
+	for index := 0; i < len(content) && content[i] != '}' && content[i] != ']'; index++ {
+		m := member{start: i, valueStart: i, key: "[" + strconv.Itoa(index) + "]"}
+		if content[open] == '{' {
+			keyEnd := skipJSONValue(content, i)
+			if err := json.Unmarshal([]byte(content[i:keyEnd]), &m.key); err != nil {
+				return whole
+			}
+			m.valueStart = skipJSONSpace(content, keyEnd)
+			m.valueStart = skipJSONSpace(content, m.valueStart+1) // skip ':'
+		}
+		members = append(members, m)
+
+		i = skipJSONSpace(content, skipJSONValue(content, m.valueStart))
+		if i < len(content) && content[i] == ',' {
+			i = skipJSONSpace(content, i+1)
+		}
+	}
+	if len(members) == 0 {
+		return whole
+	}
+
+	var sections []section
+	for n, m := range members {
+		sec := section{Start: m.start, End: end, Path: appendPath(prefix, m.key)}
+		if n == 0 {
+			sec.Start = start
+		}
+		if n+1 < len(members) {
+			sec.End = members[n+1].start
+		}
+		if sec.End-sec.Start > maxBytes {
+			sections = append(sections, jsonSections(content, sec.Start, sec.End, m.valueStart, sec.Path, maxBytes)...)
+			continue
+		}
+		sections = append(sections, sec)
+	}
+	return sections
+}
+
+// skipJSONSpace returns the offset of the first non-whitespace byte at or after i.
+func skipJSONSpace(content string, i int) int {
+	for i < len(content) && strings.IndexByte(" \t\r\n", content[i]) >= 0 {
+		i++
+	}
+	return i
+}
+
+// skipJSONValue returns the offset just past the JSON value starting at content[i].
+// The content must already be valid JSON.
+func skipJSONValue(content string, i int) int {
+	depth := 0
+	for ; i < len(content); i++ {
+		switch content[i] {
+		case '"':
+			for i++; i < len(content) && content[i] != '"'; i++ {
+				if content[i] == '\\' {
+					i++
+				}
+			}
+			if depth == 0 {
+				return i + 1
+			}
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth <= 0 {
+				return i + 1
+			}
+		case ',', ' ', '\t', '\r', '\n', ':':
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return i
+}
+
+// joinKeyPath renders a key path, attaching array indexes to the key before them.
+func joinKeyPath(path []string) string {
+	var sb strings.Builder
+	for _, key := range path {
+		if sb.Len() > 0 && !strings.HasPrefix(key, "[") {
+			sb.WriteByte('.')
+		}
+		sb.WriteString(key)
+	}
+	return sb.String()
+}
+
+func appendPath(prefix []string, key string) []string {
+	path := make([]string, 0, len(prefix)+1)
+	return append(append(path, prefix...), key)
+}
+
+// YAMLChunker splits YAML at mapping keys, descending into nested mappings too large
+// for one chunk. Each chunk's Context is the key path of its content, such as
+// "server.tls". Comment lines directly above a key stay with it.
+type YAMLChunker struct {
+	MaxBytes int
+}
+
+// yamlKeyRe matches a mapping key at the start of a line with its indentation removed.
+var yamlKeyRe = regexp.MustCompile(`^("[^"]*"|'[^']*'|[^\s#'"\-?][^:#]*?)\s*:(?:\s|$)`)
+
+// Split implements Chunker.
+func (c YAMLChunker) Split(content string) []Chunk {
+	sections := yamlSections(content, 0, len(content), 0, nil, chunkBytesOrDefault(c.MaxBytes))
+	return packSections(content, sections, c.MaxBytes, joinKeyPath, false)
+}
+
+// yamlSections covers content[start:end] with one section per key indented by exactly
+// indent spaces. Bytes before the first key join it.
+func yamlSections(content string, start, end, indent int, prefix []string, maxBytes int) []section {
+	type key struct {
+		start int
+		line  int
+		name  string
+	}
+	lines := lineSpans(content, start, end)
+	var keys []key
+	comment := -1
+	for i, line := range lines {
+		text := strings.TrimRight(content[line[0]:line[1]], "\r\n")
+		body := strings.TrimLeft(text, " ")
+		if body == "" || len(text)-len(body) != indent {
+			comment = -1
+			continue
+		}
+		if strings.HasPrefix(body, "#") {
+			if comment < 0 {
+				comment = line[0]
+			}
+			continue
+		}
+		match := yamlKeyRe.FindStringSubmatch(body)
+		if match == nil {
+			comment = -1
+			continue
+		}
+		k := key{start: line[0], line: i, name: strings.Trim(match[1], `"'`)}
+		if comment >= 0 {
+			k.start = comment
+		}
+		keys = append(keys, k)
+		comment = -1
+	}
+	if len(keys) == 0 {
+		return []section{{Start: start, End: end, Path: prefix}}
+	}
+
+	var sections []section
+	for n, k := range keys {
+		sec := section{Start: k.start, End: end, Path: appendPath(prefix, k.name)}
+		if n == 0 {
+			sec.Start = start
+		}
+		if n+1 < len(keys) {
+			sec.End = keys[n+1].start
+		}
+		if sec.End-sec.Start > maxBytes {
+			if child := yamlChildIndent(content, lines[k.line+1:], sec.End, indent); child > indent {
+				sections = append(sections, yamlSections(content, sec.Start, sec.End, child, sec.Path, maxBytes)...)
+				continue
+			}
+		}
+		sections = append(sections, sec)
+	}
+	return sections
+}
+
+// yamlChildIndent returns the indentation of the first content line before end that is
+// nested deeper than indent, or -1 when there is none.
+func yamlChildIndent(content string, lines [][2]int, end, indent int) int {
+	for _, line := range lines {
+		if line[0] >= end {
+			break
+		}
+		text := strings.TrimRight(content[line[0]:line[1]], "\r\n")
+		body := strings.TrimLeft(text, " ")
+		if body == "" || strings.HasPrefix(body, "#") {
+			continue
+		}
+		if depth := len(text) - len(body); depth > indent {
+			return depth
+		}
+		return -1
+	}
+	return -1
+}
+
+// lineSpans returns the [start, end) offsets of the lines in content[start:end], each
+// including its trailing line break.
+func lineSpans(content string, start, end int) [][2]int {
+	var spans [][2]int
+	for start < end {
+		stop := end
+		if nl := strings.IndexByte(content[start:end], '\n'); nl >= 0 {
+			stop = start + nl + 1
+		}
+		spans = append(spans, [2]int{start, stop})
+		start = stop
+	}
+	return spans
+}
@@ -0,0 +1,115 @@
+package files
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// requireExactChunks verifies chunks cover content in order and that every chunk's
+// offsets address its own content.
+func requireExactChunks(t *testing.T, content string, chunks []Chunk, maxBytes int) {
+	t.Helper()
+	var next int64
+	for i, chunk := range chunks {
+		require.Equal(t, i, chunk.Index)
+		require.Equal(t, next, chunk.StartByte)
+		require.Equal(t, content[chunk.StartByte:chunk.EndByte], chunk.Content)
+		require.LessOrEqual(t, len(chunk.Content), maxBytes)
+		next = chunk.EndByte
+	}
+	require.Equal(t, int64(len(content)), next)
+}
+
+// TestMarkdownChunker_HeadingPath verifies chunks start at headings and carry the
+// enclosing heading path as context.
+func TestMarkdownChunker_HeadingPath(t *testing.T) {
+	content := strings.Repeat("intro text\n", 4) +
+		"# Guide\n" + strings.Repeat("guide body\n", 4) +
+		"## Install\n" + strings.Repeat("install step\n", 3) +
+		"```sh\n# not a heading\n```\n" +
+		"## Usage\n" + strings.Repeat("usage text\n", 4)
+	chunks := MarkdownChunker{MaxBytes: 80}.Split(content)
+	requireExactChunks(t, content, chunks, 80)
+
+	require.Len(t, chunks, 4)
+	require.Equal(t, "", chunks[0].Context)
+	require.Equal(t, "# Guide", chunks[1].Context)
+	require.True(t, strings.HasPrefix(chunks[2].Content, "## Install\n"))
+	require.Contains(t, chunks[2].Content, "# not a heading")
+	require.Equal(t, "# Guide > ## Install", chunks[2].Context)
+	require.Equal(t, "# Guide > ## Usage", chunks[3].Context)
+}
+
+// TestCodeChunker_Declarations verifies code splits at top-level declarations with
+// their doc comments, and that continuation chunks name the declaration.
+func TestCodeChunker_Declarations(t *testing.T) {
+	content := "package demo\n\nimport \"fmt\"\n\n" +
+		"// Hello greets.\nfunc Hello() {\n\tfmt.Println(\"hi\")\n}\n\n" +
+		"type T struct{}\n\n" +
+		"func Long() {\n" + strings.Repeat("\tfmt.Println(\"line\")\n", 6) + "}\n"
+	chunks := CodeChunker{MaxBytes: 60, Language: CodeLanguageGo}.Split(content)
+	requireExactChunks(t, content, chunks, 60)
+
+	require.Equal(t, "package demo\n\nimport \"fmt\"\n\n", chunks[0].Content)
+	require.True(t, strings.HasPrefix(chunks[1].Content, "// Hello greets.\nfunc Hello()"))
+	require.Empty(t, chunks[1].Context)
+	var long []Chunk
+	for _, chunk := range chunks {
+		if chunk.Context == "func Long() {" {
+			long = append(long, chunk)
+		}
+	}
+	require.NotEmpty(t, long)
+
+	python := "import os\n\n@cache\ndef a():\n    return 1\n\nclass B:\n    pass\n"
+	chunks = CodeChunker{MaxBytes: 20, Language: CodeLanguagePython}.Split(python)
+	requireExactChunks(t, python, chunks, 20)
+	require.Equal(t, "@cache\ndef a():\n", chunks[1].Content)
+
+	ts := "import x from 'x'\n\n/** Doc. */\nexport async function f() {}\n\nexport default class C {}\n"
+	chunks = CodeChunker{MaxBytes: 60, Language: CodeLanguageTypeScript}.Split(ts)
+	requireExactChunks(t, ts, chunks, 60)
+	require.Equal(t, "/** Doc. */\nexport async function f() {}\n\n", chunks[1].Content)
+	require.Equal(t, "export default class C {}\n", chunks[2].Content)
+}
+
+// TestKeyPathChunkers verifies JSON and YAML split by key and report key paths.
+func TestKeyPathChunkers(t *testing.T) {
+	value := func(ch string) string { return `"` + strings.Repeat(ch, 30) + `"` }
+	content := `{"name": ` + value("a") + `, "servers": [{"host": ` + value("b") + `}, {"host": ` + value("c") +
+		`}], "tls": {"cert": ` + value("d") + `}}`
+	chunks := JSONChunker{MaxBytes: 60}.Split(content)
+	requireExactChunks(t, content, chunks, 60)
+	var contexts []string
+	for _, chunk := range chunks {
+		contexts = append(contexts, chunk.Context)
+	}
+	require.Equal(t, []string{"name", "servers[0]", "servers[1]", "tls"}, contexts)
+
+	chunks = JSONChunker{MaxBytes: 10}.Split("{not json")
+	requireExactChunks(t, "{not json", chunks, 10)
+
+	yaml := "name: demo\nserver:\n  # listen address\n  host: 0.0.0.0\n  port: 80\nlog: " + strings.Repeat("l", 40) + "\n"
+	chunks = YAMLChunker{MaxBytes: 50}.Split(yaml)
+	requireExactChunks(t, yaml, chunks, 50)
+	contexts = contexts[:0]
+	for _, chunk := range chunks {
+		contexts = append(contexts, chunk.Context)
+	}
+	require.Equal(t, []string{"name", "server.host", "server.port", "log"}, contexts)
+	require.Equal(t, "server:\n  # listen address\n  host: 0.0.0.0\n", chunks[1].Content)
+}
+
+// TestChunkerRegistry_ForPath verifies chunkers are chosen by extension.
+func TestChunkerRegistry_ForPath(t *testing.T) {
+	registry := NewChunkerRegistry(100)
+	require.IsType(t, MarkdownChunker{}, registry.ForPath("/docs/README.MD"))
+	require.Equal(t, CodeChunker{MaxBytes: 100, Language: CodeLanguageTypeScript}, registry.ForPath("/src/app.tsx"))
+	require.IsType(t, YAMLChunker{}, registry.ForPath("/ci.yml"))
+	require.Equal(t, DefaultChunker{MaxBytes: 100}, registry.ForPath("/notes.txt"))
+
+	registry.Register(DefaultChunker{MaxBytes: 5}, ".md")
+	require.Equal(t, DefaultChunker{MaxBytes: 5}, registry.ForPath("/a.md"))
+}
@@ -0,0 +1,52 @@
+package files
+
+import (
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// DetectMimeType picks the media type recorded for a file. A declared type wins,
+// then the path extension, then content sniffing.
+func DetectMimeType(path string, content []byte, declared string) string {
+	if mediaType := baseMediaType(declared); mediaType != "" && mediaType != "application/octet-stream" {
+		return mediaType
+	}
+	if mediaType := baseMediaType(mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))); mediaType != "" {
+		return mediaType
+	}
+	return baseMediaType(http.DetectContentType(content))
+}
+
+// baseMediaType strips parameters such as charset from a media type.
+func baseMediaType(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	mediaType, _, err := mime.ParseMediaType(value)
+	if err != nil {
+		return ""
+	}
+	return mediaType
+}
+
+// IsTextMimeType reports whether content of mimeType is served and indexed as text.
+// Rows written before mime types were recorded have an empty type and count as text.
+func IsTextMimeType(mimeType string) bool {
+	mediaType := baseMediaType(mimeType)
+	switch {
+	case mimeType == "" || strings.HasPrefix(mediaType, "text/"):
+		return true
+	case strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript", "application/x-yaml",
+		"application/yaml", "application/toml", "application/x-sh", "application/sql":
+		return true
+	default:
+		return false
+	}
+}
@@ -1,16 +1,43 @@
 package files
 
-import "strings"
+import (
+	"encoding/base64"
+	"strings"
+)
+
+const (
+	// ContentEncodingUTF8 carries content as plain text.
+	ContentEncodingUTF8 = "utf-8"
+	// ContentEncodingBase64 carries arbitrary bytes as standard base64.
+	ContentEncodingBase64 = "base64"
+)
 
 // NormalizeContentEncoding returns the normalized encoding or an error.
 func NormalizeContentEncoding(encoding string) (string, error) {
-	if strings.TrimSpace(encoding) == "" {
-		return "utf-8", nil
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", ContentEncodingUTF8, "utf8":
+		return ContentEncodingUTF8, nil
+	case ContentEncodingBase64:
+		return ContentEncodingBase64, nil
+	default:
+		return "", NewError(ErrCodeInvalidQuery, "content_encoding must be utf-8 or base64", false)
+	}
+}
+
+// DecodeContent returns the raw bytes carried by content in the given encoding.
+func DecodeContent(content, encoding string) ([]byte, error) {
+	normalized, err := NormalizeContentEncoding(encoding)
+	if err != nil {
+		return nil, err
+	}
+	if normalized == ContentEncodingUTF8 {
+		return []byte(content), nil
 	}
-	if strings.EqualFold(strings.TrimSpace(encoding), "utf-8") {
-		return "utf-8", nil
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(content))
+	if err != nil {
+		return nil, NewError(ErrCodeInvalidQuery, "content is not valid base64", false)
 	}
-	return "", NewError(ErrCodeInvalidQuery, "content_encoding must be utf-8", false)
+	return decoded, nil
 }
 
 // ValidatePayloadSize enforces request payload size limits.
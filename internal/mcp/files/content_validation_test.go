@@ -16,11 +16,45 @@ func TestNormalizeContentEncoding(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, "utf-8", encoding)
 
-	_, err = NormalizeContentEncoding("base64")
+	encoding, err = NormalizeContentEncoding("Base64")
+	require.NoError(t, err)
+	require.Equal(t, "base64", encoding)
+
+	_, err = NormalizeContentEncoding("latin-1")
 	require.Error(t, err)
 	require.True(t, IsCode(err, ErrCodeInvalidQuery))
 }
 
+// TestDecodeContent verifies text passes through and base64 is decoded.
+func TestDecodeContent(t *testing.T) {
+	data, err := DecodeContent("hello", "utf-8")
+	require.NoError(t, err)
+	require.Equal(t, []byte("hello"), data)
+
+	data, err = DecodeContent("AAH/", "base64")
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x00, 0x01, 0xff}, data)
+
+	_, err = DecodeContent("not base64!", "base64")
+	require.Error(t, err)
+	require.True(t, IsCode(err, ErrCodeInvalidQuery))
+}
+
+// TestDetectMimeType verifies declared, extension and sniffed types in that order.
+func TestDetectMimeType(t *testing.T) {
+	require.Equal(t, "image/png", DetectMimeType("/a.bin", nil, "image/png; q=1"))
+	require.Equal(t, "application/pdf", DetectMimeType("/doc.pdf", []byte("anything"), ""))
+	require.Equal(t, "application/pdf", DetectMimeType("/doc.pdf", nil, "application/octet-stream"))
+	require.Equal(t, "text/plain", DetectMimeType("/notes", []byte("plain words"), ""))
+	require.Equal(t, "application/octet-stream", DetectMimeType("/blob", []byte{0x00, 0x01, 0x02}, ""))
+
+	require.True(t, IsTextMimeType(""))
+	require.True(t, IsTextMimeType("text/markdown"))
+	require.True(t, IsTextMimeType("application/ld+json"))
+	require.False(t, IsTextMimeType("application/pdf"))
+	require.False(t, IsTextMimeType("image/png"))
+}
+
 // TestValidatePayloadSize verifies payload limit enforcement.
 func TestValidatePayloadSize(t *testing.T) {
 	require.NoError(t, ValidatePayloadSize(10, 10))
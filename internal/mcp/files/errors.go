@@ -10,21 +10,23 @@ import (
 type ErrorCode string
 
 const (
-	ErrCodeNotFound         ErrorCode = "NOT_FOUND"
-	ErrCodeAlreadyExists    ErrorCode = "ALREADY_EXISTS"
-	ErrCodeIsDirectory      ErrorCode = "IS_DIRECTORY"
-	ErrCodeNotDirectory     ErrorCode = "NOT_DIRECTORY"
-	ErrCodeInvalidArgument  ErrorCode = "INVALID_ARGUMENT"
-	ErrCodeInvalidPath      ErrorCode = "INVALID_PATH"
-	ErrCodeInvalidOffset    ErrorCode = "INVALID_OFFSET"
-	ErrCodeInvalidQuery     ErrorCode = "INVALID_QUERY"
-	ErrCodeNotEmpty         ErrorCode = "NOT_EMPTY"
-	ErrCodePermissionDenied ErrorCode = "PERMISSION_DENIED"
-	ErrCodePayloadTooLarge  ErrorCode = "PAYLOAD_TOO_LARGE"
-	ErrCodeQuotaExceeded    ErrorCode = "QUOTA_EXCEEDED"
-	ErrCodeRateLimited      ErrorCode = "RATE_LIMITED"
-	ErrCodeResourceBusy     ErrorCode = "RESOURCE_BUSY"
-	ErrCodeSearchBackend    ErrorCode = "SEARCH_BACKEND_ERROR"
+	ErrCodeNotFound           ErrorCode = "NOT_FOUND"
+	ErrCodeAlreadyExists      ErrorCode = "ALREADY_EXISTS"
+	ErrCodeIsDirectory        ErrorCode = "IS_DIRECTORY"
+	ErrCodeNotDirectory       ErrorCode = "NOT_DIRECTORY"
+	ErrCodeInvalidArgument    ErrorCode = "INVALID_ARGUMENT"
+	ErrCodeInvalidPath        ErrorCode = "INVALID_PATH"
+	ErrCodeInvalidOffset      ErrorCode = "INVALID_OFFSET"
+	ErrCodeInvalidQuery       ErrorCode = "INVALID_QUERY"
+	ErrCodeNotEmpty           ErrorCode = "NOT_EMPTY"
+	ErrCodePermissionDenied   ErrorCode = "PERMISSION_DENIED"
+	ErrCodePayloadTooLarge    ErrorCode = "PAYLOAD_TOO_LARGE"
+	ErrCodeQuotaExceeded      ErrorCode = "QUOTA_EXCEEDED"
+	ErrCodeRateLimited        ErrorCode = "RATE_LIMITED"
+	ErrCodeResourceBusy       ErrorCode = "RESOURCE_BUSY"
+	ErrCodeConflict           ErrorCode = "CONFLICT"
+	ErrCodePreconditionFailed ErrorCode = "PRECONDITION_FAILED"
+	ErrCodeSearchBackend      ErrorCode = "SEARCH_BACKEND_ERROR"
 )
 
 // Error captures a typed file error with retryability metadata.
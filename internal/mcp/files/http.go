@@ -2,10 +2,13 @@ package files
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -31,8 +34,18 @@ type filesHTTPHandler struct {
 }
 
 const (
-	versionsAPIPath = "/api/versions"
-	fileAPIPath     = "/api/file"
+	versionsAPIPath    = "/api/versions"
+	fileAPIPath        = "/api/file"
+	usageAPIPath       = "/api/usage"
+	snapshotsAPIPath   = "/api/snapshots"
+	exportAPIPath      = "/api/export"
+	importAPIPath      = "/api/import"
+	embeddingAPIPath   = "/api/embedding"
+	indexStatusAPIPath = "/api/index_status"
+	reindexAPIPath     = "/api/admin/reindex"
+
+	// adminTokenHeader carries SecuritySettings.AdminToken for the admin endpoints.
+	adminTokenHeader = "X-Admin-Token"
 )
 
 // ServeHTTP routes requests for the file_io management endpoints.
@@ -46,6 +59,34 @@ func (h *filesHTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		h.handleRestoreVersion(w, r)
 	case r.URL.Path == fileAPIPath && r.Method == http.MethodPut:
 		h.handlePutFile(w, r)
+	case r.URL.Path == usageAPIPath && r.Method == http.MethodGet:
+		h.handleUsage(w, r)
+	case r.URL.Path == snapshotsAPIPath && r.Method == http.MethodGet:
+		h.handleListSnapshots(w, r)
+	case r.URL.Path == snapshotsAPIPath && r.Method == http.MethodPost:
+		h.handleCreateSnapshot(w, r)
+	case r.URL.Path == snapshotsAPIPath && r.Method == http.MethodDelete:
+		h.handleDeleteSnapshot(w, r)
+	case r.URL.Path == snapshotsAPIPath+"/diff" && r.Method == http.MethodGet:
+		h.handleDiffSnapshot(w, r)
+	case r.URL.Path == snapshotsAPIPath+"/restore" && r.Method == http.MethodPost:
+		h.handleRestoreSnapshot(w, r)
+	case r.URL.Path == exportAPIPath && r.Method == http.MethodGet:
+		h.handleExport(w, r)
+	case r.URL.Path == importAPIPath && r.Method == http.MethodPost:
+		h.handleImport(w, r)
+	case r.URL.Path == embeddingAPIPath && r.Method == http.MethodGet:
+		h.handleGetEmbedding(w, r)
+	case r.URL.Path == embeddingAPIPath && r.Method == http.MethodPut:
+		h.handleSetEmbedding(w, r)
+	case r.URL.Path == indexStatusAPIPath && r.Method == http.MethodGet:
+		h.handleIndexStatus(w, r)
+	case r.URL.Path == indexStatusAPIPath+"/retry" && r.Method == http.MethodPost:
+		h.handleRetryIndexJobs(w, r)
+	case r.URL.Path == reindexAPIPath && r.Method == http.MethodPost:
+		h.handleStartReindex(w, r)
+	case r.URL.Path == reindexAPIPath && r.Method == http.MethodGet:
+		h.handleReindexStatus(w, r)
 	default:
 		logger := h.logFromCtx(r.Context())
 		h.writeErrorWithLogger(w, logger, http.StatusNotFound, "resource not found")
@@ -181,7 +222,11 @@ func (h *filesHTTPHandler) handleRestoreVersion(w http.ResponseWriter, r *http.R
 	h.writeJSON(w, map[string]any{"bytes_written": result.BytesWritten})
 }
 
-// handlePutFile saves edited content using TRUNCATE write mode.
+// handlePutFile saves content using TRUNCATE write mode. JSON bodies carry the
+// project, path and content (optionally base64 encoded). Any other Content-Type is
+// treated as a raw upload of the file bytes, addressed by the project and path query
+// parameters and recorded with the request's Content-Type. An If-Match header holding
+// a file revision makes the write conditional.
 func (h *filesHTTPHandler) handlePutFile(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
@@ -198,23 +243,470 @@ func (h *filesHTTPHandler) handlePutFile(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	ifMatch := strings.TrimSpace(strings.Trim(r.Header.Get("If-Match"), `"`))
+
+	contentType := r.Header.Get("Content-Type")
+	if mediaType := baseMediaType(contentType); mediaType != "" && mediaType != "application/json" {
+		h.handleUploadFile(ctx, w, r, logger, toFilesAuth(authCtx), WriteOpts{MimeType: mediaType, IfMatch: ifMatch})
+		return
+	}
+
 	var payload struct {
-		Project string `json:"project"`
-		Path    string `json:"path"`
-		Content string `json:"content"`
+		Project         string `json:"project"`
+		Path            string `json:"path"`
+		Content         string `json:"content"`
+		ContentEncoding string `json:"content_encoding"`
+		MimeType        string `json:"mime_type"`
 	}
 	if err := json.NewDecoder(io.LimitReader(r.Body, 32<<20)).Decode(&payload); err != nil {
 		h.writeErrorWithLogger(w, logger, http.StatusBadRequest, "invalid JSON payload")
 		return
 	}
 
-	result, err := h.service.Write(ctx, toFilesAuth(authCtx), payload.Project, payload.Path, payload.Content, "utf-8", 0, WriteModeTruncate)
+	result, err := h.service.WriteWith(ctx, toFilesAuth(authCtx), payload.Project, payload.Path, payload.Content,
+		payload.ContentEncoding, 0, WriteModeTruncate, WriteOpts{MimeType: payload.MimeType, IfMatch: ifMatch})
 	if err != nil {
 		h.writeFileError(w, logger, err, "write file")
 		return
 	}
 
-	h.writeJSON(w, map[string]any{"bytes_written": result.BytesWritten})
+	h.writeJSON(w, map[string]any{"bytes_written": result.BytesWritten, "revision": result.Revision})
+}
+
+// handleUploadFile stores a raw request body as the file content. The body is buffered
+// in memory, read through a limit one byte past the payload cap, so an oversized upload
+// costs at most cap+1 bytes before WriteContent rejects it. opts carries the upload's
+// Content-Type as its MIME type.
+func (h *filesHTTPHandler) handleUploadFile(ctx context.Context, w http.ResponseWriter, r *http.Request, logger logSDK.Logger, auth AuthContext, opts WriteOpts) {
+	query := r.URL.Query()
+	limit := h.service.settings.MaxPayloadBytes
+	if limit <= 0 {
+		limit = defaultMaxPayloadBytes
+	}
+	content, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		h.writeErrorWithLogger(w, logger, http.StatusBadRequest, "failed to read upload body")
+		return
+	}
+
+	result, err := h.service.WriteContent(ctx, auth, query.Get("project"), query.Get("path"), content, 0, WriteModeTruncate, opts)
+	if err != nil {
+		h.writeFileError(w, logger, err, "upload file")
+		return
+	}
+
+	h.writeJSON(w, map[string]any{"bytes_written": result.BytesWritten, "mime_type": opts.MimeType, "revision": result.Revision})
+}
+
+// handleUsage reports logical and deduplicated storage for a project.
+func (h *filesHTTPHandler) handleUsage(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	logger := h.logFromCtx(ctx)
+
+	if h.service == nil {
+		h.writeErrorWithLogger(w, logger, http.StatusServiceUnavailable, "files service unavailable")
+		return
+	}
+
+	authCtx, err := askuser.ParseAuthorizationFromContext(r.Context(), r.Header.Get("Authorization"))
+	if err != nil {
+		h.writeErrorWithLogger(w, logger, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	usage, err := h.service.Usage(ctx, toFilesAuth(authCtx), r.URL.Query().Get("project"))
+	if err != nil {
+		h.writeFileError(w, logger, err, "read project usage")
+		return
+	}
+
+	h.writeJSON(w, map[string]any{
+		"logical_bytes":            usage.LogicalBytes,
+		"stored_bytes":             usage.StoredBytes,
+		"file_count":               usage.FileCount,
+		"version_count":            usage.VersionCount,
+		"max_project_bytes":        h.service.settings.MaxProjectBytes,
+		"max_project_stored_bytes": h.service.settings.MaxProjectStoredBytes,
+	})
+}
+
+// handleGetEmbedding reports the embedding provider of a project.
+func (h *filesHTTPHandler) handleGetEmbedding(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	logger := h.logFromCtx(ctx)
+
+	if h.service == nil {
+		h.writeErrorWithLogger(w, logger, http.StatusServiceUnavailable, "files service unavailable")
+		return
+	}
+
+	authCtx, err := askuser.ParseAuthorizationFromContext(r.Context(), r.Header.Get("Authorization"))
+	if err != nil {
+		h.writeErrorWithLogger(w, logger, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	embedding, err := h.service.GetProjectEmbedding(ctx, toFilesAuth(authCtx), r.URL.Query().Get("project"))
+	if err != nil {
+		h.writeFileError(w, logger, err, "read project embedding")
+		return
+	}
+
+	h.writeJSON(w, embedding)
+}
+
+// handleSetEmbedding selects the embedding provider of a project.
+func (h *filesHTTPHandler) handleSetEmbedding(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	logger := h.logFromCtx(ctx)
+
+	if h.service == nil {
+		h.writeErrorWithLogger(w, logger, http.StatusServiceUnavailable, "files service unavailable")
+		return
+	}
+
+	authCtx, err := askuser.ParseAuthorizationFromContext(r.Context(), r.Header.Get("Authorization"))
+	if err != nil {
+		h.writeErrorWithLogger(w, logger, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var payload struct {
+		Project  string `json:"project"`
+		Provider string `json:"provider"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&payload); err != nil {
+		h.writeErrorWithLogger(w, logger, http.StatusBadRequest, "invalid JSON payload")
+		return
+	}
+
+	embedding, err := h.service.SetProjectEmbedding(ctx, toFilesAuth(authCtx), payload.Project, payload.Provider)
+	if err != nil {
+		h.writeFileError(w, logger, err, "set project embedding")
+		return
+	}
+
+	h.writeJSON(w, embedding)
+}
+
+// handleListSnapshots returns the snapshots of a project, newest first.
+func (h *filesHTTPHandler) handleListSnapshots(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	logger := h.logFromCtx(ctx)
+
+	if h.service == nil {
+		h.writeErrorWithLogger(w, logger, http.StatusServiceUnavailable, "files service unavailable")
+		return
+	}
+
+	authCtx, err := askuser.ParseAuthorizationFromContext(r.Context(), r.Header.Get("Authorization"))
+	if err != nil {
+		h.writeErrorWithLogger(w, logger, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	snapshots, err := h.service.ListSnapshots(ctx, toFilesAuth(authCtx), r.URL.Query().Get("project"))
+	if err != nil {
+		h.writeFileError(w, logger, err, "list snapshots")
+		return
+	}
+
+	items := make([]map[string]any, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		items = append(items, snapshotPayload(snapshot))
+	}
+	h.writeJSON(w, map[string]any{"snapshots": items})
+}
+
+// handleCreateSnapshot takes a named snapshot of a project.
+func (h *filesHTTPHandler) handleCreateSnapshot(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	logger := h.logFromCtx(ctx)
+
+	if h.service == nil {
+		h.writeErrorWithLogger(w, logger, http.StatusServiceUnavailable, "files service unavailable")
+		return
+	}
+
+	authCtx, err := askuser.ParseAuthorizationFromContext(r.Context(), r.Header.Get("Authorization"))
+	if err != nil {
+		h.writeErrorWithLogger(w, logger, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var payload struct {
+		Project string `json:"project"`
+		Name    string `json:"name"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&payload); err != nil {
+		h.writeErrorWithLogger(w, logger, http.StatusBadRequest, "invalid JSON payload")
+		return
+	}
+
+	snapshot, err := h.service.CreateSnapshot(ctx, toFilesAuth(authCtx), payload.Project, payload.Name)
+	if err != nil {
+		h.writeFileError(w, logger, err, "create snapshot")
+		return
+	}
+
+	h.writeJSON(w, snapshotPayload(snapshot))
+}
+
+// handleDeleteSnapshot removes the snapshot named by the project and name query parameters.
+func (h *filesHTTPHandler) handleDeleteSnapshot(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	logger := h.logFromCtx(ctx)
+
+	if h.service == nil {
+		h.writeErrorWithLogger(w, logger, http.StatusServiceUnavailable, "files service unavailable")
+		return
+	}
+
+	authCtx, err := askuser.ParseAuthorizationFromContext(r.Context(), r.Header.Get("Authorization"))
+	if err != nil {
+		h.writeErrorWithLogger(w, logger, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	if err := h.service.DeleteSnapshot(ctx, toFilesAuth(authCtx), query.Get("project"), query.Get("name")); err != nil {
+		h.writeFileError(w, logger, err, "delete snapshot")
+		return
+	}
+
+	h.writeJSON(w, map[string]any{"deleted": true})
+}
+
+// handleDiffSnapshot lists the paths that changed since a snapshot.
+func (h *filesHTTPHandler) handleDiffSnapshot(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+	logger := h.logFromCtx(ctx)
+
+	if h.service == nil {
+		h.writeErrorWithLogger(w, logger, http.StatusServiceUnavailable, "files service unavailable")
+		return
+	}
+
+	authCtx, err := askuser.ParseAuthorizationFromContext(r.Context(), r.Header.Get("Authorization"))
+	if err != nil {
+		h.writeErrorWithLogger(w, logger, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	diff, err := h.service.DiffSnapshot(ctx, toFilesAuth(authCtx), query.Get("project"), query.Get("name"), query.Get("prefix"))
+	if err != nil {
+		h.writeFileError(w, logger, err, "diff snapshot")
+		return
+	}
+
+	h.writeJSON(w, map[string]any{"changes": diff})
+}
+
+// handleRestoreSnapshot restores a project, or the files under prefix, to a named
+// snapshot or to an RFC 3339 timestamp. Exactly one of name and at is required.
+func (h *filesHTTPHandler) handleRestoreSnapshot(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+	logger := h.logFromCtx(ctx)
+
+	if h.service == nil {
+		h.writeErrorWithLogger(w, logger, http.StatusServiceUnavailable, "files service unavailable")
+		return
+	}
+
+	authCtx, err := askuser.ParseAuthorizationFromContext(r.Context(), r.Header.Get("Authorization"))
+	if err != nil {
+		h.writeErrorWithLogger(w, logger, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var payload struct {
+		Project string `json:"project"`
+		Name    string `json:"name"`
+		At      string `json:"at"`
+		Prefix  string `json:"prefix"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&payload); err != nil {
+		h.writeErrorWithLogger(w, logger, http.StatusBadRequest, "invalid JSON payload")
+		return
+	}
+	if (payload.Name == "") == (payload.At == "") {
+		h.writeErrorWithLogger(w, logger, http.StatusBadRequest, "exactly one of name and at is required")
+		return
+	}
+
+	var result SnapshotRestoreResult
+	if payload.Name != "" {
+		result, err = h.service.RestoreSnapshot(ctx, toFilesAuth(authCtx), payload.Project, payload.Name, payload.Prefix)
+	} else {
+		at, parseErr := time.Parse(time.RFC3339, payload.At)
+		if parseErr != nil {
+			h.writeErrorWithLogger(w, logger, http.StatusBadRequest, "at must be an RFC 3339 timestamp")
+			return
+		}
+		result, err = h.service.RestoreToTime(ctx, toFilesAuth(authCtx), payload.Project, at, payload.Prefix)
+	}
+	if err != nil {
+		h.writeFileError(w, logger, err, "restore snapshot")
+		return
+	}
+
+	h.writeJSON(w, map[string]any{
+		"restored":  result.Restored,
+		"deleted":   result.Deleted,
+		"unchanged": result.Unchanged,
+	})
+}
+
+// handleExport streams a project, or the files under prefix, as a tar.gz or zip
+// archive. The archive is staged in a temporary file so a slow client does not hold
+// the project lock while it downloads.
+func (h *filesHTTPHandler) handleExport(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 120*time.Second)
+	defer cancel()
+	logger := h.logFromCtx(ctx)
+
+	if h.service == nil {
+		h.writeErrorWithLogger(w, logger, http.StatusServiceUnavailable, "files service unavailable")
+		return
+	}
+
+	authCtx, err := askuser.ParseAuthorizationFromContext(r.Context(), r.Header.Get("Authorization"))
+	if err != nil {
+		h.writeErrorWithLogger(w, logger, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	format, err := ParseArchiveFormat(query.Get("format"))
+	if err != nil {
+		h.writeFileError(w, logger, err, "export project")
+		return
+	}
+	includeVersions, err := parseBoolQuery(query.Get("versions"))
+	if err != nil {
+		h.writeErrorWithLogger(w, logger, http.StatusBadRequest, "versions must be a boolean")
+		return
+	}
+	includeDeleted, err := parseBoolQuery(query.Get("deleted"))
+	if err != nil {
+		h.writeErrorWithLogger(w, logger, http.StatusBadRequest, "deleted must be a boolean")
+		return
+	}
+
+	staged, err := os.CreateTemp("", "fileio-export-*")
+	if err != nil {
+		logger.Error("create export staging file", zap.Error(err))
+		h.writeErrorWithLogger(w, logger, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	defer func() {
+		_ = staged.Close()
+		_ = os.Remove(staged.Name())
+	}()
+
+	project := query.Get("project")
+	if _, err := h.service.ExportProject(ctx, toFilesAuth(authCtx), project, staged, ExportOptions{
+		Format:          format,
+		Prefix:          query.Get("prefix"),
+		IncludeVersions: includeVersions,
+		IncludeDeleted:  includeDeleted,
+	}); err != nil {
+		h.writeFileError(w, logger, err, "export project")
+		return
+	}
+	if _, err := staged.Seek(0, io.SeekStart); err != nil {
+		logger.Error("rewind export staging file", zap.Error(err))
+		h.writeErrorWithLogger(w, logger, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	contentType := "application/gzip"
+	if format == ArchiveFormatZip {
+		contentType = "application/zip"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, project, format))
+	if _, err := io.Copy(w, staged); err != nil {
+		logger.Warn("stream project export", zap.Error(err))
+	}
+}
+
+// handleImport applies an archive uploaded as the request body to a project. The
+// overwrite query parameter replaces existing files instead of rejecting the import.
+func (h *filesHTTPHandler) handleImport(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 120*time.Second)
+	defer cancel()
+	logger := h.logFromCtx(ctx)
+
+	if h.service == nil {
+		h.writeErrorWithLogger(w, logger, http.StatusServiceUnavailable, "files service unavailable")
+		return
+	}
+
+	authCtx, err := askuser.ParseAuthorizationFromContext(r.Context(), r.Header.Get("Authorization"))
+	if err != nil {
+		h.writeErrorWithLogger(w, logger, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	format, err := ParseArchiveFormat(query.Get("format"))
+	if err != nil {
+		h.writeFileError(w, logger, err, "import project")
+		return
+	}
+	overwrite, err := parseBoolQuery(query.Get("overwrite"))
+	if err != nil {
+		h.writeErrorWithLogger(w, logger, http.StatusBadRequest, "overwrite must be a boolean")
+		return
+	}
+
+	result, err := h.service.ImportProject(ctx, toFilesAuth(authCtx), query.Get("project"), r.Body, ImportOptions{
+		Format:    format,
+		Overwrite: overwrite,
+	})
+	if err != nil {
+		h.writeFileError(w, logger, err, "import project")
+		return
+	}
+
+	h.writeJSON(w, map[string]any{
+		"files":    result.Files,
+		"versions": result.Versions,
+		"deleted":  result.Deleted,
+	})
+}
+
+// parseBoolQuery parses an optional boolean query parameter; empty means false.
+func parseBoolQuery(raw string) (bool, error) {
+	if raw == "" {
+		return false, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, errors.Wrap(err, "parse boolean")
+	}
+	return value, nil
+}
+
+// snapshotPayload renders snapshot metadata for JSON responses.
+func snapshotPayload(snapshot FileSnapshot) map[string]any {
+	return map[string]any{
+		"name":       snapshot.Name,
+		"auto":       snapshot.Auto,
+		"file_count": snapshot.FileCount,
+		"total_size": snapshot.TotalSize,
+		"created_at": snapshot.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
 }
 
 // parseVersionID extracts the numeric version ID from the URL path.
@@ -232,6 +724,189 @@ func parseVersionID(urlPath, suffix string) (uint64, error) {
 	return id, nil
 }
 
+// handleIndexStatus reports the search index health of a project.
+func (h *filesHTTPHandler) handleIndexStatus(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	logger := h.logFromCtx(ctx)
+
+	if h.service == nil {
+		h.writeErrorWithLogger(w, logger, http.StatusServiceUnavailable, "files service unavailable")
+		return
+	}
+
+	authCtx, err := askuser.ParseAuthorizationFromContext(r.Context(), r.Header.Get("Authorization"))
+	if err != nil {
+		h.writeErrorWithLogger(w, logger, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	status, err := h.service.IndexStatus(ctx, toFilesAuth(authCtx), r.URL.Query().Get("project"))
+	if err != nil {
+		h.writeFileError(w, logger, err, "read index status")
+		return
+	}
+
+	h.writeJSON(w, status)
+}
+
+// handleRetryIndexJobs requeues the failed index jobs of a project, or the one given
+// by job_id.
+func (h *filesHTTPHandler) handleRetryIndexJobs(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	logger := h.logFromCtx(ctx)
+
+	if h.service == nil {
+		h.writeErrorWithLogger(w, logger, http.StatusServiceUnavailable, "files service unavailable")
+		return
+	}
+
+	authCtx, err := askuser.ParseAuthorizationFromContext(r.Context(), r.Header.Get("Authorization"))
+	if err != nil {
+		h.writeErrorWithLogger(w, logger, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var payload struct {
+		Project string `json:"project"`
+		JobID   int64  `json:"job_id"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&payload); err != nil {
+		h.writeErrorWithLogger(w, logger, http.StatusBadRequest, "invalid JSON payload")
+		return
+	}
+
+	retried, err := h.service.RetryFailedIndexJobs(ctx, toFilesAuth(authCtx), payload.Project, payload.JobID)
+	if err != nil {
+		h.writeFileError(w, logger, err, "retry index jobs")
+		return
+	}
+
+	h.writeJSON(w, map[string]any{"retried": retried})
+}
+
+// handleStartReindex enqueues a re-index run for an API key, one of its projects, or
+// every stored file. The key is given raw in api_key, which also lets providers billed
+// to it embed, or as apikey_hash; re-indexing everything requires "all": true.
+func (h *filesHTTPHandler) handleStartReindex(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute)
+	defer cancel()
+	logger := h.logFromCtx(ctx)
+
+	if h.service == nil {
+		h.writeErrorWithLogger(w, logger, http.StatusServiceUnavailable, "files service unavailable")
+		return
+	}
+	if !h.authorizeAdmin(w, r, logger) {
+		return
+	}
+
+	var payload struct {
+		APIKey     string `json:"api_key"`
+		APIKeyHash string `json:"apikey_hash"`
+		Project    string `json:"project"`
+		All        bool   `json:"all"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&payload); err != nil {
+		h.writeErrorWithLogger(w, logger, http.StatusBadRequest, "invalid JSON payload")
+		return
+	}
+
+	scope := ReindexScope{APIKeyHash: strings.TrimSpace(payload.APIKeyHash), Project: payload.Project}
+	if strings.TrimSpace(payload.APIKey) != "" {
+		derived, err := mcpauth.DeriveFromAPIKey(payload.APIKey)
+		if err != nil {
+			h.writeErrorWithLogger(w, logger, http.StatusBadRequest, "invalid api_key")
+			return
+		}
+		if scope.APIKeyHash != "" && scope.APIKeyHash != derived.APIKeyHash {
+			h.writeErrorWithLogger(w, logger, http.StatusBadRequest, "api_key does not match apikey_hash")
+			return
+		}
+		scope.APIKey = derived.APIKey
+		scope.APIKeyHash = derived.APIKeyHash
+	}
+	if scope.APIKeyHash == "" && !payload.All {
+		h.writeErrorWithLogger(w, logger, http.StatusBadRequest, "api_key, apikey_hash or all is required")
+		return
+	}
+
+	run, err := h.service.StartReindex(ctx, scope)
+	if err != nil {
+		h.writeFileError(w, logger, err, "start reindex")
+		return
+	}
+
+	h.writeJSON(w, run)
+}
+
+// handleReindexStatus reports one re-index run when id is given, or the latest runs.
+func (h *filesHTTPHandler) handleReindexStatus(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	logger := h.logFromCtx(ctx)
+
+	if h.service == nil {
+		h.writeErrorWithLogger(w, logger, http.StatusServiceUnavailable, "files service unavailable")
+		return
+	}
+	if !h.authorizeAdmin(w, r, logger) {
+		return
+	}
+
+	query := r.URL.Query()
+	if rawID := strings.TrimSpace(query.Get("id")); rawID != "" {
+		id, err := strconv.ParseInt(rawID, 10, 64)
+		if err != nil || id <= 0 {
+			h.writeErrorWithLogger(w, logger, http.StatusBadRequest, "invalid run id")
+			return
+		}
+		run, err := h.service.ReindexRunStatus(ctx, id)
+		if err != nil {
+			h.writeFileError(w, logger, err, "read reindex run")
+			return
+		}
+		h.writeJSON(w, run)
+		return
+	}
+
+	limit := 0
+	if rawLimit := strings.TrimSpace(query.Get("limit")); rawLimit != "" {
+		parsed, err := strconv.Atoi(rawLimit)
+		if err != nil {
+			h.writeErrorWithLogger(w, logger, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = parsed
+	}
+	runs, err := h.service.ListReindexRuns(ctx, limit)
+	if err != nil {
+		h.writeFileError(w, logger, err, "list reindex runs")
+		return
+	}
+	if runs == nil {
+		runs = []ReindexRun{}
+	}
+	h.writeJSON(w, map[string]any{"runs": runs})
+}
+
+// authorizeAdmin checks the admin token header and writes the error response when it
+// does not match. The admin endpoints are disabled while no token is configured.
+func (h *filesHTTPHandler) authorizeAdmin(w http.ResponseWriter, r *http.Request, logger logSDK.Logger) bool {
+	token := h.service.settings.Security.AdminToken
+	if token == "" {
+		h.writeErrorWithLogger(w, logger, http.StatusForbidden, "admin API disabled")
+		return false
+	}
+	provided := strings.TrimSpace(r.Header.Get(adminTokenHeader))
+	if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+		h.writeErrorWithLogger(w, logger, http.StatusForbidden, "invalid admin token")
+		return false
+	}
+	return true
+}
+
 // toFilesAuth maps the shared auth context to the files-package AuthContext.
 func toFilesAuth(authCtx *askuser.AuthorizationContext) AuthContext {
 	if authCtx == nil {
@@ -254,7 +929,7 @@ func (h *filesHTTPHandler) writeFileError(w http.ResponseWriter, logger logSDK.L
 			status = http.StatusNotFound
 		case ErrCodePermissionDenied:
 			status = http.StatusUnauthorized
-		case ErrCodeAlreadyExists, ErrCodeNotEmpty:
+		case ErrCodeAlreadyExists, ErrCodeNotEmpty, ErrCodeConflict:
 			status = http.StatusConflict
 		case ErrCodeIsDirectory, ErrCodeNotDirectory, ErrCodeInvalidArgument, ErrCodeInvalidPath, ErrCodeInvalidOffset, ErrCodeInvalidQuery:
 			status = http.StatusBadRequest
@@ -262,6 +937,8 @@ func (h *filesHTTPHandler) writeFileError(w http.ResponseWriter, logger logSDK.L
 			status = http.StatusRequestEntityTooLarge
 		case ErrCodeQuotaExceeded:
 			status = http.StatusInsufficientStorage
+		case ErrCodePreconditionFailed:
+			status = http.StatusPreconditionFailed
 		case ErrCodeRateLimited:
 			status = http.StatusTooManyRequests
 		case ErrCodeResourceBusy:
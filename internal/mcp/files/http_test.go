@@ -265,6 +265,182 @@ func TestHTTP_PutFile_OversizedBody(t *testing.T) {
 	require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
 }
 
+// TestHTTP_PutFile_RawUpload verifies non-JSON bodies are stored as raw bytes.
+func TestHTTP_PutFile_RawUpload(t *testing.T) {
+	svc, handler, auth := newHTTPTestEnv(t)
+	raw := []byte{0x89, 'P', 'N', 'G', 0x00, 0x01}
+
+	req := httptest.NewRequest(http.MethodPut, "/api/file?project=proj&path=/img.bin", bytes.NewReader(raw))
+	req.Header.Set("Authorization", httpAuthHeader())
+	req.Header.Set("Content-Type", "image/png")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	read, err := svc.Read(context.Background(), auth, "proj", "/img.bin", 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, "image/png", read.MimeType)
+	require.Equal(t, ContentEncodingBase64, read.ContentEncoding)
+	require.Equal(t, base64.StdEncoding.EncodeToString(raw), read.Content)
+}
+
+// TestHTTP_Snapshots_CreateDiffRestore exercises the /api/snapshots endpoints.
+func TestHTTP_Snapshots_CreateDiffRestore(t *testing.T) {
+	svc, handler, auth := newHTTPTestEnv(t)
+
+	_, err := svc.Write(context.Background(), auth, "proj", "/a.txt", "AAA", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+
+	serve := func(method, url, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", httpAuthHeader())
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodPost, "/api/snapshots", `{"project": "proj", "name": "base"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = serve(http.MethodPost, "/api/snapshots", `{"project": "proj", "name": "base"}`)
+	require.Equal(t, http.StatusConflict, rec.Code)
+
+	_, err = svc.Write(context.Background(), auth, "proj", "/a.txt", "BBB", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+
+	rec = serve(http.MethodGet, "/api/snapshots/diff?project=proj&name=base", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var diff struct {
+		Changes []SnapshotDiffEntry `json:"changes"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &diff))
+	require.Equal(t, []SnapshotDiffEntry{{Path: "/a.txt", Change: SnapshotChangeModified}}, diff.Changes)
+
+	rec = serve(http.MethodPost, "/api/snapshots/restore", `{"project": "proj", "name": "base", "at": "2026-02-11T00:00:00Z"}`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = serve(http.MethodPost, "/api/snapshots/restore", `{"project": "proj", "name": "base"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	read, err := svc.Read(context.Background(), auth, "proj", "/a.txt", 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, "AAA", read.Content)
+
+	rec = serve(http.MethodDelete, "/api/snapshots?project=proj&name=base", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = serve(http.MethodGet, "/api/snapshots?project=proj", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"snapshots": []}`, rec.Body.String())
+}
+
+// TestHTTP_ExportImport round-trips a project through the archive endpoints.
+func TestHTTP_ExportImport(t *testing.T) {
+	svc, handler, auth := newHTTPTestEnv(t)
+
+	_, err := svc.Write(context.Background(), auth, "proj", "/a.txt", "AAA", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+
+	serve := func(method, url string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, bytes.NewReader(body))
+		req.Header.Set("Authorization", httpAuthHeader())
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodGet, "/api/export?project=proj&format=rar", nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = serve(http.MethodGet, "/api/export?project=proj&format=zip&versions=true", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "application/zip", rec.Header().Get("Content-Type"))
+	require.Contains(t, rec.Header().Get("Content-Disposition"), `filename="proj.zip"`)
+	archive := rec.Body.Bytes()
+
+	rec = serve(http.MethodPost, "/api/import?project=copy&format=zip", archive)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"files": ["/a.txt"], "versions": 0, "deleted": 0}`, rec.Body.String())
+	read, err := svc.Read(context.Background(), auth, "copy", "/a.txt", 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, "AAA", read.Content)
+
+	rec = serve(http.MethodPost, "/api/import?project=copy&format=zip", archive)
+	require.Equal(t, http.StatusConflict, rec.Code)
+	rec = serve(http.MethodPost, "/api/import?project=copy&format=zip&overwrite=true", archive)
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+// TestHTTP_AdminReindex verifies the re-index endpoints need the admin token and
+// start and report runs scoped by the raw API key.
+func TestHTTP_AdminReindex(t *testing.T) {
+	svc, handler, auth := newHTTPTestEnv(t)
+	_, err := svc.Write(context.Background(), auth, "proj", "/a.txt", "AAA", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+
+	serve := func(method, url, token string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, bytes.NewReader(body))
+		req.Header.Set("Authorization", httpAuthHeader())
+		if token != "" {
+			req.Header.Set(adminTokenHeader, token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodGet, "/api/admin/reindex", "secret", nil)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	svc.settings.Security.AdminToken = "secret"
+	rec = serve(http.MethodGet, "/api/admin/reindex", "wrong", nil)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = serve(http.MethodPost, "/api/admin/reindex", "secret", []byte(`{}`))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = serve(http.MethodPost, "/api/admin/reindex", "secret", []byte(`{"api_key": "`+httpAPIKey+`", "project": "proj"}`))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var run ReindexRun
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &run))
+	require.Equal(t, httpAPIKeyHash(), run.APIKeyHash)
+	require.Equal(t, int64(1), run.TotalJobs)
+
+	rec = serve(http.MethodGet, fmt.Sprintf("/api/admin/reindex?id=%d", run.ID), "secret", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &run))
+	require.Equal(t, "running", run.Status)
+	rec = serve(http.MethodGet, "/api/admin/reindex?id=999", "secret", nil)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+// TestHTTP_IndexStatus verifies the index status endpoint reports the caller's project
+// and retry maps unknown jobs to 404.
+func TestHTTP_IndexStatus(t *testing.T) {
+	svc, handler, auth := newHTTPTestEnv(t)
+	_, err := svc.Write(context.Background(), auth, "proj", "/a.txt", "AAA", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+
+	serve := func(method, url, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", httpAuthHeader())
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodGet, "/api/index_status?project=proj", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var status IndexStatus
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	require.Equal(t, "proj", status.Project)
+	require.Equal(t, int64(1), status.Files)
+	rec = serve(http.MethodGet, "/api/index_status", "")
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = serve(http.MethodPost, "/api/index_status/retry", `{"project": "proj"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"retried": 0}`, rec.Body.String())
+	rec = serve(http.MethodPost, "/api/index_status/retry", `{"project": "proj", "job_id": 999}`)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	rec = serve(http.MethodPost, "/api/index_status/retry", `{`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
 // TestHTTP_UnknownRoute verifies unknown paths return 404.
 func TestHTTP_UnknownRoute(t *testing.T) {
 	_, handler, _ := newHTTPTestEnv(t)
@@ -7,6 +7,7 @@ import (
 )
 
 // buildContextualizedChunkInputs prepends LLM-generated chunk context to each chunk for indexing inputs.
+// The structural Chunk.Context, such as a heading path, is always kept in front of the chunk text.
 func (s *Service) buildContextualizedChunkInputs(ctx context.Context, apiKey, document, filePath string, chunks []Chunk) []string {
 	if len(chunks) == 0 {
 		return nil
@@ -14,7 +15,11 @@ func (s *Service) buildContextualizedChunkInputs(ctx context.Context, apiKey, do
 
 	result := make([]string, 0, len(chunks))
 	for _, chunk := range chunks {
-		result = append(result, chunk.Content)
+		if chunk.Context == "" {
+			result = append(result, chunk.Content)
+			continue
+		}
+		result = append(result, chunk.Context+"\n\n"+chunk.Content)
 	}
 
 	if s == nil || s.contextualizer == nil {
@@ -43,7 +48,7 @@ func (s *Service) buildContextualizedChunkInputs(ctx context.Context, apiKey, do
 		if contextText == "" {
 			continue
 		}
-		result[i] = contextText + "\n\n" + chunks[i].Content
+		result[i] = contextText + "\n\n" + result[i]
 	}
 
 	return result
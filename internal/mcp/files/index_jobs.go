@@ -9,6 +9,11 @@ import (
 
 // storeCredentialEnvelope encrypts and stores the api key for async workers.
 func (s *Service) storeCredentialEnvelope(ctx context.Context, auth AuthContext, project, path string, updatedAt time.Time) error {
+	return s.storeCredentialEnvelopeTTL(ctx, auth, project, path, updatedAt, s.settings.Security.CredentialCacheTTL)
+}
+
+// storeCredentialEnvelopeTTL is storeCredentialEnvelope with an explicit lifetime.
+func (s *Service) storeCredentialEnvelopeTTL(ctx context.Context, auth AuthContext, project, path string, updatedAt time.Time, ttl time.Duration) error {
 	if !s.settings.Search.Enabled {
 		return nil
 	}
@@ -26,7 +31,7 @@ func (s *Service) storeCredentialEnvelope(ctx context.Context, auth AuthContext,
 		return errors.Wrap(err, "encrypt credential")
 	}
 	key := ref.CacheKey(s.settings.Security.CredentialCachePrefix)
-	if err := s.credStore.Store(ctx, key, payload, s.settings.Security.CredentialCacheTTL); err != nil {
+	if err := s.credStore.Store(ctx, key, payload, ttl); err != nil {
 		return errors.Wrap(err, "store credential envelope")
 	}
 	return nil
@@ -20,7 +20,9 @@ import (
 // embeddingPlan describes semantic vectors availability for one indexing attempt.
 type embeddingPlan struct {
 	vectors []pgvector.Vector
-	err     error
+	// model is the model of the provider that produced vectors.
+	model string
+	err   error
 }
 
 // IndexWorker processes file indexing jobs.
@@ -114,8 +116,9 @@ func (w *IndexWorker) claimJobs(ctx context.Context) ([]FileIndexJob, error) {
 
 	// Index workers only process user-namespace jobs. System-owner writes never
 	// enqueue, but we filter defensively so a future bug cannot leak system rows
-	// into the chunking/embedding pipeline.
-	query := "SELECT id, apikey_hash, project, file_path, operation, file_updated_at, status, retry_count, available_at, created_at, updated_at FROM mcp_file_index_jobs WHERE status = ? AND available_at <= ? AND system_owner = ? ORDER BY id ASC LIMIT ?"
+	// into the chunking/embedding pipeline. Live writes are claimed before re-index
+	// jobs so a large re-index run does not hold back their freshness.
+	query := "SELECT id, apikey_hash, project, file_path, operation, file_updated_at, reindex_run_id, status, retry_count, available_at, created_at, updated_at FROM mcp_file_index_jobs WHERE status = ? AND available_at <= ? AND system_owner = ? ORDER BY CASE WHEN reindex_run_id = 0 THEN 0 ELSE 1 END ASC, id ASC LIMIT ?"
 	args := []any{"pending", now, "", batch}
 	if svc.isPostgres {
 		query += " FOR UPDATE SKIP LOCKED"
@@ -136,6 +139,7 @@ func (w *IndexWorker) claimJobs(ctx context.Context) ([]FileIndexJob, error) {
 			&job.FilePath,
 			&job.Operation,
 			&job.FileUpdatedAt,
+			&job.ReindexRunID,
 			&job.Status,
 			&job.RetryCount,
 			&job.AvailableAt,
@@ -198,10 +202,20 @@ func (w *IndexWorker) processJob(ctx context.Context, job FileIndexJob) error {
 	default:
 		return w.markJobFailed(ctx, job, errors.New("unknown job operation"))
 	}
-	if err != nil {
-		return w.handleJobError(ctx, job, err)
+	if errors.Is(err, errReindexNeedsCredential) {
+		// Retrying cannot bring the envelope back; the file keeps its old index rows.
+		err = w.markJobFailed(ctx, job, err)
+	} else if err != nil {
+		err = w.handleJobError(ctx, job, err)
+	} else {
+		err = w.markJobDone(ctx, job)
+	}
+	if job.ReindexRunID > 0 {
+		if refreshErr := svc.refreshReindexRun(ctx, job.ReindexRunID); refreshErr != nil {
+			w.logger.Warn("refresh reindex run failed", zap.Error(refreshErr), zap.Int64("run_id", job.ReindexRunID))
+		}
 	}
-	return w.markJobDone(ctx, job)
+	return err
 }
 
 // handleJobError schedules retries or marks a job as failed.
@@ -218,11 +232,12 @@ func (w *IndexWorker) handleJobError(ctx context.Context, job FileIndexJob, err
 	next := svc.clock().Add(backoff * time.Duration(job.RetryCount+1))
 	_, execErr := svc.db.ExecContext(ctx,
 		rebindSQL(`UPDATE mcp_file_index_jobs
-		SET status = ?, retry_count = ?, available_at = ?, updated_at = ?
+		SET status = ?, retry_count = ?, available_at = ?, last_error = ?, updated_at = ?
 		WHERE id = ? AND system_owner = ?`, svc.isPostgres),
 		"pending",
 		job.RetryCount+1,
 		next,
+		indexJobErrorMessage(err),
 		svc.clock(),
 		job.ID,
 		"",
@@ -235,8 +250,9 @@ func (w *IndexWorker) markJobFailed(ctx context.Context, job FileIndexJob, err e
 	svc := w.svc
 	w.logger.Warn("index job failed", zap.Error(err), zap.Int64("job_id", job.ID))
 	_, execErr := svc.db.ExecContext(ctx,
-		rebindSQL(`UPDATE mcp_file_index_jobs SET status = ?, updated_at = ? WHERE id = ? AND system_owner = ?`, svc.isPostgres),
+		rebindSQL(`UPDATE mcp_file_index_jobs SET status = ?, last_error = ?, updated_at = ? WHERE id = ? AND system_owner = ?`, svc.isPostgres),
 		"failed",
+		indexJobErrorMessage(err),
 		svc.clock(),
 		job.ID,
 		"",
@@ -276,13 +292,23 @@ func (s *Service) processUpsertJob(ctx context.Context, job FileIndexJob) error
 		return nil
 	}
 
-	chunks := s.chunker.Split(string(file.Content))
+	if !IsTextMimeType(file.MimeType) {
+		// Binary files are stored and served as-is but never chunked or embedded.
+		s.LoggerFromContext(ctx).Debug("file index upsert skipped: binary content",
+			zap.String("project", job.Project),
+			zap.String("file_path", job.FilePath),
+			zap.String("mime_type", file.MimeType),
+		)
+		return s.deleteIndexRows(ctx, job.APIKeyHash, job.Project, job.FilePath)
+	}
+
+	chunks := s.chunkers.ForPath(job.FilePath).Split(string(file.Content))
 	apiKey := ""
 	ref := CredentialReference{APIKeyHash: job.APIKeyHash, Project: job.Project, Path: job.FilePath}
 	if job.FileUpdatedAt != nil {
 		ref.UpdatedAt = *job.FileUpdatedAt
 	}
-	if s.contextualizer != nil || s.embedder != nil {
+	if s.contextualizer != nil || s.embeddingConfigured() {
 		loadedAPIKey, loadErr := s.loadCredential(ctx, ref)
 		if loadErr != nil {
 			s.LoggerFromContext(ctx).Debug("load credential failed before indexing",
@@ -297,7 +323,12 @@ func (s *Service) processUpsertJob(ctx context.Context, job FileIndexJob) error
 
 	indexContents := s.buildContextualizedChunkInputs(ctx, apiKey, string(file.Content), job.FilePath, chunks)
 	plan := s.buildEmbeddingPlan(ctx, job, apiKey, indexContents)
-	if err := s.replaceIndexRows(ctx, job, chunks, indexContents, plan.vectors); err != nil {
+	if job.ReindexRunID > 0 && plan.err != nil {
+		// Re-index jobs never degrade a file to lexical-only rows: search keeps
+		// serving its previous vectors until new ones are ready.
+		return plan.err
+	}
+	if err := s.replaceIndexRows(ctx, job, chunks, indexContents, plan); err != nil {
 		return err
 	}
 	if plan.err != nil {
@@ -310,7 +341,7 @@ func (s *Service) processUpsertJob(ctx context.Context, job FileIndexJob) error
 		return plan.err
 	}
 
-	if s.embedder != nil || s.contextualizer != nil {
+	if s.embeddingConfigured() || s.contextualizer != nil {
 		if err := s.deleteCredential(ctx, ref); err != nil {
 			return err
 		}
@@ -331,10 +362,17 @@ func (s *Service) buildEmbeddingPlan(ctx context.Context, job FileIndexJob, apiK
 	if len(indexContents) == 0 {
 		return embeddingPlan{}
 	}
-	if s.embedder == nil {
+	provider, err := s.embeddingProviderFor(ctx, job.APIKeyHash, job.Project)
+	if err != nil {
+		return embeddingPlan{err: errors.Wrap(err, "resolve embedding provider")}
+	}
+	if provider == nil {
 		return embeddingPlan{}
 	}
-	if strings.TrimSpace(apiKey) == "" {
+	if provider.RequiresAPIKey() && strings.TrimSpace(apiKey) == "" {
+		if job.ReindexRunID > 0 {
+			return embeddingPlan{err: errReindexNeedsCredential}
+		}
 		s.LoggerFromContext(ctx).Debug("skip embedding for index upsert: missing credential envelope",
 			zap.String("project", job.Project),
 			zap.String("file_path", job.FilePath),
@@ -342,7 +380,7 @@ func (s *Service) buildEmbeddingPlan(ctx context.Context, job FileIndexJob, apiK
 		return embeddingPlan{}
 	}
 
-	vectors, err := s.embedder.EmbedTexts(ctx, apiKey, indexContents)
+	vectors, err := provider.EmbedTexts(ctx, apiKey, indexContents)
 	if err != nil {
 		return embeddingPlan{err: errors.Wrap(err, "embed chunk contents")}
 	}
@@ -350,7 +388,7 @@ func (s *Service) buildEmbeddingPlan(ctx context.Context, job FileIndexJob, apiK
 		return embeddingPlan{err: errors.New("embedding count mismatch")}
 	}
 
-	return embeddingPlan{vectors: vectors}
+	return embeddingPlan{vectors: vectors, model: provider.Model()}
 }
 
 // processDeleteJob removes index rows for a file path.
@@ -368,8 +406,8 @@ func (s *Service) processDeleteJob(ctx context.Context, job FileIndexJob) error
 }
 
 // replaceIndexRows rebuilds all chunk rows and lexical metadata for a file,
-// and writes embeddings when vectors are provided.
-func (s *Service) replaceIndexRows(ctx context.Context, job FileIndexJob, chunks []Chunk, indexContents []string, vectors []pgvector.Vector) error {
+// and writes embeddings when the plan provides vectors.
+func (s *Service) replaceIndexRows(ctx context.Context, job FileIndexJob, chunks []Chunk, indexContents []string, plan embeddingPlan) error {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return errors.Wrap(err, "begin replace index rows transaction")
@@ -387,7 +425,7 @@ func (s *Service) replaceIndexRows(ctx context.Context, job FileIndexJob, chunks
 		}
 		return nil
 	}
-	shouldWriteEmbeddings := len(vectors) == len(chunks)
+	shouldWriteEmbeddings := len(plan.vectors) == len(chunks)
 	shouldUseContextualizedContent := len(indexContents) == len(chunks)
 
 	for i, ch := range chunks {
@@ -446,7 +484,7 @@ func (s *Service) replaceIndexRows(ctx context.Context, job FileIndexJob, chunks
 			return err
 		}
 		if shouldWriteEmbeddings {
-			if err := s.insertEmbedding(ctx, tx, chunkID, vectors[i], now); err != nil {
+			if err := s.insertEmbedding(ctx, tx, chunkID, plan.vectors[i], plan.model, now); err != nil {
 				_ = tx.Rollback()
 				return err
 			}
@@ -460,14 +498,16 @@ func (s *Service) replaceIndexRows(ctx context.Context, job FileIndexJob, chunks
 	return nil
 }
 
-// insertEmbedding stores a chunk embedding, using pgvector when available.
-func (s *Service) insertEmbedding(ctx context.Context, tx *sql.Tx, chunkID int64, vector pgvector.Vector, now time.Time) error {
+// insertEmbedding stores a chunk embedding with its model and dimensions, using
+// pgvector when available.
+func (s *Service) insertEmbedding(ctx context.Context, tx *sql.Tx, chunkID int64, vector pgvector.Vector, model string, now time.Time) error {
 	if s.isPostgres {
 		_, err := tx.ExecContext(ctx,
-			rebindSQL(`INSERT INTO mcp_file_chunk_embeddings (chunk_id, embedding, model, created_at, updated_at, system_owner) VALUES (?, ?, ?, ?, ?, ?)`, s.isPostgres),
+			rebindSQL(`INSERT INTO mcp_file_chunk_embeddings (chunk_id, embedding, model, dimensions, created_at, updated_at, system_owner) VALUES (?, ?, ?, ?, ?, ?, ?)`, s.isPostgres),
 			chunkID,
 			vector,
-			s.settings.EmbeddingModel,
+			model,
+			len(vector.Slice()),
 			now,
 			now,
 			"",
@@ -480,10 +520,11 @@ func (s *Service) insertEmbedding(ctx context.Context, tx *sql.Tx, chunkID int64
 		return errors.Wrap(err, "marshal embedding")
 	}
 	_, err = tx.ExecContext(ctx,
-		rebindSQL("INSERT INTO mcp_file_chunk_embeddings (chunk_id, embedding, model, created_at, updated_at, system_owner) VALUES (?, ?, ?, ?, ?, ?)", s.isPostgres),
+		rebindSQL("INSERT INTO mcp_file_chunk_embeddings (chunk_id, embedding, model, dimensions, created_at, updated_at, system_owner) VALUES (?, ?, ?, ?, ?, ?, ?)", s.isPostgres),
 		chunkID,
 		string(payload),
-		s.settings.EmbeddingModel,
+		model,
+		len(vector.Slice()),
 		now,
 		now,
 		"",
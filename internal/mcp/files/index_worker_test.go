@@ -121,3 +121,34 @@ func TestIndexWorkerUsesContextualizedInputs(t *testing.T) {
 	require.True(t, strings.Contains(capturedInputs[0], "context-for-chunk"))
 	require.True(t, strings.Contains(capturedInputs[0], "alpha beta gamma delta"))
 }
+
+// TestIndexWorkerSkipsBinaryContent verifies binary files produce no chunks.
+func TestIndexWorkerSkipsBinaryContent(t *testing.T) {
+	settings := LoadSettingsFromConfig()
+	settings.Search.Enabled = true
+	settings.Security.EncryptionKEKs = map[uint16]string{1: testEncryptionKey()}
+	settings.Index.BatchSize = 10
+	settings.Index.ChunkBytes = 64
+	settings.MaxProjectBytes = 10_000
+
+	svc := newTestService(t, settings, testEmbedder{vector: pgvector.NewVector([]float32{1, 0})}, &memoryCredentialStore{})
+	auth := AuthContext{APIKeyHash: "hash", APIKey: "key", UserIdentity: "user:test"}
+
+	_, err := svc.Write(context.Background(), auth, "proj", "/img.png", "iVBORw0KGgoAAAANSUhEUg==", "base64", 0, WriteModeTruncate)
+	require.NoError(t, err)
+
+	worker := svc.NewIndexWorker()
+	require.NoError(t, worker.RunOnce(context.Background()))
+
+	var status string
+	require.NoError(t, svc.db.QueryRowContext(context.Background(),
+		`SELECT status FROM mcp_file_index_jobs WHERE file_path = ? ORDER BY id DESC LIMIT 1`, "/img.png",
+	).Scan(&status))
+	require.Equal(t, "done", status)
+
+	var chunkCount int
+	require.NoError(t, svc.db.QueryRowContext(context.Background(),
+		`SELECT COUNT(1) FROM mcp_file_chunks WHERE file_path = ?`, "/img.png",
+	).Scan(&chunkCount))
+	require.Zero(t, chunkCount)
+}
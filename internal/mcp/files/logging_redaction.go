@@ -11,6 +11,7 @@ var FileToolNames = map[string]struct{}{
 	"file_rename": {},
 	"file_list":   {},
 	"file_search": {},
+	"file_grep":   {},
 }
 
 // RedactToolArguments removes sensitive payloads from tool arguments.
@@ -46,6 +47,9 @@ func RedactToolResult(toolName string, result map[string]any) map[string]any {
 			cloned["chunks"] = sanitized
 		}
 	}
+	if matches, ok := cloned["matches"]; ok {
+		cloned["matches"] = redactGrepMatches(matches)
+	}
 	return cloned
 }
 
@@ -71,6 +75,30 @@ func redactChunks(value any) any {
 	return result
 }
 
+// redactGrepMatches removes matched lines and their context from grep results.
+func redactGrepMatches(value any) any {
+	slice, ok := value.([]any)
+	if !ok {
+		return value
+	}
+	result := make([]any, 0, len(slice))
+	for _, item := range slice {
+		entry, ok := item.(map[string]any)
+		if !ok {
+			result = append(result, item)
+			continue
+		}
+		cloned := cloneMap(entry)
+		for _, key := range []string{"line", "context_before", "context_after"} {
+			if content, ok := cloned[key]; ok {
+				cloned[key] = summarizeRedaction(content)
+			}
+		}
+		result = append(result, cloned)
+	}
+	return result
+}
+
 // summarizeRedaction builds a lightweight redaction summary for logs.
 func summarizeRedaction(value any) map[string]any {
 	var length int
@@ -33,3 +33,23 @@ func TestRedactToolResultChunks(t *testing.T) {
 	require.True(t, ok)
 	require.Equal(t, true, payload["redacted"])
 }
+
+// TestRedactToolResultGrepMatches ensures matched lines are redacted in grep results.
+func TestRedactToolResultGrepMatches(t *testing.T) {
+	result := map[string]any{
+		"matches": []any{
+			map[string]any{"file_path": "/a.md", "line": "secret", "context_before": []any{"also secret"}},
+		},
+	}
+	redacted := RedactToolResult("file_grep", result)
+	matches, ok := redacted["matches"].([]any)
+	require.True(t, ok)
+	entry, ok := matches[0].(map[string]any)
+	require.True(t, ok)
+	require.Equal(t, "/a.md", entry["file_path"])
+	line, ok := entry["line"].(map[string]any)
+	require.True(t, ok)
+	require.Equal(t, true, line["redacted"])
+	_, ok = entry["context_before"].(map[string]any)
+	require.True(t, ok)
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"strings"
+	"time"
 
 	errors "github.com/Laisky/errors/v2"
 	logSDK "github.com/Laisky/go-utils/v6/log"
+	"github.com/Laisky/zap"
 
 	"github.com/Laisky/laisky-blog-graphql/library/log"
 )
@@ -54,6 +56,34 @@ func RunMigrations(ctx context.Context, db *sql.DB, logger logSDK.Logger) error
 		return errors.WithStack(err)
 	}
 
+	if err := applyContentHashColumns(ctx, db, isPostgres); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := applyMimeTypeColumn(ctx, db, isPostgres); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := applyRevisionColumn(ctx, db, isPostgres); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := applyEmbeddingDimensionsColumn(ctx, db, isPostgres); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := applyReindexRunColumn(ctx, db, isPostgres); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := applyIndexJobLastErrorColumn(ctx, db, isPostgres); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := applyReindexRunSkippedColumn(ctx, db, isPostgres); err != nil {
+		return errors.WithStack(err)
+	}
+
 	statements := []string{}
 	if isPostgres {
 		statements = []string{
@@ -62,7 +92,9 @@ func RunMigrations(ctx context.Context, db *sql.DB, logger logSDK.Logger) error
 			`CREATE INDEX IF NOT EXISTS idx_mcp_files_deleted_at ON mcp_files (deleted_at) WHERE deleted = TRUE`,
 			`CREATE INDEX IF NOT EXISTS idx_mcp_file_chunks_prefix ON mcp_file_chunks (apikey_hash, project, file_path text_pattern_ops)`,
 			`CREATE INDEX IF NOT EXISTS idx_mcp_file_index_jobs_pending ON mcp_file_index_jobs (status, available_at, id)`,
+			`CREATE INDEX IF NOT EXISTS idx_mcp_file_index_jobs_reindex_run ON mcp_file_index_jobs (reindex_run_id, status) WHERE reindex_run_id > 0`,
 			`CREATE INDEX IF NOT EXISTS idx_mcp_file_versions_path ON mcp_file_versions (apikey_hash, project, path, created_at DESC)`,
+			`CREATE INDEX IF NOT EXISTS idx_mcp_file_blobs_unreferenced ON mcp_file_blobs (updated_at) WHERE ref_count <= 0`,
 		}
 	}
 
@@ -78,6 +110,23 @@ func RunMigrations(ctx context.Context, db *sql.DB, logger logSDK.Logger) error
 		}
 	}
 
+	for _, stmt := range snapshotIndexStatements() {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return errors.Wrap(err, "create snapshot index")
+		}
+	}
+
+	// Status, backlog and retry look up the later jobs of each failed job's file.
+	if _, err := db.ExecContext(ctx,
+		`CREATE INDEX IF NOT EXISTS idx_mcp_file_index_jobs_file ON mcp_file_index_jobs (apikey_hash, project, file_path, id)`,
+	); err != nil {
+		return errors.Wrap(err, "create index job file index")
+	}
+
+	if err := backfillContentBlobs(ctx, db, isPostgres, logger); err != nil {
+		return errors.WithStack(err)
+	}
+
 	logger.Debug("mcp files migrations completed")
 	return nil
 }
@@ -116,6 +165,221 @@ func applySkipRAGIndexColumn(ctx context.Context, db *sql.DB, isPostgres bool) e
 		`ALTER TABLE mcp_files ADD COLUMN skip_rag_index BOOLEAN NOT NULL DEFAULT 0`)
 }
 
+// applyContentHashColumns adds the content_hash column that points file and version
+// rows at their shared row in mcp_file_blobs. Rows written before the column existed
+// keep an empty hash until backfillContentBlobs moves their inline content.
+func applyContentHashColumns(ctx context.Context, db *sql.DB, isPostgres bool) error {
+	for _, table := range []string{"mcp_files", "mcp_file_versions"} {
+		if isPostgres {
+			stmt := `ALTER TABLE ` + table + ` ADD COLUMN IF NOT EXISTS content_hash VARCHAR(64) NOT NULL DEFAULT ''`
+			if _, err := db.ExecContext(ctx, stmt); err != nil {
+				return errors.Wrapf(err, "add content_hash column on %s", table)
+			}
+			continue
+		}
+		if err := applyAddColumnIfMissing(ctx, db, table, "content_hash",
+			`ALTER TABLE `+table+` ADD COLUMN content_hash TEXT NOT NULL DEFAULT ''`); err != nil {
+			return errors.Wrapf(err, "add content_hash column on %s", table)
+		}
+	}
+	return nil
+}
+
+// backfillContentBlobs moves inline content written before mcp_file_blobs existed into
+// blobs, one batch per transaction, so re-running after a partial pass is safe.
+func backfillContentBlobs(ctx context.Context, db *sql.DB, isPostgres bool, logger logSDK.Logger) error {
+	for _, table := range []string{"mcp_files", "mcp_file_versions"} {
+		var moved int
+		for {
+			n, err := backfillContentBlobsBatch(ctx, db, isPostgres, table)
+			if err != nil {
+				return errors.Wrapf(err, "backfill content blobs on %s", table)
+			}
+			moved += n
+			if n < blobGCBatchSize {
+				break
+			}
+		}
+		if moved > 0 {
+			logger.Info("moved inline file content into blobs", zap.String("table", table), zap.Int("rows", moved))
+		}
+	}
+	return nil
+}
+
+// backfillContentBlobsBatch converts up to blobGCBatchSize legacy rows of table.
+func backfillContentBlobsBatch(ctx context.Context, db *sql.DB, isPostgres bool, table string) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "begin backfill transaction")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	rows, err := tx.QueryContext(ctx,
+		rebindSQL(`SELECT id, content FROM `+table+` WHERE content_hash = '' ORDER BY id ASC LIMIT ?`, isPostgres),
+		blobGCBatchSize,
+	)
+	if err != nil {
+		return 0, errors.Wrap(err, "query legacy rows")
+	}
+	type legacyRow struct {
+		id      uint64
+		content []byte
+	}
+	var legacy []legacyRow
+	for rows.Next() {
+		var row legacyRow
+		if scanErr := rows.Scan(&row.id, &row.content); scanErr != nil {
+			_ = rows.Close()
+			return 0, errors.Wrap(scanErr, "scan legacy row")
+		}
+		legacy = append(legacy, row)
+	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return 0, errors.Wrap(err, "iterate legacy rows")
+	}
+	if err := rows.Close(); err != nil {
+		return 0, errors.Wrap(err, "close legacy rows cursor")
+	}
+
+	now := time.Now().UTC()
+	for _, row := range legacy {
+		hash, err := referenceBlobTx(ctx, tx, isPostgres, row.content, now)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx,
+			rebindSQL(`UPDATE `+table+` SET content = ?, content_hash = ? WHERE id = ?`, isPostgres),
+			[]byte{},
+			hash,
+			row.id,
+		); err != nil {
+			return 0, errors.Wrap(err, "point legacy row at blob")
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, errors.Wrap(err, "commit backfill transaction")
+	}
+	return len(legacy), nil
+}
+
+// applyMimeTypeColumn adds mcp_files.mime_type. Rows written before the column existed
+// keep an empty type, which readers treat as text.
+func applyMimeTypeColumn(ctx context.Context, db *sql.DB, isPostgres bool) error {
+	if isPostgres {
+		stmt := `ALTER TABLE mcp_files ADD COLUMN IF NOT EXISTS mime_type VARCHAR(255) NOT NULL DEFAULT ''`
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return errors.Wrap(err, "add mime_type column on mcp_files")
+		}
+		return nil
+	}
+	return applyAddColumnIfMissing(ctx, db, "mcp_files", "mime_type",
+		`ALTER TABLE mcp_files ADD COLUMN mime_type TEXT NOT NULL DEFAULT ''`)
+}
+
+// applyRevisionColumn adds mcp_files.revision. Existing files start at revision 1.
+func applyRevisionColumn(ctx context.Context, db *sql.DB, isPostgres bool) error {
+	if isPostgres {
+		stmt := `ALTER TABLE mcp_files ADD COLUMN IF NOT EXISTS revision BIGINT NOT NULL DEFAULT 1`
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return errors.Wrap(err, "add revision column on mcp_files")
+		}
+		return nil
+	}
+	return applyAddColumnIfMissing(ctx, db, "mcp_files", "revision",
+		`ALTER TABLE mcp_files ADD COLUMN revision INTEGER NOT NULL DEFAULT 1`)
+}
+
+// applyEmbeddingDimensionsColumn records the dimensions of every chunk embedding and,
+// on Postgres, drops the fixed vector(1536) type of older schemas so providers with
+// other dimensions can share the table. Both steps rewrite the whole table, so they
+// only run once: the type change while the column still has a fixed dimension, and
+// the backfill from stored vectors when the column is first added.
+func applyEmbeddingDimensionsColumn(ctx context.Context, db *sql.DB, isPostgres bool) error {
+	var (
+		exists bool
+		err    error
+	)
+	backfill := `UPDATE mcp_file_chunk_embeddings SET dimensions = json_array_length(embedding) WHERE dimensions = 0`
+	if isPostgres {
+		fixedDims, typErr := postgresVectorDimensions(ctx, db, "mcp_file_chunk_embeddings", "embedding")
+		if typErr != nil {
+			return errors.Wrap(typErr, "probe mcp_file_chunk_embeddings.embedding type")
+		}
+		if fixedDims > 0 {
+			if _, err := db.ExecContext(ctx, `ALTER TABLE mcp_file_chunk_embeddings ALTER COLUMN embedding TYPE vector`); err != nil {
+				return errors.Wrap(err, "relax mcp_file_chunk_embeddings.embedding type")
+			}
+		}
+		exists, err = postgresColumnExists(ctx, db, "mcp_file_chunk_embeddings", "dimensions")
+		backfill = `UPDATE mcp_file_chunk_embeddings SET dimensions = vector_dims(embedding) WHERE dimensions = 0`
+	} else {
+		exists, err = sqliteColumnExists(ctx, db, "mcp_file_chunk_embeddings", "dimensions")
+	}
+	if err != nil {
+		return errors.Wrap(err, "probe mcp_file_chunk_embeddings.dimensions")
+	}
+	if exists {
+		return nil
+	}
+
+	addColumn := `ALTER TABLE mcp_file_chunk_embeddings ADD COLUMN dimensions INTEGER NOT NULL DEFAULT 0`
+	if isPostgres {
+		// Another instance may be migrating concurrently.
+		addColumn = `ALTER TABLE mcp_file_chunk_embeddings ADD COLUMN IF NOT EXISTS dimensions INTEGER NOT NULL DEFAULT 0`
+	}
+	if _, err := db.ExecContext(ctx, addColumn); err != nil {
+		return errors.Wrap(err, "add dimensions column on mcp_file_chunk_embeddings")
+	}
+	if _, err := db.ExecContext(ctx, backfill); err != nil {
+		return errors.Wrap(err, "backfill mcp_file_chunk_embeddings dimensions")
+	}
+	return nil
+}
+
+// applyReindexRunColumn adds mcp_file_index_jobs.reindex_run_id, which links jobs
+// enqueued by a re-index run to their mcp_file_reindex_runs row. Live writes keep 0.
+func applyReindexRunColumn(ctx context.Context, db *sql.DB, isPostgres bool) error {
+	if isPostgres {
+		stmt := `ALTER TABLE mcp_file_index_jobs ADD COLUMN IF NOT EXISTS reindex_run_id BIGINT NOT NULL DEFAULT 0`
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return errors.Wrap(err, "add reindex_run_id column on mcp_file_index_jobs")
+		}
+		return nil
+	}
+	return applyAddColumnIfMissing(ctx, db, "mcp_file_index_jobs", "reindex_run_id",
+		`ALTER TABLE mcp_file_index_jobs ADD COLUMN reindex_run_id INTEGER NOT NULL DEFAULT 0`)
+}
+
+// applyIndexJobLastErrorColumn adds mcp_file_index_jobs.last_error, the error of the
+// latest failed attempt, reported by IndexStatus.
+func applyIndexJobLastErrorColumn(ctx context.Context, db *sql.DB, isPostgres bool) error {
+	if isPostgres {
+		stmt := `ALTER TABLE mcp_file_index_jobs ADD COLUMN IF NOT EXISTS last_error TEXT NOT NULL DEFAULT ''`
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return errors.Wrap(err, "add last_error column on mcp_file_index_jobs")
+		}
+		return nil
+	}
+	return applyAddColumnIfMissing(ctx, db, "mcp_file_index_jobs", "last_error",
+		`ALTER TABLE mcp_file_index_jobs ADD COLUMN last_error TEXT NOT NULL DEFAULT ''`)
+}
+
+// applyReindexRunSkippedColumn adds mcp_file_reindex_runs.skipped, the JSON list of
+// projects an all-keys run left out because they need the owner's API key.
+func applyReindexRunSkippedColumn(ctx context.Context, db *sql.DB, isPostgres bool) error {
+	if isPostgres {
+		stmt := `ALTER TABLE mcp_file_reindex_runs ADD COLUMN IF NOT EXISTS skipped TEXT NOT NULL DEFAULT ''`
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return errors.Wrap(err, "add skipped column on mcp_file_reindex_runs")
+		}
+		return nil
+	}
+	return applyAddColumnIfMissing(ctx, db, "mcp_file_reindex_runs", "skipped",
+		`ALTER TABLE mcp_file_reindex_runs ADD COLUMN skipped TEXT NOT NULL DEFAULT ''`)
+}
+
 // applyAddColumnIfMissing emulates ADD COLUMN IF NOT EXISTS for SQLite, which lacked
 // native support before 3.35. We probe PRAGMA table_info first and only run the ALTER
 // when the column is absent, so the migration is safe to re-run.
@@ -133,6 +397,38 @@ func applyAddColumnIfMissing(ctx context.Context, db *sql.DB, table, column, ddl
 	return nil
 }
 
+// postgresColumnExists returns true when the column exists on table in the current schema.
+func postgresColumnExists(ctx context.Context, db *sql.DB, table, column string) (bool, error) {
+	var count int
+	err := db.QueryRowContext(ctx,
+		`SELECT COUNT(1) FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = $1 AND column_name = $2`,
+		table,
+		column,
+	).Scan(&count)
+	if err != nil {
+		return false, errors.Wrap(err, "query information_schema.columns")
+	}
+	return count > 0, nil
+}
+
+// postgresVectorDimensions returns the fixed dimension of a pgvector column, such as
+// 1536 for vector(1536), or 0 when the column is unconstrained.
+func postgresVectorDimensions(ctx context.Context, db *sql.DB, table, column string) (int, error) {
+	var typmod int
+	err := db.QueryRowContext(ctx,
+		`SELECT a.atttypmod FROM pg_attribute a
+		JOIN pg_class c ON c.oid = a.attrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = current_schema() AND c.relname = $1 AND a.attname = $2 AND NOT a.attisdropped`,
+		table,
+		column,
+	).Scan(&typmod)
+	if err != nil {
+		return 0, errors.Wrap(err, "query pg_attribute")
+	}
+	return max(typmod, 0), nil
+}
+
 // sqliteColumnExists returns true when PRAGMA table_info reports the column.
 func sqliteColumnExists(ctx context.Context, db *sql.DB, table, column string) (bool, error) {
 	rows, err := db.QueryContext(ctx, "PRAGMA table_info("+table+")")
@@ -172,6 +468,15 @@ func systemOwnerIndexStatements() []string {
 	}
 }
 
+// snapshotIndexStatements returns the indexes backing project snapshots. Snapshot
+// names are unique within a project, and entries are always read per snapshot.
+func snapshotIndexStatements() []string {
+	return []string{
+		`CREATE UNIQUE INDEX IF NOT EXISTS uq_mcp_file_snapshots_name ON mcp_file_snapshots (apikey_hash, project, system_owner, name)`,
+		`CREATE INDEX IF NOT EXISTS idx_mcp_file_snapshot_entries_snapshot ON mcp_file_snapshot_entries (snapshot_id, path)`,
+	}
+}
+
 // migrationTableStatements returns CREATE TABLE statements for supported databases.
 func migrationTableStatements(isPostgres bool) []string {
 	if isPostgres {
@@ -204,7 +509,7 @@ func migrationTableStatements(isPostgres bool) []string {
 			)`,
 			`CREATE TABLE IF NOT EXISTS mcp_file_chunk_embeddings (
 				chunk_id BIGINT PRIMARY KEY,
-				embedding vector(1536) NOT NULL,
+				embedding vector NOT NULL,
 				model VARCHAR(128) NOT NULL,
 				created_at TIMESTAMPTZ NOT NULL,
 				updated_at TIMESTAMPTZ NOT NULL
@@ -240,6 +545,51 @@ func migrationTableStatements(isPostgres bool) []string {
 				created_at TIMESTAMPTZ NOT NULL,
 				source_file_id BIGINT
 			)`,
+			`CREATE TABLE IF NOT EXISTS mcp_file_blobs (
+				hash VARCHAR(64) PRIMARY KEY,
+				content BYTEA NOT NULL,
+				size BIGINT NOT NULL,
+				ref_count BIGINT NOT NULL,
+				created_at TIMESTAMPTZ NOT NULL,
+				updated_at TIMESTAMPTZ NOT NULL
+			)`,
+			`CREATE TABLE IF NOT EXISTS mcp_file_snapshots (
+				id BIGSERIAL PRIMARY KEY,
+				apikey_hash VARCHAR(64) NOT NULL,
+				project VARCHAR(128) NOT NULL,
+				name VARCHAR(128) NOT NULL,
+				automatic BOOLEAN NOT NULL DEFAULT FALSE,
+				file_count BIGINT NOT NULL,
+				total_size BIGINT NOT NULL,
+				system_owner TEXT NOT NULL DEFAULT '',
+				created_at TIMESTAMPTZ NOT NULL
+			)`,
+			`CREATE TABLE IF NOT EXISTS mcp_file_snapshot_entries (
+				id BIGSERIAL PRIMARY KEY,
+				snapshot_id BIGINT NOT NULL,
+				path VARCHAR(1024) NOT NULL,
+				content_hash VARCHAR(64) NOT NULL,
+				mime_type VARCHAR(255) NOT NULL DEFAULT '',
+				size BIGINT NOT NULL
+			)`,
+			`CREATE TABLE IF NOT EXISTS mcp_file_project_settings (
+				apikey_hash VARCHAR(64) NOT NULL,
+				project VARCHAR(128) NOT NULL,
+				system_owner TEXT NOT NULL DEFAULT '',
+				embedding_provider VARCHAR(64) NOT NULL,
+				updated_at TIMESTAMPTZ NOT NULL,
+				PRIMARY KEY (apikey_hash, project, system_owner)
+			)`,
+			`CREATE TABLE IF NOT EXISTS mcp_file_reindex_runs (
+				id BIGSERIAL PRIMARY KEY,
+				apikey_hash VARCHAR(64) NOT NULL,
+				project VARCHAR(128) NOT NULL,
+				status VARCHAR(16) NOT NULL,
+				total_jobs BIGINT NOT NULL,
+				created_at TIMESTAMPTZ NOT NULL,
+				updated_at TIMESTAMPTZ NOT NULL,
+				completed_at TIMESTAMPTZ
+			)`,
 		}
 	}
 
@@ -308,6 +658,51 @@ func migrationTableStatements(isPostgres bool) []string {
 			created_at DATETIME NOT NULL,
 			source_file_id INTEGER
 		)`,
+		`CREATE TABLE IF NOT EXISTS mcp_file_blobs (
+			hash TEXT PRIMARY KEY,
+			content BLOB NOT NULL,
+			size INTEGER NOT NULL,
+			ref_count INTEGER NOT NULL,
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS mcp_file_snapshots (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			apikey_hash TEXT NOT NULL,
+			project TEXT NOT NULL,
+			name TEXT NOT NULL,
+			automatic BOOLEAN NOT NULL DEFAULT FALSE,
+			file_count INTEGER NOT NULL,
+			total_size INTEGER NOT NULL,
+			system_owner TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS mcp_file_snapshot_entries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			snapshot_id INTEGER NOT NULL,
+			path TEXT NOT NULL,
+			content_hash TEXT NOT NULL,
+			mime_type TEXT NOT NULL DEFAULT '',
+			size INTEGER NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS mcp_file_project_settings (
+			apikey_hash TEXT NOT NULL,
+			project TEXT NOT NULL,
+			system_owner TEXT NOT NULL DEFAULT '',
+			embedding_provider TEXT NOT NULL,
+			updated_at DATETIME NOT NULL,
+			PRIMARY KEY (apikey_hash, project, system_owner)
+		)`,
+		`CREATE TABLE IF NOT EXISTS mcp_file_reindex_runs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			apikey_hash TEXT NOT NULL,
+			project TEXT NOT NULL,
+			status TEXT NOT NULL,
+			total_jobs INTEGER NOT NULL,
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL,
+			completed_at DATETIME
+		)`,
 	}
 }
 
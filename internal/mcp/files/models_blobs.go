@@ -0,0 +1,20 @@
+package files
+
+import "time"
+
+// FileBlob is content shared by every file and version row whose bytes hash to Hash.
+type FileBlob struct {
+	Hash    string
+	Content []byte
+	Size    int64
+	// RefCount is the number of mcp_files, mcp_file_versions and mcp_file_snapshot_entries
+	// rows referencing the blob.
+	RefCount  int64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// TableName returns the database table name.
+func (FileBlob) TableName() string {
+	return "mcp_file_blobs"
+}
@@ -10,10 +10,17 @@ type File struct {
 	Path       string
 	Content    []byte
 	Size       int64
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
-	Deleted    bool
-	DeletedAt  *time.Time
+	// ContentHash is the SHA-256 of Content and the key of its row in mcp_file_blobs.
+	// It is empty only for legacy rows that still hold their content inline.
+	ContentHash string
+	// MimeType is the media type recorded at write time; empty for legacy rows.
+	MimeType string
+	// Revision increases by one on every write, rename or delete of the path.
+	Revision  int64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Deleted   bool
+	DeletedAt *time.Time
 }
 
 // TableName returns the database table name.
@@ -33,8 +33,11 @@ type FileChunkEmbedding struct {
 	ChunkID   int64
 	Embedding pgvector.Vector
 	Model     string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	// Dimensions is the length of Embedding, recorded so search only compares vectors
+	// of the same model and size.
+	Dimensions int
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
 }
 
 // TableName returns the database table name.
@@ -65,11 +68,13 @@ type FileIndexJob struct {
 	FilePath      string
 	Operation     string
 	FileUpdatedAt *time.Time
-	Status        string
-	RetryCount    int
-	AvailableAt   time.Time
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
+	// ReindexRunID links jobs enqueued by StartReindex to their run; 0 for live writes.
+	ReindexRunID int64
+	Status       string
+	RetryCount   int
+	AvailableAt  time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
 }
 
 // TableName returns the database table name.
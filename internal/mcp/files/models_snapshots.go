@@ -0,0 +1,37 @@
+package files
+
+import "time"
+
+// FileSnapshot is a named copy of a project's file tree at one moment.
+type FileSnapshot struct {
+	ID         uint64
+	APIKeyHash string
+	Project    string
+	Name       string
+	// Auto marks snapshots taken by the snapshot worker rather than on request.
+	Auto      bool
+	FileCount int64
+	TotalSize int64
+	CreatedAt time.Time
+}
+
+// TableName returns the database table name.
+func (FileSnapshot) TableName() string {
+	return "mcp_file_snapshots"
+}
+
+// FileSnapshotEntry is one file captured by a snapshot. Each entry holds its own
+// reference on the blob keyed by ContentHash.
+type FileSnapshotEntry struct {
+	ID          uint64
+	SnapshotID  uint64
+	Path        string
+	ContentHash string
+	MimeType    string
+	Size        int64
+}
+
+// TableName returns the database table name.
+func (FileSnapshotEntry) TableName() string {
+	return "mcp_file_snapshot_entries"
+}
@@ -10,6 +10,7 @@ type FileVersion struct {
 	Path         string
 	Content      []byte
 	Size         int64
+	ContentHash  string
 	CreatedAt    time.Time
 	SourceFileID *uint64
 }
@@ -0,0 +1,341 @@
+package files
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// patchConflictContextLines bounds how many lines a conflict message quotes.
+	patchConflictContextLines = 8
+	// patchConflictLineBytes bounds the length of each quoted line.
+	patchConflictLineBytes = 200
+)
+
+// PatchHunk is one search/replace edit accepted by PATCH writes.
+type PatchHunk struct {
+	Search  string `json:"search"`
+	Replace string `json:"replace"`
+	// ReplaceAll replaces every occurrence instead of requiring exactly one match.
+	ReplaceAll bool `json:"replace_all,omitempty"`
+}
+
+// applyPatch applies a PATCH payload to existing. The payload is either a JSON array
+// of PatchHunk objects or a unified diff. Hunks apply in order and all-or-nothing;
+// a hunk whose context no longer matches fails with ErrCodeConflict.
+func applyPatch(existing, patch []byte) ([]byte, error) {
+	trimmed := bytes.TrimSpace(patch)
+	if len(trimmed) == 0 {
+		return nil, NewError(ErrCodeInvalidArgument, "patch is empty", false)
+	}
+	if trimmed[0] == '[' {
+		var hunks []PatchHunk
+		if err := json.Unmarshal(trimmed, &hunks); err != nil {
+			return nil, NewError(ErrCodeInvalidArgument, "patch must be a unified diff or a JSON array of {search, replace} hunks", false)
+		}
+		return applySearchReplace(existing, hunks)
+	}
+	return applyUnifiedDiff(existing, string(patch))
+}
+
+// applySearchReplace applies search/replace hunks in order.
+func applySearchReplace(existing []byte, hunks []PatchHunk) ([]byte, error) {
+	if len(hunks) == 0 {
+		return nil, NewError(ErrCodeInvalidArgument, "patch has no hunks", false)
+	}
+	content := string(existing)
+	for i, hunk := range hunks {
+		if hunk.Search == "" {
+			return nil, NewError(ErrCodeInvalidArgument, fmt.Sprintf("patch hunk %d has an empty search", i+1), false)
+		}
+		count := strings.Count(content, hunk.Search)
+		switch {
+		case count == 0:
+			return nil, NewError(ErrCodeConflict,
+				fmt.Sprintf("patch hunk %d does not apply: search text not found:\n%s", i+1, quotePatchLines(splitPatchLines(hunk.Search))), false)
+		case count > 1 && !hunk.ReplaceAll:
+			return nil, NewError(ErrCodeConflict,
+				fmt.Sprintf("patch hunk %d is ambiguous: search text matches %d times; add context or set replace_all", i+1, count), false)
+		case hunk.ReplaceAll:
+			content = strings.ReplaceAll(content, hunk.Search, hunk.Replace)
+		default:
+			content = strings.Replace(content, hunk.Search, hunk.Replace, 1)
+		}
+	}
+	return []byte(content), nil
+}
+
+// diffHunk is one parsed "@@ -a,b +c,d @@" section of a unified diff.
+type diffHunk struct {
+	oldStart int
+	oldLines []string
+	newLines []string
+	// newNoEOL records a "\ No newline at end of file" marker on the new side.
+	newNoEOL bool
+}
+
+// applyUnifiedDiff applies a unified diff. A hunk is tried at its stated line first,
+// then at the nearest position after the previous hunk where its context matches.
+//
+// The diff is parsed with "\n" line endings, so a CRLF file is matched with its line
+// endings normalized the same way and written back with CRLF.
+func applyUnifiedDiff(existing []byte, diff string) ([]byte, error) {
+	hunks, err := parseUnifiedDiff(diff)
+	if err != nil {
+		return nil, err
+	}
+
+	crlf := bytes.Contains(existing, []byte("\r\n"))
+	if crlf {
+		existing = bytes.ReplaceAll(existing, []byte("\r\n"), []byte("\n"))
+	}
+	lines := splitPatchLines(string(existing))
+	finalNewline := len(existing) == 0 || existing[len(existing)-1] == '\n'
+	out := make([]string, 0, len(lines))
+	cursor := 0
+	for i, hunk := range hunks {
+		at, ok := locateHunk(lines, hunk.oldLines, hunk.startIndex(), cursor)
+		if !ok {
+			return nil, hunkConflictError(i+1, hunk, lines, cursor)
+		}
+		out = append(out, lines[cursor:at]...)
+		out = append(out, hunk.newLines...)
+		cursor = at + len(hunk.oldLines)
+		if cursor == len(lines) {
+			finalNewline = !hunk.newNoEOL
+		}
+	}
+	out = append(out, lines[cursor:]...)
+
+	if len(out) == 0 {
+		return []byte{}, nil
+	}
+	lineEnding := "\n"
+	if crlf {
+		lineEnding = "\r\n"
+	}
+	result := strings.Join(out, lineEnding)
+	if finalNewline {
+		result += lineEnding
+	}
+	return []byte(result), nil
+}
+
+// parseUnifiedDiff extracts hunks, ignoring file headers such as "---" and "+++".
+// Each hunk spans exactly the line counts of its "@@" header, so blank lines after
+// the last hunk are ignored and a "---" line that follows a complete hunk is read as
+// another file's header. A diff that touches more than one file is rejected because
+// a PATCH write targets a single path.
+func parseUnifiedDiff(diff string) ([]diffHunk, error) {
+	var (
+		hunks            []diffHunk
+		current          *diffHunk
+		oldLeft, newLeft int
+		lastOp           byte
+	)
+	diff = strings.TrimSuffix(strings.ReplaceAll(diff, "\r\n", "\n"), "\n")
+	for _, line := range strings.Split(diff, "\n") {
+		if current != nil && (oldLeft > 0 || newLeft > 0) {
+			switch {
+			case strings.HasPrefix(line, `\`):
+				if lastOp != '-' {
+					current.newNoEOL = true
+				}
+				continue
+			case strings.HasPrefix(line, "+") && newLeft > 0:
+				current.newLines = append(current.newLines, line[1:])
+				newLeft--
+				lastOp = '+'
+				continue
+			case strings.HasPrefix(line, "-") && oldLeft > 0:
+				current.oldLines = append(current.oldLines, line[1:])
+				oldLeft--
+				lastOp = '-'
+				continue
+			case (strings.HasPrefix(line, " ") || line == "") && oldLeft > 0 && newLeft > 0:
+				// Some tools strip the single space that marks a blank context line.
+				context := strings.TrimPrefix(line, " ")
+				current.oldLines = append(current.oldLines, context)
+				current.newLines = append(current.newLines, context)
+				oldLeft--
+				newLeft--
+				lastOp = ' '
+				continue
+			}
+			// A hunk shorter than its header still applies; the line is read as a header.
+			oldLeft, newLeft = 0, 0
+		}
+
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			header, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, err
+			}
+			hunks = append(hunks, diffHunk{oldStart: header.oldStart})
+			current = &hunks[len(hunks)-1]
+			oldLeft, newLeft = header.oldCount, header.newCount
+			lastOp = 0
+		case strings.HasPrefix(line, `\`):
+			// "\ No newline at end of file" follows the last line of a complete hunk.
+			if current != nil && lastOp != '-' {
+				current.newNoEOL = true
+			}
+		case strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "diff "):
+			if len(hunks) > 0 {
+				return nil, NewError(ErrCodeInvalidArgument, "patch touches more than one file; send one diff per file", false)
+			}
+		case current != nil && line != "" && line != "-- " && strings.ContainsRune(" +-", rune(line[0])):
+			// Body lines past the header counts would otherwise be dropped silently;
+			// "-- " is the signature separator git format-patch appends.
+			return nil, NewError(ErrCodeInvalidArgument,
+				fmt.Sprintf("patch hunk %d has more lines than its header counts", len(hunks)), false)
+		}
+		// Anything else is a header before the first hunk or trailing text after the last.
+	}
+	if len(hunks) == 0 {
+		return nil, NewError(ErrCodeInvalidArgument, "patch must be a unified diff or a JSON array of {search, replace} hunks", false)
+	}
+	return hunks, nil
+}
+
+// hunkHeader is the parsed "@@ -a,b +c,d @@" line of a hunk.
+type hunkHeader struct {
+	oldStart int
+	oldCount int
+	newCount int
+}
+
+// parseHunkHeader parses "@@ -a,b +c,d @@". An omitted count defaults to 1.
+func parseHunkHeader(line string) (hunkHeader, error) {
+	invalid := NewError(ErrCodeInvalidArgument, "invalid hunk header: "+line, false)
+	fields := strings.Fields(line)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return hunkHeader{}, invalid
+	}
+	oldStart, oldCount, ok := parseHunkRange(strings.TrimPrefix(fields[1], "-"))
+	if !ok {
+		return hunkHeader{}, invalid
+	}
+	_, newCount, ok := parseHunkRange(strings.TrimPrefix(fields[2], "+"))
+	if !ok {
+		return hunkHeader{}, invalid
+	}
+	return hunkHeader{oldStart: oldStart, oldCount: oldCount, newCount: newCount}, nil
+}
+
+// parseHunkRange parses the "start,count" half of a hunk header.
+func parseHunkRange(text string) (start, count int, ok bool) {
+	startText, countText, hasCount := strings.Cut(text, ",")
+	start, err := strconv.Atoi(startText)
+	if err != nil || start < 0 {
+		return 0, 0, false
+	}
+	if !hasCount {
+		return start, 1, true
+	}
+	count, err = strconv.Atoi(countText)
+	if err != nil || count < 0 {
+		return 0, 0, false
+	}
+	return start, count, true
+}
+
+// startIndex returns the zero-based line the hunk expects to apply at. A hunk with no
+// old lines is a pure insertion after line oldStart, so it needs no adjustment.
+func (h diffHunk) startIndex() int {
+	if len(h.oldLines) == 0 {
+		return h.oldStart
+	}
+	return h.oldStart - 1
+}
+
+// locateHunk finds where want matches lines, preferring hint and never before minAt.
+func locateHunk(lines, want []string, hint, minAt int) (int, bool) {
+	if hint < minAt {
+		hint = minAt
+	}
+	if matchLinesAt(lines, want, hint) {
+		return hint, true
+	}
+	for delta := 1; hint-delta >= minAt || hint+delta <= len(lines); delta++ {
+		if at := hint - delta; at >= minAt && matchLinesAt(lines, want, at) {
+			return at, true
+		}
+		if at := hint + delta; at <= len(lines) && matchLinesAt(lines, want, at) {
+			return at, true
+		}
+	}
+	return 0, false
+}
+
+// matchLinesAt reports whether want equals lines starting at index at.
+func matchLinesAt(lines, want []string, at int) bool {
+	if at < 0 || at+len(want) > len(lines) {
+		return false
+	}
+	for i, line := range want {
+		if lines[at+i] != line {
+			return false
+		}
+	}
+	return true
+}
+
+// hunkConflictError describes a hunk that did not apply, quoting the expected
+// context next to what the file holds at the hunk's stated position.
+func hunkConflictError(index int, hunk diffHunk, lines []string, cursor int) error {
+	at := hunk.startIndex()
+	if at < cursor {
+		at = cursor
+	}
+	if at > len(lines) {
+		at = len(lines)
+	}
+	end := at + len(hunk.oldLines)
+	if end > len(lines) {
+		end = len(lines)
+	}
+	message := fmt.Sprintf("patch hunk %d does not apply at line %d\nexpected:\n%s\nfound:\n%s",
+		index, hunk.oldStart, quotePatchLines(hunk.oldLines), quotePatchLines(lines[at:end]))
+	return NewError(ErrCodeConflict, message, false)
+}
+
+// splitPatchLines splits text into lines without their terminators.
+func splitPatchLines(text string) []string {
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// quotePatchLines renders a bounded, indented excerpt for conflict messages.
+func quotePatchLines(lines []string) string {
+	if len(lines) == 0 {
+		return "  <end of file>"
+	}
+	var b strings.Builder
+	for i, line := range lines {
+		if i == patchConflictContextLines {
+			fmt.Fprintf(&b, "  ... %d more lines", len(lines)-i)
+			break
+		}
+		if len(line) > patchConflictLineBytes {
+			cut := patchConflictLineBytes
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			line = line[:cut] + "..."
+		}
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString("  " + line)
+	}
+	return b.String()
+}
@@ -0,0 +1,168 @@
+package files
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestApplyPatchUnifiedDiff verifies hunks apply at their line or a shifted offset.
+func TestApplyPatchUnifiedDiff(t *testing.T) {
+	existing := []byte("alpha\nbeta\ngamma\ndelta\nepsilon\n")
+
+	out, err := applyPatch(existing, []byte(`--- a/notes.md
++++ b/notes.md
+@@ -2,3 +2,3 @@
+ beta
+-gamma
++GAMMA
+ delta
+`))
+	require.NoError(t, err)
+	require.Equal(t, "alpha\nbeta\nGAMMA\ndelta\nepsilon\n", string(out))
+
+	// The stated line is off by two; the hunk still applies where its context matches.
+	out, err = applyPatch(existing, []byte("@@ -1,2 +1,3 @@\n delta\n+inserted\n epsilon\n"))
+	require.NoError(t, err)
+	require.Equal(t, "alpha\nbeta\ngamma\ndelta\ninserted\nepsilon\n", string(out))
+
+	out, err = applyPatch(existing, []byte("@@ -5 +5 @@\n-epsilon\n+omega\n\\ No newline at end of file\n"))
+	require.NoError(t, err)
+	require.Equal(t, "alpha\nbeta\ngamma\ndelta\nomega", string(out))
+
+	out, err = applyPatch(nil, []byte("@@ -0,0 +1,2 @@\n+first\n+second\n"))
+	require.NoError(t, err)
+	require.Equal(t, "first\nsecond\n", string(out))
+}
+
+// TestApplyPatchUnifiedDiffCRLF verifies diffs apply to CRLF files and keep their line endings.
+func TestApplyPatchUnifiedDiffCRLF(t *testing.T) {
+	existing := []byte("alpha\r\nbeta\r\ngamma\r\n")
+
+	for _, diff := range []string{
+		"@@ -2 +2 @@\n-beta\n+BETA\n",
+		"@@ -2 +2 @@\r\n-beta\r\n+BETA\r\n",
+	} {
+		out, err := applyPatch(existing, []byte(diff))
+		require.NoError(t, err)
+		require.Equal(t, "alpha\r\nBETA\r\ngamma\r\n", string(out))
+	}
+}
+
+// TestApplyPatchUnifiedDiffTrailingBlankLines verifies blank lines after the last
+// hunk are not read as context.
+func TestApplyPatchUnifiedDiffTrailingBlankLines(t *testing.T) {
+	existing := []byte("alpha\nbeta\ngamma\n")
+
+	out, err := applyPatch(existing, []byte("--- a/notes.md\n+++ b/notes.md\n@@ -2,2 +2,2 @@\n-beta\n+BETA\n gamma\n\n\n"))
+	require.NoError(t, err)
+	require.Equal(t, "alpha\nBETA\ngamma\n", string(out))
+
+	// A blank context line whose marking space was stripped still counts inside the hunk.
+	existing = []byte("alpha\n\ngamma\n")
+	out, err = applyPatch(existing, []byte("@@ -1,3 +1,3 @@\n alpha\n\n-gamma\n+GAMMA\n"))
+	require.NoError(t, err)
+	require.Equal(t, "alpha\n\nGAMMA\n", string(out))
+
+	_, err = applyPatch(existing, []byte("@@ -3 +3 @@\n-gamma\n+GAMMA\n+extra\n"))
+	require.True(t, IsCode(err, ErrCodeInvalidArgument))
+	require.Contains(t, err.Error(), "more lines than its header counts")
+}
+
+// TestApplyPatchUnifiedDiffMultipleFiles verifies a diff spanning two files is
+// rejected instead of reading the second file's header as removed lines.
+func TestApplyPatchUnifiedDiffMultipleFiles(t *testing.T) {
+	existing := []byte("alpha\nbeta\n")
+
+	_, err := applyPatch(existing, []byte(`--- a/one.md
++++ b/one.md
+@@ -1 +1 @@
+-alpha
++ALPHA
+--- a/two.md
++++ b/two.md
+@@ -1 +1 @@
+-other
++OTHER
+`))
+	require.True(t, IsCode(err, ErrCodeInvalidArgument))
+	require.Contains(t, err.Error(), "more than one file")
+}
+
+// TestApplyPatchConflict verifies mismatching context fails without partial edits.
+func TestApplyPatchConflict(t *testing.T) {
+	existing := []byte("alpha\nbeta\ngamma\n")
+
+	_, err := applyPatch(existing, []byte("@@ -1 +1 @@\n-alpha\n+ALPHA\n@@ -3 +3 @@\n-zeta\n+ZETA\n"))
+	require.True(t, IsCode(err, ErrCodeConflict))
+	require.Contains(t, err.Error(), "patch hunk 2 does not apply at line 3")
+	require.Contains(t, err.Error(), "expected:\n  zeta")
+	require.Contains(t, err.Error(), "found:\n  gamma")
+
+	_, err = applyPatch(existing, []byte("not a diff"))
+	require.True(t, IsCode(err, ErrCodeInvalidArgument))
+}
+
+// TestQuotePatchLinesKeepsRunes verifies long lines are cut on a rune boundary.
+func TestQuotePatchLinesKeepsRunes(t *testing.T) {
+	line := "a" + strings.Repeat("é", patchConflictLineBytes)
+	quoted := quotePatchLines([]string{line})
+	require.True(t, utf8.ValidString(quoted))
+	require.Equal(t, "  a"+strings.Repeat("é", (patchConflictLineBytes-2)/2)+"...", quoted)
+}
+
+// TestApplyPatchSearchReplace verifies JSON hunks require a unique match by default.
+func TestApplyPatchSearchReplace(t *testing.T) {
+	existing := []byte("a = 1\nb = 1\n")
+
+	out, err := applyPatch(existing, []byte(`[{"search": "a = 1", "replace": "a = 2"}]`))
+	require.NoError(t, err)
+	require.Equal(t, "a = 2\nb = 1\n", string(out))
+
+	_, err = applyPatch(existing, []byte(`[{"search": "= 1", "replace": "= 3"}]`))
+	require.True(t, IsCode(err, ErrCodeConflict))
+	require.Contains(t, err.Error(), "matches 2 times")
+
+	out, err = applyPatch(existing, []byte(`[{"search": "= 1", "replace": "= 3", "replace_all": true}]`))
+	require.NoError(t, err)
+	require.Equal(t, "a = 3\nb = 3\n", string(out))
+
+	_, err = applyPatch(existing, []byte(`[{"search": "c = 1", "replace": "c = 2"}]`))
+	require.True(t, IsCode(err, ErrCodeConflict))
+	require.Contains(t, err.Error(), "search text not found:\n  c = 1")
+}
+
+// TestWritePatchRecordsVersion verifies PATCH writes snapshot the prior content.
+func TestWritePatchRecordsVersion(t *testing.T) {
+	svc := newVersionsTestService(t)
+	auth := versionsTestAuth()
+	ctx := context.Background()
+
+	_, err := svc.Write(ctx, auth, "proj", "/memory.md", "# Notes\n- likes tea\n", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+
+	_, err = svc.Write(ctx, auth, "proj", "/memory.md", `[{"search": "tea", "replace": "coffee"}]`, "utf-8", 0, WriteModePatch)
+	require.NoError(t, err)
+	read, err := svc.Read(ctx, auth, "proj", "/memory.md", 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, "# Notes\n- likes coffee\n", read.Content)
+
+	versions, err := svc.ListVersions(ctx, auth, "proj", "/memory.md")
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	version, err := svc.ReadVersion(ctx, auth, "proj", "/memory.md", versions[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, "# Notes\n- likes tea\n", string(version.Content))
+
+	_, err = svc.Write(ctx, auth, "proj", "/memory.md", `[{"search": "tea", "replace": "water"}]`, "utf-8", 0, WriteModePatch)
+	require.True(t, IsCode(err, ErrCodeConflict))
+	versions, err = svc.ListVersions(ctx, auth, "proj", "/memory.md")
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+
+	_, err = svc.Write(ctx, auth, "proj", "/memory.md", "@@ -1 +1 @@\n-# Notes\n+# Memory\n", "utf-8", 3, WriteModePatch)
+	require.True(t, IsCode(err, ErrCodeInvalidOffset))
+}
@@ -0,0 +1,70 @@
+package files
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// IfMatchAny matches any existing file, so the write fails if the path is missing.
+const IfMatchAny = "*"
+
+// ifMatchKey is a private context key carrying an if_match precondition.
+type ifMatchKey struct{}
+
+// WithIfMatch returns a context that makes the next delete or rename conditional on
+// the target file's revision. ifMatch is a revision from file_stat, file_read or
+// file_list, IfMatchAny, or "0" to require that the file does not exist. Plugins only
+// forward the positional arguments of deletes and renames, so tools pass it this way;
+// writes take WriteOpts.IfMatch instead.
+func WithIfMatch(ctx context.Context, ifMatch string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, ifMatchKey{}, strings.TrimSpace(ifMatch))
+}
+
+// ifMatchFromContext returns the precondition set with WithIfMatch, if any. System
+// namespace calls never see it: the caller's revision names a user file, not the
+// plugin data a SystemFS handle touches while serving that call.
+func ifMatchFromContext(ctx context.Context) string {
+	if ctx == nil || systemOwnerFromContext(ctx) != "" {
+		return ""
+	}
+	v, _ := ctx.Value(ifMatchKey{}).(string)
+	return v
+}
+
+// FormatRevision renders a revision the way if_match expects it.
+func FormatRevision(revision int64) string {
+	return strconv.FormatInt(revision, 10)
+}
+
+// checkIfMatch compares an if_match precondition with the current file, which is
+// nil when the path holds no file. An empty ifMatch always passes.
+func checkIfMatch(ifMatch string, file *File) error {
+	ifMatch = strings.TrimSpace(ifMatch)
+	if ifMatch == "" {
+		return nil
+	}
+	if ifMatch == IfMatchAny {
+		if file == nil {
+			return NewError(ErrCodePreconditionFailed, "if_match failed: file does not exist", false)
+		}
+		return nil
+	}
+
+	expected, err := strconv.ParseInt(ifMatch, 10, 64)
+	if err != nil || expected < 0 {
+		return NewError(ErrCodeInvalidArgument, "if_match must be a file revision, \"*\" or \"0\"", false)
+	}
+	var current int64
+	if file != nil {
+		current = file.Revision
+	}
+	if current != expected {
+		return NewError(ErrCodePreconditionFailed,
+			"if_match failed: expected revision "+FormatRevision(expected)+", current revision is "+FormatRevision(current), false)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package files
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestRevision_IncrementsAcrossWriteDeleteRecreate verifies revisions never repeat for a path.
+func TestRevision_IncrementsAcrossWriteDeleteRecreate(t *testing.T) {
+	svc := newVersionsTestService(t)
+	auth := versionsTestAuth()
+	ctx := context.Background()
+
+	written, err := svc.Write(ctx, auth, "proj", "/a.txt", "one", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, written.Revision)
+
+	written, err = svc.Write(ctx, auth, "proj", "/a.txt", "+two", "utf-8", 0, WriteModeAppend)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, written.Revision)
+
+	stat, err := svc.Stat(ctx, auth, "proj", "/a.txt")
+	require.NoError(t, err)
+	require.EqualValues(t, 2, stat.Revision)
+	read, err := svc.Read(ctx, auth, "proj", "/a.txt", 0, -1)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, read.Revision)
+	list, err := svc.List(ctx, auth, "proj", "", 1, 10)
+	require.NoError(t, err)
+	require.Len(t, list.Entries, 1)
+	require.EqualValues(t, 2, list.Entries[0].Revision)
+
+	_, err = svc.Delete(ctx, auth, "proj", "/a.txt", false)
+	require.NoError(t, err)
+	written, err = svc.Write(ctx, auth, "proj", "/a.txt", "again", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+	require.EqualValues(t, 4, written.Revision)
+}
+
+// TestIfMatch_Write verifies stale, create-only and existence preconditions on writes.
+func TestIfMatch_Write(t *testing.T) {
+	svc := newVersionsTestService(t)
+	auth := versionsTestAuth()
+	ctx := context.Background()
+
+	_, err := svc.WriteWith(ctx, auth, "proj", "/a.txt", "x", "utf-8", 0, WriteModeTruncate, WriteOpts{IfMatch: IfMatchAny})
+	require.True(t, IsCode(err, ErrCodePreconditionFailed))
+
+	_, err = svc.WriteWith(ctx, auth, "proj", "/a.txt", "first", "utf-8", 0, WriteModeTruncate, WriteOpts{IfMatch: "0"})
+	require.NoError(t, err)
+	_, err = svc.WriteWith(ctx, auth, "proj", "/a.txt", "second", "utf-8", 0, WriteModeTruncate, WriteOpts{IfMatch: "0"})
+	require.True(t, IsCode(err, ErrCodePreconditionFailed))
+
+	// Two agents read revision 1; only the first conditional write succeeds.
+	_, err = svc.WriteWith(ctx, auth, "proj", "/a.txt", "agent a", "utf-8", 0, WriteModeTruncate, WriteOpts{IfMatch: "1"})
+	require.NoError(t, err)
+	_, err = svc.WriteWith(ctx, auth, "proj", "/a.txt", "agent b", "utf-8", 0, WriteModeTruncate, WriteOpts{IfMatch: "1"})
+	require.True(t, IsCode(err, ErrCodePreconditionFailed))
+	require.Contains(t, err.Error(), "current revision is 2")
+
+	read, err := svc.Read(ctx, auth, "proj", "/a.txt", 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, "agent a", read.Content)
+
+	_, err = svc.WriteWith(ctx, auth, "proj", "/a.txt", "x", "utf-8", 0, WriteModeTruncate, WriteOpts{IfMatch: "latest"})
+	require.True(t, IsCode(err, ErrCodeInvalidArgument))
+}
+
+// TestIfMatch_DeleteAndRename verifies stale preconditions leave the file untouched.
+func TestIfMatch_DeleteAndRename(t *testing.T) {
+	svc := newVersionsTestService(t)
+	auth := versionsTestAuth()
+	ctx := context.Background()
+
+	_, err := svc.Write(ctx, auth, "proj", "/dir/a.txt", "one", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+	_, err = svc.Write(ctx, auth, "proj", "/dir/a.txt", "two", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+
+	_, err = svc.Delete(WithIfMatch(ctx, "1"), auth, "proj", "/dir/a.txt", false)
+	require.True(t, IsCode(err, ErrCodePreconditionFailed))
+	_, err = svc.Delete(WithIfMatch(ctx, "2"), auth, "proj", "/dir", true)
+	require.True(t, IsCode(err, ErrCodeInvalidArgument))
+	_, err = svc.Rename(WithIfMatch(ctx, "1"), auth, "proj", "/dir/a.txt", "/dir/b.txt", false)
+	require.True(t, IsCode(err, ErrCodePreconditionFailed))
+
+	_, err = svc.Rename(WithIfMatch(ctx, "2"), auth, "proj", "/dir/a.txt", "/dir/b.txt", false)
+	require.NoError(t, err)
+	stat, err := svc.Stat(ctx, auth, "proj", "/dir/b.txt")
+	require.NoError(t, err)
+	require.EqualValues(t, 3, stat.Revision)
+
+	_, err = svc.Delete(WithIfMatch(ctx, "3"), auth, "proj", "/dir/b.txt", false)
+	require.NoError(t, err)
+	_, err = svc.Delete(WithIfMatch(ctx, "3"), auth, "proj", "/dir/b.txt", false)
+	require.True(t, IsCode(err, ErrCodePreconditionFailed))
+}
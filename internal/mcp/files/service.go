@@ -3,6 +3,7 @@ package files
 import (
 	"context"
 	"database/sql"
+	"sync"
 	"time"
 
 	errors "github.com/Laisky/errors/v2"
@@ -48,11 +49,17 @@ type Service struct {
 	embedder       Embedder
 	contextualizer Contextualizer
 	rerank         RerankClient
-	chunker        Chunker
+	chunkers       *ChunkerRegistry
 	credential     *CredentialProtector
 	credStore      CredentialStore
 	lockProvider   LockProvider
 	clock          Clock
+
+	observersMu sync.RWMutex
+	observers   []ChangeObserver
+
+	embeddingMu       sync.RWMutex
+	embeddingResolver EmbeddingResolver
 }
 
 // NewService constructs a FileIO service and runs migrations.
@@ -94,7 +101,7 @@ func NewService(db *sql.DB, settings Settings, embedder Embedder, rerank RerankC
 		embedder:       embedder,
 		contextualizer: NewOpenAIContextualizer(settings.Index.SummaryBaseURL, settings.Index.SummaryModel, settings.Index.SummaryTimeout, nil),
 		rerank:         rerank,
-		chunker:        DefaultChunker{MaxBytes: settings.Index.ChunkBytes},
+		chunkers:       NewChunkerRegistry(settings.Index.ChunkBytes),
 		credential:     credential,
 		credStore:      store,
 		lockProvider:   lockProvider,
@@ -0,0 +1,625 @@
+package files
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	errors "github.com/Laisky/errors/v2"
+)
+
+const (
+	// archiveFormatVersion is bumped whenever the manifest layout changes incompatibly.
+	archiveFormatVersion = 1
+	// archiveManifestName is the entry describing every record in an archive.
+	archiveManifestName = "manifest.json"
+	// archiveFilesDir holds the content of live files under their project paths.
+	archiveFilesDir = "files"
+	// archiveHistoryDir holds version and tombstone content keyed by SHA-256, so
+	// repeated content is stored once.
+	archiveHistoryDir = "history"
+)
+
+// archiveManifest is the manifest.json of an export archive.
+type archiveManifest struct {
+	FormatVersion int              `json:"format_version"`
+	Project       string           `json:"project"`
+	Prefix        string           `json:"prefix,omitempty"`
+	ExportedAt    time.Time        `json:"exported_at"`
+	Files         []archiveFile    `json:"files"`
+	Versions      []archiveVersion `json:"versions,omitempty"`
+	Deleted       []archiveFile    `json:"deleted,omitempty"`
+}
+
+// archiveFile describes one live file or, when DeletedAt is set, one tombstone.
+type archiveFile struct {
+	Path      string     `json:"path"`
+	SHA256    string     `json:"sha256"`
+	MimeType  string     `json:"mime_type,omitempty"`
+	Size      int64      `json:"size"`
+	Revision  int64      `json:"revision"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// archiveVersion describes one retained version of a path.
+type archiveVersion struct {
+	Path      string    `json:"path"`
+	SHA256    string    `json:"sha256"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ParseArchiveFormat maps a user-supplied format name to an ArchiveFormat. An empty
+// name selects tar.gz.
+func ParseArchiveFormat(raw string) (ArchiveFormat, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "", string(ArchiveFormatTarGz), "tgz":
+		return ArchiveFormatTarGz, nil
+	case string(ArchiveFormatZip):
+		return ArchiveFormatZip, nil
+	default:
+		return "", NewError(ErrCodeInvalidArgument, "archive format must be tar.gz or zip", false)
+	}
+}
+
+// ExportProject writes the files of project under opts.Prefix to w as an archive.
+// The archive holds a manifest.json with the metadata of every record, the live files
+// under files/ at their project paths, and the content of versions and tombstones
+// under history/. The project lock is held while writing so the archive is consistent.
+func (s *Service) ExportProject(ctx context.Context, auth AuthContext, project string, w io.Writer, opts ExportOptions) (ExportResult, error) {
+	if err := s.validateAuth(auth); err != nil {
+		return ExportResult{}, errors.WithStack(err)
+	}
+	if err := ValidateProject(project); err != nil {
+		return ExportResult{}, errors.WithStack(err)
+	}
+	if err := ValidatePath(opts.Prefix); err != nil {
+		return ExportResult{}, errors.WithStack(err)
+	}
+	format, err := ParseArchiveFormat(string(opts.Format))
+	if err != nil {
+		return ExportResult{}, errors.WithStack(err)
+	}
+
+	var result ExportResult
+	err = s.lockProvider.WithProjectLock(ctx, s.db, s.isPostgres, auth.APIKeyHash, project, s.settings.LockTimeout, func(tx *sql.Tx) error {
+		manifest, inline, err := s.exportManifestTx(ctx, tx, auth.APIKeyHash, project, opts)
+		if err != nil {
+			return err
+		}
+		if err := s.writeExportArchiveTx(ctx, tx, format, w, manifest, inline); err != nil {
+			return err
+		}
+		result = ExportResult{Files: len(manifest.Files), Versions: len(manifest.Versions), Deleted: len(manifest.Deleted)}
+		return nil
+	})
+	if err != nil {
+		return ExportResult{}, errors.WithStack(err)
+	}
+	return result, nil
+}
+
+// exportManifestTx collects the records to export. Legacy rows that still hold their
+// bytes inline have no blob, so their content is returned keyed by its hash.
+func (s *Service) exportManifestTx(ctx context.Context, tx *sql.Tx, apiKeyHash, project string, opts ExportOptions) (archiveManifest, map[string][]byte, error) {
+	owner := systemOwnerFromContext(ctx)
+	manifest := archiveManifest{
+		FormatVersion: archiveFormatVersion,
+		Project:       project,
+		Prefix:        opts.Prefix,
+		ExportedAt:    s.clock(),
+		Files:         []archiveFile{},
+	}
+	inline := make(map[string][]byte)
+
+	fileQuery := `SELECT path, content, content_hash, mime_type, size, revision, created_at, updated_at, deleted, deleted_at FROM mcp_files
+		WHERE apikey_hash = ? AND project = ? AND system_owner = ? AND (path = ? OR path LIKE ?)`
+	if !opts.IncludeDeleted {
+		fileQuery += ` AND deleted = FALSE`
+	}
+	rows, err := tx.QueryContext(ctx, rebindSQL(fileQuery+` ORDER BY path ASC, id ASC`, s.isPostgres),
+		apiKeyHash,
+		project,
+		owner,
+		opts.Prefix,
+		buildPathPrefix(opts.Prefix),
+	)
+	if err != nil {
+		return archiveManifest{}, nil, errors.Wrap(err, "query files for export")
+	}
+	for rows.Next() {
+		var (
+			file                 archiveFile
+			content              []byte
+			deleted              bool
+			createdAt, updatedAt any
+			deletedAt            any
+		)
+		if scanErr := rows.Scan(&file.Path, &content, &file.SHA256, &file.MimeType, &file.Size, &file.Revision, &createdAt, &updatedAt, &deleted, &deletedAt); scanErr != nil {
+			_ = rows.Close()
+			return archiveManifest{}, nil, errors.Wrap(scanErr, "scan file for export")
+		}
+		if file.CreatedAt, err = parseDBTime(createdAt); err != nil {
+			_ = rows.Close()
+			return archiveManifest{}, nil, errors.Wrap(err, "parse file created_at")
+		}
+		if file.UpdatedAt, err = parseDBTime(updatedAt); err != nil {
+			_ = rows.Close()
+			return archiveManifest{}, nil, errors.Wrap(err, "parse file updated_at")
+		}
+		if file.SHA256 == "" {
+			file.SHA256 = blobHash(content)
+			inline[file.SHA256] = content
+		}
+		if !deleted {
+			manifest.Files = append(manifest.Files, file)
+			continue
+		}
+		at := file.UpdatedAt
+		if deletedAt != nil {
+			if at, err = parseDBTime(deletedAt); err != nil {
+				_ = rows.Close()
+				return archiveManifest{}, nil, errors.Wrap(err, "parse file deleted_at")
+			}
+		}
+		file.DeletedAt = &at
+		manifest.Deleted = append(manifest.Deleted, file)
+	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return archiveManifest{}, nil, errors.Wrap(err, "iterate files for export")
+	}
+	if err := rows.Close(); err != nil {
+		return archiveManifest{}, nil, errors.Wrap(err, "close export files cursor")
+	}
+
+	if !opts.IncludeVersions {
+		return manifest, inline, nil
+	}
+	rows, err = tx.QueryContext(ctx,
+		rebindSQL(`SELECT path, content, content_hash, size, created_at FROM mcp_file_versions
+			WHERE apikey_hash = ? AND project = ? AND system_owner = ? AND (path = ? OR path LIKE ?)
+			ORDER BY path ASC, created_at ASC, id ASC`, s.isPostgres),
+		apiKeyHash,
+		project,
+		owner,
+		opts.Prefix,
+		buildPathPrefix(opts.Prefix),
+	)
+	if err != nil {
+		return archiveManifest{}, nil, errors.Wrap(err, "query versions for export")
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var (
+			version   archiveVersion
+			content   []byte
+			createdAt any
+		)
+		if err := rows.Scan(&version.Path, &content, &version.SHA256, &version.Size, &createdAt); err != nil {
+			return archiveManifest{}, nil, errors.Wrap(err, "scan version for export")
+		}
+		if version.CreatedAt, err = parseDBTime(createdAt); err != nil {
+			return archiveManifest{}, nil, errors.Wrap(err, "parse version created_at")
+		}
+		if version.SHA256 == "" {
+			version.SHA256 = blobHash(content)
+			inline[version.SHA256] = content
+		}
+		manifest.Versions = append(manifest.Versions, version)
+	}
+	if err := rows.Err(); err != nil {
+		return archiveManifest{}, nil, errors.Wrap(err, "iterate versions for export")
+	}
+	return manifest, inline, nil
+}
+
+// writeExportArchiveTx writes the manifest followed by the content of every record,
+// loading one blob at a time.
+func (s *Service) writeExportArchiveTx(ctx context.Context, tx *sql.Tx, format ArchiveFormat, w io.Writer, manifest archiveManifest, inline map[string][]byte) error {
+	encoded, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "encode archive manifest")
+	}
+	writer := newArchiveWriter(format, w)
+	if err := writer.WriteEntry(archiveManifestName, encoded, manifest.ExportedAt); err != nil {
+		return err
+	}
+
+	load := func(hash string) ([]byte, error) {
+		if content, ok := inline[hash]; ok {
+			return content, nil
+		}
+		return loadBlobContentTx(ctx, tx, s.isPostgres, hash)
+	}
+	for _, file := range manifest.Files {
+		content, err := load(file.SHA256)
+		if err != nil {
+			return err
+		}
+		if err := writer.WriteEntry(archiveFilesDir+file.Path, content, file.UpdatedAt); err != nil {
+			return err
+		}
+	}
+
+	written := make(map[string]struct{})
+	history := make([]archiveVersion, 0, len(manifest.Versions)+len(manifest.Deleted))
+	history = append(history, manifest.Versions...)
+	for _, file := range manifest.Deleted {
+		history = append(history, archiveVersion{SHA256: file.SHA256, CreatedAt: *file.DeletedAt})
+	}
+	for _, record := range history {
+		if _, ok := written[record.SHA256]; ok {
+			continue
+		}
+		written[record.SHA256] = struct{}{}
+		content, err := load(record.SHA256)
+		if err != nil {
+			return err
+		}
+		if err := writer.WriteEntry(archiveHistoryDir+"/"+record.SHA256, content, record.CreatedAt); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
+
+// ImportProject applies an archive written by ExportProject to project within one
+// transaction. Live files go through the regular write pipeline, so path validation,
+// size and quota checks apply and index jobs are enqueued for them. Versions and
+// tombstones are recorded as history without being indexed. Unless opts.Overwrite is
+// set, an archived path that already holds a file fails the whole import.
+func (s *Service) ImportProject(ctx context.Context, auth AuthContext, project string, r io.Reader, opts ImportOptions) (ImportResult, error) {
+	if err := s.validateAuth(auth); err != nil {
+		return ImportResult{}, errors.WithStack(err)
+	}
+	if err := ValidateProject(project); err != nil {
+		return ImportResult{}, errors.WithStack(err)
+	}
+	format, err := ParseArchiveFormat(string(opts.Format))
+	if err != nil {
+		return ImportResult{}, errors.WithStack(err)
+	}
+
+	entries, err := readArchiveEntries(format, r, s.settings.Archive.MaxImportBytes)
+	if err != nil {
+		return ImportResult{}, errors.WithStack(err)
+	}
+	manifest, err := s.parseArchiveManifest(entries)
+	if err != nil {
+		return ImportResult{}, errors.WithStack(err)
+	}
+
+	result := ImportResult{Files: []string{}}
+	err = s.lockProvider.WithProjectLock(ctx, s.db, s.isPostgres, auth.APIKeyHash, project, s.settings.LockTimeout, func(tx *sql.Tx) error {
+		if !opts.Overwrite {
+			for _, file := range manifest.Files {
+				_, findErr := s.findActiveFileTx(ctx, tx, auth.APIKeyHash, project, file.Path)
+				switch {
+				case findErr == nil:
+					return errors.WithStack(NewError(ErrCodeAlreadyExists, fmt.Sprintf("file %s already exists", file.Path), false))
+				case !errors.Is(findErr, sql.ErrNoRows):
+					return errors.Wrap(findErr, "query existing file")
+				}
+			}
+		}
+
+		// Tombstones go first so the live files imported at the same paths take
+		// higher revisions.
+		for _, file := range manifest.Deleted {
+			if err := s.importTombstoneTx(ctx, tx, auth.APIKeyHash, project, file, entries[archiveHistoryDir+"/"+file.SHA256]); err != nil {
+				return err
+			}
+		}
+		for _, file := range manifest.Files {
+			content := entries[archiveFilesDir+file.Path]
+			if _, err := s.writeWithinTx(ctx, tx, auth, project, file.Path, content, WriteModeTruncate, 0, int64(len(content)), WriteOpts{MimeType: file.MimeType}); err != nil {
+				return err
+			}
+			result.Files = append(result.Files, file.Path)
+		}
+		for _, version := range manifest.Versions {
+			if err := s.importVersionTx(ctx, tx, auth.APIKeyHash, project, version, entries[archiveHistoryDir+"/"+version.SHA256]); err != nil {
+				return err
+			}
+		}
+		result.Versions = len(manifest.Versions)
+		result.Deleted = len(manifest.Deleted)
+		return nil
+	})
+	if err != nil {
+		return ImportResult{}, errors.WithStack(err)
+	}
+
+	changes := make([]FileChange, 0, len(result.Files))
+	for _, path := range result.Files {
+		changes = append(changes, FileChange{APIKeyHash: auth.APIKeyHash, Project: project, Path: path})
+	}
+	s.notifyChanges(ctx, changes...)
+	return result, nil
+}
+
+// parseArchiveManifest decodes manifest.json and checks every record against the
+// archive before anything is written: paths must be valid, live paths unique, and
+// each content entry present with a matching checksum.
+func (s *Service) parseArchiveManifest(entries map[string][]byte) (archiveManifest, error) {
+	raw, ok := entries[archiveManifestName]
+	if !ok {
+		return archiveManifest{}, NewError(ErrCodeInvalidArgument, "archive has no manifest.json", false)
+	}
+	var manifest archiveManifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return archiveManifest{}, NewError(ErrCodeInvalidArgument, "archive manifest is not valid JSON", false)
+	}
+	if manifest.FormatVersion != archiveFormatVersion {
+		return archiveManifest{}, NewError(ErrCodeInvalidArgument, fmt.Sprintf("unsupported archive format version %d", manifest.FormatVersion), false)
+	}
+
+	checkContent := func(path, name, hash string) error {
+		if err := ValidatePath(path); err != nil {
+			return err
+		}
+		if path == "" {
+			return NewError(ErrCodeInvalidPath, "archive record has no path", false)
+		}
+		content, ok := entries[name]
+		if !ok {
+			return NewError(ErrCodeInvalidArgument, fmt.Sprintf("archive entry %s is missing", name), false)
+		}
+		if blobHash(content) != hash {
+			return NewError(ErrCodeInvalidArgument, fmt.Sprintf("archive entry %s does not match its checksum", name), false)
+		}
+		return ValidateFileSize(int64(len(content)), s.settings.MaxFileBytes)
+	}
+
+	seen := make(map[string]struct{}, len(manifest.Files))
+	for _, file := range manifest.Files {
+		if _, ok := seen[file.Path]; ok {
+			return archiveManifest{}, NewError(ErrCodeInvalidArgument, fmt.Sprintf("archive lists %s more than once", file.Path), false)
+		}
+		seen[file.Path] = struct{}{}
+		if err := checkContent(file.Path, archiveFilesDir+file.Path, file.SHA256); err != nil {
+			return archiveManifest{}, err
+		}
+	}
+	for _, file := range manifest.Deleted {
+		if file.DeletedAt == nil {
+			return archiveManifest{}, NewError(ErrCodeInvalidArgument, fmt.Sprintf("deleted record %s has no deleted_at", file.Path), false)
+		}
+		if err := checkContent(file.Path, archiveHistoryDir+"/"+file.SHA256, file.SHA256); err != nil {
+			return archiveManifest{}, err
+		}
+	}
+	for _, version := range manifest.Versions {
+		if err := checkContent(version.Path, archiveHistoryDir+"/"+version.SHA256, version.SHA256); err != nil {
+			return archiveManifest{}, err
+		}
+	}
+	sort.Slice(manifest.Files, func(i, j int) bool { return manifest.Files[i].Path < manifest.Files[j].Path })
+	return manifest, nil
+}
+
+// importTombstoneTx records an archived deleted file as a soft-deleted row, which
+// garbage collection purges once DeleteRetention has passed since its deleted_at.
+func (s *Service) importTombstoneTx(ctx context.Context, tx *sql.Tx, apiKeyHash, project string, file archiveFile, content []byte) error {
+	owner := systemOwnerFromContext(ctx)
+	now := s.clock()
+	if err := s.ensureProjectStoredQuota(ctx, tx, apiKeyHash, project, file.SHA256, int64(len(content))); err != nil {
+		return err
+	}
+	hash, err := referenceBlobTx(ctx, tx, s.isPostgres, content, now)
+	if err != nil {
+		return err
+	}
+	revision, err := s.nextPathRevisionTx(ctx, tx, apiKeyHash, project, file.Path, owner)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		rebindSQL(`INSERT INTO mcp_files (apikey_hash, project, path, content, content_hash, mime_type, revision, size, created_at, updated_at, deleted, deleted_at, system_owner, skip_rag_index)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, TRUE, ?, ?, FALSE)`, s.isPostgres),
+		apiKeyHash,
+		project,
+		file.Path,
+		[]byte{},
+		hash,
+		file.MimeType,
+		revision,
+		int64(len(content)),
+		file.CreatedAt,
+		*file.DeletedAt,
+		*file.DeletedAt,
+		owner,
+	); err != nil {
+		return errors.Wrap(err, "insert imported tombstone")
+	}
+	return nil
+}
+
+// importVersionTx records one archived version with its original timestamp. Version
+// retention applies on the next write to the path.
+func (s *Service) importVersionTx(ctx context.Context, tx *sql.Tx, apiKeyHash, project string, version archiveVersion, content []byte) error {
+	now := s.clock()
+	if err := s.ensureProjectStoredQuota(ctx, tx, apiKeyHash, project, version.SHA256, int64(len(content))); err != nil {
+		return err
+	}
+	hash, err := referenceBlobTx(ctx, tx, s.isPostgres, content, now)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		rebindSQL(`INSERT INTO mcp_file_versions (apikey_hash, project, path, content, content_hash, size, created_at, source_file_id, system_owner)
+			VALUES (?, ?, ?, ?, ?, ?, ?, NULL, ?)`, s.isPostgres),
+		apiKeyHash,
+		project,
+		version.Path,
+		[]byte{},
+		hash,
+		int64(len(content)),
+		version.CreatedAt,
+		systemOwnerFromContext(ctx),
+	); err != nil {
+		return errors.Wrap(err, "insert imported version")
+	}
+	return nil
+}
+
+// archiveWriter appends regular files to an archive.
+type archiveWriter interface {
+	WriteEntry(name string, content []byte, modTime time.Time) error
+	Close() error
+}
+
+// newArchiveWriter returns a writer producing format on w.
+func newArchiveWriter(format ArchiveFormat, w io.Writer) archiveWriter {
+	if format == ArchiveFormatZip {
+		return &zipArchiveWriter{zw: zip.NewWriter(w)}
+	}
+	gz := gzip.NewWriter(w)
+	return &tarGzArchiveWriter{gz: gz, tw: tar.NewWriter(gz)}
+}
+
+// tarGzArchiveWriter writes a gzip-compressed tar archive.
+type tarGzArchiveWriter struct {
+	gz *gzip.Writer
+	tw *tar.Writer
+}
+
+// WriteEntry appends one regular file.
+func (a *tarGzArchiveWriter) WriteEntry(name string, content []byte, modTime time.Time) error {
+	if err := a.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0o644,
+		Size:     int64(len(content)),
+		ModTime:  modTime,
+	}); err != nil {
+		return errors.Wrapf(err, "write tar header %s", name)
+	}
+	if _, err := a.tw.Write(content); err != nil {
+		return errors.Wrapf(err, "write tar entry %s", name)
+	}
+	return nil
+}
+
+// Close flushes the tar stream and the gzip footer.
+func (a *tarGzArchiveWriter) Close() error {
+	if err := a.tw.Close(); err != nil {
+		return errors.Wrap(err, "close tar writer")
+	}
+	if err := a.gz.Close(); err != nil {
+		return errors.Wrap(err, "close gzip writer")
+	}
+	return nil
+}
+
+// zipArchiveWriter writes a zip archive.
+type zipArchiveWriter struct {
+	zw *zip.Writer
+}
+
+// WriteEntry appends one deflated file.
+func (a *zipArchiveWriter) WriteEntry(name string, content []byte, modTime time.Time) error {
+	entry, err := a.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime})
+	if err != nil {
+		return errors.Wrapf(err, "write zip header %s", name)
+	}
+	if _, err := entry.Write(content); err != nil {
+		return errors.Wrapf(err, "write zip entry %s", name)
+	}
+	return nil
+}
+
+// Close writes the zip central directory.
+func (a *zipArchiveWriter) Close() error {
+	if err := a.zw.Close(); err != nil {
+		return errors.Wrap(err, "close zip writer")
+	}
+	return nil
+}
+
+// readArchiveEntries unpacks the regular files of an archive into memory. The packed
+// archive and the unpacked total are both capped at limit so a compression bomb is
+// rejected before it is expanded.
+func readArchiveEntries(format ArchiveFormat, r io.Reader, limit int64) (map[string][]byte, error) {
+	entries := make(map[string][]byte)
+	var total int64
+	add := func(name string, src io.Reader) error {
+		if _, ok := entries[name]; ok {
+			return NewError(ErrCodeInvalidArgument, fmt.Sprintf("archive contains %s more than once", name), false)
+		}
+		content, err := io.ReadAll(io.LimitReader(src, limit-total+1))
+		if err != nil {
+			return NewError(ErrCodeInvalidArgument, fmt.Sprintf("read archive entry %s: %v", name, err), false)
+		}
+		total += int64(len(content))
+		if total > limit {
+			return NewError(ErrCodePayloadTooLarge, "archive exceeds the import size limit", false)
+		}
+		entries[name] = content
+		return nil
+	}
+
+	packed, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "read archive")
+	}
+	if int64(len(packed)) > limit {
+		return nil, NewError(ErrCodePayloadTooLarge, "archive exceeds the import size limit", false)
+	}
+
+	if format == ArchiveFormatZip {
+		zr, err := zip.NewReader(bytes.NewReader(packed), int64(len(packed)))
+		if err != nil {
+			return nil, NewError(ErrCodeInvalidArgument, "archive is not a valid zip file", false)
+		}
+		for _, file := range zr.File {
+			if file.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := file.Open()
+			if err != nil {
+				return nil, NewError(ErrCodeInvalidArgument, fmt.Sprintf("open archive entry %s: %v", file.Name, err), false)
+			}
+			err = add(file.Name, rc)
+			_ = rc.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+		return entries, nil
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(packed))
+	if err != nil {
+		return nil, NewError(ErrCodeInvalidArgument, "archive is not a valid tar.gz file", false)
+	}
+	defer func() { _ = gz.Close() }()
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, NewError(ErrCodeInvalidArgument, fmt.Sprintf("read tar archive: %v", err), false)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := add(header.Name, tr); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// buildTestArchive packs a manifest and raw entries into a tar.gz archive.
+func buildTestArchive(t *testing.T, manifest archiveManifest, entries map[string]string) *bytes.Buffer {
+	t.Helper()
+	manifest.FormatVersion = archiveFormatVersion
+	encoded, err := json.Marshal(manifest)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	writer := newArchiveWriter(ArchiveFormatTarGz, &buf)
+	require.NoError(t, writer.WriteEntry(archiveManifestName, encoded, time.Time{}))
+	for name, content := range entries {
+		require.NoError(t, writer.WriteEntry(name, []byte(content), time.Time{}))
+	}
+	require.NoError(t, writer.Close())
+	return &buf
+}
+
+// countRows returns the number of rows in table owned by apiKeyHash matching extra.
+func countRows(t *testing.T, svc *Service, table, apiKeyHash, extra string) int {
+	t.Helper()
+	var count int
+	require.NoError(t, svc.db.QueryRowContext(context.Background(),
+		rebindSQL(`SELECT COUNT(1) FROM `+table+` WHERE apikey_hash = ?`+extra, svc.isPostgres),
+		apiKeyHash,
+	).Scan(&count))
+	return count
+}
+
+// TestArchive_ExportImportRoundTrip verifies both formats carry files, history and
+// tombstones to another API key.
+func TestArchive_ExportImportRoundTrip(t *testing.T) {
+	for _, format := range []ArchiveFormat{ArchiveFormatTarGz, ArchiveFormatZip} {
+		t.Run(string(format), func(t *testing.T) {
+			svc := newVersionsTestService(t)
+			auth := versionsTestAuth()
+			ctx := context.Background()
+
+			for _, step := range [][2]string{{"/a.md", "one"}, {"/a.md", "two"}, {"/notes/b.md", "beta"}, {"/gone.md", "gone"}} {
+				_, err := svc.Write(ctx, auth, "proj", step[0], step[1], "utf-8", 0, WriteModeTruncate)
+				require.NoError(t, err)
+			}
+			_, err := svc.Delete(ctx, auth, "proj", "/gone.md", false)
+			require.NoError(t, err)
+
+			var archive bytes.Buffer
+			exported, err := svc.ExportProject(ctx, auth, "proj", &archive, ExportOptions{Format: format, IncludeVersions: true, IncludeDeleted: true})
+			require.NoError(t, err)
+			require.Equal(t, ExportResult{Files: 2, Versions: 2, Deleted: 1}, exported)
+
+			other := AuthContext{APIKeyHash: "other", APIKey: "other-key"}
+			packed := archive.Bytes()
+			imported, err := svc.ImportProject(ctx, other, "proj", bytes.NewReader(packed), ImportOptions{Format: format})
+			require.NoError(t, err)
+			require.Equal(t, []string{"/a.md", "/notes/b.md"}, imported.Files)
+			require.Equal(t, 2, imported.Versions)
+			require.Equal(t, 1, imported.Deleted)
+
+			require.Equal(t, "two", readTestFile(t, svc, other, "/a.md"))
+			require.Equal(t, "beta", readTestFile(t, svc, other, "/notes/b.md"))
+			versions, err := svc.ListVersions(ctx, other, "proj", "/a.md")
+			require.NoError(t, err)
+			require.Len(t, versions, 1)
+			require.Equal(t, 1, countRows(t, svc, "mcp_files", "other", " AND deleted = TRUE"))
+			require.Equal(t, 2, countRows(t, svc, "mcp_file_index_jobs", "other", ""))
+
+			_, err = svc.ImportProject(ctx, other, "proj", bytes.NewReader(packed), ImportOptions{Format: format})
+			require.True(t, IsCode(err, ErrCodeAlreadyExists))
+			_, err = svc.ImportProject(ctx, other, "proj", bytes.NewReader(packed), ImportOptions{Format: format, Overwrite: true})
+			require.NoError(t, err)
+		})
+	}
+}
+
+// TestArchive_ExportPrefix verifies a prefix export leaves out other paths and history.
+func TestArchive_ExportPrefix(t *testing.T) {
+	svc := newVersionsTestService(t)
+	auth := versionsTestAuth()
+	ctx := context.Background()
+
+	for path, content := range map[string]string{"/notes/a.md": "alpha", "/notesx.md": "x", "/top.md": "top"} {
+		_, err := svc.Write(ctx, auth, "proj", path, content, "utf-8", 0, WriteModeTruncate)
+		require.NoError(t, err)
+	}
+
+	var archive bytes.Buffer
+	exported, err := svc.ExportProject(ctx, auth, "proj", &archive, ExportOptions{Prefix: "/notes"})
+	require.NoError(t, err)
+	require.Equal(t, ExportResult{Files: 1}, exported)
+
+	entries, err := readArchiveEntries(ArchiveFormatTarGz, &archive, 1<<20)
+	require.NoError(t, err)
+	require.Equal(t, "alpha", string(entries["files/notes/a.md"]))
+	require.Len(t, entries, 2)
+}
+
+// TestArchive_ImportRejectsBadArchives verifies nothing is written when an archive
+// fails validation or a quota check.
+func TestArchive_ImportRejectsBadArchives(t *testing.T) {
+	svc := newVersionsTestService(t)
+	auth := versionsTestAuth()
+	ctx := context.Background()
+	file := func(path, content string) archiveFile {
+		return archiveFile{Path: path, SHA256: blobHash([]byte(content)), Size: int64(len(content))}
+	}
+
+	_, err := svc.ImportProject(ctx, auth, "proj", buildTestArchive(t, archiveManifest{
+		Files: []archiveFile{file("/x.md", "x")},
+	}, map[string]string{"files/x.md": "tampered"}), ImportOptions{})
+	require.True(t, IsCode(err, ErrCodeInvalidArgument))
+
+	_, err = svc.ImportProject(ctx, auth, "proj", buildTestArchive(t, archiveManifest{
+		Files: []archiveFile{file("/a/../x.md", "x")},
+	}, map[string]string{"files/a/../x.md": "x"}), ImportOptions{})
+	require.True(t, IsCode(err, ErrCodeInvalidPath))
+
+	big := strings.Repeat("b", 6_000)
+	_, err = svc.ImportProject(ctx, auth, "proj", buildTestArchive(t, archiveManifest{
+		Files: []archiveFile{file("/one.md", big), file("/two.md", big+"!")},
+	}, map[string]string{"files/one.md": big, "files/two.md": big + "!"}), ImportOptions{})
+	require.True(t, IsCode(err, ErrCodeQuotaExceeded))
+	stat, err := svc.Stat(ctx, auth, "proj", "/one.md")
+	require.NoError(t, err)
+	require.False(t, stat.Exists)
+
+	_, err = svc.ImportProject(ctx, auth, "proj", strings.NewReader("not an archive"), ImportOptions{})
+	require.True(t, IsCode(err, ErrCodeInvalidArgument))
+
+	svc.settings.Archive.MaxImportBytes = 16
+	_, err = svc.ImportProject(ctx, auth, "proj", buildTestArchive(t, archiveManifest{}, nil), ImportOptions{})
+	require.True(t, IsCode(err, ErrCodePayloadTooLarge))
+}
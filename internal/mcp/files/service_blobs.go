@@ -0,0 +1,354 @@
+package files
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	errors "github.com/Laisky/errors/v2"
+	"github.com/Laisky/zap"
+)
+
+// blobGCBatchSize bounds how many expired file rows one purge transaction removes.
+const blobGCBatchSize = 200
+
+// blobHash returns the hex SHA-256 digest that keys content in mcp_file_blobs.
+func blobHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// referenceBlobTx takes one reference on the blob holding content, creating the blob
+// when no row references it yet, and returns its hash.
+func referenceBlobTx(ctx context.Context, tx *sql.Tx, isPostgres bool, content []byte, now time.Time) (string, error) {
+	hash := blobHash(content)
+	res, err := tx.ExecContext(ctx,
+		rebindSQL(`UPDATE mcp_file_blobs SET ref_count = ref_count + 1, updated_at = ? WHERE hash = ?`, isPostgres),
+		now,
+		hash,
+	)
+	if err != nil {
+		return "", errors.Wrap(err, "retain file blob")
+	}
+	if affected, err := res.RowsAffected(); err == nil && affected > 0 {
+		return hash, nil
+	}
+
+	if content == nil {
+		content = []byte{}
+	}
+	// The upsert covers a concurrent writer inserting the same blob between the two statements.
+	if _, err := tx.ExecContext(ctx,
+		rebindSQL(`INSERT INTO mcp_file_blobs (hash, content, size, ref_count, created_at, updated_at)
+			VALUES (?, ?, ?, 1, ?, ?)
+			ON CONFLICT (hash) DO UPDATE SET ref_count = mcp_file_blobs.ref_count + 1, updated_at = excluded.updated_at`, isPostgres),
+		hash,
+		content,
+		int64(len(content)),
+		now,
+		now,
+	); err != nil {
+		return "", errors.Wrap(err, "insert file blob")
+	}
+	return hash, nil
+}
+
+// retainBlobTx takes one more reference on a blob that is already stored.
+func retainBlobTx(ctx context.Context, tx *sql.Tx, isPostgres bool, hash string, now time.Time) error {
+	res, err := tx.ExecContext(ctx,
+		rebindSQL(`UPDATE mcp_file_blobs SET ref_count = ref_count + 1, updated_at = ? WHERE hash = ?`, isPostgres),
+		now,
+		hash,
+	)
+	if err != nil {
+		return errors.Wrap(err, "retain file blob")
+	}
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		return errors.Errorf("file blob %s not found", hash)
+	}
+	return nil
+}
+
+// loadBlobContentTx returns the content stored under hash.
+func loadBlobContentTx(ctx context.Context, tx *sql.Tx, isPostgres bool, hash string) ([]byte, error) {
+	var content []byte
+	if err := tx.QueryRowContext(ctx,
+		rebindSQL(`SELECT content FROM mcp_file_blobs WHERE hash = ?`, isPostgres),
+		hash,
+	).Scan(&content); err != nil {
+		return nil, errors.Wrapf(err, "load file blob %s", hash)
+	}
+	return content, nil
+}
+
+// releaseBlobTx drops one reference on hash and deletes the blob once nothing
+// references it. Legacy rows without a hash hold no reference.
+func releaseBlobTx(ctx context.Context, tx *sql.Tx, isPostgres bool, hash string, now time.Time) error {
+	if hash == "" {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx,
+		rebindSQL(`UPDATE mcp_file_blobs SET ref_count = ref_count - 1, updated_at = ? WHERE hash = ?`, isPostgres),
+		now,
+		hash,
+	); err != nil {
+		return errors.Wrap(err, "release file blob")
+	}
+	if _, err := tx.ExecContext(ctx,
+		rebindSQL(`DELETE FROM mcp_file_blobs WHERE hash = ? AND ref_count <= 0`, isPostgres),
+		hash,
+	); err != nil {
+		return errors.Wrap(err, "delete unreferenced file blob")
+	}
+	return nil
+}
+
+// ensureProjectStoredQuota enforces MaxProjectStoredBytes. Content already referenced
+// by the project is free to write again, so only a new hash adds to the total.
+func (s *Service) ensureProjectStoredQuota(ctx context.Context, tx *sql.Tx, apiKeyHash, project, hash string, newSize int64) error {
+	if s.settings.MaxProjectStoredBytes <= 0 {
+		return nil
+	}
+	owner := systemOwnerFromContext(ctx)
+
+	var referenced int64
+	if err := tx.QueryRowContext(ctx,
+		rebindSQL(`SELECT COUNT(1) FROM mcp_files WHERE apikey_hash = ? AND project = ? AND system_owner = ? AND content_hash = ?`, s.isPostgres),
+		apiKeyHash,
+		project,
+		owner,
+		hash,
+	).Scan(&referenced); err != nil {
+		return errors.Wrap(err, "check project blob reference")
+	}
+	if referenced == 0 {
+		if err := tx.QueryRowContext(ctx,
+			rebindSQL(`SELECT COUNT(1) FROM mcp_file_versions WHERE apikey_hash = ? AND project = ? AND system_owner = ? AND content_hash = ?`, s.isPostgres),
+			apiKeyHash,
+			project,
+			owner,
+			hash,
+		).Scan(&referenced); err != nil {
+			return errors.Wrap(err, "check project version blob reference")
+		}
+	}
+	if referenced == 0 {
+		if err := tx.QueryRowContext(ctx,
+			rebindSQL(`SELECT COUNT(1) FROM mcp_file_snapshot_entries e
+				JOIN mcp_file_snapshots sn ON sn.id = e.snapshot_id
+				WHERE sn.apikey_hash = ? AND sn.project = ? AND sn.system_owner = ? AND e.content_hash = ?`, s.isPostgres),
+			apiKeyHash,
+			project,
+			owner,
+			hash,
+		).Scan(&referenced); err != nil {
+			return errors.Wrap(err, "check project snapshot blob reference")
+		}
+	}
+	if referenced > 0 {
+		return nil
+	}
+
+	stored, err := s.projectStoredBytes(ctx, tx, apiKeyHash, project)
+	if err != nil {
+		return err
+	}
+	if stored+newSize > s.settings.MaxProjectStoredBytes {
+		return NewError(ErrCodeQuotaExceeded, "project stored bytes quota exceeded", false)
+	}
+	return nil
+}
+
+// projectStoredBytes sums the distinct blobs referenced by a project's files, versions
+// and snapshots, plus any legacy inline content that has not been moved into a blob yet.
+func (s *Service) projectStoredBytes(ctx context.Context, q sqlQuerier, apiKeyHash, project string) (int64, error) {
+	owner := systemOwnerFromContext(ctx)
+	var blobBytes, inlineBytes int64
+	if err := q.QueryRowContext(ctx,
+		rebindSQL(`SELECT COALESCE(SUM(b.size), 0) FROM mcp_file_blobs b
+			WHERE b.hash IN (
+				SELECT content_hash FROM mcp_files WHERE apikey_hash = ? AND project = ? AND system_owner = ? AND content_hash <> ''
+				UNION
+				SELECT content_hash FROM mcp_file_versions WHERE apikey_hash = ? AND project = ? AND system_owner = ? AND content_hash <> ''
+				UNION
+				SELECT e.content_hash FROM mcp_file_snapshot_entries e
+					JOIN mcp_file_snapshots sn ON sn.id = e.snapshot_id
+					WHERE sn.apikey_hash = ? AND sn.project = ? AND sn.system_owner = ?
+			)`, s.isPostgres),
+		apiKeyHash, project, owner,
+		apiKeyHash, project, owner,
+		apiKeyHash, project, owner,
+	).Scan(&blobBytes); err != nil {
+		return 0, errors.Wrap(err, "sum project blob size")
+	}
+	if err := q.QueryRowContext(ctx,
+		rebindSQL(`SELECT
+			(SELECT COALESCE(SUM(size), 0) FROM mcp_files WHERE apikey_hash = ? AND project = ? AND system_owner = ? AND content_hash = '')
+			+ (SELECT COALESCE(SUM(size), 0) FROM mcp_file_versions WHERE apikey_hash = ? AND project = ? AND system_owner = ? AND content_hash = '')`, s.isPostgres),
+		apiKeyHash, project, owner,
+		apiKeyHash, project, owner,
+	).Scan(&inlineBytes); err != nil {
+		return 0, errors.Wrap(err, "sum project inline size")
+	}
+	return blobBytes + inlineBytes, nil
+}
+
+// sqlQuerier is satisfied by both *sql.DB and *sql.Tx.
+type sqlQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Usage reports the logical and deduplicated storage used by a project.
+func (s *Service) Usage(ctx context.Context, auth AuthContext, project string) (ProjectUsage, error) {
+	if err := s.validateAuth(auth); err != nil {
+		return ProjectUsage{}, errors.WithStack(err)
+	}
+	if err := ValidateProject(project); err != nil {
+		return ProjectUsage{}, errors.WithStack(err)
+	}
+
+	owner := systemOwnerFromContext(ctx)
+	var usage ProjectUsage
+	if err := s.db.QueryRowContext(ctx,
+		rebindSQL(`SELECT COALESCE(SUM(size), 0), COUNT(1) FROM mcp_files WHERE apikey_hash = ? AND project = ? AND deleted = FALSE AND system_owner = ?`, s.isPostgres),
+		auth.APIKeyHash,
+		project,
+		owner,
+	).Scan(&usage.LogicalBytes, &usage.FileCount); err != nil {
+		return ProjectUsage{}, errors.Wrap(err, "sum project logical size")
+	}
+	if err := s.db.QueryRowContext(ctx,
+		rebindSQL(`SELECT COUNT(1) FROM mcp_file_versions WHERE apikey_hash = ? AND project = ? AND system_owner = ?`, s.isPostgres),
+		auth.APIKeyHash,
+		project,
+		owner,
+	).Scan(&usage.VersionCount); err != nil {
+		return ProjectUsage{}, errors.Wrap(err, "count project versions")
+	}
+	stored, err := s.projectStoredBytes(ctx, s.db, auth.APIKeyHash, project)
+	if err != nil {
+		return ProjectUsage{}, errors.WithStack(err)
+	}
+	usage.StoredBytes = stored
+	return usage, nil
+}
+
+// CollectGarbage purges soft-deleted files older than DeleteRetention, releasing their
+// blobs, then removes any blob that no row references.
+func (s *Service) CollectGarbage(ctx context.Context) (GarbageCollectResult, error) {
+	var result GarbageCollectResult
+	cutoff := s.clock().Add(-s.settings.DeleteRetention)
+	for {
+		purged, err := s.purgeExpiredFilesBatch(ctx, cutoff)
+		if err != nil {
+			return result, errors.WithStack(err)
+		}
+		result.PurgedFiles += purged
+		if purged < blobGCBatchSize {
+			break
+		}
+	}
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM mcp_file_blobs WHERE ref_count <= 0`)
+	if err != nil {
+		return result, errors.Wrap(err, "delete unreferenced file blobs")
+	}
+	if affected, err := res.RowsAffected(); err == nil {
+		result.DeletedBlobs = affected
+	}
+	return result, nil
+}
+
+// purgeExpiredFilesBatch hard-deletes up to blobGCBatchSize expired file rows.
+func (s *Service) purgeExpiredFilesBatch(ctx context.Context, cutoff time.Time) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "begin purge transaction")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	rows, err := tx.QueryContext(ctx,
+		rebindSQL(`SELECT id, content_hash FROM mcp_files WHERE deleted = TRUE AND deleted_at < ? ORDER BY id ASC LIMIT ?`, s.isPostgres),
+		cutoff,
+		blobGCBatchSize,
+	)
+	if err != nil {
+		return 0, errors.Wrap(err, "query expired files")
+	}
+	var (
+		ids    []any
+		hashes []string
+	)
+	for rows.Next() {
+		var (
+			id   uint64
+			hash string
+		)
+		if scanErr := rows.Scan(&id, &hash); scanErr != nil {
+			_ = rows.Close()
+			return 0, errors.Wrap(scanErr, "scan expired file")
+		}
+		ids = append(ids, id)
+		hashes = append(hashes, hash)
+	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return 0, errors.Wrap(err, "iterate expired files")
+	}
+	if err := rows.Close(); err != nil {
+		return 0, errors.Wrap(err, "close expired files cursor")
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	now := s.clock()
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	if _, err := tx.ExecContext(ctx, rebindSQL(`DELETE FROM mcp_files WHERE id IN (`+placeholders+`)`, s.isPostgres), ids...); err != nil {
+		return 0, errors.Wrap(err, "delete expired files")
+	}
+	for _, hash := range hashes {
+		if err := releaseBlobTx(ctx, tx, s.isPostgres, hash, now); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, errors.Wrap(err, "commit purge transaction")
+	}
+	return int64(len(ids)), nil
+}
+
+// StartBlobGCWorker periodically runs CollectGarbage until ctx is canceled.
+func (s *Service) StartBlobGCWorker(ctx context.Context) {
+	if s == nil || s.settings.BlobGCInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.settings.BlobGCInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				sweepCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute) //nolint:contextcheck // detached context for background sweep
+				result, err := s.CollectGarbage(sweepCtx)
+				cancel()
+				if err != nil {
+					s.logger.Warn("file blob garbage collection failed", zap.Error(err))
+					continue
+				}
+				if result.PurgedFiles > 0 || result.DeletedBlobs > 0 {
+					s.logger.Info("file blob garbage collection completed",
+						zap.Int64("purged_files", result.PurgedFiles),
+						zap.Int64("deleted_blobs", result.DeletedBlobs))
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,160 @@
+package files
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// blobRefCount returns the ref_count of the blob holding content, or -1 when absent.
+func blobRefCount(t *testing.T, svc *Service, content string) int64 {
+	t.Helper()
+	var count int64
+	err := svc.db.QueryRowContext(context.Background(),
+		rebindSQL(`SELECT ref_count FROM mcp_file_blobs WHERE hash = ?`, svc.isPostgres),
+		blobHash([]byte(content)),
+	).Scan(&count)
+	if err != nil {
+		return -1
+	}
+	return count
+}
+
+// steppingClock returns a clock that advances by step on every call.
+func steppingClock(start time.Time, step time.Duration) Clock {
+	now := start
+	return func() time.Time {
+		now = now.Add(step)
+		return now
+	}
+}
+
+// TestBlobs_IdenticalContentSharesOneBlob verifies dedup and the two usage numbers.
+func TestBlobs_IdenticalContentSharesOneBlob(t *testing.T) {
+	svc := newVersionsTestService(t)
+	auth := versionsTestAuth()
+	ctx := context.Background()
+
+	_, err := svc.Write(ctx, auth, "proj", "/a.txt", "shared", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+	_, err = svc.Write(ctx, auth, "proj", "/b.txt", "shared", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+
+	require.EqualValues(t, 2, blobRefCount(t, svc, "shared"))
+
+	read, err := svc.Read(ctx, auth, "proj", "/b.txt", 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, "shared", read.Content)
+
+	usage, err := svc.Usage(ctx, auth, "proj")
+	require.NoError(t, err)
+	require.EqualValues(t, 12, usage.LogicalBytes)
+	require.EqualValues(t, 6, usage.StoredBytes)
+	require.EqualValues(t, 2, usage.FileCount)
+}
+
+// TestBlobs_PruneReleasesReferences verifies pruned versions drop their blobs.
+func TestBlobs_PruneReleasesReferences(t *testing.T) {
+	svc := newVersionsTestService(t)
+	svc.clock = steppingClock(time.Date(2026, 2, 11, 0, 0, 0, 0, time.UTC), 8*24*time.Hour)
+	auth := versionsTestAuth()
+	ctx := context.Background()
+
+	for _, content := range []string{"v1", "v2", "v3", "v4", "v5"} {
+		_, err := svc.Write(ctx, auth, "proj", "/a.txt", content, "utf-8", 0, WriteModeTruncate)
+		require.NoError(t, err)
+	}
+
+	// v1 fell out of the top-3 retention window and was the only reference to its blob.
+	require.EqualValues(t, -1, blobRefCount(t, svc, "v1"))
+	for _, content := range []string{"v2", "v3", "v4", "v5"} {
+		require.EqualValues(t, 1, blobRefCount(t, svc, content), content)
+	}
+
+	versions, err := svc.ListVersions(ctx, auth, "proj", "/a.txt")
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+	version, err := svc.ReadVersion(ctx, auth, "proj", "/a.txt", versions[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, "v4", string(version.Content))
+}
+
+// TestBlobs_StoredQuotaCountsDistinctContent verifies dedup is free under the stored limit.
+func TestBlobs_StoredQuotaCountsDistinctContent(t *testing.T) {
+	settings := versionsTestSettings()
+	settings.MaxProjectStoredBytes = 10
+	svc := newTestService(t, settings, testEmbedder{}, &memoryCredentialStore{})
+	auth := versionsTestAuth()
+	ctx := context.Background()
+
+	_, err := svc.Write(ctx, auth, "proj", "/a.txt", "12345678", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+	_, err = svc.Write(ctx, auth, "proj", "/b.txt", "12345678", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+
+	_, err = svc.Write(ctx, auth, "proj", "/c.txt", "abcdefgh", "utf-8", 0, WriteModeTruncate)
+	require.Error(t, err)
+	var fileErr *Error
+	require.ErrorAs(t, err, &fileErr)
+	require.Equal(t, ErrCodeQuotaExceeded, fileErr.Code)
+}
+
+// TestBlobs_CollectGarbage verifies expired deletes are purged and orphans removed.
+func TestBlobs_CollectGarbage(t *testing.T) {
+	svc := newVersionsTestService(t)
+	auth := versionsTestAuth()
+	ctx := context.Background()
+
+	_, err := svc.Write(ctx, auth, "proj", "/a.txt", "gone", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+	_, err = svc.Delete(ctx, auth, "proj", "/a.txt", false)
+	require.NoError(t, err)
+	// The soft-deleted row and its delete snapshot both reference the blob.
+	require.EqualValues(t, 2, blobRefCount(t, svc, "gone"))
+
+	_, err = svc.db.ExecContext(ctx,
+		rebindSQL(`INSERT INTO mcp_file_blobs (hash, content, size, ref_count, created_at, updated_at) VALUES (?, ?, ?, 0, ?, ?)`, svc.isPostgres),
+		blobHash([]byte("orphan")), []byte("orphan"), 6, time.Now(), time.Now(),
+	)
+	require.NoError(t, err)
+
+	svc.clock = func() time.Time {
+		return time.Date(2026, 2, 11, 0, 0, 0, 0, time.UTC).Add(svc.settings.DeleteRetention + time.Hour)
+	}
+	result, err := svc.CollectGarbage(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, result.PurgedFiles)
+	require.EqualValues(t, 1, result.DeletedBlobs)
+	require.EqualValues(t, 1, blobRefCount(t, svc, "gone"))
+	require.EqualValues(t, -1, blobRefCount(t, svc, "orphan"))
+}
+
+// TestBlobs_MigrationBackfillsInlineContent verifies legacy rows move into blobs.
+func TestBlobs_MigrationBackfillsInlineContent(t *testing.T) {
+	svc := newVersionsTestService(t)
+	auth := versionsTestAuth()
+	ctx := context.Background()
+	now := time.Date(2026, 2, 11, 0, 0, 0, 0, time.UTC)
+
+	_, err := svc.db.ExecContext(ctx,
+		rebindSQL(`INSERT INTO mcp_files (apikey_hash, project, path, content, size, created_at, updated_at, deleted)
+			VALUES (?, ?, ?, ?, ?, ?, ?, FALSE)`, svc.isPostgres),
+		auth.APIKeyHash, "proj", "/legacy.txt", []byte("legacy"), 6, now, now,
+	)
+	require.NoError(t, err)
+	seedVersionsAtTimes(t, svc, auth, "proj", "/legacy.txt", 1, []time.Time{now})
+
+	require.NoError(t, RunMigrations(ctx, svc.db, svc.logger))
+
+	var inline int64
+	require.NoError(t, svc.db.QueryRowContext(ctx, `SELECT COUNT(1) FROM mcp_files WHERE content_hash = ''`).Scan(&inline))
+	require.Zero(t, inline)
+	require.EqualValues(t, 1, blobRefCount(t, svc, "legacy"))
+	require.EqualValues(t, 1, blobRefCount(t, svc, "seed"))
+
+	read, err := svc.Read(ctx, auth, "proj", "/legacy.txt", 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, "legacy", read.Content)
+}
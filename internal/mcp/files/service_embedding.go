@@ -0,0 +1,201 @@
+package files
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	errors "github.com/Laisky/errors/v2"
+)
+
+// EmbeddingProvider is an Embedder that reports the model its vectors come from and
+// whether it bills the caller's API key. Stored vectors record the model and their
+// dimensions, so vectors of different providers are never compared with each other.
+type EmbeddingProvider interface {
+	Embedder
+	Model() string
+	RequiresAPIKey() bool
+}
+
+// EmbeddingResolver returns the provider registered under name, or the default
+// provider when name is empty.
+type EmbeddingResolver func(name string) (EmbeddingProvider, error)
+
+// embeddingProviderNameMaxLen bounds a stored provider name.
+const embeddingProviderNameMaxLen = 64
+
+// modelEmbedder adapts an Embedder passed to NewService that does not report its
+// model; its vectors are recorded under Settings.EmbeddingModel.
+type modelEmbedder struct {
+	Embedder
+	model string
+}
+
+// Model returns the configured embedding model.
+func (e modelEmbedder) Model() string {
+	return e.model
+}
+
+// RequiresAPIKey assumes the embedder bills the caller, like the OpenAI endpoint.
+func (e modelEmbedder) RequiresAPIKey() bool {
+	return true
+}
+
+// SetEmbeddingResolver makes projects choose their embedding provider through
+// resolver. Without one, every project uses the embedder passed to NewService and
+// SetProjectEmbedding only accepts the default.
+func (s *Service) SetEmbeddingResolver(resolver EmbeddingResolver) {
+	if s == nil {
+		return
+	}
+
+	s.embeddingMu.Lock()
+	defer s.embeddingMu.Unlock()
+	s.embeddingResolver = resolver
+}
+
+// loadEmbeddingResolver returns the resolver set by SetEmbeddingResolver.
+func (s *Service) loadEmbeddingResolver() EmbeddingResolver {
+	s.embeddingMu.RLock()
+	defer s.embeddingMu.RUnlock()
+	return s.embeddingResolver
+}
+
+// embeddingConfigured reports whether any project can get semantic vectors.
+func (s *Service) embeddingConfigured() bool {
+	return s.embedder != nil || s.loadEmbeddingResolver() != nil
+}
+
+// resolveEmbeddingProvider returns the provider registered under name. A nil provider
+// without error means semantic indexing is not configured.
+func (s *Service) resolveEmbeddingProvider(name string) (EmbeddingProvider, error) {
+	if resolver := s.loadEmbeddingResolver(); resolver != nil {
+		provider, err := resolver(name)
+		if err != nil {
+			return nil, errors.WithStack(NewError(ErrCodeInvalidArgument, err.Error(), false))
+		}
+		return provider, nil
+	}
+	if name != "" {
+		return nil, errors.WithStack(NewError(ErrCodeInvalidArgument, "embedding providers not configured", false))
+	}
+	if s.embedder == nil {
+		return nil, nil
+	}
+	if provider, ok := s.embedder.(EmbeddingProvider); ok {
+		return provider, nil
+	}
+	return modelEmbedder{Embedder: s.embedder, model: s.settings.EmbeddingModel}, nil
+}
+
+// embeddingProviderFor returns the provider chosen for project. Cross-project
+// searches use the default provider and only match chunks embedded with it.
+func (s *Service) embeddingProviderFor(ctx context.Context, apiKeyHash, project string) (EmbeddingProvider, error) {
+	if !s.embeddingConfigured() {
+		return nil, nil
+	}
+	name := ""
+	if project != ProjectWildcard {
+		var err error
+		name, err = s.loadProjectEmbeddingName(ctx, apiKeyHash, project)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s.resolveEmbeddingProvider(name)
+}
+
+// loadProjectEmbeddingName returns the provider name stored for project, or "" when
+// the project uses the default provider.
+func (s *Service) loadProjectEmbeddingName(ctx context.Context, apiKeyHash, project string) (string, error) {
+	var name string
+	err := s.db.QueryRowContext(ctx,
+		rebindSQL(`SELECT embedding_provider FROM mcp_file_project_settings WHERE apikey_hash = ? AND project = ? AND system_owner = ?`, s.isPostgres),
+		apiKeyHash,
+		project,
+		systemOwnerFromContext(ctx),
+	).Scan(&name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "query project embedding provider")
+	}
+	return name, nil
+}
+
+// GetProjectEmbedding reports the embedding provider project is indexed with.
+func (s *Service) GetProjectEmbedding(ctx context.Context, auth AuthContext, project string) (ProjectEmbedding, error) {
+	if err := s.validateAuth(auth); err != nil {
+		return ProjectEmbedding{}, errors.WithStack(err)
+	}
+	if err := ValidateProject(project); err != nil {
+		return ProjectEmbedding{}, errors.WithStack(err)
+	}
+
+	name, err := s.loadProjectEmbeddingName(ctx, auth.APIKeyHash, project)
+	if err != nil {
+		return ProjectEmbedding{}, errors.WithStack(err)
+	}
+	return s.projectEmbedding(name)
+}
+
+// SetProjectEmbedding selects the embedding provider of project; an empty provider
+// returns the project to the default. Chunks embedded by the previous provider stop
+// matching semantic queries until the project is re-embedded.
+func (s *Service) SetProjectEmbedding(ctx context.Context, auth AuthContext, project, provider string) (ProjectEmbedding, error) {
+	if err := s.validateAuth(auth); err != nil {
+		return ProjectEmbedding{}, errors.WithStack(err)
+	}
+	if err := ValidateProject(project); err != nil {
+		return ProjectEmbedding{}, errors.WithStack(err)
+	}
+	provider = strings.TrimSpace(provider)
+	if len(provider) > embeddingProviderNameMaxLen {
+		return ProjectEmbedding{}, errors.WithStack(NewError(ErrCodeInvalidArgument, "embedding provider name exceeds max length", false))
+	}
+	result, err := s.projectEmbedding(provider)
+	if err != nil {
+		return ProjectEmbedding{}, errors.WithStack(err)
+	}
+
+	owner := systemOwnerFromContext(ctx)
+	if provider == "" {
+		if _, err := s.db.ExecContext(ctx,
+			rebindSQL(`DELETE FROM mcp_file_project_settings WHERE apikey_hash = ? AND project = ? AND system_owner = ?`, s.isPostgres),
+			auth.APIKeyHash,
+			project,
+			owner,
+		); err != nil {
+			return ProjectEmbedding{}, errors.Wrap(err, "clear project embedding provider")
+		}
+		return result, nil
+	}
+
+	if _, err := s.db.ExecContext(ctx,
+		rebindSQL(`INSERT INTO mcp_file_project_settings (apikey_hash, project, system_owner, embedding_provider, updated_at)
+			VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (apikey_hash, project, system_owner) DO UPDATE SET embedding_provider = excluded.embedding_provider, updated_at = excluded.updated_at`, s.isPostgres),
+		auth.APIKeyHash,
+		project,
+		owner,
+		provider,
+		s.clock(),
+	); err != nil {
+		return ProjectEmbedding{}, errors.Wrap(err, "store project embedding provider")
+	}
+	return result, nil
+}
+
+// projectEmbedding resolves name and reports it with the provider's model.
+func (s *Service) projectEmbedding(name string) (ProjectEmbedding, error) {
+	provider, err := s.resolveEmbeddingProvider(name)
+	if err != nil {
+		return ProjectEmbedding{}, err
+	}
+	result := ProjectEmbedding{Provider: name}
+	if provider != nil {
+		result.Model = provider.Model()
+	}
+	return result, nil
+}
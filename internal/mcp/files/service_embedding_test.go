@@ -0,0 +1,149 @@
+package files
+
+import (
+	"context"
+	"testing"
+
+	errors "github.com/Laisky/errors/v2"
+	"github.com/pgvector/pgvector-go"
+	"github.com/stretchr/testify/require"
+)
+
+// testProvider is a testEmbedder that reports a model and its API key requirement.
+type testProvider struct {
+	testEmbedder
+	model   string
+	keyless bool
+}
+
+// Model returns the configured model.
+func (p testProvider) Model() string {
+	return p.model
+}
+
+// RequiresAPIKey reports whether the provider needs the caller's API key.
+func (p testProvider) RequiresAPIKey() bool {
+	return !p.keyless
+}
+
+// embeddingRows returns the model and dimensions of every embedding in project.
+func embeddingRows(t *testing.T, svc *Service, project string) [][2]any {
+	t.Helper()
+	rows, err := svc.db.QueryContext(context.Background(),
+		`SELECT e.model, e.dimensions FROM mcp_file_chunk_embeddings e JOIN mcp_file_chunks c ON c.id = e.chunk_id WHERE c.project = ?`,
+		project,
+	)
+	require.NoError(t, err)
+	defer func() { _ = rows.Close() }()
+	var result [][2]any
+	for rows.Next() {
+		var model string
+		var dimensions int
+		require.NoError(t, rows.Scan(&model, &dimensions))
+		result = append(result, [2]any{model, dimensions})
+	}
+	require.NoError(t, rows.Err())
+	return result
+}
+
+// TestProjectEmbedding_PerProjectProviders verifies each project indexes with its own
+// provider, records model and dimensions per chunk, and only searches matching vectors.
+func TestProjectEmbedding_PerProjectProviders(t *testing.T) {
+	settings := LoadSettingsFromConfig()
+	settings.Search.Enabled = true
+	settings.Security.EncryptionKEKs = map[uint16]string{1: testEncryptionKey()}
+	settings.Index.ChunkBytes = 64
+	store := &memoryCredentialStore{}
+	svc := newTestService(t, settings, nil, store)
+	providers := map[string]EmbeddingProvider{
+		"":      testProvider{testEmbedder: testEmbedder{vector: pgvector.NewVector([]float32{1, 0})}, model: "model-a"},
+		"local": testProvider{testEmbedder: testEmbedder{vector: pgvector.NewVector([]float32{0, 1, 0})}, model: "model-b", keyless: true},
+	}
+	svc.SetEmbeddingResolver(func(name string) (EmbeddingProvider, error) {
+		provider, ok := providers[name]
+		if !ok {
+			return nil, errors.Errorf("unknown embedding provider %q", name)
+		}
+		return provider, nil
+	})
+	auth := AuthContext{APIKeyHash: "hash", APIKey: "key"}
+	ctx := context.Background()
+
+	got, err := svc.SetProjectEmbedding(ctx, auth, "local-proj", "local")
+	require.NoError(t, err)
+	require.Equal(t, ProjectEmbedding{Provider: "local", Model: "model-b"}, got)
+	got, err = svc.GetProjectEmbedding(ctx, auth, "default-proj")
+	require.NoError(t, err)
+	require.Equal(t, ProjectEmbedding{Model: "model-a"}, got)
+	_, err = svc.SetProjectEmbedding(ctx, auth, "local-proj", "missing")
+	require.True(t, IsCode(err, ErrCodeInvalidArgument))
+
+	worker := svc.NewIndexWorker()
+	_, err = svc.Write(ctx, auth, "default-proj", "/a.txt", "alpha beta", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+	require.NoError(t, worker.RunOnce(ctx))
+	require.Equal(t, [][2]any{{"model-a", 2}}, embeddingRows(t, svc, "default-proj"))
+
+	// The keyless provider embeds even when the credential envelope is gone.
+	_, err = svc.Write(ctx, auth, "local-proj", "/b.txt", "alpha gamma", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+	store.data = nil
+	require.NoError(t, worker.RunOnce(ctx))
+	require.Equal(t, [][2]any{{"model-b", 3}}, embeddingRows(t, svc, "local-proj"))
+
+	filter, err := compileSearchFilter(SearchFilter{})
+	require.NoError(t, err)
+	candidates, err := svc.fetchSemanticCandidates(ctx, auth.APIKeyHash, ProjectWildcard, "", filter, "model-a", pgvector.NewVector([]float32{1, 0}), 10)
+	require.NoError(t, err)
+	require.Len(t, candidates, 1)
+	require.Equal(t, "default-proj", candidates[0].Chunk.Project)
+
+	result, err := svc.Search(ctx, auth, "local-proj", "alpha", "", 5)
+	require.NoError(t, err)
+	require.Len(t, result.Chunks, 1)
+	require.Equal(t, "/b.txt", result.Chunks[0].FilePath)
+
+	got, err = svc.SetProjectEmbedding(ctx, auth, "local-proj", "")
+	require.NoError(t, err)
+	require.Equal(t, ProjectEmbedding{Model: "model-a"}, got)
+}
+
+// TestProjectEmbedding_WithoutResolver verifies the NewService embedder is the only
+// provider when no resolver is set, recorded under the configured model.
+func TestProjectEmbedding_WithoutResolver(t *testing.T) {
+	settings := LoadSettingsFromConfig()
+	settings.EmbeddingModel = "configured-model"
+	settings.Security.EncryptionKEKs = map[uint16]string{1: testEncryptionKey()}
+	svc := newTestService(t, settings, testEmbedder{vector: pgvector.NewVector([]float32{1, 0})}, &memoryCredentialStore{})
+	auth := AuthContext{APIKeyHash: "hash", APIKey: "key"}
+
+	_, err := svc.SetProjectEmbedding(context.Background(), auth, "proj", "local")
+	require.True(t, IsCode(err, ErrCodeInvalidArgument))
+	got, err := svc.GetProjectEmbedding(context.Background(), auth, "proj")
+	require.NoError(t, err)
+	require.Equal(t, ProjectEmbedding{Model: "configured-model"}, got)
+}
+
+// TestEmbeddingDimensionsMigrationRunsOnce verifies the dimensions backfill only runs
+// when the column is added, not on every start.
+func TestEmbeddingDimensionsMigrationRunsOnce(t *testing.T) {
+	settings := LoadSettingsFromConfig()
+	settings.Security.EncryptionKEKs = map[uint16]string{1: testEncryptionKey()}
+	svc := newTestService(t, settings, nil, &memoryCredentialStore{})
+	ctx := context.Background()
+
+	_, err := svc.db.ExecContext(ctx,
+		`INSERT INTO mcp_file_chunk_embeddings (chunk_id, embedding, model, created_at, updated_at) VALUES (1, '[1,2,3]', 'm', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`)
+	require.NoError(t, err)
+	require.NoError(t, applyEmbeddingDimensionsColumn(ctx, svc.db, false))
+
+	var dimensions int
+	require.NoError(t, svc.db.QueryRowContext(ctx, `SELECT dimensions FROM mcp_file_chunk_embeddings WHERE chunk_id = 1`).Scan(&dimensions))
+	require.Zero(t, dimensions)
+
+	_, err = svc.db.ExecContext(ctx, `ALTER TABLE mcp_file_chunk_embeddings DROP COLUMN dimensions`)
+	require.NoError(t, err)
+	require.NoError(t, applyEmbeddingDimensionsColumn(ctx, svc.db, false))
+	require.NoError(t, svc.db.QueryRowContext(ctx, `SELECT dimensions FROM mcp_file_chunk_embeddings WHERE chunk_id = 1`).Scan(&dimensions))
+	require.Equal(t, 3, dimensions)
+}
@@ -0,0 +1,307 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	errors "github.com/Laisky/errors/v2"
+)
+
+const (
+	// grepMaxPatternBytes bounds the pattern accepted by Grep.
+	grepMaxPatternBytes = 1024
+	// grepMaxLineBytes bounds each matched or context line echoed back to the caller.
+	grepMaxLineBytes = 500
+	// grepMaxSkippedPaths bounds GrepResult.SkippedPaths; FilesSkipped keeps the full count.
+	grepMaxSkippedPaths = 100
+)
+
+// GrepRequest describes one file_grep call.
+type GrepRequest struct {
+	// Pattern is a Go (RE2) regular expression, or plain text when Literal is set.
+	Pattern    string
+	Literal    bool
+	IgnoreCase bool
+	// PathGlob selects files. "*" and "?" stay within one path segment and "**" spans
+	// segments. A glob without "/" matches file names at any depth, and a plain
+	// absolute path matches that file or everything below it. Empty matches all files.
+	PathGlob string
+	// ContextLines is how many lines before and after each match are returned.
+	ContextLines int
+	// Limit caps the number of matching lines returned.
+	Limit int
+}
+
+// grepCandidate is a file selected by the glob, before its content is loaded.
+type grepCandidate struct {
+	ID   uint64
+	Path string
+	Size int64
+}
+
+// Grep scans the text files of a project line by line and returns every line that
+// matches the pattern. Binary files are skipped. A file that would push the scan past
+// Settings.Grep.MaxScanBytes is skipped and reported, and smaller files after it are
+// still scanned. Scanning stops once Limit lines have matched. Either case marks the
+// result truncated.
+func (s *Service) Grep(ctx context.Context, auth AuthContext, project string, req GrepRequest) (GrepResult, error) {
+	if err := s.validateAuth(auth); err != nil {
+		return GrepResult{}, errors.WithStack(err)
+	}
+	if err := ValidateProject(project); err != nil {
+		return GrepResult{}, errors.WithStack(err)
+	}
+	re, err := compileGrepPattern(req)
+	if err != nil {
+		return GrepResult{}, errors.WithStack(err)
+	}
+	glob, likePrefix, err := compilePathGlob(req.PathGlob)
+	if err != nil {
+		return GrepResult{}, errors.WithStack(err)
+	}
+	if req.ContextLines < 0 {
+		return GrepResult{}, errors.WithStack(NewError(ErrCodeInvalidQuery, "context_lines must be >= 0", false))
+	}
+	contextLines := min(req.ContextLines, s.settings.Grep.MaxContextLines)
+	limit := req.Limit
+	if limit <= 0 {
+		limit = s.settings.Grep.LimitDefault
+	}
+	limit = min(limit, s.settings.Grep.LimitMax)
+
+	candidates, err := s.listGrepCandidates(ctx, auth.APIKeyHash, project, likePrefix, glob)
+	if err != nil {
+		return GrepResult{}, errors.WithStack(err)
+	}
+
+	result := GrepResult{Matches: []GrepMatch{}}
+	for _, candidate := range candidates {
+		if err := ctx.Err(); err != nil {
+			return GrepResult{}, errors.WithStack(err)
+		}
+		if result.BytesScanned+candidate.Size > s.settings.Grep.MaxScanBytes {
+			result.Truncated = true
+			result.TruncatedReason = GrepTruncatedScanLimit
+			result.FilesSkipped++
+			if len(result.SkippedPaths) < grepMaxSkippedPaths {
+				result.SkippedPaths = append(result.SkippedPaths, candidate.Path)
+			}
+			continue
+		}
+
+		content, err := s.loadFileContent(ctx, candidate.ID)
+		if err != nil {
+			return GrepResult{}, errors.WithStack(err)
+		}
+		result.FilesScanned++
+		result.BytesScanned += candidate.Size
+
+		matches, more := grepContent(candidate.Path, content, re, contextLines, limit-len(result.Matches))
+		result.Matches = append(result.Matches, matches...)
+		if more {
+			result.Truncated = true
+			result.TruncatedReason = GrepTruncatedMatchLimit
+			break
+		}
+	}
+
+	return result, nil
+}
+
+// listGrepCandidates returns the active text files matching glob, ordered by path.
+func (s *Service) listGrepCandidates(ctx context.Context, apiKeyHash, project, likePrefix string, glob *regexp.Regexp) ([]grepCandidate, error) {
+	rows, err := s.db.QueryContext(ctx,
+		rebindSQL(`SELECT id, path, size, mime_type FROM mcp_files
+		WHERE apikey_hash = ? AND project = ? AND path LIKE ? ESCAPE '\' AND deleted = FALSE AND system_owner = ?
+		ORDER BY path ASC`, s.isPostgres),
+		apiKeyHash,
+		project,
+		escapeLikePattern(likePrefix)+"%",
+		systemOwnerFromContext(ctx),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "query grep candidates")
+	}
+	defer func() { _ = rows.Close() }()
+
+	var candidates []grepCandidate
+	for rows.Next() {
+		var (
+			candidate grepCandidate
+			mimeType  string
+		)
+		if err := rows.Scan(&candidate.ID, &candidate.Path, &candidate.Size, &mimeType); err != nil {
+			return nil, errors.Wrap(err, "scan grep candidate")
+		}
+		if !IsTextMimeType(mimeType) || !glob.MatchString(candidate.Path) {
+			continue
+		}
+		candidates = append(candidates, candidate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate grep candidates")
+	}
+	return candidates, nil
+}
+
+// escapeLikePattern escapes the LIKE wildcards in value so it matches literally
+// under ESCAPE '\'. Glob prefixes may contain "_", which LIKE would otherwise treat
+// as any single character.
+func escapeLikePattern(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// loadFileContent returns the content of one file row, wherever it is stored.
+func (s *Service) loadFileContent(ctx context.Context, id uint64) ([]byte, error) {
+	var content []byte
+	err := s.db.QueryRowContext(ctx,
+		rebindSQL(`SELECT COALESCE(b.content, f.content) FROM mcp_files f
+		LEFT JOIN mcp_file_blobs b ON b.hash = f.content_hash
+		WHERE f.id = ?`, s.isPostgres),
+		id,
+	).Scan(&content)
+	if err != nil {
+		return nil, errors.Wrap(err, "load file content")
+	}
+	return content, nil
+}
+
+// compileGrepPattern validates and compiles the request pattern.
+func compileGrepPattern(req GrepRequest) (*regexp.Regexp, error) {
+	if req.Pattern == "" {
+		return nil, NewError(ErrCodeInvalidQuery, "pattern is required", false)
+	}
+	if len(req.Pattern) > grepMaxPatternBytes {
+		return nil, NewError(ErrCodeInvalidQuery, "pattern exceeds max length", false)
+	}
+	expr := req.Pattern
+	if req.Literal {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if req.IgnoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, NewError(ErrCodeInvalidQuery, "invalid pattern: "+err.Error(), false)
+	}
+	return re, nil
+}
+
+// compilePathGlob turns a path glob into a matcher plus the literal path prefix that
+// narrows the SQL query.
+func compilePathGlob(glob string) (*regexp.Regexp, string, error) {
+	glob = strings.TrimSpace(glob)
+	if glob == "" || glob == "/" {
+		return regexp.MustCompile(`^/`), "", nil
+	}
+	for i := 0; i < len(glob); i++ {
+		char := glob[i]
+		if char != '*' && char != '?' && char != '/' && char != '_' && char != '-' && char != '.' && !isASCIILetterOrDigit(char) {
+			return nil, "", NewError(ErrCodeInvalidPath, "path glob contains invalid characters", false)
+		}
+	}
+
+	if !strings.Contains(glob, "/") {
+		return regexp.MustCompile(`^(?:.*/)?` + globToRegexp(glob) + `$`), "", nil
+	}
+	if !strings.HasPrefix(glob, "/") {
+		return nil, "", NewError(ErrCodeInvalidPath, "path glob must start with '/' or be a bare file name pattern", false)
+	}
+
+	glob = strings.TrimSuffix(glob, "/")
+	if !strings.ContainsAny(glob, "*?") {
+		if err := ValidatePath(glob); err != nil {
+			return nil, "", err
+		}
+		return regexp.MustCompile(`^` + regexp.QuoteMeta(glob) + `(?:/|$)`), glob, nil
+	}
+	prefix := glob[:strings.IndexAny(glob, "*?")]
+	return regexp.MustCompile(`^` + globToRegexp(glob) + `$`), prefix, nil
+}
+
+// globToRegexp translates glob wildcards into regular expression syntax.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString(`(?:.*/)?`)
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(`.*`)
+			i++
+		case glob[i] == '*':
+			b.WriteString(`[^/]*`)
+		case glob[i] == '?':
+			b.WriteString(`[^/]`)
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	return b.String()
+}
+
+// grepContent returns up to limit matching lines of content and reports whether more
+// matches were left unreported.
+func grepContent(path string, content []byte, re *regexp.Regexp, contextLines, limit int) ([]GrepMatch, bool) {
+	lines := bytes.Split(content, []byte("\n"))
+	if len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+
+	var (
+		matches []GrepMatch
+		offset  int64
+	)
+	for i, line := range lines {
+		lineStart := offset
+		offset += int64(len(line)) + 1
+
+		loc := re.FindIndex(bytes.TrimSuffix(line, []byte("\r")))
+		if loc == nil {
+			continue
+		}
+		if len(matches) == limit {
+			return matches, true
+		}
+		matches = append(matches, GrepMatch{
+			FilePath:           path,
+			LineNumber:         i + 1,
+			FileSeekStartBytes: lineStart + int64(loc[0]),
+			FileSeekEndBytes:   lineStart + int64(loc[1]),
+			Line:               grepLineText(line),
+			ContextBefore:      grepContextLines(lines, max(0, i-contextLines), i),
+			ContextAfter:       grepContextLines(lines, i+1, min(len(lines), i+1+contextLines)),
+		})
+	}
+	return matches, false
+}
+
+// grepContextLines renders lines[from:to], or nil when the range is empty.
+func grepContextLines(lines [][]byte, from, to int) []string {
+	if from >= to {
+		return nil
+	}
+	out := make([]string, 0, to-from)
+	for _, line := range lines[from:to] {
+		out = append(out, grepLineText(line))
+	}
+	return out
+}
+
+// grepLineText renders one line, trimmed to grepMaxLineBytes on a rune boundary.
+func grepLineText(line []byte) string {
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	if len(line) <= grepMaxLineBytes {
+		return string(line)
+	}
+	cut := grepMaxLineBytes
+	for cut > 0 && !utf8.RuneStart(line[cut]) {
+		cut--
+	}
+	return string(line[:cut]) + "..."
+}
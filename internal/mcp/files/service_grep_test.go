@@ -0,0 +1,133 @@
+package files
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// seedGrepFiles writes a small tree used by the grep tests.
+func seedGrepFiles(t *testing.T, svc *Service, auth AuthContext) {
+	t.Helper()
+	ctx := context.Background()
+	for path, content := range map[string]string{
+		"/notes/a.md":       "intro\nTODO: write tests\nbody\n",
+		"/notes/deep/b.md":  "todo lowercase\nTODO again\n",
+		"/notes/c.txt":      "nothing here\n",
+		"/other/d.md":       "TODO elsewhere\n",
+		"/notes/logo.png":   "TODO inside binary",
+		"/notes/regex.go":   "a.b\naxb\n",
+		"/notes/deep/e.txt": "line1\nline2\nTODO\nline4\nline5\n",
+	} {
+		_, err := svc.Write(ctx, auth, "proj", path, content, "utf-8", 0, WriteModeTruncate)
+		require.NoError(t, err)
+	}
+}
+
+// TestGrep_GlobAndContext verifies glob selection, offsets and context lines.
+func TestGrep_GlobAndContext(t *testing.T) {
+	svc := newVersionsTestService(t)
+	auth := versionsTestAuth()
+	seedGrepFiles(t, svc, auth)
+	ctx := context.Background()
+
+	result, err := svc.Grep(ctx, auth, "proj", GrepRequest{Pattern: "TODO", PathGlob: "/notes/**/*.md"})
+	require.NoError(t, err)
+	require.False(t, result.Truncated)
+	require.Len(t, result.Matches, 2)
+	require.Equal(t, "/notes/a.md", result.Matches[0].FilePath)
+	require.Equal(t, 2, result.Matches[0].LineNumber)
+	require.EqualValues(t, 6, result.Matches[0].FileSeekStartBytes)
+	require.EqualValues(t, 10, result.Matches[0].FileSeekEndBytes)
+	require.Equal(t, "TODO: write tests", result.Matches[0].Line)
+	require.Equal(t, "/notes/deep/b.md", result.Matches[1].FilePath)
+	require.Equal(t, 2, result.Matches[1].LineNumber)
+
+	// A plain directory path selects everything below it; binary files are skipped.
+	result, err = svc.Grep(ctx, auth, "proj", GrepRequest{Pattern: "todo", IgnoreCase: true, PathGlob: "/notes", ContextLines: 1})
+	require.NoError(t, err)
+	require.Len(t, result.Matches, 4)
+	require.Equal(t, 5, result.FilesScanned)
+	last := result.Matches[3]
+	require.Equal(t, "/notes/deep/e.txt", last.FilePath)
+	require.Equal(t, []string{"line2"}, last.ContextBefore)
+	require.Equal(t, []string{"line4"}, last.ContextAfter)
+
+	// A bare name pattern matches at any depth.
+	result, err = svc.Grep(ctx, auth, "proj", GrepRequest{Pattern: "TODO", PathGlob: "*.md"})
+	require.NoError(t, err)
+	require.Len(t, result.Matches, 3)
+}
+
+// TestGrep_LiteralAndRegex verifies literal patterns are not interpreted as regexes.
+func TestGrep_LiteralAndRegex(t *testing.T) {
+	svc := newVersionsTestService(t)
+	auth := versionsTestAuth()
+	seedGrepFiles(t, svc, auth)
+	ctx := context.Background()
+
+	result, err := svc.Grep(ctx, auth, "proj", GrepRequest{Pattern: "a.b", PathGlob: "/notes/regex.go"})
+	require.NoError(t, err)
+	require.Len(t, result.Matches, 2)
+
+	result, err = svc.Grep(ctx, auth, "proj", GrepRequest{Pattern: "a.b", Literal: true, PathGlob: "/notes/regex.go"})
+	require.NoError(t, err)
+	require.Len(t, result.Matches, 1)
+	require.Equal(t, 1, result.Matches[0].LineNumber)
+
+	_, err = svc.Grep(ctx, auth, "proj", GrepRequest{Pattern: "(unclosed"})
+	require.True(t, IsCode(err, ErrCodeInvalidQuery))
+	_, err = svc.Grep(ctx, auth, "proj", GrepRequest{Pattern: "x", PathGlob: "notes/*.md"})
+	require.True(t, IsCode(err, ErrCodeInvalidPath))
+}
+
+// TestGrep_Limits verifies match and scan budgets truncate the result.
+func TestGrep_Limits(t *testing.T) {
+	svc := newVersionsTestService(t)
+	auth := versionsTestAuth()
+	seedGrepFiles(t, svc, auth)
+	ctx := context.Background()
+
+	result, err := svc.Grep(ctx, auth, "proj", GrepRequest{Pattern: "TODO", Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, result.Matches, 2)
+	require.True(t, result.Truncated)
+	require.Equal(t, GrepTruncatedMatchLimit, result.TruncatedReason)
+
+	svc.settings.Grep.MaxScanBytes = 30
+	result, err = svc.Grep(ctx, auth, "proj", GrepRequest{Pattern: "TODO"})
+	require.NoError(t, err)
+	require.True(t, result.Truncated)
+	require.Equal(t, GrepTruncatedScanLimit, result.TruncatedReason)
+	require.Equal(t, 1, result.FilesScanned)
+	require.LessOrEqual(t, result.BytesScanned, int64(30))
+	require.Equal(t, 5, result.FilesSkipped)
+	require.Equal(t, []string{"/notes/c.txt", "/notes/deep/b.md", "/notes/deep/e.txt", "/notes/regex.go", "/other/d.md"}, result.SkippedPaths)
+
+	// Oversized files are skipped without ending the scan.
+	svc.settings.Grep.MaxScanBytes = 15
+	result, err = svc.Grep(ctx, auth, "proj", GrepRequest{Pattern: "TODO", PathGlob: "*.md"})
+	require.NoError(t, err)
+	require.Equal(t, GrepTruncatedScanLimit, result.TruncatedReason)
+	require.Equal(t, []string{"/notes/a.md", "/notes/deep/b.md"}, result.SkippedPaths)
+	require.Len(t, result.Matches, 1)
+	require.Equal(t, "/other/d.md", result.Matches[0].FilePath)
+}
+
+// TestGrep_UnderscoreInPathGlob verifies "_" in a glob prefix matches literally.
+func TestGrep_UnderscoreInPathGlob(t *testing.T) {
+	svc := newVersionsTestService(t)
+	auth := versionsTestAuth()
+	ctx := context.Background()
+	for _, path := range []string{"/my_dir/a.md", "/myxdir/b.md"} {
+		_, err := svc.Write(ctx, auth, "proj", path, "TODO\n", "utf-8", 0, WriteModeTruncate)
+		require.NoError(t, err)
+	}
+
+	candidates, err := svc.listGrepCandidates(ctx, auth.APIKeyHash, "proj", "/my_dir", regexp.MustCompile(`.`))
+	require.NoError(t, err)
+	require.Len(t, candidates, 1)
+	require.Equal(t, "/my_dir/a.md", candidates[0].Path)
+}
@@ -1,5 +1,7 @@
 package files
 
+import "strings"
+
 // buildPathPrefix returns the SQL LIKE prefix for descendants.
 func buildPathPrefix(path string) string {
 	if path == "" {
@@ -7,3 +9,9 @@ func buildPathPrefix(path string) string {
 	}
 	return path + "/%"
 }
+
+// pathWithinPrefix reports whether path is prefix itself or lies below it. An empty
+// prefix covers the whole project.
+func pathWithinPrefix(path, prefix string) bool {
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
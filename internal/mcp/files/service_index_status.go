@@ -0,0 +1,332 @@
+package files
+
+import (
+	"context"
+	"database/sql"
+	"time"
+	"unicode/utf8"
+
+	errors "github.com/Laisky/errors/v2"
+	"github.com/Laisky/zap"
+)
+
+const (
+	// indexStatusJobLimit bounds the retrying and failed jobs IndexStatus lists.
+	indexStatusJobLimit = 50
+	// indexJobErrorMaxBytes bounds the stored last_error of a job.
+	indexJobErrorMaxBytes = 512
+
+	// unsupersededJobClause keeps jobs of alias j whose file was not indexed by a later
+	// job. Failed jobs are retained, so without it every old failure would be reported.
+	unsupersededJobClause = ` AND NOT EXISTS (
+		SELECT 1 FROM mcp_file_index_jobs n
+		WHERE n.apikey_hash = j.apikey_hash AND n.project = j.project AND n.file_path = j.file_path
+			AND n.system_owner = j.system_owner AND n.id > j.id AND n.status = 'done'
+	)`
+	// unqueuedJobClause keeps jobs of alias j whose file has no later pending or
+	// processing job, which would already redo their work.
+	unqueuedJobClause = ` AND NOT EXISTS (
+		SELECT 1 FROM mcp_file_index_jobs q
+		WHERE q.apikey_hash = j.apikey_hash AND q.project = j.project AND q.file_path = j.file_path
+			AND q.system_owner = j.system_owner AND q.id > j.id AND q.status IN ('pending', 'processing')
+	)`
+)
+
+// indexJobErrorMessage renders err for mcp_file_index_jobs.last_error.
+func indexJobErrorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	msg := err.Error()
+	if len(msg) <= indexJobErrorMaxBytes {
+		return msg
+	}
+	msg = msg[:indexJobErrorMaxBytes]
+	for len(msg) > 0 && !utf8.ValidString(msg) {
+		msg = msg[:len(msg)-1]
+	}
+	return msg + "..."
+}
+
+// IndexStatus reports why project content is or is not searchable yet: queued and
+// failed index jobs with their errors, index row counts, the age of the oldest write
+// not yet indexed against IndexSettings.FreshnessSLO, and the index and rerank
+// settings in effect.
+func (s *Service) IndexStatus(ctx context.Context, auth AuthContext, project string) (IndexStatus, error) {
+	if err := s.validateAuth(auth); err != nil {
+		return IndexStatus{}, errors.WithStack(err)
+	}
+	if err := ValidateProject(project); err != nil {
+		return IndexStatus{}, errors.WithStack(err)
+	}
+
+	status := IndexStatus{Project: project, Jobs: []IndexJobStatus{}}
+	embedding, err := s.GetProjectEmbedding(ctx, auth, project)
+	if err != nil {
+		return IndexStatus{}, errors.WithStack(err)
+	}
+	status.Embedding = embedding
+
+	if err := s.fillIndexRowCounts(ctx, auth.APIKeyHash, project, &status); err != nil {
+		return IndexStatus{}, errors.WithStack(err)
+	}
+	if err := s.fillIndexJobCounts(ctx, auth.APIKeyHash, project, &status); err != nil {
+		return IndexStatus{}, errors.WithStack(err)
+	}
+	jobs, err := s.listProblemIndexJobs(ctx, auth.APIKeyHash, project)
+	if err != nil {
+		return IndexStatus{}, errors.WithStack(err)
+	}
+	status.Jobs = jobs
+	backlog, err := s.indexBacklog(ctx, auth.APIKeyHash, project)
+	if err != nil {
+		return IndexStatus{}, errors.WithStack(err)
+	}
+	status.Freshness = backlog
+
+	status.Contextualizer = IndexContextualizerStatus{
+		Enabled:    s.contextualizer != nil,
+		ChunkBytes: s.settings.Index.ChunkBytes,
+	}
+	if s.contextualizer != nil {
+		status.Contextualizer.Model = s.settings.Index.SummaryModel
+		status.Contextualizer.TimeoutMS = s.settings.Index.SummaryTimeout.Milliseconds()
+	}
+	status.Rerank = SearchRerankStatus{
+		Enabled:        s.rerank != nil,
+		SemanticWeight: s.settings.Search.SemanticWeight,
+		LexicalWeight:  s.settings.Search.LexicalWeight,
+	}
+	if s.rerank != nil {
+		status.Rerank.Model = s.settings.Search.RerankModel
+		status.Rerank.TimeoutMS = s.settings.Search.RerankTimeout.Milliseconds()
+	}
+	return status, nil
+}
+
+// RetryFailedIndexJobs requeues the failed jobs of project that IndexStatus reports,
+// or only job jobID when it is positive, and returns how many were requeued. Jobs
+// whose file already has a newer job queued are skipped. UPSERT jobs get a fresh
+// credential envelope from auth, since the original one has expired.
+func (s *Service) RetryFailedIndexJobs(ctx context.Context, auth AuthContext, project string, jobID int64) (int, error) {
+	if err := s.validateAuth(auth); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if err := ValidateProject(project); err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	query := `SELECT j.id, j.file_path, j.operation, j.file_updated_at, j.reindex_run_id FROM mcp_file_index_jobs j
+		WHERE j.apikey_hash = ? AND j.project = ? AND j.system_owner = ? AND j.status = ?` + unsupersededJobClause + unqueuedJobClause
+	args := []any{auth.APIKeyHash, project, "", "failed"}
+	if jobID > 0 {
+		query += " AND j.id = ?"
+		args = append(args, jobID)
+	}
+	query += " ORDER BY j.id ASC"
+
+	rows, err := s.db.QueryContext(ctx, rebindSQL(query, s.isPostgres), args...)
+	if err != nil {
+		return 0, errors.Wrap(err, "query failed index jobs")
+	}
+	var jobs []FileIndexJob
+	for rows.Next() {
+		var job FileIndexJob
+		if scanErr := rows.Scan(&job.ID, &job.FilePath, &job.Operation, &job.FileUpdatedAt, &job.ReindexRunID); scanErr != nil {
+			_ = rows.Close()
+			return 0, errors.Wrap(scanErr, "scan failed index job")
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return 0, errors.Wrap(err, "iterate failed index jobs")
+	}
+	if err := rows.Close(); err != nil {
+		return 0, errors.Wrap(err, "close failed index jobs rows")
+	}
+	if len(jobs) == 0 {
+		if jobID > 0 {
+			return 0, errors.WithStack(NewError(ErrCodeNotFound, "failed index job not found or its file is already queued", false))
+		}
+		return 0, nil
+	}
+
+	for _, job := range jobs {
+		if job.Operation != "UPSERT" || job.FileUpdatedAt == nil {
+			continue
+		}
+		if err := s.storeCredentialEnvelope(ctx, auth, project, job.FilePath, *job.FileUpdatedAt); err != nil {
+			return 0, errors.WithStack(err)
+		}
+	}
+
+	now := s.clock()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "begin retry index jobs transaction")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	runs := map[int64]struct{}{}
+	for _, job := range jobs {
+		if _, err := tx.ExecContext(ctx,
+			rebindSQL(`UPDATE mcp_file_index_jobs SET status = ?, retry_count = ?, available_at = ?, last_error = ?, updated_at = ?
+			WHERE id = ? AND status = ? AND system_owner = ?`, s.isPostgres),
+			"pending",
+			0,
+			now,
+			"",
+			now,
+			job.ID,
+			"failed",
+			"",
+		); err != nil {
+			return 0, errors.Wrap(err, "requeue index job")
+		}
+		if job.ReindexRunID > 0 {
+			runs[job.ReindexRunID] = struct{}{}
+		}
+	}
+	// Requeued run jobs reopen their completed run until they finish again. A run that
+	// failed while enqueuing stays failed, since part of its scope was never queued.
+	for runID := range runs {
+		if _, err := tx.ExecContext(ctx,
+			rebindSQL(`UPDATE mcp_file_reindex_runs SET status = ?, completed_at = NULL, updated_at = ? WHERE id = ? AND status = ?`, s.isPostgres),
+			"running",
+			now,
+			runID,
+			"completed",
+		); err != nil {
+			return 0, errors.Wrap(err, "reopen reindex run")
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, errors.Wrap(err, "commit retry index jobs transaction")
+	}
+
+	s.LoggerFromContext(ctx).Info("failed index jobs requeued",
+		zap.String("project", project),
+		zap.Int("jobs", len(jobs)),
+	)
+	return len(jobs), nil
+}
+
+// fillIndexRowCounts counts the project's files, chunks and embeddings.
+func (s *Service) fillIndexRowCounts(ctx context.Context, apiKeyHash, project string, status *IndexStatus) error {
+	owner := systemOwnerFromContext(ctx)
+	if err := s.db.QueryRowContext(ctx,
+		rebindSQL(`SELECT COUNT(1) FROM mcp_files WHERE apikey_hash = ? AND project = ? AND deleted = FALSE AND system_owner = ?`, s.isPostgres),
+		apiKeyHash, project, owner,
+	).Scan(&status.Files); err != nil {
+		return errors.Wrap(err, "count project files")
+	}
+	if err := s.db.QueryRowContext(ctx,
+		rebindSQL(`SELECT COUNT(1), COUNT(DISTINCT file_path) FROM mcp_file_chunks WHERE apikey_hash = ? AND project = ? AND system_owner = ?`, s.isPostgres),
+		apiKeyHash, project, owner,
+	).Scan(&status.Chunks, &status.IndexedFiles); err != nil {
+		return errors.Wrap(err, "count project chunks")
+	}
+
+	var total, current int64
+	if err := s.db.QueryRowContext(ctx,
+		rebindSQL(`SELECT COUNT(1), COALESCE(SUM(CASE WHEN e.model = ? THEN 1 ELSE 0 END), 0) FROM mcp_file_chunk_embeddings e
+		JOIN mcp_file_chunks c ON c.id = e.chunk_id
+		WHERE c.apikey_hash = ? AND c.project = ? AND c.system_owner = ?`, s.isPostgres),
+		status.Embedding.Model, apiKeyHash, project, owner,
+	).Scan(&total, &current); err != nil {
+		return errors.Wrap(err, "count project embeddings")
+	}
+	status.Embeddings = current
+	status.StaleEmbeddings = total - current
+	return nil
+}
+
+// fillIndexJobCounts counts the project's queued, retrying and failed jobs.
+func (s *Service) fillIndexJobCounts(ctx context.Context, apiKeyHash, project string, status *IndexStatus) error {
+	if err := s.db.QueryRowContext(ctx,
+		rebindSQL(`SELECT
+			COALESCE(SUM(CASE WHEN status = 'pending' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status = 'processing' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status = 'pending' AND retry_count > 0 THEN 1 ELSE 0 END), 0)
+		FROM mcp_file_index_jobs WHERE apikey_hash = ? AND project = ? AND system_owner = ? AND status IN (?, ?)`, s.isPostgres),
+		apiKeyHash, project, "", "pending", "processing",
+	).Scan(&status.Pending, &status.Processing, &status.Retrying); err != nil {
+		return errors.Wrap(err, "count queued index jobs")
+	}
+	if err := s.db.QueryRowContext(ctx,
+		rebindSQL(`SELECT COUNT(1) FROM mcp_file_index_jobs j
+		WHERE j.apikey_hash = ? AND j.project = ? AND j.system_owner = ? AND j.status = ?`+unsupersededJobClause, s.isPostgres),
+		apiKeyHash, project, "", "failed",
+	).Scan(&status.Failed); err != nil {
+		return errors.Wrap(err, "count failed index jobs")
+	}
+	return nil
+}
+
+// listProblemIndexJobs returns the most recent retrying and unsuperseded failed jobs.
+func (s *Service) listProblemIndexJobs(ctx context.Context, apiKeyHash, project string) ([]IndexJobStatus, error) {
+	rows, err := s.db.QueryContext(ctx,
+		rebindSQL(`SELECT j.id, j.file_path, j.operation, j.status, j.retry_count, j.last_error, j.reindex_run_id, j.created_at, j.updated_at, j.available_at
+		FROM mcp_file_index_jobs j
+		WHERE j.apikey_hash = ? AND j.project = ? AND j.system_owner = ?
+			AND ((j.status = 'pending' AND j.retry_count > 0) OR (j.status = 'failed'`+unsupersededJobClause+`))
+		ORDER BY j.id DESC LIMIT ?`, s.isPostgres),
+		apiKeyHash, project, "", indexStatusJobLimit,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "query problem index jobs")
+	}
+	defer func() { _ = rows.Close() }()
+
+	jobs := []IndexJobStatus{}
+	for rows.Next() {
+		var job IndexJobStatus
+		if scanErr := rows.Scan(&job.ID, &job.Path, &job.Operation, &job.Status, &job.RetryCount, &job.LastError,
+			&job.ReindexRunID, &job.CreatedAt, &job.UpdatedAt, &job.AvailableAt); scanErr != nil {
+			return nil, errors.Wrap(scanErr, "scan problem index job")
+		}
+		if job.Status == "pending" {
+			job.Status = "retrying"
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate problem index jobs")
+	}
+	return jobs, nil
+}
+
+// indexBacklog finds the oldest live write of project that is not indexed yet:
+// pending, processing, or failed without a later job indexing the file. Re-index run
+// jobs are left out; their progress is reported per run.
+func (s *Service) indexBacklog(ctx context.Context, apiKeyHash, project string) (IndexBacklog, error) {
+	backlog := IndexBacklog{SLOSeconds: s.settings.Index.FreshnessSLO.Seconds(), WithinSLO: true}
+	var (
+		path      string
+		createdAt time.Time
+	)
+	err := s.db.QueryRowContext(ctx,
+		rebindSQL(`SELECT j.file_path, j.created_at FROM mcp_file_index_jobs j
+		WHERE j.apikey_hash = ? AND j.project = ? AND j.system_owner = ? AND j.reindex_run_id = 0
+			AND (j.status IN ('pending', 'processing') OR (j.status = 'failed'`+unsupersededJobClause+`))
+		ORDER BY j.id ASC LIMIT 1`, s.isPostgres),
+		apiKeyHash, project, "",
+	).Scan(&path, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return backlog, nil
+	}
+	if err != nil {
+		return backlog, errors.Wrap(err, "query oldest unindexed write")
+	}
+
+	age := s.clock().Sub(createdAt)
+	if age < 0 {
+		age = 0
+	}
+	backlog.OldestUnindexedAt = &createdAt
+	backlog.OldestUnindexedPath = path
+	backlog.OldestUnindexedSeconds = age.Seconds()
+	backlog.WithinSLO = age <= s.settings.Index.FreshnessSLO
+	return backlog, nil
+}
@@ -0,0 +1,136 @@
+package files
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	errors "github.com/Laisky/errors/v2"
+	"github.com/stretchr/testify/require"
+)
+
+// TestIndexStatus_ReportsAndRetriesFailedJobs verifies a job failing to embed is
+// reported as retrying and then failed with its error and backlog age, and that a
+// retry requeues it until it indexes the file.
+func TestIndexStatus_ReportsAndRetriesFailedJobs(t *testing.T) {
+	settings := LoadSettingsFromConfig()
+	settings.Search.Enabled = true
+	settings.Security.EncryptionKEKs = map[uint16]string{1: testEncryptionKey()}
+	settings.Index.BatchSize = 10
+	settings.Index.RetryMax = 1
+	settings.Index.FreshnessSLO = 30 * time.Second
+
+	svc := newTestService(t, settings, errTestEmbedder{}, &memoryCredentialStore{})
+	now := time.Date(2026, 2, 11, 0, 0, 0, 0, time.UTC)
+	svc.clock = func() time.Time { return now }
+	auth := AuthContext{APIKeyHash: "hash", APIKey: "key"}
+	ctx := context.Background()
+	worker := svc.NewIndexWorker()
+
+	_, err := svc.Write(ctx, auth, "proj", "/a.txt", "alpha beta", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+	require.NoError(t, worker.RunOnce(ctx))
+
+	status, err := svc.IndexStatus(ctx, auth, "proj")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), status.Files)
+	require.Equal(t, int64(1), status.IndexedFiles)
+	require.Positive(t, status.Chunks)
+	require.Zero(t, status.Embeddings)
+	require.Equal(t, int64(1), status.Pending)
+	require.Equal(t, int64(1), status.Retrying)
+	require.Zero(t, status.Failed)
+	require.Len(t, status.Jobs, 1)
+	require.Equal(t, "retrying", status.Jobs[0].Status)
+	require.Contains(t, status.Jobs[0].LastError, "embedder unavailable")
+	require.Equal(t, "/a.txt", status.Freshness.OldestUnindexedPath)
+	require.True(t, status.Freshness.WithinSLO)
+	require.False(t, status.Contextualizer.Enabled)
+
+	jobID := status.Jobs[0].ID
+	_, err = svc.RetryFailedIndexJobs(ctx, auth, "proj", jobID)
+	require.True(t, IsCode(err, ErrCodeNotFound))
+
+	now = now.Add(time.Hour)
+	require.NoError(t, worker.RunOnce(ctx))
+	status, err = svc.IndexStatus(ctx, auth, "proj")
+	require.NoError(t, err)
+	require.Zero(t, status.Pending)
+	require.Equal(t, int64(1), status.Failed)
+	require.Len(t, status.Jobs, 1)
+	require.Equal(t, "failed", status.Jobs[0].Status)
+	require.Equal(t, jobID, status.Jobs[0].ID)
+	require.False(t, status.Freshness.WithinSLO)
+	require.Equal(t, float64(3600), status.Freshness.OldestUnindexedSeconds)
+
+	svc.SetEmbeddingResolver(func(string) (EmbeddingProvider, error) {
+		return newTestModelProvider("model-a"), nil
+	})
+	retried, err := svc.RetryFailedIndexJobs(ctx, auth, "proj", 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, retried)
+	status, err = svc.IndexStatus(ctx, auth, "proj")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), status.Pending)
+	require.Zero(t, status.Retrying)
+	require.Zero(t, status.Failed)
+	require.Empty(t, status.Jobs)
+
+	require.NoError(t, worker.RunOnce(ctx))
+	status, err = svc.IndexStatus(ctx, auth, "proj")
+	require.NoError(t, err)
+	require.Zero(t, status.Pending)
+	require.Positive(t, status.Embeddings)
+	require.Nil(t, status.Freshness.OldestUnindexedAt)
+	require.True(t, status.Freshness.WithinSLO)
+
+	retried, err = svc.RetryFailedIndexJobs(ctx, auth, "proj", 0)
+	require.NoError(t, err)
+	require.Zero(t, retried)
+}
+
+// TestIndexStatus_RetrySkipsQueuedFile verifies a failed job is not requeued while
+// a newer job for the same file is still pending.
+func TestIndexStatus_RetrySkipsQueuedFile(t *testing.T) {
+	settings := LoadSettingsFromConfig()
+	settings.Search.Enabled = true
+	settings.Security.EncryptionKEKs = map[uint16]string{1: testEncryptionKey()}
+	settings.Index.RetryMax = 0
+
+	svc := newTestService(t, settings, errTestEmbedder{}, &memoryCredentialStore{})
+	auth := AuthContext{APIKeyHash: "hash", APIKey: "key"}
+	ctx := context.Background()
+	worker := svc.NewIndexWorker()
+
+	_, err := svc.Write(ctx, auth, "proj", "/a.txt", "alpha beta", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+	require.NoError(t, worker.RunOnce(ctx))
+	status, err := svc.IndexStatus(ctx, auth, "proj")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), status.Failed)
+	jobID := status.Jobs[0].ID
+
+	_, err = svc.Write(ctx, auth, "proj", "/a.txt", "alpha gamma", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+	retried, err := svc.RetryFailedIndexJobs(ctx, auth, "proj", 0)
+	require.NoError(t, err)
+	require.Zero(t, retried)
+	_, err = svc.RetryFailedIndexJobs(ctx, auth, "proj", jobID)
+	require.True(t, IsCode(err, ErrCodeNotFound))
+
+	status, err = svc.IndexStatus(ctx, auth, "proj")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), status.Pending)
+}
+
+// TestIndexJobErrorMessage verifies stored job errors are bounded on a rune boundary.
+func TestIndexJobErrorMessage(t *testing.T) {
+	require.Empty(t, indexJobErrorMessage(nil))
+
+	msg := indexJobErrorMessage(errors.New(strings.Repeat("é", indexJobErrorMaxBytes)))
+	require.True(t, strings.HasSuffix(msg, "..."))
+	require.LessOrEqual(t, len(msg), indexJobErrorMaxBytes+3)
+	require.True(t, strings.HasPrefix(msg, "éé"))
+	require.NotContains(t, strings.TrimSuffix(msg, "..."), "�")
+}
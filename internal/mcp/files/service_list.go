@@ -68,6 +68,39 @@ func (s *Service) List(ctx context.Context, auth AuthContext, project, path stri
 	return ListResult{Entries: entries, HasMore: hasMore}, nil
 }
 
+// ListProjects returns the names of the caller's projects that hold at least one file.
+func (s *Service) ListProjects(ctx context.Context, auth AuthContext) ([]string, error) {
+	if err := s.validateAuth(auth); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		rebindSQL(`SELECT DISTINCT project FROM mcp_files
+		WHERE apikey_hash = ? AND deleted = FALSE AND system_owner = ?`, s.isPostgres),
+		auth.APIKeyHash,
+		systemOwnerFromContext(ctx),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "query projects")
+	}
+	defer func() { _ = rows.Close() }()
+
+	var projects []string
+	for rows.Next() {
+		var project string
+		if err := rows.Scan(&project); err != nil {
+			return nil, errors.Wrap(err, "scan project row")
+		}
+		projects = append(projects, project)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate projects")
+	}
+
+	sort.Strings(projects)
+	return projects, nil
+}
+
 // listSelfEntry returns the entry for the target path itself.
 func (s *Service) listSelfEntry(ctx context.Context, apiKeyHash, project, path string) (FileEntry, bool, error) {
 	if path == "" {
@@ -126,7 +159,7 @@ func (s *Service) listDescendants(ctx context.Context, apiKeyHash, project, path
 	owner := systemOwnerFromContext(ctx)
 	prefix := buildPathPrefix(path)
 	rows, err := s.db.QueryContext(ctx,
-		rebindSQL(`SELECT path, size, created_at, updated_at
+		rebindSQL(`SELECT path, size, revision, created_at, updated_at
 		FROM mcp_files
 		WHERE apikey_hash = ? AND project = ? AND path LIKE ? AND deleted = FALSE AND system_owner = ?`, s.isPostgres),
 		apiKeyHash,
@@ -144,10 +177,10 @@ func (s *Service) listDescendants(ctx context.Context, apiKeyHash, project, path
 
 	for rows.Next() {
 		var p string
-		var size int64
+		var size, revision int64
 		var createdAt time.Time
 		var updatedAt time.Time
-		if err := rows.Scan(&p, &size, &createdAt, &updatedAt); err != nil {
+		if err := rows.Scan(&p, &size, &revision, &createdAt, &updatedAt); err != nil {
 			return nil, errors.Wrap(err, "scan file row")
 		}
 		rel := strings.TrimPrefix(p, path)
@@ -164,6 +197,7 @@ func (s *Service) listDescendants(ctx context.Context, apiKeyHash, project, path
 				Path:      entryPath,
 				Type:      FileTypeFile,
 				Size:      size,
+				Revision:  revision,
 				CreatedAt: createdAt,
 				UpdatedAt: updatedAt,
 			}
@@ -223,6 +257,7 @@ func fileToEntry(file File) FileEntry {
 		Path:      file.Path,
 		Type:      FileTypeFile,
 		Size:      file.Size,
+		Revision:  file.Revision,
 		CreatedAt: file.CreatedAt,
 		UpdatedAt: file.UpdatedAt,
 	}
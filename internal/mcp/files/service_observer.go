@@ -0,0 +1,45 @@
+package files
+
+import "context"
+
+// FileChange describes a committed change to a user file.
+type FileChange struct {
+	APIKeyHash string
+	Project    string
+	Path       string
+	// Deleted reports whether the path no longer holds a file after the change.
+	Deleted bool
+}
+
+// ChangeObserver is notified after a file change has been committed.
+// Observers run synchronously on the writing goroutine and must not block.
+type ChangeObserver func(ctx context.Context, change FileChange)
+
+// AddChangeObserver registers an observer for committed writes, deletes, renames
+// and restores of user files. Changes in system namespaces are not reported.
+func (s *Service) AddChangeObserver(observer ChangeObserver) {
+	if s == nil || observer == nil {
+		return
+	}
+
+	s.observersMu.Lock()
+	defer s.observersMu.Unlock()
+	s.observers = append(s.observers, observer)
+}
+
+// notifyChanges reports committed changes to the registered observers.
+func (s *Service) notifyChanges(ctx context.Context, changes ...FileChange) {
+	if systemOwnerFromContext(ctx) != "" || len(changes) == 0 {
+		return
+	}
+
+	s.observersMu.RLock()
+	observers := s.observers
+	s.observersMu.RUnlock()
+
+	for _, observer := range observers {
+		for _, change := range changes {
+			observer(ctx, change)
+		}
+	}
+}
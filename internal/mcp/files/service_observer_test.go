@@ -0,0 +1,74 @@
+package files
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pgvector/pgvector-go"
+	"github.com/stretchr/testify/require"
+)
+
+// TestChangeObserverReportsCommittedChanges verifies that writes, renames and deletes
+// of user files are reported while system namespace writes are not.
+func TestChangeObserverReportsCommittedChanges(t *testing.T) {
+	settings := LoadSettingsFromConfig()
+	settings.Search.Enabled = false
+	settings.Security.EncryptionKEKs = map[uint16]string{1: testEncryptionKey()}
+
+	svc := newTestService(t, settings, testEmbedder{vector: pgvector.NewVector([]float32{1, 0})}, &memoryCredentialStore{})
+	auth := AuthContext{APIKeyHash: "hash", APIKey: "key", UserIdentity: "user:test"}
+
+	var changes []FileChange
+	svc.AddChangeObserver(func(_ context.Context, change FileChange) {
+		changes = append(changes, change)
+	})
+
+	ctx := context.Background()
+	_, err := svc.Write(ctx, auth, "proj", "/a.txt", "hello", "utf-8", 0, WriteModeAppend)
+	require.NoError(t, err)
+	_, err = svc.Rename(ctx, auth, "proj", "/a.txt", "/b.txt", false)
+	require.NoError(t, err)
+	_, err = svc.Delete(ctx, auth, "proj", "/b.txt", false)
+	require.NoError(t, err)
+
+	// A failed write is not reported.
+	_, err = svc.Write(ctx, auth, "proj", "/c.txt", "x", "utf-8", 5, WriteModeOverwrite)
+	require.Error(t, err)
+
+	sysCtx := contextWithSystemOwner(ctx, "observer")
+	_, err = svc.WriteWith(sysCtx, AuthContext{APIKeyHash: "system:observer"}, "proj", "/sys.txt", "hidden", "utf-8", 0,
+		WriteModeTruncate, WriteOpts{SystemOwner: "observer"})
+	require.NoError(t, err)
+
+	require.Equal(t, []FileChange{
+		{APIKeyHash: "hash", Project: "proj", Path: "/a.txt"},
+		{APIKeyHash: "hash", Project: "proj", Path: "/a.txt", Deleted: true},
+		{APIKeyHash: "hash", Project: "proj", Path: "/b.txt"},
+		{APIKeyHash: "hash", Project: "proj", Path: "/b.txt", Deleted: true},
+	}, changes)
+}
+
+// TestListProjects verifies that only projects holding live user files are listed.
+func TestListProjects(t *testing.T) {
+	settings := LoadSettingsFromConfig()
+	settings.Search.Enabled = false
+	settings.Security.EncryptionKEKs = map[uint16]string{1: testEncryptionKey()}
+
+	svc := newTestService(t, settings, testEmbedder{vector: pgvector.NewVector([]float32{1, 0})}, &memoryCredentialStore{})
+	auth := AuthContext{APIKeyHash: "hash", APIKey: "key", UserIdentity: "user:test"}
+	other := AuthContext{APIKeyHash: "other", APIKey: "other-key", UserIdentity: "user:other"}
+
+	ctx := context.Background()
+	for _, project := range []string{"zeta", "alpha", "gone"} {
+		_, err := svc.Write(ctx, auth, project, "/note.md", "x", "utf-8", 0, WriteModeAppend)
+		require.NoError(t, err)
+	}
+	_, err := svc.Delete(ctx, auth, "gone", "/note.md", false)
+	require.NoError(t, err)
+	_, err = svc.Write(ctx, other, "private", "/note.md", "x", "utf-8", 0, WriteModeAppend)
+	require.NoError(t, err)
+
+	projects, err := svc.ListProjects(ctx, auth)
+	require.NoError(t, err)
+	require.Equal(t, []string{"alpha", "zeta"}, projects)
+}
@@ -0,0 +1,624 @@
+package files
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	errors "github.com/Laisky/errors/v2"
+	"github.com/Laisky/zap"
+	"github.com/pgvector/pgvector-go"
+)
+
+const (
+	// reindexFreshnessSampleSize bounds the live jobs a run's freshness p95 is computed over.
+	reindexFreshnessSampleSize = 1000
+	// reindexStaleModelsMax bounds the extra query embeddings, each billed to the
+	// caller, that search spends on chunks a running re-index has not reached yet.
+	reindexStaleModelsMax = 2
+)
+
+// errReindexNeedsCredential marks a re-index job whose provider bills the caller's API
+// key while the run was started without it. Retrying cannot help, so the job fails
+// and the file keeps its previous index rows.
+var errReindexNeedsCredential = errors.New("re-index run has no credential for this embedding provider")
+
+// ModelEmbedder is implemented by providers that can embed with models other than
+// their configured one. While a re-index run is active, search embeds the query with
+// the models of not yet re-indexed chunks so they keep matching.
+type ModelEmbedder interface {
+	EmbedTextsWithModel(ctx context.Context, apiKey, model string, inputs []string) ([]pgvector.Vector, error)
+}
+
+// StartReindex enqueues an UPSERT job for every indexable file in scope, linked to a
+// new re-index run. Jobs go through the regular outbox behind live writes, and each
+// file keeps its previous index rows until its job replaces them, so search is served
+// from the old index until the run completes.
+//
+// A run without the raw API key cannot embed with providers billed to it. A run for
+// one key is refused when a project needs it; an all-keys run skips such projects
+// and lists them in ReindexRun.Skipped.
+//
+// Jobs are committed in pages of Index.ReindexBatchSize while the run is "enqueuing",
+// so workers cannot complete it before the last page lands. When a page fails the run
+// is marked "failed" with the jobs committed so far, which still run.
+func (s *Service) StartReindex(ctx context.Context, scope ReindexScope) (ReindexRun, error) {
+	scope.APIKeyHash = strings.TrimSpace(scope.APIKeyHash)
+	scope.Project = strings.TrimSpace(scope.Project)
+	scope.APIKey = strings.TrimSpace(scope.APIKey)
+	if scope.Project != "" {
+		if scope.APIKeyHash == "" {
+			return ReindexRun{}, errors.WithStack(NewError(ErrCodeInvalidArgument, "project re-index requires an api key", false))
+		}
+		if err := ValidateProject(scope.Project); err != nil {
+			return ReindexRun{}, errors.WithStack(err)
+		}
+	}
+	if scope.APIKey != "" && scope.APIKeyHash == "" {
+		return ReindexRun{}, errors.WithStack(NewError(ErrCodeInvalidArgument, "api key hash is required with an api key", false))
+	}
+
+	running, err := s.countRunningReindexRuns(ctx, scope)
+	if err != nil {
+		return ReindexRun{}, errors.WithStack(err)
+	}
+	if running > 0 {
+		return ReindexRun{}, errors.WithStack(NewError(ErrCodeAlreadyExists, "a re-index run for this scope is already running", false))
+	}
+
+	targets, err := s.loadReindexTargets(ctx, scope)
+	if err != nil {
+		return ReindexRun{}, errors.WithStack(err)
+	}
+	targets, skipped, err := s.filterReindexCredential(ctx, scope, targets)
+	if err != nil {
+		return ReindexRun{}, errors.WithStack(err)
+	}
+	for _, project := range skipped {
+		s.LoggerFromContext(ctx).Warn("file re-index skipped project billed to its api key",
+			zap.String("apikey_hash", project.APIKeyHash),
+			zap.String("project", project.Project),
+			zap.String("model", project.Model),
+			zap.Int64("files", project.Files),
+		)
+	}
+
+	now := s.clock()
+	if len(targets) == 0 {
+		runID, err := s.insertReindexRun(ctx, scope, "completed", 0, skipped, now, &now)
+		if err != nil {
+			return ReindexRun{}, errors.WithStack(err)
+		}
+		return s.ReindexRunStatus(ctx, runID)
+	}
+
+	runID, err := s.insertReindexRun(ctx, scope, "enqueuing", int64(len(targets)), skipped, now, nil)
+	if err != nil {
+		return ReindexRun{}, errors.WithStack(err)
+	}
+	batchSize := s.settings.Index.ReindexBatchSize
+	for start := 0; start < len(targets); start += batchSize {
+		end := min(start+batchSize, len(targets))
+		if err := s.enqueueReindexBatch(ctx, scope, runID, targets[start:end], now); err != nil {
+			s.failReindexRun(ctx, runID, int64(start))
+			return ReindexRun{}, errors.WithStack(err)
+		}
+	}
+
+	if _, err := s.db.ExecContext(ctx,
+		rebindSQL(`UPDATE mcp_file_reindex_runs SET status = ?, updated_at = ? WHERE id = ? AND status = ?`, s.isPostgres),
+		"running",
+		s.clock(),
+		runID,
+		"enqueuing",
+	); err != nil {
+		return ReindexRun{}, errors.Wrap(err, "mark reindex run running")
+	}
+	// Workers may already have finished every job of the early pages.
+	if err := s.refreshReindexRun(ctx, runID); err != nil {
+		return ReindexRun{}, errors.WithStack(err)
+	}
+
+	s.LoggerFromContext(ctx).Info("file re-index run started",
+		zap.Int64("run_id", runID),
+		zap.String("project", scope.Project),
+		zap.Bool("all_api_keys", scope.APIKeyHash == ""),
+		zap.Int("jobs", len(targets)),
+	)
+	return s.ReindexRunStatus(ctx, runID)
+}
+
+// filterReindexCredential drops the targets a run without the raw API key cannot
+// embed: their project uses a provider billed to that key, so every job would fail.
+// A run scoped to one key is refused instead, since its caller can supply the key;
+// an all-keys run returns the dropped projects so they can be reported.
+func (s *Service) filterReindexCredential(ctx context.Context, scope ReindexScope, targets []reindexTarget) ([]reindexTarget, []ReindexSkippedProject, error) {
+	if scope.APIKey != "" {
+		return targets, nil, nil
+	}
+
+	var (
+		kept    = targets[:0]
+		skipped []ReindexSkippedProject
+		// skipIndex maps a project to its position in skipped, or -1 when it is kept.
+		skipIndex = make(map[[2]string]int)
+	)
+	for _, target := range targets {
+		key := [2]string{target.APIKeyHash, target.Project}
+		at, checked := skipIndex[key]
+		if !checked {
+			provider, err := s.embeddingProviderFor(ctx, target.APIKeyHash, target.Project)
+			if err != nil {
+				return nil, nil, errors.Wrap(err, "resolve embedding provider")
+			}
+			at = -1
+			if provider != nil && provider.RequiresAPIKey() {
+				if scope.APIKeyHash != "" {
+					return nil, nil, errors.WithStack(NewError(ErrCodeInvalidArgument,
+						fmt.Sprintf("project %s embeds with model %s billed to the caller's api key; start the run with the raw api key", target.Project, provider.Model()),
+						false))
+				}
+				at = len(skipped)
+				skipped = append(skipped, ReindexSkippedProject{APIKeyHash: target.APIKeyHash, Project: target.Project, Model: provider.Model()})
+			}
+			skipIndex[key] = at
+		}
+		if at < 0 {
+			kept = append(kept, target)
+			continue
+		}
+		skipped[at].Files++
+	}
+	return kept, skipped, nil
+}
+
+// enqueueReindexBatch writes the credential envelopes of targets, then commits their
+// jobs in one transaction. The envelopes go first because a job may be claimed as
+// soon as it is committed.
+func (s *Service) enqueueReindexBatch(ctx context.Context, scope ReindexScope, runID int64, targets []reindexTarget, now time.Time) error {
+	if scope.APIKey != "" {
+		auth := AuthContext{APIKey: scope.APIKey, APIKeyHash: scope.APIKeyHash}
+		for _, target := range targets {
+			if err := s.storeCredentialEnvelopeTTL(ctx, auth, target.Project, target.Path, target.UpdatedAt, s.settings.Index.ReindexCredentialTTL); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "begin reindex batch transaction")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, target := range targets {
+		updatedAt := target.UpdatedAt
+		if err := s.insertIndexJobTx(ctx, tx, FileIndexJob{
+			APIKeyHash:    target.APIKeyHash,
+			Project:       target.Project,
+			FilePath:      target.Path,
+			Operation:     "UPSERT",
+			FileUpdatedAt: &updatedAt,
+			ReindexRunID:  runID,
+			Status:        "pending",
+			RetryCount:    0,
+			AvailableAt:   now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}); err != nil {
+			return errors.Wrap(err, "enqueue reindex job")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "commit reindex batch transaction")
+	}
+	return nil
+}
+
+// failReindexRun marks run id failed after enqueued of its jobs were committed. It
+// runs detached from ctx, which has usually expired by then, and only logs errors.
+func (s *Service) failReindexRun(ctx context.Context, id, enqueued int64) {
+	updateCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second) //nolint:contextcheck // the request context may have expired
+	defer cancel()
+
+	now := s.clock()
+	if _, err := s.db.ExecContext(updateCtx,
+		rebindSQL(`UPDATE mcp_file_reindex_runs SET status = ?, total_jobs = ?, completed_at = ?, updated_at = ? WHERE id = ?`, s.isPostgres),
+		"failed",
+		enqueued,
+		now,
+		now,
+		id,
+	); err != nil {
+		s.LoggerFromContext(ctx).Warn("mark reindex run failed", zap.Int64("run_id", id), zap.Error(err))
+	}
+}
+
+// ReindexRunStatus reports the progress of run id.
+func (s *Service) ReindexRunStatus(ctx context.Context, id int64) (ReindexRun, error) {
+	var (
+		run     ReindexRun
+		skipped string
+	)
+	err := s.db.QueryRowContext(ctx,
+		rebindSQL(`SELECT id, apikey_hash, project, status, total_jobs, skipped, created_at, completed_at FROM mcp_file_reindex_runs WHERE id = ?`, s.isPostgres),
+		id,
+	).Scan(&run.ID, &run.APIKeyHash, &run.Project, &run.Status, &run.TotalJobs, &skipped, &run.CreatedAt, &run.CompletedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ReindexRun{}, errors.WithStack(NewError(ErrCodeNotFound, "re-index run not found", false))
+	}
+	if err != nil {
+		return ReindexRun{}, errors.Wrap(err, "query reindex run")
+	}
+	if run.Skipped, err = decodeReindexSkipped(skipped); err != nil {
+		return ReindexRun{}, errors.WithStack(err)
+	}
+	if err := s.fillReindexProgress(ctx, &run); err != nil {
+		return ReindexRun{}, errors.WithStack(err)
+	}
+	return run, nil
+}
+
+// ListReindexRuns returns up to limit re-index runs, newest first.
+func (s *Service) ListReindexRuns(ctx context.Context, limit int) ([]ReindexRun, error) {
+	if limit <= 0 {
+		limit = s.settings.ListLimitDefault
+	}
+	if limit > s.settings.ListLimitMax {
+		limit = s.settings.ListLimitMax
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		rebindSQL(`SELECT id, apikey_hash, project, status, total_jobs, skipped, created_at, completed_at FROM mcp_file_reindex_runs ORDER BY id DESC LIMIT ?`, s.isPostgres),
+		limit,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "query reindex runs")
+	}
+	var runs []ReindexRun
+	for rows.Next() {
+		var (
+			run     ReindexRun
+			skipped string
+		)
+		if scanErr := rows.Scan(&run.ID, &run.APIKeyHash, &run.Project, &run.Status, &run.TotalJobs, &skipped, &run.CreatedAt, &run.CompletedAt); scanErr != nil {
+			_ = rows.Close()
+			return nil, errors.Wrap(scanErr, "scan reindex run")
+		}
+		var decodeErr error
+		if run.Skipped, decodeErr = decodeReindexSkipped(skipped); decodeErr != nil {
+			_ = rows.Close()
+			return nil, errors.WithStack(decodeErr)
+		}
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return nil, errors.Wrap(err, "iterate reindex runs")
+	}
+	if err := rows.Close(); err != nil {
+		return nil, errors.Wrap(err, "close reindex runs rows")
+	}
+
+	for i := range runs {
+		if err := s.fillReindexProgress(ctx, &runs[i]); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return runs, nil
+}
+
+// decodeReindexSkipped parses the skipped column written by insertReindexRun.
+func decodeReindexSkipped(raw string) ([]ReindexSkippedProject, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var skipped []ReindexSkippedProject
+	if err := json.Unmarshal([]byte(raw), &skipped); err != nil {
+		return nil, errors.Wrap(err, "decode skipped projects")
+	}
+	return skipped, nil
+}
+
+// reindexTarget is one file a re-index run enqueues.
+type reindexTarget struct {
+	APIKeyHash string
+	Project    string
+	Path       string
+	UpdatedAt  time.Time
+}
+
+// loadReindexTargets lists the active, indexable user files in scope.
+func (s *Service) loadReindexTargets(ctx context.Context, scope ReindexScope) ([]reindexTarget, error) {
+	query := `SELECT apikey_hash, project, path, updated_at FROM mcp_files WHERE deleted = FALSE AND skip_rag_index = FALSE AND system_owner = ?`
+	args := []any{""}
+	if scope.APIKeyHash != "" {
+		query += " AND apikey_hash = ?"
+		args = append(args, scope.APIKeyHash)
+	}
+	if scope.Project != "" {
+		query += " AND project = ?"
+		args = append(args, scope.Project)
+	}
+	query += " ORDER BY id ASC"
+
+	rows, err := s.db.QueryContext(ctx, rebindSQL(query, s.isPostgres), args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "query reindex files")
+	}
+	defer func() { _ = rows.Close() }()
+
+	var targets []reindexTarget
+	for rows.Next() {
+		var target reindexTarget
+		if scanErr := rows.Scan(&target.APIKeyHash, &target.Project, &target.Path, &target.UpdatedAt); scanErr != nil {
+			return nil, errors.Wrap(scanErr, "scan reindex file")
+		}
+		targets = append(targets, target)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate reindex files")
+	}
+	return targets, nil
+}
+
+// insertReindexRun records a new run and returns its id.
+func (s *Service) insertReindexRun(ctx context.Context, scope ReindexScope, status string, total int64, skipped []ReindexSkippedProject, now time.Time, completedAt *time.Time) (int64, error) {
+	var skippedJSON string
+	if len(skipped) > 0 {
+		raw, err := json.Marshal(skipped)
+		if err != nil {
+			return 0, errors.Wrap(err, "encode skipped projects")
+		}
+		skippedJSON = string(raw)
+	}
+
+	query := `INSERT INTO mcp_file_reindex_runs (apikey_hash, project, status, total_jobs, skipped, created_at, updated_at, completed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	args := []any{scope.APIKeyHash, scope.Project, status, total, skippedJSON, now, now, completedAt}
+	if s.isPostgres {
+		var id int64
+		if err := s.db.QueryRowContext(ctx, rebindSQL(query+" RETURNING id", true), args...).Scan(&id); err != nil {
+			return 0, errors.Wrap(err, "insert reindex run")
+		}
+		return id, nil
+	}
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, errors.Wrap(err, "insert reindex run")
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, errors.Wrap(err, "read reindex run id")
+	}
+	return id, nil
+}
+
+// countRunningReindexRuns counts enqueuing and running runs with exactly scope.
+func (s *Service) countRunningReindexRuns(ctx context.Context, scope ReindexScope) (int64, error) {
+	var count int64
+	if err := s.db.QueryRowContext(ctx,
+		rebindSQL(`SELECT COUNT(1) FROM mcp_file_reindex_runs WHERE status IN (?, ?) AND apikey_hash = ? AND project = ?`, s.isPostgres),
+		"enqueuing",
+		"running",
+		scope.APIKeyHash,
+		scope.Project,
+	).Scan(&count); err != nil {
+		return 0, errors.Wrap(err, "count running reindex runs")
+	}
+	return count, nil
+}
+
+// refreshReindexRun completes run id once none of its jobs is pending or processing.
+func (s *Service) refreshReindexRun(ctx context.Context, id int64) error {
+	now := s.clock()
+	_, err := s.db.ExecContext(ctx,
+		rebindSQL(`UPDATE mcp_file_reindex_runs SET status = ?, completed_at = ?, updated_at = ?
+		WHERE id = ? AND status = ? AND NOT EXISTS (
+			SELECT 1 FROM mcp_file_index_jobs WHERE reindex_run_id = ? AND status IN (?, ?)
+		)`, s.isPostgres),
+		"completed",
+		now,
+		now,
+		id,
+		"running",
+		id,
+		"pending",
+		"processing",
+	)
+	if err != nil {
+		return errors.Wrap(err, "complete reindex run")
+	}
+	return nil
+}
+
+// fillReindexProgress counts the run's jobs by status and measures the freshness of
+// live writes indexed while it was active.
+func (s *Service) fillReindexProgress(ctx context.Context, run *ReindexRun) error {
+	rows, err := s.db.QueryContext(ctx,
+		rebindSQL(`SELECT status, COUNT(1) FROM mcp_file_index_jobs WHERE reindex_run_id = ? GROUP BY status`, s.isPostgres),
+		run.ID,
+	)
+	if err != nil {
+		return errors.Wrap(err, "count reindex jobs")
+	}
+	for rows.Next() {
+		var status string
+		var count int64
+		if scanErr := rows.Scan(&status, &count); scanErr != nil {
+			_ = rows.Close()
+			return errors.Wrap(scanErr, "scan reindex job count")
+		}
+		switch status {
+		case "pending":
+			run.Pending = count
+		case "processing":
+			run.Processing = count
+		case "done":
+			run.Done = count
+		case "failed":
+			run.Failed = count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return errors.Wrap(err, "iterate reindex job counts")
+	}
+	if err := rows.Close(); err != nil {
+		return errors.Wrap(err, "close reindex job counts rows")
+	}
+
+	freshness, err := s.liveIndexFreshness(ctx, run.APIKeyHash, run.Project, run.CreatedAt, run.CompletedAt)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	run.Freshness = freshness
+	return nil
+}
+
+// liveIndexFreshness computes the p95 wait of live write jobs in scope that were
+// enqueued between since and until (open-ended when nil) and are done.
+func (s *Service) liveIndexFreshness(ctx context.Context, apiKeyHash, project string, since time.Time, until *time.Time) (IndexFreshness, error) {
+	freshness := IndexFreshness{SLOSeconds: s.settings.Index.FreshnessSLO.Seconds(), WithinSLO: true}
+	query := `SELECT created_at, updated_at FROM mcp_file_index_jobs WHERE reindex_run_id = 0 AND status = ? AND system_owner = ? AND created_at >= ?`
+	args := []any{"done", "", since}
+	if until != nil {
+		query += " AND created_at <= ?"
+		args = append(args, *until)
+	}
+	if apiKeyHash != "" {
+		query += " AND apikey_hash = ?"
+		args = append(args, apiKeyHash)
+	}
+	if project != "" {
+		query += " AND project = ?"
+		args = append(args, project)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, reindexFreshnessSampleSize)
+
+	rows, err := s.db.QueryContext(ctx, rebindSQL(query, s.isPostgres), args...)
+	if err != nil {
+		return freshness, errors.Wrap(err, "query live index jobs")
+	}
+	defer func() { _ = rows.Close() }()
+
+	var waits []float64
+	for rows.Next() {
+		var createdAt, doneAt time.Time
+		if scanErr := rows.Scan(&createdAt, &doneAt); scanErr != nil {
+			return freshness, errors.Wrap(scanErr, "scan live index job")
+		}
+		waits = append(waits, math.Max(0, doneAt.Sub(createdAt).Seconds()))
+	}
+	if err := rows.Err(); err != nil {
+		return freshness, errors.Wrap(err, "iterate live index jobs")
+	}
+	if len(waits) == 0 {
+		return freshness, nil
+	}
+
+	sort.Float64s(waits)
+	freshness.Jobs = len(waits)
+	freshness.P95Seconds = waits[int(math.Ceil(0.95*float64(len(waits))))-1]
+	freshness.WithinSLO = freshness.P95Seconds <= freshness.SLOSeconds
+	return freshness, nil
+}
+
+// staleEmbeddingModels returns up to reindexStaleModelsMax models other than model
+// that chunks in the search scope are still embedded with, those with the most
+// embeddings first, but only while a re-index run covering the scope is enqueuing or
+// running. Outside of runs, vectors of other models are not searched.
+func (s *Service) staleEmbeddingModels(ctx context.Context, apiKeyHash, project, model string) ([]string, error) {
+	if systemOwnerFromContext(ctx) != "" {
+		return nil, nil
+	}
+	runQuery := `SELECT COUNT(1) FROM mcp_file_reindex_runs WHERE status IN (?, ?) AND (apikey_hash = '' OR apikey_hash = ?)`
+	runArgs := []any{"enqueuing", "running", apiKeyHash}
+	if project != ProjectWildcard {
+		runQuery += " AND (project = '' OR project = ?)"
+		runArgs = append(runArgs, project)
+	}
+	var running int64
+	if err := s.db.QueryRowContext(ctx, rebindSQL(runQuery, s.isPostgres), runArgs...).Scan(&running); err != nil {
+		return nil, errors.Wrap(err, "query running reindex runs")
+	}
+	if running == 0 {
+		return nil, nil
+	}
+
+	query := `SELECT e.model FROM mcp_file_chunk_embeddings e
+		JOIN mcp_file_chunks c ON c.id = e.chunk_id
+		WHERE c.apikey_hash = ? AND c.system_owner = ? AND e.model <> ?`
+	args := []any{apiKeyHash, "", model}
+	if project != ProjectWildcard {
+		query += " AND c.project = ?"
+		args = append(args, project)
+	}
+	query += " GROUP BY e.model ORDER BY COUNT(1) DESC, e.model ASC LIMIT ?"
+	args = append(args, reindexStaleModelsMax)
+	rows, err := s.db.QueryContext(ctx, rebindSQL(query, s.isPostgres), args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "query stale embedding models")
+	}
+	defer func() { _ = rows.Close() }()
+
+	var models []string
+	for rows.Next() {
+		var stale string
+		if scanErr := rows.Scan(&stale); scanErr != nil {
+			return nil, errors.Wrap(scanErr, "scan stale embedding model")
+		}
+		models = append(models, stale)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate stale embedding models")
+	}
+	return models, nil
+}
+
+// fetchStaleSemanticCandidates searches the chunks not yet re-embedded with the
+// provider's model. Each stale model costs one more query embedding billed to the
+// caller, so at most reindexStaleModelsMax are searched. It is best-effort: failures
+// only cost recall, so they are logged.
+func (s *Service) fetchStaleSemanticCandidates(ctx context.Context, auth AuthContext, project, pathPrefix string, filter *compiledSearchFilter, provider EmbeddingProvider, query string) []searchCandidate {
+	embedder, ok := provider.(ModelEmbedder)
+	if !ok {
+		return nil
+	}
+	models, err := s.staleEmbeddingModels(ctx, auth.APIKeyHash, project, provider.Model())
+	if err != nil {
+		s.LoggerFromContext(ctx).Debug("file search stale model lookup failed", zap.String("project", project), zap.Error(err))
+		return nil
+	}
+
+	var candidates []searchCandidate
+	for _, model := range models {
+		vectors, err := embedder.EmbedTextsWithModel(ctx, auth.APIKey, model, []string{query})
+		if err != nil || len(vectors) == 0 {
+			s.LoggerFromContext(ctx).Debug("file search stale model embedding failed",
+				zap.String("project", project),
+				zap.String("model", model),
+				zap.Error(err),
+			)
+			continue
+		}
+		stale, err := s.fetchSemanticCandidates(ctx, auth.APIKeyHash, project, pathPrefix, filter, model, vectors[0], s.settings.Search.VectorCandidates)
+		if err != nil {
+			s.LoggerFromContext(ctx).Debug("file search stale model retrieval failed",
+				zap.String("project", project),
+				zap.String("model", model),
+				zap.Error(err),
+			)
+			continue
+		}
+		candidates = append(candidates, stale...)
+	}
+	return candidates
+}
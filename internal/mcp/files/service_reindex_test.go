@@ -0,0 +1,279 @@
+package files
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	errors "github.com/Laisky/errors/v2"
+	"github.com/pgvector/pgvector-go"
+	"github.com/stretchr/testify/require"
+)
+
+// reindexTestVectors maps the test models to the vector they embed every text as.
+var reindexTestVectors = map[string]pgvector.Vector{
+	"model-a": pgvector.NewVector([]float32{1, 0}),
+	"model-b": pgvector.NewVector([]float32{0, 1, 0}),
+}
+
+// testModelProvider is a testProvider that can also embed with other models.
+type testModelProvider struct {
+	testProvider
+}
+
+// newTestModelProvider returns a provider embedding with model.
+func newTestModelProvider(model string) testModelProvider {
+	return testModelProvider{testProvider{testEmbedder: testEmbedder{vector: reindexTestVectors[model]}, model: model}}
+}
+
+// EmbedTextsWithModel returns the vector of model for each input.
+func (p testModelProvider) EmbedTextsWithModel(ctx context.Context, apiKey, model string, inputs []string) ([]pgvector.Vector, error) {
+	return testEmbedder{vector: reindexTestVectors[model]}.EmbedTexts(ctx, apiKey, inputs)
+}
+
+// newReindexTestService returns a service whose default provider can be swapped.
+func newReindexTestService(t *testing.T) (*Service, *memoryCredentialStore, *EmbeddingProvider) {
+	t.Helper()
+	settings := LoadSettingsFromConfig()
+	settings.Search.Enabled = true
+	settings.Security.EncryptionKEKs = map[uint16]string{1: testEncryptionKey()}
+	settings.Index.BatchSize = 1
+	store := &memoryCredentialStore{}
+	svc := newTestService(t, settings, nil, store)
+	// Freshness windows compare timestamps, so the clock has to move.
+	now := time.Date(2026, 2, 11, 0, 0, 0, 0, time.UTC)
+	svc.clock = func() time.Time {
+		now = now.Add(time.Millisecond)
+		return now
+	}
+	var current EmbeddingProvider = newTestModelProvider("model-a")
+	svc.SetEmbeddingResolver(func(string) (EmbeddingProvider, error) {
+		return current, nil
+	})
+	return svc, store, &current
+}
+
+// TestReindex_MigratesEmbeddingModel verifies a run re-embeds files with the new
+// model, serves the old vectors until it completes, and lets live writes go first.
+func TestReindex_MigratesEmbeddingModel(t *testing.T) {
+	svc, _, current := newReindexTestService(t)
+	auth := AuthContext{APIKeyHash: "hash", APIKey: "key"}
+	ctx := context.Background()
+	worker := svc.NewIndexWorker()
+
+	for _, path := range []string{"/a.txt", "/b.txt"} {
+		_, err := svc.Write(ctx, auth, "proj", path, "alpha beta", "utf-8", 0, WriteModeTruncate)
+		require.NoError(t, err)
+		require.NoError(t, worker.RunOnce(ctx))
+	}
+	require.Equal(t, [][2]any{{"model-a", 2}, {"model-a", 2}}, embeddingRows(t, svc, "proj"))
+
+	*current = newTestModelProvider("model-b")
+	filter, err := compileSearchFilter(SearchFilter{})
+	require.NoError(t, err)
+	require.Empty(t, svc.fetchStaleSemanticCandidates(ctx, auth, "proj", "", filter, *current, "alpha"))
+
+	_, err = svc.StartReindex(ctx, ReindexScope{Project: "proj"})
+	require.True(t, IsCode(err, ErrCodeInvalidArgument))
+	run, err := svc.StartReindex(ctx, ReindexScope{APIKeyHash: auth.APIKeyHash, APIKey: auth.APIKey, Project: "proj"})
+	require.NoError(t, err)
+	require.Equal(t, "running", run.Status)
+	require.Equal(t, int64(2), run.TotalJobs)
+	require.Equal(t, int64(2), run.Pending)
+	_, err = svc.StartReindex(ctx, ReindexScope{APIKeyHash: auth.APIKeyHash, Project: "proj"})
+	require.True(t, IsCode(err, ErrCodeAlreadyExists))
+
+	// The old vectors keep matching through their own model while the run is pending.
+	require.Len(t, svc.fetchStaleSemanticCandidates(ctx, auth, "proj", "", filter, *current, "alpha"), 2)
+
+	// A live write enqueued after the run is claimed before the run's jobs.
+	_, err = svc.Write(ctx, auth, "proj", "/c.txt", "alpha gamma", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+	require.NoError(t, worker.RunOnce(ctx))
+	run, err = svc.ReindexRunStatus(ctx, run.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), run.Pending)
+	require.Equal(t, 1, run.Freshness.Jobs)
+	require.True(t, run.Freshness.WithinSLO)
+
+	require.NoError(t, worker.RunOnce(ctx))
+	require.NoError(t, worker.RunOnce(ctx))
+	run, err = svc.ReindexRunStatus(ctx, run.ID)
+	require.NoError(t, err)
+	require.Equal(t, "completed", run.Status)
+	require.Equal(t, int64(2), run.Done)
+	require.NotNil(t, run.CompletedAt)
+	require.Equal(t, [][2]any{{"model-b", 3}, {"model-b", 3}, {"model-b", 3}}, embeddingRows(t, svc, "proj"))
+	require.Empty(t, svc.fetchStaleSemanticCandidates(ctx, auth, "proj", "", filter, *current, "alpha"))
+
+	runs, err := svc.ListReindexRuns(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	require.Equal(t, run.ID, runs[0].ID)
+}
+
+// TestReindex_MissingCredentialKeepsIndex verifies a run needing the API key is
+// refused without it, and that jobs whose credential envelope expired fail without
+// retries, keeping the old vectors.
+func TestReindex_MissingCredentialKeepsIndex(t *testing.T) {
+	svc, store, current := newReindexTestService(t)
+	auth := AuthContext{APIKeyHash: "hash", APIKey: "key"}
+	ctx := context.Background()
+	worker := svc.NewIndexWorker()
+
+	_, err := svc.Write(ctx, auth, "proj", "/a.txt", "alpha beta", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+	require.NoError(t, worker.RunOnce(ctx))
+
+	*current = newTestModelProvider("model-b").testProvider
+	_, err = svc.StartReindex(ctx, ReindexScope{APIKeyHash: auth.APIKeyHash})
+	require.True(t, IsCode(err, ErrCodeInvalidArgument))
+	runs, err := svc.ListReindexRuns(ctx, 0)
+	require.NoError(t, err)
+	require.Empty(t, runs)
+
+	run, err := svc.StartReindex(ctx, ReindexScope{APIKeyHash: auth.APIKeyHash, APIKey: auth.APIKey})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), run.TotalJobs)
+	store.data = nil
+
+	require.NoError(t, worker.RunOnce(ctx))
+	run, err = svc.ReindexRunStatus(ctx, run.ID)
+	require.NoError(t, err)
+	require.Equal(t, "completed", run.Status)
+	require.Equal(t, int64(1), run.Failed)
+	require.Equal(t, [][2]any{{"model-a", 2}}, embeddingRows(t, svc, "proj"))
+
+	// Retrying the failed job reopens the completed run until the job finishes.
+	retried, err := svc.RetryFailedIndexJobs(ctx, auth, "proj", 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, retried)
+	run, err = svc.ReindexRunStatus(ctx, run.ID)
+	require.NoError(t, err)
+	require.Equal(t, "running", run.Status)
+	require.NoError(t, worker.RunOnce(ctx))
+	run, err = svc.ReindexRunStatus(ctx, run.ID)
+	require.NoError(t, err)
+	require.Equal(t, "completed", run.Status)
+	require.Equal(t, int64(1), run.Done)
+	require.Equal(t, [][2]any{{"model-b", 3}}, embeddingRows(t, svc, "proj"))
+
+	empty, err := svc.StartReindex(ctx, ReindexScope{APIKeyHash: "other"})
+	require.NoError(t, err)
+	require.Equal(t, "completed", empty.Status)
+	require.Zero(t, empty.TotalJobs)
+}
+
+// TestReindex_AllKeysSkipsProjectsNeedingKey verifies an all-keys run, which carries
+// no API key, enqueues the projects of keyless providers and reports the others.
+func TestReindex_AllKeysSkipsProjectsNeedingKey(t *testing.T) {
+	settings := LoadSettingsFromConfig()
+	settings.Search.Enabled = true
+	settings.Security.EncryptionKEKs = map[uint16]string{1: testEncryptionKey()}
+	svc := newTestService(t, settings, nil, &memoryCredentialStore{})
+	providers := map[string]EmbeddingProvider{
+		"":      newTestModelProvider("model-a"),
+		"local": testProvider{testEmbedder: testEmbedder{vector: reindexTestVectors["model-b"]}, model: "model-b", keyless: true},
+	}
+	svc.SetEmbeddingResolver(func(name string) (EmbeddingProvider, error) {
+		return providers[name], nil
+	})
+	auth := AuthContext{APIKeyHash: "hash", APIKey: "key"}
+	ctx := context.Background()
+
+	_, err := svc.SetProjectEmbedding(ctx, auth, "local-proj", "local")
+	require.NoError(t, err)
+	for _, file := range [][2]string{{"keyed", "/a.txt"}, {"keyed", "/b.txt"}, {"local-proj", "/c.txt"}} {
+		_, err = svc.Write(ctx, auth, file[0], file[1], "alpha beta", "utf-8", 0, WriteModeTruncate)
+		require.NoError(t, err)
+	}
+
+	run, err := svc.StartReindex(ctx, ReindexScope{})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), run.TotalJobs)
+	skipped := []ReindexSkippedProject{{APIKeyHash: "hash", Project: "keyed", Model: "model-a", Files: 2}}
+	require.Equal(t, skipped, run.Skipped)
+
+	runs, err := svc.ListReindexRuns(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	require.Equal(t, skipped, runs[0].Skipped)
+
+	// A run for one key is refused instead, since its caller can supply the key.
+	_, err = svc.StartReindex(ctx, ReindexScope{APIKeyHash: "hash"})
+	require.True(t, IsCode(err, ErrCodeInvalidArgument))
+}
+
+// failingCredentialStore is a memoryCredentialStore that fails after storing limit
+// envelopes.
+type failingCredentialStore struct {
+	memoryCredentialStore
+	limit int
+}
+
+// Store fails once limit envelopes are stored.
+func (s *failingCredentialStore) Store(ctx context.Context, key, payload string, ttl time.Duration) error {
+	if len(s.memoryCredentialStore.data) >= s.limit {
+		return errors.New("credential store unavailable")
+	}
+	return s.memoryCredentialStore.Store(ctx, key, payload, ttl)
+}
+
+// TestReindex_EnqueuesInBatches verifies jobs are committed page by page: a failing
+// page marks the run failed with the jobs of the pages before it, which still run.
+func TestReindex_EnqueuesInBatches(t *testing.T) {
+	settings := LoadSettingsFromConfig()
+	settings.Search.Enabled = true
+	settings.Security.EncryptionKEKs = map[uint16]string{1: testEncryptionKey()}
+	settings.Index.ReindexBatchSize = 2
+	store := &failingCredentialStore{limit: 100}
+	svc := newTestService(t, settings, nil, store)
+	var current EmbeddingProvider = newTestModelProvider("model-a")
+	svc.SetEmbeddingResolver(func(string) (EmbeddingProvider, error) {
+		return current, nil
+	})
+	auth := AuthContext{APIKeyHash: "hash", APIKey: "key"}
+	ctx := context.Background()
+	worker := svc.NewIndexWorker()
+
+	for _, path := range []string{"/a.txt", "/b.txt", "/c.txt"} {
+		_, err := svc.Write(ctx, auth, "proj", path, "alpha beta", "utf-8", 0, WriteModeTruncate)
+		require.NoError(t, err)
+	}
+	for range 3 {
+		require.NoError(t, worker.RunOnce(ctx))
+	}
+
+	current = newTestModelProvider("model-b")
+	store.data = nil
+	store.limit = 2
+	scope := ReindexScope{APIKeyHash: auth.APIKeyHash, APIKey: auth.APIKey}
+	_, err := svc.StartReindex(ctx, scope)
+	require.Error(t, err)
+
+	runs, err := svc.ListReindexRuns(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	require.Equal(t, "failed", runs[0].Status)
+	require.Equal(t, int64(2), runs[0].TotalJobs)
+	require.Equal(t, int64(2), runs[0].Pending)
+
+	// Retrying its jobs does not reopen a run that failed while enqueuing.
+	store.data = nil
+	require.NoError(t, worker.RunOnce(ctx))
+	retried, err := svc.RetryFailedIndexJobs(ctx, auth, "proj", 0)
+	require.NoError(t, err)
+	require.Equal(t, 2, retried)
+	failed, err := svc.ReindexRunStatus(ctx, runs[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, "failed", failed.Status)
+	require.Equal(t, int64(2), failed.Pending)
+
+	// A failed run does not block the next one.
+	store.limit = 100
+	run, err := svc.StartReindex(ctx, scope)
+	require.NoError(t, err)
+	require.Equal(t, "running", run.Status)
+	require.Equal(t, int64(3), run.TotalJobs)
+	require.Equal(t, int64(3), run.Pending)
+}
@@ -10,18 +10,21 @@ import (
 
 // renameSourceFile represents an active file selected as a rename source.
 type renameSourceFile struct {
-	ID   uint64
-	Path string
+	ID       uint64
+	Path     string
+	Revision int64
 }
 
 // renameMapping represents one old path to new path update during rename.
 type renameMapping struct {
-	ID      uint64
-	OldPath string
-	NewPath string
+	ID       uint64
+	OldPath  string
+	NewPath  string
+	Revision int64
 }
 
-// Rename renames or moves a file path or directory subtree.
+// Rename renames or moves a file path or directory subtree. An if_match precondition
+// on ctx is checked against the source, which must then be a single file.
 func (s *Service) Rename(ctx context.Context, auth AuthContext, project, fromPath, toPath string, overwrite bool) (RenameResult, error) { //nolint:gocognit // rename involves multiple validation and migration steps
 	if err := s.validateAuth(auth); err != nil {
 		return RenameResult{}, errors.WithStack(err)
@@ -43,12 +46,27 @@ func (s *Service) Rename(ctx context.Context, auth AuthContext, project, fromPat
 	}
 
 	owner := systemOwnerFromContext(ctx)
+	ifMatch := ifMatchFromContext(ctx)
 	movedCount := 0
+	var changes []FileChange
 	err := s.lockProvider.WithProjectLock(ctx, s.db, s.isPostgres, auth.APIKeyHash, project, s.settings.LockTimeout, func(tx *sql.Tx) error {
 		sourceFiles, sourceIsDirectory, err := s.resolveRenameSources(ctx, tx, auth.APIKeyHash, project, fromPath)
 		if err != nil {
+			if IsCode(err, ErrCodeNotFound) {
+				if matchErr := checkIfMatch(ifMatch, nil); matchErr != nil {
+					return matchErr
+				}
+			}
 			return err
 		}
+		if ifMatch != "" {
+			if sourceIsDirectory {
+				return NewError(ErrCodeInvalidArgument, "if_match requires a file path", false)
+			}
+			if err := checkIfMatch(ifMatch, &File{Path: sourceFiles[0].Path, Revision: sourceFiles[0].Revision}); err != nil {
+				return err
+			}
+		}
 
 		if sourceIsDirectory && strings.HasPrefix(toPath, fromPath+"/") {
 			return NewError(ErrCodeInvalidPath, "destination cannot be within source subtree", false)
@@ -71,7 +89,7 @@ func (s *Service) Rename(ctx context.Context, auth AuthContext, project, fromPat
 
 		if len(overwritePaths) > 0 {
 			inClause, inArgs := buildInClause(overwritePaths, s.isPostgres, 6)
-			query := rebindSQL(`UPDATE mcp_files SET deleted = TRUE, deleted_at = ?, updated_at = ? WHERE apikey_hash = ? AND project = ? AND deleted = FALSE AND system_owner = ? AND path IN (%s)`, s.isPostgres)
+			query := rebindSQL(`UPDATE mcp_files SET deleted = TRUE, deleted_at = ?, updated_at = ?, revision = revision + 1 WHERE apikey_hash = ? AND project = ? AND deleted = FALSE AND system_owner = ? AND path IN (%s)`, s.isPostgres)
 			args := make([]any, 0, 5+len(inArgs))
 			args = append(args, now, now, auth.APIKeyHash, project, owner)
 			args = append(args, inArgs...)
@@ -81,9 +99,16 @@ func (s *Service) Rename(ctx context.Context, auth AuthContext, project, fromPat
 		}
 
 		for _, mapping := range mappings {
+			// A moved file must also outrank any earlier file at its new path.
+			revision, err := s.nextPathRevisionTx(ctx, tx, auth.APIKeyHash, project, mapping.NewPath, owner)
+			if err != nil {
+				return err
+			}
+			revision = max(revision, mapping.Revision+1)
 			if _, err := tx.ExecContext(ctx,
-				rebindSQL(`UPDATE mcp_files SET path = ?, updated_at = ? WHERE id = ? AND system_owner = ?`, s.isPostgres),
+				rebindSQL(`UPDATE mcp_files SET path = ?, revision = ?, updated_at = ? WHERE id = ? AND system_owner = ?`, s.isPostgres),
 				mapping.NewPath,
+				revision,
 				now,
 				mapping.ID,
 				owner,
@@ -159,12 +184,19 @@ func (s *Service) Rename(ctx context.Context, auth AuthContext, project, fromPat
 		}
 
 		movedCount = len(mappings)
+		for _, mapping := range mappings {
+			changes = append(changes,
+				FileChange{APIKeyHash: auth.APIKeyHash, Project: project, Path: mapping.OldPath, Deleted: true},
+				FileChange{APIKeyHash: auth.APIKeyHash, Project: project, Path: mapping.NewPath},
+			)
+		}
 		return nil
 	})
 	if err != nil {
 		return RenameResult{}, errors.WithStack(err)
 	}
 
+	s.notifyChanges(ctx, changes...)
 	return RenameResult{MovedCount: movedCount}, nil
 }
 
@@ -174,12 +206,12 @@ func (s *Service) resolveRenameSources(ctx context.Context, tx *sql.Tx, apiKeyHa
 	owner := systemOwnerFromContext(ctx)
 	var exact renameSourceFile
 	err := tx.QueryRowContext(ctx,
-		rebindSQL(`SELECT id, path FROM mcp_files WHERE apikey_hash = ? AND project = ? AND path = ? AND deleted = FALSE AND system_owner = ? LIMIT 1`, s.isPostgres),
+		rebindSQL(`SELECT id, path, revision FROM mcp_files WHERE apikey_hash = ? AND project = ? AND path = ? AND deleted = FALSE AND system_owner = ? LIMIT 1`, s.isPostgres),
 		apiKeyHash,
 		project,
 		fromPath,
 		owner,
-	).Scan(&exact.ID, &exact.Path)
+	).Scan(&exact.ID, &exact.Path, &exact.Revision)
 	if err == nil {
 		return []renameSourceFile{exact}, false, nil
 	}
@@ -189,7 +221,7 @@ func (s *Service) resolveRenameSources(ctx context.Context, tx *sql.Tx, apiKeyHa
 
 	prefix := buildPathPrefix(fromPath)
 	rows, err := tx.QueryContext(ctx,
-		rebindSQL(`SELECT id, path FROM mcp_files WHERE apikey_hash = ? AND project = ? AND path LIKE ? AND deleted = FALSE AND system_owner = ? ORDER BY path ASC`, s.isPostgres),
+		rebindSQL(`SELECT id, path, revision FROM mcp_files WHERE apikey_hash = ? AND project = ? AND path LIKE ? AND deleted = FALSE AND system_owner = ? ORDER BY path ASC`, s.isPostgres),
 		apiKeyHash,
 		project,
 		prefix,
@@ -203,7 +235,7 @@ func (s *Service) resolveRenameSources(ctx context.Context, tx *sql.Tx, apiKeyHa
 	var descendants []renameSourceFile
 	for rows.Next() {
 		var row renameSourceFile
-		if scanErr := rows.Scan(&row.ID, &row.Path); scanErr != nil {
+		if scanErr := rows.Scan(&row.ID, &row.Path, &row.Revision); scanErr != nil {
 			return nil, false, errors.Wrap(scanErr, "scan rename source descendant")
 		}
 		descendants = append(descendants, row)
@@ -230,7 +262,7 @@ func buildRenameMappings(sourceFiles []renameSourceFile, fromPath, toPath string
 			}
 			newPath = toPath + suffix
 		}
-		mappings = append(mappings, renameMapping{ID: source.ID, OldPath: source.Path, NewPath: newPath})
+		mappings = append(mappings, renameMapping{ID: source.ID, OldPath: source.Path, NewPath: newPath, Revision: source.Revision})
 	}
 
 	return mappings, nil
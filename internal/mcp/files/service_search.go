@@ -22,7 +22,9 @@ type searchCandidate struct {
 	FinalScore    float64
 }
 
-// Search performs hybrid retrieval over indexed file chunks.
+// Search performs hybrid retrieval over indexed file chunks. It applies the SearchFilter
+// attached to ctx by WithSearchFilter and reports facets over the ranked candidate pool,
+// which holds at most Settings.Search.LexicalCandidates + VectorCandidates chunks.
 func (s *Service) Search(ctx context.Context, auth AuthContext, project, query, pathPrefix string, limit int) (SearchResult, error) {
 	if err := s.validateAuth(auth); err != nil {
 		return SearchResult{}, errors.WithStack(err)
@@ -34,6 +36,10 @@ func (s *Service) Search(ctx context.Context, auth AuthContext, project, query,
 	if query == "" {
 		return SearchResult{}, errors.WithStack(NewError(ErrCodeInvalidQuery, "query cannot be empty", false))
 	}
+	filter, err := compileSearchFilter(SearchFilterFromContext(ctx))
+	if err != nil {
+		return SearchResult{}, errors.WithStack(err)
+	}
 	if limit <= 0 {
 		limit = s.settings.Search.LimitDefault
 	}
@@ -46,7 +52,7 @@ func (s *Service) Search(ctx context.Context, auth AuthContext, project, query,
 
 	lexicalEngine := s.lexicalSearchEngineName()
 	lexicalStartedAt := time.Now()
-	lexical, lexicalErr := s.fetchLexicalCandidates(ctx, auth.APIKeyHash, project, pathPrefix, query, s.settings.Search.LexicalCandidates)
+	lexical, lexicalErr := s.fetchLexicalCandidates(ctx, auth.APIKeyHash, project, pathPrefix, filter, query, s.settings.Search.LexicalCandidates)
 	s.logSearchStage(ctx, project, pathPrefix, searchStageMetrics{
 		Stage:       "lexical_retrieve",
 		Engine:      lexicalEngine,
@@ -66,8 +72,12 @@ func (s *Service) Search(ctx context.Context, auth AuthContext, project, query,
 	semantic := []searchCandidate{}
 	var semanticErr error
 	semanticEngine := s.semanticSearchEngineName()
-	if s.embedder == nil {
+	provider, providerErr := s.embeddingProviderFor(ctx, auth.APIKeyHash, project)
+	if provider == nil {
 		semanticErr = NewError(ErrCodeSearchBackend, "embedder not configured", false)
+		if providerErr != nil {
+			semanticErr = errors.Wrap(providerErr, "resolve embedding provider")
+		}
 		s.logSearchStage(ctx, project, pathPrefix, searchStageMetrics{
 			Stage:       "semantic_retrieve",
 			Engine:      semanticEngine,
@@ -81,7 +91,7 @@ func (s *Service) Search(ctx context.Context, auth AuthContext, project, query,
 		)
 	} else {
 		embedStartedAt := time.Now()
-		vectors, embedErr := s.embedder.EmbedTexts(ctx, auth.APIKey, []string{query})
+		vectors, embedErr := provider.EmbedTexts(ctx, auth.APIKey, []string{query})
 		s.logSearchStage(ctx, project, pathPrefix, searchStageMetrics{
 			Stage:       "semantic_embed",
 			Engine:      "embedder",
@@ -105,7 +115,7 @@ func (s *Service) Search(ctx context.Context, auth AuthContext, project, query,
 		} else {
 			queryVec := vectors[0]
 			semanticStartedAt := time.Now()
-			semantic, semanticErr = s.fetchSemanticCandidates(ctx, auth.APIKeyHash, project, pathPrefix, queryVec, s.settings.Search.VectorCandidates)
+			semantic, semanticErr = s.fetchSemanticCandidates(ctx, auth.APIKeyHash, project, pathPrefix, filter, provider.Model(), queryVec, s.settings.Search.VectorCandidates)
 			s.logSearchStage(ctx, project, pathPrefix, searchStageMetrics{
 				Stage:       "semantic_retrieve",
 				Engine:      semanticEngine,
@@ -120,6 +130,10 @@ func (s *Service) Search(ctx context.Context, auth AuthContext, project, query,
 					zap.Int("candidate_limit", s.settings.Search.VectorCandidates),
 					zap.Error(semanticErr),
 				)
+			} else {
+				// While a re-index run is migrating the scope to another model, chunks it
+				// has not reached yet are still searched with their old model.
+				semantic = append(semantic, s.fetchStaleSemanticCandidates(ctx, auth, project, pathPrefix, filter, provider, query)...)
 			}
 		}
 	}
@@ -131,12 +145,12 @@ func (s *Service) Search(ctx context.Context, auth AuthContext, project, query,
 	merged := mergeCandidates(semantic, lexical)
 	if len(merged) == 0 {
 		fallbackStartedAt := time.Now()
-		fallbackChunks, fallbackErr := s.searchFallbackFromRawFiles(ctx, auth.APIKeyHash, project, pathPrefix, query, limit)
+		fallback, fallbackErr := s.searchFallbackFromRawFiles(ctx, auth.APIKeyHash, project, pathPrefix, filter, query, limit)
 		s.logSearchStage(ctx, project, pathPrefix, searchStageMetrics{
 			Stage:       "raw_file_fallback",
 			Engine:      "sql_raw_file_scan",
 			DurationMS:  time.Since(fallbackStartedAt).Milliseconds(),
-			ResultCount: len(fallbackChunks),
+			ResultCount: len(fallback.Chunks),
 			Err:         fallbackErr,
 		})
 		if fallbackErr != nil {
@@ -147,13 +161,13 @@ func (s *Service) Search(ctx context.Context, auth AuthContext, project, query,
 				zap.Error(fallbackErr),
 			)
 		}
-		if len(fallbackChunks) > 0 {
+		if len(fallback.Chunks) > 0 {
 			s.LoggerFromContext(ctx).Debug("file search returned raw-file fallback results",
 				zap.String("project", project),
 				zap.String("path_prefix", pathPrefix),
-				zap.Int("result_count", len(fallbackChunks)),
+				zap.Int("result_count", len(fallback.Chunks)),
 			)
-			return SearchResult{Chunks: fallbackChunks}, nil
+			return fallback, nil
 		}
 		s.logEmptySearchDiagnostics(ctx, auth.APIKeyHash, project, pathPrefix, lexicalErr, semanticErr)
 		return SearchResult{Chunks: nil}, nil
@@ -173,6 +187,21 @@ func (s *Service) Search(ctx context.Context, auth AuthContext, project, query,
 	sort.Slice(finalCandidates, func(i, j int) bool {
 		return finalCandidates[i].FinalScore > finalCandidates[j].FinalScore
 	})
+	if filter.MinScore != 0 {
+		kept := finalCandidates[:0]
+		for _, c := range finalCandidates {
+			if c.FinalScore >= filter.MinScore {
+				kept = append(kept, c)
+			}
+		}
+		finalCandidates = kept
+	}
+
+	matchedPaths := make([]string, 0, len(finalCandidates))
+	for _, c := range finalCandidates {
+		matchedPaths = append(matchedPaths, c.Chunk.FilePath)
+	}
+	facets := searchFacets(matchedPaths)
 
 	if len(finalCandidates) > limit {
 		finalCandidates = finalCandidates[:limit]
@@ -201,31 +230,34 @@ func (s *Service) Search(ctx context.Context, auth AuthContext, project, query,
 		return SearchResult{}, errors.WithStack(err)
 	}
 
-	return SearchResult{Chunks: chunks}, nil
+	return SearchResult{Chunks: chunks, Facets: facets}, nil
 }
 
 // searchFallbackFromRawFiles performs a best-effort lexical scan over active files when index rows are unavailable.
-func (s *Service) searchFallbackFromRawFiles(ctx context.Context, apiKeyHash, project, pathPrefix, query string, limit int) ([]ChunkEntry, error) {
+func (s *Service) searchFallbackFromRawFiles(ctx context.Context, apiKeyHash, project, pathPrefix string, filter *compiledSearchFilter, query string, limit int) (SearchResult, error) {
 	if limit <= 0 {
-		return nil, nil
+		return SearchResult{}, nil
 	}
 
 	owner := systemOwnerFromContext(ctx)
 	crossProject := project == ProjectWildcard
 	args := []any{apiKeyHash, owner}
-	statement := "SELECT path, content, size, project FROM mcp_files WHERE apikey_hash = ? AND system_owner = ? AND deleted = FALSE"
+	statement := "SELECT f.path, COALESCE(b.content, f.content), f.size, f.project, f.mime_type FROM mcp_files f LEFT JOIN mcp_file_blobs b ON b.hash = f.content_hash WHERE f.apikey_hash = ? AND f.system_owner = ? AND f.deleted = FALSE"
 	if !crossProject {
-		statement += " AND project = ?"
+		statement += " AND f.project = ?"
 		args = append(args, project)
 	}
 	if strings.TrimSpace(pathPrefix) != "" {
-		statement += " AND path LIKE ?"
+		statement += " AND f.path LIKE ?"
 		args = append(args, pathPrefix+"%")
 	}
+	filterClause, filterArgs := filter.sqlClause("f.path", s.isPostgres)
+	statement += filterClause
+	args = append(args, filterArgs...)
 
 	rows, err := s.db.QueryContext(ctx, rebindSQL(statement, s.isPostgres), args...)
 	if err != nil {
-		return nil, errors.Wrap(err, "query raw files fallback")
+		return SearchResult{}, errors.Wrap(err, "query raw files fallback")
 	}
 	defer func() { _ = rows.Close() }()
 
@@ -243,22 +275,28 @@ func (s *Service) searchFallbackFromRawFiles(ctx context.Context, apiKeyHash, pr
 		var content []byte
 		var size int64
 		var rowProject string
-		if scanErr := rows.Scan(&path, &content, &size, &rowProject); scanErr != nil {
-			return nil, errors.Wrap(scanErr, "scan raw file fallback")
+		var mimeType string
+		if scanErr := rows.Scan(&path, &content, &size, &rowProject, &mimeType); scanErr != nil {
+			return SearchResult{}, errors.Wrap(scanErr, "scan raw file fallback")
+		}
+		if !IsTextMimeType(mimeType) || !filter.matchPath(path) {
+			continue
 		}
 		text := string(content)
 		score := lexicalScore(queryTokens, tokenize(text))
-		if score <= 0 {
+		// Token overlap counts matched query tokens, so MinScore is compared against the
+		// matched fraction to keep it on the same 0..1 scale as fused scores.
+		if score <= 0 || score/float64(len(queryTokens)) < filter.MinScore {
 			continue
 		}
 		candidates = append(candidates, fallbackCandidate{Path: path, Project: rowProject, Score: score, Content: text, Size: size})
 	}
 	if err := rows.Err(); err != nil {
-		return nil, errors.Wrap(err, "iterate raw files fallback")
+		return SearchResult{}, errors.Wrap(err, "iterate raw files fallback")
 	}
 
 	if len(candidates) == 0 {
-		return nil, nil
+		return SearchResult{}, nil
 	}
 
 	sort.Slice(candidates, func(i, j int) bool {
@@ -267,6 +305,11 @@ func (s *Service) searchFallbackFromRawFiles(ctx context.Context, apiKeyHash, pr
 		}
 		return candidates[i].Score > candidates[j].Score
 	})
+	matchedPaths := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		matchedPaths = append(matchedPaths, candidate.Path)
+	}
+	facets := searchFacets(matchedPaths)
 	if len(candidates) > limit {
 		candidates = candidates[:limit]
 	}
@@ -288,7 +331,7 @@ func (s *Service) searchFallbackFromRawFiles(ctx context.Context, apiKeyHash, pr
 		result = append(result, entry)
 	}
 
-	return result, nil
+	return SearchResult{Chunks: result, Facets: facets}, nil
 }
 
 // isChunkFullFile reports whether a chunk byte range covers the entire file payload.
@@ -401,25 +444,26 @@ func (s *Service) countPendingIndexJobs(ctx context.Context, apiKeyHash, project
 }
 
 // fetchSemanticCandidates retrieves semantic candidates from the best backend.
-func (s *Service) fetchSemanticCandidates(ctx context.Context, apiKeyHash, project, pathPrefix string, queryVec pgvector.Vector, limit int) ([]searchCandidate, error) {
+// Only chunks embedded by model with the dimensions of queryVec are compared.
+func (s *Service) fetchSemanticCandidates(ctx context.Context, apiKeyHash, project, pathPrefix string, filter *compiledSearchFilter, model string, queryVec pgvector.Vector, limit int) ([]searchCandidate, error) {
 	if limit <= 0 {
 		return nil, nil
 	}
 	if s.isPostgres {
-		return s.fetchSemanticCandidatesPostgres(ctx, apiKeyHash, project, pathPrefix, queryVec, limit)
+		return s.fetchSemanticCandidatesPostgres(ctx, apiKeyHash, project, pathPrefix, filter, model, queryVec, limit)
 	}
-	return s.fetchSemanticCandidatesInMemory(ctx, apiKeyHash, project, pathPrefix, queryVec, limit)
+	return s.fetchSemanticCandidatesInMemory(ctx, apiKeyHash, project, pathPrefix, filter, model, queryVec, limit)
 }
 
 // fetchSemanticCandidatesPostgres performs vector distance search via pgvector.
-func (s *Service) fetchSemanticCandidatesPostgres(ctx context.Context, apiKeyHash, project, pathPrefix string, queryVec pgvector.Vector, limit int) ([]searchCandidate, error) {
+func (s *Service) fetchSemanticCandidatesPostgres(ctx context.Context, apiKeyHash, project, pathPrefix string, filter *compiledSearchFilter, model string, queryVec pgvector.Vector, limit int) ([]searchCandidate, error) {
 	owner := systemOwnerFromContext(ctx)
-	args := []any{queryVec, apiKeyHash, owner}
+	args := []any{queryVec, apiKeyHash, owner, model, len(queryVec.Slice())}
 	query := `SELECT c.id, c.project, c.file_path, c.start_byte, c.end_byte, c.chunk_content, f.size, e.embedding <-> ? AS distance
 		FROM mcp_file_chunk_embeddings e
 		JOIN mcp_file_chunks c ON c.id = e.chunk_id
 		JOIN mcp_files f ON f.apikey_hash = c.apikey_hash AND f.project = c.project AND f.path = c.file_path AND f.deleted = FALSE AND f.system_owner = c.system_owner
-		WHERE c.apikey_hash = ? AND c.system_owner = ?`
+		WHERE c.apikey_hash = ? AND c.system_owner = ? AND e.model = ? AND e.dimensions = ?`
 	if project != ProjectWildcard {
 		query += " AND c.project = ?"
 		args = append(args, project)
@@ -428,6 +472,9 @@ func (s *Service) fetchSemanticCandidatesPostgres(ctx context.Context, apiKeyHas
 		query += sqlAndFilePathLike
 		args = append(args, pathPrefix+"%")
 	}
+	filterClause, filterArgs := filter.sqlClause("c.file_path", s.isPostgres)
+	query += filterClause
+	args = append(args, filterArgs...)
 	query += " ORDER BY e.embedding <-> ? LIMIT ?"
 	args = append(args, queryVec, limit)
 
@@ -474,8 +521,8 @@ func (s *Service) fetchSemanticCandidatesPostgres(ctx context.Context, apiKeyHas
 }
 
 // fetchSemanticCandidatesInMemory computes similarity scores without pgvector.
-func (s *Service) fetchSemanticCandidatesInMemory(ctx context.Context, apiKeyHash, project, pathPrefix string, queryVec pgvector.Vector, limit int) ([]searchCandidate, error) {
-	rows, err := s.fetchChunkEmbeddings(ctx, apiKeyHash, project, pathPrefix)
+func (s *Service) fetchSemanticCandidatesInMemory(ctx context.Context, apiKeyHash, project, pathPrefix string, filter *compiledSearchFilter, model string, queryVec pgvector.Vector, limit int) ([]searchCandidate, error) {
+	rows, err := s.fetchChunkEmbeddings(ctx, apiKeyHash, project, pathPrefix, filter, model, len(queryVec.Slice()))
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
@@ -508,18 +555,18 @@ func (s *Service) fetchSemanticCandidatesInMemory(ctx context.Context, apiKeyHas
 }
 
 // fetchLexicalCandidates routes lexical retrieval to the best available backend.
-func (s *Service) fetchLexicalCandidates(ctx context.Context, apiKeyHash, project, pathPrefix, query string, limit int) ([]searchCandidate, error) {
+func (s *Service) fetchLexicalCandidates(ctx context.Context, apiKeyHash, project, pathPrefix string, filter *compiledSearchFilter, query string, limit int) ([]searchCandidate, error) {
 	if limit <= 0 {
 		return nil, nil
 	}
 	if s.isPostgres {
-		return s.fetchLexicalCandidatesPostgres(ctx, apiKeyHash, project, pathPrefix, query, limit)
+		return s.fetchLexicalCandidatesPostgres(ctx, apiKeyHash, project, pathPrefix, filter, query, limit)
 	}
-	return s.fetchLexicalCandidatesInMemory(ctx, apiKeyHash, project, pathPrefix, query, limit)
+	return s.fetchLexicalCandidatesInMemory(ctx, apiKeyHash, project, pathPrefix, filter, query, limit)
 }
 
 // fetchLexicalCandidatesPostgres uses tsvector ranking to score candidates.
-func (s *Service) fetchLexicalCandidatesPostgres(ctx context.Context, apiKeyHash, project, pathPrefix, query string, limit int) ([]searchCandidate, error) {
+func (s *Service) fetchLexicalCandidatesPostgres(ctx context.Context, apiKeyHash, project, pathPrefix string, filter *compiledSearchFilter, query string, limit int) ([]searchCandidate, error) {
 	owner := systemOwnerFromContext(ctx)
 	args := []any{query, apiKeyHash, owner}
 	statement := `SELECT c.id, c.project, c.file_path, c.start_byte, c.end_byte, c.chunk_content, f.size,
@@ -535,6 +582,9 @@ func (s *Service) fetchLexicalCandidatesPostgres(ctx context.Context, apiKeyHash
 		statement += sqlAndFilePathLike
 		args = append(args, pathPrefix+"%")
 	}
+	filterClause, filterArgs := filter.sqlClause("c.file_path", s.isPostgres)
+	statement += filterClause
+	args = append(args, filterArgs...)
 	statement += " ORDER BY score DESC LIMIT ?"
 	args = append(args, limit)
 
@@ -580,8 +630,8 @@ func (s *Service) fetchLexicalCandidatesPostgres(ctx context.Context, apiKeyHash
 }
 
 // fetchLexicalCandidatesInMemory computes lexical scores in-process.
-func (s *Service) fetchLexicalCandidatesInMemory(ctx context.Context, apiKeyHash, project, pathPrefix, query string, limit int) ([]searchCandidate, error) {
-	chunks, err := s.fetchChunkRows(ctx, apiKeyHash, project, pathPrefix)
+func (s *Service) fetchLexicalCandidatesInMemory(ctx context.Context, apiKeyHash, project, pathPrefix string, filter *compiledSearchFilter, query string, limit int) ([]searchCandidate, error) {
+	chunks, err := s.fetchChunkRows(ctx, apiKeyHash, project, pathPrefix, filter)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
@@ -704,15 +754,16 @@ type chunkEmbeddingRow struct {
 	Embedding []float32
 }
 
-// fetchChunkEmbeddings loads chunks and embeddings for in-memory similarity.
-func (s *Service) fetchChunkEmbeddings(ctx context.Context, apiKeyHash, project, pathPrefix string) ([]chunkEmbeddingRow, error) {
+// fetchChunkEmbeddings loads the chunks passing filter whose embeddings come from model
+// with the given dimensions, for in-memory similarity.
+func (s *Service) fetchChunkEmbeddings(ctx context.Context, apiKeyHash, project, pathPrefix string, filter *compiledSearchFilter, model string, dimensions int) ([]chunkEmbeddingRow, error) {
 	owner := systemOwnerFromContext(ctx)
-	args := []any{apiKeyHash, owner}
+	args := []any{apiKeyHash, owner, model, dimensions}
 	query := `SELECT c.id, c.project, c.file_path, c.start_byte, c.end_byte, f.size, c.chunk_content, e.embedding
 		FROM mcp_file_chunk_embeddings e
 		JOIN mcp_file_chunks c ON c.id = e.chunk_id
 		JOIN mcp_files f ON f.apikey_hash = c.apikey_hash AND f.project = c.project AND f.path = c.file_path AND f.deleted = FALSE AND f.system_owner = c.system_owner
-		WHERE c.apikey_hash = ? AND c.system_owner = ?`
+		WHERE c.apikey_hash = ? AND c.system_owner = ? AND e.model = ? AND e.dimensions = ?`
 	if project != ProjectWildcard {
 		query += " AND c.project = ?"
 		args = append(args, project)
@@ -721,6 +772,9 @@ func (s *Service) fetchChunkEmbeddings(ctx context.Context, apiKeyHash, project,
 		query += sqlAndFilePathLike
 		args = append(args, pathPrefix+"%")
 	}
+	filterClause, filterArgs := filter.sqlClause("c.file_path", s.isPostgres)
+	query += filterClause
+	args = append(args, filterArgs...)
 
 	rows, err := s.db.QueryContext(ctx, rebindSQL(query, s.isPostgres), args...)
 	if err != nil {
@@ -735,6 +789,9 @@ func (s *Service) fetchChunkEmbeddings(ctx context.Context, apiKeyHash, project,
 		if scanErr := rows.Scan(&row.ChunkID, &row.Project, &row.FilePath, &row.StartByte, &row.EndByte, &row.FileSize, &row.Content, &raw); scanErr != nil {
 			return nil, errors.Wrap(scanErr, "scan chunk embedding")
 		}
+		if !filter.matchPath(row.FilePath) {
+			continue
+		}
 		emb, err := decodeEmbedding(raw)
 		if err != nil {
 			return nil, errors.Wrap(err, "decode embedding")
@@ -785,7 +842,8 @@ type chunkRow struct {
 	Content   string
 }
 
-func (s *Service) fetchChunkRows(ctx context.Context, apiKeyHash, project, pathPrefix string) ([]chunkRow, error) {
+// fetchChunkRows loads the chunks passing filter for in-memory lexical scoring.
+func (s *Service) fetchChunkRows(ctx context.Context, apiKeyHash, project, pathPrefix string, filter *compiledSearchFilter) ([]chunkRow, error) {
 	owner := systemOwnerFromContext(ctx)
 	args := []any{apiKeyHash, owner}
 	query := `SELECT c.id, c.project, c.file_path, c.start_byte, c.end_byte, f.size, c.chunk_content
@@ -800,6 +858,9 @@ func (s *Service) fetchChunkRows(ctx context.Context, apiKeyHash, project, pathP
 		query += sqlAndFilePathLike
 		args = append(args, pathPrefix+"%")
 	}
+	filterClause, filterArgs := filter.sqlClause("c.file_path", s.isPostgres)
+	query += filterClause
+	args = append(args, filterArgs...)
 
 	rows, err := s.db.QueryContext(ctx, rebindSQL(query, s.isPostgres), args...)
 	if err != nil {
@@ -813,6 +874,9 @@ func (s *Service) fetchChunkRows(ctx context.Context, apiKeyHash, project, pathP
 		if scanErr := rows.Scan(&row.ID, &row.Project, &row.FilePath, &row.StartByte, &row.EndByte, &row.FileSize, &row.Content); scanErr != nil {
 			return nil, errors.Wrap(scanErr, "scan chunk row")
 		}
+		if !filter.matchPath(row.FilePath) {
+			continue
+		}
 		result = append(result, row)
 	}
 	if err := rows.Err(); err != nil {
@@ -0,0 +1,200 @@
+package files
+
+import (
+	"context"
+	"path"
+	"regexp"
+	"strings"
+
+	errors "github.com/Laisky/errors/v2"
+)
+
+type searchFilterContextKey struct{}
+
+// WithSearchFilter returns a context that makes Search apply filter. file_search reaches
+// the service through memory plugins whose Search signature is fixed, so the filter
+// travels in the context the same way the plugin override does.
+func WithSearchFilter(ctx context.Context, filter SearchFilter) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, searchFilterContextKey{}, filter)
+}
+
+// SearchFilterFromContext returns the filter stored by WithSearchFilter, or the zero
+// filter that matches everything.
+func SearchFilterFromContext(ctx context.Context) SearchFilter {
+	if ctx == nil {
+		return SearchFilter{}
+	}
+	filter, _ := ctx.Value(searchFilterContextKey{}).(SearchFilter)
+	return filter
+}
+
+// searchExtensionRe accepts a file extension with or without its leading dot.
+var searchExtensionRe = regexp.MustCompile(`^\.?[A-Za-z0-9][A-Za-z0-9.+-]*$`)
+
+// compiledSearchFilter is a validated SearchFilter with its globs compiled.
+type compiledSearchFilter struct {
+	SearchFilter
+	// extensions are lowercase and start with a dot.
+	extensions []string
+	include    []*regexp.Regexp
+	// includePrefixes holds the literal path prefix of every include glob, or is nil
+	// when any include glob matches file names at any depth.
+	includePrefixes []string
+	exclude         []*regexp.Regexp
+}
+
+// compileSearchFilter validates filter and compiles its globs with the file_grep
+// path glob syntax.
+func compileSearchFilter(filter SearchFilter) (*compiledSearchFilter, error) {
+	if filter.UpdatedAfter != nil && filter.UpdatedBefore != nil && !filter.UpdatedAfter.Before(*filter.UpdatedBefore) {
+		return nil, NewError(ErrCodeInvalidArgument, "updated_after must be earlier than updated_before", false)
+	}
+
+	compiled := &compiledSearchFilter{SearchFilter: filter}
+	for _, ext := range filter.Extensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if !searchExtensionRe.MatchString(ext) {
+			return nil, NewError(ErrCodeInvalidArgument, "invalid extension filter: "+ext, false)
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		compiled.extensions = append(compiled.extensions, ext)
+	}
+
+	prefixed := true
+	for _, glob := range filter.IncludeGlobs {
+		re, prefix, err := compileSearchGlob(glob)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		compiled.include = append(compiled.include, re)
+		compiled.includePrefixes = append(compiled.includePrefixes, prefix)
+		prefixed = prefixed && prefix != ""
+	}
+	if !prefixed {
+		compiled.includePrefixes = nil
+	}
+	for _, glob := range filter.ExcludeGlobs {
+		re, _, err := compileSearchGlob(glob)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		compiled.exclude = append(compiled.exclude, re)
+	}
+	return compiled, nil
+}
+
+// compileSearchGlob compiles one include or exclude glob, rejecting the empty glob
+// that compilePathGlob would treat as "everything".
+func compileSearchGlob(glob string) (*regexp.Regexp, string, error) {
+	if strings.TrimSpace(glob) == "" {
+		return nil, "", NewError(ErrCodeInvalidPath, "path glob cannot be empty", false)
+	}
+	return compilePathGlob(glob)
+}
+
+// sqlClause returns the conditions and arguments that push the filter into a chunk or
+// file query joined to mcp_files as f, with pathColumn naming the file path column.
+// Postgres evaluates the globs with its regular expression operators; SQLite only gets
+// the literal glob prefixes, and callers finish the job with matchPath.
+func (f *compiledSearchFilter) sqlClause(pathColumn string, isPostgres bool) (string, []any) {
+	var (
+		clause strings.Builder
+		args   []any
+	)
+	if f.UpdatedAfter != nil {
+		clause.WriteString(" AND f.updated_at >= ?")
+		args = append(args, f.UpdatedAfter.UTC())
+	}
+	if f.UpdatedBefore != nil {
+		clause.WriteString(" AND f.updated_at < ?")
+		args = append(args, f.UpdatedBefore.UTC())
+	}
+
+	anyOf := func(column, op string, values []string) {
+		if len(values) == 0 {
+			return
+		}
+		conds := make([]string, 0, len(values))
+		for _, value := range values {
+			conds = append(conds, column+" "+op+" ?")
+			args = append(args, value)
+		}
+		clause.WriteString(" AND (" + strings.Join(conds, " OR ") + ")")
+	}
+
+	suffixes := make([]string, 0, len(f.extensions))
+	for _, ext := range f.extensions {
+		suffixes = append(suffixes, "%"+ext)
+	}
+	anyOf("LOWER("+pathColumn+")", "LIKE", suffixes)
+
+	if isPostgres {
+		patterns := make([]string, 0, len(f.include))
+		for _, re := range f.include {
+			patterns = append(patterns, re.String())
+		}
+		anyOf(pathColumn, "~", patterns)
+		for _, re := range f.exclude {
+			clause.WriteString(" AND " + pathColumn + " !~ ?")
+			args = append(args, re.String())
+		}
+	} else {
+		prefixes := make([]string, 0, len(f.includePrefixes))
+		for _, prefix := range f.includePrefixes {
+			prefixes = append(prefixes, prefix+"%")
+		}
+		anyOf(pathColumn, "LIKE", prefixes)
+	}
+	return clause.String(), args
+}
+
+// matchPath reports whether filePath passes the extension and glob filters.
+func (f *compiledSearchFilter) matchPath(filePath string) bool {
+	if len(f.extensions) > 0 {
+		lower := strings.ToLower(filePath)
+		matched := false
+		for _, want := range f.extensions {
+			if strings.HasSuffix(lower, want) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(f.include) > 0 {
+		matched := false
+		for _, re := range f.include {
+			if re.MatchString(filePath) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for _, re := range f.exclude {
+		if re.MatchString(filePath) {
+			return false
+		}
+	}
+	return true
+}
+
+// searchFacets counts hits per parent directory and per lowercase file extension.
+// Files without an extension are counted under "".
+func searchFacets(paths []string) SearchFacets {
+	facets := SearchFacets{Directories: map[string]int{}, Extensions: map[string]int{}}
+	for _, filePath := range paths {
+		facets.Directories[path.Dir(filePath)]++
+		facets.Extensions[strings.ToLower(path.Ext(filePath))]++
+	}
+	return facets
+}
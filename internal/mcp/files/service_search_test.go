@@ -3,8 +3,10 @@ package files
 import (
 	"context"
 	"encoding/json"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	errors "github.com/Laisky/errors/v2"
 	"github.com/pgvector/pgvector-go"
@@ -658,3 +660,65 @@ func TestSearchFallbackHonorsPathPrefix(t *testing.T) {
 		require.True(t, strings.HasPrefix(chunk.FilePath, "/dir"))
 	}
 }
+
+// TestSearchFilterAndFacets verifies filters narrow indexed and raw-file fallback
+// results and that facets count every match before the limit.
+func TestSearchFilterAndFacets(t *testing.T) {
+	settings := LoadSettingsFromConfig()
+	settings.Search.Enabled = true
+	settings.Security.EncryptionKEKs = map[uint16]string{1: testEncryptionKey()}
+	settings.Index.BatchSize = 10
+	settings.Index.ChunkBytes = 64
+	settings.MaxProjectBytes = 10_000
+
+	svc := newTestService(t, settings, testEmbedder{vector: pgvector.NewVector([]float32{1, 0})}, &memoryCredentialStore{})
+	auth := AuthContext{APIKeyHash: "hash", APIKey: "key", UserIdentity: "user:test"}
+	for _, path := range []string{"/notes/a.md", "/notes/b.MD", "/notes/c.go", "/archive/d.md"} {
+		_, err := svc.Write(context.Background(), auth, "proj", path, "match data", "utf-8", 0, WriteModeAppend)
+		require.NoError(t, err)
+	}
+	search := func(filter SearchFilter, limit int) SearchResult {
+		t.Helper()
+		result, err := svc.Search(WithSearchFilter(context.Background(), filter), auth, "proj", "match", "", limit)
+		require.NoError(t, err)
+		return result
+	}
+	paths := func(result SearchResult) []string {
+		var got []string
+		for _, chunk := range result.Chunks {
+			got = append(got, chunk.FilePath)
+		}
+		sort.Strings(got)
+		return got
+	}
+	filter := SearchFilter{Extensions: []string{"md"}, ExcludeGlobs: []string{"/archive"}}
+
+	// Before indexing, the raw-file fallback applies the filter.
+	require.Equal(t, []string{"/notes/a.md", "/notes/b.MD"}, paths(search(filter, 5)))
+	// The fallback compares min_score with the fraction of query tokens matched.
+	for minScore, want := range map[float64]int{0.5: 4, 0.6: 0} {
+		result, err := svc.Search(WithSearchFilter(context.Background(), SearchFilter{MinScore: minScore}), auth, "proj", "match missing", "", 10)
+		require.NoError(t, err)
+		require.Len(t, result.Chunks, want, minScore)
+	}
+
+	require.NoError(t, svc.NewIndexWorker().RunOnce(context.Background()))
+
+	result := search(filter, 1)
+	require.Len(t, result.Chunks, 1)
+	require.Equal(t, map[string]int{"/notes": 2}, result.Facets.Directories)
+	require.Equal(t, map[string]int{".md": 2}, result.Facets.Extensions)
+
+	result = search(SearchFilter{IncludeGlobs: []string{"*.go", "/archive/**"}}, 5)
+	require.Equal(t, []string{"/archive/d.md", "/notes/c.go"}, paths(result))
+	require.Equal(t, map[string]int{".go": 1, ".md": 1}, result.Facets.Extensions)
+
+	after := time.Date(2026, 2, 10, 0, 0, 0, 0, time.UTC)
+	require.Len(t, search(SearchFilter{UpdatedAfter: &after}, 10).Chunks, 4)
+	after = time.Date(2026, 2, 12, 0, 0, 0, 0, time.UTC)
+	require.Empty(t, search(SearchFilter{UpdatedAfter: &after}, 10).Chunks)
+	require.Empty(t, search(SearchFilter{MinScore: 2}, 10).Chunks)
+
+	_, err := svc.Search(WithSearchFilter(context.Background(), SearchFilter{Extensions: []string{"m%"}}), auth, "proj", "match", "", 5)
+	require.True(t, IsCode(err, ErrCodeInvalidArgument))
+}
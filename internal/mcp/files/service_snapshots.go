@@ -0,0 +1,812 @@
+package files
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+
+	errors "github.com/Laisky/errors/v2"
+	"github.com/Laisky/zap"
+)
+
+const (
+	// snapshotNameMaxLen bounds the length of a snapshot name.
+	snapshotNameMaxLen = 128
+	// autoSnapshotPrefix starts the name of every snapshot taken by the snapshot worker.
+	autoSnapshotPrefix = "auto-"
+)
+
+// snapshotFileRow is the metadata of one active file compared against a restore point.
+type snapshotFileRow struct {
+	ID          uint64
+	Path        string
+	ContentHash string
+	MimeType    string
+	Size        int64
+}
+
+// snapshotTarget is the content one path holds at a restore point. Content is read
+// from the blob keyed by Hash when it has not been loaded yet.
+type snapshotTarget struct {
+	Hash     string
+	MimeType string
+	Content  []byte
+}
+
+// ValidateSnapshotName verifies a snapshot name against length and charset rules.
+func ValidateSnapshotName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return NewError(ErrCodeInvalidArgument, "snapshot name is required", false)
+	}
+	if len(name) > snapshotNameMaxLen {
+		return NewError(ErrCodeInvalidArgument, "snapshot name exceeds max length", false)
+	}
+	for i := 0; i < len(name); i++ {
+		char := name[i]
+		if char != '_' && char != '-' && char != '.' && !isASCIILetterOrDigit(char) {
+			return NewError(ErrCodeInvalidArgument, "snapshot name contains invalid characters", false)
+		}
+	}
+	return nil
+}
+
+// CreateSnapshot records the current state of every file in project under name.
+// Entries share content blobs with the files, so no file bytes are copied. Names
+// starting with "auto-" are reserved for the snapshot worker.
+func (s *Service) CreateSnapshot(ctx context.Context, auth AuthContext, project, name string) (FileSnapshot, error) {
+	if err := s.validateAuth(auth); err != nil {
+		return FileSnapshot{}, errors.WithStack(err)
+	}
+	if err := ValidateProject(project); err != nil {
+		return FileSnapshot{}, errors.WithStack(err)
+	}
+	if err := ValidateSnapshotName(name); err != nil {
+		return FileSnapshot{}, errors.WithStack(err)
+	}
+	if strings.HasPrefix(name, autoSnapshotPrefix) {
+		return FileSnapshot{}, errors.WithStack(NewError(ErrCodeInvalidArgument, "snapshot names starting with 'auto-' are reserved", false))
+	}
+
+	owner := systemOwnerFromContext(ctx)
+	var snapshot FileSnapshot
+	err := s.lockProvider.WithProjectLock(ctx, s.db, s.isPostgres, auth.APIKeyHash, project, s.settings.LockTimeout, func(tx *sql.Tx) error {
+		var count int
+		if err := tx.QueryRowContext(ctx,
+			rebindSQL(`SELECT COUNT(1) FROM mcp_file_snapshots WHERE apikey_hash = ? AND project = ? AND system_owner = ? AND automatic = FALSE`, s.isPostgres),
+			auth.APIKeyHash,
+			project,
+			owner,
+		).Scan(&count); err != nil {
+			return errors.Wrap(err, "count project snapshots")
+		}
+		if count >= s.settings.Snapshot.MaxPerProject {
+			return errors.WithStack(NewError(ErrCodeQuotaExceeded, "project snapshot limit reached", false))
+		}
+
+		created, err := s.createSnapshotTx(ctx, tx, auth.APIKeyHash, project, name, false)
+		if err != nil {
+			return err
+		}
+		snapshot = created
+		return nil
+	})
+	if err != nil {
+		return FileSnapshot{}, errors.WithStack(err)
+	}
+	return snapshot, nil
+}
+
+// createSnapshotTx inserts a snapshot of every active file assuming the project lock
+// is held. Each entry takes its own reference on the file's blob.
+func (s *Service) createSnapshotTx(ctx context.Context, tx *sql.Tx, apiKeyHash, project, name string, automatic bool) (FileSnapshot, error) {
+	owner := systemOwnerFromContext(ctx)
+	if _, err := s.findSnapshot(ctx, tx, apiKeyHash, project, name); err == nil {
+		return FileSnapshot{}, errors.WithStack(NewError(ErrCodeAlreadyExists, "snapshot already exists", false))
+	} else if !IsCode(err, ErrCodeNotFound) {
+		return FileSnapshot{}, err
+	}
+
+	files, err := s.listSnapshotFiles(ctx, tx, apiKeyHash, project, "")
+	if err != nil {
+		return FileSnapshot{}, err
+	}
+
+	now := s.clock()
+	snapshot := FileSnapshot{
+		APIKeyHash: apiKeyHash,
+		Project:    project,
+		Name:       name,
+		Auto:       automatic,
+		CreatedAt:  now,
+	}
+	for _, file := range files {
+		snapshot.FileCount++
+		snapshot.TotalSize += file.Size
+	}
+
+	insertQuery := rebindSQL(`INSERT INTO mcp_file_snapshots (apikey_hash, project, name, automatic, file_count, total_size, system_owner, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, s.isPostgres)
+	insertArgs := []any{apiKeyHash, project, name, automatic, snapshot.FileCount, snapshot.TotalSize, owner, now}
+	if s.isPostgres {
+		if err := tx.QueryRowContext(ctx, insertQuery+" RETURNING id", insertArgs...).Scan(&snapshot.ID); err != nil {
+			return FileSnapshot{}, errors.Wrap(err, "insert snapshot")
+		}
+	} else {
+		res, err := tx.ExecContext(ctx, insertQuery, insertArgs...)
+		if err != nil {
+			return FileSnapshot{}, errors.Wrap(err, "insert snapshot")
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return FileSnapshot{}, errors.Wrap(err, "load inserted snapshot id")
+		}
+		snapshot.ID = uint64(id)
+	}
+
+	for _, file := range files {
+		hash := file.ContentHash
+		if hash == "" {
+			// Rows written before blob storage still hold their bytes inline.
+			var content []byte
+			if err := tx.QueryRowContext(ctx, rebindSQL(`SELECT content FROM mcp_files WHERE id = ?`, s.isPostgres), file.ID).Scan(&content); err != nil {
+				return FileSnapshot{}, errors.Wrap(err, "load inline file content")
+			}
+			if hash, err = referenceBlobTx(ctx, tx, s.isPostgres, content, now); err != nil {
+				return FileSnapshot{}, err
+			}
+		} else if err := retainBlobTx(ctx, tx, s.isPostgres, hash, now); err != nil {
+			return FileSnapshot{}, err
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			rebindSQL(`INSERT INTO mcp_file_snapshot_entries (snapshot_id, path, content_hash, mime_type, size)
+				VALUES (?, ?, ?, ?, ?)`, s.isPostgres),
+			snapshot.ID,
+			file.Path,
+			hash,
+			file.MimeType,
+			file.Size,
+		); err != nil {
+			return FileSnapshot{}, errors.Wrap(err, "insert snapshot entry")
+		}
+	}
+	return snapshot, nil
+}
+
+// ListSnapshots returns the snapshots of a project, newest first.
+func (s *Service) ListSnapshots(ctx context.Context, auth AuthContext, project string) ([]FileSnapshot, error) {
+	if err := s.validateAuth(auth); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := ValidateProject(project); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		rebindSQL(`SELECT id, name, automatic, file_count, total_size, created_at FROM mcp_file_snapshots
+			WHERE apikey_hash = ? AND project = ? AND system_owner = ?
+			ORDER BY created_at DESC, id DESC`, s.isPostgres),
+		auth.APIKeyHash,
+		project,
+		systemOwnerFromContext(ctx),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "query snapshots")
+	}
+	defer func() { _ = rows.Close() }()
+
+	snapshots := []FileSnapshot{}
+	for rows.Next() {
+		var (
+			snapshot  FileSnapshot
+			createdAt any
+		)
+		if err := rows.Scan(&snapshot.ID, &snapshot.Name, &snapshot.Auto, &snapshot.FileCount, &snapshot.TotalSize, &createdAt); err != nil {
+			return nil, errors.Wrap(err, "scan snapshot")
+		}
+		if snapshot.CreatedAt, err = parseDBTime(createdAt); err != nil {
+			return nil, errors.Wrap(err, "parse snapshot created_at")
+		}
+		snapshot.APIKeyHash = auth.APIKeyHash
+		snapshot.Project = project
+		snapshots = append(snapshots, snapshot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate snapshots")
+	}
+	return snapshots, nil
+}
+
+// DeleteSnapshot removes a snapshot and releases the blobs it referenced.
+func (s *Service) DeleteSnapshot(ctx context.Context, auth AuthContext, project, name string) error {
+	if err := s.validateAuth(auth); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := ValidateProject(project); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := ValidateSnapshotName(name); err != nil {
+		return errors.WithStack(err)
+	}
+
+	err := s.lockProvider.WithProjectLock(ctx, s.db, s.isPostgres, auth.APIKeyHash, project, s.settings.LockTimeout, func(tx *sql.Tx) error {
+		snapshot, err := s.findSnapshot(ctx, tx, auth.APIKeyHash, project, name)
+		if err != nil {
+			return err
+		}
+		return s.deleteSnapshotTx(ctx, tx, snapshot.ID)
+	})
+	return errors.WithStack(err)
+}
+
+// deleteSnapshotTx removes one snapshot with its entries and drops their blob references.
+func (s *Service) deleteSnapshotTx(ctx context.Context, tx *sql.Tx, snapshotID uint64) error {
+	rows, err := tx.QueryContext(ctx,
+		rebindSQL(`SELECT content_hash FROM mcp_file_snapshot_entries WHERE snapshot_id = ?`, s.isPostgres),
+		snapshotID,
+	)
+	if err != nil {
+		return errors.Wrap(err, "query snapshot entries for delete")
+	}
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if scanErr := rows.Scan(&hash); scanErr != nil {
+			_ = rows.Close()
+			return errors.Wrap(scanErr, "scan snapshot entry for delete")
+		}
+		hashes = append(hashes, hash)
+	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return errors.Wrap(err, "iterate snapshot entries for delete")
+	}
+	if err := rows.Close(); err != nil {
+		return errors.Wrap(err, "close snapshot entries cursor")
+	}
+
+	if _, err := tx.ExecContext(ctx, rebindSQL(`DELETE FROM mcp_file_snapshot_entries WHERE snapshot_id = ?`, s.isPostgres), snapshotID); err != nil {
+		return errors.Wrap(err, "delete snapshot entries")
+	}
+	if _, err := tx.ExecContext(ctx, rebindSQL(`DELETE FROM mcp_file_snapshots WHERE id = ?`, s.isPostgres), snapshotID); err != nil {
+		return errors.Wrap(err, "delete snapshot")
+	}
+	now := s.clock()
+	for _, hash := range hashes {
+		if err := releaseBlobTx(ctx, tx, s.isPostgres, hash, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DiffSnapshot lists the paths under prefix whose current state differs from the
+// snapshot, ordered by path. An empty prefix compares the whole project.
+func (s *Service) DiffSnapshot(ctx context.Context, auth AuthContext, project, name, prefix string) ([]SnapshotDiffEntry, error) {
+	if err := s.validateAuth(auth); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := ValidateProject(project); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := ValidateSnapshotName(name); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := ValidatePath(prefix); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	snapshot, err := s.findSnapshot(ctx, s.db, auth.APIKeyHash, project, name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	targets, err := s.snapshotTargets(ctx, s.db, snapshot.ID, prefix)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	current, err := s.listSnapshotFiles(ctx, s.db, auth.APIKeyHash, project, prefix)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	diff := []SnapshotDiffEntry{}
+	seen := make(map[string]struct{}, len(current))
+	for _, file := range current {
+		seen[file.Path] = struct{}{}
+		target, ok := targets[file.Path]
+		switch {
+		case !ok:
+			diff = append(diff, SnapshotDiffEntry{Path: file.Path, Change: SnapshotChangeAdded})
+		case target.Hash != file.ContentHash:
+			diff = append(diff, SnapshotDiffEntry{Path: file.Path, Change: SnapshotChangeModified})
+		}
+	}
+	for path := range targets {
+		if _, ok := seen[path]; !ok {
+			diff = append(diff, SnapshotDiffEntry{Path: path, Change: SnapshotChangeDeleted})
+		}
+	}
+	sort.Slice(diff, func(i, j int) bool { return diff[i].Path < diff[j].Path })
+	return diff, nil
+}
+
+// RestoreSnapshot returns every path under prefix to its state in the snapshot within
+// one transaction: files created since are deleted and changed files are rewritten.
+// Each overwritten or deleted file keeps its prior content as a version.
+func (s *Service) RestoreSnapshot(ctx context.Context, auth AuthContext, project, name, prefix string) (SnapshotRestoreResult, error) {
+	if err := s.validateAuth(auth); err != nil {
+		return SnapshotRestoreResult{}, errors.WithStack(err)
+	}
+	if err := ValidateProject(project); err != nil {
+		return SnapshotRestoreResult{}, errors.WithStack(err)
+	}
+	if err := ValidateSnapshotName(name); err != nil {
+		return SnapshotRestoreResult{}, errors.WithStack(err)
+	}
+	if err := ValidatePath(prefix); err != nil {
+		return SnapshotRestoreResult{}, errors.WithStack(err)
+	}
+
+	var result SnapshotRestoreResult
+	err := s.lockProvider.WithProjectLock(ctx, s.db, s.isPostgres, auth.APIKeyHash, project, s.settings.LockTimeout, func(tx *sql.Tx) error {
+		snapshot, err := s.findSnapshot(ctx, tx, auth.APIKeyHash, project, name)
+		if err != nil {
+			return err
+		}
+		targets, err := s.snapshotTargets(ctx, tx, snapshot.ID, prefix)
+		if err != nil {
+			return err
+		}
+		result, err = s.restoreTargetsTx(ctx, tx, auth, project, prefix, targets)
+		return err
+	})
+	if err != nil {
+		return SnapshotRestoreResult{}, errors.WithStack(err)
+	}
+
+	s.notifyRestore(ctx, auth, project, result)
+	return result, nil
+}
+
+// RestoreToTime returns every path under prefix to its state at the given moment
+// within one transaction. The state is rebuilt from file rows and version history,
+// so the moment must lie inside the version retention window. Files renamed since
+// are restored under their current path, and deleted files already purged by
+// garbage collection cannot be recovered.
+func (s *Service) RestoreToTime(ctx context.Context, auth AuthContext, project string, at time.Time, prefix string) (SnapshotRestoreResult, error) {
+	if err := s.validateAuth(auth); err != nil {
+		return SnapshotRestoreResult{}, errors.WithStack(err)
+	}
+	if err := ValidateProject(project); err != nil {
+		return SnapshotRestoreResult{}, errors.WithStack(err)
+	}
+	if err := ValidatePath(prefix); err != nil {
+		return SnapshotRestoreResult{}, errors.WithStack(err)
+	}
+	now := s.clock()
+	if at.IsZero() || at.After(now) {
+		return SnapshotRestoreResult{}, errors.WithStack(NewError(ErrCodeInvalidArgument, "restore time must not be in the future", false))
+	}
+	if now.Sub(at) > versionRetentionWindow {
+		return SnapshotRestoreResult{}, errors.WithStack(NewError(ErrCodeInvalidArgument, "restore time is older than the version retention window", false))
+	}
+
+	var result SnapshotRestoreResult
+	err := s.lockProvider.WithProjectLock(ctx, s.db, s.isPostgres, auth.APIKeyHash, project, s.settings.LockTimeout, func(tx *sql.Tx) error {
+		targets, err := s.pointInTimeTargetsTx(ctx, tx, auth.APIKeyHash, project, at, prefix)
+		if err != nil {
+			return err
+		}
+		result, err = s.restoreTargetsTx(ctx, tx, auth, project, prefix, targets)
+		return err
+	})
+	if err != nil {
+		return SnapshotRestoreResult{}, errors.WithStack(err)
+	}
+
+	s.notifyRestore(ctx, auth, project, result)
+	return result, nil
+}
+
+// restoreTargetsTx makes the files under prefix match targets assuming the project
+// lock is held. Deletions run first so a restored file can take the place of a
+// directory created since the restore point.
+func (s *Service) restoreTargetsTx(ctx context.Context, tx *sql.Tx, auth AuthContext, project, prefix string, targets map[string]snapshotTarget) (SnapshotRestoreResult, error) {
+	current, err := s.listSnapshotFiles(ctx, tx, auth.APIKeyHash, project, prefix)
+	if err != nil {
+		return SnapshotRestoreResult{}, err
+	}
+
+	result := SnapshotRestoreResult{Restored: []string{}, Deleted: []string{}}
+	currentHashes := make(map[string]string, len(current))
+	for _, file := range current {
+		currentHashes[file.Path] = file.ContentHash
+		if _, ok := targets[file.Path]; !ok {
+			result.Deleted = append(result.Deleted, file.Path)
+		}
+	}
+	if len(result.Deleted) > 0 {
+		stale, err := s.loadFilesForSnapshotTx(ctx, tx, auth.APIKeyHash, project, result.Deleted)
+		if err != nil {
+			return SnapshotRestoreResult{}, err
+		}
+		if err := s.softDeleteFilesTx(ctx, tx, auth.APIKeyHash, project, stale, s.clock()); err != nil {
+			return SnapshotRestoreResult{}, err
+		}
+	}
+
+	paths := make([]string, 0, len(targets))
+	for path := range targets {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		target := targets[path]
+		if hash, ok := currentHashes[path]; ok && hash == target.Hash {
+			result.Unchanged++
+			continue
+		}
+		content := target.Content
+		if content == nil {
+			if content, err = loadBlobContentTx(ctx, tx, s.isPostgres, target.Hash); err != nil {
+				return SnapshotRestoreResult{}, err
+			}
+		}
+		if _, err := s.writeWithinTx(ctx, tx, auth, project, path, content, WriteModeTruncate, 0, int64(len(content)), WriteOpts{MimeType: target.MimeType}); err != nil {
+			return SnapshotRestoreResult{}, err
+		}
+		result.Restored = append(result.Restored, path)
+	}
+	return result, nil
+}
+
+// notifyRestore reports every path a restore rewrote or deleted.
+func (s *Service) notifyRestore(ctx context.Context, auth AuthContext, project string, result SnapshotRestoreResult) {
+	changes := make([]FileChange, 0, len(result.Restored)+len(result.Deleted))
+	for _, path := range result.Restored {
+		changes = append(changes, FileChange{APIKeyHash: auth.APIKeyHash, Project: project, Path: path})
+	}
+	for _, path := range result.Deleted {
+		changes = append(changes, FileChange{APIKeyHash: auth.APIKeyHash, Project: project, Path: path, Deleted: true})
+	}
+	s.notifyChanges(ctx, changes...)
+}
+
+// findSnapshot loads one snapshot of a project by name.
+func (s *Service) findSnapshot(ctx context.Context, q sqlQuerier, apiKeyHash, project, name string) (FileSnapshot, error) {
+	var (
+		snapshot  FileSnapshot
+		createdAt any
+	)
+	err := q.QueryRowContext(ctx,
+		rebindSQL(`SELECT id, automatic, file_count, total_size, created_at FROM mcp_file_snapshots
+			WHERE apikey_hash = ? AND project = ? AND system_owner = ? AND name = ?
+			LIMIT 1`, s.isPostgres),
+		apiKeyHash,
+		project,
+		systemOwnerFromContext(ctx),
+		name,
+	).Scan(&snapshot.ID, &snapshot.Auto, &snapshot.FileCount, &snapshot.TotalSize, &createdAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return FileSnapshot{}, errors.WithStack(NewError(ErrCodeNotFound, "snapshot not found", false))
+		}
+		return FileSnapshot{}, errors.Wrap(err, "query snapshot")
+	}
+	if snapshot.CreatedAt, err = parseDBTime(createdAt); err != nil {
+		return FileSnapshot{}, errors.Wrap(err, "parse snapshot created_at")
+	}
+	snapshot.APIKeyHash = apiKeyHash
+	snapshot.Project = project
+	snapshot.Name = name
+	return snapshot, nil
+}
+
+// snapshotTargets returns the entries of a snapshot under prefix keyed by path.
+func (s *Service) snapshotTargets(ctx context.Context, q sqlQuerier, snapshotID uint64, prefix string) (map[string]snapshotTarget, error) {
+	rows, err := q.QueryContext(ctx,
+		rebindSQL(`SELECT path, content_hash, mime_type FROM mcp_file_snapshot_entries WHERE snapshot_id = ?`, s.isPostgres),
+		snapshotID,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "query snapshot entries")
+	}
+	defer func() { _ = rows.Close() }()
+
+	targets := make(map[string]snapshotTarget)
+	for rows.Next() {
+		var (
+			path   string
+			target snapshotTarget
+		)
+		if err := rows.Scan(&path, &target.Hash, &target.MimeType); err != nil {
+			return nil, errors.Wrap(err, "scan snapshot entry")
+		}
+		if pathWithinPrefix(path, prefix) {
+			targets[path] = target
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate snapshot entries")
+	}
+	return targets, nil
+}
+
+// listSnapshotFiles returns the metadata of the active files under prefix.
+func (s *Service) listSnapshotFiles(ctx context.Context, q sqlQuerier, apiKeyHash, project, prefix string) ([]snapshotFileRow, error) {
+	rows, err := q.QueryContext(ctx,
+		rebindSQL(`SELECT id, path, content_hash, mime_type, size FROM mcp_files
+			WHERE apikey_hash = ? AND project = ? AND deleted = FALSE AND system_owner = ? AND (path = ? OR path LIKE ?)
+			ORDER BY path ASC`, s.isPostgres),
+		apiKeyHash,
+		project,
+		systemOwnerFromContext(ctx),
+		prefix,
+		buildPathPrefix(prefix),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "query files for snapshot")
+	}
+	defer func() { _ = rows.Close() }()
+
+	var files []snapshotFileRow
+	for rows.Next() {
+		var file snapshotFileRow
+		if err := rows.Scan(&file.ID, &file.Path, &file.ContentHash, &file.MimeType, &file.Size); err != nil {
+			return nil, errors.Wrap(err, "scan file for snapshot")
+		}
+		files = append(files, file)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate files for snapshot")
+	}
+	return files, nil
+}
+
+// pointInTimeTargetsTx rebuilds the files under prefix as they were at the given
+// moment. A row was live then if it had been created and not yet deleted. Every later
+// write or delete stored the prior content as a version, so a row changed since holds
+// its old content in the earliest version recorded after that moment.
+func (s *Service) pointInTimeTargetsTx(ctx context.Context, tx *sql.Tx, apiKeyHash, project string, at time.Time, prefix string) (map[string]snapshotTarget, error) {
+	owner := systemOwnerFromContext(ctx)
+	rows, err := tx.QueryContext(ctx,
+		rebindSQL(`SELECT path, content_hash, mime_type, updated_at FROM mcp_files
+			WHERE apikey_hash = ? AND project = ? AND system_owner = ? AND created_at <= ?
+				AND (deleted = FALSE OR deleted_at > ?)
+			ORDER BY path ASC`, s.isPostgres),
+		apiKeyHash,
+		project,
+		owner,
+		at,
+		at,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "query files at restore time")
+	}
+
+	type liveRow struct {
+		path      string
+		target    snapshotTarget
+		updatedAt time.Time
+	}
+	var live []liveRow
+	for rows.Next() {
+		var (
+			row       liveRow
+			updatedAt any
+		)
+		if scanErr := rows.Scan(&row.path, &row.target.Hash, &row.target.MimeType, &updatedAt); scanErr != nil {
+			_ = rows.Close()
+			return nil, errors.Wrap(scanErr, "scan file at restore time")
+		}
+		if row.updatedAt, err = parseDBTime(updatedAt); err != nil {
+			_ = rows.Close()
+			return nil, errors.Wrap(err, "parse file updated_at")
+		}
+		if pathWithinPrefix(row.path, prefix) {
+			live = append(live, row)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return nil, errors.Wrap(err, "iterate files at restore time")
+	}
+	if err := rows.Close(); err != nil {
+		return nil, errors.Wrap(err, "close files cursor at restore time")
+	}
+
+	targets := make(map[string]snapshotTarget, len(live))
+	for _, row := range live {
+		target := row.target
+		if row.updatedAt.After(at) {
+			var content []byte
+			err := tx.QueryRowContext(ctx,
+				rebindSQL(`SELECT COALESCE(b.content, v.content) FROM mcp_file_versions v
+					LEFT JOIN mcp_file_blobs b ON b.hash = v.content_hash
+					WHERE v.apikey_hash = ? AND v.project = ? AND v.path = ? AND v.system_owner = ? AND v.created_at > ?
+					ORDER BY v.created_at ASC, v.id ASC
+					LIMIT 1`, s.isPostgres),
+				apiKeyHash,
+				project,
+				row.path,
+				owner,
+				at,
+			).Scan(&content)
+			switch {
+			case err == nil:
+				target.Hash = blobHash(content)
+				target.Content = content
+			case errors.Is(err, sql.ErrNoRows):
+				// Only metadata such as the path changed, so the content is still current.
+			default:
+				return nil, errors.Wrap(err, "query version at restore time")
+			}
+		}
+		targets[row.path] = target
+	}
+	return targets, nil
+}
+
+// RunAutoSnapshots snapshots every user project changed since its latest automatic
+// snapshot, then drops automatic snapshots beyond Settings.Snapshot.AutoRetain. It
+// returns the number of snapshots taken. A project that fails is logged and skipped,
+// and its error is joined into the returned error.
+func (s *Service) RunAutoSnapshots(ctx context.Context) (int, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT apikey_hash, project, MAX(updated_at) FROM mcp_files WHERE system_owner = '' GROUP BY apikey_hash, project`)
+	if err != nil {
+		return 0, errors.Wrap(err, "query changed projects")
+	}
+	type projectRow struct {
+		apiKeyHash string
+		project    string
+		changedAt  time.Time
+	}
+	var projects []projectRow
+	for rows.Next() {
+		var (
+			row       projectRow
+			changedAt any
+		)
+		if scanErr := rows.Scan(&row.apiKeyHash, &row.project, &changedAt); scanErr != nil {
+			_ = rows.Close()
+			return 0, errors.Wrap(scanErr, "scan changed project")
+		}
+		if row.changedAt, err = parseDBTime(changedAt); err != nil {
+			_ = rows.Close()
+			return 0, errors.Wrap(err, "parse project updated_at")
+		}
+		projects = append(projects, row)
+	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return 0, errors.Wrap(err, "iterate changed projects")
+	}
+	if err := rows.Close(); err != nil {
+		return 0, errors.Wrap(err, "close changed projects cursor")
+	}
+
+	var (
+		taken int
+		errs  []error
+	)
+	for _, row := range projects {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, errors.WithStack(err))
+			break
+		}
+		ok, err := s.autoSnapshotProject(ctx, row.apiKeyHash, row.project, row.changedAt)
+		if err != nil {
+			s.logger.Warn("automatic project snapshot failed", zap.String("project", row.project), zap.Error(err))
+			errs = append(errs, errors.Wrapf(err, "snapshot project %s", row.project))
+			continue
+		}
+		if ok {
+			taken++
+		}
+	}
+	return taken, errors.Join(errs...)
+}
+
+// autoSnapshotProject takes an automatic snapshot of one project when it changed after
+// its latest automatic snapshot, and reports whether a snapshot was taken.
+func (s *Service) autoSnapshotProject(ctx context.Context, apiKeyHash, project string, changedAt time.Time) (bool, error) {
+	var lastAt any
+	if err := s.db.QueryRowContext(ctx,
+		rebindSQL(`SELECT MAX(created_at) FROM mcp_file_snapshots WHERE apikey_hash = ? AND project = ? AND system_owner = '' AND automatic = TRUE`, s.isPostgres),
+		apiKeyHash,
+		project,
+	).Scan(&lastAt); err != nil {
+		return false, errors.Wrap(err, "query latest automatic snapshot")
+	}
+	if lastAt != nil {
+		last, err := parseDBTime(lastAt)
+		if err != nil {
+			return false, errors.Wrap(err, "parse automatic snapshot created_at")
+		}
+		if !changedAt.After(last) {
+			return false, nil
+		}
+	}
+
+	err := s.lockProvider.WithProjectLock(ctx, s.db, s.isPostgres, apiKeyHash, project, s.settings.LockTimeout, func(tx *sql.Tx) error {
+		name := autoSnapshotPrefix + s.clock().UTC().Format("20060102T150405Z")
+		if _, err := s.createSnapshotTx(ctx, tx, apiKeyHash, project, name, true); err != nil {
+			return err
+		}
+		return s.pruneAutoSnapshotsTx(ctx, tx, apiKeyHash, project)
+	})
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	return true, nil
+}
+
+// pruneAutoSnapshotsTx deletes the automatic snapshots of a project beyond the newest
+// Settings.Snapshot.AutoRetain.
+func (s *Service) pruneAutoSnapshotsTx(ctx context.Context, tx *sql.Tx, apiKeyHash, project string) error {
+	rows, err := tx.QueryContext(ctx,
+		rebindSQL(`SELECT id FROM mcp_file_snapshots WHERE apikey_hash = ? AND project = ? AND system_owner = ? AND automatic = TRUE
+			ORDER BY created_at DESC, id DESC`, s.isPostgres),
+		apiKeyHash,
+		project,
+		systemOwnerFromContext(ctx),
+	)
+	if err != nil {
+		return errors.Wrap(err, "query automatic snapshots")
+	}
+	var ids []uint64
+	for rows.Next() {
+		var id uint64
+		if scanErr := rows.Scan(&id); scanErr != nil {
+			_ = rows.Close()
+			return errors.Wrap(scanErr, "scan automatic snapshot")
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return errors.Wrap(err, "iterate automatic snapshots")
+	}
+	if err := rows.Close(); err != nil {
+		return errors.Wrap(err, "close automatic snapshots cursor")
+	}
+
+	for i := s.settings.Snapshot.AutoRetain; i < len(ids); i++ {
+		if err := s.deleteSnapshotTx(ctx, tx, ids[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartSnapshotWorker periodically runs RunAutoSnapshots until ctx is canceled.
+func (s *Service) StartSnapshotWorker(ctx context.Context) {
+	if s == nil || s.settings.Snapshot.AutoInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.settings.Snapshot.AutoInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				runCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Minute) //nolint:contextcheck // detached context for background snapshots
+				taken, err := s.RunAutoSnapshots(runCtx)
+				cancel()
+				if err != nil {
+					s.logger.Warn("automatic project snapshots failed", zap.Int("snapshots", taken), zap.Error(err))
+					continue
+				}
+				if taken > 0 {
+					s.logger.Info("automatic project snapshots completed", zap.Int("snapshots", taken))
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,220 @@
+package files
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	errors "github.com/Laisky/errors/v2"
+	"github.com/stretchr/testify/require"
+)
+
+// readTestFile returns the current content of path, failing the test on error.
+func readTestFile(t *testing.T, svc *Service, auth AuthContext, path string) string {
+	t.Helper()
+	read, err := svc.Read(context.Background(), auth, "proj", path, 0, -1)
+	require.NoError(t, err)
+	return read.Content
+}
+
+// TestSnapshot_DiffAndRestore verifies a snapshot survives corruption of many files.
+func TestSnapshot_DiffAndRestore(t *testing.T) {
+	svc := newVersionsTestService(t)
+	auth := versionsTestAuth()
+	ctx := context.Background()
+
+	for path, content := range map[string]string{
+		"/notes/a.md": "alpha",
+		"/notes/b.md": "beta",
+		"/plan.md":    "plan",
+	} {
+		_, err := svc.Write(ctx, auth, "proj", path, content, "utf-8", 0, WriteModeTruncate)
+		require.NoError(t, err)
+	}
+
+	snapshot, err := svc.CreateSnapshot(ctx, auth, "proj", "before-agent")
+	require.NoError(t, err)
+	require.EqualValues(t, 3, snapshot.FileCount)
+	require.EqualValues(t, 2, blobRefCount(t, svc, "plan"))
+	_, err = svc.CreateSnapshot(ctx, auth, "proj", "before-agent")
+	require.True(t, IsCode(err, ErrCodeAlreadyExists))
+	_, err = svc.CreateSnapshot(ctx, auth, "proj", "auto-mine")
+	require.True(t, IsCode(err, ErrCodeInvalidArgument))
+
+	_, err = svc.Write(ctx, auth, "proj", "/notes/a.md", "corrupted", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+	_, err = svc.Delete(ctx, auth, "proj", "/notes/b.md", false)
+	require.NoError(t, err)
+	_, err = svc.Write(ctx, auth, "proj", "/notes/junk.md", "junk", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+
+	diff, err := svc.DiffSnapshot(ctx, auth, "proj", "before-agent", "")
+	require.NoError(t, err)
+	require.Equal(t, []SnapshotDiffEntry{
+		{Path: "/notes/a.md", Change: SnapshotChangeModified},
+		{Path: "/notes/b.md", Change: SnapshotChangeDeleted},
+		{Path: "/notes/junk.md", Change: SnapshotChangeAdded},
+	}, diff)
+
+	result, err := svc.RestoreSnapshot(ctx, auth, "proj", "before-agent", "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"/notes/a.md", "/notes/b.md"}, result.Restored)
+	require.Equal(t, []string{"/notes/junk.md"}, result.Deleted)
+	require.Equal(t, 1, result.Unchanged)
+
+	require.Equal(t, "alpha", readTestFile(t, svc, auth, "/notes/a.md"))
+	require.Equal(t, "beta", readTestFile(t, svc, auth, "/notes/b.md"))
+	stat, err := svc.Stat(ctx, auth, "proj", "/notes/junk.md")
+	require.NoError(t, err)
+	require.False(t, stat.Exists)
+	diff, err = svc.DiffSnapshot(ctx, auth, "proj", "before-agent", "")
+	require.NoError(t, err)
+	require.Empty(t, diff)
+
+	// The corrupted content is kept as a version, so the restore itself can be undone.
+	versions, err := svc.ListVersions(ctx, auth, "proj", "/notes/a.md")
+	require.NoError(t, err)
+	require.NotEmpty(t, versions)
+
+	require.NoError(t, svc.DeleteSnapshot(ctx, auth, "proj", "before-agent"))
+	snapshots, err := svc.ListSnapshots(ctx, auth, "proj")
+	require.NoError(t, err)
+	require.Empty(t, snapshots)
+	require.EqualValues(t, 1, blobRefCount(t, svc, "plan"))
+}
+
+// TestSnapshot_RestorePrefix verifies a prefix restore leaves other paths alone.
+func TestSnapshot_RestorePrefix(t *testing.T) {
+	svc := newVersionsTestService(t)
+	auth := versionsTestAuth()
+	ctx := context.Background()
+
+	_, err := svc.Write(ctx, auth, "proj", "/notes/a.md", "alpha", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+	_, err = svc.Write(ctx, auth, "proj", "/other.md", "other", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+	_, err = svc.CreateSnapshot(ctx, auth, "proj", "base")
+	require.NoError(t, err)
+
+	_, err = svc.Write(ctx, auth, "proj", "/notes/a.md", "changed", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+	_, err = svc.Write(ctx, auth, "proj", "/other.md", "changed too", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+
+	result, err := svc.RestoreSnapshot(ctx, auth, "proj", "base", "/notes")
+	require.NoError(t, err)
+	require.Equal(t, []string{"/notes/a.md"}, result.Restored)
+	require.Equal(t, "alpha", readTestFile(t, svc, auth, "/notes/a.md"))
+	require.Equal(t, "changed too", readTestFile(t, svc, auth, "/other.md"))
+
+	_, err = svc.RestoreSnapshot(ctx, auth, "proj", "missing", "")
+	require.True(t, IsCode(err, ErrCodeNotFound))
+}
+
+// TestSnapshot_RestoreToTime verifies the project is rebuilt from rows and versions.
+func TestSnapshot_RestoreToTime(t *testing.T) {
+	svc := newVersionsTestService(t)
+	start := time.Date(2026, 2, 11, 0, 0, 0, 0, time.UTC)
+	svc.clock = steppingClock(start, time.Minute)
+	auth := versionsTestAuth()
+	ctx := context.Background()
+
+	_, err := svc.Write(ctx, auth, "proj", "/a.md", "a1", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+	_, err = svc.Write(ctx, auth, "proj", "/b.md", "b1", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+	checkpoint := start.Add(30 * time.Minute)
+
+	svc.clock = steppingClock(checkpoint, time.Minute)
+	_, err = svc.Write(ctx, auth, "proj", "/a.md", "a2", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+	_, err = svc.Write(ctx, auth, "proj", "/a.md", "a3", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+	_, err = svc.Delete(ctx, auth, "proj", "/b.md", false)
+	require.NoError(t, err)
+	_, err = svc.Write(ctx, auth, "proj", "/c.md", "c1", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+
+	result, err := svc.RestoreToTime(ctx, auth, "proj", checkpoint, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"/a.md", "/b.md"}, result.Restored)
+	require.Equal(t, []string{"/c.md"}, result.Deleted)
+	require.Equal(t, "a1", readTestFile(t, svc, auth, "/a.md"))
+	require.Equal(t, "b1", readTestFile(t, svc, auth, "/b.md"))
+
+	_, err = svc.RestoreToTime(ctx, auth, "proj", checkpoint.Add(-8*24*time.Hour), "")
+	require.True(t, IsCode(err, ErrCodeInvalidArgument))
+	_, err = svc.RestoreToTime(ctx, auth, "proj", checkpoint.Add(time.Hour), "")
+	require.True(t, IsCode(err, ErrCodeInvalidArgument))
+}
+
+// TestSnapshot_AutoSnapshots verifies only changed projects are snapshotted and old
+// automatic snapshots are pruned.
+func TestSnapshot_AutoSnapshots(t *testing.T) {
+	svc := newVersionsTestService(t)
+	svc.clock = steppingClock(time.Date(2026, 2, 11, 0, 0, 0, 0, time.UTC), time.Minute)
+	svc.settings.Snapshot.AutoRetain = 2
+	auth := versionsTestAuth()
+	ctx := context.Background()
+
+	_, err := svc.Write(ctx, auth, "proj", "/a.md", "one", "utf-8", 0, WriteModeTruncate)
+	require.NoError(t, err)
+	taken, err := svc.RunAutoSnapshots(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, taken)
+	taken, err = svc.RunAutoSnapshots(ctx)
+	require.NoError(t, err)
+	require.Zero(t, taken)
+
+	for _, content := range []string{"two", "three"} {
+		_, err = svc.Write(ctx, auth, "proj", "/a.md", content, "utf-8", 0, WriteModeTruncate)
+		require.NoError(t, err)
+		taken, err = svc.RunAutoSnapshots(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 1, taken)
+	}
+
+	snapshots, err := svc.ListSnapshots(ctx, auth, "proj")
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	for _, snapshot := range snapshots {
+		require.True(t, snapshot.Auto)
+		require.Contains(t, snapshot.Name, autoSnapshotPrefix)
+	}
+}
+
+// failingProjectLock fails the project lock of one project and delegates the rest.
+type failingProjectLock struct {
+	DefaultLockProvider
+	project string
+}
+
+func (l failingProjectLock) WithProjectLock(ctx context.Context, db *sql.DB, isPostgres bool, apiKeyHash, project string, timeout time.Duration, fn func(tx *sql.Tx) error) error {
+	if project == l.project {
+		return errors.New("lock unavailable")
+	}
+	return l.DefaultLockProvider.WithProjectLock(ctx, db, isPostgres, apiKeyHash, project, timeout, fn)
+}
+
+// TestSnapshot_AutoSnapshotsContinuePastFailures verifies one failing project does not
+// block automatic snapshots of the others.
+func TestSnapshot_AutoSnapshotsContinuePastFailures(t *testing.T) {
+	svc := newVersionsTestService(t)
+	auth := versionsTestAuth()
+	ctx := context.Background()
+	for _, project := range []string{"alpha", "beta", "gamma"} {
+		_, err := svc.Write(ctx, auth, project, "/a.md", "one", "utf-8", 0, WriteModeTruncate)
+		require.NoError(t, err)
+	}
+	svc.lockProvider = failingProjectLock{project: "beta"}
+
+	taken, err := svc.RunAutoSnapshots(ctx)
+	require.ErrorContains(t, err, "snapshot project beta")
+	require.Equal(t, 2, taken)
+	for _, project := range []string{"alpha", "gamma"} {
+		snapshots, err := svc.ListSnapshots(ctx, auth, project)
+		require.NoError(t, err)
+		require.Len(t, snapshots, 1)
+	}
+}
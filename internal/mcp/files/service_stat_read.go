@@ -3,6 +3,7 @@ package files
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
 	"time"
 
 	errors "github.com/Laisky/errors/v2"
@@ -42,6 +43,8 @@ func (s *Service) Stat(ctx context.Context, auth AuthContext, project, path stri
 			Exists:    true,
 			Type:      FileTypeFile,
 			Size:      file.Size,
+			MimeType:  file.MimeType,
+			Revision:  file.Revision,
 			CreatedAt: file.CreatedAt,
 			UpdatedAt: file.UpdatedAt,
 		}, nil
@@ -101,15 +104,23 @@ func (s *Service) Read(ctx context.Context, auth AuthContext, project, path stri
 
 	data := file.Content
 	if offset >= int64(len(data)) {
-		return ReadResult{Content: "", ContentEncoding: "utf-8"}, nil
+		return ReadResult{Content: "", ContentEncoding: ContentEncodingUTF8, MimeType: file.MimeType, Revision: file.Revision}, nil
 	}
 	end := int64(len(data))
 	if length >= 0 && offset+length < end {
 		end = offset + length
 	}
+	if !IsTextMimeType(file.MimeType) {
+		return ReadResult{
+			Content:         base64.StdEncoding.EncodeToString(data[offset:end]),
+			ContentEncoding: ContentEncodingBase64,
+			MimeType:        file.MimeType,
+			Revision:        file.Revision,
+		}, nil
+	}
 	payload := string(data[offset:end])
 
-	return ReadResult{Content: payload, ContentEncoding: "utf-8"}, nil
+	return ReadResult{Content: payload, ContentEncoding: ContentEncodingUTF8, MimeType: file.MimeType, Revision: file.Revision}, nil
 }
 
 // findActiveFile loads a non-deleted file row by path.
@@ -117,9 +128,10 @@ func (s *Service) findActiveFile(ctx context.Context, apiKeyHash, project, path
 	owner := systemOwnerFromContext(ctx)
 	var file File
 	err := s.db.QueryRowContext(ctx,
-		rebindSQL(`SELECT id, apikey_hash, project, path, content, size, created_at, updated_at, deleted, deleted_at
-			FROM mcp_files
-			WHERE apikey_hash = ? AND project = ? AND path = ? AND deleted = FALSE AND system_owner = ?
+		rebindSQL(`SELECT f.id, f.apikey_hash, f.project, f.path, COALESCE(b.content, f.content), f.content_hash, f.mime_type, f.revision, f.size, f.created_at, f.updated_at, f.deleted, f.deleted_at
+			FROM mcp_files f
+			LEFT JOIN mcp_file_blobs b ON b.hash = f.content_hash
+			WHERE f.apikey_hash = ? AND f.project = ? AND f.path = ? AND f.deleted = FALSE AND f.system_owner = ?
 			LIMIT 1`, s.isPostgres),
 		apiKeyHash,
 		project,
@@ -131,6 +143,9 @@ func (s *Service) findActiveFile(ctx context.Context, apiKeyHash, project, path
 		&file.Project,
 		&file.Path,
 		&file.Content,
+		&file.ContentHash,
+		&file.MimeType,
+		&file.Revision,
 		&file.Size,
 		&file.CreatedAt,
 		&file.UpdatedAt,
@@ -99,16 +99,17 @@ func (s *Service) ReadVersion(ctx context.Context, auth AuthContext, project, pa
 		sourceID  sql.NullInt64
 	)
 	err := s.db.QueryRowContext(ctx,
-		rebindSQL(`SELECT id, content, size, created_at, source_file_id
-			FROM mcp_file_versions
-			WHERE apikey_hash = ? AND project = ? AND path = ? AND id = ? AND system_owner = ?
+		rebindSQL(`SELECT v.id, COALESCE(b.content, v.content), v.content_hash, v.size, v.created_at, v.source_file_id
+			FROM mcp_file_versions v
+			LEFT JOIN mcp_file_blobs b ON b.hash = v.content_hash
+			WHERE v.apikey_hash = ? AND v.project = ? AND v.path = ? AND v.id = ? AND v.system_owner = ?
 			LIMIT 1`, s.isPostgres),
 		auth.APIKeyHash,
 		project,
 		path,
 		versionID,
 		owner,
-	).Scan(&row.ID, &row.Content, &row.Size, &createdAt, &sourceID)
+	).Scan(&row.ID, &row.Content, &row.ContentHash, &row.Size, &createdAt, &sourceID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return FileVersion{}, errors.WithStack(NewError(ErrCodeNotFound, "version not found", false))
@@ -148,13 +149,14 @@ func (s *Service) RestoreVersion(ctx context.Context, auth AuthContext, project,
 	}
 
 	owner := systemOwnerFromContext(ctx)
-	var bytesWritten int64
+	var result WriteResult
 	err := s.lockProvider.WithProjectLock(ctx, s.db, s.isPostgres, auth.APIKeyHash, project, s.settings.LockTimeout, func(tx *sql.Tx) error {
 		var content []byte
 		var size int64
 		err := tx.QueryRowContext(ctx,
-			rebindSQL(`SELECT content, size FROM mcp_file_versions
-				WHERE apikey_hash = ? AND project = ? AND path = ? AND id = ? AND system_owner = ?
+			rebindSQL(`SELECT COALESCE(b.content, v.content), v.size FROM mcp_file_versions v
+				LEFT JOIN mcp_file_blobs b ON b.hash = v.content_hash
+				WHERE v.apikey_hash = ? AND v.project = ? AND v.path = ? AND v.id = ? AND v.system_owner = ?
 				LIMIT 1`, s.isPostgres),
 			auth.APIKeyHash,
 			project,
@@ -173,32 +175,40 @@ func (s *Service) RestoreVersion(ctx context.Context, auth AuthContext, project,
 			return errors.WithStack(err)
 		}
 
-		n, err := s.writeWithinTx(ctx, tx, auth, project, path, content, WriteModeTruncate, 0, size, WriteOpts{SystemOwner: owner})
+		written, err := s.writeWithinTx(ctx, tx, auth, project, path, content, WriteModeTruncate, 0, size, WriteOpts{SystemOwner: owner})
 		if err != nil {
 			return err
 		}
-		bytesWritten = n
+		result = written
 		return nil
 	})
 	if err != nil {
 		return WriteResult{}, errors.WithStack(err)
 	}
-	return WriteResult{BytesWritten: bytesWritten}, nil
+
+	s.notifyChanges(ctx, FileChange{APIKeyHash: auth.APIKeyHash, Project: project, Path: path})
+	return result, nil
 }
 
 // snapshotFileVersionTx inserts a snapshot row representing a file's prior content.
-func (s *Service) snapshotFileVersionTx(ctx context.Context, tx *sql.Tx, apiKeyHash, project, path string, content []byte, size int64, sourceID uint64, now time.Time) error {
+// The snapshot takes its own reference on the file's blob.
+func (s *Service) snapshotFileVersionTx(ctx context.Context, tx *sql.Tx, apiKeyHash, project, path string, file *File, now time.Time) error {
 	owner := systemOwnerFromContext(ctx)
+	contentHash, err := referenceBlobTx(ctx, tx, s.isPostgres, file.Content, now)
+	if err != nil {
+		return err
+	}
 	if _, err := tx.ExecContext(ctx,
-		rebindSQL(`INSERT INTO mcp_file_versions (apikey_hash, project, path, content, size, created_at, source_file_id, system_owner)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, s.isPostgres),
+		rebindSQL(`INSERT INTO mcp_file_versions (apikey_hash, project, path, content, content_hash, size, created_at, source_file_id, system_owner)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`, s.isPostgres),
 		apiKeyHash,
 		project,
 		path,
-		content,
-		size,
+		[]byte{},
+		contentHash,
+		file.Size,
 		now,
-		sourceID,
+		file.ID,
 		owner,
 	); err != nil {
 		return errors.Wrap(err, "insert file version snapshot")
@@ -206,11 +216,12 @@ func (s *Service) snapshotFileVersionTx(ctx context.Context, tx *sql.Tx, apiKeyH
 	return nil
 }
 
-// pruneVersionsTx applies the union retention rule (top N OR within retention window).
+// pruneVersionsTx applies the union retention rule (top N OR within retention window)
+// and releases the blob reference held by every pruned version.
 func (s *Service) pruneVersionsTx(ctx context.Context, tx *sql.Tx, apiKeyHash, project, path string, now time.Time) error {
 	owner := systemOwnerFromContext(ctx)
 	rows, err := tx.QueryContext(ctx,
-		rebindSQL(`SELECT id, created_at FROM mcp_file_versions
+		rebindSQL(`SELECT id, content_hash, created_at FROM mcp_file_versions
 			WHERE apikey_hash = ? AND project = ? AND path = ? AND system_owner = ?
 			ORDER BY created_at DESC, id DESC`, s.isPostgres),
 		apiKeyHash,
@@ -223,16 +234,18 @@ func (s *Service) pruneVersionsTx(ctx context.Context, tx *sql.Tx, apiKeyHash, p
 	}
 
 	type versionRow struct {
-		id        uint64
-		createdAt time.Time
+		id          uint64
+		contentHash string
+		createdAt   time.Time
 	}
 	var all []versionRow
 	for rows.Next() {
 		var (
-			id        uint64
-			createdAt any
+			id          uint64
+			contentHash string
+			createdAt   any
 		)
-		if scanErr := rows.Scan(&id, &createdAt); scanErr != nil {
+		if scanErr := rows.Scan(&id, &contentHash, &createdAt); scanErr != nil {
 			_ = rows.Close()
 			return errors.Wrap(scanErr, "scan version for prune")
 		}
@@ -241,7 +254,7 @@ func (s *Service) pruneVersionsTx(ctx context.Context, tx *sql.Tx, apiKeyHash, p
 			_ = rows.Close()
 			return errors.Wrap(parseErr, "parse version created_at for prune")
 		}
-		all = append(all, versionRow{id: id, createdAt: parsedAt})
+		all = append(all, versionRow{id: id, contentHash: contentHash, createdAt: parsedAt})
 	}
 	if err := rows.Err(); err != nil {
 		_ = rows.Close()
@@ -267,9 +280,11 @@ func (s *Service) pruneVersionsTx(ctx context.Context, tx *sql.Tx, apiKeyHash, p
 	}
 
 	deleteIDs := make([]uint64, 0, len(all))
+	releaseHashes := make([]string, 0, len(all))
 	for _, row := range all {
 		if _, ok := keep[row.id]; !ok {
 			deleteIDs = append(deleteIDs, row.id)
+			releaseHashes = append(releaseHashes, row.contentHash)
 		}
 	}
 	if len(deleteIDs) == 0 {
@@ -289,5 +304,10 @@ func (s *Service) pruneVersionsTx(ctx context.Context, tx *sql.Tx, apiKeyHash, p
 	if _, err := tx.ExecContext(ctx, rebindSQL(query, s.isPostgres), args...); err != nil {
 		return errors.Wrap(err, "delete pruned versions")
 	}
+	for _, hash := range releaseHashes {
+		if err := releaseBlobTx(ctx, tx, s.isPostgres, hash, now); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -16,8 +16,21 @@ func (s *Service) Write(ctx context.Context, auth AuthContext, project, path, co
 
 // WriteWith applies content using the supplied WriteOpts (proposal §2.6.1, §4.2).
 // The plain Write entry point delegates here with a zero-valued opts to preserve
-// existing behavior.
+// existing behavior. Content is decoded per encoding before it is stored.
 func (s *Service) WriteWith(ctx context.Context, auth AuthContext, project, path, content, encoding string, offset int64, mode WriteMode, opts WriteOpts) (WriteResult, error) {
+	if err := s.validateAuth(auth); err != nil {
+		return WriteResult{}, errors.WithStack(err)
+	}
+	data, err := DecodeContent(content, encoding)
+	if err != nil {
+		return WriteResult{}, errors.WithStack(err)
+	}
+	return s.WriteContent(ctx, auth, project, path, data, offset, mode, opts)
+}
+
+// WriteContent applies raw bytes to a file path. It backs WriteWith and binary
+// uploads that arrive without a text encoding.
+func (s *Service) WriteContent(ctx context.Context, auth AuthContext, project, path string, content []byte, offset int64, mode WriteMode, opts WriteOpts) (WriteResult, error) {
 	if err := s.validateAuth(auth); err != nil {
 		return WriteResult{}, errors.WithStack(err)
 	}
@@ -33,20 +46,20 @@ func (s *Service) WriteWith(ctx context.Context, auth AuthContext, project, path
 	if offset < 0 {
 		return WriteResult{}, errors.WithStack(NewError(ErrCodeInvalidOffset, "offset must be >= 0", false))
 	}
-	if _, err := NormalizeContentEncoding(encoding); err != nil {
-		return WriteResult{}, errors.WithStack(err)
-	}
 	if mode == "" {
 		mode = WriteModeAppend
 	}
-	if mode != WriteModeAppend && mode != WriteModeOverwrite && mode != WriteModeTruncate {
+	if mode != WriteModeAppend && mode != WriteModeOverwrite && mode != WriteModeTruncate && mode != WriteModePatch {
 		return WriteResult{}, errors.WithStack(NewError(ErrCodeInvalidOffset, "invalid write mode", false))
 	}
 	if mode == WriteModeTruncate && offset != 0 {
 		return WriteResult{}, errors.WithStack(NewError(ErrCodeInvalidOffset, "truncate requires offset 0", false))
 	}
+	if mode == WriteModePatch && offset != 0 {
+		return WriteResult{}, errors.WithStack(NewError(ErrCodeInvalidOffset, "patch requires offset 0", false))
+	}
 
-	payloadBytes := int64(len([]byte(content)))
+	payloadBytes := int64(len(content))
 	if err := ValidatePayloadSize(payloadBytes, s.settings.MaxPayloadBytes); err != nil {
 		return WriteResult{}, errors.WithStack(err)
 	}
@@ -55,25 +68,27 @@ func (s *Service) WriteWith(ctx context.Context, auth AuthContext, project, path
 		ctx = contextWithSystemOwner(ctx, opts.SystemOwner)
 	}
 
-	var bytesWritten int64
+	var result WriteResult
 	err := s.lockProvider.WithProjectLock(ctx, s.db, s.isPostgres, auth.APIKeyHash, project, s.settings.LockTimeout, func(tx *sql.Tx) error {
-		n, err := s.writeWithinTx(ctx, tx, auth, project, path, []byte(content), mode, offset, payloadBytes, opts)
+		written, err := s.writeWithinTx(ctx, tx, auth, project, path, content, mode, offset, payloadBytes, opts)
 		if err != nil {
 			return err
 		}
-		bytesWritten = n
+		result = written
 		return nil
 	})
 	if err != nil {
 		return WriteResult{}, errors.WithStack(err)
 	}
 
-	return WriteResult{BytesWritten: bytesWritten}, nil
+	s.notifyChanges(ctx, FileChange{APIKeyHash: auth.APIKeyHash, Project: project, Path: path})
+	return result, nil
 }
 
 // writeWithinTx executes the write pipeline assuming the project lock is held.
 // All snapshot, file upsert, prune, index-job, and credential-store steps live here
-// so that callers such as RestoreVersion can reuse the same atomic flow.
+// so that callers such as RestoreVersion can reuse the same atomic flow. A user write's
+// opts.IfMatch is checked against the file found under the lock.
 func (s *Service) writeWithinTx( //nolint:gocognit // write involves multiple validation and upsert steps
 	ctx context.Context,
 	tx *sql.Tx,
@@ -84,22 +99,27 @@ func (s *Service) writeWithinTx( //nolint:gocognit // write involves multiple va
 	offset int64,
 	bytesWritten int64,
 	opts WriteOpts,
-) (int64, error) {
+) (WriteResult, error) {
 	owner := systemOwnerFromContext(ctx)
 	if opts.SystemOwner != "" {
 		owner = opts.SystemOwner
 	}
 
 	if err := s.ensureNoDescendantFile(ctx, tx, auth.APIKeyHash, project, path); err != nil {
-		return 0, err
+		return WriteResult{}, err
 	}
 	if err := s.ensureNoParentFile(ctx, tx, auth.APIKeyHash, project, path); err != nil {
-		return 0, err
+		return WriteResult{}, err
 	}
 
 	existing, findErr := s.findActiveFileTx(ctx, tx, auth.APIKeyHash, project, path)
 	if findErr != nil && !errors.Is(findErr, sql.ErrNoRows) {
-		return 0, errors.Wrap(findErr, "query existing file")
+		return WriteResult{}, errors.Wrap(findErr, "query existing file")
+	}
+	if owner == "" {
+		if err := checkIfMatch(opts.IfMatch, existing); err != nil {
+			return WriteResult{}, err
+		}
 	}
 
 	now := s.clock()
@@ -117,50 +137,74 @@ func (s *Service) writeWithinTx( //nolint:gocognit // write involves multiple va
 		newContent, err = applyWriteModeBytes(existing.Content, content, offset, mode)
 	}
 	if err != nil {
-		return 0, err
+		return WriteResult{}, err
 	}
 
 	newSize := int64(len(newContent))
 	if err := ValidateFileSize(newSize, s.settings.MaxFileBytes); err != nil {
-		return 0, err
+		return WriteResult{}, err
 	}
 	if err := s.ensureProjectQuota(ctx, tx, auth.APIKeyHash, project, newSize, existing); err != nil {
-		return 0, err
+		return WriteResult{}, err
+	}
+	if err := s.ensureProjectStoredQuota(ctx, tx, auth.APIKeyHash, project, blobHash(newContent), newSize); err != nil {
+		return WriteResult{}, err
 	}
+	contentHash, err := referenceBlobTx(ctx, tx, s.isPostgres, newContent, now)
+	if err != nil {
+		return WriteResult{}, err
+	}
+	mimeType := resolveMimeType(path, newContent, mode, existing, opts)
 
+	var revision int64
 	if errors.Is(findErr, sql.ErrNoRows) {
+		revision, err = s.nextPathRevisionTx(ctx, tx, auth.APIKeyHash, project, path, owner)
+		if err != nil {
+			return WriteResult{}, err
+		}
 		if _, err := tx.ExecContext(ctx,
-			rebindSQL(`INSERT INTO mcp_files (apikey_hash, project, path, content, size, created_at, updated_at, deleted, deleted_at, system_owner, skip_rag_index)
-				VALUES (?, ?, ?, ?, ?, ?, ?, FALSE, NULL, ?, ?)`, s.isPostgres),
+			rebindSQL(`INSERT INTO mcp_files (apikey_hash, project, path, content, content_hash, mime_type, revision, size, created_at, updated_at, deleted, deleted_at, system_owner, skip_rag_index)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, FALSE, NULL, ?, ?)`, s.isPostgres),
 			auth.APIKeyHash,
 			project,
 			path,
-			newContent,
+			[]byte{},
+			contentHash,
+			mimeType,
+			revision,
 			newSize,
 			createdAt,
 			now,
 			owner,
 			opts.SkipRAGIndex,
 		); err != nil {
-			return 0, errors.Wrap(err, "create file")
+			return WriteResult{}, errors.Wrap(err, "create file")
 		}
 	} else {
-		if err := s.snapshotFileVersionTx(ctx, tx, auth.APIKeyHash, project, path, existing.Content, existing.Size, existing.ID, now); err != nil {
-			return 0, err
+		if err := s.snapshotFileVersionTx(ctx, tx, auth.APIKeyHash, project, path, existing, now); err != nil {
+			return WriteResult{}, err
 		}
+		revision = existing.Revision + 1
 		if _, err := tx.ExecContext(ctx,
-			rebindSQL(`UPDATE mcp_files SET content = ?, size = ?, updated_at = ?, deleted = FALSE, deleted_at = NULL, skip_rag_index = ? WHERE id = ? AND system_owner = ?`, s.isPostgres),
-			newContent,
+			rebindSQL(`UPDATE mcp_files SET content = ?, content_hash = ?, mime_type = ?, revision = ?, size = ?, updated_at = ?, deleted = FALSE, deleted_at = NULL, skip_rag_index = ? WHERE id = ? AND system_owner = ?`, s.isPostgres),
+			[]byte{},
+			contentHash,
+			mimeType,
+			revision,
 			newSize,
 			now,
 			opts.SkipRAGIndex,
 			existing.ID,
 			owner,
 		); err != nil {
-			return 0, errors.Wrap(err, "update file")
+			return WriteResult{}, errors.Wrap(err, "update file")
+		}
+		// The snapshot took its own reference, so the row's old one can go.
+		if err := releaseBlobTx(ctx, tx, s.isPostgres, existing.ContentHash, now); err != nil {
+			return WriteResult{}, err
 		}
 		if err := s.pruneVersionsTx(ctx, tx, auth.APIKeyHash, project, path, now); err != nil {
-			return 0, err
+			return WriteResult{}, err
 		}
 	}
 
@@ -180,20 +224,35 @@ func (s *Service) writeWithinTx( //nolint:gocognit // write involves multiple va
 			CreatedAt:     now,
 			UpdatedAt:     now,
 		}); err != nil {
-			return 0, errors.Wrap(err, "enqueue index job")
+			return WriteResult{}, errors.Wrap(err, "enqueue index job")
 		}
 	}
 
 	if owner == "" {
 		if err := s.storeCredentialEnvelope(ctx, auth, project, path, now); err != nil {
-			return 0, err
+			return WriteResult{}, err
 		}
 	}
 
-	return bytesWritten, nil
+	return WriteResult{BytesWritten: bytesWritten, Revision: revision}, nil
 }
 
-// Delete removes a file or directory tree.
+// nextPathRevisionTx returns the revision for a file created at path. It continues
+// from soft-deleted rows at the same path so a recreated file never reuses a revision.
+func (s *Service) nextPathRevisionTx(ctx context.Context, tx *sql.Tx, apiKeyHash, project, path, owner string) (int64, error) {
+	var current int64
+	err := tx.QueryRowContext(ctx,
+		rebindSQL(`SELECT COALESCE(MAX(revision), 0) FROM mcp_files WHERE apikey_hash = ? AND project = ? AND path = ? AND system_owner = ?`, s.isPostgres),
+		apiKeyHash, project, path, owner,
+	).Scan(&current)
+	if err != nil {
+		return 0, errors.Wrap(err, "query path revision")
+	}
+	return current + 1, nil
+}
+
+// Delete removes a file or directory tree. An if_match precondition on ctx is only
+// accepted when path names a single file.
 func (s *Service) Delete(ctx context.Context, auth AuthContext, project, path string, recursive bool) (DeleteResult, error) {
 	if err := s.validateAuth(auth); err != nil {
 		return DeleteResult{}, errors.WithStack(err)
@@ -208,8 +267,8 @@ func (s *Service) Delete(ctx context.Context, auth AuthContext, project, path st
 		return DeleteResult{}, errors.WithStack(NewError(ErrCodePermissionDenied, "root directory cannot be deleted", false))
 	}
 
-	owner := systemOwnerFromContext(ctx)
-	var deletedCount int
+	ifMatch := ifMatchFromContext(ctx)
+	var deletedPaths []string
 	err := s.lockProvider.WithProjectLock(ctx, s.db, s.isPostgres, auth.APIKeyHash, project, s.settings.LockTimeout, func(tx *sql.Tx) error {
 		now := s.clock()
 		paths, err := s.resolveDeleteTargets(ctx, tx, auth.APIKeyHash, project, path, recursive)
@@ -217,6 +276,9 @@ func (s *Service) Delete(ctx context.Context, auth AuthContext, project, path st
 			return err
 		}
 		if len(paths) == 0 {
+			if err := checkIfMatch(ifMatch, nil); err != nil {
+				return err
+			}
 			return errors.WithStack(NewError(ErrCodeNotFound, "path not found", false))
 		}
 
@@ -224,53 +286,93 @@ func (s *Service) Delete(ctx context.Context, auth AuthContext, project, path st
 		if err != nil {
 			return err
 		}
-		for _, snap := range snapshots {
-			if err := s.snapshotFileVersionTx(ctx, tx, auth.APIKeyHash, project, snap.Path, snap.Content, snap.Size, snap.ID, now); err != nil {
+		if ifMatch != "" {
+			if len(snapshots) != 1 || snapshots[0].Path != path {
+				return errors.WithStack(NewError(ErrCodeInvalidArgument, "if_match requires a file path", false))
+			}
+			if err := checkIfMatch(ifMatch, &snapshots[0]); err != nil {
 				return err
 			}
 		}
+		if err := s.softDeleteFilesTx(ctx, tx, auth.APIKeyHash, project, snapshots, now); err != nil {
+			return err
+		}
+
+		deletedPaths = paths
+		return nil
+	})
+	if err != nil {
+		return DeleteResult{}, errors.WithStack(err)
+	}
 
-		query := rebindSQL(`UPDATE mcp_files SET deleted = TRUE, deleted_at = ?, updated_at = ? WHERE apikey_hash = ? AND project = ? AND deleted = FALSE AND system_owner = ? AND path IN (%s)`, s.isPostgres)
-		inClause, inArgs := buildInClause(paths, s.isPostgres, 6)
-		args := make([]any, 0, 5+len(inArgs))
-		args = append(args, now, now, auth.APIKeyHash, project, owner)
-		args = append(args, inArgs...)
-		if _, err := tx.ExecContext(ctx, strings.Replace(query, "%s", inClause, 1), args...); err != nil {
-			return errors.Wrap(err, "soft delete files")
+	changes := make([]FileChange, 0, len(deletedPaths))
+	for _, p := range deletedPaths {
+		changes = append(changes, FileChange{APIKeyHash: auth.APIKeyHash, Project: project, Path: p, Deleted: true})
+	}
+	s.notifyChanges(ctx, changes...)
+	return DeleteResult{DeletedCount: len(deletedPaths)}, nil
+}
+
+// softDeleteFilesTx marks files deleted assuming the project lock is held. Each file's
+// current content is kept as a version, and user-namespace deletes enqueue index
+// cleanup jobs.
+func (s *Service) softDeleteFilesTx(ctx context.Context, tx *sql.Tx, apiKeyHash, project string, files []File, now time.Time) error {
+	if len(files) == 0 {
+		return nil
+	}
+	owner := systemOwnerFromContext(ctx)
+	paths := make([]string, 0, len(files))
+	for i := range files {
+		if err := s.snapshotFileVersionTx(ctx, tx, apiKeyHash, project, files[i].Path, &files[i], now); err != nil {
+			return err
 		}
+		paths = append(paths, files[i].Path)
+	}
 
-		for _, snap := range snapshots {
-			if err := s.pruneVersionsTx(ctx, tx, auth.APIKeyHash, project, snap.Path, now); err != nil {
-				return err
-			}
+	query := rebindSQL(`UPDATE mcp_files SET deleted = TRUE, deleted_at = ?, updated_at = ?, revision = revision + 1 WHERE apikey_hash = ? AND project = ? AND deleted = FALSE AND system_owner = ? AND path IN (%s)`, s.isPostgres)
+	inClause, inArgs := buildInClause(paths, s.isPostgres, 6)
+	args := make([]any, 0, 5+len(inArgs))
+	args = append(args, now, now, apiKeyHash, project, owner)
+	args = append(args, inArgs...)
+	if _, err := tx.ExecContext(ctx, strings.Replace(query, "%s", inClause, 1), args...); err != nil {
+		return errors.Wrap(err, "soft delete files")
+	}
+
+	for _, p := range paths {
+		if err := s.pruneVersionsTx(ctx, tx, apiKeyHash, project, p, now); err != nil {
+			return err
 		}
+	}
 
-		if owner == "" {
-			for _, p := range paths {
-				if err := s.insertIndexJobTx(ctx, tx, FileIndexJob{
-					APIKeyHash:    auth.APIKeyHash,
-					Project:       project,
-					FilePath:      p,
-					Operation:     "DELETE",
-					FileUpdatedAt: &now,
-					Status:        "pending",
-					RetryCount:    0,
-					AvailableAt:   now,
-					CreatedAt:     now,
-					UpdatedAt:     now,
-				}); err != nil {
-					return errors.Wrap(err, "enqueue delete job")
-				}
+	if owner == "" {
+		for _, p := range paths {
+			if err := s.insertIndexJobTx(ctx, tx, FileIndexJob{
+				APIKeyHash:    apiKeyHash,
+				Project:       project,
+				FilePath:      p,
+				Operation:     "DELETE",
+				FileUpdatedAt: &now,
+				Status:        "pending",
+				RetryCount:    0,
+				AvailableAt:   now,
+				CreatedAt:     now,
+				UpdatedAt:     now,
+			}); err != nil {
+				return errors.Wrap(err, "enqueue delete job")
 			}
 		}
+	}
+	return nil
+}
 
-		deletedCount = len(paths)
-		return nil
-	})
-	if err != nil {
-		return DeleteResult{}, errors.WithStack(err)
+// resolveMimeType picks the media type recorded by a write. A declared type wins; a
+// partial write keeps the type already on file; otherwise the type is detected.
+func resolveMimeType(path string, content []byte, mode WriteMode, existing *File, opts WriteOpts) string {
+	declared := opts.MimeType
+	if declared == "" && mode != WriteModeTruncate && existing != nil && existing.MimeType != "" {
+		return existing.MimeType
 	}
-	return DeleteResult{DeletedCount: deletedCount}, nil
+	return DetectMimeType(path, content, declared)
 }
 
 // applyWriteMode merges incoming content with existing data and returns new bytes.
@@ -285,6 +387,8 @@ func applyWriteModeBytes(existing, incoming []byte, offset int64, mode WriteMode
 		return append(existing, incoming...), nil
 	case WriteModeTruncate:
 		return append([]byte{}, incoming...), nil
+	case WriteModePatch:
+		return applyPatch(existing, incoming)
 	case WriteModeOverwrite:
 		size := int64(len(existing))
 		if offset > size {
@@ -481,13 +585,14 @@ func (s *Service) listAllFilePaths(ctx context.Context, tx *sql.Tx, apiKeyHash,
 func (s *Service) insertIndexJobTx(ctx context.Context, tx *sql.Tx, job FileIndexJob) error {
 	owner := systemOwnerFromContext(ctx)
 	_, err := tx.ExecContext(ctx,
-		rebindSQL(`INSERT INTO mcp_file_index_jobs (apikey_hash, project, file_path, operation, file_updated_at, status, retry_count, available_at, created_at, updated_at, system_owner)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, s.isPostgres),
+		rebindSQL(`INSERT INTO mcp_file_index_jobs (apikey_hash, project, file_path, operation, file_updated_at, reindex_run_id, status, retry_count, available_at, created_at, updated_at, system_owner)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, s.isPostgres),
 		job.APIKeyHash,
 		job.Project,
 		job.FilePath,
 		job.Operation,
 		job.FileUpdatedAt,
+		job.ReindexRunID,
 		job.Status,
 		job.RetryCount,
 		job.AvailableAt,
@@ -508,9 +613,10 @@ func (s *Service) loadFilesForSnapshotTx(ctx context.Context, tx *sql.Tx, apiKey
 	}
 	owner := systemOwnerFromContext(ctx)
 	inClause, inArgs := buildInClause(paths, s.isPostgres, 4)
-	query := rebindSQL(`SELECT id, path, content, size FROM mcp_files
-		WHERE apikey_hash = ? AND project = ? AND deleted = FALSE AND system_owner = ? AND path IN (%s)
-		ORDER BY path ASC`, s.isPostgres)
+	query := rebindSQL(`SELECT f.id, f.path, COALESCE(b.content, f.content), f.content_hash, f.revision, f.size FROM mcp_files f
+		LEFT JOIN mcp_file_blobs b ON b.hash = f.content_hash
+		WHERE f.apikey_hash = ? AND f.project = ? AND f.deleted = FALSE AND f.system_owner = ? AND f.path IN (%s)
+		ORDER BY f.path ASC`, s.isPostgres)
 	args := make([]any, 0, 3+len(inArgs))
 	args = append(args, apiKeyHash, project, owner)
 	args = append(args, inArgs...)
@@ -523,7 +629,7 @@ func (s *Service) loadFilesForSnapshotTx(ctx context.Context, tx *sql.Tx, apiKey
 	var files []File
 	for rows.Next() {
 		var file File
-		if scanErr := rows.Scan(&file.ID, &file.Path, &file.Content, &file.Size); scanErr != nil {
+		if scanErr := rows.Scan(&file.ID, &file.Path, &file.Content, &file.ContentHash, &file.Revision, &file.Size); scanErr != nil {
 			return nil, errors.Wrap(scanErr, "scan file for snapshot")
 		}
 		files = append(files, file)
@@ -539,9 +645,10 @@ func (s *Service) findActiveFileTx(ctx context.Context, tx *sql.Tx, apiKeyHash,
 	owner := systemOwnerFromContext(ctx)
 	var file File
 	err := tx.QueryRowContext(ctx,
-		rebindSQL(`SELECT id, apikey_hash, project, path, content, size, created_at, updated_at, deleted, deleted_at
-		FROM mcp_files
-		WHERE apikey_hash = ? AND project = ? AND path = ? AND deleted = FALSE AND system_owner = ?
+		rebindSQL(`SELECT f.id, f.apikey_hash, f.project, f.path, COALESCE(b.content, f.content), f.content_hash, f.mime_type, f.revision, f.size, f.created_at, f.updated_at, f.deleted, f.deleted_at
+		FROM mcp_files f
+		LEFT JOIN mcp_file_blobs b ON b.hash = f.content_hash
+		WHERE f.apikey_hash = ? AND f.project = ? AND f.path = ? AND f.deleted = FALSE AND f.system_owner = ?
 		LIMIT 1`, s.isPostgres),
 		apiKeyHash,
 		project,
@@ -553,6 +660,9 @@ func (s *Service) findActiveFileTx(ctx context.Context, tx *sql.Tx, apiKeyHash,
 		&file.Project,
 		&file.Path,
 		&file.Content,
+		&file.ContentHash,
+		&file.MimeType,
+		&file.Revision,
 		&file.Size,
 		&file.CreatedAt,
 		&file.UpdatedAt,
@@ -2,6 +2,7 @@ package files
 
 import (
 	"context"
+	"encoding/base64"
 	"testing"
 	"time"
 
@@ -340,3 +341,35 @@ func TestStatRootDirectory(t *testing.T) {
 	require.True(t, statRes.Exists)
 	require.Equal(t, FileTypeDirectory, statRes.Type)
 }
+
+// TestWriteReadBinaryContent verifies base64 round trips and recorded mime types.
+func TestWriteReadBinaryContent(t *testing.T) {
+	svc := newVersionsTestService(t)
+	auth := versionsTestAuth()
+	ctx := context.Background()
+	raw := []byte{0x25, 0x50, 0x44, 0x46, 0x00, 0xff, 0x10}
+
+	res, err := svc.Write(ctx, auth, "proj", "/doc.pdf", base64.StdEncoding.EncodeToString(raw), "base64", 0, WriteModeTruncate)
+	require.NoError(t, err)
+	require.EqualValues(t, len(raw), res.BytesWritten)
+
+	stat, err := svc.Stat(ctx, auth, "proj", "/doc.pdf")
+	require.NoError(t, err)
+	require.Equal(t, "application/pdf", stat.MimeType)
+	require.EqualValues(t, len(raw), stat.Size)
+
+	read, err := svc.Read(ctx, auth, "proj", "/doc.pdf", 4, 2)
+	require.NoError(t, err)
+	require.Equal(t, ContentEncodingBase64, read.ContentEncoding)
+	require.Equal(t, base64.StdEncoding.EncodeToString(raw[4:6]), read.Content)
+
+	_, err = svc.Write(ctx, auth, "proj", "/doc.pdf", "%%%", "base64", 0, WriteModeTruncate)
+	require.True(t, IsCode(err, ErrCodeInvalidQuery))
+
+	_, err = svc.WriteWith(ctx, auth, "proj", "/notes", "plain", "utf-8", 0, WriteModeTruncate, WriteOpts{MimeType: "text/markdown; charset=utf-8"})
+	require.NoError(t, err)
+	read, err = svc.Read(ctx, auth, "proj", "/notes", 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, ContentEncodingUTF8, read.ContentEncoding)
+	require.Equal(t, "text/markdown", read.MimeType)
+}
@@ -21,21 +21,32 @@ var (
 const (
 	legacyFilesConfigPrefix = "settings.mcp.files"
 	ragFilesConfigPrefix    = "settings.mcp.tools.memory.plugins.rag"
+
+	// defaultMaxPayloadBytes caps one write when max_payload_bytes is unset.
+	defaultMaxPayloadBytes int64 = 2_000_000
 )
 
 // Settings captures runtime configuration for FileIO features.
 type Settings struct {
-	AllowRootWipe    bool
-	MaxPayloadBytes  int64
-	MaxFileBytes     int64
-	MaxProjectBytes  int64
-	ListLimitDefault int
-	ListLimitMax     int
-	LockTimeout      time.Duration
-	DeleteRetention  time.Duration
+	AllowRootWipe   bool
+	MaxPayloadBytes int64
+	MaxFileBytes    int64
+	MaxProjectBytes int64
+	// MaxProjectStoredBytes bounds the deduplicated bytes kept for a project, version
+	// history included. Zero disables the limit.
+	MaxProjectStoredBytes int64
+	ListLimitDefault      int
+	ListLimitMax          int
+	LockTimeout           time.Duration
+	DeleteRetention       time.Duration
+	// BlobGCInterval is how often expired deleted files and unreferenced blobs are purged.
+	BlobGCInterval   time.Duration
 	EmbeddingModel   string
 	EmbeddingBaseURL string
 	Search           SearchSettings
+	Grep             GrepSettings
+	Snapshot         SnapshotSettings
+	Archive          ArchiveSettings
 	Index            IndexSettings
 	Security         SecuritySettings
 }
@@ -54,6 +65,35 @@ type SearchSettings struct {
 	LexicalWeight     float64
 }
 
+// GrepSettings bounds the work done by a single file_grep request.
+type GrepSettings struct {
+	// MaxScanBytes caps the file content scanned per request; the result is marked
+	// truncated when more files matched the glob.
+	MaxScanBytes    int64
+	LimitDefault    int
+	LimitMax        int
+	MaxContextLines int
+}
+
+// SnapshotSettings configures project snapshots.
+type SnapshotSettings struct {
+	// MaxPerProject caps the named snapshots kept for one project. Automatic
+	// snapshots do not count towards it.
+	MaxPerProject int
+	// AutoInterval is how often changed projects are snapshotted automatically.
+	// Zero disables automatic snapshots.
+	AutoInterval time.Duration
+	// AutoRetain is how many automatic snapshots are kept per project.
+	AutoRetain int
+}
+
+// ArchiveSettings bounds project export and import archives.
+type ArchiveSettings struct {
+	// MaxImportBytes caps both the uploaded archive and the total size of the entries
+	// unpacked from it, since imports are staged in memory before they are applied.
+	MaxImportBytes int64
+}
+
 // IndexSettings configures index worker behavior.
 type IndexSettings struct {
 	Workers        int
@@ -65,6 +105,11 @@ type IndexSettings struct {
 	SummaryBaseURL string
 	SummaryTimeout time.Duration
 	FreshnessSLO   time.Duration
+	// ReindexCredentialTTL is how long the credential envelopes of a re-index run stay
+	// usable. Runs queue behind live writes, so they need longer than CredentialCacheTTL.
+	ReindexCredentialTTL time.Duration
+	// ReindexBatchSize is how many jobs of a re-index run are committed per transaction.
+	ReindexBatchSize int
 }
 
 // SecuritySettings configures credential handoff encryption and cache usage.
@@ -72,6 +117,8 @@ type SecuritySettings struct {
 	EncryptionKEKs        map[uint16]string
 	CredentialCachePrefix string
 	CredentialCacheTTL    time.Duration
+	// AdminToken authorizes the admin HTTP endpoints. They are disabled when empty.
+	AdminToken string
 }
 
 // KEKs returns all configured non-empty KEKs.
@@ -90,16 +137,18 @@ func (s SecuritySettings) KEKs() map[uint16]string {
 // LoadSettingsFromConfig reads configuration and applies safe defaults.
 func LoadSettingsFromConfig() Settings {
 	settings := Settings{
-		AllowRootWipe:    gconfig.S.GetBool(configKeyWithFallback(ragFilesConfigKey("allow_root_wipe"), legacyFilesConfigKey("allow_root_wipe"))),
-		MaxPayloadBytes:  int64FromConfig(configKeyWithFallback(ragFilesConfigKey("max_payload_bytes"), legacyFilesConfigKey("max_payload_bytes")), 2_000_000),
-		MaxFileBytes:     int64FromConfig(configKeyWithFallback(ragFilesConfigKey("max_file_bytes"), legacyFilesConfigKey("max_file_bytes")), 10_000_000),
-		MaxProjectBytes:  int64FromConfig(configKeyWithFallback(ragFilesConfigKey("max_project_bytes"), legacyFilesConfigKey("max_project_bytes")), 100_000_000),
-		ListLimitDefault: intFromConfig(configKeyWithFallback(ragFilesConfigKey("list_limit_default"), legacyFilesConfigKey("list_limit_default")), 256),
-		ListLimitMax:     intFromConfig(configKeyWithFallback(ragFilesConfigKey("list_limit_max"), legacyFilesConfigKey("list_limit_max")), 1024),
-		LockTimeout:      time.Duration(intFromConfig(configKeyWithFallback(ragFilesConfigKey("lock_timeout_ms"), legacyFilesConfigKey("lock_timeout_ms")), 3000)) * time.Millisecond,
-		DeleteRetention:  time.Duration(intFromConfig(configKeyWithFallback(ragFilesConfigKey("delete_retention_days"), legacyFilesConfigKey("delete_retention_days")), 30)) * 24 * time.Hour,
-		EmbeddingModel:   strings.TrimSpace(gconfig.S.GetString("settings.openai.embedding_model")),
-		EmbeddingBaseURL: strings.TrimSpace(gconfig.S.GetString("settings.openai.base_url")),
+		AllowRootWipe:         gconfig.S.GetBool(configKeyWithFallback(ragFilesConfigKey("allow_root_wipe"), legacyFilesConfigKey("allow_root_wipe"))),
+		MaxPayloadBytes:       int64FromConfig(configKeyWithFallback(ragFilesConfigKey("max_payload_bytes"), legacyFilesConfigKey("max_payload_bytes")), defaultMaxPayloadBytes),
+		MaxFileBytes:          int64FromConfig(configKeyWithFallback(ragFilesConfigKey("max_file_bytes"), legacyFilesConfigKey("max_file_bytes")), 10_000_000),
+		MaxProjectBytes:       int64FromConfig(configKeyWithFallback(ragFilesConfigKey("max_project_bytes"), legacyFilesConfigKey("max_project_bytes")), 100_000_000),
+		MaxProjectStoredBytes: int64FromConfig(configKeyWithFallback(ragFilesConfigKey("max_project_stored_bytes"), legacyFilesConfigKey("max_project_stored_bytes")), 0),
+		ListLimitDefault:      intFromConfig(configKeyWithFallback(ragFilesConfigKey("list_limit_default"), legacyFilesConfigKey("list_limit_default")), 256),
+		ListLimitMax:          intFromConfig(configKeyWithFallback(ragFilesConfigKey("list_limit_max"), legacyFilesConfigKey("list_limit_max")), 1024),
+		LockTimeout:           time.Duration(intFromConfig(configKeyWithFallback(ragFilesConfigKey("lock_timeout_ms"), legacyFilesConfigKey("lock_timeout_ms")), 3000)) * time.Millisecond,
+		DeleteRetention:       time.Duration(intFromConfig(configKeyWithFallback(ragFilesConfigKey("delete_retention_days"), legacyFilesConfigKey("delete_retention_days")), 30)) * 24 * time.Hour,
+		BlobGCInterval:        time.Duration(intFromConfig(configKeyWithFallback(ragFilesConfigKey("blob_gc_interval_minutes"), legacyFilesConfigKey("blob_gc_interval_minutes")), 60)) * time.Minute,
+		EmbeddingModel:        strings.TrimSpace(gconfig.S.GetString("settings.openai.embedding_model")),
+		EmbeddingBaseURL:      strings.TrimSpace(gconfig.S.GetString("settings.openai.base_url")),
 		Search: SearchSettings{
 			Enabled:           boolFromConfig(configKeyWithFallback(ragFilesConfigKey("search.enabled"), legacyFilesConfigKey("search.enabled")), true),
 			LimitDefault:      intFromConfig(configKeyWithFallback(ragFilesConfigKey("search.limit_default"), legacyFilesConfigKey("search.limit_default")), 20),
@@ -112,26 +161,43 @@ func LoadSettingsFromConfig() Settings {
 			SemanticWeight:    floatFromConfig(configKeyWithFallback(ragFilesConfigKey("search.fallback.semantic_weight"), legacyFilesConfigKey("search.fallback.semantic_weight")), 0.65),
 			LexicalWeight:     floatFromConfig(configKeyWithFallback(ragFilesConfigKey("search.fallback.lexical_weight"), legacyFilesConfigKey("search.fallback.lexical_weight")), 0.35),
 		},
+		Grep: GrepSettings{
+			MaxScanBytes:    int64FromConfig(configKeyWithFallback(ragFilesConfigKey("grep.max_scan_bytes"), legacyFilesConfigKey("grep.max_scan_bytes")), 20_000_000),
+			LimitDefault:    intFromConfig(configKeyWithFallback(ragFilesConfigKey("grep.limit_default"), legacyFilesConfigKey("grep.limit_default")), 100),
+			LimitMax:        intFromConfig(configKeyWithFallback(ragFilesConfigKey("grep.limit_max"), legacyFilesConfigKey("grep.limit_max")), 1000),
+			MaxContextLines: intFromConfig(configKeyWithFallback(ragFilesConfigKey("grep.max_context_lines"), legacyFilesConfigKey("grep.max_context_lines")), 10),
+		},
+		Snapshot: SnapshotSettings{
+			MaxPerProject: intFromConfig(configKeyWithFallback(ragFilesConfigKey("snapshot.max_per_project"), legacyFilesConfigKey("snapshot.max_per_project")), 50),
+			AutoInterval:  time.Duration(intFromConfig(configKeyWithFallback(ragFilesConfigKey("snapshot.auto_interval_minutes"), legacyFilesConfigKey("snapshot.auto_interval_minutes")), 0)) * time.Minute,
+			AutoRetain:    intFromConfig(configKeyWithFallback(ragFilesConfigKey("snapshot.auto_retain"), legacyFilesConfigKey("snapshot.auto_retain")), 24),
+		},
+		Archive: ArchiveSettings{
+			MaxImportBytes: int64FromConfig(configKeyWithFallback(ragFilesConfigKey("archive.max_import_bytes"), legacyFilesConfigKey("archive.max_import_bytes")), 200_000_000),
+		},
 		Index: IndexSettings{
-			Workers:        intFromConfig(configKeyWithFallback(ragFilesConfigKey("index.workers"), legacyFilesConfigKey("index.workers")), 2),
-			BatchSize:      intFromConfig(configKeyWithFallback(ragFilesConfigKey("index.batch_size"), legacyFilesConfigKey("index.batch_size")), 20),
-			RetryMax:       intFromConfig(configKeyWithFallback(ragFilesConfigKey("index.retry_max"), legacyFilesConfigKey("index.retry_max")), 5),
-			RetryBackoff:   time.Duration(intFromConfig(configKeyWithFallback(ragFilesConfigKey("index.retry_backoff_ms"), legacyFilesConfigKey("index.retry_backoff_ms")), 1000)) * time.Millisecond,
-			ChunkBytes:     intFromConfig(configKeyWithFallback(ragFilesConfigKey("index.chunk_bytes"), legacyFilesConfigKey("index.chunk_bytes")), 500),
-			SummaryModel:   strings.TrimSpace(gconfig.S.GetString(configKeyWithFallback(ragFilesConfigKey("index.summary.model"), legacyFilesConfigKey("index.summary.model")))),
-			SummaryBaseURL: strings.TrimSpace(gconfig.S.GetString(configKeyWithFallback(ragFilesConfigKey("index.summary.base_url"), legacyFilesConfigKey("index.summary.base_url")))),
-			SummaryTimeout: time.Duration(intFromConfig(configKeyWithFallback(ragFilesConfigKey("index.summary.timeout_ms"), legacyFilesConfigKey("index.summary.timeout_ms")), 8000)) * time.Millisecond,
-			FreshnessSLO:   time.Duration(intFromConfig(configKeyWithFallback(ragFilesConfigKey("index.slo_p95_seconds"), legacyFilesConfigKey("index.slo_p95_seconds")), 30)) * time.Second,
+			Workers:              intFromConfig(configKeyWithFallback(ragFilesConfigKey("index.workers"), legacyFilesConfigKey("index.workers")), 2),
+			BatchSize:            intFromConfig(configKeyWithFallback(ragFilesConfigKey("index.batch_size"), legacyFilesConfigKey("index.batch_size")), 20),
+			RetryMax:             intFromConfig(configKeyWithFallback(ragFilesConfigKey("index.retry_max"), legacyFilesConfigKey("index.retry_max")), 5),
+			RetryBackoff:         time.Duration(intFromConfig(configKeyWithFallback(ragFilesConfigKey("index.retry_backoff_ms"), legacyFilesConfigKey("index.retry_backoff_ms")), 1000)) * time.Millisecond,
+			ChunkBytes:           intFromConfig(configKeyWithFallback(ragFilesConfigKey("index.chunk_bytes"), legacyFilesConfigKey("index.chunk_bytes")), 500),
+			SummaryModel:         strings.TrimSpace(gconfig.S.GetString(configKeyWithFallback(ragFilesConfigKey("index.summary.model"), legacyFilesConfigKey("index.summary.model")))),
+			SummaryBaseURL:       strings.TrimSpace(gconfig.S.GetString(configKeyWithFallback(ragFilesConfigKey("index.summary.base_url"), legacyFilesConfigKey("index.summary.base_url")))),
+			SummaryTimeout:       time.Duration(intFromConfig(configKeyWithFallback(ragFilesConfigKey("index.summary.timeout_ms"), legacyFilesConfigKey("index.summary.timeout_ms")), 8000)) * time.Millisecond,
+			FreshnessSLO:         time.Duration(intFromConfig(configKeyWithFallback(ragFilesConfigKey("index.slo_p95_seconds"), legacyFilesConfigKey("index.slo_p95_seconds")), 30)) * time.Second,
+			ReindexCredentialTTL: time.Duration(intFromConfig(configKeyWithFallback(ragFilesConfigKey("index.reindex.credential_ttl_seconds"), legacyFilesConfigKey("index.reindex.credential_ttl_seconds")), 86400)) * time.Second,
+			ReindexBatchSize:     intFromConfig(configKeyWithFallback(ragFilesConfigKey("index.reindex.batch_size"), legacyFilesConfigKey("index.reindex.batch_size")), 500),
 		},
 		Security: SecuritySettings{
 			EncryptionKEKs:        uint16StringMapFromConfig(configKeyWithFallback(ragFilesConfigKey("security.encryption_keks"), legacyFilesConfigKey("security.encryption_keks"))),
 			CredentialCachePrefix: strings.TrimSpace(gconfig.S.GetString(configKeyWithFallback(ragFilesConfigKey("security.credential_cache_prefix"), legacyFilesConfigKey("security.credential_cache_prefix")))),
 			CredentialCacheTTL:    time.Duration(intFromConfig(configKeyWithFallback(ragFilesConfigKey("security.credential_cache_ttl_seconds"), legacyFilesConfigKey("security.credential_cache_ttl_seconds")), 300)) * time.Second,
+			AdminToken:            strings.TrimSpace(gconfig.S.GetString(configKeyWithFallback(ragFilesConfigKey("security.admin_token"), legacyFilesConfigKey("security.admin_token")))),
 		},
 	}
 
 	if settings.MaxPayloadBytes <= 0 {
-		settings.MaxPayloadBytes = 2_000_000
+		settings.MaxPayloadBytes = defaultMaxPayloadBytes
 	}
 	if settings.MaxFileBytes <= 0 {
 		settings.MaxFileBytes = 10_000_000
@@ -154,6 +220,9 @@ func LoadSettingsFromConfig() Settings {
 	if settings.DeleteRetention <= 0 {
 		settings.DeleteRetention = 30 * 24 * time.Hour
 	}
+	if settings.MaxProjectStoredBytes < 0 {
+		settings.MaxProjectStoredBytes = 0
+	}
 	if settings.EmbeddingModel == "" {
 		settings.EmbeddingModel = "text-embedding-3-small"
 	}
@@ -185,6 +254,30 @@ func LoadSettingsFromConfig() Settings {
 		settings.Search.RerankTimeout = 6 * time.Second
 	}
 	settings.Search.SemanticWeight, settings.Search.LexicalWeight = normalizeWeights(settings.Search.SemanticWeight, settings.Search.LexicalWeight)
+	if settings.Grep.MaxScanBytes <= 0 {
+		settings.Grep.MaxScanBytes = 20_000_000
+	}
+	if settings.Grep.LimitMax <= 0 {
+		settings.Grep.LimitMax = 1000
+	}
+	if settings.Grep.LimitDefault <= 0 || settings.Grep.LimitDefault > settings.Grep.LimitMax {
+		settings.Grep.LimitDefault = min(100, settings.Grep.LimitMax)
+	}
+	if settings.Grep.MaxContextLines < 0 {
+		settings.Grep.MaxContextLines = 0
+	}
+	if settings.Snapshot.MaxPerProject <= 0 {
+		settings.Snapshot.MaxPerProject = 50
+	}
+	if settings.Snapshot.AutoInterval < 0 {
+		settings.Snapshot.AutoInterval = 0
+	}
+	if settings.Snapshot.AutoRetain <= 0 {
+		settings.Snapshot.AutoRetain = 24
+	}
+	if settings.Archive.MaxImportBytes <= 0 {
+		settings.Archive.MaxImportBytes = 200_000_000
+	}
 	if settings.Index.Workers <= 0 {
 		settings.Index.Workers = 1
 	}
@@ -212,6 +305,12 @@ func LoadSettingsFromConfig() Settings {
 	if settings.Index.FreshnessSLO <= 0 {
 		settings.Index.FreshnessSLO = 30 * time.Second
 	}
+	if settings.Index.ReindexCredentialTTL <= 0 {
+		settings.Index.ReindexCredentialTTL = 24 * time.Hour
+	}
+	if settings.Index.ReindexBatchSize <= 0 {
+		settings.Index.ReindexBatchSize = 500
+	}
 	if settings.Security.CredentialCachePrefix == "" {
 		settings.Security.CredentialCachePrefix = "mcp:files:cred"
 	}
@@ -38,3 +38,26 @@ func TestLegacyConfigConfigured(t *testing.T) {
 	gconfig.Shared.Set(legacyRootKey, map[string]any{"list_limit_default": 12})
 	require.True(t, LegacyConfigConfigured())
 }
+
+// TestLoadSettingsFromConfigLegacyFallback verifies settings added to the RAG tree are
+// still read from the legacy tree. It is not parallel because TestLegacyConfigConfigured
+// replaces the whole legacy tree.
+func TestLoadSettingsFromConfigLegacyFallback(t *testing.T) {
+	keys := map[string]any{
+		"settings.mcp.files.security.admin_token":     "legacy-admin",
+		"settings.mcp.files.index.reindex.batch_size": 77,
+		"settings.mcp.files.grep.limit_max":           333,
+		"settings.mcp.files.snapshot.max_per_project": 9,
+	}
+	for key, value := range keys {
+		old := gconfig.Shared.Get(key)
+		defer gconfig.Shared.Set(key, old)
+		gconfig.Shared.Set(key, value)
+	}
+
+	settings := LoadSettingsFromConfig()
+	require.Equal(t, "legacy-admin", settings.Security.AdminToken)
+	require.Equal(t, 77, settings.Index.ReindexBatchSize)
+	require.Equal(t, 333, settings.Grep.LimitMax)
+	require.Equal(t, 9, settings.Snapshot.MaxPerProject)
+}
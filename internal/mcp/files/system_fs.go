@@ -55,7 +55,11 @@ func (s *systemFS) Read(ctx context.Context, project, path string) ([]byte, erro
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	return []byte(res.Content), nil
+	data, err := DecodeContent(res.Content, res.ContentEncoding)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return data, nil
 }
 
 // Write replaces the content at path within the system namespace. Mode is
@@ -22,14 +22,19 @@ const (
 	WriteModeOverwrite WriteMode = "OVERWRITE"
 	// WriteModeTruncate clears the file before writing.
 	WriteModeTruncate WriteMode = "TRUNCATE"
+	// WriteModePatch applies a unified diff or JSON search/replace hunks to the
+	// current content.
+	WriteModePatch WriteMode = "PATCH"
 )
 
 // FileEntry summarizes a file or directory path for file_list responses.
 type FileEntry struct {
-	Name      string    `json:"name"`
-	Path      string    `json:"path"`
-	Type      FileType  `json:"type"`
-	Size      int64     `json:"size"`
+	Name string   `json:"name"`
+	Path string   `json:"path"`
+	Type FileType `json:"type"`
+	Size int64    `json:"size"`
+	// Revision is set for files only; pass it as if_match to guard a later change.
+	Revision  int64     `json:"revision,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -56,22 +61,46 @@ type AuthContext struct {
 
 // StatResult returns the file_stat outcome.
 type StatResult struct {
-	Exists    bool
-	Type      FileType
-	Size      int64
+	Exists   bool
+	Type     FileType
+	Size     int64
+	MimeType string
+	// Revision is the file's current revision; zero for directories and missing paths.
+	Revision  int64
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
 
-// ReadResult returns the file_read payload.
+// ReadResult returns the file_read payload. Binary content is returned base64 encoded.
 type ReadResult struct {
 	Content         string
 	ContentEncoding string
+	MimeType        string
+	Revision        int64
 }
 
 // WriteResult returns the file_write outcome.
 type WriteResult struct {
 	BytesWritten int64
+	// Revision is the file's revision after the write.
+	Revision int64
+}
+
+// ProjectUsage reports the storage used by one project.
+type ProjectUsage struct {
+	// LogicalBytes is the total size of the live files, which MaxProjectBytes bounds.
+	LogicalBytes int64
+	// StoredBytes counts every distinct blob referenced by the project's files, deleted
+	// files, versions and snapshots once, which MaxProjectStoredBytes bounds.
+	StoredBytes  int64
+	FileCount    int64
+	VersionCount int64
+}
+
+// GarbageCollectResult reports what one blob garbage collection pass removed.
+type GarbageCollectResult struct {
+	PurgedFiles  int64
+	DeletedBlobs int64
 }
 
 // DeleteResult returns the file_delete outcome.
@@ -90,9 +119,272 @@ type ListResult struct {
 	HasMore bool
 }
 
+// GrepMatch is one line matched by file_grep. The byte offsets locate the first match
+// on the line and can be passed to file_read.
+type GrepMatch struct {
+	FilePath           string   `json:"file_path"`
+	LineNumber         int      `json:"line_number"`
+	FileSeekStartBytes int64    `json:"file_seek_start_bytes"`
+	FileSeekEndBytes   int64    `json:"file_seek_end_bytes"`
+	Line               string   `json:"line"`
+	ContextBefore      []string `json:"context_before,omitempty"`
+	ContextAfter       []string `json:"context_after,omitempty"`
+}
+
+const (
+	// GrepTruncatedMatchLimit reports that more lines matched than the request limit.
+	GrepTruncatedMatchLimit = "match_limit"
+	// GrepTruncatedScanLimit reports that files were skipped by the byte budget.
+	GrepTruncatedScanLimit = "scan_limit"
+)
+
+// GrepResult returns the file_grep outcome.
+type GrepResult struct {
+	Matches      []GrepMatch
+	FilesScanned int
+	BytesScanned int64
+	Truncated    bool
+	// TruncatedReason is GrepTruncatedMatchLimit or GrepTruncatedScanLimit when Truncated.
+	TruncatedReason string
+	// FilesSkipped counts files left unscanned because they did not fit the byte budget.
+	FilesSkipped int
+	// SkippedPaths lists the first skipped files in path order.
+	SkippedPaths []string
+}
+
+// SnapshotChange classifies how a path differs between a snapshot and the live tree.
+type SnapshotChange string
+
+const (
+	// SnapshotChangeAdded marks a path created since the snapshot; a restore deletes it.
+	SnapshotChangeAdded SnapshotChange = "added"
+	// SnapshotChangeDeleted marks a path deleted since the snapshot; a restore recreates it.
+	SnapshotChangeDeleted SnapshotChange = "deleted"
+	// SnapshotChangeModified marks a path whose content changed since the snapshot.
+	SnapshotChangeModified SnapshotChange = "modified"
+)
+
+// SnapshotDiffEntry is one path that differs between a snapshot and the live tree.
+type SnapshotDiffEntry struct {
+	Path   string         `json:"path"`
+	Change SnapshotChange `json:"change"`
+}
+
+// SnapshotRestoreResult returns the outcome of a snapshot or point-in-time restore.
+type SnapshotRestoreResult struct {
+	// Restored lists the paths rewritten with their snapshot content.
+	Restored []string
+	// Deleted lists the paths removed because they did not exist at the restore point.
+	Deleted []string
+	// Unchanged counts the paths that already matched the restore point.
+	Unchanged int
+}
+
+// ArchiveFormat names the container of a project export.
+type ArchiveFormat string
+
+const (
+	// ArchiveFormatTarGz is a gzip-compressed tar archive.
+	ArchiveFormatTarGz ArchiveFormat = "tar.gz"
+	// ArchiveFormatZip is a zip archive.
+	ArchiveFormatZip ArchiveFormat = "zip"
+)
+
+// ExportOptions selects what ExportProject writes.
+type ExportOptions struct {
+	Format ArchiveFormat
+	// Prefix limits the export to the files below a directory; empty exports the project.
+	Prefix string
+	// IncludeVersions adds the retained version history of every exported path.
+	IncludeVersions bool
+	// IncludeDeleted adds soft-deleted files that garbage collection has not purged yet.
+	IncludeDeleted bool
+}
+
+// ExportResult counts the records written to an export archive.
+type ExportResult struct {
+	Files    int
+	Versions int
+	Deleted  int
+}
+
+// ImportOptions controls how ImportProject applies an archive.
+type ImportOptions struct {
+	Format ArchiveFormat
+	// Overwrite replaces existing files at archived paths instead of failing the import.
+	Overwrite bool
+}
+
+// ImportResult returns the outcome of an archive import.
+type ImportResult struct {
+	// Files lists the paths written from the archive.
+	Files []string
+	// Versions counts the version history records imported.
+	Versions int
+	// Deleted counts the deleted-file tombstones imported.
+	Deleted int
+}
+
+// SearchFilter narrows file_search results. The zero value matches every chunk.
+type SearchFilter struct {
+	// UpdatedAfter and UpdatedBefore bound the file's updated_at; UpdatedAfter is
+	// inclusive and UpdatedBefore exclusive.
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+	// Extensions keeps files ending in one of these extensions, such as ".md" or "go".
+	Extensions []string
+	// IncludeGlobs keeps files matching any of these file_grep style globs, and
+	// ExcludeGlobs drops files matching any of them.
+	IncludeGlobs []string
+	ExcludeGlobs []string
+	// MinScore drops chunks whose score is lower. Indexed results compare it with the
+	// fused score, which lies in 0..1 relative to the candidate pool, or with the rerank
+	// score when a reranker is configured. The raw-file fallback compares it with the
+	// fraction of query tokens the file contains.
+	MinScore float64
+}
+
+// SearchFacets counts the chunks that matched a search, before the result limit, by
+// parent directory and by lowercase file extension ("" for none). Indexed searches count
+// only the ranked candidate pool, so facets are a sample for large projects rather
+// than exact totals.
+type SearchFacets struct {
+	Directories map[string]int `json:"directories"`
+	Extensions  map[string]int `json:"extensions"`
+}
+
 // SearchResult returns the file_search outcome.
 type SearchResult struct {
 	Chunks []ChunkEntry
+	Facets SearchFacets
+}
+
+// ProjectEmbedding reports the embedding provider a project is indexed and searched with.
+type ProjectEmbedding struct {
+	// Provider is the configured provider name; empty means the default provider.
+	Provider string `json:"provider"`
+	// Model is the model of the resolved provider, or empty when semantic search is
+	// not configured.
+	Model string `json:"model"`
+}
+
+// ReindexScope selects the files a re-index run covers. An empty APIKeyHash covers
+// every API key and an empty Project every project of the key.
+type ReindexScope struct {
+	APIKeyHash string
+	Project    string
+	// APIKey is the raw key APIKeyHash was derived from. When set, the run stores
+	// credential envelopes so providers billed to the key can embed without a write.
+	APIKey string
+}
+
+// IndexFreshness compares how long live writes waited for indexing with
+// IndexSettings.FreshnessSLO.
+type IndexFreshness struct {
+	SLOSeconds float64 `json:"slo_seconds"`
+	// Jobs is the number of live write jobs the p95 was computed over.
+	Jobs       int     `json:"jobs"`
+	P95Seconds float64 `json:"p95_seconds"`
+	WithinSLO  bool    `json:"within_slo"`
+}
+
+// ReindexRun reports the progress of a re-index run.
+type ReindexRun struct {
+	ID          int64      `json:"id"`
+	APIKeyHash  string     `json:"apikey_hash,omitempty"`
+	Project     string     `json:"project,omitempty"`
+	Status      string     `json:"status"`
+	TotalJobs   int64      `json:"total_jobs"`
+	Pending     int64      `json:"pending"`
+	Processing  int64      `json:"processing"`
+	Done        int64      `json:"done"`
+	Failed      int64      `json:"failed"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	// Freshness covers the live writes indexed while the run was active.
+	Freshness IndexFreshness `json:"freshness"`
+	// Skipped lists the projects an all-keys run left out; see ReindexSkippedProject.
+	Skipped []ReindexSkippedProject `json:"skipped,omitempty"`
+}
+
+// ReindexSkippedProject is a project an all-keys re-index run did not enqueue: its
+// embedding provider bills the owner's API key, which such runs do not carry. Start
+// a run with that key to re-index it.
+type ReindexSkippedProject struct {
+	APIKeyHash string `json:"apikey_hash"`
+	Project    string `json:"project"`
+	Model      string `json:"model"`
+	Files      int64  `json:"files"`
+}
+
+// IndexJobStatus describes a retrying or failed index job.
+type IndexJobStatus struct {
+	ID           int64     `json:"id"`
+	Path         string    `json:"path"`
+	Operation    string    `json:"operation"`
+	Status       string    `json:"status"`
+	RetryCount   int       `json:"retry_count"`
+	LastError    string    `json:"last_error"`
+	ReindexRunID int64     `json:"reindex_run_id,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	// UpdatedAt is when the job failed, or for retrying jobs when it last failed.
+	UpdatedAt time.Time `json:"updated_at"`
+	// AvailableAt is when a retrying job is attempted next.
+	AvailableAt time.Time `json:"available_at"`
+}
+
+// IndexBacklog compares the oldest write not yet indexed with IndexSettings.FreshnessSLO.
+type IndexBacklog struct {
+	SLOSeconds float64 `json:"slo_seconds"`
+	// OldestUnindexedAt is when the oldest pending, processing or failed write was made;
+	// nil when every write is indexed.
+	OldestUnindexedAt      *time.Time `json:"oldest_unindexed_at,omitempty"`
+	OldestUnindexedPath    string     `json:"oldest_unindexed_path,omitempty"`
+	OldestUnindexedSeconds float64    `json:"oldest_unindexed_seconds"`
+	WithinSLO              bool       `json:"within_slo"`
+}
+
+// IndexContextualizerStatus reports the chunk contextualizer in effect.
+type IndexContextualizerStatus struct {
+	Enabled    bool   `json:"enabled"`
+	Model      string `json:"model,omitempty"`
+	TimeoutMS  int64  `json:"timeout_ms,omitempty"`
+	ChunkBytes int    `json:"chunk_bytes"`
+}
+
+// SearchRerankStatus reports the search reranker in effect. Without one, results are
+// ordered by the weighted semantic and lexical scores.
+type SearchRerankStatus struct {
+	Enabled        bool    `json:"enabled"`
+	Model          string  `json:"model,omitempty"`
+	TimeoutMS      int64   `json:"timeout_ms,omitempty"`
+	SemanticWeight float64 `json:"semantic_weight"`
+	LexicalWeight  float64 `json:"lexical_weight"`
+}
+
+// IndexStatus reports the search index health of a project.
+type IndexStatus struct {
+	Project string `json:"project"`
+	// Files counts active files; IndexedFiles those with chunk rows.
+	Files        int64 `json:"files"`
+	IndexedFiles int64 `json:"indexed_files"`
+	Chunks       int64 `json:"chunks"`
+	// Embeddings counts chunk vectors of the project's current model; StaleEmbeddings
+	// those of other models, which semantic search skips until a re-index.
+	Embeddings      int64            `json:"embeddings"`
+	StaleEmbeddings int64            `json:"stale_embeddings"`
+	Embedding       ProjectEmbedding `json:"embedding"`
+	// Pending includes Retrying jobs waiting for their next attempt. Failed only
+	// counts jobs whose file was not indexed by a later job.
+	Pending    int64 `json:"pending"`
+	Processing int64 `json:"processing"`
+	Retrying   int64 `json:"retrying"`
+	Failed     int64 `json:"failed"`
+	// Jobs lists the most recent retrying and failed jobs.
+	Jobs           []IndexJobStatus          `json:"jobs"`
+	Freshness      IndexBacklog              `json:"freshness"`
+	Contextualizer IndexContextualizerStatus `json:"contextualizer"`
+	Rerank         SearchRerankStatus        `json:"rerank"`
 }
 
 // WriteOpts modulates non-default Write behavior. Zero value preserves today's behavior.
@@ -102,4 +394,10 @@ type WriteOpts struct {
 	SkipRAGIndex bool
 	// SystemOwner is populated by SystemFS only; user paths leave it empty.
 	SystemOwner string
+	// MimeType is the caller-declared media type. When empty it is detected from the
+	// path extension and the content.
+	MimeType string
+	// IfMatch makes the write conditional on the file's current revision; see
+	// WithIfMatch for the accepted values. SystemOwner writes ignore it.
+	IfMatch string
 }
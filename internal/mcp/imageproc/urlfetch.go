@@ -33,6 +33,11 @@ type URLFetchConfig struct {
 	// that re-checks the resolved IP at connect time. Tests typically leave
 	// this nil to use the production behavior.
 	DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+	// Accept is sent as the Accept request header. Default: "image/*".
+	Accept string
+	// UserAgent is sent as the User-Agent request header.
+	// Default: "laisky-mcp-image-fetcher/1.0".
+	UserAgent string
 }
 
 // DefaultURLFetchConfig returns a conservative configuration.
@@ -76,6 +81,12 @@ func NewURLFetcher(cfg URLFetchConfig) *URLFetcher {
 	if cfg.MaxBodyBytes <= 0 {
 		cfg.MaxBodyBytes = 20 * 1024 * 1024
 	}
+	if cfg.Accept == "" {
+		cfg.Accept = "image/*"
+	}
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = "laisky-mcp-image-fetcher/1.0"
+	}
 	return &URLFetcher{cfg: cfg}
 }
 
@@ -129,8 +140,8 @@ func (f *URLFetcher) Fetch(ctx context.Context, url string) (FetchResult, error)
 	if err != nil {
 		return FetchResult{}, laiskyerr.Wrap(ErrURLFetchFailed, err.Error())
 	}
-	req.Header.Set("Accept", "image/*")
-	req.Header.Set("User-Agent", "laisky-mcp-image-fetcher/1.0")
+	req.Header.Set("Accept", f.cfg.Accept)
+	req.Header.Set("User-Agent", f.cfg.UserAgent)
 
 	resp, err := client.Do(req)
 	if err != nil {
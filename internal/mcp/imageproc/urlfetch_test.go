@@ -143,3 +143,31 @@ func TestFetchTooManyRedirects(t *testing.T) {
 	require.Error(t, err)
 	require.True(t, errs.Is(err, ErrURLFetchFailed))
 }
+
+func TestFetchRequestHeaders(t *testing.T) {
+	var accept, userAgent atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept.Store(r.Header.Get("Accept"))
+		userAgent.Store(r.Header.Get("User-Agent"))
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	fetch := func(cfg URLFetchConfig) {
+		cfg.AllowHTTP = true
+		cfg.LookupHost = staticResolver(net.ParseIP("1.1.1.1"))
+		cfg.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, srv.Listener.Addr().String())
+		}
+		_, err := NewURLFetcher(cfg).Fetch(context.Background(), strings.Replace(srv.URL, "http://127.0.0.1", "http://example.com", 1))
+		require.NoError(t, err)
+	}
+
+	fetch(URLFetchConfig{})
+	require.Equal(t, "image/*", accept.Load())
+	require.Equal(t, "laisky-mcp-image-fetcher/1.0", userAgent.Load())
+
+	fetch(URLFetchConfig{Accept: "text/html", UserAgent: "page-fetcher/1.0"})
+	require.Equal(t, "text/html", accept.Load())
+	require.Equal(t, "page-fetcher/1.0", userAgent.Load())
+}
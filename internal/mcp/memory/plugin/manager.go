@@ -184,12 +184,17 @@ func (m *Manager) Read(ctx context.Context, auth files.AuthContext, project, pat
 
 // Write routes file_write to the selected plugin.
 func (m *Manager) Write(ctx context.Context, auth files.AuthContext, project, path, content, contentEncoding string, offset int64, mode files.WriteMode) (files.WriteResult, error) {
+	return m.WriteWith(ctx, auth, project, path, content, contentEncoding, offset, mode, files.WriteOpts{})
+}
+
+// WriteWith routes file_write with WriteOpts to the selected plugin.
+func (m *Manager) WriteWith(ctx context.Context, auth files.AuthContext, project, path, content, contentEncoding string, offset int64, mode files.WriteMode, opts files.WriteOpts) (files.WriteResult, error) {
 	item, err := m.Resolve(ctx, auth, project, "")
 	if err != nil {
 		return files.WriteResult{}, err
 	}
 
-	return item.Write(ctx, auth, project, path, content, contentEncoding, offset, mode)
+	return WriteWithOpts(ctx, item, auth, project, path, content, contentEncoding, offset, mode, opts)
 }
 
 // Delete routes file_delete to the selected plugin.
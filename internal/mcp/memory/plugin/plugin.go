@@ -60,6 +60,26 @@ type Plugin interface {
 	Stop(context.Context) error
 }
 
+// OptsWriter is implemented by plugins whose writes accept files.WriteOpts,
+// such as a caller-declared MIME type.
+type OptsWriter interface {
+	WriteWith(context.Context, files.AuthContext, string, string, string, string, int64, files.WriteMode, files.WriteOpts) (files.WriteResult, error)
+}
+
+// WriteWithOpts writes through p.WriteWith when p accepts WriteOpts and falls back to
+// p.Write, which applies the plugin's defaults, otherwise. A plugin without WriteWith
+// cannot honor an if_match precondition, so such a write is rejected rather than
+// applied unconditionally.
+func WriteWithOpts(ctx context.Context, p Plugin, auth files.AuthContext, project, path, content, contentEncoding string, offset int64, mode files.WriteMode, opts files.WriteOpts) (files.WriteResult, error) {
+	if writer, ok := p.(OptsWriter); ok {
+		return writer.WriteWith(ctx, auth, project, path, content, contentEncoding, offset, mode, opts)
+	}
+	if opts.IfMatch != "" {
+		return files.WriteResult{}, files.NewError(files.ErrCodeInvalidArgument, "if_match is not supported by memory plugin "+p.Name(), false)
+	}
+	return p.Write(ctx, auth, project, path, content, contentEncoding, offset, mode)
+}
+
 type overrideContextKey struct{}
 
 // WithOverride stores a per-call plugin override in context for manager-based routing.
@@ -173,15 +173,24 @@ func (s *ShadowPlugin) List(ctx context.Context, auth files.AuthContext, project
 
 // Write applies the mutation to live first; on success it dual-writes to shadow.
 func (s *ShadowPlugin) Write(ctx context.Context, auth files.AuthContext, project, path, content, contentEncoding string, offset int64, mode files.WriteMode) (files.WriteResult, error) {
+	return s.WriteWith(ctx, auth, project, path, content, contentEncoding, offset, mode, files.WriteOpts{})
+}
+
+// WriteWith is Write with WriteOpts, forwarded to both live and shadow. The if_match
+// precondition only guards the live write: shadow revisions are unrelated to the
+// revision the caller read.
+func (s *ShadowPlugin) WriteWith(ctx context.Context, auth files.AuthContext, project, path, content, contentEncoding string, offset int64, mode files.WriteMode, opts files.WriteOpts) (files.WriteResult, error) {
 	liveStart := time.Now()
-	res, err := s.live.Write(ctx, auth, project, path, content, contentEncoding, offset, mode)
+	res, err := WriteWithOpts(ctx, s.live, auth, project, path, content, contentEncoding, offset, mode, opts)
 	liveDur := time.Since(liveStart)
 	if err != nil {
 		return res, err
 	}
 
+	shadowOpts := opts
+	shadowOpts.IfMatch = ""
 	s.fireMutation("write", project, path, liveDur, func(opCtx context.Context) error {
-		_, e := s.shadow.Write(opCtx, auth, project, path, content, contentEncoding, offset, mode)
+		_, e := WriteWithOpts(opCtx, s.shadow, auth, project, path, content, contentEncoding, offset, mode, shadowOpts)
 		return e
 	})
 	return res, nil
@@ -43,6 +43,8 @@ type shadowFakePlugin struct {
 
 	searchDelay time.Duration
 
+	writeOpts atomic.Value // files.WriteOpts of the last WriteWith call
+
 	startErr error
 	stopErr  error
 }
@@ -79,6 +81,10 @@ func (p *shadowFakePlugin) Write(context.Context, files.AuthContext, string, str
 	atomic.AddInt32(&p.writeCount, 1)
 	return p.writeRes, p.writeErr
 }
+func (p *shadowFakePlugin) WriteWith(ctx context.Context, auth files.AuthContext, project, path, content, contentEncoding string, offset int64, mode files.WriteMode, opts files.WriteOpts) (files.WriteResult, error) {
+	p.writeOpts.Store(opts)
+	return p.Write(ctx, auth, project, path, content, contentEncoding, offset, mode)
+}
 func (p *shadowFakePlugin) Delete(context.Context, files.AuthContext, string, string, bool) (files.DeleteResult, error) {
 	atomic.AddInt32(&p.deleteCount, 1)
 	return p.delRes, p.delErr
@@ -162,6 +168,24 @@ func TestShadowPluginDualWritesMutation(t *testing.T) {
 	require.Empty(t, mutations[0].LiveErr)
 }
 
+// TestShadowPluginIfMatchGuardsLiveOnly checks the caller's if_match reaches live
+// only; the shadow store keeps its own revisions.
+func TestShadowPluginIfMatchGuardsLiveOnly(t *testing.T) {
+	t.Parallel()
+
+	live := newFake("live")
+	shadow := newFake("shadow")
+	wrap, _ := newShadowFromFakes(t, live, shadow)
+	ctx := context.Background()
+
+	_, err := wrap.WriteWith(ctx, files.AuthContext{}, "proj", "/x", "hi", "utf8", 0, files.WriteModeTruncate, files.WriteOpts{MimeType: "text/plain", IfMatch: "4"})
+	require.NoError(t, err)
+	require.NoError(t, wrap.Stop(ctx))
+
+	require.Equal(t, files.WriteOpts{MimeType: "text/plain", IfMatch: "4"}, live.writeOpts.Load())
+	require.Equal(t, files.WriteOpts{MimeType: "text/plain"}, shadow.writeOpts.Load())
+}
+
 // TestShadowPluginLiveErrorBlocksShadow checks that on a live failure the
 // shadow plugin is not called — we must never let the shadow drift ahead of a
 // failed live mutation.
@@ -22,6 +22,7 @@ const (
 	WriteModeAppend    = files.WriteModeAppend
 	WriteModeOverwrite = files.WriteModeOverwrite
 	WriteModeTruncate  = files.WriteModeTruncate
+	WriteModePatch     = files.WriteModePatch
 
 	FileTypeFile      = files.FileTypeFile
 	FileTypeDirectory = files.FileTypeDirectory
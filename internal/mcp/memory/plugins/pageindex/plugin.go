@@ -142,10 +142,16 @@ func (p *Plugin) Read(ctx context.Context, auth files.AuthContext, project, path
 
 // Write forwards to userFS.WriteWith with SkipRAGIndex=true and triggers indexing.
 func (p *Plugin) Write(ctx context.Context, auth files.AuthContext, project, path, content, encoding string, offset int64, mode files.WriteMode) (files.WriteResult, error) {
+	return p.WriteWith(ctx, auth, project, path, content, encoding, offset, mode, files.WriteOpts{})
+}
+
+// WriteWith is Write with caller WriteOpts; SkipRAGIndex is always set.
+func (p *Plugin) WriteWith(ctx context.Context, auth files.AuthContext, project, path, content, encoding string, offset int64, mode files.WriteMode, opts files.WriteOpts) (files.WriteResult, error) {
 	if isLongDocPath(path) && mode == files.WriteModeOverwrite && offset > 0 {
 		return files.WriteResult{}, errors.New("INVALID_ARGUMENT: pageindex rejects OVERWRITE@offset on .pdf/.md paths; use file_delete then file_write instead")
 	}
-	res, err := p.userFS.WriteWith(ctx, auth, project, path, content, encoding, offset, mode, files.WriteOpts{SkipRAGIndex: true})
+	opts.SkipRAGIndex = true
+	res, err := p.userFS.WriteWith(ctx, auth, project, path, content, encoding, offset, mode, opts)
 	if err != nil {
 		return res, err
 	}
@@ -155,7 +161,21 @@ func (p *Plugin) Write(ctx context.Context, auth files.AuthContext, project, pat
 	if p.indexer == nil {
 		return res, nil
 	}
-	bytesContent := []byte(content)
+	// WriteWith already rejected content that does not decode, e.g. malformed base64 PDFs.
+	bytesContent, err := files.DecodeContent(content, encoding)
+	if err != nil {
+		return res, err
+	}
+	if mode == files.WriteModePatch {
+		// content is the patch, not the document; index what the patch produced.
+		current, readErr := p.userFS.Read(ctx, auth, project, path, 0, -1)
+		if readErr != nil {
+			return res, readErr
+		}
+		if bytesContent, err = files.DecodeContent(current.Content, current.ContentEncoding); err != nil {
+			return res, err
+		}
+	}
 	kind := KindPDF
 	if strings.HasSuffix(strings.ToLower(path), ".md") {
 		kind = KindMarkdown
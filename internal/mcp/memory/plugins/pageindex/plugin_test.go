@@ -2,11 +2,17 @@ package pageindex
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
 	"strings"
 	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
 
 	"github.com/Laisky/laisky-blog-graphql/internal/mcp/files"
 	mcpplugin "github.com/Laisky/laisky-blog-graphql/internal/mcp/memory/plugin"
+	"github.com/Laisky/laisky-blog-graphql/library/log"
 )
 
 func TestPluginInterfaceAssertion(_ *testing.T) {
@@ -59,6 +65,84 @@ func TestPluginRejectOverwriteOffsetOnMD(t *testing.T) {
 	}
 }
 
+// TestPluginWriteIfMatchStoresTree verifies an if_match precondition guards only the
+// user file: the tree and index entries the plugin keeps in its system namespace are
+// still written and removed.
+func TestPluginWriteIfMatchStoresTree(t *testing.T) {
+	dsn := fmt.Sprintf("file:%s-%d?mode=memory&cache=shared", t.Name(), time.Now().UTC().UnixNano())
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	fileSettings := files.LoadSettingsFromConfig()
+	fileSettings.Search.Enabled = false
+	userFS, err := files.NewService(db, fileSettings, nil, nil, nil, nil, log.Logger.Named("pageindex_plugin_test"), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sysFS, err := userFS.SystemNamespace("pageindex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tk, err := NewTokenizer("gpt-5.4-mini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := New(PluginDeps{UserFS: userFS, SystemFS: sysFS, Settings: defaultTestSettings(), LLM: NewStubLLM(), Tokenizer: tk, Logger: log.Logger.Named("pageindex_plugin_test")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	auth := files.AuthContext{APIKey: "sk-test", APIKeyHash: "hash-test", UserIdentity: "user:test"}
+	content := "# Title\n\nIntro.\n\n## Section\n\nBody text.\n"
+	for _, ifMatch := range []string{"0", "1"} {
+		if _, err := p.WriteWith(ctx, auth, "proj", "/notes.md", content, "utf-8", 0, files.WriteModeTruncate, files.WriteOpts{IfMatch: ifMatch}); err != nil {
+			t.Fatalf("write with if_match %q: %v", ifMatch, err)
+		}
+	}
+
+	ix, err := p.store.GetIndex(ctx, "proj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := ix["/notes.md"]
+	if !ok {
+		t.Fatalf("expected an index entry for /notes.md, got %+v", ix)
+	}
+	tree, err := p.store.GetTree(ctx, "proj", entry.DocID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree == nil || len(tree.Structure) == 0 {
+		t.Fatalf("expected a stored tree, got %+v", tree)
+	}
+
+	_, err = p.WriteWith(ctx, auth, "proj", "/notes.md", content, "utf-8", 0, files.WriteModeTruncate, files.WriteOpts{IfMatch: "1"})
+	if !files.IsCode(err, files.ErrCodePreconditionFailed) {
+		t.Fatalf("expected PRECONDITION_FAILED for a stale if_match, got %v", err)
+	}
+
+	// The rename moves the user file to revision 3 while its tree stays at revision 2.
+	if _, err := p.Rename(ctx, auth, "proj", "/notes.md", "/moved.md", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Delete(files.WithIfMatch(ctx, "3"), auth, "proj", "/moved.md", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.store.GetTree(ctx, "proj", entry.DocID); err == nil {
+		t.Fatal("expected the tree to be deleted with its file")
+	}
+	if ix, err = p.store.GetIndex(ctx, "proj"); err != nil {
+		t.Fatal(err)
+	}
+	if len(ix) != 0 {
+		t.Fatalf("expected an empty index, got %+v", ix)
+	}
+}
+
 // TestIsLongDocPathClassification documents the path-suffix gate that wraps
 // every long-doc-only check (P06/P13/P18 share this gate).
 func TestIsLongDocPathClassification(t *testing.T) {
@@ -68,6 +68,11 @@ func (p *Plugin) Write(ctx context.Context, auth files.AuthContext, project, pat
 	return p.inner.Write(ctx, auth, project, path, content, contentEncoding, offset, mode)
 }
 
+// WriteWith delegates file_write with WriteOpts to the wrapped file service.
+func (p *Plugin) WriteWith(ctx context.Context, auth files.AuthContext, project, path, content, contentEncoding string, offset int64, mode files.WriteMode, opts files.WriteOpts) (files.WriteResult, error) {
+	return p.inner.WriteWith(ctx, auth, project, path, content, contentEncoding, offset, mode, opts)
+}
+
 // Delete delegates file_delete to the wrapped file service.
 func (p *Plugin) Delete(ctx context.Context, auth files.AuthContext, project, path string, recursive bool) (files.DeleteResult, error) {
 	return p.inner.Delete(ctx, auth, project, path, recursive)
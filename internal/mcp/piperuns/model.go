@@ -0,0 +1,51 @@
+package piperuns
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Status enumerations for asynchronous pipeline runs.
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCanceled  = "canceled"
+)
+
+const (
+	// MaxActiveRunsPerKey caps the number of pending or running pipelines a single API key can own.
+	MaxActiveRunsPerKey = 10
+	// DefaultStaleAfter is how long a running pipeline may go without a heartbeat
+	// before it is reported as interrupted.
+	DefaultStaleAfter = 2 * time.Minute
+)
+
+// Run persists the state of a single asynchronous mcp_pipe execution.
+type Run struct {
+	ID              uuid.UUID
+	APIKeyHash      string
+	Status          string
+	Spec            []byte
+	Steps           []byte
+	Result          []byte
+	ErrorMessage    string
+	CancelRequested bool
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	StartedAt       *time.Time
+	FinishedAt      *time.Time
+	HeartbeatAt     *time.Time
+}
+
+// Terminal reports whether the run has reached a final status.
+func (r *Run) Terminal() bool {
+	switch r.Status {
+	case StatusSucceeded, StatusFailed, StatusCanceled:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,378 @@
+package piperuns
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	errors "github.com/Laisky/errors/v2"
+	gutils "github.com/Laisky/go-utils/v6"
+	logSDK "github.com/Laisky/go-utils/v6/log"
+	"github.com/Laisky/zap"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Laisky/laisky-blog-graphql/library/log"
+)
+
+var (
+	// ErrRunNotFound indicates that the run does not exist or belongs to another API key.
+	ErrRunNotFound = errors.New("pipeline run not found")
+	// ErrRunNotActive indicates that a run already reached a terminal status, for
+	// example because it was marked failed after its worker stopped sending heartbeats.
+	ErrRunNotActive = errors.New("pipeline run is no longer active")
+	// ErrActiveRunLimit indicates that the API key already owns too many active runs.
+	ErrActiveRunLimit = errors.New("too many active pipeline runs")
+	// ErrMissingAPIKeyHash indicates that a run was requested without a caller identity.
+	ErrMissingAPIKeyHash = errors.New("api key hash is required")
+)
+
+// Clock provides the current time in UTC.
+type Clock func() time.Time
+
+// DB defines the database capabilities required by the pipeline run service.
+type DB interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// Service persists asynchronous mcp_pipe runs and their per-step progress.
+type Service struct {
+	db         DB
+	logger     logSDK.Logger
+	clock      Clock
+	staleAfter time.Duration
+}
+
+const runColumns = `id, api_key_hash, status, spec, steps, result, error_message, cancel_requested,
+	created_at, updated_at, started_at, finished_at, heartbeat_at`
+
+// NewService constructs a Service backed by the supplied PostgreSQL connection.
+func NewService(db DB, logger logSDK.Logger, clock Clock) (*Service, error) {
+	if db == nil {
+		return nil, errors.New("database is required")
+	}
+	if logger == nil {
+		logger = log.Logger.Named("pipe_run_service")
+	}
+	if clock == nil {
+		clock = func() time.Time {
+			return time.Now().UTC()
+		}
+	}
+
+	if err := runMigrations(context.Background(), db); err != nil {
+		return nil, errors.Wrap(err, "migrate mcp_pipe runs")
+	}
+
+	return &Service{db: db, logger: logger, clock: clock, staleAfter: DefaultStaleAfter}, nil
+}
+
+// Create stores a new pending run owned by apiKeyHash.
+func (s *Service) Create(ctx context.Context, apiKeyHash string, spec any) (*Run, error) {
+	if s == nil {
+		return nil, errors.New("pipe run service is nil")
+	}
+	apiKeyHash = strings.TrimSpace(apiKeyHash)
+	if apiKeyHash == "" {
+		return nil, ErrMissingAPIKeyHash
+	}
+
+	payload, err := json.Marshal(spec)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal pipe run spec")
+	}
+
+	now := s.clock()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "begin pipe run transaction")
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// Serialize submissions of one key so the count sees every run committed before it.
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, "mcp_pipe_runs:"+apiKeyHash); err != nil {
+		return nil, errors.Wrap(err, "lock pipe runs of api key")
+	}
+	var active int64
+	if err := tx.QueryRow(ctx, `
+		SELECT COUNT(*) FROM mcp_pipe_runs
+		WHERE api_key_hash = $1
+			AND status IN ('pending', 'running')
+			AND COALESCE(heartbeat_at, created_at) > $2
+	`, apiKeyHash, now.Add(-s.staleAfter)).Scan(&active); err != nil {
+		return nil, errors.Wrap(err, "count active pipe runs")
+	}
+	if active >= MaxActiveRunsPerKey {
+		return nil, ErrActiveRunLimit
+	}
+
+	run := &Run{
+		ID:         gutils.UUID7Bytes(),
+		APIKeyHash: apiKeyHash,
+		Status:     StatusPending,
+		Spec:       payload,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO mcp_pipe_runs (id, api_key_hash, status, spec, created_at, updated_at)
+		VALUES ($1, $2, $3, $4::jsonb, $5, $6)
+	`,
+		run.ID,
+		run.APIKeyHash,
+		run.Status,
+		string(run.Spec),
+		run.CreatedAt,
+		run.UpdatedAt,
+	); err != nil {
+		return nil, errors.Wrap(err, "create pipe run")
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, errors.Wrap(err, "commit pipe run")
+	}
+
+	s.logger.Debug("created pipe run", zap.String("run_id", run.ID.String()))
+	return run, nil
+}
+
+// MarkRunning transitions a pending run to running. It returns false when the
+// run was canceled before the worker picked it up.
+func (s *Service) MarkRunning(ctx context.Context, runID uuid.UUID) (bool, error) {
+	if s == nil {
+		return false, errors.New("pipe run service is nil")
+	}
+
+	now := s.clock()
+	tag, err := s.db.Exec(ctx, `
+		UPDATE mcp_pipe_runs
+		SET status = $2, started_at = $3, heartbeat_at = $3, updated_at = $3
+		WHERE id = $1 AND status = $4 AND NOT cancel_requested
+	`, runID, StatusRunning, now, StatusPending)
+	if err != nil {
+		return false, errors.Wrap(err, "mark pipe run running")
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// Heartbeat refreshes the liveness timestamp of a running run and, when steps
+// is not nil, stores the latest per-step results. It reports whether the owner
+// has requested cancellation.
+func (s *Service) Heartbeat(ctx context.Context, runID uuid.UUID, steps map[string]any) (bool, error) {
+	if s == nil {
+		return false, errors.New("pipe run service is nil")
+	}
+
+	var stepsPayload *string
+	if steps != nil {
+		encoded, err := json.Marshal(steps)
+		if err != nil {
+			return false, errors.Wrap(err, "marshal pipe run steps")
+		}
+		value := string(encoded)
+		stepsPayload = &value
+	}
+
+	now := s.clock()
+	var cancelRequested bool
+	err := s.db.QueryRow(ctx, `
+		UPDATE mcp_pipe_runs
+		SET steps = COALESCE($2::jsonb, steps), heartbeat_at = $3, updated_at = $3
+		WHERE id = $1 AND status = $4
+		RETURNING cancel_requested
+	`, runID, stepsPayload, now, StatusRunning).Scan(&cancelRequested)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, ErrRunNotFound
+		}
+		return false, errors.Wrap(err, "update pipe run heartbeat")
+	}
+	return cancelRequested, nil
+}
+
+// Finish records the final status, step results, and return value of a run. It
+// returns ErrRunNotActive without changes when the run already finished.
+func (s *Service) Finish(ctx context.Context, runID uuid.UUID, status string, steps map[string]any, result any, errMsg string) error {
+	if s == nil {
+		return errors.New("pipe run service is nil")
+	}
+	switch status {
+	case StatusSucceeded, StatusFailed, StatusCanceled:
+	default:
+		return errors.Errorf("invalid terminal status %q", status)
+	}
+
+	stepsPayload, err := json.Marshal(steps)
+	if err != nil {
+		return errors.Wrap(err, "marshal pipe run steps")
+	}
+	resultPayload, err := json.Marshal(result)
+	if err != nil {
+		return errors.Wrap(err, "marshal pipe run result")
+	}
+
+	// Use a detached context so the final state is stored even when the run was canceled.
+	ctx = context.WithoutCancel(ctx)
+	now := s.clock()
+	tag, err := s.db.Exec(ctx, `
+		UPDATE mcp_pipe_runs
+		SET status = $2, steps = $3::jsonb, result = $4::jsonb, error_message = $5,
+			finished_at = $6, heartbeat_at = $6, updated_at = $6
+		WHERE id = $1 AND status IN ('pending', 'running')
+	`, runID, status, string(stepsPayload), string(resultPayload), strings.TrimSpace(errMsg), now)
+	if err != nil {
+		return errors.Wrap(err, "finish pipe run")
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrRunNotActive
+	}
+
+	s.logger.Debug("finished pipe run", zap.String("run_id", runID.String()), zap.String("status", status))
+	return nil
+}
+
+// Get loads a run owned by apiKeyHash. Runs whose worker stopped sending
+// heartbeats are reported as failed.
+func (s *Service) Get(ctx context.Context, apiKeyHash string, runID string) (*Run, error) {
+	if s == nil {
+		return nil, errors.New("pipe run service is nil")
+	}
+	id, err := parseRunID(apiKeyHash, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	run, err := s.load(ctx, apiKeyHash, id)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if run.Terminal() {
+		return run, nil
+	}
+
+	lastSeen := run.CreatedAt
+	if run.HeartbeatAt != nil {
+		lastSeen = *run.HeartbeatAt
+	}
+	if s.clock().Sub(lastSeen) <= s.staleAfter {
+		return run, nil
+	}
+
+	now := s.clock()
+	if _, err := s.db.Exec(ctx, `
+		UPDATE mcp_pipe_runs
+		SET status = $2, error_message = $3, finished_at = $4, updated_at = $4
+		WHERE id = $1 AND status IN ('pending', 'running')
+	`, id, StatusFailed, "run interrupted: worker stopped reporting progress", now); err != nil {
+		return nil, errors.Wrap(err, "mark stale pipe run failed")
+	}
+	s.logger.Info("marked stale pipe run failed", zap.String("run_id", id.String()))
+
+	return s.load(ctx, apiKeyHash, id)
+}
+
+// RequestCancel flags a run owned by apiKeyHash for cancellation. Pending runs
+// are canceled immediately; running runs stop at the worker's next heartbeat.
+// Runs that already finished are returned unchanged.
+func (s *Service) RequestCancel(ctx context.Context, apiKeyHash string, runID string) (*Run, error) {
+	if s == nil {
+		return nil, errors.New("pipe run service is nil")
+	}
+	id, err := parseRunID(apiKeyHash, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.clock()
+	if _, err := s.db.Exec(ctx, `
+		UPDATE mcp_pipe_runs
+		SET cancel_requested = TRUE,
+			status = CASE WHEN status = 'pending' THEN 'canceled' ELSE status END,
+			finished_at = CASE WHEN status = 'pending' THEN $3 ELSE finished_at END,
+			updated_at = $3
+		WHERE id = $1 AND api_key_hash = $2 AND status IN ('pending', 'running')
+	`, id, apiKeyHash, now); err != nil {
+		return nil, errors.Wrap(err, "request pipe run cancel")
+	}
+
+	return s.load(ctx, apiKeyHash, id)
+}
+
+// load reads a run scoped to apiKeyHash.
+func (s *Service) load(ctx context.Context, apiKeyHash string, id uuid.UUID) (*Run, error) {
+	var run Run
+	err := s.db.QueryRow(ctx, `SELECT `+runColumns+` FROM mcp_pipe_runs WHERE id = $1 AND api_key_hash = $2`,
+		id, apiKeyHash,
+	).Scan(
+		&run.ID,
+		&run.APIKeyHash,
+		&run.Status,
+		&run.Spec,
+		&run.Steps,
+		&run.Result,
+		&run.ErrorMessage,
+		&run.CancelRequested,
+		&run.CreatedAt,
+		&run.UpdatedAt,
+		&run.StartedAt,
+		&run.FinishedAt,
+		&run.HeartbeatAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrRunNotFound
+		}
+		return nil, errors.Wrap(err, "load pipe run")
+	}
+	return &run, nil
+}
+
+// parseRunID validates the caller identity and run identifier.
+func parseRunID(apiKeyHash string, runID string) (uuid.UUID, error) {
+	if strings.TrimSpace(apiKeyHash) == "" {
+		return uuid.Nil, ErrMissingAPIKeyHash
+	}
+	id, err := uuid.Parse(strings.TrimSpace(runID))
+	if err != nil {
+		return uuid.Nil, ErrRunNotFound
+	}
+	return id, nil
+}
+
+// runMigrations creates the pipeline run table and indexes when absent.
+func runMigrations(ctx context.Context, db DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS mcp_pipe_runs (
+			id UUID PRIMARY KEY,
+			api_key_hash CHAR(64) NOT NULL,
+			status VARCHAR(16) NOT NULL,
+			spec JSONB NOT NULL,
+			steps JSONB,
+			result JSONB,
+			error_message TEXT NOT NULL DEFAULT '',
+			cancel_requested BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMPTZ NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL,
+			started_at TIMESTAMPTZ,
+			finished_at TIMESTAMPTZ,
+			heartbeat_at TIMESTAMPTZ
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_mcp_pipe_runs_api_key_status ON mcp_pipe_runs (api_key_hash, status)`,
+		`CREATE INDEX IF NOT EXISTS idx_mcp_pipe_runs_created_at ON mcp_pipe_runs (created_at DESC)`,
+	}
+
+	for _, stmt := range statements {
+		if _, err := db.Exec(ctx, stmt); err != nil {
+			return errors.Wrap(err, "execute pipe run migration")
+		}
+	}
+
+	return nil
+}
+
+var _ DB = (*pgxpool.Pool)(nil)
@@ -0,0 +1,170 @@
+package piperuns
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pashagolub/pgxmock/v4"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestService builds a Service over pgxmock with migrations satisfied.
+func newTestService(t *testing.T, now time.Time) (*Service, pgxmock.PgxPoolIface) {
+	t.Helper()
+
+	db, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	t.Cleanup(db.Close)
+
+	db.ExpectExec("CREATE TABLE IF NOT EXISTS mcp_pipe_runs").WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	db.ExpectExec("CREATE INDEX IF NOT EXISTS idx_mcp_pipe_runs_api_key_status").WillReturnResult(pgxmock.NewResult("CREATE INDEX", 0))
+	db.ExpectExec("CREATE INDEX IF NOT EXISTS idx_mcp_pipe_runs_created_at").WillReturnResult(pgxmock.NewResult("CREATE INDEX", 0))
+
+	svc, err := NewService(db, nil, func() time.Time { return now })
+	require.NoError(t, err)
+	return svc, db
+}
+
+// runRows returns a single-row result shaped like runColumns.
+func runRows(id uuid.UUID, status string, created time.Time, heartbeat *time.Time) *pgxmock.Rows {
+	return pgxmock.NewRows([]string{
+		"id", "api_key_hash", "status", "spec", "steps", "result", "error_message", "cancel_requested",
+		"created_at", "updated_at", "started_at", "finished_at", "heartbeat_at",
+	}).AddRow(
+		id, "hash", status, []byte(`{}`), []byte(`{"a":{"ok":true}}`), []byte(nil), "", false,
+		created, created, heartbeat, (*time.Time)(nil), heartbeat,
+	)
+}
+
+func TestServiceCreate(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	svc, db := newTestService(t, now)
+	ctx := context.Background()
+
+	db.ExpectBegin()
+	db.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock")).
+		WithArgs("mcp_pipe_runs:hash").
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	db.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM mcp_pipe_runs")).
+		WithArgs("hash", now.Add(-DefaultStaleAfter)).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(0)))
+	db.ExpectExec(regexp.QuoteMeta("INSERT INTO mcp_pipe_runs")).
+		WithArgs(pgxmock.AnyArg(), "hash", StatusPending, `{"steps":[]}`, now, now).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	db.ExpectCommit()
+
+	run, err := svc.Create(ctx, "hash", map[string]any{"steps": []any{}})
+	require.NoError(t, err)
+	require.Equal(t, StatusPending, run.Status)
+	require.NotEqual(t, uuid.Nil, run.ID)
+
+	db.ExpectBegin()
+	db.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock")).
+		WithArgs("mcp_pipe_runs:hash").
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	db.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM mcp_pipe_runs")).
+		WithArgs("hash", now.Add(-DefaultStaleAfter)).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(MaxActiveRunsPerKey)))
+	db.ExpectRollback()
+	_, err = svc.Create(ctx, "hash", map[string]any{})
+	require.ErrorIs(t, err, ErrActiveRunLimit)
+
+	_, err = svc.Create(ctx, " ", map[string]any{})
+	require.ErrorIs(t, err, ErrMissingAPIKeyHash)
+
+	require.NoError(t, db.ExpectationsWereMet())
+}
+
+func TestServiceGetScopesByAPIKey(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	svc, db := newTestService(t, now)
+	ctx := context.Background()
+	id := uuid.New()
+	heartbeat := now.Add(-time.Second)
+
+	db.ExpectQuery(regexp.QuoteMeta("FROM mcp_pipe_runs WHERE id = $1 AND api_key_hash = $2")).
+		WithArgs(id, "hash").
+		WillReturnRows(runRows(id, StatusRunning, now.Add(-time.Minute), &heartbeat))
+	run, err := svc.Get(ctx, "hash", id.String())
+	require.NoError(t, err)
+	require.Equal(t, StatusRunning, run.Status)
+	require.JSONEq(t, `{"a":{"ok":true}}`, string(run.Steps))
+
+	db.ExpectQuery(regexp.QuoteMeta("FROM mcp_pipe_runs WHERE id = $1 AND api_key_hash = $2")).
+		WithArgs(id, "other").
+		WillReturnRows(pgxmock.NewRows([]string{"id"}))
+	_, err = svc.Get(ctx, "other", id.String())
+	require.ErrorIs(t, err, ErrRunNotFound)
+
+	_, err = svc.Get(ctx, "hash", "not-a-uuid")
+	require.ErrorIs(t, err, ErrRunNotFound)
+
+	require.NoError(t, db.ExpectationsWereMet())
+}
+
+func TestServiceGetMarksStaleRunFailed(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	svc, db := newTestService(t, now)
+	ctx := context.Background()
+	id := uuid.New()
+	heartbeat := now.Add(-DefaultStaleAfter - time.Second)
+
+	db.ExpectQuery(regexp.QuoteMeta("FROM mcp_pipe_runs WHERE id = $1 AND api_key_hash = $2")).
+		WithArgs(id, "hash").
+		WillReturnRows(runRows(id, StatusRunning, heartbeat, &heartbeat))
+	db.ExpectExec(regexp.QuoteMeta("UPDATE mcp_pipe_runs")).
+		WithArgs(id, StatusFailed, pgxmock.AnyArg(), now).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	db.ExpectQuery(regexp.QuoteMeta("FROM mcp_pipe_runs WHERE id = $1 AND api_key_hash = $2")).
+		WithArgs(id, "hash").
+		WillReturnRows(runRows(id, StatusFailed, heartbeat, &heartbeat))
+
+	run, err := svc.Get(ctx, "hash", id.String())
+	require.NoError(t, err)
+	require.Equal(t, StatusFailed, run.Status)
+	require.True(t, run.Terminal())
+
+	require.NoError(t, db.ExpectationsWereMet())
+}
+
+func TestServiceHeartbeatReportsCancel(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	svc, db := newTestService(t, now)
+	ctx := context.Background()
+	id := uuid.New()
+
+	steps := `{"a":{"ok":true}}`
+	db.ExpectQuery(regexp.QuoteMeta("UPDATE mcp_pipe_runs")).
+		WithArgs(id, &steps, now, StatusRunning).
+		WillReturnRows(pgxmock.NewRows([]string{"cancel_requested"}).AddRow(true))
+
+	canceled, err := svc.Heartbeat(ctx, id, map[string]any{"a": map[string]any{"ok": true}})
+	require.NoError(t, err)
+	require.True(t, canceled)
+
+	require.NoError(t, db.ExpectationsWereMet())
+}
+
+func TestServiceFinishRejectsNonTerminalStatus(t *testing.T) {
+	svc, _ := newTestService(t, time.Now().UTC())
+
+	err := svc.Finish(context.Background(), uuid.New(), StatusRunning, nil, nil, "")
+	require.ErrorContains(t, err, "invalid terminal status")
+}
+
+func TestServiceFinishSkipsFinishedRun(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	svc, db := newTestService(t, now)
+	id := uuid.New()
+
+	db.ExpectExec(regexp.QuoteMeta("WHERE id = $1 AND status IN ('pending', 'running')")).
+		WithArgs(id, StatusSucceeded, `{}`, `null`, "", now).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	err := svc.Finish(context.Background(), id, StatusSucceeded, map[string]any{}, nil, "")
+	require.ErrorIs(t, err, ErrRunNotActive)
+	require.NoError(t, db.ExpectationsWereMet())
+}
@@ -0,0 +1,178 @@
+package rag
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	errors "github.com/Laisky/errors/v2"
+	gconfig "github.com/Laisky/go-config/v2"
+	logSDK "github.com/Laisky/go-utils/v6/log"
+)
+
+const (
+	// ProviderTypeOpenAI calls an OpenAI-compatible /v1/embeddings endpoint billed
+	// with the caller's API key.
+	ProviderTypeOpenAI = "openai"
+	// ProviderTypeOllama calls the /api/embed endpoint of an Ollama-style server.
+	ProviderTypeOllama = "ollama"
+	// ProviderTypeHash embeds in-process with HashEmbedder.
+	ProviderTypeHash = "hash"
+
+	// DefaultProviderName is the provider built from the settings.openai keys.
+	DefaultProviderName = "openai"
+
+	embeddingConfigKey = "settings.embedding"
+)
+
+// ErrUnknownEmbeddingProvider reports a provider name that is not configured.
+var ErrUnknownEmbeddingProvider = errors.New("unknown embedding provider")
+
+// providerNamePattern keeps provider names safe to store and show in URLs.
+var providerNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// Provider is an Embedder that reports which model its vectors come from and
+// whether it bills the caller's API key.
+type Provider interface {
+	Embedder
+	Model() string
+	RequiresAPIKey() bool
+}
+
+// ProviderSettings describes one configured embedding provider.
+type ProviderSettings struct {
+	// Name identifies the provider in project settings.
+	Name string `mapstructure:"name"`
+	// Type is ProviderTypeOpenAI, ProviderTypeOllama or ProviderTypeHash.
+	Type string `mapstructure:"type"`
+	// BaseURL is the endpoint of the openai and ollama types.
+	BaseURL string `mapstructure:"base_url"`
+	// Model is the embedding model of the openai and ollama types.
+	Model string `mapstructure:"model"`
+	// Dimensions is the vector size of the hash type. Defaults to DefaultHashDimensions.
+	Dimensions int `mapstructure:"dimensions"`
+}
+
+// EmbeddingSettings lists the embedding providers and names the default one.
+type EmbeddingSettings struct {
+	Default   string             `mapstructure:"default"`
+	Providers []ProviderSettings `mapstructure:"providers"`
+}
+
+// LoadEmbeddingSettingsFromConfig reads settings.embedding. The legacy
+// settings.openai.base_url and settings.openai.embedding_model keys always define an
+// openai provider named DefaultProviderName unless the list overrides that name, and
+// it is the default unless settings.embedding.default names another provider.
+func LoadEmbeddingSettingsFromConfig() (EmbeddingSettings, error) {
+	var settings EmbeddingSettings
+	if gconfig.S.Get(embeddingConfigKey) != nil {
+		if err := gconfig.S.UnmarshalKey(embeddingConfigKey, &settings); err != nil {
+			return settings, errors.Wrap(err, "parse embedding settings")
+		}
+	}
+
+	legacy := LoadSettingsFromConfig()
+	hasLegacy := false
+	for _, provider := range settings.Providers {
+		if strings.TrimSpace(provider.Name) == DefaultProviderName {
+			hasLegacy = true
+			break
+		}
+	}
+	if !hasLegacy {
+		settings.Providers = append([]ProviderSettings{{
+			Name:    DefaultProviderName,
+			Type:    ProviderTypeOpenAI,
+			BaseURL: legacy.OpenAIBaseURL,
+			Model:   legacy.EmbeddingModel,
+		}}, settings.Providers...)
+	}
+	if strings.TrimSpace(settings.Default) == "" {
+		settings.Default = DefaultProviderName
+	}
+	return settings, nil
+}
+
+// EmbedderRegistry holds the configured embedding providers by name.
+type EmbedderRegistry struct {
+	defaultName string
+	providers   map[string]Provider
+}
+
+// NewEmbedderRegistry builds every provider in settings. httpClient and logger are
+// shared by the HTTP providers and may be nil.
+func NewEmbedderRegistry(settings EmbeddingSettings, httpClient *http.Client, logger logSDK.Logger) (*EmbedderRegistry, error) {
+	registry := &EmbedderRegistry{
+		defaultName: strings.TrimSpace(settings.Default),
+		providers:   make(map[string]Provider, len(settings.Providers)),
+	}
+	for _, cfg := range settings.Providers {
+		name := strings.TrimSpace(cfg.Name)
+		if !providerNamePattern.MatchString(name) {
+			return nil, errors.Errorf("embedding provider name %q must match %s", name, providerNamePattern)
+		}
+		if _, ok := registry.providers[name]; ok {
+			return nil, errors.Errorf("duplicate embedding provider %q", name)
+		}
+		provider, err := newProvider(cfg, httpClient, logger)
+		if err != nil {
+			return nil, errors.Wrapf(err, "embedding provider %s", name)
+		}
+		registry.providers[name] = provider
+	}
+	if _, ok := registry.providers[registry.defaultName]; !ok {
+		return nil, errors.Errorf("default embedding provider %q is not configured", registry.defaultName)
+	}
+	return registry, nil
+}
+
+// newProvider builds the embedder for one provider entry.
+func newProvider(cfg ProviderSettings, httpClient *http.Client, logger logSDK.Logger) (Provider, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.Type)) {
+	case ProviderTypeOpenAI, "":
+		if strings.TrimSpace(cfg.BaseURL) == "" || strings.TrimSpace(cfg.Model) == "" {
+			return nil, errors.New("base_url and model are required for openai providers")
+		}
+		var opts []OpenAIEmbedderOption
+		if logger != nil {
+			opts = append(opts, WithLogger(logger))
+		}
+		return NewOpenAIEmbedder(cfg.BaseURL, cfg.Model, httpClient, opts...), nil
+	case ProviderTypeOllama:
+		return NewOllamaEmbedder(cfg.BaseURL, cfg.Model, httpClient)
+	case ProviderTypeHash:
+		return NewHashEmbedder(cfg.Dimensions)
+	default:
+		return nil, errors.Errorf("unsupported embedding provider type %q", cfg.Type)
+	}
+}
+
+// Provider returns the provider registered under name, or the default provider when
+// name is empty.
+func (r *EmbedderRegistry) Provider(name string) (Provider, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = r.defaultName
+	}
+	provider, ok := r.providers[name]
+	if !ok {
+		return nil, errors.Wrapf(ErrUnknownEmbeddingProvider, "provider %q", name)
+	}
+	return provider, nil
+}
+
+// DefaultName returns the name of the default provider.
+func (r *EmbedderRegistry) DefaultName() string {
+	return r.defaultName
+}
+
+// Names returns the registered provider names in sorted order.
+func (r *EmbedderRegistry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,45 @@
+package rag
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbedderRegistry(t *testing.T) {
+	registry, err := NewEmbedderRegistry(EmbeddingSettings{
+		Default: "local",
+		Providers: []ProviderSettings{
+			{Name: DefaultProviderName, Type: ProviderTypeOpenAI, BaseURL: "https://api.openai.com/v1", Model: "text-embedding-3-small"},
+			{Name: "local", Type: ProviderTypeOllama, BaseURL: "http://127.0.0.1:11434", Model: "nomic-embed-text"},
+			{Name: "offline", Type: ProviderTypeHash, Dimensions: 64},
+		},
+	}, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"local", "offline", "openai"}, registry.Names())
+
+	provider, err := registry.Provider("")
+	require.NoError(t, err)
+	require.Equal(t, "nomic-embed-text", provider.Model())
+	provider, err = registry.Provider("offline")
+	require.NoError(t, err)
+	require.Equal(t, "hash-64", provider.Model())
+	provider, err = registry.Provider(DefaultProviderName)
+	require.NoError(t, err)
+	require.True(t, provider.RequiresAPIKey())
+	_, err = registry.Provider("missing")
+	require.Error(t, err)
+}
+
+func TestEmbedderRegistry_InvalidSettings(t *testing.T) {
+	for name, settings := range map[string]EmbeddingSettings{
+		"missing default": {Default: "other", Providers: []ProviderSettings{{Name: "a", Type: ProviderTypeHash}}},
+		"duplicate name":  {Default: "a", Providers: []ProviderSettings{{Name: "a", Type: ProviderTypeHash}, {Name: "a", Type: ProviderTypeHash}}},
+		"bad name":        {Default: "A B", Providers: []ProviderSettings{{Name: "A B", Type: ProviderTypeHash}}},
+		"bad type":        {Default: "a", Providers: []ProviderSettings{{Name: "a", Type: "grpc"}}},
+		"missing model":   {Default: "a", Providers: []ProviderSettings{{Name: "a", Type: ProviderTypeOllama, BaseURL: "http://x"}}},
+	} {
+		_, err := NewEmbedderRegistry(settings, nil, nil)
+		require.Error(t, err, name)
+	}
+}
@@ -0,0 +1,95 @@
+package rag
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	errors "github.com/Laisky/errors/v2"
+	pgvector "github.com/pgvector/pgvector-go"
+)
+
+// DefaultHashDimensions is the vector size of a HashEmbedder built without one.
+const DefaultHashDimensions = 256
+
+// HashEmbedder embeds text in-process by hashing its lowercase words into a fixed
+// number of signed buckets. Vectors are deterministic and L2-normalized, so texts
+// sharing words score as similar, which is enough for offline tests and development.
+type HashEmbedder struct {
+	dimensions int
+}
+
+// NewHashEmbedder constructs a hashing embedder producing vectors of dimensions.
+func NewHashEmbedder(dimensions int) (*HashEmbedder, error) {
+	if dimensions == 0 {
+		dimensions = DefaultHashDimensions
+	}
+	if dimensions < 0 {
+		return nil, errors.Errorf("invalid hash embedding dimensions %d", dimensions)
+	}
+	return &HashEmbedder{dimensions: dimensions}, nil
+}
+
+// Model names the embedder after its dimensions, since that is all that changes
+// its vectors.
+func (e *HashEmbedder) Model() string {
+	return "hash-" + strconv.Itoa(e.dimensions)
+}
+
+// RequiresAPIKey reports that hashing needs no credentials.
+func (e *HashEmbedder) RequiresAPIKey() bool {
+	return false
+}
+
+// EmbedTexts returns one vector per input. The apiKey is ignored.
+func (e *HashEmbedder) EmbedTexts(_ context.Context, _ string, inputs []string) ([]pgvector.Vector, error) {
+	if len(inputs) == 0 {
+		return nil, errors.New("no inputs provided for embedding")
+	}
+	vectors := make([]pgvector.Vector, 0, len(inputs))
+	for _, input := range inputs {
+		vectors = append(vectors, pgvector.NewVector(e.embed(input)))
+	}
+	return vectors, nil
+}
+
+// embed hashes every word of text into the vector. Text without words hashes the
+// empty word instead, so no vector is all zeros.
+func (e *HashEmbedder) embed(text string) []float32 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		words = []string{""}
+	}
+
+	values := make([]float32, e.dimensions)
+	for _, word := range words {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(word))
+		sum := h.Sum64()
+		weight := float32(1)
+		if sum&(1<<63) != 0 {
+			weight = -1
+		}
+		values[sum%uint64(e.dimensions)] += weight
+	}
+
+	var norm float64
+	for _, value := range values {
+		norm += float64(value) * float64(value)
+	}
+	if norm == 0 {
+		// Every word cancelled out; fall back to a fixed unit vector.
+		values[0] = 1
+		return values
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i := range values {
+		values[i] *= scale
+	}
+	return values
+}
@@ -0,0 +1,39 @@
+package rag
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func cosine(a, b []float32) float64 {
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return dot
+}
+
+func TestHashEmbedder_Deterministic(t *testing.T) {
+	e, err := NewHashEmbedder(0)
+	require.NoError(t, err)
+	require.Equal(t, "hash-256", e.Model())
+
+	vecs, err := e.EmbedTexts(context.Background(), "", []string{
+		"Deploy the API server",
+		"deploy the api server",
+		"how to deploy the server",
+		"chocolate cake recipe",
+		"",
+	})
+	require.NoError(t, err)
+	require.Len(t, vecs[0].Slice(), DefaultHashDimensions)
+	require.Equal(t, vecs[0].Slice(), vecs[1].Slice())
+	require.InDelta(t, 1, cosine(vecs[0].Slice(), vecs[0].Slice()), 1e-6)
+	require.Greater(t, cosine(vecs[0].Slice(), vecs[2].Slice()), cosine(vecs[0].Slice(), vecs[3].Slice()))
+	require.InDelta(t, 1, cosine(vecs[4].Slice(), vecs[4].Slice()), 1e-6)
+
+	_, err = NewHashEmbedder(-1)
+	require.Error(t, err)
+}
@@ -28,13 +28,15 @@ type Chunk struct {
 	UpdatedAt     time.Time
 }
 
-// Embedding stores the vector representation for a chunk.
+// Embedding stores the vector representation for a chunk, with the model and vector
+// dimensions it was produced with.
 type Embedding struct {
-	ChunkID   int64
-	Vector    pgvector.Vector
-	Model     string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ChunkID    int64
+	Vector     pgvector.Vector
+	Model      string
+	Dimensions int
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
 }
 
 // BM25Row stores lexical statistics for a chunk to enable keyword-based scoring.
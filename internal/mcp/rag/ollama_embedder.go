@@ -0,0 +1,119 @@
+package rag
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	errors "github.com/Laisky/errors/v2"
+	pgvector "github.com/pgvector/pgvector-go"
+)
+
+// OllamaEmbedder calls the /api/embed endpoint of a local Ollama-style server.
+// The server is trusted infrastructure, so the caller's API key is not forwarded.
+type OllamaEmbedder struct {
+	baseURL    string
+	model      string
+	httpClient *http.Client
+}
+
+// NewOllamaEmbedder constructs an embedder for model served at baseURL.
+func NewOllamaEmbedder(baseURL, model string, httpClient *http.Client) (*OllamaEmbedder, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	e := &OllamaEmbedder{
+		baseURL:    strings.TrimSuffix(strings.TrimRight(strings.TrimSpace(baseURL), "/"), "/api/embed"),
+		model:      strings.TrimSpace(model),
+		httpClient: httpClient,
+	}
+	if e.baseURL == "" {
+		return nil, errors.New("missing ollama base url")
+	}
+	if e.model == "" {
+		return nil, errors.New("missing ollama embedding model")
+	}
+	return e, nil
+}
+
+// Model returns the embedding model requested from the server.
+func (e *OllamaEmbedder) Model() string {
+	return e.model
+}
+
+// RequiresAPIKey reports that the server does not need the caller's API key.
+func (e *OllamaEmbedder) RequiresAPIKey() bool {
+	return false
+}
+
+type ollamaEmbedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type ollamaEmbedResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+// EmbedTexts sends all inputs in one request and returns their vectors in order.
+// The apiKey is ignored.
+func (e *OllamaEmbedder) EmbedTexts(ctx context.Context, apiKey string, inputs []string) ([]pgvector.Vector, error) {
+	if e == nil {
+		return nil, errors.New("embedder is nil")
+	}
+	return e.EmbedTextsWithModel(ctx, apiKey, e.model, inputs)
+}
+
+// EmbedTextsWithModel is EmbedTexts with another model served by the same server.
+func (e *OllamaEmbedder) EmbedTextsWithModel(ctx context.Context, _ string, model string, inputs []string) ([]pgvector.Vector, error) {
+	if len(inputs) == 0 {
+		return nil, errors.New("no inputs provided for embedding")
+	}
+	if e == nil {
+		return nil, errors.New("embedder is nil")
+	}
+	model = strings.TrimSpace(model)
+	if model == "" {
+		return nil, errors.New("missing ollama embedding model")
+	}
+
+	body, err := json.Marshal(ollamaEmbedRequest{Model: model, Input: inputs})
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal ollama embed request")
+	}
+	endpoint := e.baseURL + "/api/embed"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "build ollama embed request")
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	httpResp, err := e.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, errors.Wrap(err, "call ollama embed endpoint")
+	}
+	defer func() { _ = httpResp.Body.Close() }()
+
+	if httpResp.StatusCode < http.StatusOK || httpResp.StatusCode >= http.StatusMultipleChoices {
+		respSnippet, _ := io.ReadAll(io.LimitReader(httpResp.Body, 512))
+		return nil, errors.Errorf("ollama embed endpoint status %d, url=%s, body=%s",
+			httpResp.StatusCode, endpoint, string(respSnippet))
+	}
+
+	var decoded ollamaEmbedResponse
+	if err := json.NewDecoder(httpResp.Body).Decode(&decoded); err != nil {
+		return nil, errors.Wrap(err, "decode ollama embed response")
+	}
+	if len(decoded.Embeddings) != len(inputs) {
+		return nil, errors.Errorf("ollama returned %d embeddings for %d inputs", len(decoded.Embeddings), len(inputs))
+	}
+
+	vectors := make([]pgvector.Vector, 0, len(decoded.Embeddings))
+	for _, values := range decoded.Embeddings {
+		vectors = append(vectors, pgvector.NewVector(values))
+	}
+	return vectors, nil
+}
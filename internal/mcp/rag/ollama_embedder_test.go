@@ -0,0 +1,50 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOllamaEmbedder_EmbedTexts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/embed", r.URL.Path)
+		require.Empty(t, r.Header.Get("Authorization"))
+
+		var req ollamaEmbedRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, "nomic-embed-text", req.Model)
+		require.Equal(t, []string{"a", "b"}, req.Input)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(ollamaEmbedResponse{Embeddings: [][]float32{{0.1, 0.2}, {0.3, 0.4}}})
+	}))
+	defer srv.Close()
+
+	e, err := NewOllamaEmbedder(srv.URL+"/", "nomic-embed-text", srv.Client())
+	require.NoError(t, err)
+	require.False(t, e.RequiresAPIKey())
+	vecs, err := e.EmbedTexts(context.Background(), "", []string{"a", "b"})
+	require.NoError(t, err)
+	require.Len(t, vecs, 2)
+	require.Equal(t, []float32{0.3, 0.4}, vecs[1].Slice())
+}
+
+func TestOllamaEmbedder_CountMismatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(ollamaEmbedResponse{Embeddings: [][]float32{{0.1}}})
+	}))
+	defer srv.Close()
+
+	e, err := NewOllamaEmbedder(srv.URL, "m", srv.Client())
+	require.NoError(t, err)
+	_, err = e.EmbedTexts(context.Background(), "", []string{"a", "b"})
+	require.ErrorContains(t, err, "1 embeddings for 2 inputs")
+
+	_, err = NewOllamaEmbedder("", "m", nil)
+	require.Error(t, err)
+}
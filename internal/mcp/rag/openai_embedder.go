@@ -61,8 +61,27 @@ func WithLogger(logger logSDK.Logger) OpenAIEmbedderOption {
 	}
 }
 
+// Model returns the embedding model requested from the endpoint.
+func (e *OpenAIEmbedder) Model() string {
+	return e.model
+}
+
+// RequiresAPIKey reports that requests are billed with the caller's API key.
+func (e *OpenAIEmbedder) RequiresAPIKey() bool {
+	return true
+}
+
 // EmbedTexts batches the input strings and returns their vector representations.
 func (e *OpenAIEmbedder) EmbedTexts(ctx context.Context, apiKey string, inputs []string) ([]pgvector.Vector, error) {
+	if e == nil {
+		return nil, errors.New("embedder is nil")
+	}
+	return e.EmbedTextsWithModel(ctx, apiKey, e.model, inputs)
+}
+
+// EmbedTextsWithModel is EmbedTexts with another model served by the same endpoint,
+// used to embed queries for vectors stored before the configured model changed.
+func (e *OpenAIEmbedder) EmbedTextsWithModel(ctx context.Context, apiKey, model string, inputs []string) ([]pgvector.Vector, error) {
 	if len(inputs) == 0 {
 		return nil, errors.New("no inputs provided for embedding")
 	}
@@ -78,7 +97,8 @@ func (e *OpenAIEmbedder) EmbedTexts(ctx context.Context, apiKey string, inputs [
 	if e.baseURL == "" {
 		return nil, errors.New("missing embeddings base url")
 	}
-	if e.model == "" {
+	model = strings.TrimSpace(model)
+	if model == "" {
 		return nil, errors.New("missing embeddings model")
 	}
 
@@ -90,7 +110,7 @@ func (e *OpenAIEmbedder) EmbedTexts(ctx context.Context, apiKey string, inputs [
 			end = len(inputs)
 		}
 		batch := inputs[start:end]
-		resp, err := e.createEmbeddings(ctx, apiKey, batch)
+		resp, err := e.createEmbeddings(ctx, apiKey, model, batch)
 		if err != nil {
 			return nil, errors.Wrap(err, "create embeddings")
 		}
@@ -120,8 +140,8 @@ type embeddingsDataItem struct {
 }
 
 // createEmbeddings sends one embeddings batch request and parses vectors from response.
-func (e *OpenAIEmbedder) createEmbeddings(ctx context.Context, apiKey string, batch []string) (*embeddingsResponse, error) {
-	body, err := json.Marshal(embeddingsRequest{Model: e.model, Input: batch})
+func (e *OpenAIEmbedder) createEmbeddings(ctx context.Context, apiKey, model string, batch []string) (*embeddingsResponse, error) {
+	body, err := json.Marshal(embeddingsRequest{Model: model, Input: batch})
 	if err != nil {
 		return nil, errors.Wrap(err, "marshal embeddings request")
 	}
@@ -146,7 +166,7 @@ func (e *OpenAIEmbedder) createEmbeddings(ctx context.Context, apiKey string, ba
 		if e.logger != nil {
 			e.logger.Debug("embeddings endpoint returned non-2xx",
 				zap.String("url", endpoint),
-				zap.String("model", e.model),
+				zap.String("model", model),
 				zap.Int("status", httpResp.StatusCode),
 				zap.Int("batch_size", len(batch)),
 				zap.String("response_body", string(respSnippet)),
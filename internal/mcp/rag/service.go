@@ -34,17 +34,20 @@ type ExtractInput struct {
 	Query     string
 	Materials string
 	TopK      int
+	// EmbeddingProvider names the provider to embed with; empty selects the default.
+	EmbeddingProvider string
 }
 
 // Service coordinates chunking, storage, and retrieval for the extract_key_info tool.
 type Service struct {
-	db       *sql.DB
-	dialect  sqlDialect
-	embedder Embedder
-	chunker  Chunker
-	settings Settings
-	logger   logSDK.Logger
-	clock    Clock
+	db        *sql.DB
+	dialect   sqlDialect
+	embedder  Embedder
+	embedders *EmbedderRegistry
+	chunker   Chunker
+	settings  Settings
+	logger    logSDK.Logger
+	clock     Clock
 }
 
 // NewService wires the dependencies and runs the required schema migrations.
@@ -81,6 +84,41 @@ func NewService(db *sql.DB, embedder Embedder, chunker Chunker, settings Setting
 	return svc, nil
 }
 
+// SetEmbedderRegistry lets ExtractInput.EmbeddingProvider select any provider in
+// registry. Call it before the service handles requests.
+func (s *Service) SetEmbedderRegistry(registry *EmbedderRegistry) {
+	if s == nil {
+		return
+	}
+	s.embedders = registry
+}
+
+// ValidateEmbeddingProvider reports whether ExtractInput.EmbeddingProvider may name
+// provider, so callers can reject a request before billing it.
+func (s *Service) ValidateEmbeddingProvider(name string) error {
+	_, _, err := s.embedderFor(name)
+	return errors.WithStack(err)
+}
+
+// embedderFor returns the embedder registered under name and the model recorded on
+// its vectors. Without a registry only the default embedder is available.
+func (s *Service) embedderFor(name string) (Embedder, string, error) {
+	if s.embedders != nil {
+		provider, err := s.embedders.Provider(name)
+		if err != nil {
+			return nil, "", errors.WithStack(err)
+		}
+		return provider, provider.Model(), nil
+	}
+	if strings.TrimSpace(name) != "" {
+		return nil, "", errors.Wrapf(ErrUnknownEmbeddingProvider, "provider %q", name)
+	}
+	if provider, ok := s.embedder.(Provider); ok {
+		return provider, provider.Model(), nil
+	}
+	return s.embedder, s.settings.EmbeddingModel, nil
+}
+
 // runRAGMigrations ensures table/index schemas needed by RAG retrieval are present.
 func runRAGMigrations(ctx context.Context, db *sql.DB, logger logSDK.Logger) error {
 	if logger == nil {
@@ -119,6 +157,9 @@ func runRAGMigrations(ctx context.Context, db *sql.DB, logger logSDK.Logger) err
 			return errors.Wrapf(err, "run rag migration statement #%d with dialect %s", idx, dialect.String())
 		}
 	}
+	if err := applyRAGEmbeddingDimensions(ctx, db, dialect); err != nil {
+		return errors.WithStack(err)
+	}
 	logger.Debug("rag sql migrations finished")
 
 	return nil
@@ -210,8 +251,9 @@ func ragMigrationStatements(dialect sqlDialect) []string {
 			`CREATE INDEX IF NOT EXISTS idx_rag_chunks_unique ON mcp_rag_chunks(chunk_index)`,
 			`CREATE TABLE IF NOT EXISTS mcp_rag_embeddings (
 				chunk_id BIGINT PRIMARY KEY,
-				vector VECTOR(1536) NOT NULL,
+				vector VECTOR NOT NULL,
 				model VARCHAR(128) NOT NULL,
+				dimensions INTEGER NOT NULL DEFAULT 0,
 				created_at TIMESTAMPTZ NOT NULL,
 				updated_at TIMESTAMPTZ NOT NULL
 			)`,
@@ -252,6 +294,7 @@ func ragMigrationStatements(dialect sqlDialect) []string {
 			chunk_id INTEGER PRIMARY KEY,
 			vector TEXT NOT NULL,
 			model TEXT NOT NULL,
+			dimensions INTEGER NOT NULL DEFAULT 0,
 			created_at TIMESTAMP NOT NULL,
 			updated_at TIMESTAMP NOT NULL
 		)`,
@@ -266,23 +309,107 @@ func ragMigrationStatements(dialect sqlDialect) []string {
 	}
 }
 
+// applyRAGEmbeddingDimensions upgrades mcp_rag_embeddings tables created before
+// providers were pluggable. On Postgres it drops the fixed vector(1536) type so other
+// dimensions fit, and it adds the dimensions column, backfilled from stored vectors
+// when the column is first added.
+func applyRAGEmbeddingDimensions(ctx context.Context, db *sql.DB, dialect sqlDialect) error {
+	var (
+		exists bool
+		err    error
+	)
+	addColumn := `ALTER TABLE mcp_rag_embeddings ADD COLUMN dimensions INTEGER NOT NULL DEFAULT 0`
+	backfill := `UPDATE mcp_rag_embeddings SET dimensions = json_array_length(vector) WHERE dimensions = 0`
+	if dialect == sqlDialectSQLite {
+		exists, err = sqliteRAGColumnExists(ctx, db, "mcp_rag_embeddings", "dimensions")
+	} else {
+		var typmod int
+		if err := db.QueryRowContext(ctx,
+			`SELECT a.atttypmod FROM pg_attribute a
+			JOIN pg_class c ON c.oid = a.attrelid
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE n.nspname = current_schema() AND c.relname = 'mcp_rag_embeddings' AND a.attname = 'vector' AND NOT a.attisdropped`,
+		).Scan(&typmod); err != nil {
+			return errors.Wrap(err, "probe mcp_rag_embeddings.vector type")
+		}
+		if typmod > 0 {
+			if _, err := db.ExecContext(ctx, `ALTER TABLE mcp_rag_embeddings ALTER COLUMN vector TYPE vector`); err != nil {
+				return errors.Wrap(err, "relax mcp_rag_embeddings.vector type")
+			}
+		}
+		var count int
+		err = db.QueryRowContext(ctx,
+			`SELECT COUNT(1) FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = 'mcp_rag_embeddings' AND column_name = 'dimensions'`,
+		).Scan(&count)
+		exists = count > 0
+		// Another instance may be migrating concurrently.
+		addColumn = `ALTER TABLE mcp_rag_embeddings ADD COLUMN IF NOT EXISTS dimensions INTEGER NOT NULL DEFAULT 0`
+		backfill = `UPDATE mcp_rag_embeddings SET dimensions = vector_dims(vector) WHERE dimensions = 0`
+	}
+	if err != nil {
+		return errors.Wrap(err, "probe mcp_rag_embeddings.dimensions")
+	}
+	if exists {
+		return nil
+	}
+
+	if _, err := db.ExecContext(ctx, addColumn); err != nil {
+		return errors.Wrap(err, "add dimensions column on mcp_rag_embeddings")
+	}
+	if _, err := db.ExecContext(ctx, backfill); err != nil {
+		return errors.Wrap(err, "backfill mcp_rag_embeddings dimensions")
+	}
+	return nil
+}
+
+// sqliteRAGColumnExists returns true when PRAGMA table_info reports the column.
+func sqliteRAGColumnExists(ctx context.Context, db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.QueryContext(ctx, "PRAGMA table_info("+table+")")
+	if err != nil {
+		return false, errors.Wrap(err, "pragma table_info")
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var (
+			cid     int
+			name    string
+			ctype   string
+			notnull int
+			dflt    sql.NullString
+			pk      int
+		)
+		if err := rows.Scan(&cid, &name, &ctype, &notnull, &dflt, &pk); err != nil {
+			return false, errors.Wrap(err, "scan pragma row")
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, errors.WithStack(rows.Err())
+}
+
 // ExtractKeyInfo orchestrates ingestion (if needed) and hybrid retrieval for the request.
 func (s *Service) ExtractKeyInfo(ctx context.Context, input ExtractInput) ([]string, error) {
 	if err := s.validateInput(input); err != nil {
 		return nil, errors.WithStack(err)
 	}
 
+	embedder, model, err := s.embedderFor(input.EmbeddingProvider)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
 	task, err := s.ensureTask(ctx, input.UserID, input.TaskID, input.APIKey)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
 
-	if err := s.ensureChunks(ctx, task, input); err != nil {
+	if err := s.ensureChunks(ctx, task, input, embedder, model); err != nil {
 		return nil, errors.WithStack(err)
 	}
 
 	queryTokens := Tokenize(input.Query)
-	queryVecs, err := s.embedder.EmbedTexts(ctx, input.APIKey, []string{input.Query})
+	queryVecs, err := embedder.EmbedTexts(ctx, input.APIKey, []string{input.Query})
 	if err != nil {
 		return nil, errors.Wrap(err, "embed query")
 	}
@@ -291,7 +418,7 @@ func (s *Service) ExtractKeyInfo(ctx context.Context, input ExtractInput) ([]str
 	}
 	queryVec := queryVecs[0]
 
-	candidates, err := s.fetchCandidates(ctx, task.ID, queryVec, max(16, input.TopK*4))
+	candidates, err := s.fetchCandidates(ctx, task.ID, model, queryVec, max(16, input.TopK*4))
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
@@ -392,7 +519,9 @@ func legacyUserIDFromAPIKey(apiKey string) string {
 	return keyPrefix + "_" + hashPrefix[:7]
 }
 
-func (s *Service) ensureChunks(ctx context.Context, task *Task, input ExtractInput) error {
+// ensureChunks stores the chunks of input.Materials with embedder vectors recorded
+// under model, unless the task already holds them for that model.
+func (s *Service) ensureChunks(ctx context.Context, task *Task, input ExtractInput, embedder Embedder, model string) error {
 	fragments := s.chunker.Split(input.Materials, s.settings.MaxChunkChars)
 	if len(fragments) == 0 {
 		return errors.New("no chunks generated from materials")
@@ -409,9 +538,11 @@ func (s *Service) ensureChunks(ctx context.Context, task *Task, input ExtractInp
 		_ = tx.Rollback()
 	}()
 
-	countQuery := rebindPlaceholders(s.dialect, `SELECT COUNT(1) FROM mcp_rag_chunks WHERE task_id = ? AND materials_hash = ?`)
+	countQuery := rebindPlaceholders(s.dialect, `SELECT COUNT(1) FROM mcp_rag_chunks c
+		JOIN mcp_rag_embeddings e ON e.chunk_id = c.id
+		WHERE c.task_id = ? AND c.materials_hash = ? AND e.model = ?`)
 	var count int64
-	if err := tx.QueryRowContext(ctx, countQuery, task.ID, materialsHash).Scan(&count); err != nil {
+	if err := tx.QueryRowContext(ctx, countQuery, task.ID, materialsHash, model).Scan(&count); err != nil {
 		return errors.Wrap(err, "check existing chunks")
 	}
 	if count > 0 {
@@ -425,7 +556,7 @@ func (s *Service) ensureChunks(ctx context.Context, task *Task, input ExtractInp
 	for _, fragment := range fragments {
 		texts = append(texts, fragment.Cleaned)
 	}
-	embeddings, err := s.embedder.EmbedTexts(ctx, input.APIKey, texts)
+	embeddings, err := embedder.EmbedTexts(ctx, input.APIKey, texts)
 	if err != nil {
 		return errors.Wrap(err, "embed materials")
 	}
@@ -459,11 +590,12 @@ func (s *Service) ensureChunks(ctx context.Context, task *Task, input ExtractInp
 		}
 
 		if err := s.insertEmbeddingTx(ctx, tx, Embedding{
-			ChunkID:   chunk.ID,
-			Vector:    embeddings[idx],
-			Model:     s.settings.EmbeddingModel,
-			CreatedAt: now,
-			UpdatedAt: now,
+			ChunkID:    chunk.ID,
+			Vector:     embeddings[idx],
+			Model:      model,
+			Dimensions: len(embeddings[idx].Slice()),
+			CreatedAt:  now,
+			UpdatedAt:  now,
 		}); err != nil {
 			return errors.Wrap(err, "insert embedding")
 		}
@@ -498,7 +630,9 @@ func (s *Service) ensureChunks(ctx context.Context, task *Task, input ExtractInp
 	return nil
 }
 
-func (s *Service) fetchCandidates(ctx context.Context, taskID int64, queryVec pgvector.Vector, limit int) ([]candidateChunk, error) {
+// fetchCandidates returns the chunks of a task nearest to queryVec. Only vectors of
+// model are compared, since vectors of other models live in a different space.
+func (s *Service) fetchCandidates(ctx context.Context, taskID int64, model string, queryVec pgvector.Vector, limit int) ([]candidateChunk, error) {
 	logger := s.loggerFromContext(ctx)
 	logger.Debug("fetching rag candidates", zap.Int64("task_id", taskID), zap.Int("limit", limit))
 	rows := make([]candidateChunk, 0, limit)
@@ -507,7 +641,7 @@ func (s *Service) fetchCandidates(ctx context.Context, taskID int64, queryVec pg
             FROM mcp_rag_chunks c
             JOIN mcp_rag_embeddings e ON e.chunk_id = c.id
             LEFT JOIN mcp_rag_bm25 b ON b.chunk_id = c.id
-            WHERE c.task_id = ?
+            WHERE c.task_id = ? AND e.model = ?
 		    ORDER BY e.vector <=> ? ASC
             LIMIT ?
 	        `)
@@ -517,7 +651,7 @@ func (s *Service) fetchCandidates(ctx context.Context, taskID int64, queryVec pg
 		return nil, errors.Wrap(err, "encode query vector")
 	}
 
-	dbRows, err := s.db.QueryContext(ctx, query, taskID, queryVectorArg, limit)
+	dbRows, err := s.db.QueryContext(ctx, query, taskID, model, queryVectorArg, limit)
 	if err != nil {
 		return nil, errors.Wrap(err, "query rag candidates")
 	}
@@ -821,8 +955,8 @@ func (s *Service) insertEmbeddingTx(ctx context.Context, tx *sql.Tx, embedding E
 	}
 
 	query := rebindPlaceholders(s.dialect, `
-		INSERT INTO mcp_rag_embeddings(chunk_id, vector, model, created_at, updated_at)
-		VALUES(?, ?, ?, ?, ?)
+		INSERT INTO mcp_rag_embeddings(chunk_id, vector, model, dimensions, created_at, updated_at)
+		VALUES(?, ?, ?, ?, ?, ?)
 	`)
 	if _, err := tx.ExecContext(
 		ctx,
@@ -830,6 +964,7 @@ func (s *Service) insertEmbeddingTx(ctx context.Context, tx *sql.Tx, embedding E
 		embedding.ChunkID,
 		vectorValue,
 		embedding.Model,
+		embedding.Dimensions,
 		embedding.CreatedAt,
 		embedding.UpdatedAt,
 	); err != nil {
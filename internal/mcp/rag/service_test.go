@@ -91,15 +91,15 @@ func TestFetchCandidatesUsesVectorColumn(t *testing.T) {
 
 	queryVec := pgvector.NewVector([]float32{0.1, 0.2})
 
-	pattern := regexp.MustCompile(`SELECT c\.id, c\.text, c\.cleaned_text, e\.vector AS embedding, b\.tokens[\s\S]+ORDER BY e\.vector <=> \$[0-9]+ ASC[\s\S]+LIMIT \$[0-9]+`)
+	pattern := regexp.MustCompile(`SELECT c\.id, c\.text, c\.cleaned_text, e\.vector AS embedding, b\.tokens[\s\S]+e\.model = \$[0-9]+[\s\S]+ORDER BY e\.vector <=> \$[0-9]+ ASC[\s\S]+LIMIT \$[0-9]+`)
 	rows := sqlmock.NewRows([]string{"id", "text", "cleaned_text", "embedding", "tokens"}).
 		AddRow(int64(1), "chunk text", "chunk cleaned", queryVec, []byte(`["jwt"]`))
 
 	mock.ExpectQuery(pattern.String()).
-		WithArgs(int64(1), sqlmock.AnyArg(), 5).
+		WithArgs(int64(1), "model-a", sqlmock.AnyArg(), 5).
 		WillReturnRows(rows)
 
-	candidates, err := svc.fetchCandidates(context.Background(), 1, queryVec, 5)
+	candidates, err := svc.fetchCandidates(context.Background(), 1, "model-a", queryVec, 5)
 	require.NoError(t, err)
 	require.Len(t, candidates, 1)
 	require.Equal(t, "chunk text", candidates[0].Text)
@@ -192,3 +192,59 @@ func TestDetectSQLDialectByDriverTypePGXStdlib(t *testing.T) {
 	dialect := detectSQLDialectByDriverType("*stdlib.Driver")
 	require.Equal(t, sqlDialectPostgres, dialect)
 }
+
+// TestExtractKeyInfoUsesSelectedProvider verifies the requested provider embeds the
+// materials and its model and dimensions are stored with each vector.
+func TestExtractKeyInfoUsesSelectedProvider(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file:rag_provider_selection?mode=memory&cache=shared")
+	require.NoError(t, err)
+	defer db.Close()
+
+	registry, err := NewEmbedderRegistry(EmbeddingSettings{
+		Default:   "local",
+		Providers: []ProviderSettings{{Name: "local", Type: ProviderTypeHash, Dimensions: 8}},
+	}, nil, nil)
+	require.NoError(t, err)
+	settings := LoadSettingsFromConfig()
+	settings.MaxChunkChars = 80
+	svc, err := NewService(db, &captureRAGEmbedder{}, ParagraphChunker{}, settings, log.Logger.Named("rag_provider_test"))
+	require.NoError(t, err)
+	svc.SetEmbedderRegistry(registry)
+
+	input := ExtractInput{
+		UserID:            "user:tenant",
+		TaskID:            "task-1",
+		APIKey:            "key",
+		Query:             "alpha",
+		Materials:         "alpha content\n\nbeta content",
+		TopK:              1,
+		EmbeddingProvider: "missing",
+	}
+	_, err = svc.ExtractKeyInfo(context.Background(), input)
+	require.ErrorIs(t, err, ErrUnknownEmbeddingProvider)
+
+	input.EmbeddingProvider = "local"
+	if _, err = svc.ExtractKeyInfo(context.Background(), input); err != nil {
+		// SQLite cannot rank by vector distance; ingestion has committed by then.
+		require.Contains(t, err.Error(), "query rag candidates")
+	}
+
+	provider, err := registry.Provider("local")
+	require.NoError(t, err)
+	rows, err := db.Query(`SELECT model, dimensions FROM mcp_rag_embeddings`)
+	require.NoError(t, err)
+	defer rows.Close()
+	count := 0
+	for rows.Next() {
+		var (
+			model      string
+			dimensions int
+		)
+		require.NoError(t, rows.Scan(&model, &dimensions))
+		require.Equal(t, provider.Model(), model)
+		require.Equal(t, 8, dimensions)
+		count++
+	}
+	require.NoError(t, rows.Err())
+	require.Positive(t, count)
+}
@@ -6,11 +6,13 @@ import (
 	"net/http"
 	"sort"
 	"strings"
+	"time"
 
 	errors "github.com/Laisky/errors/v2"
 	gutils "github.com/Laisky/go-utils/v6"
 	logSDK "github.com/Laisky/go-utils/v6/log"
 	"github.com/Laisky/zap"
+	"github.com/google/uuid"
 	mcp "github.com/mark3labs/mcp-go/mcp"
 	srv "github.com/mark3labs/mcp-go/server"
 
@@ -18,7 +20,10 @@ import (
 	mcpauth "github.com/Laisky/laisky-blog-graphql/internal/mcp/auth"
 	"github.com/Laisky/laisky-blog-graphql/internal/mcp/calllog"
 	"github.com/Laisky/laisky-blog-graphql/internal/mcp/ctxkeys"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/federation"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/files"
 	mcpmemory "github.com/Laisky/laisky-blog-graphql/internal/mcp/memory"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/piperuns"
 	"github.com/Laisky/laisky-blog-graphql/internal/mcp/rag"
 	"github.com/Laisky/laisky-blog-graphql/internal/mcp/tools"
 	"github.com/Laisky/laisky-blog-graphql/internal/mcp/userrequests"
@@ -43,6 +48,20 @@ type callRecorder interface {
 // externalBillingReporter reports MCP usage events to the centralized billing system.
 type externalBillingReporter func(context.Context, string, oneapi.Price, string) error
 
+// toolBaseCosts lists the base cost billed per call for the billed tools.
+// mcp_pipe dry runs use it to estimate the cost of a pipeline.
+var toolBaseCosts = map[string]oneapi.Price{
+	"web_search":       oneapi.PriceWebSearch,
+	"web_fetch":        oneapi.PriceWebFetch,
+	"extract_key_info": oneapi.PriceExtractKeyInfo,
+	"find_tool":        oneapi.PriceFindTool,
+}
+
+// toolArgsCosts lists the argument-dependent cost billed per call on top of toolBaseCosts.
+var toolArgsCosts = map[string]func(args map[string]any) oneapi.Price{
+	"web_search": tools.WebSearchFanOutPrice,
+}
+
 // addToolWithSchemaValidation logs schema warnings and registers a tool handler.
 func addToolWithSchemaValidation(mcpServer *srv.MCPServer, logger logSDK.Logger, definition mcp.Tool, handler srv.ToolHandlerFunc) {
 	logInvalidArrayItemSchemas(logger, definition)
@@ -102,14 +121,35 @@ type Server struct {
 	fileRename                *tools.FileRenameTool
 	fileList                  *tools.FileListTool
 	fileSearch                *tools.FileSearchTool
+	fileGrep                  *tools.FileGrepTool
+	fileSnapshot              *tools.FileSnapshotTool
+	fileIndexStatus           *tools.FileIndexStatusTool
 	memoryBeforeTurn          *tools.MemoryBeforeTurnTool
 	memoryAfterTurn           *tools.MemoryAfterTurnTool
 	memoryRunMaintenance      *tools.MemoryRunMaintenanceTool
 	memoryListDirWithAbstract *tools.MemoryListDirWithAbstractTool
 	mcpPipe                   *tools.MCPPipeTool
+	mcpPipeStatus             *tools.MCPPipeStatusTool
+	mcpPipeCancel             *tools.MCPPipeCancelTool
 	findTool                  *tools.FindToolTool
+	webCrawl                  *tools.WebCrawlTool
+	webCrawlStatus            *tools.WebCrawlStatusTool
+	webCrawlCancel            *tools.WebCrawlCancelTool
 	callLogger                callRecorder
-	holdManager               *userrequests.HoldManager
+	mcpServer                 *srv.MCPServer
+	// federation routes calls of re-exported upstream tools when attached.
+	federation  *federation.Manager
+	holdManager *userrequests.HoldManager
+	// savedPipelines exposes per-key saved pipelines as session tools when set.
+	savedPipelines *userrequests.Service
+	// savedCommands exposes per-key saved commands as prompts when set.
+	savedCommands *userrequests.Service
+	// files backs the file:// resources when attached.
+	files                 *files.Service
+	resourceSubscriptions *resourceSubscriptions
+	hooks                 *srv.Hooks
+	// rpcMethods answers JSON-RPC methods that mcp-go cannot serve per caller.
+	rpcMethods map[string]rpcMethodHandler
 	// toolHandlers maps tool names to their handler functions,
 	// enabling mcp_pipe to dynamically invoke any registered tool.
 	toolHandlers    map[string]srv.ToolHandlerFunc
@@ -121,8 +161,12 @@ type Server struct {
 // askUserService enables the ask_user tool when not nil and toolsSettings.AskUserEnabled is true.
 // rdb enables the web_fetch tool when not nil and toolsSettings.WebFetchEnabled is true.
 // userRequestService enables the get_user_request tool when not nil and toolsSettings.GetUserRequestEnabled is true.
+// It also backs saved pipelines, which are exposed as tools when toolsSettings.MCPPipeEnabled is true,
+// and saved commands, which are exposed as prompts.
 // ragService enables the extract_key_info tool when not nil and toolsSettings.ExtractKeyInfoEnabled is true.
+// embedders enables find_tool when not nil and toolsSettings.FindToolEnabled is true; it embeds with the default provider.
 // callLogger records tool invocations for auditing when provided.
+// pipeRunService enables mcp_pipe async mode and the mcp_pipe_status/mcp_pipe_cancel tools when not nil.
 // logger overrides the default logger when provided.
 // It returns the configured server or an error if no capability is available.
 //
@@ -133,10 +177,12 @@ func NewServer(
 	userRequestService *userrequests.Service,
 	ragService *rag.Service,
 	ragSettings rag.Settings,
+	embedders *rag.EmbedderRegistry,
 	fileService tools.FileService,
 	memoryService *mcpmemory.Service,
 	rdb *rlibs.DB,
 	callLogger callRecorder,
+	pipeRunService *piperuns.Service,
 	toolsSettings ToolsSettings,
 	logger logSDK.Logger,
 ) (*Server, error) {
@@ -149,20 +195,35 @@ func NewServer(
 
 	hooks := newMCPHooks(logger.Named("mcp_hooks"))
 
-	mcpServer := srv.NewMCPServer(
-		"LAISKY MCP SERVER",
-		"1.0.0",
+	serverOpts := []srv.ServerOption{
 		srv.WithToolCapabilities(true),
 		srv.WithInstructions("Use web_search for Google Programmable Search queries and web_fetch to retrieve dynamic web pages."),
 		srv.WithRecovery(),
 		srv.WithHooks(hooks),
-	)
+	}
+	if userRequestService != nil {
+		serverOpts = append(serverOpts, srv.WithPromptCapabilities(false))
+	}
+	mcpServer := srv.NewMCPServer("LAISKY MCP SERVER", "1.0.0", serverOpts...)
 
 	serverLogger := logger.Named("mcp")
-	billingReporter := externalBillingReporter(oneapi.CheckUserExternalBilling)
+	s := &Server{
+		logger:          serverLogger,
+		billingReporter: externalBillingReporter(oneapi.CheckUserExternalBilling),
+		callLogger:      callLogger,
+		mcpServer:       mcpServer,
+		hooks:           hooks,
+		toolHandlers:    make(map[string]srv.ToolHandlerFunc),
+		toolDefinitions: make(map[string]mcp.Tool),
+	}
+	// Reads s.billingReporter per call, so WithBillingReporter also applies to tools built below.
 	trackedBillingReporter := func(ctx context.Context, apiKey string, price oneapi.Price, toolName string) error {
 		markBillingAttempted(ctx)
-		return billingReporter(ctx, apiKey, price, toolName)
+		if err := s.billingReporter(ctx, apiKey, price, toolName); err != nil {
+			return err
+		}
+		addBilledPrice(ctx, price)
+		return nil
 	}
 
 	streamable := srv.NewStreamableHTTPServer(
@@ -200,14 +261,16 @@ func NewServer(
 	)
 
 	normalizedAuthHandler := withAuthorizationHeaderNormalization(streamable, serverLogger.Named("auth"))
-	s := &Server{
-		handler:         withHTTPLogging(withToolsListFiltering(normalizedAuthHandler, serverLogger.Named("tools_list_filter"), userRequestService), serverLogger.Named("http")),
-		logger:          serverLogger,
-		billingReporter: billingReporter,
-		callLogger:      callLogger,
-		toolHandlers:    make(map[string]srv.ToolHandlerFunc),
-		toolDefinitions: make(map[string]mcp.Tool),
-	}
+	s.handler = withRequestBodyLimit(
+		withHTTPLogging(
+			withRPCMethodOverrides(
+				withToolsListFiltering(normalizedAuthHandler, serverLogger.Named("tools_list_filter"), userRequestService),
+				serverLogger.Named("rpc_overrides"), s,
+			),
+			serverLogger.Named("http"),
+		),
+		toolsSettings.MaxRequestBodyBytes,
+	)
 
 	apiKeyProvider := func(ctx context.Context) string {
 		return apiKeyFromContext(ctx)
@@ -244,6 +307,9 @@ func NewServer(
 		if err != nil {
 			return nil, errors.Wrap(err, "init web_fetch tool")
 		}
+		if toolsSettings.WebFetchFallbackEnabled {
+			webFetchTool.WithFallback(newWebFetchFallback(), toolsSettings.WebFetchCrawlerDeadline)
+		}
 		s.webFetch = webFetchTool
 		s.registerTool(mcpServer, webFetchTool.Definition(), s.handleWebFetch)
 	} else if rdb != nil && !toolsSettings.WebFetchEnabled {
@@ -288,6 +354,10 @@ func NewServer(
 		serverLogger.Info("get_user_request tool disabled by configuration")
 	}
 
+	if userRequestService != nil {
+		s.enableSavedCommandPrompts(userRequestService)
+	}
+
 	if ragService != nil && toolsSettings.ExtractKeyInfoEnabled {
 		ragTool, err := tools.NewExtractKeyInfoTool(
 			ragService,
@@ -412,15 +482,56 @@ func NewServer(
 		if err != nil {
 			return nil, errors.Wrap(err, "init mcp_pipe tool")
 		}
+		pipeTool.WithToolCatalog(func(toolName string) (tools.PipeToolInfo, bool) {
+			if toolName == "mcp_pipe" {
+				return tools.PipeToolInfo{}, false
+			}
+			if _, ok := s.toolHandlers[toolName]; !ok {
+				return tools.PipeToolInfo{}, false
+			}
+			definition, ok := s.toolDefinitions[toolName]
+			if !ok {
+				definition = mcp.NewTool(toolName)
+			}
+			return tools.PipeToolInfo{Definition: definition, Price: s.toolPrice(toolName), ArgsPrice: toolArgsCosts[toolName]}, true
+		})
 		s.mcpPipe = pipeTool
 		s.registerTool(mcpServer, pipeTool.Definition(), s.handleMCPPipe)
+
+		if pipeRunService != nil {
+			pipeTool.WithRunStore(pipeRunService)
+
+			statusTool, err := tools.NewMCPPipeStatusTool(pipeTool)
+			if err != nil {
+				return nil, errors.Wrap(err, "init mcp_pipe_status tool")
+			}
+			s.mcpPipeStatus = statusTool
+			s.registerTool(mcpServer, statusTool.Definition(), s.handleMCPPipeStatus)
+
+			cancelTool, err := tools.NewMCPPipeCancelTool(pipeTool)
+			if err != nil {
+				return nil, errors.Wrap(err, "init mcp_pipe_cancel tool")
+			}
+			s.mcpPipeCancel = cancelTool
+			s.registerTool(mcpServer, cancelTool.Definition(), s.handleMCPPipeCancel)
+		} else {
+			serverLogger.Info("mcp_pipe async mode disabled: pipe run service unavailable")
+		}
+
+		if userRequestService != nil && hooks != nil {
+			s.enableSavedPipelines(hooks, userRequestService)
+		} else {
+			serverLogger.Info("saved pipelines disabled: user request service unavailable")
+		}
 	} else {
 		serverLogger.Info("mcp_pipe tool disabled by configuration")
 	}
 
-	if toolsSettings.FindToolEnabled && ragSettings.OpenAIBaseURL != "" && ragSettings.EmbeddingModel != "" {
-		embedder := rag.NewOpenAIEmbedder(ragSettings.OpenAIBaseURL, ragSettings.EmbeddingModel, nil,
-			rag.WithLogger(serverLogger.Named("embedder")))
+	if toolsSettings.FindToolEnabled && embedders != nil {
+		embedder, err := embedders.Provider("")
+		if err != nil {
+			return nil, errors.Wrap(err, "load find_tool embedding provider")
+		}
 		findToolInstance, err := tools.NewFindToolTool(
 			embedder,
 			serverLogger.Named("find_tool"),
@@ -434,18 +545,19 @@ func NewServer(
 		s.findTool = findToolInstance
 		s.registerTool(mcpServer, findToolInstance.Definition(), s.handleFindTool)
 
-		// Collect all registered tool definitions (excluding find_tool itself)
-		// and provide them to the find_tool for indexing.
-		allTools := make([]mcp.Tool, 0, len(s.toolDefinitions))
-		for name, def := range s.toolDefinitions {
-			if name == "find_tool" {
-				continue
-			}
-			allTools = append(allTools, def)
+		s.refreshFindToolIndex()
+		if s.savedPipelines != nil {
+			findToolInstance.SetExtraToolsProvider(s.savedPipelineSearchTools)
+			s.savedPipelines.WithSavedPipelineChangeListener(func(id uuid.UUID) {
+				findToolInstance.ForgetExtraTool(id.String())
+			})
+		}
+		if source, ok := callLogger.(toolStatsSource); ok {
+			loader := &findToolUsageLoader{source: source, now: time.Now}
+			findToolInstance.SetUsageProvider(loader.Load)
 		}
-		findToolInstance.SetTools(allTools)
 	} else if toolsSettings.FindToolEnabled {
-		serverLogger.Info("find_tool disabled: missing embedding configuration")
+		serverLogger.Info("find_tool disabled: no embedding providers configured")
 	}
 
 	return s, nil
@@ -462,6 +574,33 @@ func (s *Server) HoldManager() *userrequests.HoldManager {
 	return s.holdManager
 }
 
+// refreshFindToolIndex provides all registered tool definitions (excluding find_tool itself)
+// to find_tool for indexing.
+func (s *Server) refreshFindToolIndex() {
+	if s.findTool == nil {
+		return
+	}
+
+	allTools := make([]mcp.Tool, 0, len(s.toolDefinitions))
+	for name, def := range s.toolDefinitions {
+		if name == "find_tool" {
+			continue
+		}
+		allTools = append(allTools, def)
+	}
+	s.findTool.SetTools(allTools)
+}
+
+// WithBillingReporter replaces the centralized billing call used by every billed tool,
+// so the MCP server and the GraphQL resolvers charge through the same reporter.
+// It must be called before the server starts handling requests.
+func (s *Server) WithBillingReporter(reporter func(context.Context, string, oneapi.Price, string) error) *Server {
+	if s != nil && reporter != nil {
+		s.billingReporter = reporter
+	}
+	return s
+}
+
 // AttachImageIssuer wires an image issuer into the get_user_request tool so
 // the MCP response can include ImageContent / ResourceLink attachments. It is
 // safe to call when the tool is disabled (no-op).
@@ -562,13 +562,13 @@ func TestLoggerFromContextBehavior(t *testing.T) {
 // ---------------------------------------------------------------------------
 
 func TestNewServerAllDisabledReturnsError(t *testing.T) {
-	s, err := NewServer(nil, nil, nil, nil, rag.Settings{}, nil, nil, nil, nil, ToolsSettings{}, log.Logger)
+	s, err := NewServer(nil, nil, nil, nil, rag.Settings{}, nil, nil, nil, nil, nil, nil, ToolsSettings{}, log.Logger)
 	require.Nil(t, s)
 	require.Error(t, err)
 }
 
 func TestNewServerWithOnlyMCPPipe(t *testing.T) {
-	s, err := NewServer(nil, nil, nil, nil, rag.Settings{}, nil, nil, nil, nil, ToolsSettings{MCPPipeEnabled: true}, log.Logger)
+	s, err := NewServer(nil, nil, nil, nil, rag.Settings{}, nil, nil, nil, nil, nil, nil, ToolsSettings{MCPPipeEnabled: true}, log.Logger)
 	require.NoError(t, err)
 	require.NotNil(t, s)
 	require.Contains(t, s.AvailableToolNames(), "mcp_pipe")
@@ -579,7 +579,7 @@ func TestNewServerWithWebSearch(t *testing.T) {
 		output: &searchlib.SearchOutput{Items: nil, EngineName: "test", EngineType: "test"},
 	}
 	s, err := NewServer(
-		provider, nil, nil, nil, rag.Settings{}, nil, nil, nil, nil,
+		provider, nil, nil, nil, rag.Settings{}, nil, nil, nil, nil, nil, nil,
 		ToolsSettings{WebSearchEnabled: true},
 		log.Logger,
 	)
@@ -592,7 +592,7 @@ func TestNewServerWebSearchDisabledByConfig(t *testing.T) {
 		output: &searchlib.SearchOutput{Items: nil, EngineName: "test", EngineType: "test"},
 	}
 	s, err := NewServer(
-		provider, nil, nil, nil, rag.Settings{}, nil, nil, nil, nil,
+		provider, nil, nil, nil, rag.Settings{}, nil, nil, nil, nil, nil, nil,
 		ToolsSettings{WebSearchEnabled: false, MCPPipeEnabled: true},
 		log.Logger,
 	)
@@ -601,17 +601,110 @@ func TestNewServerWebSearchDisabledByConfig(t *testing.T) {
 }
 
 func TestNewServerHoldManagerNilWhenGetUserRequestDisabled(t *testing.T) {
-	s, err := NewServer(nil, nil, nil, nil, rag.Settings{}, nil, nil, nil, nil, ToolsSettings{MCPPipeEnabled: true}, log.Logger)
+	s, err := NewServer(nil, nil, nil, nil, rag.Settings{}, nil, nil, nil, nil, nil, nil, ToolsSettings{MCPPipeEnabled: true}, log.Logger)
 	require.NoError(t, err)
 	require.Nil(t, s.HoldManager())
 }
 
 func TestNewServerNilLogger(t *testing.T) {
-	s, err := NewServer(nil, nil, nil, nil, rag.Settings{}, nil, nil, nil, nil, ToolsSettings{MCPPipeEnabled: true}, nil)
+	s, err := NewServer(nil, nil, nil, nil, rag.Settings{}, nil, nil, nil, nil, nil, nil, ToolsSettings{MCPPipeEnabled: true}, nil)
 	require.NoError(t, err)
 	require.NotNil(t, s)
 }
 
+// ---------------------------------------------------------------------------
+// Test: mcp_pipe retries are visible in call logs
+// ---------------------------------------------------------------------------
+
+func TestMCPPipeRetryRecordedInCallLog(t *testing.T) {
+	recorder := &behaviorRecorder{}
+	s, err := NewServer(nil, nil, nil, nil, rag.Settings{}, nil, nil, nil, nil, recorder, nil, ToolsSettings{MCPPipeEnabled: true}, log.Logger)
+	require.NoError(t, err)
+
+	calls := 0
+	s.toolHandlers["flaky"] = func(ctx context.Context, req mcpgo.CallToolRequest) (*mcpgo.CallToolResult, error) {
+		return s.executeToolHandler(ctx, req, "flaky", 0, "flaky is not available", func(context.Context, mcpgo.CallToolRequest) (*mcpgo.CallToolResult, error) {
+			calls++
+			if calls == 1 {
+				return mcpgo.NewToolResultError("temporary failure"), nil
+			}
+			return mcpgo.NewToolResultText("ok"), nil
+		})
+	}
+
+	result, err := s.handleMCPPipe(context.Background(), mcpgo.CallToolRequest{Params: mcpgo.CallToolParams{Arguments: map[string]any{
+		"steps": []any{map[string]any{
+			"id":    "fetch",
+			"tool":  "flaky",
+			"args":  map[string]any{"url": "https://example.com", "mcp_pipe": "argument"},
+			"retry": map[string]any{"count": 2, "backoff_ms": 1},
+		}},
+	}}})
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+
+	var flaky []calllog.RecordInput
+	for _, record := range recorder.records {
+		if record.ToolName == "flaky" {
+			flaky = append(flaky, record)
+		}
+	}
+	require.Len(t, flaky, 2)
+	require.Equal(t, calllog.StatusError, flaky[0].Status)
+	require.Equal(t, map[string]any{"step_id": "fetch", "attempt": 1, "max_attempts": 3, "fallback": false}, flaky[0].Parameters["_meta"].(map[string]any)["mcp_pipe"])
+	require.Equal(t, calllog.StatusSuccess, flaky[1].Status)
+	require.Equal(t, 2, flaky[1].Parameters["_meta"].(map[string]any)["mcp_pipe"].(map[string]any)["attempt"])
+	require.Equal(t, "https://example.com", flaky[1].Parameters["url"])
+	require.Equal(t, "argument", flaky[1].Parameters["mcp_pipe"])
+	require.Equal(t, "mcp_pipe", recorder.last().ToolName)
+	require.NotContains(t, recorder.last().Parameters, "_meta")
+}
+
+// ---------------------------------------------------------------------------
+// Test: mcp_pipe dry runs check registered tools and estimate cost
+// ---------------------------------------------------------------------------
+
+func TestMCPPipeDryRunUsesRegisteredTools(t *testing.T) {
+	provider := &stubSearchProvider{err: goerrors.New("search must not run during a dry run")}
+	s, err := NewServer(provider, nil, nil, nil, rag.Settings{}, nil, nil, nil, nil, nil, nil,
+		ToolsSettings{WebSearchEnabled: true, MCPPipeEnabled: true}, log.Logger)
+	require.NoError(t, err)
+
+	result, err := s.handleMCPPipe(context.Background(), mcpgo.CallToolRequest{Params: mcpgo.CallToolParams{Arguments: map[string]any{
+		"dry_run": true,
+		"spec": map[string]any{
+			"steps": []any{
+				map[string]any{"id": "a", "tool": "web_search", "args": map[string]any{"query": "golang"}},
+				map[string]any{"id": "b", "tool": "web_search", "args": map[string]any{"query": "${steps.a.text}"}, "retry": 1},
+			},
+		},
+	}}})
+	require.NoError(t, err)
+	require.False(t, result.IsError, result.StructuredContent)
+
+	payload := result.StructuredContent.(map[string]any)
+	require.Equal(t, 2*oneapi.PriceWebSearch.Int(), payload["estimated_cost"])
+	require.Equal(t, 3*oneapi.PriceWebSearch.Int(), payload["max_cost"])
+
+	result, err = s.handleMCPPipe(context.Background(), mcpgo.CallToolRequest{Params: mcpgo.CallToolParams{Arguments: map[string]any{
+		"dry_run": true,
+		"spec": map[string]any{
+			"steps": []any{
+				map[string]any{"id": "a", "tool": "web_serch", "args": map[string]any{"query": "golang"}},
+				map[string]any{"id": "b", "tool": "web_search", "args": map[string]any{}},
+				map[string]any{"id": "c", "tool": "mcp_pipe", "args": map[string]any{}},
+			},
+		},
+	}}})
+	require.NoError(t, err)
+	require.True(t, result.IsError)
+	require.Equal(t, []string{
+		`step "a": unknown tool: web_serch`,
+		`step "b": missing required argument "query" for tool web_search`,
+		`step "c": unknown tool: mcp_pipe`,
+	}, result.StructuredContent.(map[string]any)["errors"])
+}
+
 // ---------------------------------------------------------------------------
 // Test: AvailableToolNames is sorted
 // ---------------------------------------------------------------------------
@@ -413,3 +413,13 @@ func TestMCPPipeContinueOnErrorReportsEveryFreeFileIOAttempt(t *testing.T) {
 			len(counts) == 4
 	}, time.Second, 10*time.Millisecond)
 }
+
+func TestBilledPriceAccumulatesPerInvocation(t *testing.T) {
+	require.Zero(t, billedPrice(context.Background()))
+	addBilledPrice(context.Background(), oneapi.PriceWebSearch)
+
+	ctx := withBillingAttemptTracking(context.Background())
+	addBilledPrice(ctx, oneapi.PriceWebSearch)
+	addBilledPrice(ctx, 2*oneapi.PriceWebSearch)
+	require.Equal(t, 3*oneapi.PriceWebSearch, billedPrice(ctx))
+}
@@ -0,0 +1,84 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Laisky/zap"
+	mcp "github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/federation"
+	"github.com/Laisky/laisky-blog-graphql/library/billing/oneapi"
+)
+
+// AttachFederation re-exports the tools of the upstream MCP servers connected by manager.
+//
+// Federated tools are registered like in-process tools, so their calls pass through the
+// MCP hooks, call logging and billing, and they are visible to find_tool and mcp_pipe.
+// A tool whose namespaced name clashes with an existing tool is skipped.
+// It must be called before the server starts handling requests.
+func (s *Server) AttachFederation(manager *federation.Manager) {
+	if s == nil || manager == nil {
+		return
+	}
+
+	s.federation = manager
+	manager.WithAuthorization(func(ctx context.Context) string {
+		authHeader, _ := ctx.Value(keyAuthorization).(string)
+		return authHeader
+	})
+
+	registered := 0
+	for _, tool := range manager.Tools() {
+		name := tool.Definition.Name
+		if _, exists := s.toolHandlers[name]; exists {
+			s.logger.Warn("skip federated tool that clashes with a registered tool",
+				zap.String("tool", name), zap.String("upstream", tool.Upstream))
+			continue
+		}
+		s.registerTool(s.mcpServer, tool.Definition, s.federatedToolHandler(tool))
+		registered++
+	}
+
+	s.refreshFindToolIndex()
+	s.logger.Info("attached federated mcp tools", zap.Int("tools", registered))
+}
+
+// federatedToolHandler returns the MCP handler that forwards calls of tool to its upstream.
+func (s *Server) federatedToolHandler(tool federation.Tool) func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name := tool.Definition.Name
+	exec := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := s.federation.Call(ctx, name, req)
+		if err != nil {
+			LoggerFromContext(ctx).Warn("federated tool call failed", zap.String("tool", name), zap.Error(err))
+			return mcp.NewToolResultError(fmt.Sprintf("upstream %s unavailable: %v", tool.Upstream, err)), nil
+		}
+
+		// Only successful upstream results are billed, so failed or timed-out calls cost nothing.
+		if tool.Price > 0 && !result.IsError {
+			markBillingAttempted(ctx)
+			if err := s.billingReporter(ctx, apiKeyFromContext(ctx), tool.Price, name); err != nil {
+				LoggerFromContext(ctx).Warn("federated tool billing denied", zap.String("tool", name), zap.Error(err))
+				return mcp.NewToolResultError(fmt.Sprintf("billing check failed: %v", err)), nil
+			}
+		}
+		return result, nil
+	}
+
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return s.executeToolHandler(ctx, req, name, tool.Price, fmt.Sprintf("%s tool is not available", name), exec)
+	}
+}
+
+// toolPrice returns the base cost billed per call of the named tool.
+func (s *Server) toolPrice(name string) oneapi.Price {
+	if price, ok := toolBaseCosts[name]; ok {
+		return price
+	}
+	if s.federation != nil {
+		if tool, ok := s.federation.Tool(name); ok {
+			return tool.Price
+		}
+	}
+	return 0
+}
@@ -0,0 +1,119 @@
+package mcp
+
+import (
+	"context"
+	"testing"
+
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	mcpgo "github.com/mark3labs/mcp-go/mcp"
+	srv "github.com/mark3labs/mcp-go/server"
+	pgvector "github.com/pgvector/pgvector-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/federation"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/rag"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/tools"
+	"github.com/Laisky/laisky-blog-graphql/library/billing/oneapi"
+	"github.com/Laisky/laisky-blog-graphql/library/log"
+)
+
+// unusedEmbedder fails the test if find_tool computes embeddings.
+type unusedEmbedder struct{ t *testing.T }
+
+func (e unusedEmbedder) EmbedTexts(context.Context, string, []string) ([]pgvector.Vector, error) {
+	e.t.Fatal("embeddings must not be computed")
+	return nil, nil
+}
+
+// newTestFederation connects an in-process upstream that serves an echo and a failing tool.
+func newTestFederation(t *testing.T, cfg federation.UpstreamSettings) *federation.Manager {
+	t.Helper()
+	upstream := srv.NewMCPServer("upstream", "1.0.0", srv.WithToolCapabilities(true))
+	upstream.AddTool(
+		mcpgo.NewTool("echo", mcpgo.WithDescription("Echo a message back."), mcpgo.WithString("msg", mcpgo.Required())),
+		func(_ context.Context, req mcpgo.CallToolRequest) (*mcpgo.CallToolResult, error) {
+			return mcpgo.NewToolResultStructuredOnly(map[string]any{"msg": req.GetString("msg", "")}), nil
+		},
+	)
+	upstream.AddTool(mcpgo.NewTool("fail", mcpgo.WithDescription("Always fail.")),
+		func(context.Context, mcpgo.CallToolRequest) (*mcpgo.CallToolResult, error) {
+			return mcpgo.NewToolResultError("upstream failure"), nil
+		},
+	)
+	client, err := mcpclient.NewInProcessClient(upstream)
+	require.NoError(t, err)
+	require.NoError(t, client.Start(context.Background()))
+
+	manager, err := federation.NewManager(log.Logger.Named("federation_test"))
+	require.NoError(t, err)
+	require.NoError(t, manager.Attach(context.Background(), cfg, client))
+	t.Cleanup(func() { require.NoError(t, manager.Close()) })
+	return manager
+}
+
+// TestAttachFederationRoutesCalls verifies that federated tools are logged, billed,
+// callable from mcp_pipe and indexed by find_tool.
+func TestAttachFederationRoutesCalls(t *testing.T) {
+	recorder := &behaviorRecorder{}
+	s, err := NewServer(nil, nil, nil, nil, rag.Settings{}, nil, nil, nil, nil, recorder, nil,
+		ToolsSettings{MCPPipeEnabled: true}, log.Logger)
+	require.NoError(t, err)
+	billing := &behaviorBillingReporter{}
+	s.billingReporter = billing.Check
+
+	s.findTool, err = tools.NewFindToolTool(unusedEmbedder{t}, log.Logger.Named("find_tool_test"),
+		func(context.Context) string { return "Bearer sk-federation" },
+		func(context.Context, string, oneapi.Price, string) error { return nil },
+		rag.Settings{SemanticWeight: 0.5, LexicalWeight: 0.5})
+	require.NoError(t, err)
+
+	s.AttachFederation(newTestFederation(t, federation.UpstreamSettings{Name: "team", URL: "inprocess", Price: 42}))
+	require.Contains(t, s.AvailableToolNames(), "team_echo")
+	require.Equal(t, oneapi.Price(42), s.toolPrice("team_echo"))
+
+	ctx := context.WithValue(context.Background(), keyAuthorization, "Bearer sk-federation")
+	result, err := s.handleMCPPipe(ctx, mcpgo.CallToolRequest{Params: mcpgo.CallToolParams{Arguments: map[string]any{
+		"spec": map[string]any{
+			"steps":  []any{map[string]any{"id": "e", "tool": "team_echo", "args": map[string]any{"msg": "hello"}}},
+			"return": map[string]any{"$ref": "steps.e.structured.msg"},
+		},
+	}}})
+	require.NoError(t, err)
+	require.False(t, result.IsError, result.StructuredContent)
+	require.Equal(t, "hello", result.StructuredContent.(map[string]any)["result"])
+
+	require.Equal(t, 1, billing.count())
+	require.Equal(t, oneapi.Price(42), billing.last().price)
+	require.Equal(t, "team_echo", billing.last().reason)
+	require.Equal(t, "team_echo", recorder.records[0].ToolName)
+	require.Equal(t, 42, recorder.records[0].Cost)
+
+	found, err := s.findTool.Handle(ctx, mcpgo.CallToolRequest{Params: mcpgo.CallToolParams{Arguments: map[string]any{
+		"query": "echo a message", "mode": "bm25", "return_references_only": true,
+	}}})
+	require.NoError(t, err)
+	require.Contains(t, found.Content[0].(mcpgo.TextContent).Text, "team_echo")
+
+	// Failed upstream calls are not billed.
+	failed, err := s.toolHandlers["team_fail"](ctx, mcpgo.CallToolRequest{})
+	require.NoError(t, err)
+	require.True(t, failed.IsError)
+	require.Equal(t, 1, billing.count())
+}
+
+// TestAttachFederationSkipsClashingTools verifies that federated tools never shadow local tools.
+func TestAttachFederationSkipsClashingTools(t *testing.T) {
+	s, err := NewServer(nil, nil, nil, nil, rag.Settings{}, nil, nil, nil, nil, nil, nil,
+		ToolsSettings{MCPPipeEnabled: true}, log.Logger)
+	require.NoError(t, err)
+	local := func(context.Context, mcpgo.CallToolRequest) (*mcpgo.CallToolResult, error) {
+		return mcpgo.NewToolResultText("local"), nil
+	}
+	s.registerTool(s.mcpServer, mcpgo.NewTool("team_echo"), local)
+
+	s.AttachFederation(newTestFederation(t, federation.UpstreamSettings{Name: "team", URL: "inprocess"}))
+
+	result, err := s.toolHandlers["team_echo"](context.Background(), mcpgo.CallToolRequest{})
+	require.NoError(t, err)
+	require.Equal(t, "local", result.Content[0].(mcpgo.TextContent).Text)
+}
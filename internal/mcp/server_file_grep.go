@@ -0,0 +1,39 @@
+package mcp
+
+import (
+	"context"
+
+	"github.com/Laisky/zap"
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/files"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/tools"
+)
+
+// AttachFileGrep registers file_grep. Grep reads file rows and blobs straight from the
+// FileIO tables, so it bypasses the plugin chain and sees writes before indexing.
+func (s *Server) AttachFileGrep(service *files.Service) {
+	if s == nil || service == nil {
+		return
+	}
+
+	grepTool, err := tools.NewFileGrepTool(service)
+	if err != nil {
+		s.logger.Error("init file_grep tool", zap.Error(err))
+		return
+	}
+
+	s.fileGrep = grepTool
+	s.registerTool(s.mcpServer, grepTool.Definition(), s.handleFileGrep)
+	s.refreshFindToolIndex()
+}
+
+// handleFileGrep executes the file_grep MCP tool, auditing the invocation via the call logger.
+func (s *Server) handleFileGrep(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var exec toolExecutor
+	if s.fileGrep != nil {
+		exec = s.fileGrep.Handle
+	}
+
+	return s.executeToolHandler(ctx, req, "file_grep", 0, "file_grep tool is not available", exec)
+}
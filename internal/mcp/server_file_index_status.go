@@ -0,0 +1,39 @@
+package mcp
+
+import (
+	"context"
+
+	"github.com/Laisky/zap"
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/files"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/tools"
+)
+
+// AttachFileIndexStatus registers file_index_status, which reports pending, retrying
+// and failed index jobs of a project and can requeue the failed ones.
+func (s *Server) AttachFileIndexStatus(service *files.Service) {
+	if s == nil || service == nil {
+		return
+	}
+
+	statusTool, err := tools.NewFileIndexStatusTool(service)
+	if err != nil {
+		s.logger.Error("init file_index_status tool", zap.Error(err))
+		return
+	}
+
+	s.fileIndexStatus = statusTool
+	s.registerTool(s.mcpServer, statusTool.Definition(), s.handleFileIndexStatus)
+	s.refreshFindToolIndex()
+}
+
+// handleFileIndexStatus executes the file_index_status MCP tool, auditing the invocation via the call logger.
+func (s *Server) handleFileIndexStatus(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var exec toolExecutor
+	if s.fileIndexStatus != nil {
+		exec = s.fileIndexStatus.Handle
+	}
+
+	return s.executeToolHandler(ctx, req, "file_index_status", 0, "file_index_status tool is not available", exec)
+}
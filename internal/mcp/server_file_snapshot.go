@@ -0,0 +1,39 @@
+package mcp
+
+import (
+	"context"
+
+	"github.com/Laisky/zap"
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/files"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/tools"
+)
+
+// AttachFileSnapshots registers file_snapshot, which creates, lists, diffs, restores
+// and deletes whole-project snapshots.
+func (s *Server) AttachFileSnapshots(service *files.Service) {
+	if s == nil || service == nil {
+		return
+	}
+
+	snapshotTool, err := tools.NewFileSnapshotTool(service)
+	if err != nil {
+		s.logger.Error("init file_snapshot tool", zap.Error(err))
+		return
+	}
+
+	s.fileSnapshot = snapshotTool
+	s.registerTool(s.mcpServer, snapshotTool.Definition(), s.handleFileSnapshot)
+	s.refreshFindToolIndex()
+}
+
+// handleFileSnapshot executes the file_snapshot MCP tool, auditing the invocation via the call logger.
+func (s *Server) handleFileSnapshot(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var exec toolExecutor
+	if s.fileSnapshot != nil {
+		exec = s.fileSnapshot.Handle
+	}
+
+	return s.executeToolHandler(ctx, req, "file_snapshot", 0, "file_snapshot tool is not available", exec)
+}
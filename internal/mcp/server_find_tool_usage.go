@@ -0,0 +1,83 @@
+package mcp
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	errors "github.com/Laisky/errors/v2"
+
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/calllog"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/tools"
+)
+
+const (
+	// findToolUsageWindow bounds the call history that boosts find_tool rankings,
+	// so tools that were fixed or broken recently are not judged by old calls.
+	findToolUsageWindow = 30 * 24 * time.Hour
+	// findToolGlobalUsageTTL is how long the all-callers stats are reused between queries.
+	findToolGlobalUsageTTL = 5 * time.Minute
+)
+
+// toolStatsSource aggregates recorded tool calls, as implemented by *calllog.Service.
+type toolStatsSource interface {
+	ToolStats(context.Context, calllog.ToolStatsOptions) ([]calllog.ToolStat, error)
+}
+
+// findToolUsageLoader feeds call log success rates into find_tool hybrid rankings.
+// Stats across all callers change slowly and are cached; per-key stats are queried on demand.
+type findToolUsageLoader struct {
+	source toolStatsSource
+	now    func() time.Time
+
+	mu          sync.Mutex
+	global      map[string]tools.ToolUsageStats
+	globalUntil time.Time
+}
+
+// Load implements tools.FindToolUsageProvider.
+func (l *findToolUsageLoader) Load(ctx context.Context, apiKey string) (*tools.FindToolUsage, error) {
+	since := l.now().Add(-findToolUsageWindow)
+
+	global, err := l.loadGlobal(ctx, since)
+	if err != nil {
+		return nil, errors.Wrap(err, "load global tool stats")
+	}
+
+	usage := &tools.FindToolUsage{Global: global}
+	if apiKey != "" {
+		stats, err := l.source.ToolStats(ctx, calllog.ToolStatsOptions{APIKey: apiKey, Since: since})
+		if err != nil {
+			return nil, errors.Wrap(err, "load api key tool stats")
+		}
+		usage.Key = toolUsageByName(stats)
+	}
+
+	return usage, nil
+}
+
+// loadGlobal returns the cached all-callers stats, refreshing them once they expire.
+func (l *findToolUsageLoader) loadGlobal(ctx context.Context, since time.Time) (map[string]tools.ToolUsageStats, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.global != nil && l.now().Before(l.globalUntil) {
+		return l.global, nil
+	}
+
+	stats, err := l.source.ToolStats(ctx, calllog.ToolStatsOptions{Since: since})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	l.global = toolUsageByName(stats)
+	l.globalUntil = l.now().Add(findToolGlobalUsageTTL)
+	return l.global, nil
+}
+
+// toolUsageByName indexes call log stats by tool name.
+func toolUsageByName(stats []calllog.ToolStat) map[string]tools.ToolUsageStats {
+	byName := make(map[string]tools.ToolUsageStats, len(stats))
+	for _, stat := range stats {
+		byName[stat.ToolName] = tools.ToolUsageStats{Calls: stat.Calls, Successes: stat.Successes}
+	}
+	return byName
+}
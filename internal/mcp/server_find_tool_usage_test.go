@@ -0,0 +1,47 @@
+package mcp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/calllog"
+)
+
+// fakeToolStatsSource returns fixed stats and records the queries it receives.
+type fakeToolStatsSource struct {
+	queries []calllog.ToolStatsOptions
+}
+
+func (f *fakeToolStatsSource) ToolStats(_ context.Context, opts calllog.ToolStatsOptions) ([]calllog.ToolStat, error) {
+	f.queries = append(f.queries, opts)
+	if opts.APIKey != "" {
+		return []calllog.ToolStat{{ToolName: "file_read", Calls: 4, Successes: 1}}, nil
+	}
+	return []calllog.ToolStat{{ToolName: "file_read", Calls: 10, Successes: 9}}, nil
+}
+
+// TestFindToolUsageLoader verifies that global stats are cached while per-key stats are not.
+func TestFindToolUsageLoader(t *testing.T) {
+	now := time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)
+	source := &fakeToolStatsSource{}
+	loader := &findToolUsageLoader{source: source, now: func() time.Time { return now }}
+
+	usage, err := loader.Load(context.Background(), "sk-usage")
+	require.NoError(t, err)
+	require.EqualValues(t, 9, usage.Global["file_read"].Successes)
+	require.EqualValues(t, 4, usage.Key["file_read"].Calls)
+	require.Equal(t, now.Add(-findToolUsageWindow), source.queries[0].Since)
+
+	_, err = loader.Load(context.Background(), "sk-usage")
+	require.NoError(t, err)
+	require.Len(t, source.queries, 3, "global stats should be served from cache")
+
+	now = now.Add(findToolGlobalUsageTTL)
+	usage, err = loader.Load(context.Background(), "")
+	require.NoError(t, err)
+	require.Nil(t, usage.Key)
+	require.Len(t, source.queries, 4, "expired global stats should be reloaded")
+}
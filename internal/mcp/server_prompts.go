@@ -0,0 +1,119 @@
+package mcp
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Laisky/zap"
+	mcp "github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/askuser"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/userrequests"
+)
+
+// savedCommandDescriptionLimit caps the prompt description derived from a command.
+const savedCommandDescriptionLimit = 120
+
+// enableSavedCommandPrompts exposes the caller's saved commands as MCP prompts.
+//
+// Saved commands are scoped per API key, while mcp-go only serves global prompts,
+// so prompts/list and prompts/get are answered with the caller's authorization
+// before they reach mcp-go.
+func (s *Server) enableSavedCommandPrompts(service *userrequests.Service) {
+	s.savedCommands = service
+	s.registerRPCMethod(string(mcp.MethodPromptsList), s.handleListSavedCommandPrompts)
+	s.registerRPCMethod(string(mcp.MethodPromptsGet), s.handleGetSavedCommandPrompt)
+}
+
+// savedCommandPrompt is a saved command exposed under a unique prompt name.
+type savedCommandPrompt struct {
+	prompt  mcp.Prompt
+	command userrequests.SavedCommand
+}
+
+// savedCommandPrompts loads the caller's saved commands in their display order.
+// Commands that share a label are disambiguated with the start of their ID.
+func (s *Server) savedCommandPrompts(r *http.Request, call rpcCall) ([]savedCommandPrompt, *mcp.JSONRPCErrorDetails) {
+	if call.Auth == nil {
+		return nil, nil
+	}
+
+	commands, err := s.savedCommands.ListSavedCommands(r.Context(), &askuser.AuthorizationContext{
+		APIKey:       call.Auth.APIKey,
+		APIKeyHash:   call.Auth.APIKeyHash,
+		KeySuffix:    call.Auth.KeySuffix,
+		UserIdentity: call.Auth.UserIdentity,
+	})
+	if err != nil {
+		s.logger.Warn("list saved commands for prompts", zap.Error(err))
+		return nil, rpcError(mcp.INTERNAL_ERROR, "failed to load saved commands")
+	}
+
+	prompts := make([]savedCommandPrompt, 0, len(commands))
+	seen := make(map[string]struct{}, len(commands))
+	for _, command := range commands {
+		name := command.Label
+		if _, duplicate := seen[name]; duplicate {
+			name = command.Label + " (" + command.ID.String()[:8] + ")"
+		}
+		seen[name] = struct{}{}
+		prompts = append(prompts, savedCommandPrompt{
+			prompt:  mcp.NewPrompt(name, mcp.WithPromptDescription(savedCommandDescription(command.Content))),
+			command: command,
+		})
+	}
+	return prompts, nil
+}
+
+// savedCommandDescription summarizes a command by its first line.
+func savedCommandDescription(content string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(content), "\n")
+	line = strings.TrimSpace(line)
+	if utf8.RuneCountInString(line) <= savedCommandDescriptionLimit {
+		return line
+	}
+	return string([]rune(line)[:savedCommandDescriptionLimit]) + "…"
+}
+
+// handleListSavedCommandPrompts answers prompts/list.
+func (s *Server) handleListSavedCommandPrompts(r *http.Request, call rpcCall) (any, *mcp.JSONRPCErrorDetails) {
+	prompts, rpcErr := s.savedCommandPrompts(r, call)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	result := mcp.ListPromptsResult{Prompts: make([]mcp.Prompt, 0, len(prompts))}
+	for _, item := range prompts {
+		result.Prompts = append(result.Prompts, item.prompt)
+	}
+	return result, nil
+}
+
+// handleGetSavedCommandPrompt answers prompts/get with the saved command as a user message.
+// The prompt can be addressed by its listed name or by the command ID.
+func (s *Server) handleGetSavedCommandPrompt(r *http.Request, call rpcCall) (any, *mcp.JSONRPCErrorDetails) {
+	if call.Auth == nil {
+		return nil, rpcError(mcp.INVALID_REQUEST, "missing authorization")
+	}
+
+	var params mcp.GetPromptParams
+	if err := json.Unmarshal(call.Params, &params); err != nil || params.Name == "" {
+		return nil, rpcError(mcp.INVALID_PARAMS, "prompt name is required")
+	}
+
+	prompts, rpcErr := s.savedCommandPrompts(r, call)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	for _, item := range prompts {
+		if item.prompt.Name != params.Name && item.command.ID.String() != params.Name {
+			continue
+		}
+		return mcp.NewGetPromptResult(item.prompt.Description, []mcp.PromptMessage{
+			mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(item.command.Content)),
+		}), nil
+	}
+	return nil, rpcError(mcp.INVALID_PARAMS, "prompt not found: "+params.Name)
+}
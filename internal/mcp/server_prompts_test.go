@@ -0,0 +1,88 @@
+package mcp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	logSDK "github.com/Laisky/go-utils/v6/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/rag"
+)
+
+// TestSavedCommandsExposedAsPrompts verifies that saved commands are listed and rendered
+// as prompts for their owner only.
+func TestSavedCommandsExposedAsPrompts(t *testing.T) {
+	service := newUserPreferenceServiceForToolsListTest(t, "file:saved_command_prompts?mode=memory&cache=shared")
+	server, err := NewServer(nil, nil, service, nil, rag.Settings{}, nil, nil, nil, nil, nil, nil,
+		ToolsSettings{}, logSDK.Shared)
+	require.NoError(t, err)
+
+	owner := "Bearer sk-saved-command-owner"
+	ctx := context.Background()
+	_, err = service.CreateSavedCommand(ctx, mustAuthorizationContext(t, owner), "review", "Review the diff.\nFocus on errors.")
+	require.NoError(t, err)
+	duplicate, err := service.CreateSavedCommand(ctx, mustAuthorizationContext(t, owner), "review", strings.Repeat("long ", 40))
+	require.NoError(t, err)
+
+	handler := server.Handler()
+	payload, sessionID := postMCP(t, handler, "", owner, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	require.Contains(t, payload["result"].(map[string]any)["capabilities"], "prompts")
+
+	listPrompts := func(authorization string) []any {
+		payload, _ := postMCP(t, handler, sessionID, authorization, `{"jsonrpc":"2.0","id":2,"method":"prompts/list","params":{}}`)
+		return payload["result"].(map[string]any)["prompts"].([]any)
+	}
+	prompts := listPrompts(owner)
+	require.Len(t, prompts, 2)
+	first := prompts[0].(map[string]any)
+	require.Equal(t, "review", first["name"])
+	require.Equal(t, "Review the diff.", first["description"])
+	second := prompts[1].(map[string]any)
+	require.Equal(t, "review ("+duplicate.ID.String()[:8]+")", second["name"])
+	require.True(t, strings.HasSuffix(second["description"].(string), "…"))
+	require.Empty(t, listPrompts("Bearer sk-saved-command-stranger"))
+
+	payload, _ = postMCP(t, handler, sessionID, owner, `{"jsonrpc":"2.0","id":3,"method":"prompts/get","params":{"name":"review"}}`)
+	messages := payload["result"].(map[string]any)["messages"].([]any)
+	require.Len(t, messages, 1)
+	message := messages[0].(map[string]any)
+	require.Equal(t, "user", message["role"])
+	require.Equal(t, "Review the diff.\nFocus on errors.", message["content"].(map[string]any)["text"])
+
+	payload, _ = postMCP(t, handler, sessionID, owner,
+		`{"jsonrpc":"2.0","id":4,"method":"prompts/get","params":{"name":"`+duplicate.ID.String()+`"}}`)
+	require.Contains(t, payload, "result")
+
+	payload, _ = postMCP(t, handler, sessionID, "Bearer sk-saved-command-stranger",
+		`{"jsonrpc":"2.0","id":5,"method":"prompts/get","params":{"name":"review"}}`)
+	require.Contains(t, payload["error"].(map[string]any)["message"], "prompt not found")
+}
+
+// TestRPCOverridesRejectOversizedAndBatchRequests verifies the request body cap and the
+// explicit rejection of JSON-RPC batches.
+func TestRPCOverridesRejectOversizedAndBatchRequests(t *testing.T) {
+	server, err := NewServer(nil, nil, nil, nil, rag.Settings{}, nil, nil, nil, nil, nil, nil,
+		ToolsSettings{MCPPipeEnabled: true, MaxRequestBodyBytes: 1024}, logSDK.Shared)
+	require.NoError(t, err)
+	handler := server.Handler()
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/mcp/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post(`{"jsonrpc":"2.0","id":1,"method":"ping","params":{"pad":"` + strings.Repeat("x", 2048) + `"}}`)
+	require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	require.Contains(t, rec.Body.String(), "exceeds 1024 bytes")
+
+	rec = post(`[{"jsonrpc":"2.0","id":1,"method":"prompts/list"}]`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "batch requests are not supported")
+}
@@ -0,0 +1,331 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	errors "github.com/Laisky/errors/v2"
+	"github.com/Laisky/zap"
+	mcp "github.com/mark3labs/mcp-go/mcp"
+	srv "github.com/mark3labs/mcp-go/server"
+
+	mcpauth "github.com/Laisky/laisky-blog-graphql/internal/mcp/auth"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/files"
+)
+
+const (
+	// fileResourceScheme prefixes the URIs of FileIO files, file://{project}{path}.
+	fileResourceScheme = "file://"
+	// fileResourceURITemplate matches the URI of any FileIO file.
+	fileResourceURITemplate = "file://{project}{+path}"
+	// maxListedFileResources caps the files returned by one resources/list.
+	maxListedFileResources = 1000
+	// maxFileResourceDepth is deep enough to reach every valid file path.
+	maxFileResourceDepth = 256
+
+	methodResourcesSubscribe   = "resources/subscribe"
+	methodResourcesUnsubscribe = "resources/unsubscribe"
+)
+
+// AttachFileResources exposes the caller's FileIO files as MCP resources.
+//
+// Every file is addressed as file://{project}{path}. resources/list returns the caller's
+// files as session resources, resources/read reads one through the caller's authorization,
+// and sessions that subscribe to a file receive notifications/resources/updated whenever
+// service commits a change to it. It must be called before the server starts handling requests.
+func (s *Server) AttachFileResources(service *files.Service) {
+	if s == nil || service == nil {
+		return
+	}
+
+	s.files = service
+	s.resourceSubscriptions = newResourceSubscriptions()
+
+	srv.WithResourceCapabilities(true, false)(s.mcpServer)
+	s.mcpServer.AddResourceTemplate(
+		mcp.NewResourceTemplate(fileResourceURITemplate, "FileIO files",
+			mcp.WithTemplateDescription("Files stored with the file_* tools, addressed by project and absolute path."),
+		),
+		s.readFileResource,
+	)
+
+	s.hooks.AddBeforeListResources(func(ctx context.Context, _ any, _ *mcp.ListResourcesRequest) {
+		s.refreshSessionFileResources(ctx)
+	})
+	s.hooks.AddOnUnregisterSession(func(_ context.Context, session srv.ClientSession) {
+		s.resourceSubscriptions.drop(session.SessionID())
+	})
+	s.registerRPCMethod(methodResourcesSubscribe, s.handleResourceSubscribe)
+	s.registerRPCMethod(methodResourcesUnsubscribe, s.handleResourceUnsubscribe)
+
+	service.AddChangeObserver(s.notifyFileResourceUpdated)
+	s.logger.Info("attached file resources")
+}
+
+// fileResourceURI returns the resource URI of a file.
+func fileResourceURI(project, filePath string) string {
+	return fileResourceScheme + project + filePath
+}
+
+// parseFileResourceURI splits a file resource URI into its project and path.
+func parseFileResourceURI(uri string) (project, filePath string, err error) {
+	rest, ok := strings.CutPrefix(uri, fileResourceScheme)
+	if !ok {
+		return "", "", errors.Errorf("unsupported resource uri %q", uri)
+	}
+	project, filePath, _ = strings.Cut(rest, "/")
+	filePath = "/" + filePath
+	if err := files.ValidateProject(project); err != nil {
+		return "", "", errors.WithStack(err)
+	}
+	if err := files.ValidatePath(filePath); err != nil || filePath == "/" {
+		return "", "", errors.Errorf("resource uri %q does not name a file", uri)
+	}
+	return project, filePath, nil
+}
+
+// fileResourceMIMEType guesses the MIME type of a file from its extension.
+func fileResourceMIMEType(filePath string) string {
+	if mimeType := mime.TypeByExtension(path.Ext(filePath)); mimeType != "" {
+		return mimeType
+	}
+	return "text/plain"
+}
+
+// fileResourceAuth resolves the caller's FileIO identity.
+func fileResourceAuth(ctx context.Context) (files.AuthContext, error) {
+	auth, ok := mcpauth.FromContext(ctx)
+	if !ok {
+		return files.AuthContext{}, errors.New("missing authorization")
+	}
+	return files.AuthContext{
+		APIKey:       auth.APIKey,
+		APIKeyHash:   auth.APIKeyHash,
+		UserID:       auth.UserID,
+		UserIdentity: auth.UserIdentity,
+	}, nil
+}
+
+// readFileResource answers resources/read for a file resource.
+func (s *Server) readFileResource(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	auth, err := fileResourceAuth(ctx)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	project, filePath, err := parseFileResourceURI(req.Params.URI)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	result, err := s.files.Read(ctx, auth, project, filePath, 0, -1)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read %s", req.Params.URI)
+	}
+	if result.ContentEncoding == files.ContentEncodingBase64 {
+		return []mcp.ResourceContents{mcp.BlobResourceContents{
+			URI:      req.Params.URI,
+			MIMEType: result.MimeType,
+			Blob:     result.Content,
+		}}, nil
+	}
+	return []mcp.ResourceContents{mcp.TextResourceContents{
+		URI:      req.Params.URI,
+		MIMEType: fileResourceMIMEType(filePath),
+		Text:     result.Content,
+	}}, nil
+}
+
+// fileResources lists the caller's files as resources.
+// It returns nil when the caller is unauthenticated or the lookup fails.
+func (s *Server) fileResources(ctx context.Context) []mcp.Resource {
+	auth, err := fileResourceAuth(ctx)
+	if err != nil {
+		return nil
+	}
+
+	logger := LoggerFromContext(ctx)
+	projects, err := s.files.ListProjects(ctx, auth)
+	if err != nil {
+		logger.Warn("list file projects for resources", zap.Error(err))
+		return nil
+	}
+
+	var resources []mcp.Resource
+	for _, project := range projects {
+		listed, err := s.files.List(ctx, auth, project, "", maxFileResourceDepth, maxListedFileResources)
+		if err != nil {
+			logger.Warn("list files for resources", zap.String("project", project), zap.Error(err))
+			continue
+		}
+		for _, entry := range listed.Entries {
+			if entry.Type != files.FileTypeFile {
+				continue
+			}
+			if len(resources) == maxListedFileResources {
+				logger.Debug("file resources truncated", zap.Int("limit", maxListedFileResources))
+				return resources
+			}
+			resources = append(resources, mcp.NewResource(
+				fileResourceURI(project, entry.Path),
+				project+entry.Path,
+				mcp.WithMIMEType(fileResourceMIMEType(entry.Path)),
+				mcp.WithLastModified(entry.UpdatedAt.UTC().Format(time.RFC3339)),
+			))
+		}
+	}
+	return resources
+}
+
+// refreshSessionFileResources replaces the resources of the current MCP session
+// with the caller's files.
+func (s *Server) refreshSessionFileResources(ctx context.Context) {
+	session, ok := srv.ClientSessionFromContext(ctx).(srv.SessionWithResources)
+	if !ok || session.SessionID() == "" {
+		// Session resources are keyed by session ID; without one they would leak across callers.
+		return
+	}
+
+	resources := s.fileResources(ctx)
+	sessionResources := make(map[string]srv.ServerResource, len(resources))
+	for _, resource := range resources {
+		sessionResources[resource.URI] = srv.ServerResource{Resource: resource, Handler: s.readFileResource}
+	}
+	session.SetSessionResources(sessionResources)
+}
+
+// resourceSubscriptionParams are the params of resources/subscribe and resources/unsubscribe.
+type resourceSubscriptionParams struct {
+	URI string `json:"uri"`
+}
+
+// parseResourceSubscription validates a subscription request.
+func parseResourceSubscription(call rpcCall) (string, *mcp.JSONRPCErrorDetails) {
+	if call.Auth == nil {
+		return "", rpcError(mcp.INVALID_REQUEST, "missing authorization")
+	}
+	if call.SessionID == "" {
+		return "", rpcError(mcp.INVALID_REQUEST, "resource subscriptions require an MCP session")
+	}
+
+	var params resourceSubscriptionParams
+	if err := json.Unmarshal(call.Params, &params); err != nil {
+		return "", rpcError(mcp.INVALID_PARAMS, "invalid params")
+	}
+	if _, _, err := parseFileResourceURI(params.URI); err != nil {
+		return "", rpcError(mcp.INVALID_PARAMS, err.Error())
+	}
+	return params.URI, nil
+}
+
+// handleResourceSubscribe answers resources/subscribe.
+func (s *Server) handleResourceSubscribe(_ *http.Request, call rpcCall) (any, *mcp.JSONRPCErrorDetails) {
+	uri, rpcErr := parseResourceSubscription(call)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	s.resourceSubscriptions.subscribe(call.SessionID, call.Auth.APIKeyHash, uri)
+	return mcp.EmptyResult{}, nil
+}
+
+// handleResourceUnsubscribe answers resources/unsubscribe.
+func (s *Server) handleResourceUnsubscribe(_ *http.Request, call rpcCall) (any, *mcp.JSONRPCErrorDetails) {
+	uri, rpcErr := parseResourceSubscription(call)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	s.resourceSubscriptions.unsubscribe(call.SessionID, call.Auth.APIKeyHash, uri)
+	return mcp.EmptyResult{}, nil
+}
+
+// notifyFileResourceUpdated sends notifications/resources/updated to the sessions
+// subscribed to the changed file.
+func (s *Server) notifyFileResourceUpdated(_ context.Context, change files.FileChange) {
+	uri := fileResourceURI(change.Project, change.Path)
+	for _, sessionID := range s.resourceSubscriptions.subscribers(change.APIKeyHash, uri) {
+		err := s.mcpServer.SendNotificationToSpecificClient(sessionID, mcp.MethodNotificationResourceUpdated,
+			map[string]any{"uri": uri})
+		switch {
+		case errors.Is(err, srv.ErrSessionNotFound):
+			s.resourceSubscriptions.drop(sessionID)
+		case err != nil:
+			s.logger.Debug("send resource updated notification",
+				zap.String("session_id", sessionID), zap.String("uri", uri), zap.Error(err))
+		}
+	}
+}
+
+// resourceSubscriptions tracks the resources each MCP session subscribed to.
+type resourceSubscriptions struct {
+	mu       sync.Mutex
+	sessions map[string]*resourceSubscriber
+}
+
+// resourceSubscriber is the owner and subscribed URIs of one session.
+type resourceSubscriber struct {
+	apiKeyHash string
+	uris       map[string]struct{}
+}
+
+// newResourceSubscriptions creates an empty subscription registry.
+func newResourceSubscriptions() *resourceSubscriptions {
+	return &resourceSubscriptions{sessions: make(map[string]*resourceSubscriber)}
+}
+
+// subscribe records that sessionID, owned by apiKeyHash, follows uri.
+// A session is bound to the key that first subscribed with it.
+func (r *resourceSubscriptions) subscribe(sessionID, apiKeyHash, uri string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	subscriber, ok := r.sessions[sessionID]
+	if !ok || subscriber.apiKeyHash != apiKeyHash {
+		subscriber = &resourceSubscriber{apiKeyHash: apiKeyHash, uris: make(map[string]struct{})}
+		r.sessions[sessionID] = subscriber
+	}
+	subscriber.uris[uri] = struct{}{}
+}
+
+// unsubscribe removes uri from the subscriptions of sessionID.
+func (r *resourceSubscriptions) unsubscribe(sessionID, apiKeyHash, uri string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	subscriber, ok := r.sessions[sessionID]
+	if !ok || subscriber.apiKeyHash != apiKeyHash {
+		return
+	}
+	delete(subscriber.uris, uri)
+	if len(subscriber.uris) == 0 {
+		delete(r.sessions, sessionID)
+	}
+}
+
+// drop forgets every subscription of sessionID.
+func (r *resourceSubscriptions) drop(sessionID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sessions, sessionID)
+}
+
+// subscribers returns the sessions of apiKeyHash that follow uri.
+func (r *resourceSubscriptions) subscribers(apiKeyHash, uri string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var sessionIDs []string
+	for sessionID, subscriber := range r.sessions {
+		if subscriber.apiKeyHash != apiKeyHash {
+			continue
+		}
+		if _, ok := subscriber.uris[uri]; ok {
+			sessionIDs = append(sessionIDs, sessionID)
+		}
+	}
+	return sessionIDs
+}
@@ -0,0 +1,149 @@
+package mcp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	logSDK "github.com/Laisky/go-utils/v6/log"
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	mcpgo "github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/require"
+
+	mcpauth "github.com/Laisky/laisky-blog-graphql/internal/mcp/auth"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/files"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/rag"
+)
+
+// newResourceTestFileService creates a sqlite backed file service without search.
+func newResourceTestFileService(t *testing.T) *files.Service {
+	t.Helper()
+
+	settings := files.LoadSettingsFromConfig()
+	settings.Search.Enabled = false
+	settings.Security.EncryptionKEKs = map[uint16]string{1: base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))}
+
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s-%d?mode=memory&cache=shared", t.Name(), time.Now().UnixNano()))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, db.Close()) })
+
+	service, err := files.NewService(db, settings, nil, nil, nil, nil, logSDK.Shared, nil, nil)
+	require.NoError(t, err)
+	return service
+}
+
+// newResourceTestClient connects a streamable HTTP client that listens for notifications.
+func newResourceTestClient(t *testing.T, url, authorization string) *mcpclient.Client {
+	t.Helper()
+
+	client, err := mcpclient.NewStreamableHttpClient(url,
+		transport.WithHTTPHeaders(map[string]string{"Authorization": authorization}),
+		transport.WithContinuousListening(),
+	)
+	require.NoError(t, err)
+	require.NoError(t, client.Start(context.Background()))
+	t.Cleanup(func() { _ = client.Close() })
+
+	initReq := mcpgo.InitializeRequest{}
+	initReq.Params.ProtocolVersion = mcpgo.LATEST_PROTOCOL_VERSION
+	initialized, err := client.Initialize(context.Background(), initReq)
+	require.NoError(t, err)
+	require.NotNil(t, initialized.Capabilities.Resources)
+	require.True(t, initialized.Capabilities.Resources.Subscribe)
+	return client
+}
+
+// TestFileResources verifies that files are listed, read and followed per API key.
+func TestFileResources(t *testing.T) {
+	service := newResourceTestFileService(t)
+	server, err := NewServer(nil, nil, nil, nil, rag.Settings{}, nil, nil, nil, nil, nil, nil,
+		ToolsSettings{MCPPipeEnabled: true}, logSDK.Shared)
+	require.NoError(t, err)
+	server.AttachFileResources(service)
+
+	ts := httptest.NewServer(server.Handler())
+	t.Cleanup(ts.Close) // runs after the clients are closed
+
+	owner := "Bearer sk-file-resource-owner"
+	ownerAuth, err := mcpauth.ParseAuthorizationContext(owner)
+	require.NoError(t, err)
+	filesAuth := files.AuthContext{APIKey: ownerAuth.APIKey, APIKeyHash: ownerAuth.APIKeyHash, UserIdentity: ownerAuth.UserIdentity}
+
+	ctx := context.Background()
+	_, err = service.Write(ctx, filesAuth, "notes", "/daily/today.md", "# Today", "utf-8", 0, files.WriteModeTruncate)
+	require.NoError(t, err)
+
+	client := newResourceTestClient(t, ts.URL, owner)
+	listed, err := client.ListResources(ctx, mcpgo.ListResourcesRequest{})
+	require.NoError(t, err)
+	require.Len(t, listed.Resources, 1)
+	require.Equal(t, "file://notes/daily/today.md", listed.Resources[0].URI)
+
+	readReq := mcpgo.ReadResourceRequest{}
+	readReq.Params.URI = "file://notes/daily/today.md"
+	read, err := client.ReadResource(ctx, readReq)
+	require.NoError(t, err)
+	require.Equal(t, "# Today", read.Contents[0].(mcpgo.TextResourceContents).Text)
+
+	templates, err := client.ListResourceTemplates(ctx, mcpgo.ListResourceTemplatesRequest{})
+	require.NoError(t, err)
+	require.Len(t, templates.ResourceTemplates, 1)
+
+	// Another key neither lists nor reads the owner's files.
+	stranger := newResourceTestClient(t, ts.URL, "Bearer sk-file-resource-stranger")
+	listed, err = stranger.ListResources(ctx, mcpgo.ListResourcesRequest{})
+	require.NoError(t, err)
+	require.Empty(t, listed.Resources)
+	_, err = stranger.ReadResource(ctx, readReq)
+	require.Error(t, err)
+
+	updated := make(chan string, 4)
+	client.OnNotification(func(notification mcpgo.JSONRPCNotification) {
+		if notification.Method == mcpgo.MethodNotificationResourceUpdated {
+			uri, _ := notification.Params.AdditionalFields["uri"].(string)
+			updated <- uri
+		}
+	})
+	subReq := mcpgo.SubscribeRequest{}
+	subReq.Params.URI = "file://notes/daily/today.md"
+	require.NoError(t, client.Subscribe(ctx, subReq))
+	require.NoError(t, stranger.Subscribe(ctx, subReq))
+
+	badReq := mcpgo.SubscribeRequest{}
+	badReq.Params.URI = "https://example.com/"
+	require.Error(t, client.Subscribe(ctx, badReq))
+
+	_, err = service.Write(ctx, filesAuth, "notes", "/daily/today.md", "\n- ship it", "utf-8", 0, files.WriteModeAppend)
+	require.NoError(t, err)
+	select {
+	case uri := <-updated:
+		require.Equal(t, "file://notes/daily/today.md", uri)
+	case <-time.After(5 * time.Second):
+		t.Fatal("resource updated notification not received")
+	}
+	require.Len(t, server.resourceSubscriptions.subscribers(ownerAuth.APIKeyHash, subReq.Params.URI), 1)
+
+	unsubReq := mcpgo.UnsubscribeRequest{}
+	unsubReq.Params.URI = subReq.Params.URI
+	require.NoError(t, client.Unsubscribe(ctx, unsubReq))
+	require.Empty(t, server.resourceSubscriptions.subscribers(ownerAuth.APIKeyHash, subReq.Params.URI))
+}
+
+// TestParseFileResourceURI verifies the mapping between resource URIs and file paths.
+func TestParseFileResourceURI(t *testing.T) {
+	project, filePath, err := parseFileResourceURI("file://notes/a/b.md")
+	require.NoError(t, err)
+	require.Equal(t, "notes", project)
+	require.Equal(t, "/a/b.md", filePath)
+	require.Equal(t, "file://notes/a/b.md", fileResourceURI(project, filePath))
+
+	for _, uri := range []string{"file://notes", "file://notes/", "file://bad project/a", "file://notes/a/../b", "mem://notes/a"} {
+		_, _, err := parseFileResourceURI(uri)
+		require.Error(t, err, uri)
+	}
+}
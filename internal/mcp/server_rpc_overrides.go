@@ -0,0 +1,163 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	errors "github.com/Laisky/errors/v2"
+	logSDK "github.com/Laisky/go-utils/v6/log"
+	"github.com/Laisky/zap"
+	mcp "github.com/mark3labs/mcp-go/mcp"
+	srv "github.com/mark3labs/mcp-go/server"
+
+	mcpauth "github.com/Laisky/laisky-blog-graphql/internal/mcp/auth"
+)
+
+// rpcCall is one JSON-RPC request answered outside of mcp-go.
+type rpcCall struct {
+	// Auth is the caller's authorization, nil when the request carries none.
+	Auth *mcpauth.Context
+	// SessionID is the MCP session of the request, empty before initialize.
+	SessionID string
+	Params    json.RawMessage
+}
+
+// rpcMethodHandler answers one JSON-RPC method.
+// It returns either the result or the JSON-RPC error to report.
+type rpcMethodHandler func(r *http.Request, call rpcCall) (any, *mcp.JSONRPCErrorDetails)
+
+// rpcError builds a JSON-RPC error detail.
+func rpcError(code int, message string) *mcp.JSONRPCErrorDetails {
+	details := mcp.NewJSONRPCErrorDetails(code, message, nil)
+	return &details
+}
+
+// registerRPCMethod answers method in the HTTP layer instead of forwarding it to mcp-go.
+//
+// mcp-go routes resources/subscribe and per-caller prompts nowhere, so these methods are
+// answered here with the caller's authorization. It must be called before the server
+// starts handling requests.
+func (s *Server) registerRPCMethod(method string, handler rpcMethodHandler) {
+	if s.rpcMethods == nil {
+		s.rpcMethods = make(map[string]rpcMethodHandler)
+	}
+	s.rpcMethods[method] = handler
+}
+
+// defaultMaxRequestBodyBytes caps MCP request bodies when no limit is configured.
+const defaultMaxRequestBodyBytes int64 = 16 << 20
+
+// withRequestBodyLimit caps the request body at limit bytes before any middleware buffers it.
+// A non-positive limit uses defaultMaxRequestBodyBytes.
+func withRequestBodyLimit(next http.Handler, limit int64) http.Handler {
+	if next == nil {
+		return nil
+	}
+	if limit <= 0 {
+		limit = defaultMaxRequestBodyBytes
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeRPCError writes a JSON-RPC error that is not tied to a request ID.
+func writeRPCError(w http.ResponseWriter, status int, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(mcp.JSONRPCError{
+		JSONRPC: mcp.JSONRPC_VERSION,
+		ID:      mcp.NewRequestId(nil),
+		Error:   *rpcError(code, message),
+	})
+}
+
+// withRPCMethodOverrides answers the JSON-RPC methods registered on s before they reach next.
+//
+// JSON-RPC batches are rejected explicitly: mcp-go cannot serve them either, and letting one
+// through would route overridden methods to a server that does not know them.
+func withRPCMethodOverrides(next http.Handler, logger logSDK.Logger, s *Server) http.Handler {
+	if next == nil {
+		return nil
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Body == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeRPCError(w, http.StatusRequestEntityTooLarge, mcp.INVALID_REQUEST,
+					fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+				return
+			}
+			if logger != nil {
+				logger.Warn("read request body for rpc overrides", zap.Error(err))
+			}
+			writeRPCError(w, http.StatusBadRequest, mcp.PARSE_ERROR, "read request body failed")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+			writeRPCError(w, http.StatusBadRequest, mcp.INVALID_REQUEST, "JSON-RPC batch requests are not supported")
+			return
+		}
+		if len(s.rpcMethods) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var payload struct {
+			ID     *mcp.RequestId  `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil || payload.ID == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		handler, ok := s.rpcMethods[payload.Method]
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		call := rpcCall{
+			SessionID: strings.TrimSpace(r.Header.Get(srv.HeaderKeySessionID)),
+			Params:    payload.Params,
+		}
+		authHeader, _ := resolveRequestAuthorizationHeader(r)
+		if auth, err := mcpauth.ParseAuthorizationContext(authHeader); err == nil {
+			call.Auth = auth
+		}
+
+		var response any
+		result, rpcErr := handler(r, call)
+		if rpcErr != nil {
+			response = mcp.JSONRPCError{JSONRPC: mcp.JSONRPC_VERSION, ID: *payload.ID, Error: *rpcErr}
+		} else {
+			response = mcp.NewJSONRPCResultResponse(*payload.ID, result)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if call.SessionID != "" {
+			w.Header().Set(srv.HeaderKeySessionID, call.SessionID)
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil && logger != nil {
+			logger.Warn("write rpc override response", zap.String("method", payload.Method), zap.Error(err))
+		}
+	})
+}
@@ -0,0 +1,134 @@
+package mcp
+
+import (
+	"context"
+	"time"
+
+	errors "github.com/Laisky/errors/v2"
+	"github.com/Laisky/zap"
+	mcp "github.com/mark3labs/mcp-go/mcp"
+	srv "github.com/mark3labs/mcp-go/server"
+
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/askuser"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/tools"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/userrequests"
+)
+
+// enableSavedPipelines exposes the caller's saved pipelines as session tools.
+//
+// Saved pipelines are scoped per API key, so they cannot be registered globally.
+// Instead, the tools of the current session are refreshed from the database
+// before every tools/list and before calls to a saved pipeline tool.
+func (s *Server) enableSavedPipelines(hooks *srv.Hooks, service *userrequests.Service) {
+	s.savedPipelines = service
+	service.WithPipelineSpecValidator(s.mcpPipe.ValidateSavedSpec)
+
+	hooks.AddBeforeListTools(func(ctx context.Context, _ any, _ *mcp.ListToolsRequest) {
+		s.refreshSessionPipelineTools(ctx)
+	})
+	hooks.AddBeforeCallTool(func(ctx context.Context, _ any, req *mcp.CallToolRequest) {
+		if _, ok := tools.SavedPipelineNameFromTool(req.Params.Name); ok {
+			s.refreshSessionPipelineTools(ctx)
+		}
+	})
+}
+
+// savedPipelineAuth resolves the caller's authorization for saved pipeline lookups.
+func savedPipelineAuth(ctx context.Context) (*askuser.AuthorizationContext, error) {
+	authHeader, _ := ctx.Value(keyAuthorization).(string)
+	auth, err := askuser.ParseAuthorizationFromContext(ctx, authHeader)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return auth, nil
+}
+
+// listCallerSavedPipelines returns the caller's saved pipelines.
+// It returns nil when the caller is unauthenticated or the lookup fails.
+func (s *Server) listCallerSavedPipelines(ctx context.Context) []userrequests.SavedPipeline {
+	if s.savedPipelines == nil {
+		return nil
+	}
+	auth, err := savedPipelineAuth(ctx)
+	if err != nil {
+		return nil
+	}
+
+	pipelines, err := s.savedPipelines.ListSavedPipelines(ctx, auth)
+	if err != nil {
+		LoggerFromContext(ctx).Warn("list saved pipelines for tools", zap.Error(err))
+		return nil
+	}
+	return pipelines
+}
+
+// savedPipelineSearchTools returns the caller's saved pipelines for find_tool, keyed
+// by pipeline ID and versioned by their update time so their embeddings can be cached.
+func (s *Server) savedPipelineSearchTools(ctx context.Context) []tools.FindToolExtraTool {
+	pipelines := s.listCallerSavedPipelines(ctx)
+	extras := make([]tools.FindToolExtraTool, 0, len(pipelines))
+	for i := range pipelines {
+		extras = append(extras, tools.FindToolExtraTool{
+			Tool:    tools.SavedPipelineDefinition(&pipelines[i]),
+			Key:     pipelines[i].ID.String(),
+			Version: pipelines[i].UpdatedAt.UTC().Format(time.RFC3339Nano),
+		})
+	}
+	return extras
+}
+
+// refreshSessionPipelineTools syncs the saved pipeline tools of the current MCP
+// session with the caller's saved pipelines. Other session tools are kept.
+func (s *Server) refreshSessionPipelineTools(ctx context.Context) {
+	session, ok := srv.ClientSessionFromContext(ctx).(srv.SessionWithTools)
+	if !ok || session.SessionID() == "" {
+		// Session tools are keyed by session ID; without one they would leak across callers.
+		return
+	}
+
+	pipelines := s.listCallerSavedPipelines(ctx)
+	current := session.GetSessionTools()
+	sessionTools := make(map[string]srv.ServerTool, len(current)+len(pipelines))
+	for name, tool := range current {
+		if _, ok := tools.SavedPipelineNameFromTool(name); !ok {
+			sessionTools[name] = tool
+		}
+	}
+	for i := range pipelines {
+		definition := tools.SavedPipelineDefinition(&pipelines[i])
+		logInvalidArrayItemSchemas(s.logger, definition)
+		sessionTools[definition.Name] = srv.ServerTool{Tool: definition, Handler: s.handleSavedPipeline}
+	}
+	session.SetSessionTools(sessionTools)
+}
+
+// handleSavedPipeline executes a saved pipeline tool, auditing the invocation via the call logger.
+// The pipeline is reloaded on every call so edits take effect immediately.
+func (s *Server) handleSavedPipeline(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	toolName := req.Params.Name
+	var exec toolExecutor
+	if s.mcpPipe != nil && s.savedPipelines != nil {
+		exec = func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			name, ok := tools.SavedPipelineNameFromTool(toolName)
+			if !ok {
+				return mcp.NewToolResultError("unknown saved pipeline: " + toolName), nil
+			}
+			auth, err := savedPipelineAuth(ctx)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+
+			pipeline, err := s.savedPipelines.GetSavedPipelineByName(ctx, auth, name)
+			if err != nil {
+				if errors.Is(err, userrequests.ErrSavedPipelineNotFound) {
+					return mcp.NewToolResultError("saved pipeline not found: " + name), nil
+				}
+				return nil, errors.Wrap(err, "load saved pipeline")
+			}
+
+			return s.mcpPipe.RunSaved(ctx, pipeline, argumentsMap(req.Params.Arguments))
+		}
+	}
+
+	return s.executeToolHandler(ctx, req, toolName, 0, "saved pipelines are not available", exec)
+}
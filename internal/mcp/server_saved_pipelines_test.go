@@ -0,0 +1,119 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	logSDK "github.com/Laisky/go-utils/v6/log"
+	mcpgo "github.com/mark3labs/mcp-go/mcp"
+	srv "github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/rag"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/userrequests"
+)
+
+// postMCP sends one JSON-RPC request to the MCP handler and decodes the response.
+func postMCP(t *testing.T, handler http.Handler, sessionID, authorization, body string) (map[string]any, string) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/mcp/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", authorization)
+	if sessionID != "" {
+		req.Header.Set(srv.HeaderKeySessionID, sessionID)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var payload map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &payload), rec.Body.String())
+	return payload, rec.Header().Get(srv.HeaderKeySessionID)
+}
+
+// TestSavedPipelinesExposedAsSessionTools verifies that saved pipelines appear in tools/list
+// for their owner only and can be called like any other tool.
+func TestSavedPipelinesExposedAsSessionTools(t *testing.T) {
+	service := newUserPreferenceServiceForToolsListTest(t, "file:saved_pipelines_session_tools?mode=memory&cache=shared")
+	recorder := &behaviorRecorder{}
+	server, err := NewServer(nil, nil, service, nil, rag.Settings{}, nil, nil, nil, nil, recorder, nil,
+		ToolsSettings{MCPPipeEnabled: true}, logSDK.Shared)
+	require.NoError(t, err)
+
+	server.toolHandlers["echo"] = func(_ context.Context, req mcpgo.CallToolRequest) (*mcpgo.CallToolResult, error) {
+		return mcpgo.NewToolResultJSON(req.GetArguments())
+	}
+
+	owner := "Bearer sk-saved-pipeline-owner"
+	shout, broken := "shout", "broken"
+	vars := []userrequests.SavedPipelineVar{{Name: "text", Type: userrequests.SavedPipelineVarString, Required: true}}
+	_, err = service.CreateSavedPipeline(context.Background(), mustAuthorizationContext(t, owner), userrequests.SavedPipelineInput{
+		Name: &shout,
+		Vars: &vars,
+		Spec: json.RawMessage(`{"steps":[{"id":"e","tool":"echo","args":{"msg":"${vars.text}!"}}],"return":{"$ref":"steps.e.structured.msg"}}`),
+	})
+	require.NoError(t, err)
+
+	// Saving a spec that fails mcp_pipe validation is rejected.
+	_, err = service.CreateSavedPipeline(context.Background(), mustAuthorizationContext(t, owner), userrequests.SavedPipelineInput{
+		Name: &broken,
+		Spec: json.RawMessage(`{"steps":[{"id":"e","tool":"echo","pipe":{"steps":[]}}]}`),
+	})
+	require.ErrorIs(t, err, userrequests.ErrInvalidSavedPipeline)
+
+	handler := server.Handler()
+	listTools := func(authorization string) []string {
+		_, sessionID := postMCP(t, handler, "", authorization, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+		require.NotEmpty(t, sessionID)
+		payload, _ := postMCP(t, handler, sessionID, authorization, `{"jsonrpc":"2.0","id":2,"method":"tools/list","params":{}}`)
+		var names []string
+		for _, tool := range payload["result"].(map[string]any)["tools"].([]any) {
+			names = append(names, tool.(map[string]any)["name"].(string))
+		}
+		return names
+	}
+
+	require.Contains(t, listTools(owner), "pipeline_shout")
+	require.NotContains(t, listTools("Bearer sk-saved-pipeline-stranger"), "pipeline_shout")
+
+	_, sessionID := postMCP(t, handler, "", owner, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	payload, _ := postMCP(t, handler, sessionID, owner,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"pipeline_shout","arguments":{"text":"hi"}}}`)
+	result := payload["result"].(map[string]any)
+	require.NotEqual(t, true, result["isError"], payload)
+	require.Equal(t, "hi!", result["structuredContent"].(map[string]any)["result"])
+	require.Equal(t, "pipeline_shout", recorder.last().ToolName)
+}
+
+// fakeToolsSession is a client session holding session tools in memory.
+type fakeToolsSession struct {
+	tools map[string]srv.ServerTool
+}
+
+func (s *fakeToolsSession) Initialize()                                           {}
+func (s *fakeToolsSession) Initialized() bool                                     { return true }
+func (s *fakeToolsSession) NotificationChannel() chan<- mcpgo.JSONRPCNotification { return nil }
+func (s *fakeToolsSession) SessionID() string                                     { return "session" }
+func (s *fakeToolsSession) GetSessionTools() map[string]srv.ServerTool            { return s.tools }
+func (s *fakeToolsSession) SetSessionTools(tools map[string]srv.ServerTool)       { s.tools = tools }
+
+// TestRefreshSessionPipelineToolsKeepsOtherTools verifies that syncing saved pipelines
+// only replaces the pipeline tools of a session.
+func TestRefreshSessionPipelineToolsKeepsOtherTools(t *testing.T) {
+	server, err := NewServer(nil, nil, nil, nil, rag.Settings{}, nil, nil, nil, nil, nil, nil, ToolsSettings{MCPPipeEnabled: true}, logSDK.Shared)
+	require.NoError(t, err)
+
+	session := &fakeToolsSession{tools: map[string]srv.ServerTool{
+		"other":         {Tool: mcpgo.NewTool("other")},
+		"pipeline_gone": {Tool: mcpgo.NewTool("pipeline_gone")},
+	}}
+	server.refreshSessionPipelineTools(server.mcpServer.WithContext(context.Background(), session))
+
+	require.Contains(t, session.tools, "other")
+	require.NotContains(t, session.tools, "pipeline_gone")
+}
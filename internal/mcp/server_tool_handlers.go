@@ -16,6 +16,7 @@ import (
 	"github.com/Laisky/laisky-blog-graphql/internal/mcp/ctxkeys"
 	"github.com/Laisky/laisky-blog-graphql/internal/mcp/files"
 	mcpmemory "github.com/Laisky/laisky-blog-graphql/internal/mcp/memory"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/tools"
 	"github.com/Laisky/laisky-blog-graphql/library/billing/oneapi"
 	"github.com/Laisky/laisky-blog-graphql/library/log"
 )
@@ -63,6 +64,7 @@ type billingAttemptContextKey struct{}
 
 type billingAttemptState struct {
 	attempted bool
+	billed    oneapi.Price
 }
 
 type toolExecutor func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error)
@@ -89,6 +91,34 @@ func markBillingAttempted(ctx context.Context) {
 	tracker.attempted = true
 }
 
+// addBilledPrice accumulates quota successfully charged during a tool invocation.
+// Parameters:
+//   - ctx: request context carrying billing attempt state.
+//   - price: quota charged by one centralized billing call.
+func addBilledPrice(ctx context.Context, price oneapi.Price) {
+	tracker, ok := ctx.Value(billingAttemptContextKey{}).(*billingAttemptState)
+	if !ok || tracker == nil {
+		return
+	}
+
+	tracker.billed += price
+}
+
+// billedPrice returns the quota charged so far during a tool invocation.
+// Parameters:
+//   - ctx: request context carrying billing attempt state.
+//
+// Returns:
+//   - total quota charged through centralized billing, zero when untracked.
+func billedPrice(ctx context.Context) oneapi.Price {
+	tracker, ok := ctx.Value(billingAttemptContextKey{}).(*billingAttemptState)
+	if !ok || tracker == nil {
+		return 0
+	}
+
+	return tracker.billed
+}
+
 // billingAttemptRecorded reports whether centralized billing was already invoked.
 // Parameters:
 //   - ctx: request context carrying billing attempt state.
@@ -203,6 +233,10 @@ func (s *Server) recordToolInvocation(ctx context.Context, toolName string, apiK
 	params := cloneArguments(args)
 	params = files.RedactToolArguments(toolName, params)
 	params = mcpmemory.RedactToolArguments(toolName, params)
+	if attempt, ok := tools.PipeStepAttemptFromContext(ctx); ok {
+		// Mark sub-tool calls issued by mcp_pipe so retries and fallbacks are visible in call logs.
+		params = withPipeLogMeta(params, attempt.LogFields())
+	}
 	status := calllog.StatusSuccess
 	errorMessage := ""
 
@@ -225,6 +259,10 @@ func (s *Server) recordToolInvocation(ctx context.Context, toolName string, apiK
 	costValue := 0
 	if status == calllog.StatusSuccess {
 		costValue = baseCost
+		// Tools with usage-based pricing, such as fan-out web_search, charge above their base cost.
+		if billed := billedPrice(ctx).Int(); billed > costValue {
+			costValue = billed
+		}
 	}
 
 	if duration < 0 {
@@ -256,6 +294,28 @@ func (s *Server) recordToolInvocation(ctx context.Context, toolName string, apiK
 	}
 }
 
+// withPipeLogMeta records fields under _meta.mcp_pipe of params, the key MCP reserves
+// for metadata, so no tool argument is overwritten. An existing _meta map is copied
+// before fields are added; a _meta argument that is not a map is left as is.
+func withPipeLogMeta(params map[string]any, fields map[string]any) map[string]any {
+	if params == nil {
+		params = map[string]any{}
+	}
+	meta := map[string]any{}
+	if existing, ok := params["_meta"]; ok {
+		existingMap, isMap := existing.(map[string]any)
+		if !isMap {
+			return params
+		}
+		for key, value := range existingMap {
+			meta[key] = value
+		}
+	}
+	meta["mcp_pipe"] = fields
+	params["_meta"] = meta
+	return params
+}
+
 func cloneArguments(args map[string]any) map[string]any {
 	if len(args) == 0 {
 		return nil
@@ -405,6 +465,26 @@ func (s *Server) handleMCPPipe(ctx context.Context, req mcp.CallToolRequest) (*m
 	return s.executeToolHandler(ctx, req, "mcp_pipe", 0, "mcp_pipe tool is not available", exec)
 }
 
+// handleMCPPipeStatus executes the mcp_pipe_status MCP tool, auditing the invocation via the call logger.
+func (s *Server) handleMCPPipeStatus(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var exec toolExecutor
+	if s.mcpPipeStatus != nil {
+		exec = s.mcpPipeStatus.Handle
+	}
+
+	return s.executeToolHandler(ctx, req, "mcp_pipe_status", 0, "mcp_pipe_status tool is not available", exec)
+}
+
+// handleMCPPipeCancel executes the mcp_pipe_cancel MCP tool, auditing the invocation via the call logger.
+func (s *Server) handleMCPPipeCancel(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var exec toolExecutor
+	if s.mcpPipeCancel != nil {
+		exec = s.mcpPipeCancel.Handle
+	}
+
+	return s.executeToolHandler(ctx, req, "mcp_pipe_cancel", 0, "mcp_pipe_cancel tool is not available", exec)
+}
+
 // handleFindTool executes the find_tool MCP tool, auditing the invocation via the call logger.
 func (s *Server) handleFindTool(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	var exec toolExecutor
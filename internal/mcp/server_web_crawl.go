@@ -0,0 +1,86 @@
+package mcp
+
+import (
+	"context"
+
+	"github.com/Laisky/zap"
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/crawljobs"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/tools"
+)
+
+// AttachWebCrawl registers web_crawl and its companion tools. Crawled pages are fetched
+// through web_fetch and written to fileService, so both must be available.
+func (s *Server) AttachWebCrawl(jobs *crawljobs.Service, fileService tools.FileService) {
+	if s == nil || jobs == nil || fileService == nil {
+		return
+	}
+	if s.webFetch == nil {
+		s.logger.Info("web_crawl tool disabled because web_fetch is unavailable")
+		return
+	}
+
+	crawlTool, err := tools.NewWebCrawlTool(
+		jobs,
+		s.logger.Named("web_crawl"),
+		apiKeyFromContext,
+		// Pages are billed from the background job, after the web_crawl call itself was logged.
+		tools.BillingChecker(s.billingReporter),
+		s.webFetch.FetchPage,
+		newWebPageFetcher(),
+		fileService,
+	)
+	if err != nil {
+		s.logger.Error("init web_crawl tool", zap.Error(err))
+		return
+	}
+	statusTool, err := tools.NewWebCrawlStatusTool(crawlTool)
+	if err != nil {
+		s.logger.Error("init web_crawl_status tool", zap.Error(err))
+		return
+	}
+	cancelTool, err := tools.NewWebCrawlCancelTool(crawlTool)
+	if err != nil {
+		s.logger.Error("init web_crawl_cancel tool", zap.Error(err))
+		return
+	}
+
+	s.webCrawl = crawlTool
+	s.webCrawlStatus = statusTool
+	s.webCrawlCancel = cancelTool
+	s.registerTool(s.mcpServer, crawlTool.Definition(), s.handleWebCrawl)
+	s.registerTool(s.mcpServer, statusTool.Definition(), s.handleWebCrawlStatus)
+	s.registerTool(s.mcpServer, cancelTool.Definition(), s.handleWebCrawlCancel)
+	s.refreshFindToolIndex()
+}
+
+// handleWebCrawl executes the web_crawl MCP tool, auditing the invocation via the call logger.
+func (s *Server) handleWebCrawl(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var exec toolExecutor
+	if s.webCrawl != nil {
+		exec = s.webCrawl.Handle
+	}
+
+	return s.executeToolHandler(ctx, req, "web_crawl", 0, "web_crawl tool is not available", exec)
+}
+
+// handleWebCrawlStatus executes the web_crawl_status MCP tool, auditing the invocation via the call logger.
+func (s *Server) handleWebCrawlStatus(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var exec toolExecutor
+	if s.webCrawlStatus != nil {
+		exec = s.webCrawlStatus.Handle
+	}
+
+	return s.executeToolHandler(ctx, req, "web_crawl_status", 0, "web_crawl_status tool is not available", exec)
+}
+
+// handleWebCrawlCancel executes the web_crawl_cancel MCP tool, auditing the invocation via the call logger.
+func (s *Server) handleWebCrawlCancel(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var exec toolExecutor
+	if s.webCrawlCancel != nil {
+		exec = s.webCrawlCancel.Handle
+	}
+
+	return s.executeToolHandler(ctx, req, "web_crawl_cancel", 0, "web_crawl_cancel tool is not available", exec)
+}
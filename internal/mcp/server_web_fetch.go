@@ -0,0 +1,51 @@
+package mcp
+
+import (
+	"context"
+	"time"
+
+	errors "github.com/Laisky/errors/v2"
+
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/imageproc"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/tools"
+	searchlib "github.com/Laisky/laisky-blog-graphql/library/search"
+)
+
+const (
+	// webFetchFallbackTimeout bounds one in-process page fetch.
+	webFetchFallbackTimeout = 15 * time.Second
+	// webFetchFallbackMaxBytes caps the page size accepted by the fallback fetcher.
+	webFetchFallbackMaxBytes = 10 * 1024 * 1024
+)
+
+// newWebPageFetcher returns a plain HTTP page fetcher built on the SSRF-guarded image
+// fetcher, so redirects and DNS answers pointing at private addresses are rejected just
+// like image_url inputs.
+func newWebPageFetcher() searchlib.PlainHTTPFetcher {
+	fetcher := imageproc.NewURLFetcher(imageproc.URLFetchConfig{
+		AllowHTTP:    true,
+		MaxRedirects: 5,
+		TotalTimeout: webFetchFallbackTimeout,
+		MaxBodyBytes: webFetchFallbackMaxBytes,
+		Accept:       "text/html,application/xhtml+xml;q=0.9,*/*;q=0.8",
+		UserAgent:    "Mozilla/5.0 (compatible; laisky-mcp-web-fetch/1.0)",
+	})
+	return func(ctx context.Context, pageURL string) ([]byte, string, error) {
+		result, err := fetcher.Fetch(ctx, pageURL)
+		if errors.Is(err, imageproc.ErrImageTooLarge) {
+			return nil, "", errors.Errorf("page exceeds %d bytes", webFetchFallbackMaxBytes)
+		}
+		if err != nil {
+			return nil, "", errors.WithStack(err)
+		}
+		return result.Body, result.MIMEHint, nil
+	}
+}
+
+// newWebFetchFallback returns the in-process web_fetch renderer used when the crawler is down.
+func newWebFetchFallback() tools.FallbackFetcher {
+	fetch := newWebPageFetcher()
+	return func(ctx context.Context, pageURL string, outputMarkdown bool) ([]byte, error) {
+		return searchlib.RenderPageWithoutBrowser(ctx, fetch, pageURL, outputMarkdown)
+	}
+}
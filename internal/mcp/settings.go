@@ -2,6 +2,8 @@
 package mcp
 
 import (
+	"time"
+
 	gconfig "github.com/Laisky/go-config/v2"
 )
 
@@ -16,6 +18,15 @@ type ToolsSettings struct {
 	MemoryEnabled         bool
 	MCPPipeEnabled        bool
 	FindToolEnabled       bool
+	WebCrawlEnabled       bool
+
+	// WebFetchFallbackEnabled lets web_fetch fetch pages in-process when the crawler does not answer.
+	WebFetchFallbackEnabled bool
+	// WebFetchCrawlerDeadline is how long web_fetch waits for the crawler before using the fallback.
+	WebFetchCrawlerDeadline time.Duration
+
+	// MaxRequestBodyBytes caps the body of one MCP HTTP request. Zero uses defaultMaxRequestBodyBytes.
+	MaxRequestBodyBytes int64
 }
 
 // LoadToolsSettingsFromConfig reads the MCP tools configuration and returns a ToolsSettings instance.
@@ -31,6 +42,12 @@ func LoadToolsSettingsFromConfig() ToolsSettings {
 		MemoryEnabled:         boolFromConfig("settings.mcp.tools.memory.enabled", false),
 		MCPPipeEnabled:        boolFromConfig("settings.mcp.tools.mcp_pipe.enabled", true),
 		FindToolEnabled:       boolFromConfig("settings.mcp.tools.find_tool.enabled", true),
+		WebCrawlEnabled:       boolFromConfig("settings.mcp.tools.web_crawl.enabled", true),
+
+		WebFetchFallbackEnabled: boolFromConfig("settings.mcp.tools.web_fetch.fallback.enabled", true),
+		WebFetchCrawlerDeadline: gconfig.S.GetDuration("settings.mcp.tools.web_fetch.fallback.crawler_deadline"),
+
+		MaxRequestBodyBytes: gconfig.S.GetInt64("settings.mcp.max_request_body_bytes"),
 	}
 }
 
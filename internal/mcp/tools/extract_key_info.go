@@ -19,6 +19,7 @@ import (
 // KeyInfoService defines the subset of rag.Service methods required by the tool.
 type KeyInfoService interface {
 	ExtractKeyInfo(context.Context, rag.ExtractInput) ([]string, error)
+	ValidateEmbeddingProvider(name string) error
 }
 
 // ExtractKeyInfoTool exposes the extract_key_info MCP capability.
@@ -76,6 +77,10 @@ func (t *ExtractKeyInfoTool) Definition() mcp.Tool {
 			"top_k",
 			mcp.Description("Maximum number of contexts to return."),
 		),
+		mcp.WithString(
+			"embedding_provider",
+			mcp.Description("Optional embedding provider name from the server configuration. Defaults to the default provider."),
+		),
 	)
 }
 
@@ -116,6 +121,11 @@ func (t *ExtractKeyInfoTool) Handle(ctx context.Context, req mcp.CallToolRequest
 		return mcp.NewToolResultError(fmt.Sprintf("top_k must be between 1 and %d", t.settings.TopKLimit)), nil
 	}
 
+	provider := strings.TrimSpace(req.GetString("embedding_provider", ""))
+	if err := t.service.ValidateEmbeddingProvider(provider); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
 	header := t.headerProvider(ctx)
 	authCtx, err := mcpauth.FromContextOrHeader(ctx, header)
 	if err != nil {
@@ -129,12 +139,13 @@ func (t *ExtractKeyInfoTool) Handle(ctx context.Context, req mcp.CallToolRequest
 	}
 
 	input := rag.ExtractInput{
-		UserID:    authCtx.UserID,
-		TaskID:    taskID,
-		APIKey:    authCtx.APIKey,
-		Query:     query,
-		Materials: materials,
-		TopK:      topK,
+		UserID:            authCtx.UserID,
+		TaskID:            taskID,
+		APIKey:            authCtx.APIKey,
+		Query:             query,
+		Materials:         materials,
+		TopK:              topK,
+		EmbeddingProvider: provider,
 	}
 
 	contexts, err := t.service.ExtractKeyInfo(ctx, input)
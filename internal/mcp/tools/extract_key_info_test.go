@@ -26,6 +26,13 @@ func (s *stubKeyInfoService) ExtractKeyInfo(ctx context.Context, input rag.Extra
 	return s.contexts, nil
 }
 
+func (s *stubKeyInfoService) ValidateEmbeddingProvider(name string) error {
+	if name != "" && name != "local" {
+		return rag.ErrUnknownEmbeddingProvider
+	}
+	return nil
+}
+
 func TestExtractKeyInfoTool_HandleSuccess(t *testing.T) {
 	svc := &stubKeyInfoService{contexts: []string{"ctx"}}
 	settings := rag.Settings{TopKDefault: 2, TopKLimit: 5, MaxMaterialsSize: 1000, SemanticWeight: 0.5, LexicalWeight: 0.5}
@@ -138,3 +145,32 @@ func TestExtractKeyInfoTool_GeneratesUniqueTaskIDPerCall(t *testing.T) {
 	require.NotEmpty(t, second)
 	require.NotEqual(t, first, second)
 }
+
+func TestExtractKeyInfoTool_EmbeddingProvider(t *testing.T) {
+	svc := &stubKeyInfoService{contexts: []string{"ctx"}}
+	billed := 0
+	tool, err := NewExtractKeyInfoTool(
+		svc,
+		log.Logger.Named("extract_key_info_test"),
+		func(ctx context.Context) string { return "Bearer task@sk-test" },
+		func(context.Context, string, oneapi.Price, string) error { billed++; return nil },
+		rag.Settings{TopKDefault: 2, TopKLimit: 5, MaxMaterialsSize: 1000},
+	)
+	require.NoError(t, err)
+
+	call := func(provider string) *mcp.CallToolResult {
+		req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+			"query": "q", "materials": "text", "embedding_provider": provider,
+		}}}
+		result, err := tool.Handle(context.Background(), req)
+		require.NoError(t, err)
+		return result
+	}
+
+	require.True(t, call("missing").IsError)
+	require.Zero(t, billed)
+
+	require.False(t, call("local").IsError)
+	require.Equal(t, "local", svc.input.EmbeddingProvider)
+	require.Equal(t, 1, billed)
+}
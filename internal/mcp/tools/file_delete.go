@@ -29,6 +29,7 @@ func (t *FileDeleteTool) Definition() mcp.Tool {
 		mcp.WithString("project", mcp.Required(), mcp.Description("Target project namespace.")),
 		mcp.WithString("path", mcp.Description("File or directory path; empty string means project root.")),
 		mcp.WithBoolean("recursive", mcp.Description("Delete descendants when target is a directory.")),
+		mcp.WithString("if_match", mcp.Description("Only delete when the file is still at this revision (from file_stat, file_read or file_list); fails with PRECONDITION_FAILED otherwise. Not allowed for directories.")),
 		fileToolPluginOption(),
 		mcp.WithIdempotentHintAnnotation(false),
 	)
@@ -43,6 +44,9 @@ func (t *FileDeleteTool) Handle(ctx context.Context, req mcp.CallToolRequest) (*
 	path := readStringArg(req, "path")
 	recursive := readBoolArg(req, "recursive")
 	ctx = withFilePluginOverride(ctx, req)
+	if ifMatch := readStringArg(req, "if_match"); ifMatch != "" {
+		ctx = files.WithIfMatch(ctx, ifMatch)
+	}
 	if auth, ok := fileAuthFromContext(ctx); ok {
 		result, svcErr := t.svc.Delete(ctx, auth, project, path, recursive)
 		if svcErr != nil {
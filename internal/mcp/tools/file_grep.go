@@ -0,0 +1,92 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/files"
+)
+
+// FileGrepService scans project files for exact matches, as implemented by files.Service.
+type FileGrepService interface {
+	Grep(ctx context.Context, auth files.AuthContext, project string, req files.GrepRequest) (files.GrepResult, error)
+}
+
+// FileGrepTool implements the file_grep MCP tool.
+type FileGrepTool struct {
+	svc FileGrepService
+}
+
+// NewFileGrepTool constructs a FileGrepTool.
+func NewFileGrepTool(svc FileGrepService) (*FileGrepTool, error) {
+	if svc == nil {
+		return nil, files.NewError(files.ErrCodeSearchBackend, "file service is required", false)
+	}
+	return &FileGrepTool{svc: svc}, nil
+}
+
+// Definition returns the MCP metadata for file_grep.
+func (t *FileGrepTool) Definition() mcp.Tool {
+	return mcp.NewTool(
+		"file_grep",
+		mcp.WithDescription("Find every line matching a regular expression or literal text in project files, like grep -n. "+
+			"Use this for exact matches such as TODO markers or identifiers; use file_search for semantic retrieval. "+
+			"Returns path, line number, byte offsets and optional context lines; binary files are skipped."),
+		mcp.WithString("project", mcp.Required(), mcp.Description("Target project namespace.")),
+		mcp.WithString("pattern", mcp.Required(), mcp.Description("RE2 regular expression matched against each line, or plain text when literal is true.")),
+		mcp.WithString("path_glob", mcp.Description("Optional file filter. \"*\" and \"?\" match within one path segment and \"**\" across segments, "+
+			"e.g. \"/notes/**/*.md\". A pattern without \"/\" such as \"*.md\" matches file names at any depth; a plain path such as \"/notes\" matches everything below it.")),
+		mcp.WithBoolean("literal", mcp.Description("Treat pattern as plain text instead of a regular expression.")),
+		mcp.WithBoolean("ignore_case", mcp.Description("Match case-insensitively.")),
+		mcp.WithNumber("context_lines", mcp.Description("Lines of context to return before and after each match.")),
+		mcp.WithNumber("limit", mcp.Description("Maximum number of matching lines to return.")),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithIdempotentHintAnnotation(true),
+	)
+}
+
+// Handle executes the file_grep tool logic.
+func (t *FileGrepTool) Handle(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	project, err := req.RequireString("project")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	pattern, err := req.RequireString("pattern")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	grepReq := files.GrepRequest{
+		Pattern:      pattern,
+		Literal:      readBoolArg(req, "literal"),
+		IgnoreCase:   readBoolArg(req, "ignore_case"),
+		PathGlob:     readStringArg(req, "path_glob"),
+		ContextLines: readIntArg(req, "context_lines"),
+		Limit:        readIntArg(req, "limit"),
+	}
+	if auth, ok := fileAuthFromContext(ctx); ok {
+		result, svcErr := t.svc.Grep(ctx, auth, project, grepReq)
+		if svcErr != nil {
+			return fileToolErrorFromErr(svcErr), nil //nolint:nilerr // error returned as tool result text
+		}
+		payload := map[string]any{
+			"matches":       result.Matches,
+			"files_scanned": result.FilesScanned,
+			"bytes_scanned": result.BytesScanned,
+			"truncated":     result.Truncated,
+		}
+		if result.Truncated {
+			payload["truncated_reason"] = result.TruncatedReason
+		}
+		if result.FilesSkipped > 0 {
+			payload["files_skipped"] = result.FilesSkipped
+			payload["skipped_paths"] = result.SkippedPaths
+		}
+		toolResult, encodeErr := mcp.NewToolResultJSON(payload)
+		if encodeErr != nil {
+			return fileToolErrorResult(files.ErrCodeSearchBackend, "failed to encode response", true), nil //nolint:nilerr // error returned as tool result text
+		}
+		return toolResult, nil
+	}
+	return fileToolErrorResult(files.ErrCodePermissionDenied, "missing authorization", false), nil
+}
@@ -0,0 +1,64 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/ctxkeys"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/files"
+)
+
+// stubGrepService records the last grep request and returns a canned result.
+type stubGrepService struct {
+	got    files.GrepRequest
+	result files.GrepResult
+}
+
+// Grep records req and returns the configured result.
+func (s *stubGrepService) Grep(_ context.Context, _ files.AuthContext, _ string, req files.GrepRequest) (files.GrepResult, error) {
+	s.got = req
+	return s.result, nil
+}
+
+// TestFileGrepForwardsArguments verifies tool arguments reach the service request.
+func TestFileGrepForwardsArguments(t *testing.T) {
+	svc := &stubGrepService{result: files.GrepResult{
+		Matches:         []files.GrepMatch{{FilePath: "/a.md", LineNumber: 3, Line: "TODO"}},
+		FilesScanned:    1,
+		Truncated:       true,
+		TruncatedReason: files.GrepTruncatedMatchLimit,
+	}}
+	tool, err := NewFileGrepTool(svc)
+	require.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), ctxkeys.AuthContext, &files.AuthContext{APIKeyHash: "hash", APIKey: "key"})
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"project":       "proj",
+		"pattern":       "todo",
+		"path_glob":     "/notes/**",
+		"literal":       true,
+		"ignore_case":   true,
+		"context_lines": float64(2),
+		"limit":         float64(5),
+	}}}
+
+	result, handleErr := tool.Handle(ctx, req)
+	require.NoError(t, handleErr)
+	require.False(t, result.IsError)
+	require.Equal(t, files.GrepRequest{
+		Pattern:      "todo",
+		Literal:      true,
+		IgnoreCase:   true,
+		PathGlob:     "/notes/**",
+		ContextLines: 2,
+		Limit:        5,
+	}, svc.got)
+
+	payload, ok := result.StructuredContent.(map[string]any)
+	require.True(t, ok)
+	require.Equal(t, true, payload["truncated"])
+	require.Equal(t, files.GrepTruncatedMatchLimit, payload["truncated_reason"])
+}
@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/files"
+)
+
+const (
+	indexStatusActionStatus = "status"
+	indexStatusActionRetry  = "retry"
+)
+
+// FileIndexStatusService reports and repairs project search indexes, as implemented by files.Service.
+type FileIndexStatusService interface {
+	IndexStatus(ctx context.Context, auth files.AuthContext, project string) (files.IndexStatus, error)
+	RetryFailedIndexJobs(ctx context.Context, auth files.AuthContext, project string, jobID int64) (int, error)
+}
+
+// FileIndexStatusTool implements the file_index_status MCP tool.
+type FileIndexStatusTool struct {
+	svc FileIndexStatusService
+}
+
+// NewFileIndexStatusTool constructs a FileIndexStatusTool.
+func NewFileIndexStatusTool(svc FileIndexStatusService) (*FileIndexStatusTool, error) {
+	if svc == nil {
+		return nil, files.NewError(files.ErrCodeSearchBackend, "file service is required", false)
+	}
+	return &FileIndexStatusTool{svc: svc}, nil
+}
+
+// Definition returns the MCP metadata for file_index_status.
+func (t *FileIndexStatusTool) Definition() mcp.Tool {
+	return mcp.NewTool(
+		"file_index_status",
+		mcp.WithDescription("Report the search index health of a project: pending, retrying and failed index jobs with their last error, "+
+			"file, chunk and embedding counts, the oldest write not yet searchable compared with the freshness SLO, "+
+			"and the contextualizer and rerank settings in effect. "+
+			"Use it when file_search misses recent writes. The retry action requeues failed jobs."),
+		mcp.WithString("project", mcp.Required(), mcp.Description("Target project namespace.")),
+		mcp.WithString("action",
+			mcp.Description("status (default) or retry."),
+			mcp.Enum(indexStatusActionStatus, indexStatusActionRetry),
+		),
+		mcp.WithNumber("job_id", mcp.Description("Failed job to retry. Omit to retry every failed job of the project.")),
+	)
+}
+
+// Handle executes the file_index_status tool logic.
+func (t *FileIndexStatusTool) Handle(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	project, err := req.RequireString("project")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	auth, ok := fileAuthFromContext(ctx)
+	if !ok {
+		return fileToolErrorResult(files.ErrCodePermissionDenied, "missing authorization", false), nil
+	}
+
+	var payload any
+	switch action := readStringArg(req, "action"); action {
+	case "", indexStatusActionStatus:
+		status, svcErr := t.svc.IndexStatus(ctx, auth, project)
+		if svcErr != nil {
+			return fileToolErrorFromErr(svcErr), nil //nolint:nilerr // error returned as tool result text
+		}
+		payload = status
+	case indexStatusActionRetry:
+		retried, svcErr := t.svc.RetryFailedIndexJobs(ctx, auth, project, readInt64Arg(req, "job_id"))
+		if svcErr != nil {
+			return fileToolErrorFromErr(svcErr), nil //nolint:nilerr // error returned as tool result text
+		}
+		payload = map[string]any{"retried": retried}
+	default:
+		return fileToolErrorResult(files.ErrCodeInvalidArgument, "unknown action "+action, false), nil
+	}
+
+	toolResult, encodeErr := mcp.NewToolResultJSON(payload)
+	if encodeErr != nil {
+		return fileToolErrorResult(files.ErrCodeSearchBackend, "failed to encode response", true), nil //nolint:nilerr // error returned as tool result text
+	}
+	return toolResult, nil
+}
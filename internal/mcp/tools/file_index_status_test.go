@@ -0,0 +1,54 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/ctxkeys"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/files"
+)
+
+// stubIndexStatusService records the job retried.
+type stubIndexStatusService struct {
+	retriedJobID int64
+}
+
+func (s *stubIndexStatusService) IndexStatus(_ context.Context, _ files.AuthContext, project string) (files.IndexStatus, error) {
+	return files.IndexStatus{Project: project, Failed: 1}, nil
+}
+
+func (s *stubIndexStatusService) RetryFailedIndexJobs(_ context.Context, _ files.AuthContext, _ string, jobID int64) (int, error) {
+	s.retriedJobID = jobID
+	if jobID == 404 {
+		return 0, files.NewError(files.ErrCodeNotFound, "failed index job not found", false)
+	}
+	return 1, nil
+}
+
+// TestFileIndexStatusActions verifies status is the default action and retry passes the job id.
+func TestFileIndexStatusActions(t *testing.T) {
+	svc := &stubIndexStatusService{}
+	tool, err := NewFileIndexStatusTool(svc)
+	require.NoError(t, err)
+	ctx := context.WithValue(context.Background(), ctxkeys.AuthContext, &files.AuthContext{APIKeyHash: "hash", APIKey: "key"})
+	call := func(args map[string]any) *mcp.CallToolResult {
+		args["project"] = "proj"
+		result, handleErr := tool.Handle(ctx, mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: args}})
+		require.NoError(t, handleErr)
+		return result
+	}
+
+	result := call(map[string]any{})
+	require.False(t, result.IsError)
+	require.Equal(t, files.IndexStatus{Project: "proj", Failed: 1}, result.StructuredContent)
+
+	result = call(map[string]any{"action": "retry", "job_id": float64(7)})
+	require.False(t, result.IsError)
+	require.Equal(t, int64(7), svc.retriedJobID)
+
+	require.True(t, call(map[string]any{"action": "retry", "job_id": float64(404)}).IsError)
+	require.True(t, call(map[string]any{"action": "rebuild"}).IsError)
+}
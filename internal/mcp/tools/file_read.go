@@ -2,6 +2,7 @@ package tools
 
 import (
 	"context"
+	"encoding/base64"
 
 	"github.com/mark3labs/mcp-go/mcp"
 
@@ -30,6 +31,7 @@ func (t *FileReadTool) Definition() mcp.Tool {
 		mcp.WithString("path", mcp.Required(), mcp.Description("File path to read.")),
 		mcp.WithNumber("offset", mcp.Description("Byte offset to start reading from.")),
 		mcp.WithNumber("length", mcp.Description("Number of bytes to read; -1 reads to EOF.")),
+		mcp.WithString("content_encoding", mcp.Description("Response encoding: utf-8 (default) or base64. Binary files are always returned as base64.")),
 		fileToolPluginOption(),
 		mcp.WithReadOnlyHintAnnotation(true),
 		mcp.WithIdempotentHintAnnotation(true),
@@ -48,15 +50,27 @@ func (t *FileReadTool) Handle(ctx context.Context, req mcp.CallToolRequest) (*mc
 	}
 	offset := readInt64Arg(req, "offset")
 	length := readInt64ArgWithDefault(req, "length", -1)
+	encoding, err := files.NormalizeContentEncoding(readStringArg(req, "content_encoding"))
+	if err != nil {
+		return fileToolErrorFromErr(err), nil //nolint:nilerr // error returned as tool result text
+	}
 	ctx = withFilePluginOverride(ctx, req)
 	if auth, ok := fileAuthFromContext(ctx); ok {
 		result, svcErr := t.svc.Read(ctx, auth, project, path, offset, length)
 		if svcErr != nil {
 			return fileToolErrorFromErr(svcErr), nil //nolint:nilerr // error returned as tool result text
 		}
+		if encoding == files.ContentEncodingBase64 && result.ContentEncoding != files.ContentEncodingBase64 {
+			result.Content = base64.StdEncoding.EncodeToString([]byte(result.Content))
+			result.ContentEncoding = files.ContentEncodingBase64
+		}
 		payload := map[string]any{
 			"content":          result.Content,
 			"content_encoding": result.ContentEncoding,
+			"revision":         result.Revision,
+		}
+		if result.MimeType != "" {
+			payload["mime_type"] = result.MimeType
 		}
 		toolResult, encodeErr := mcp.NewToolResultJSON(payload)
 		if encodeErr != nil {
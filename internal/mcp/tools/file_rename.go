@@ -30,6 +30,7 @@ func (t *FileRenameTool) Definition() mcp.Tool {
 		mcp.WithString("from_path", mcp.Required(), mcp.Description("Source file or directory path.")),
 		mcp.WithString("to_path", mcp.Required(), mcp.Description("Destination file or directory path.")),
 		mcp.WithBoolean("overwrite", mcp.Description("When true, replace an existing destination file for file moves.")),
+		mcp.WithString("if_match", mcp.Description("Only move when the source file is still at this revision (from file_stat, file_read or file_list); fails with PRECONDITION_FAILED otherwise. Not allowed for directories.")),
 		fileToolPluginOption(),
 		mcp.WithIdempotentHintAnnotation(false),
 	)
@@ -51,6 +52,9 @@ func (t *FileRenameTool) Handle(ctx context.Context, req mcp.CallToolRequest) (*
 	}
 	overwrite := readBoolArg(req, "overwrite")
 	ctx = withFilePluginOverride(ctx, req)
+	if ifMatch := readStringArg(req, "if_match"); ifMatch != "" {
+		ctx = files.WithIfMatch(ctx, ifMatch)
+	}
 
 	if auth, ok := fileAuthFromContext(ctx); ok {
 		result, svcErr := t.svc.Rename(ctx, auth, project, fromPath, toPath, overwrite)
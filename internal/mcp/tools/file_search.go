@@ -2,7 +2,12 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
 
+	errors "github.com/Laisky/errors/v2"
 	"github.com/mark3labs/mcp-go/mcp"
 
 	"github.com/Laisky/laisky-blog-graphql/internal/mcp/files"
@@ -30,6 +35,18 @@ func (t *FileSearchTool) Definition() mcp.Tool {
 		mcp.WithString("query", mcp.Required(), mcp.Description("Search query string.")),
 		mcp.WithString("path_prefix", mcp.Description("Optional path prefix filter.")),
 		mcp.WithNumber("limit", mcp.Description("Maximum number of chunks to return.")),
+		mcp.WithObject("filter",
+			mcp.Description("Optional filters applied before ranking. The response also reports per-directory and per-extension counts of the ranked candidates in facets."),
+			mcp.Properties(map[string]any{
+				"updated_after":  map[string]any{"type": "string", "description": "Only files updated at or after this RFC 3339 time."},
+				"updated_before": map[string]any{"type": "string", "description": "Only files updated before this RFC 3339 time."},
+				"updated_within": map[string]any{"type": "string", "description": "Only files updated within this long ago, such as \"7d\" or \"36h\". Cannot be combined with updated_after."},
+				"extensions":     map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Only files with one of these extensions, such as [\"md\", \".go\"]."},
+				"include":        map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Only files matching one of these path globs, with the file_grep path_glob syntax."},
+				"exclude":        map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Skip files matching any of these path globs, such as \"/archive\"."},
+				"min_score":      map[string]any{"type": "number", "description": "Drop chunks scoring below this value, on a 0..1 scale unless a reranker is configured."},
+			}),
+		),
 		fileToolPluginOption(),
 		mcp.WithReadOnlyHintAnnotation(true),
 		mcp.WithIdempotentHintAnnotation(true),
@@ -48,13 +65,17 @@ func (t *FileSearchTool) Handle(ctx context.Context, req mcp.CallToolRequest) (*
 	}
 	pathPrefix := readStringArg(req, "path_prefix")
 	limit := readIntArg(req, "limit")
-	ctx = withFilePluginOverride(ctx, req)
+	filter, err := readSearchFilterArg(req, time.Now())
+	if err != nil {
+		return fileToolErrorResult(files.ErrCodeInvalidArgument, err.Error(), false), nil
+	}
+	ctx = files.WithSearchFilter(withFilePluginOverride(ctx, req), filter)
 	if auth, ok := fileAuthFromContext(ctx); ok {
 		result, svcErr := t.svc.Search(ctx, auth, project, query, pathPrefix, limit)
 		if svcErr != nil {
 			return fileToolErrorFromErr(svcErr), nil //nolint:nilerr // error returned as tool result text
 		}
-		payload := map[string]any{"chunks": result.Chunks}
+		payload := map[string]any{"chunks": result.Chunks, "facets": result.Facets}
 		toolResult, encodeErr := mcp.NewToolResultJSON(payload)
 		if encodeErr != nil {
 			return fileToolErrorResult(files.ErrCodeSearchBackend, "failed to encode response", true), nil //nolint:nilerr // error returned as tool result text
@@ -63,3 +84,91 @@ func (t *FileSearchTool) Handle(ctx context.Context, req mcp.CallToolRequest) (*
 	}
 	return fileToolErrorResult(files.ErrCodePermissionDenied, "missing authorization", false), nil
 }
+
+// searchFilterArgs is the JSON shape of the file_search filter argument.
+type searchFilterArgs struct {
+	UpdatedAfter  string   `json:"updated_after"`
+	UpdatedBefore string   `json:"updated_before"`
+	UpdatedWithin string   `json:"updated_within"`
+	Extensions    []string `json:"extensions"`
+	Include       []string `json:"include"`
+	Exclude       []string `json:"exclude"`
+	MinScore      float64  `json:"min_score"`
+}
+
+// readSearchFilterArg parses the optional filter argument, resolving updated_within
+// against now.
+func readSearchFilterArg(req mcp.CallToolRequest, now time.Time) (files.SearchFilter, error) {
+	raw, ok := req.GetArguments()["filter"]
+	if !ok || raw == nil {
+		return files.SearchFilter{}, nil
+	}
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return files.SearchFilter{}, errors.New("filter must be an object")
+	}
+	var args searchFilterArgs
+	if err := json.Unmarshal(encoded, &args); err != nil {
+		return files.SearchFilter{}, errors.New("filter must be an object")
+	}
+
+	filter := files.SearchFilter{
+		Extensions:   args.Extensions,
+		IncludeGlobs: args.Include,
+		ExcludeGlobs: args.Exclude,
+		MinScore:     args.MinScore,
+	}
+	if filter.UpdatedAfter, err = parseFilterTime("updated_after", args.UpdatedAfter); err != nil {
+		return files.SearchFilter{}, err
+	}
+	if filter.UpdatedBefore, err = parseFilterTime("updated_before", args.UpdatedBefore); err != nil {
+		return files.SearchFilter{}, err
+	}
+	if within := strings.TrimSpace(args.UpdatedWithin); within != "" {
+		if filter.UpdatedAfter != nil {
+			return files.SearchFilter{}, errors.New("updated_within cannot be combined with updated_after")
+		}
+		window, err := parseFilterWindow(within)
+		if err != nil {
+			return files.SearchFilter{}, err
+		}
+		after := now.Add(-window)
+		filter.UpdatedAfter = &after
+	}
+	return filter, nil
+}
+
+// parseFilterTime parses an optional RFC 3339 filter bound.
+func parseFilterTime(name, value string) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, errors.Errorf("%s must be an RFC 3339 time", name)
+	}
+	return &parsed, nil
+}
+
+// parseFilterWindow parses a positive Go duration, also accepting whole days as "7d".
+func parseFilterWindow(value string) (time.Duration, error) {
+	var window time.Duration
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, errors.New("updated_within must be a duration such as \"7d\" or \"36h\"")
+		}
+		window = time.Duration(n) * 24 * time.Hour
+	} else {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, errors.New("updated_within must be a duration such as \"7d\" or \"36h\"")
+		}
+		window = parsed
+	}
+	if window <= 0 {
+		return 0, errors.New("updated_within must be positive")
+	}
+	return window, nil
+}
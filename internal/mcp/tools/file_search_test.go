@@ -0,0 +1,74 @@
+package tools
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/files"
+)
+
+// filterCaptureService records the search filter attached to the request context.
+type filterCaptureService struct {
+	behaviorFileService
+	filter files.SearchFilter
+}
+
+func (s *filterCaptureService) Search(ctx context.Context, _ files.AuthContext, _, _, _ string, _ int) (files.SearchResult, error) {
+	s.filter = files.SearchFilterFromContext(ctx)
+	return files.SearchResult{Facets: files.SearchFacets{Extensions: map[string]int{".md": 3}}}, nil
+}
+
+// TestFileSearchToolFilter verifies the filter argument reaches the service and the
+// facets reach the response.
+func TestFileSearchToolFilter(t *testing.T) {
+	t.Parallel()
+	svc := &filterCaptureService{}
+	tool, err := NewFileSearchTool(svc)
+	require.NoError(t, err)
+
+	result, err := tool.Handle(behaviorAuthCtx(), behaviorReq(map[string]any{
+		"project": "p",
+		"query":   "q",
+		"filter": map[string]any{
+			"updated_before": "2026-02-10T12:00:00Z",
+			"extensions":     []any{"md"},
+			"exclude":        []any{"/archive"},
+			"min_score":      0.5,
+		},
+	}))
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+	require.Equal(t, []string{"md"}, svc.filter.Extensions)
+	require.Equal(t, []string{"/archive"}, svc.filter.ExcludeGlobs)
+	require.Equal(t, 0.5, svc.filter.MinScore)
+	require.Equal(t, time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC), *svc.filter.UpdatedBefore)
+	facets, ok := behaviorJSONContent(t, result)["facets"].(map[string]any)
+	require.True(t, ok)
+	require.Equal(t, map[string]any{".md": float64(3)}, facets["extensions"])
+
+	for _, bad := range []map[string]any{
+		{"updated_after": "yesterday"},
+		{"updated_within": "7d", "updated_after": "2026-02-10T12:00:00Z"},
+		{"updated_within": "-1h"},
+	} {
+		result, err = tool.Handle(behaviorAuthCtx(), behaviorReq(map[string]any{"project": "p", "query": "q", "filter": bad}))
+		require.NoError(t, err)
+		require.True(t, result.IsError)
+	}
+}
+
+// TestReadSearchFilterArgUpdatedWithin verifies relative windows resolve against now.
+func TestReadSearchFilterArgUpdatedWithin(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC)
+	filter, err := readSearchFilterArg(behaviorReq(map[string]any{"filter": map[string]any{"updated_within": "7d"}}), now)
+	require.NoError(t, err)
+	require.Equal(t, now.AddDate(0, 0, -7), *filter.UpdatedAfter)
+
+	filter, err = readSearchFilterArg(behaviorReq(map[string]any{}), now)
+	require.NoError(t, err)
+	require.Equal(t, files.SearchFilter{}, filter)
+}
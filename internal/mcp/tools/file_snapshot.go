@@ -0,0 +1,154 @@
+package tools
+
+import (
+	"context"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/files"
+)
+
+const (
+	snapshotActionCreate  = "create"
+	snapshotActionList    = "list"
+	snapshotActionDiff    = "diff"
+	snapshotActionRestore = "restore"
+	snapshotActionDelete  = "delete"
+)
+
+// FileSnapshotService manages project snapshots, as implemented by files.Service.
+type FileSnapshotService interface {
+	CreateSnapshot(ctx context.Context, auth files.AuthContext, project, name string) (files.FileSnapshot, error)
+	ListSnapshots(ctx context.Context, auth files.AuthContext, project string) ([]files.FileSnapshot, error)
+	DiffSnapshot(ctx context.Context, auth files.AuthContext, project, name, prefix string) ([]files.SnapshotDiffEntry, error)
+	RestoreSnapshot(ctx context.Context, auth files.AuthContext, project, name, prefix string) (files.SnapshotRestoreResult, error)
+	RestoreToTime(ctx context.Context, auth files.AuthContext, project string, at time.Time, prefix string) (files.SnapshotRestoreResult, error)
+	DeleteSnapshot(ctx context.Context, auth files.AuthContext, project, name string) error
+}
+
+// FileSnapshotTool implements the file_snapshot MCP tool.
+type FileSnapshotTool struct {
+	svc FileSnapshotService
+}
+
+// NewFileSnapshotTool constructs a FileSnapshotTool.
+func NewFileSnapshotTool(svc FileSnapshotService) (*FileSnapshotTool, error) {
+	if svc == nil {
+		return nil, files.NewError(files.ErrCodeSearchBackend, "file service is required", false)
+	}
+	return &FileSnapshotTool{svc: svc}, nil
+}
+
+// Definition returns the MCP metadata for file_snapshot.
+func (t *FileSnapshotTool) Definition() mcp.Tool {
+	return mcp.NewTool(
+		"file_snapshot",
+		mcp.WithDescription("Take, inspect and restore whole-project snapshots. "+
+			"Create a named snapshot before risky multi-file edits, diff it to see what changed, and restore it to roll every file back in one step. "+
+			"Restore also accepts an RFC 3339 time within the last 7 days instead of a snapshot name. "+
+			"A restore keeps the overwritten content as file versions."),
+		mcp.WithString("project", mcp.Required(), mcp.Description("Target project namespace.")),
+		mcp.WithString("action", mcp.Required(),
+			mcp.Description("create, list, diff, restore or delete."),
+			mcp.Enum(snapshotActionCreate, snapshotActionList, snapshotActionDiff, snapshotActionRestore, snapshotActionDelete),
+		),
+		mcp.WithString("name", mcp.Description("Snapshot name. Required for create, diff and delete, and for restore unless at is set.")),
+		mcp.WithString("at", mcp.Description("RFC 3339 timestamp to restore to instead of a named snapshot.")),
+		mcp.WithString("prefix", mcp.Description("Optional directory such as \"/notes\" limiting diff and restore to the files below it.")),
+	)
+}
+
+// Handle executes the file_snapshot tool logic.
+func (t *FileSnapshotTool) Handle(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	project, err := req.RequireString("project")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	action, err := req.RequireString("action")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	auth, ok := fileAuthFromContext(ctx)
+	if !ok {
+		return fileToolErrorResult(files.ErrCodePermissionDenied, "missing authorization", false), nil
+	}
+	name := readStringArg(req, "name")
+	prefix := readStringArg(req, "prefix")
+
+	var payload map[string]any
+	switch action {
+	case snapshotActionCreate:
+		snapshot, svcErr := t.svc.CreateSnapshot(ctx, auth, project, name)
+		if svcErr != nil {
+			return fileToolErrorFromErr(svcErr), nil //nolint:nilerr // error returned as tool result text
+		}
+		payload = snapshotToolPayload(snapshot)
+	case snapshotActionList:
+		snapshots, svcErr := t.svc.ListSnapshots(ctx, auth, project)
+		if svcErr != nil {
+			return fileToolErrorFromErr(svcErr), nil //nolint:nilerr // error returned as tool result text
+		}
+		items := make([]map[string]any, 0, len(snapshots))
+		for _, snapshot := range snapshots {
+			items = append(items, snapshotToolPayload(snapshot))
+		}
+		payload = map[string]any{"snapshots": items}
+	case snapshotActionDiff:
+		diff, svcErr := t.svc.DiffSnapshot(ctx, auth, project, name, prefix)
+		if svcErr != nil {
+			return fileToolErrorFromErr(svcErr), nil //nolint:nilerr // error returned as tool result text
+		}
+		payload = map[string]any{"changes": diff}
+	case snapshotActionRestore:
+		var (
+			result files.SnapshotRestoreResult
+			svcErr error
+		)
+		at := readStringArg(req, "at")
+		switch {
+		case (name == "") == (at == ""):
+			return fileToolErrorResult(files.ErrCodeInvalidArgument, "exactly one of name and at is required", false), nil
+		case name != "":
+			result, svcErr = t.svc.RestoreSnapshot(ctx, auth, project, name, prefix)
+		default:
+			parsed, parseErr := time.Parse(time.RFC3339, at)
+			if parseErr != nil {
+				return fileToolErrorResult(files.ErrCodeInvalidArgument, "at must be an RFC 3339 timestamp", false), nil
+			}
+			result, svcErr = t.svc.RestoreToTime(ctx, auth, project, parsed, prefix)
+		}
+		if svcErr != nil {
+			return fileToolErrorFromErr(svcErr), nil //nolint:nilerr // error returned as tool result text
+		}
+		payload = map[string]any{
+			"restored":  result.Restored,
+			"deleted":   result.Deleted,
+			"unchanged": result.Unchanged,
+		}
+	case snapshotActionDelete:
+		if svcErr := t.svc.DeleteSnapshot(ctx, auth, project, name); svcErr != nil {
+			return fileToolErrorFromErr(svcErr), nil //nolint:nilerr // error returned as tool result text
+		}
+		payload = map[string]any{"deleted": true}
+	default:
+		return fileToolErrorResult(files.ErrCodeInvalidArgument, "unknown action "+action, false), nil
+	}
+
+	toolResult, encodeErr := mcp.NewToolResultJSON(payload)
+	if encodeErr != nil {
+		return fileToolErrorResult(files.ErrCodeSearchBackend, "failed to encode response", true), nil //nolint:nilerr // error returned as tool result text
+	}
+	return toolResult, nil
+}
+
+// snapshotToolPayload renders snapshot metadata for tool responses.
+func snapshotToolPayload(snapshot files.FileSnapshot) map[string]any {
+	return map[string]any{
+		"name":       snapshot.Name,
+		"auto":       snapshot.Auto,
+		"file_count": snapshot.FileCount,
+		"total_size": snapshot.TotalSize,
+		"created_at": snapshot.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,73 @@
+package tools
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/ctxkeys"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/files"
+)
+
+// stubSnapshotService records which restore entry point was called.
+type stubSnapshotService struct {
+	restoredName string
+	restoredAt   time.Time
+	prefix       string
+}
+
+func (s *stubSnapshotService) CreateSnapshot(_ context.Context, _ files.AuthContext, _ string, name string) (files.FileSnapshot, error) {
+	return files.FileSnapshot{Name: name}, nil
+}
+
+func (s *stubSnapshotService) ListSnapshots(context.Context, files.AuthContext, string) ([]files.FileSnapshot, error) {
+	return nil, nil
+}
+
+func (s *stubSnapshotService) DiffSnapshot(context.Context, files.AuthContext, string, string, string) ([]files.SnapshotDiffEntry, error) {
+	return nil, nil
+}
+
+func (s *stubSnapshotService) RestoreSnapshot(_ context.Context, _ files.AuthContext, _ string, name, prefix string) (files.SnapshotRestoreResult, error) {
+	s.restoredName, s.prefix = name, prefix
+	return files.SnapshotRestoreResult{Restored: []string{"/a.md"}}, nil
+}
+
+func (s *stubSnapshotService) RestoreToTime(_ context.Context, _ files.AuthContext, _ string, at time.Time, prefix string) (files.SnapshotRestoreResult, error) {
+	s.restoredAt, s.prefix = at, prefix
+	return files.SnapshotRestoreResult{}, nil
+}
+
+func (s *stubSnapshotService) DeleteSnapshot(context.Context, files.AuthContext, string, string) error {
+	return nil
+}
+
+// TestFileSnapshotRestoreTargets verifies restore dispatches on name or at, never both.
+func TestFileSnapshotRestoreTargets(t *testing.T) {
+	svc := &stubSnapshotService{}
+	tool, err := NewFileSnapshotTool(svc)
+	require.NoError(t, err)
+	ctx := context.WithValue(context.Background(), ctxkeys.AuthContext, &files.AuthContext{APIKeyHash: "hash", APIKey: "key"})
+	call := func(args map[string]any) *mcp.CallToolResult {
+		args["project"] = "proj"
+		args["action"] = "restore"
+		result, handleErr := tool.Handle(ctx, mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: args}})
+		require.NoError(t, handleErr)
+		return result
+	}
+
+	result := call(map[string]any{"name": "base", "prefix": "/notes"})
+	require.False(t, result.IsError)
+	require.Equal(t, "base", svc.restoredName)
+	require.Equal(t, "/notes", svc.prefix)
+
+	result = call(map[string]any{"at": "2026-02-10T12:00:00Z"})
+	require.False(t, result.IsError)
+	require.Equal(t, time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC), svc.restoredAt)
+
+	require.True(t, call(map[string]any{"name": "base", "at": "2026-02-10T12:00:00Z"}).IsError)
+	require.True(t, call(map[string]any{"at": "yesterday"}).IsError)
+}
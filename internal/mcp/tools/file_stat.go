@@ -25,7 +25,7 @@ func NewFileStatTool(svc FileService) (*FileStatTool, error) {
 func (t *FileStatTool) Definition() mcp.Tool {
 	return mcp.NewTool(
 		"file_stat",
-		mcp.WithDescription("Return metadata (size, timestamps, permissions) for a file or directory path. Use this to inspect file properties without reading content."),
+		mcp.WithDescription("Return metadata (size, timestamps, revision) for a file or directory path. Use this to inspect file properties without reading content."),
 		mcp.WithString("project", mcp.Required(), mcp.Description("Target project namespace.")),
 		mcp.WithString("path", mcp.Description("File path; empty string means project root.")),
 		fileToolPluginOption(),
@@ -51,6 +51,8 @@ func (t *FileStatTool) Handle(ctx context.Context, req mcp.CallToolRequest) (*mc
 			"exists":     result.Exists,
 			"type":       result.Type,
 			"size":       result.Size,
+			"mime_type":  result.MimeType,
+			"revision":   result.Revision,
 			"created_at": result.CreatedAt,
 			"updated_at": result.UpdatedAt,
 		}
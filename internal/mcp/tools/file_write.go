@@ -7,6 +7,7 @@ import (
 	"github.com/mark3labs/mcp-go/mcp"
 
 	"github.com/Laisky/laisky-blog-graphql/internal/mcp/files"
+	mcpplugin "github.com/Laisky/laisky-blog-graphql/internal/mcp/memory/plugin"
 )
 
 // FileWriteTool implements the file_write MCP tool.
@@ -29,10 +30,12 @@ func (t *FileWriteTool) Definition() mcp.Tool {
 		mcp.WithDescription("Write, create, or append file content. Use this to save, update, or modify files on disk."),
 		mcp.WithString("project", mcp.Required(), mcp.Description("Target project namespace.")),
 		mcp.WithString("path", mcp.Required(), mcp.Description("File path to write.")),
-		mcp.WithString("content", mcp.Required(), mcp.Description("UTF-8 encoded content.")),
-		mcp.WithString("content_encoding", mcp.Description("Content encoding; must be utf-8.")),
+		mcp.WithString("content", mcp.Required(), mcp.Description("File content, encoded per content_encoding. In PATCH mode, a unified diff or a JSON array of {\"search\", \"replace\", \"replace_all\"} hunks.")),
+		mcp.WithString("content_encoding", mcp.Description("Content encoding: utf-8 (default) for text, or base64 for binary files such as images and PDFs.")),
+		mcp.WithString("mime_type", mcp.Description("Optional media type to record, e.g. application/pdf; detected from the path and content when omitted.")),
 		mcp.WithNumber("offset", mcp.Description("Byte offset for overwrite mode.")),
-		mcp.WithString("mode", mcp.Description("Write mode: APPEND, OVERWRITE, or TRUNCATE.")),
+		mcp.WithString("mode", mcp.Description("Write mode: APPEND, OVERWRITE, TRUNCATE, or PATCH. PATCH applies all hunks atomically and fails with CONFLICT when the file no longer matches.")),
+		mcp.WithString("if_match", mcp.Description("Only write when the file is still at this revision (from file_stat, file_read or file_list). Use \"0\" to create only if the path is free, or \"*\" to require an existing file. Fails with PRECONDITION_FAILED otherwise.")),
 		fileToolPluginOption(),
 		mcp.WithIdempotentHintAnnotation(false),
 	)
@@ -57,12 +60,13 @@ func (t *FileWriteTool) Handle(ctx context.Context, req mcp.CallToolRequest) (*m
 	mode := files.WriteMode(modeRaw)
 	offset := readInt64Arg(req, "offset")
 	ctx = withFilePluginOverride(ctx, req)
+	opts := files.WriteOpts{MimeType: readStringArg(req, "mime_type"), IfMatch: readStringArg(req, "if_match")}
 	if auth, ok := fileAuthFromContext(ctx); ok {
-		result, svcErr := t.svc.Write(ctx, auth, project, path, content, encoding, offset, mode)
+		result, svcErr := mcpplugin.WriteWithOpts(ctx, t.svc, auth, project, path, content, encoding, offset, mode, opts)
 		if svcErr != nil {
 			return fileToolErrorFromErr(svcErr), nil //nolint:nilerr // error returned as tool result text
 		}
-		payload := map[string]any{"bytes_written": result.BytesWritten}
+		payload := map[string]any{"bytes_written": result.BytesWritten, "revision": result.Revision}
 		toolResult, encodeErr := mcp.NewToolResultJSON(payload)
 		if encodeErr != nil {
 			return fileToolErrorResult(files.ErrCodeSearchBackend, "failed to encode response", true), nil //nolint:nilerr // error returned as tool result text
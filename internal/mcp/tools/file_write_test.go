@@ -115,3 +115,33 @@ func TestFileWriteErrorMapping(t *testing.T) {
 	require.NoError(t, json.Unmarshal([]byte(textContent.Text), &payload))
 	require.Equal(t, string(files.ErrCodeInvalidPath), payload["code"])
 }
+
+// optsFileService records the WriteOpts of the last write.
+type optsFileService struct {
+	stubFileService
+	opts files.WriteOpts
+}
+
+// WriteWith records opts for tests.
+func (s *optsFileService) WriteWith(_ context.Context, _ files.AuthContext, _, _, _, _ string, _ int64, _ files.WriteMode, opts files.WriteOpts) (files.WriteResult, error) {
+	s.opts = opts
+	return files.WriteResult{}, nil
+}
+
+// TestFileWriteMimeTypeUsesWriteOpts verifies that mime_type reaches the plugin as WriteOpts.
+func TestFileWriteMimeTypeUsesWriteOpts(t *testing.T) {
+	svc := &optsFileService{}
+	tool, err := NewFileWriteTool(svc)
+	require.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), ctxkeys.AuthContext, &files.AuthContext{APIKeyHash: "hash"})
+	result, err := tool.Handle(ctx, mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"project":   "proj",
+		"path":      "/a.bin",
+		"content":   "data",
+		"mime_type": "image/png",
+	}}})
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+	require.Equal(t, "image/png", svc.opts.MimeType)
+}
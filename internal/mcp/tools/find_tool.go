@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -21,7 +22,11 @@ import (
 	"github.com/Laisky/laisky-blog-graphql/library/billing/oneapi"
 )
 
-const findToolDefaultTopK = 5
+const (
+	findToolDefaultTopK = 5
+	// findToolMaxExtraDocs bounds the cached embeddings of caller-specific tools.
+	findToolMaxExtraDocs = 4096
+)
 
 // Search mode constants.
 const (
@@ -34,6 +39,9 @@ const (
 	FindToolModeBM25 = "bm25"
 	// FindToolModeEmbedding ranks tools using hybrid semantic + lexical scoring (requires embedding API).
 	FindToolModeEmbedding = "embedding"
+	// FindToolModeHybrid fuses the BM25 and embedding rankings with reciprocal rank fusion
+	// and optionally boosts tools by their call success rates (requires embedding API).
+	FindToolModeHybrid = "hybrid"
 )
 
 // toolDoc holds the pre-computed text, tokens, and embedding for one tool definition.
@@ -44,6 +52,26 @@ type toolDoc struct {
 	Embedding pgvector.Vector
 }
 
+// FindToolExtraTool is a caller-specific tool definition, such as a saved pipeline.
+type FindToolExtraTool struct {
+	Tool mcp.Tool
+	// Key identifies the definition and Version changes whenever it is edited. The
+	// embedding of the definition is cached under Key until Version changes; an empty
+	// Key disables caching.
+	Key     string
+	Version string
+}
+
+// FindToolExtraToolsProvider returns caller-specific tool definitions that find_tool
+// searches alongside the registered tools.
+type FindToolExtraToolsProvider func(ctx context.Context) []FindToolExtraTool
+
+// extraToolDoc is the cached index entry of one caller-specific tool.
+type extraToolDoc struct {
+	version string
+	doc     toolDoc
+}
+
 // FindToolTool exposes the find_tool MCP capability for progressive tool discovery.
 type FindToolTool struct {
 	embedder       rag.Embedder
@@ -52,9 +80,12 @@ type FindToolTool struct {
 	headerProvider AuthorizationHeaderProvider
 	billingChecker BillingChecker
 
-	mu       sync.Mutex
-	tools    []mcp.Tool // registered tool definitions (set once via SetTools)
-	toolDocs []toolDoc  // lazily built on first embedding query
+	mu         sync.Mutex
+	tools      []mcp.Tool // registered tool definitions (set once via SetTools)
+	toolDocs   []toolDoc  // lazily built on first embedding query
+	extraTools FindToolExtraToolsProvider
+	extraDocs  map[string]extraToolDoc // embeddings of extra tools by FindToolExtraTool.Key
+	usage      FindToolUsageProvider
 }
 
 // NewFindToolTool wires the dependencies for the find_tool handler.
@@ -99,6 +130,22 @@ func (t *FindToolTool) SetTools(tools []mcp.Tool) {
 	t.toolDocs = nil // reset cache so next query re-indexes
 }
 
+// SetExtraToolsProvider registers a provider of per-caller tools that are searched
+// together with the tools passed to SetTools. Must be called before the first Handle invocation.
+func (t *FindToolTool) SetExtraToolsProvider(provider FindToolExtraToolsProvider) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.extraTools = provider
+}
+
+// ForgetExtraTool drops the cached embedding of the extra tool with key, for example
+// after the saved pipeline it describes was updated or deleted.
+func (t *FindToolTool) ForgetExtraTool(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.extraDocs, key)
+}
+
 // Definition returns the MCP metadata for the find_tool contract.
 func (t *FindToolTool) Definition() mcp.Tool {
 	return mcp.NewTool(
@@ -108,8 +155,10 @@ func (t *FindToolTool) Definition() mcp.Tool {
 				"regex (pattern matching on tool names/descriptions), "+
 				"bm25 (keyword relevance ranking), "+
 				"embedding (semantic similarity via embeddings), "+
+				"hybrid (bm25 and embedding rankings fused, optionally boosted by past call success), "+
 				"or auto (automatically selects the best mode). "+
-				"Returns matching tool schemas or tool_reference names for deferred loading. "+
+				"Returns matching tool schemas or tool_reference names for deferred loading, "+
+				"each with an explanation of why it ranked where it did. "+
 				"Use this to discover available tools when you don't know the exact tool name.",
 		),
 		mcp.WithString(
@@ -117,7 +166,7 @@ func (t *FindToolTool) Definition() mcp.Tool {
 			mcp.Required(),
 			mcp.Description(
 				"Search query. For regex mode: a Python-style regex pattern (e.g. 'file_.*', '(?i)web'). "+
-					"For bm25/embedding/auto modes: a natural-language description of the capability you need.",
+					"For bm25/embedding/hybrid/auto modes: a natural-language description of the capability you need.",
 			),
 		),
 		mcp.WithString(
@@ -125,7 +174,15 @@ func (t *FindToolTool) Definition() mcp.Tool {
 			mcp.Description(
 				"Search mode: 'auto' (default, selects regex or bm25 based on query pattern), "+
 					"'regex' (pattern match), 'bm25' (keyword ranking), "+
-					"'embedding' (semantic + lexical hybrid, requires embedding API call).",
+					"'embedding' (semantic + lexical hybrid, requires embedding API call), "+
+					"'hybrid' (reciprocal rank fusion of bm25 and embedding rankings, requires embedding API call).",
+			),
+		),
+		mcp.WithBoolean(
+			"usage_boost",
+			mcp.Description(
+				"Hybrid mode only. When true, tools whose recent calls mostly succeeded, overall and for your API key, "+
+					"rank slightly higher, and tools that mostly failed rank slightly lower. Default: false.",
 			),
 		),
 		mcp.WithBoolean(
@@ -154,14 +211,15 @@ func (t *FindToolTool) Handle(ctx context.Context, req mcp.CallToolRequest) (*mc
 	if modeStr, ok := optionalString(req, "mode"); ok && modeStr != "" {
 		modeStr = strings.ToLower(strings.TrimSpace(modeStr))
 		switch modeStr {
-		case FindToolModeRegex, FindToolModeBM25, FindToolModeEmbedding, FindToolModeAuto:
+		case FindToolModeRegex, FindToolModeBM25, FindToolModeEmbedding, FindToolModeHybrid, FindToolModeAuto:
 			mode = modeStr
 		default:
-			return mcp.NewToolResultError(fmt.Sprintf("unsupported mode %q; use auto, regex, bm25, or embedding", modeStr)), nil
+			return mcp.NewToolResultError(fmt.Sprintf("unsupported mode %q; use auto, regex, bm25, embedding, or hybrid", modeStr)), nil
 		}
 	}
 
 	refsOnly := optionalBool(req, "return_references_only")
+	usageBoost := optionalBool(req, "usage_boost")
 
 	header := t.headerProvider(ctx)
 	authCtx, err := mcpauth.FromContextOrHeader(ctx, header)
@@ -186,16 +244,25 @@ func (t *FindToolTool) Handle(ctx context.Context, req mcp.CallToolRequest) (*mc
 		zap.Bool("refs_only", refsOnly),
 	)
 
-	var rankedNames []string
+	extras := t.loadExtraTools(ctx)
+	tools := t.searchableTools(extras)
+
+	var matches []findToolMatch
 	switch mode {
 	case FindToolModeRegex:
-		rankedNames, err = t.searchRegex(query)
+		matches, err = t.searchRegex(query, tools)
 	case FindToolModeBM25:
-		rankedNames = t.searchBM25(query)
+		matches = t.searchBM25(query, tools)
 	case FindToolModeEmbedding:
-		rankedNames, err = t.searchEmbedding(ctx, authCtx.APIKey, query)
+		matches, err = t.searchEmbedding(ctx, authCtx.APIKey, query, extras)
+	case FindToolModeHybrid:
+		var usage *FindToolUsage
+		if usageBoost {
+			usage = t.loadUsage(ctx, authCtx.APIKey)
+		}
+		matches, err = t.searchHybrid(ctx, authCtx.APIKey, query, tools, extras, usage)
 	default:
-		rankedNames = t.searchBM25(query)
+		matches = t.searchBM25(query, tools)
 	}
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
@@ -203,16 +270,43 @@ func (t *FindToolTool) Handle(ctx context.Context, req mcp.CallToolRequest) (*mc
 
 	// Clamp to top-K.
 	topK := findToolDefaultTopK
-	if topK > len(rankedNames) {
-		topK = len(rankedNames)
+	if topK > len(matches) {
+		topK = len(matches)
 	}
-	rankedNames = rankedNames[:topK]
+	matches = matches[:topK]
 
-	return t.buildResponse(rankedNames, refsOnly)
+	return t.buildResponse(mode, matches, refsOnly, tools)
+}
+
+// loadExtraTools returns the caller-specific tools from the registered provider, if any.
+func (t *FindToolTool) loadExtraTools(ctx context.Context) []FindToolExtraTool {
+	t.mu.Lock()
+	provider := t.extraTools
+	t.mu.Unlock()
+	if provider == nil {
+		return nil
+	}
+	return provider(ctx)
+}
+
+// searchableTools returns the registered tools followed by extras.
+func (t *FindToolTool) searchableTools(extras []FindToolExtraTool) []mcp.Tool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(extras) == 0 {
+		return t.tools
+	}
+
+	tools := make([]mcp.Tool, 0, len(t.tools)+len(extras))
+	tools = append(tools, t.tools...)
+	for _, extra := range extras {
+		tools = append(tools, extra.Tool)
+	}
+	return tools
 }
 
 // searchRegex matches tools using a compiled regex pattern against their searchable text.
-func (t *FindToolTool) searchRegex(pattern string) ([]string, error) {
+func (t *FindToolTool) searchRegex(pattern string, tools []mcp.Tool) ([]findToolMatch, error) {
 	if len(pattern) > 200 {
 		return nil, errors.New("regex pattern exceeds 200 character limit")
 	}
@@ -222,15 +316,11 @@ func (t *FindToolTool) searchRegex(pattern string) ([]string, error) {
 		return nil, errors.Errorf("invalid regex pattern: %v", err)
 	}
 
-	t.mu.Lock()
-	tools := t.tools
-	t.mu.Unlock()
-
-	var matched []string
+	var matched []findToolMatch
 	for _, tool := range tools {
 		doc := buildToolDocument(tool)
-		if re.MatchString(doc) {
-			matched = append(matched, tool.Name)
+		if loc := re.FindStringIndex(doc); loc != nil {
+			matched = append(matched, findToolMatch{Name: tool.Name, Score: 1, RegexMatch: doc[loc[0]:loc[1]]})
 		}
 	}
 
@@ -238,16 +328,12 @@ func (t *FindToolTool) searchRegex(pattern string) ([]string, error) {
 }
 
 // searchBM25 ranks tools using BM25 term-frequency scoring (no embedding API needed).
-func (t *FindToolTool) searchBM25(query string) []string {
+func (t *FindToolTool) searchBM25(query string, tools []mcp.Tool) []findToolMatch {
 	queryTokens := rag.Tokenize(query)
 	if len(queryTokens) == 0 {
 		return nil
 	}
 
-	t.mu.Lock()
-	tools := t.tools
-	t.mu.Unlock()
-
 	// Build per-tool token lists and compute corpus-level stats.
 	type docInfo struct {
 		name   string
@@ -286,12 +372,7 @@ func (t *FindToolTool) searchBM25(query string) []string {
 	const b = 0.75
 	n := float64(len(docs))
 
-	type scored struct {
-		name  string
-		score float64
-	}
-
-	scoredDocs := make([]scored, 0, len(docs))
+	scoredDocs := make([]findToolMatch, 0, len(docs))
 	for _, doc := range docs {
 		// Count term frequencies in this document.
 		tf := make(map[string]int, len(queryTokens))
@@ -304,6 +385,7 @@ func (t *FindToolTool) searchBM25(query string) []string {
 		}
 
 		var score float64
+		var terms []string
 		dl := float64(len(doc.tokens))
 		for _, qTok := range queryTokens {
 			tfVal := float64(tf[qTok])
@@ -311,6 +393,9 @@ func (t *FindToolTool) searchBM25(query string) []string {
 			if dfVal == 0 {
 				continue
 			}
+			if tfVal > 0 && !slices.Contains(terms, qTok) {
+				terms = append(terms, qTok)
+			}
 
 			// IDF: log((N - df + 0.5) / (df + 0.5) + 1)
 			idf := math.Log((n-dfVal+0.5)/(dfVal+0.5) + 1)
@@ -319,104 +404,170 @@ func (t *FindToolTool) searchBM25(query string) []string {
 			score += idf * tfNorm
 		}
 
-		scoredDocs = append(scoredDocs, scored{name: doc.name, score: score})
+		scoredDocs = append(scoredDocs, findToolMatch{
+			Name:         doc.name,
+			Score:        score,
+			BM25:         &findToolSignal{Score: score},
+			MatchedTerms: terms,
+		})
 	}
 
-	sort.Slice(scoredDocs, func(i, j int) bool {
-		return scoredDocs[i].score > scoredDocs[j].score
+	sort.SliceStable(scoredDocs, func(i, j int) bool {
+		return scoredDocs[i].Score > scoredDocs[j].Score
 	})
 
 	// Filter out zero-score results.
-	result := make([]string, 0, len(scoredDocs))
+	result := make([]findToolMatch, 0, len(scoredDocs))
 	for _, s := range scoredDocs {
-		if s.score <= 0 {
+		if s.Score <= 0 {
 			break
 		}
-		result = append(result, s.name)
+		s.BM25.Rank = len(result) + 1
+		result = append(result, s)
 	}
 
 	return result
 }
 
-// searchEmbedding uses the original hybrid semantic + lexical scoring approach.
-func (t *FindToolTool) searchEmbedding(ctx context.Context, apiKey, query string) ([]string, error) {
+// embeddingScore holds the similarity of one tool to the query.
+type embeddingScore struct {
+	name     string
+	semantic float64
+	lexical  float64
+}
+
+// scoreEmbedding computes semantic and lexical similarity between the query and every tool.
+// Registered tools are indexed once; extras are embedded together with the query the
+// first time they are seen and then cached until their version changes.
+func (t *FindToolTool) scoreEmbedding(ctx context.Context, apiKey, query string, extras []FindToolExtraTool) ([]embeddingScore, error) {
 	// Ensure tool docs are indexed (lazy, one-time).
 	if err := t.ensureIndex(ctx, apiKey); err != nil {
 		t.logger.Error("find_tool index failed", zap.Error(err))
 		return nil, errors.New("failed to initialize tool index")
 	}
 
-	// Embed the query.
-	queryVecs, err := t.embedder.EmbedTexts(ctx, apiKey, []string{query})
+	extraDocs := make([]toolDoc, len(extras))
+	var missing []int
+	t.mu.Lock()
+	for i, extra := range extras {
+		if cached, ok := t.extraDocs[extra.Key]; ok && extra.Key != "" && cached.version == extra.Version {
+			extraDocs[i] = cached.doc
+			continue
+		}
+		missing = append(missing, i)
+	}
+	t.mu.Unlock()
+
+	// Embed the query and any uncached caller-specific tools in one request.
+	texts := make([]string, 0, 1+len(missing))
+	texts = append(texts, query)
+	for _, i := range missing {
+		texts = append(texts, buildToolDocument(extras[i].Tool))
+	}
+	vecs, err := t.embedder.EmbedTexts(ctx, apiKey, texts)
 	if err != nil {
 		t.logger.Error("find_tool embed query failed", zap.Error(err))
 		return nil, errors.New("failed to embed query")
 	}
-	if len(queryVecs) == 0 {
+	if len(vecs) != len(texts) {
 		return nil, errors.New("embedding provider returned no query vector")
 	}
-	queryVec := queryVecs[0]
+	queryVec := vecs[0]
+
+	t.mu.Lock()
+	if t.extraDocs == nil || len(t.extraDocs)+len(missing) > findToolMaxExtraDocs {
+		t.extraDocs = make(map[string]extraToolDoc, len(missing))
+	}
+	for j, i := range missing {
+		extraDocs[i] = toolDoc{
+			Name:      extras[i].Tool.Name,
+			Text:      texts[j+1],
+			Tokens:    rag.Tokenize(texts[j+1]),
+			Embedding: vecs[j+1],
+		}
+		if extras[i].Key != "" {
+			t.extraDocs[extras[i].Key] = extraToolDoc{version: extras[i].Version, doc: extraDocs[i]}
+		}
+	}
+	t.mu.Unlock()
 
-	// Hybrid rank.
 	queryTokens := rag.Tokenize(query)
 	tokenSet := make(map[string]struct{}, len(queryTokens))
 	for _, tok := range queryTokens {
 		tokenSet[tok] = struct{}{}
 	}
 
-	type scored struct {
-		name  string
-		score float64
-	}
-
 	t.mu.Lock()
 	docs := t.toolDocs
 	t.mu.Unlock()
+	// Clip so appending extras never writes into the shared index.
+	docs = append(slices.Clip(docs), extraDocs...)
 
-	scoredTools := make([]scored, 0, len(docs))
+	scores := make([]embeddingScore, 0, len(docs))
 	for _, doc := range docs {
-		semantic := rag.CosineSimilarity(queryVec, doc.Embedding)
-		lexical := rag.LexicalScore(doc.Tokens, tokenSet)
-		score := t.settings.SemanticWeight*semantic + t.settings.LexicalWeight*lexical
-		scoredTools = append(scoredTools, scored{name: doc.Name, score: score})
+		scores = append(scores, embeddingScore{
+			name:     doc.Name,
+			semantic: rag.CosineSimilarity(queryVec, doc.Embedding),
+			lexical:  rag.LexicalScore(doc.Tokens, tokenSet),
+		})
 	}
+	return scores, nil
+}
 
-	sort.Slice(scoredTools, func(i, j int) bool {
-		return scoredTools[i].score > scoredTools[j].score
-	})
+// searchEmbedding uses the original hybrid semantic + lexical scoring approach.
+func (t *FindToolTool) searchEmbedding(ctx context.Context, apiKey, query string, extras []FindToolExtraTool) ([]findToolMatch, error) {
+	scores, err := t.scoreEmbedding(ctx, apiKey, query, extras)
+	if err != nil {
+		return nil, err
+	}
 
-	result := make([]string, 0, len(scoredTools))
-	for _, s := range scoredTools {
-		result = append(result, s.name)
+	result := make([]findToolMatch, 0, len(scores))
+	for _, s := range scores {
+		lexical := s.lexical
+		result = append(result, findToolMatch{
+			Name:      s.name,
+			Score:     t.settings.SemanticWeight*s.semantic + t.settings.LexicalWeight*s.lexical,
+			Embedding: &findToolSignal{Score: s.semantic},
+			Lexical:   &lexical,
+		})
 	}
 
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Score > result[j].Score
+	})
+
 	return result, nil
 }
 
-// buildResponse constructs the MCP tool result from ranked tool names.
-func (t *FindToolTool) buildResponse(rankedNames []string, refsOnly bool) (*mcp.CallToolResult, error) {
+// buildResponse constructs the MCP tool result from ranked matches.
+func (t *FindToolTool) buildResponse(mode string, matches []findToolMatch, refsOnly bool, tools []mcp.Tool) (*mcp.CallToolResult, error) {
 	// Build name→definition map for quick lookup.
-	toolMap := make(map[string]mcp.Tool, len(t.tools))
-	t.mu.Lock()
-	for _, tool := range t.tools {
+	toolMap := make(map[string]mcp.Tool, len(tools))
+	for _, tool := range tools {
 		toolMap[tool.Name] = tool
 	}
-	t.mu.Unlock()
 
 	if refsOnly {
 		// Return tool_reference format compatible with Anthropic API.
-		refs := make([]map[string]any, 0, len(rankedNames))
-		for _, name := range rankedNames {
-			if _, ok := toolMap[name]; !ok {
+		// Explanations go in a separate list so the references stay spec-compliant.
+		refs := make([]map[string]any, 0, len(matches))
+		rankings := make([]map[string]any, 0, len(matches))
+		for _, match := range matches {
+			if _, ok := toolMap[match.Name]; !ok {
 				continue
 			}
 			refs = append(refs, map[string]any{
 				"type":      "tool_reference",
-				"tool_name": name,
+				"tool_name": match.Name,
 			})
+			ranking := match.explain(len(rankings) + 1)
+			ranking["tool_name"] = match.Name
+			rankings = append(rankings, ranking)
 		}
 		payload := map[string]any{
+			"mode":            mode,
 			"tool_references": refs,
+			"rankings":        rankings,
 		}
 		result, err := mcp.NewToolResultJSON(payload)
 		if err != nil {
@@ -427,9 +578,9 @@ func (t *FindToolTool) buildResponse(rankedNames []string, refsOnly bool) (*mcp.
 	}
 
 	// Return full tool schemas (original format).
-	results := make([]map[string]any, 0, len(rankedNames))
-	for _, name := range rankedNames {
-		def, ok := toolMap[name]
+	results := make([]map[string]any, 0, len(matches))
+	for _, match := range matches {
+		def, ok := toolMap[match.Name]
 		if !ok {
 			continue
 		}
@@ -437,10 +588,12 @@ func (t *FindToolTool) buildResponse(rankedNames []string, refsOnly bool) (*mcp.
 			"name":        def.Name,
 			"description": def.Description,
 			"inputSchema": def.InputSchema,
+			"ranking":     match.explain(len(results) + 1),
 		})
 	}
 
 	payload := map[string]any{
+		"mode":  mode,
 		"tools": results,
 	}
 	result, err := mcp.NewToolResultJSON(payload)
@@ -531,8 +684,9 @@ func buildToolDocument(tool mcp.Tool) string {
 	return sb.String()
 }
 
-// detectSearchMode examines a query string to determine the appropriate search mode.
-// Returns regex mode if the query contains regex metacharacters, otherwise bm25.
+// detectSearchMode picks the search mode of auto queries: regex when the query contains
+// regex metacharacters or looks like a tool name, otherwise bm25. Auto never selects the
+// embedding or hybrid modes, which bill an embedding call to the caller.
 func detectSearchMode(query string) string {
 	// Common regex metacharacters that wouldn't appear in natural language.
 	regexIndicators := []string{".*", ".+", "\\w", "\\d", "\\s", "\\b", "[", "]", "(?", "|", "^", "$", "{", "}"}
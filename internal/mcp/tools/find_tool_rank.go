@@ -0,0 +1,233 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/Laisky/zap"
+	mcp "github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// findToolRRFK damps the contribution of lower ranks in reciprocal rank fusion.
+	// 60 is the constant from the original RRF paper and works well without tuning.
+	findToolRRFK = 60
+	// findToolGlobalUsageWeight and findToolKeyUsageWeight cap how much the success
+	// rates of all callers and of the current API key can move a hybrid score.
+	findToolGlobalUsageWeight = 0.1
+	findToolKeyUsageWeight    = 0.2
+	// findToolUsagePrior is the call count at which usage counts for half its weight.
+	findToolUsagePrior = 10
+)
+
+// ToolUsageStats counts the recorded calls of one tool.
+type ToolUsageStats struct {
+	Calls     int64
+	Successes int64
+}
+
+// FindToolUsage holds per-tool call stats used to boost hybrid find_tool rankings.
+type FindToolUsage struct {
+	// Global aggregates the calls of all API keys, keyed by tool name.
+	Global map[string]ToolUsageStats
+	// Key aggregates the calls of the requesting API key, keyed by tool name.
+	Key map[string]ToolUsageStats
+}
+
+// FindToolUsageProvider returns the tool usage stats for the given API key.
+type FindToolUsageProvider func(ctx context.Context, apiKey string) (*FindToolUsage, error)
+
+// SetUsageProvider registers the source of call stats for the hybrid mode usage boost.
+// Must be called before the first Handle invocation.
+func (t *FindToolTool) SetUsageProvider(provider FindToolUsageProvider) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.usage = provider
+}
+
+// loadUsage returns the usage stats for apiKey, or nil when no provider is set or it fails.
+// Usage only refines the ranking, so a failing provider must not fail the search.
+func (t *FindToolTool) loadUsage(ctx context.Context, apiKey string) *FindToolUsage {
+	t.mu.Lock()
+	provider := t.usage
+	t.mu.Unlock()
+	if provider == nil {
+		return nil
+	}
+
+	usage, err := provider(ctx, apiKey)
+	if err != nil {
+		t.logger.Warn("find_tool load usage stats", zap.Error(err))
+		return nil
+	}
+	return usage
+}
+
+// findToolSignal is the position and raw score of a tool in one ranking.
+type findToolSignal struct {
+	Rank  int
+	Score float64
+}
+
+// findToolUsageSignal describes how call history adjusted a tool's hybrid score.
+type findToolUsageSignal struct {
+	Global ToolUsageStats
+	Key    ToolUsageStats
+	Boost  float64
+}
+
+// findToolMatch is one ranked tool together with the signals that placed it there.
+type findToolMatch struct {
+	Name         string
+	Score        float64
+	RegexMatch   string
+	MatchedTerms []string
+	BM25         *findToolSignal
+	Embedding    *findToolSignal
+	Lexical      *float64
+	Usage        *findToolUsageSignal
+}
+
+// searchHybrid fuses the BM25 and semantic rankings with reciprocal rank fusion,
+// then scales each fused score by the tool's usage boost when usage is provided.
+func (t *FindToolTool) searchHybrid(ctx context.Context, apiKey, query string, tools []mcp.Tool, extras []FindToolExtraTool, usage *FindToolUsage) ([]findToolMatch, error) {
+	scores, err := t.scoreEmbedding(ctx, apiKey, query, extras)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make(map[string]*findToolMatch, len(tools))
+	order := make([]string, 0, len(tools))
+	get := func(name string) *findToolMatch {
+		if m, ok := matches[name]; ok {
+			return m
+		}
+		m := &findToolMatch{Name: name}
+		matches[name] = m
+		order = append(order, name)
+		return m
+	}
+
+	for _, bm := range t.searchBM25(query, tools) {
+		m := get(bm.Name)
+		m.BM25 = bm.BM25
+		m.MatchedTerms = bm.MatchedTerms
+		m.Score += 1.0 / float64(findToolRRFK+bm.BM25.Rank)
+	}
+
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].semantic > scores[j].semantic
+	})
+	rank := 0
+	for _, s := range scores {
+		if s.semantic <= 0 {
+			break
+		}
+		rank++
+		m := get(s.name)
+		m.Embedding = &findToolSignal{Rank: rank, Score: s.semantic}
+		m.Score += 1.0 / float64(findToolRRFK+rank)
+	}
+
+	result := make([]findToolMatch, 0, len(order))
+	for _, name := range order {
+		m := matches[name]
+		if usage != nil {
+			signal := &findToolUsageSignal{Global: usage.Global[name], Key: usage.Key[name]}
+			signal.Boost = findToolGlobalUsageWeight*usageSignal(signal.Global) +
+				findToolKeyUsageWeight*usageSignal(signal.Key)
+			if signal.Global.Calls > 0 || signal.Key.Calls > 0 {
+				m.Usage = signal
+			}
+			m.Score *= 1 + signal.Boost
+		}
+		result = append(result, *m)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Score > result[j].Score
+	})
+
+	return result, nil
+}
+
+// usageSignal maps call stats to [-1, 1]: positive when most calls succeed,
+// negative when most fail, and close to zero until enough calls are recorded.
+func usageSignal(stats ToolUsageStats) float64 {
+	if stats.Calls <= 0 {
+		return 0
+	}
+
+	calls := float64(stats.Calls)
+	successes := math.Min(float64(stats.Successes), calls)
+	// Laplace smoothing keeps a single call from looking like a 0% or 100% success rate.
+	rate := (successes + 1) / (calls + 2)
+	confidence := calls / (calls + findToolUsagePrior)
+	return 2 * (rate - 0.5) * confidence
+}
+
+// explain renders the ranking explanation returned to the caller for a match at rank.
+func (m findToolMatch) explain(rank int) map[string]any {
+	var reasons []string
+	signals := make(map[string]any)
+
+	if m.RegexMatch != "" {
+		reasons = append(reasons, fmt.Sprintf("regex matched %q", m.RegexMatch))
+		signals["regex"] = map[string]any{"matched": m.RegexMatch}
+	}
+	if m.BM25 != nil {
+		reasons = append(reasons, fmt.Sprintf("bm25 rank %d for terms %s", m.BM25.Rank, strings.Join(m.MatchedTerms, ", ")))
+		signals["bm25"] = map[string]any{
+			"rank":          m.BM25.Rank,
+			"score":         roundScore(m.BM25.Score),
+			"matched_terms": m.MatchedTerms,
+		}
+	}
+	if m.Embedding != nil {
+		embedding := map[string]any{"similarity": roundScore(m.Embedding.Score)}
+		if m.Embedding.Rank > 0 {
+			embedding["rank"] = m.Embedding.Rank
+			reasons = append(reasons, fmt.Sprintf("embedding rank %d with similarity %.2f", m.Embedding.Rank, m.Embedding.Score))
+		} else {
+			reasons = append(reasons, fmt.Sprintf("semantic similarity %.2f", m.Embedding.Score))
+		}
+		signals["embedding"] = embedding
+	}
+	if m.Lexical != nil {
+		reasons = append(reasons, fmt.Sprintf("lexical overlap %.2f", *m.Lexical))
+		signals["lexical"] = map[string]any{"score": roundScore(*m.Lexical)}
+	}
+	if m.Usage != nil {
+		usage := map[string]any{"boost": roundScore(m.Usage.Boost)}
+		var parts []string
+		if m.Usage.Global.Calls > 0 {
+			rate := float64(m.Usage.Global.Successes) / float64(m.Usage.Global.Calls)
+			usage["calls"] = m.Usage.Global.Calls
+			usage["success_rate"] = roundScore(rate)
+			parts = append(parts, fmt.Sprintf("%.0f%% of %d calls succeeded", rate*100, m.Usage.Global.Calls))
+		}
+		if m.Usage.Key.Calls > 0 {
+			rate := float64(m.Usage.Key.Successes) / float64(m.Usage.Key.Calls)
+			usage["key_calls"] = m.Usage.Key.Calls
+			usage["key_success_rate"] = roundScore(rate)
+			parts = append(parts, fmt.Sprintf("%.0f%% of %d calls with your key", rate*100, m.Usage.Key.Calls))
+		}
+		reasons = append(reasons, fmt.Sprintf("usage boost %+.1f%% (%s)", m.Usage.Boost*100, strings.Join(parts, "; ")))
+		signals["usage"] = usage
+	}
+
+	return map[string]any{
+		"rank":    rank,
+		"score":   roundScore(m.Score),
+		"why":     strings.Join(reasons, "; "),
+		"signals": signals,
+	}
+}
+
+// roundScore trims scores to four decimals so explanations stay readable.
+func roundScore(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"slices"
 	"testing"
 
 	mcp "github.com/mark3labs/mcp-go/mcp"
@@ -634,3 +635,202 @@ func TestIsToolNamePattern(t *testing.T) {
 		})
 	}
 }
+
+// TestFindToolTool_ExtraToolsProvider verifies that per-caller tools are searchable in every mode.
+func TestFindToolTool_ExtraToolsProvider(t *testing.T) {
+	tool := mustFindToolTool(t)
+	tool.SetExtraToolsProvider(func(ctx context.Context) []FindToolExtraTool {
+		return []FindToolExtraTool{{Tool: mcp.NewTool("pipeline_weekly_digest",
+			mcp.WithDescription("Summarize weekly newsletter digest."),
+			mcp.WithString("topic", mcp.Description("Digest topic")),
+		)}}
+	})
+
+	for _, mode := range []string{FindToolModeRegex, FindToolModeBM25, FindToolModeEmbedding, FindToolModeHybrid} {
+		query := "newsletter digest"
+		if mode == FindToolModeRegex {
+			query = "pipeline_.*"
+		}
+		result, err := tool.Handle(context.Background(), mcp.CallToolRequest{Params: mcp.CallToolParams{
+			Arguments: map[string]any{"query": query, "mode": mode, "return_references_only": true},
+		}})
+		require.NoError(t, err, mode)
+		require.False(t, result.IsError, mode)
+		require.Contains(t, toolResultText(result), "pipeline_weekly_digest", mode)
+	}
+
+	// The shared index must not retain caller-specific tools.
+	require.Len(t, tool.toolDocs, len(sampleTools()))
+}
+
+// countingEmbedder is a deterministicEmbedder that records how many texts it embedded.
+type countingEmbedder struct {
+	deterministicEmbedder
+	texts int
+}
+
+func (c *countingEmbedder) EmbedTexts(ctx context.Context, apiKey string, inputs []string) ([]pgvector.Vector, error) {
+	c.texts += len(inputs)
+	return c.deterministicEmbedder.EmbedTexts(ctx, apiKey, inputs)
+}
+
+// TestFindToolTool_ExtraToolEmbeddingsCached verifies that extra tools are embedded
+// once per version and again after they are forgotten.
+func TestFindToolTool_ExtraToolEmbeddingsCached(t *testing.T) {
+	embedder := &countingEmbedder{}
+	tool := mustFindToolToolWithEmbedder(t, embedder)
+	version := "v1"
+	tool.SetExtraToolsProvider(func(ctx context.Context) []FindToolExtraTool {
+		return []FindToolExtraTool{{
+			Tool:    mcp.NewTool("pipeline_weekly_digest", mcp.WithDescription("Summarize weekly newsletter digest.")),
+			Key:     "pipeline-id",
+			Version: version,
+		}}
+	})
+	search := func() int {
+		before := embedder.texts
+		result, err := tool.Handle(context.Background(), mcp.CallToolRequest{Params: mcp.CallToolParams{
+			Arguments: map[string]any{"query": "newsletter digest", "mode": FindToolModeEmbedding},
+		}})
+		require.NoError(t, err)
+		require.False(t, result.IsError)
+		return embedder.texts - before
+	}
+
+	search() // builds the shared index
+	require.Equal(t, 1, search(), "only the query is embedded once the extra tool is cached")
+	version = "v2"
+	require.Equal(t, 2, search())
+	require.Equal(t, 1, search())
+	tool.ForgetExtraTool("pipeline-id")
+	require.Equal(t, 2, search())
+}
+
+// findToolRankings runs find_tool and returns the ranked tool names and their explanations.
+func findToolRankings(t *testing.T, tool *FindToolTool, args map[string]any) ([]string, []map[string]any) {
+	t.Helper()
+	result, err := tool.Handle(context.Background(), mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: args}})
+	require.NoError(t, err)
+	require.False(t, result.IsError, toolResultText(result))
+
+	var payload struct {
+		Mode  string `json:"mode"`
+		Tools []struct {
+			Name    string         `json:"name"`
+			Ranking map[string]any `json:"ranking"`
+		} `json:"tools"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(toolResultText(result)), &payload))
+	require.Equal(t, args["mode"], payload.Mode)
+
+	names := make([]string, 0, len(payload.Tools))
+	rankings := make([]map[string]any, 0, len(payload.Tools))
+	for _, entry := range payload.Tools {
+		names = append(names, entry.Name)
+		rankings = append(rankings, entry.Ranking)
+	}
+	return names, rankings
+}
+
+// TestFindToolTool_HybridMode verifies that hybrid mode fuses the bm25 and embedding
+// rankings and explains both signals.
+func TestFindToolTool_HybridMode(t *testing.T) {
+	tool := mustFindToolTool(t)
+
+	names, rankings := findToolRankings(t, tool, map[string]any{"query": "read a file from disk", "mode": FindToolModeHybrid})
+	require.NotEmpty(t, names)
+	require.LessOrEqual(t, len(names), findToolDefaultTopK)
+	require.Contains(t, names, "file_read")
+	require.EqualValues(t, 1, rankings[0]["rank"])
+
+	// The test embedder is keyword-agnostic, so only the bm25 rank is predictable.
+	ranking := rankings[slices.Index(names, "file_read")]
+	require.Contains(t, ranking["why"], "bm25 rank 1")
+	require.Contains(t, ranking["why"], "embedding rank")
+	signals := ranking["signals"].(map[string]any)
+	require.Contains(t, signals, "bm25")
+	require.Contains(t, signals, "embedding")
+	require.NotContains(t, signals, "usage")
+	require.Contains(t, signals["bm25"].(map[string]any)["matched_terms"], "disk")
+}
+
+// TestFindToolTool_HybridUsageBoost verifies that call success rates reorder hybrid results
+// only when usage_boost is set.
+func TestFindToolTool_HybridUsageBoost(t *testing.T) {
+	tool := mustFindToolTool(t)
+	args := map[string]any{"query": "file path", "mode": FindToolModeHybrid}
+
+	baseline, _ := findToolRankings(t, tool, args)
+	require.GreaterOrEqual(t, len(baseline), 2)
+	first, second := baseline[0], baseline[1]
+
+	var gotKey string
+	tool.SetUsageProvider(func(_ context.Context, apiKey string) (*FindToolUsage, error) {
+		gotKey = apiKey
+		return &FindToolUsage{
+			Global: map[string]ToolUsageStats{first: {Calls: 200, Successes: 10}, second: {Calls: 200, Successes: 195}},
+			Key:    map[string]ToolUsageStats{first: {Calls: 50, Successes: 0}, second: {Calls: 50, Successes: 50}},
+		}, nil
+	})
+
+	unset, _ := findToolRankings(t, tool, args)
+	require.Equal(t, baseline, unset)
+
+	args["usage_boost"] = true
+	boosted, rankings := findToolRankings(t, tool, args)
+	require.Equal(t, "sk-test", gotKey)
+	require.Equal(t, second, boosted[0])
+	usage := rankings[0]["signals"].(map[string]any)["usage"].(map[string]any)
+	require.EqualValues(t, 200, usage["calls"])
+	require.EqualValues(t, 50, usage["key_calls"])
+	require.Greater(t, usage["boost"], 0.0)
+	require.Contains(t, rankings[0]["why"], "usage boost +")
+
+	args["usage_boost"] = false
+	unboosted, _ := findToolRankings(t, tool, args)
+	require.Equal(t, baseline, unboosted)
+	args["usage_boost"] = true
+
+	// A failing usage provider degrades to the plain hybrid ranking.
+	tool.SetUsageProvider(func(context.Context, string) (*FindToolUsage, error) {
+		return nil, fmt.Errorf("stats unavailable")
+	})
+	fallback, _ := findToolRankings(t, tool, args)
+	require.Equal(t, baseline, fallback)
+}
+
+// TestFindToolTool_ExplainsOtherModes verifies that every mode reports why tools ranked.
+func TestFindToolTool_ExplainsOtherModes(t *testing.T) {
+	tool := mustFindToolTool(t)
+
+	_, rankings := findToolRankings(t, tool, map[string]any{"query": "web_.*", "mode": FindToolModeRegex})
+	require.Contains(t, rankings[0]["why"], `regex matched "web_search"`)
+
+	_, rankings = findToolRankings(t, tool, map[string]any{"query": "fetch a web page", "mode": FindToolModeBM25})
+	require.Contains(t, rankings[0]["why"], "bm25 rank 1")
+
+	_, rankings = findToolRankings(t, tool, map[string]any{"query": "fetch a web page", "mode": FindToolModeEmbedding})
+	signals := rankings[0]["signals"].(map[string]any)
+	require.Contains(t, signals, "embedding")
+	require.Contains(t, signals, "lexical")
+
+	result, err := tool.Handle(context.Background(), mcp.CallToolRequest{Params: mcp.CallToolParams{
+		Arguments: map[string]any{"query": "web", "mode": FindToolModeRegex, "return_references_only": true},
+	}})
+	require.NoError(t, err)
+	var payload map[string]any
+	require.NoError(t, json.Unmarshal([]byte(toolResultText(result)), &payload))
+	refs := payload["rankings"].([]any)
+	require.Len(t, refs, len(payload["tool_references"].([]any)))
+	require.Equal(t, "web_search", refs[0].(map[string]any)["tool_name"])
+}
+
+// TestUsageSignal verifies the confidence-weighted success signal.
+func TestUsageSignal(t *testing.T) {
+	require.Zero(t, usageSignal(ToolUsageStats{}))
+	require.Greater(t, usageSignal(ToolUsageStats{Calls: 100, Successes: 100}), 0.8)
+	require.Less(t, usageSignal(ToolUsageStats{Calls: 100, Successes: 0}), -0.8)
+	require.InDelta(t, 0, usageSignal(ToolUsageStats{Calls: 100, Successes: 50}), 1e-9)
+	// A single success barely moves the signal.
+	require.Less(t, usageSignal(ToolUsageStats{Calls: 1, Successes: 1}), 0.05)
+}
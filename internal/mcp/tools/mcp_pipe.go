@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"regexp"
 	"slices"
 	"strconv"
 	"strings"
@@ -34,11 +33,19 @@ type PipeLimits struct {
 // MCPPipeTool implements the mcp_pipe MCP tool.
 //
 // It executes a declarative pipeline containing sequential steps, parallel groups,
-// and nested pipelines, feeding outputs forward through variable interpolation.
+// nested pipelines, conditional branches, and foreach fan-outs, feeding outputs
+// forward through variable interpolation.
+//
+// When a PipeRunStore is attached, mode "async" persists the run and executes
+// it in the background; see mcp_pipe_async.go.
 type MCPPipeTool struct {
-	logger  logSDK.Logger
-	invoker PipeInvoker
-	limits  PipeLimits
+	logger            logSDK.Logger
+	invoker           PipeInvoker
+	limits            PipeLimits
+	runStore          PipeRunStore
+	catalog           PipeToolCatalog
+	runCancels        sync.Map // run ID -> context.CancelCauseFunc
+	heartbeatInterval time.Duration
 }
 
 // NewMCPPipeTool constructs an MCPPipeTool.
@@ -60,9 +67,10 @@ func NewMCPPipeTool(logger logSDK.Logger, invoker PipeInvoker, limits PipeLimits
 	}
 
 	return &MCPPipeTool{
-		logger:  logger,
-		invoker: invoker,
-		limits:  limits,
+		logger:            logger,
+		invoker:           invoker,
+		limits:            limits,
+		heartbeatInterval: defaultPipeHeartbeatInterval,
 	}, nil
 }
 
@@ -70,11 +78,22 @@ func NewMCPPipeTool(logger logSDK.Logger, invoker PipeInvoker, limits PipeLimits
 func (t *MCPPipeTool) Definition() mcp.Tool {
 	return mcp.NewTool(
 		"mcp_pipe",
-		mcp.WithDescription("Execute a pipeline of MCP tools (sequential, parallel, nested) with output passing."),
+		mcp.WithDescription("Execute a pipeline of MCP tools (sequential, parallel, nested, if/else, foreach) with output passing."),
 		mcp.WithString(
 			"spec",
 			mcp.Description("Pipeline specification. Either a JSON object or a JSON-encoded string."),
 		),
+		mcp.WithString(
+			"mode",
+			mcp.Description("Execution mode. \"sync\" waits for the pipeline; \"async\" returns a run_id for mcp_pipe_status and mcp_pipe_cancel."),
+			mcp.Enum(pipeModeSync, pipeModeAsync),
+			mcp.DefaultString(pipeModeSync),
+		),
+		mcp.WithBoolean(
+			"dry_run",
+			mcp.Description("Validate the whole spec without invoking any tool: checks tool names, arguments, and references, and returns the execution plan with an estimated cost."),
+			mcp.DefaultBool(false),
+		),
 		mcp.WithReadOnlyHintAnnotation(true),
 		mcp.WithIdempotentHintAnnotation(false),
 		mcp.WithOpenWorldHintAnnotation(true),
@@ -98,6 +117,21 @@ func (t *MCPPipeTool) Handle(ctx context.Context, req mcp.CallToolRequest) (*mcp
 		return mcp.NewToolResultError("steps cannot be empty"), nil
 	}
 
+	switch spec.Mode {
+	case "", pipeModeSync:
+	case pipeModeAsync:
+		if spec.DryRun {
+			return t.dryRun(spec)
+		}
+		return t.startAsyncRun(ctx, logger, spec)
+	default:
+		return mcp.NewToolResultError(fmt.Sprintf("invalid mode %q: expected sync or async", spec.Mode)), nil
+	}
+
+	if spec.DryRun {
+		return t.dryRun(spec)
+	}
+
 	env := map[string]any{
 		"vars":  spec.Vars,
 		"steps": map[string]any{},
@@ -128,12 +162,16 @@ func (t *MCPPipeTool) Handle(ctx context.Context, req mcp.CallToolRequest) (*mcp
 // pipeSpec defines the JSON schema accepted by mcp_pipe.
 //
 // It is intentionally small: clients can express sequential steps, parallel
-// groups, nested pipelines, and a final return selector.
+// groups, nested pipelines, conditional branches, foreach fan-outs, and a final
+// return selector.
 type pipeSpec struct {
+	Mode            string         `json:"mode,omitempty"`
 	Vars            map[string]any `json:"vars,omitempty"`
 	Steps           []pipeStep     `json:"steps"`
 	Return          any            `json:"return,omitempty"`
 	ContinueOnError bool           `json:"continue_on_error,omitempty"`
+	// DryRun validates the spec and returns its execution plan without invoking any tool.
+	DryRun bool `json:"dry_run,omitempty"`
 }
 
 // pipeStep represents a single pipeline step.
@@ -143,14 +181,25 @@ type pipeStep struct {
 	Args     map[string]any `json:"args,omitempty"`
 	Parallel []pipeStep     `json:"parallel,omitempty"`
 	Pipe     *pipeSpec      `json:"pipe,omitempty"`
-	Meta     map[string]any `json:"meta,omitempty"`
-	_        map[string]any `json:"-"`
+	If       *pipeCondition `json:"if,omitempty"`
+	Then     *pipeStep      `json:"then,omitempty"`
+	Else     *pipeStep      `json:"else,omitempty"`
+	Foreach  *pipeForeach   `json:"foreach,omitempty"`
+	// Retry, TimeoutMs, and Fallback apply to every step kind; see mcp_pipe_policy.go.
+	Retry     *pipeRetry     `json:"retry,omitempty"`
+	TimeoutMs int            `json:"timeout_ms,omitempty"`
+	Fallback  *pipeFallback  `json:"fallback,omitempty"`
+	Meta      map[string]any `json:"meta,omitempty"`
+	_         map[string]any `json:"-"`
 }
 
 // stepCounter tracks executed steps to enforce MaxSteps.
 type stepCounter struct {
 	mu    sync.Mutex
 	count int
+	// onStep, when set, observes the top-level steps environment after each
+	// top-level step finishes. Async runs use it to persist progress.
+	onStep func(steps map[string]any)
 }
 
 // parsePipeSpec parses the input arguments into a pipeSpec.
@@ -158,7 +207,17 @@ func parsePipeSpec(arguments any) (*pipeSpec, error) {
 	args, ok := arguments.(map[string]any)
 	if ok {
 		if rawSpec, ok := args["spec"]; ok {
-			return parsePipeSpecRaw(rawSpec)
+			spec, err := parsePipeSpecRaw(rawSpec)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if mode, ok := args["mode"].(string); ok && spec.Mode == "" {
+				spec.Mode = mode
+			}
+			if dryRun, ok := args["dry_run"].(bool); ok && dryRun {
+				spec.DryRun = true
+			}
+			return spec, nil
 		}
 		// Allow passing the spec directly as the arguments object.
 		return parsePipeSpecRaw(args)
@@ -207,6 +266,9 @@ func (t *MCPPipeTool) executeSpec(ctx context.Context, logger logSDK.Logger, spe
 	if depth > t.limits.MaxDepth {
 		return nil, errors.New("pipeline nesting too deep")
 	}
+	if depth > 0 && spec.Mode != "" && spec.Mode != pipeModeSync {
+		return nil, errors.New("nested pipelines cannot set mode")
+	}
 
 	stepsEnv, ok := env["steps"].(map[string]any)
 	if !ok {
@@ -216,6 +278,11 @@ func (t *MCPPipeTool) executeSpec(ctx context.Context, logger logSDK.Logger, spe
 	seen := map[string]struct{}{}
 	var pipelineErr error
 	for i := range spec.Steps {
+		if ctx.Err() != nil {
+			// Stop scheduling new steps once the run is canceled, even with continue_on_error.
+			return t.resolveReturn(spec, env), errors.WithStack(context.Cause(ctx))
+		}
+
 		step := spec.Steps[i]
 		if err := validateStep(step); err != nil {
 			return nil, errors.WithStack(err)
@@ -232,6 +299,9 @@ func (t *MCPPipeTool) executeSpec(ctx context.Context, logger logSDK.Logger, spe
 		stepResult, err := t.executeStep(ctx, logger, step, env, depth, counter)
 		stepsEnv[step.ID] = stepResult
 		env["last"] = stepResult
+		if depth == 0 && counter.onStep != nil {
+			counter.onStep(stepsEnv)
+		}
 
 		if err != nil {
 			if pipelineErr == nil {
@@ -254,13 +324,14 @@ func (t *MCPPipeTool) resolveReturn(spec *pipeSpec, env map[string]any) any {
 
 	resolved, err := resolveAny(spec.Return, env)
 	if err != nil {
-		return map[string]any{"error": err.Error()}
+		return map[string]any{"error": errors.Wrap(err, "resolve return").Error()}
 	}
 	return resolved
 }
 
-// executeStep runs a single step and returns a JSON-serializable result map.
-func (t *MCPPipeTool) executeStep(ctx context.Context, logger logSDK.Logger, step pipeStep, env map[string]any, depth int, counter *stepCounter) (map[string]any, error) {
+// executeStepOnce runs a single attempt of a step and returns a JSON-serializable result map.
+// Retry, timeout, and fallback policies are applied by executeStep.
+func (t *MCPPipeTool) executeStepOnce(ctx context.Context, logger logSDK.Logger, step pipeStep, env map[string]any, depth int, counter *stepCounter) (map[string]any, error) {
 	startedAt := time.Now().UTC()
 
 	if step.Tool != "" {
@@ -272,7 +343,7 @@ func (t *MCPPipeTool) executeStep(ctx context.Context, logger logSDK.Logger, ste
 				zap.Strings("arg_keys", mapKeys(step.Args)),
 				zap.Error(err),
 			)
-			wrapped := errors.WithStack(err)
+			wrapped := errors.Wrapf(err, "step %q: resolve args", step.ID)
 			return stepResultMap(step, startedAt, time.Since(startedAt), nil, wrapped), wrapped
 		}
 
@@ -300,11 +371,16 @@ func (t *MCPPipeTool) executeStep(ctx context.Context, logger logSDK.Logger, ste
 		return stepResultMap(step, startedAt, dur, result, nil), nil
 	}
 
+	if step.If != nil {
+		return t.executeConditionalStep(ctx, logger, step, env, depth, counter)
+	}
+
+	if step.Foreach != nil {
+		return t.executeForeachStep(ctx, logger, step, env, depth, counter)
+	}
+
 	if step.Pipe != nil {
-		childEnv := map[string]any{
-			"vars":  env["vars"],
-			"steps": map[string]any{},
-		}
+		childEnv := childPipeEnv(env)
 		childResult, err := t.executeSpec(ctx, logger, step.Pipe, childEnv, depth+1, counter)
 		dur := time.Since(startedAt)
 		result := map[string]any{
@@ -400,10 +476,34 @@ func validateStep(step pipeStep) error {
 	if len(step.Parallel) > 0 {
 		modeCount++
 	}
+	if step.If != nil {
+		modeCount++
+	}
+	if step.Foreach != nil {
+		modeCount++
+	}
 	if modeCount != 1 {
-		return errors.New("step must have exactly one of tool, pipe, or parallel")
+		return errors.New("step must have exactly one of tool, pipe, parallel, if, or foreach")
 	}
-	return nil
+
+	if step.If == nil && (step.Then != nil || step.Else != nil) {
+		return errors.New("then/else require if on step: " + id)
+	}
+	if step.If != nil && step.Then == nil {
+		return errors.New("if step requires then: " + id)
+	}
+	if step.Foreach != nil {
+		if step.Foreach.Step == nil {
+			return errors.New("foreach step requires step: " + id)
+		}
+		if step.Foreach.Items == nil {
+			return errors.New("foreach step requires items: " + id)
+		}
+		if _, reserved := reservedPipeEnvKeys[step.Foreach.loopVar()]; reserved {
+			return errors.New("foreach.as cannot shadow a reserved name: " + step.Foreach.loopVar())
+		}
+	}
+	return validatePolicy(step)
 }
 
 // increment increases the step counter and enforces MaxSteps.
@@ -437,8 +537,6 @@ func stepResultMap(step pipeStep, startedAt time.Time, dur time.Duration, toolRe
 	return result
 }
 
-var placeholderPattern = regexp.MustCompile(`\$\{([^}]+)\}`)
-
 // resolveAny resolves references and interpolations inside a value.
 func resolveAny(value any, env map[string]any) (any, error) {
 	switch v := value.(type) {
@@ -452,7 +550,7 @@ func resolveAny(value any, env map[string]any) (any, error) {
 			if !ok {
 				return nil, errors.New("$ref must be a string")
 			}
-			return resolvePath(refStr, env)
+			return evaluatePipeExpr(refStr, env)
 		}
 
 		out := make(map[string]any, len(v))
@@ -479,9 +577,9 @@ func resolveAny(value any, env map[string]any) (any, error) {
 	}
 }
 
-// resolveString expands ${path} placeholders inside a string.
+// resolveString expands ${expr} placeholders inside a string.
 func resolveString(s string, env map[string]any) (string, error) {
-	matches := placeholderPattern.FindAllStringSubmatchIndex(s, -1)
+	matches := findPlaceholders(s)
 	if len(matches) == 0 {
 		return s, nil
 	}
@@ -491,12 +589,9 @@ func resolveString(s string, env map[string]any) (string, error) {
 	for _, m := range matches {
 		start := m[0]
 		end := m[1]
-		pathStart := m[2]
-		pathEnd := m[3]
 
 		b.WriteString(s[cursor:start])
-		path := strings.TrimSpace(s[pathStart:pathEnd])
-		val, err := resolvePath(path, env)
+		val, err := evaluatePipeExpr(s[start+2:end-1], env)
 		if err != nil {
 			return "", err
 		}
@@ -507,6 +602,54 @@ func resolveString(s string, env map[string]any) (string, error) {
 	return b.String(), nil
 }
 
+// findPlaceholders returns [start, end) offsets of each ${...} placeholder in s.
+//
+// Braces are balanced and quoted sections are skipped so that expressions such
+// as ${ {url: url} } or ${join('}', xs)} are captured whole. An unterminated
+// or empty placeholder is left as literal text.
+func findPlaceholders(s string) [][2]int {
+	var out [][2]int
+	for i := 0; i+1 < len(s); i++ {
+		if s[i] != '$' || s[i+1] != '{' {
+			continue
+		}
+
+		depth := 0
+		var quote byte
+		end := -1
+	scan:
+		for j := i + 1; j < len(s); j++ {
+			c := s[j]
+			switch {
+			case quote != 0:
+				if c == '\\' {
+					j++
+				} else if c == quote {
+					quote = 0
+				}
+			case c == '\'' || c == '"' || c == '`':
+				quote = c
+			case c == '{':
+				depth++
+			case c == '}':
+				depth--
+				if depth == 0 {
+					end = j + 1
+					break scan
+				}
+			}
+		}
+		if end < 0 {
+			break
+		}
+		if strings.TrimSpace(s[i+2:end-1]) != "" {
+			out = append(out, [2]int{i, end})
+		}
+		i = end - 1
+	}
+	return out
+}
+
 // resolvePath resolves a dotted path against an environment map.
 func resolvePath(path string, env map[string]any) (any, error) {
 	trimmed := strings.TrimSpace(path)
@@ -526,10 +669,10 @@ func resolvePath(path string, env map[string]any) (any, error) {
 		if idxErr == nil {
 			slice, ok := current.([]any)
 			if !ok {
-				return nil, errors.New("reference segment is not an array: " + part)
+				return nil, newPipeRefError("reference segment is not an array: " + part)
 			}
 			if idx < 0 || idx >= len(slice) {
-				return nil, errors.New("reference index out of range: " + part)
+				return nil, newPipeRefError("reference index out of range: " + part)
 			}
 			current = slice[idx]
 			continue
@@ -537,11 +680,11 @@ func resolvePath(path string, env map[string]any) (any, error) {
 
 		m, ok := current.(map[string]any)
 		if !ok {
-			return nil, errors.New("reference segment is not an object: " + part)
+			return nil, newPipeRefError("reference segment is not an object: " + part)
 		}
 		next, ok := m[part]
 		if !ok {
-			return nil, errors.New("reference not found: " + part)
+			return nil, newPipeRefError("reference not found: " + part)
 		}
 		current = next
 	}
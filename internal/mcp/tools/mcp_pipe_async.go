@@ -0,0 +1,367 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	errors "github.com/Laisky/errors/v2"
+	logSDK "github.com/Laisky/go-utils/v6/log"
+	"github.com/Laisky/zap"
+	"github.com/google/uuid"
+	mcp "github.com/mark3labs/mcp-go/mcp"
+
+	mcpauth "github.com/Laisky/laisky-blog-graphql/internal/mcp/auth"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/piperuns"
+)
+
+const (
+	// pipeModeSync runs the pipeline inside the tools/call request.
+	pipeModeSync = "sync"
+	// pipeModeAsync persists the run and executes it in the background.
+	pipeModeAsync = "async"
+	// defaultPipeHeartbeatInterval controls how often a background run reports liveness
+	// and checks for cancellation requests.
+	defaultPipeHeartbeatInterval = 5 * time.Second
+)
+
+// errPipeRunCanceled is the cancellation cause used when the owner cancels a run.
+var errPipeRunCanceled = errors.New("pipeline run canceled")
+
+// PipeRunStore persists asynchronous mcp_pipe runs.
+type PipeRunStore interface {
+	Create(ctx context.Context, apiKeyHash string, spec any) (*piperuns.Run, error)
+	MarkRunning(ctx context.Context, runID uuid.UUID) (bool, error)
+	Heartbeat(ctx context.Context, runID uuid.UUID, steps map[string]any) (bool, error)
+	Finish(ctx context.Context, runID uuid.UUID, status string, steps map[string]any, result any, errMsg string) error
+	Get(ctx context.Context, apiKeyHash string, runID string) (*piperuns.Run, error)
+	RequestCancel(ctx context.Context, apiKeyHash string, runID string) (*piperuns.Run, error)
+}
+
+// WithRunStore enables mode "async" by persisting runs in store.
+func (t *MCPPipeTool) WithRunStore(store PipeRunStore) *MCPPipeTool {
+	if t != nil {
+		t.runStore = store
+	}
+	return t
+}
+
+// AsyncEnabled reports whether the tool can accept mode "async".
+func (t *MCPPipeTool) AsyncEnabled() bool {
+	return t != nil && t.runStore != nil
+}
+
+// startAsyncRun persists spec as a pending run, starts it in the background,
+// and returns the run identifier without waiting for any step.
+func (t *MCPPipeTool) startAsyncRun(ctx context.Context, logger logSDK.Logger, spec *pipeSpec) (*mcp.CallToolResult, error) {
+	if !t.AsyncEnabled() {
+		return mcp.NewToolResultError("async mode is not available"), nil
+	}
+	apiKeyHash, ok := pipeAPIKeyHash(ctx)
+	if !ok {
+		return mcp.NewToolResultError("async mode requires an authenticated API key"), nil
+	}
+
+	// Reject malformed top-level steps now instead of after the caller has gone away.
+	seen := map[string]struct{}{}
+	for _, step := range spec.Steps {
+		if err := validateStep(step); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if _, ok := seen[step.ID]; ok {
+			return mcp.NewToolResultError("duplicate step id: " + step.ID), nil
+		}
+		seen[step.ID] = struct{}{}
+	}
+
+	run, err := t.runStore.Create(ctx, apiKeyHash, spec)
+	if err != nil {
+		if errors.Is(err, piperuns.ErrActiveRunLimit) {
+			return mcp.NewToolResultError(fmt.Sprintf("too many active pipeline runs (limit %d); wait for a run to finish or cancel one", piperuns.MaxActiveRunsPerKey)), nil
+		}
+		logger.Error("mcp_pipe create async run", zap.Error(err))
+		return mcp.NewToolResultError("failed to create pipeline run"), nil
+	}
+
+	// Detach from the request so the run survives client disconnects, while
+	// keeping request values such as the authorization context for billing.
+	runCtx, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
+	t.runCancels.Store(run.ID.String(), cancel)
+	go t.executeAsyncRun(runCtx, cancel, logger, run.ID, spec)
+
+	return mcp.NewToolResultJSON(map[string]any{
+		"run_id": run.ID.String(),
+		"mode":   pipeModeAsync,
+		"status": run.Status,
+	})
+}
+
+// executeAsyncRun executes a persisted run and stores its progress and final state.
+func (t *MCPPipeTool) executeAsyncRun(ctx context.Context, cancel context.CancelCauseFunc, logger logSDK.Logger, runID uuid.UUID, spec *pipeSpec) {
+	logger = logger.With(zap.String("run_id", runID.String()))
+	defer t.runCancels.Delete(runID.String())
+	defer cancel(nil)
+
+	env := map[string]any{
+		"vars":  spec.Vars,
+		"steps": map[string]any{},
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("mcp_pipe async run panicked", zap.Any("panic", r))
+			if err := t.runStore.Finish(ctx, runID, piperuns.StatusFailed, nil, nil, fmt.Sprintf("internal error: %v", r)); err != nil {
+				logger.Error("mcp_pipe record panicked run", zap.Error(err))
+			}
+		}
+	}()
+
+	started, err := t.runStore.MarkRunning(ctx, runID)
+	if err != nil {
+		logger.Error("mcp_pipe mark async run running", zap.Error(err))
+		if finishErr := t.runStore.Finish(ctx, runID, piperuns.StatusFailed, nil, nil, "failed to start run"); finishErr != nil {
+			logger.Error("mcp_pipe record failed run", zap.Error(finishErr))
+		}
+		return
+	}
+	if !started {
+		logger.Debug("mcp_pipe async run canceled before start")
+		return
+	}
+
+	heartbeatDone := make(chan struct{})
+	go t.heartbeatAsyncRun(ctx, cancel, logger, runID, heartbeatDone)
+
+	counter := &stepCounter{
+		onStep: func(steps map[string]any) {
+			cancelRequested, hbErr := t.runStore.Heartbeat(ctx, runID, steps)
+			if hbErr != nil {
+				logger.Warn("mcp_pipe save async run progress", zap.Error(hbErr))
+				return
+			}
+			if cancelRequested {
+				cancel(errPipeRunCanceled)
+			}
+		},
+	}
+	result, execErr := t.executeSpec(ctx, logger, spec, env, 0, counter)
+	close(heartbeatDone)
+
+	status := piperuns.StatusSucceeded
+	errMsg := errorString(execErr)
+	switch {
+	case errors.Is(context.Cause(ctx), errPipeRunCanceled):
+		status = piperuns.StatusCanceled
+		errMsg = errPipeRunCanceled.Error()
+	case execErr != nil:
+		status = piperuns.StatusFailed
+	}
+
+	steps, _ := env["steps"].(map[string]any)
+	if err := t.runStore.Finish(ctx, runID, status, steps, result, errMsg); err != nil {
+		if errors.Is(err, piperuns.ErrRunNotActive) {
+			logger.Warn("mcp_pipe async run finished after it was marked terminal", zap.String("status", status))
+			return
+		}
+		logger.Error("mcp_pipe record finished async run", zap.Error(err))
+		return
+	}
+	logger.Debug("mcp_pipe async run finished", zap.String("status", status))
+}
+
+// heartbeatAsyncRun periodically refreshes run liveness and cancels the run
+// when its owner requested cancellation from another server instance.
+func (t *MCPPipeTool) heartbeatAsyncRun(ctx context.Context, cancel context.CancelCauseFunc, logger logSDK.Logger, runID uuid.UUID, done <-chan struct{}) {
+	ticker := time.NewTicker(t.heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cancelRequested, err := t.runStore.Heartbeat(ctx, runID, nil)
+			if err != nil {
+				logger.Warn("mcp_pipe async run heartbeat", zap.Error(err))
+				continue
+			}
+			if cancelRequested {
+				cancel(errPipeRunCanceled)
+				return
+			}
+		}
+	}
+}
+
+// cancelLocalRun interrupts a run executing in this process. It reports whether the run was found.
+func (t *MCPPipeTool) cancelLocalRun(runID string) bool {
+	value, ok := t.runCancels.Load(runID)
+	if !ok {
+		return false
+	}
+	cancel, ok := value.(context.CancelCauseFunc)
+	if !ok {
+		return false
+	}
+	cancel(errPipeRunCanceled)
+	return true
+}
+
+// pipeAPIKeyHash returns the caller's API key hash used to scope async runs.
+func pipeAPIKeyHash(ctx context.Context) (string, bool) {
+	authCtx, ok := mcpauth.FromContext(ctx)
+	if !ok || authCtx == nil || strings.TrimSpace(authCtx.APIKeyHash) == "" {
+		return "", false
+	}
+	return authCtx.APIKeyHash, true
+}
+
+// pipeRunPayload converts a persisted run into the JSON shape returned by the companion tools.
+func pipeRunPayload(run *piperuns.Run) map[string]any {
+	payload := map[string]any{
+		"run_id":           run.ID.String(),
+		"status":           run.Status,
+		"done":             run.Terminal(),
+		"ok":               run.Status == piperuns.StatusSucceeded,
+		"error":            run.ErrorMessage,
+		"cancel_requested": run.CancelRequested,
+		"created_at":       run.CreatedAt.UTC().Format(time.RFC3339Nano),
+		"updated_at":       run.UpdatedAt.UTC().Format(time.RFC3339Nano),
+		"steps":            decodeRunJSON(run.Steps, map[string]any{}),
+	}
+	if run.StartedAt != nil {
+		payload["started_at"] = run.StartedAt.UTC().Format(time.RFC3339Nano)
+	}
+	if run.FinishedAt != nil {
+		payload["finished_at"] = run.FinishedAt.UTC().Format(time.RFC3339Nano)
+	}
+	if run.Terminal() {
+		payload["result"] = decodeRunJSON(run.Result, nil)
+	}
+	return payload
+}
+
+// decodeRunJSON decodes a stored JSON column, returning fallback when it is empty or invalid.
+func decodeRunJSON(raw []byte, fallback any) any {
+	if len(raw) == 0 {
+		return fallback
+	}
+	var decoded any
+	if err := json.Unmarshal(raw, &decoded); err != nil || decoded == nil {
+		return fallback
+	}
+	return decoded
+}
+
+// MCPPipeStatusTool implements the mcp_pipe_status MCP tool.
+type MCPPipeStatusTool struct {
+	pipe *MCPPipeTool
+}
+
+// NewMCPPipeStatusTool constructs an MCPPipeStatusTool backed by pipe's run store.
+func NewMCPPipeStatusTool(pipe *MCPPipeTool) (*MCPPipeStatusTool, error) {
+	if !pipe.AsyncEnabled() {
+		return nil, errors.New("mcp_pipe run store is required")
+	}
+	return &MCPPipeStatusTool{pipe: pipe}, nil
+}
+
+// Definition returns the MCP metadata describing the tool.
+func (t *MCPPipeStatusTool) Definition() mcp.Tool {
+	return mcp.NewTool(
+		"mcp_pipe_status",
+		mcp.WithDescription("Get the status, per-step results, and final result of an mcp_pipe run started with mode \"async\"."),
+		mcp.WithString(
+			"run_id",
+			mcp.Required(),
+			mcp.Description("Run identifier returned by mcp_pipe in async mode."),
+		),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithIdempotentHintAnnotation(true),
+		mcp.WithOpenWorldHintAnnotation(false),
+	)
+}
+
+// Handle returns the current state of an async run owned by the caller.
+func (t *MCPPipeStatusTool) Handle(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	apiKeyHash, ok := pipeAPIKeyHash(ctx)
+	if !ok {
+		return mcp.NewToolResultError("authorization required"), nil
+	}
+	runID := strings.TrimSpace(readStringArg(req, "run_id"))
+	if runID == "" {
+		return mcp.NewToolResultError("run_id is required"), nil
+	}
+
+	run, err := t.pipe.runStore.Get(ctx, apiKeyHash, runID)
+	if err != nil {
+		if errors.Is(err, piperuns.ErrRunNotFound) {
+			return mcp.NewToolResultError("pipeline run not found"), nil
+		}
+		t.pipe.logger.Error("mcp_pipe_status load run", zap.String("run_id", runID), zap.Error(err))
+		return mcp.NewToolResultError("failed to load pipeline run"), nil
+	}
+
+	return mcp.NewToolResultJSON(pipeRunPayload(run))
+}
+
+// MCPPipeCancelTool implements the mcp_pipe_cancel MCP tool.
+type MCPPipeCancelTool struct {
+	pipe *MCPPipeTool
+}
+
+// NewMCPPipeCancelTool constructs an MCPPipeCancelTool backed by pipe's run store.
+func NewMCPPipeCancelTool(pipe *MCPPipeTool) (*MCPPipeCancelTool, error) {
+	if !pipe.AsyncEnabled() {
+		return nil, errors.New("mcp_pipe run store is required")
+	}
+	return &MCPPipeCancelTool{pipe: pipe}, nil
+}
+
+// Definition returns the MCP metadata describing the tool.
+func (t *MCPPipeCancelTool) Definition() mcp.Tool {
+	return mcp.NewTool(
+		"mcp_pipe_cancel",
+		mcp.WithDescription("Cancel an mcp_pipe run started with mode \"async\". Steps already finished keep their results."),
+		mcp.WithString(
+			"run_id",
+			mcp.Required(),
+			mcp.Description("Run identifier returned by mcp_pipe in async mode."),
+		),
+		mcp.WithReadOnlyHintAnnotation(false),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithIdempotentHintAnnotation(true),
+		mcp.WithOpenWorldHintAnnotation(false),
+	)
+}
+
+// Handle requests cancellation of an async run owned by the caller.
+func (t *MCPPipeCancelTool) Handle(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	apiKeyHash, ok := pipeAPIKeyHash(ctx)
+	if !ok {
+		return mcp.NewToolResultError("authorization required"), nil
+	}
+	runID := strings.TrimSpace(readStringArg(req, "run_id"))
+	if runID == "" {
+		return mcp.NewToolResultError("run_id is required"), nil
+	}
+
+	run, err := t.pipe.runStore.RequestCancel(ctx, apiKeyHash, runID)
+	if err != nil {
+		if errors.Is(err, piperuns.ErrRunNotFound) {
+			return mcp.NewToolResultError("pipeline run not found"), nil
+		}
+		t.pipe.logger.Error("mcp_pipe_cancel request cancel", zap.String("run_id", runID), zap.Error(err))
+		return mcp.NewToolResultError("failed to cancel pipeline run"), nil
+	}
+
+	// Runs owned by this instance stop immediately; others stop at their next heartbeat.
+	if run.CancelRequested && !run.Terminal() {
+		t.pipe.cancelLocalRun(run.ID.String())
+	}
+
+	return mcp.NewToolResultJSON(pipeRunPayload(run))
+}
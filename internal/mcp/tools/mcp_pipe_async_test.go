@@ -0,0 +1,262 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	mcp "github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/require"
+
+	mcpauth "github.com/Laisky/laisky-blog-graphql/internal/mcp/auth"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/piperuns"
+	"github.com/Laisky/laisky-blog-graphql/library/log"
+)
+
+// memoryPipeRunStore is an in-memory PipeRunStore for tests.
+type memoryPipeRunStore struct {
+	mu       sync.Mutex
+	runs     map[uuid.UUID]*piperuns.Run
+	finished chan uuid.UUID
+}
+
+func newMemoryPipeRunStore() *memoryPipeRunStore {
+	return &memoryPipeRunStore{runs: map[uuid.UUID]*piperuns.Run{}, finished: make(chan uuid.UUID, 8)}
+}
+
+func (s *memoryPipeRunStore) Create(_ context.Context, apiKeyHash string, spec any) (*piperuns.Run, error) {
+	payload, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	run := &piperuns.Run{ID: uuid.New(), APIKeyHash: apiKeyHash, Status: piperuns.StatusPending, Spec: payload, CreatedAt: now, UpdatedAt: now}
+	s.runs[run.ID] = run
+	copied := *run
+	return &copied, nil
+}
+
+func (s *memoryPipeRunStore) MarkRunning(_ context.Context, runID uuid.UUID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run := s.runs[runID]
+	if run.Status != piperuns.StatusPending || run.CancelRequested {
+		return false, nil
+	}
+	now := time.Now().UTC()
+	run.Status = piperuns.StatusRunning
+	run.StartedAt = &now
+	return true, nil
+}
+
+func (s *memoryPipeRunStore) Heartbeat(_ context.Context, runID uuid.UUID, steps map[string]any) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run := s.runs[runID]
+	if steps != nil {
+		run.Steps, _ = json.Marshal(steps)
+	}
+	return run.CancelRequested, nil
+}
+
+func (s *memoryPipeRunStore) Finish(_ context.Context, runID uuid.UUID, status string, steps map[string]any, result any, errMsg string) error {
+	s.mu.Lock()
+	run := s.runs[runID]
+	now := time.Now().UTC()
+	run.Status = status
+	run.Steps, _ = json.Marshal(steps)
+	run.Result, _ = json.Marshal(result)
+	run.ErrorMessage = errMsg
+	run.FinishedAt = &now
+	s.mu.Unlock()
+	s.finished <- runID
+	return nil
+}
+
+func (s *memoryPipeRunStore) Get(_ context.Context, apiKeyHash string, runID string) (*piperuns.Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, err := uuid.Parse(runID)
+	if err != nil {
+		return nil, piperuns.ErrRunNotFound
+	}
+	run, ok := s.runs[id]
+	if !ok || run.APIKeyHash != apiKeyHash {
+		return nil, piperuns.ErrRunNotFound
+	}
+	copied := *run
+	return &copied, nil
+}
+
+func (s *memoryPipeRunStore) RequestCancel(ctx context.Context, apiKeyHash string, runID string) (*piperuns.Run, error) {
+	if _, err := s.Get(ctx, apiKeyHash, runID); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	run := s.runs[uuid.MustParse(runID)]
+	if !run.Terminal() {
+		run.CancelRequested = true
+	}
+	s.mu.Unlock()
+	return s.Get(ctx, apiKeyHash, runID)
+}
+
+// asyncTestContext returns a context carrying an authenticated caller.
+func asyncTestContext(hash string) context.Context {
+	return mcpauth.WithContext(context.Background(), &mcpauth.Context{APIKey: "sk-test", APIKeyHash: hash})
+}
+
+// waitPipeRunFinished blocks until the store records a finished run.
+func waitPipeRunFinished(t *testing.T, store *memoryPipeRunStore) {
+	t.Helper()
+	select {
+	case <-store.finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("async run did not finish")
+	}
+}
+
+// TestMCPPipeAsyncRun verifies that async mode returns a run_id and exposes results through mcp_pipe_status.
+func TestMCPPipeAsyncRun(t *testing.T) {
+	invoker := func(ctx context.Context, toolName string, args any) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultJSON(map[string]any{"tool": toolName})
+	}
+
+	store := newMemoryPipeRunStore()
+	pipe, err := NewMCPPipeTool(log.Logger.Named("test_mcp_pipe_async"), invoker, PipeLimits{})
+	require.NoError(t, err)
+	pipe.WithRunStore(store)
+	statusTool, err := NewMCPPipeStatusTool(pipe)
+	require.NoError(t, err)
+
+	ctx := asyncTestContext("hash")
+	result, err := pipe.Handle(ctx, mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"mode": "async",
+		"spec": map[string]any{
+			"steps": []any{
+				map[string]any{"id": "search", "tool": "web_search", "args": map[string]any{"query": "q"}},
+				map[string]any{"id": "fetch", "tool": "web_fetch", "args": map[string]any{"url": "x"}},
+			},
+			"return": map[string]any{"$ref": "steps.fetch.structured.tool"},
+		},
+	}}})
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+
+	started := result.StructuredContent.(map[string]any)
+	require.Equal(t, "async", started["mode"])
+	runID := started["run_id"].(string)
+	require.NotEmpty(t, runID)
+
+	waitPipeRunFinished(t, store)
+
+	status, err := statusTool.Handle(ctx, mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{"run_id": runID}}})
+	require.NoError(t, err)
+	require.False(t, status.IsError)
+
+	payload := status.StructuredContent.(map[string]any)
+	require.Equal(t, piperuns.StatusSucceeded, payload["status"])
+	require.Equal(t, true, payload["done"])
+	require.Equal(t, "web_fetch", payload["result"])
+	steps := payload["steps"].(map[string]any)
+	require.Contains(t, steps, "search")
+	require.Contains(t, steps, "fetch")
+
+	// Runs are scoped to the caller's API key hash.
+	other, err := statusTool.Handle(asyncTestContext("other"), mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{"run_id": runID}}})
+	require.NoError(t, err)
+	require.True(t, other.IsError)
+}
+
+// TestMCPPipeAsyncCancel verifies that mcp_pipe_cancel interrupts a running pipeline.
+func TestMCPPipeAsyncCancel(t *testing.T) {
+	entered := make(chan struct{})
+	invoker := func(ctx context.Context, toolName string, args any) (*mcp.CallToolResult, error) {
+		if toolName == "slow" {
+			close(entered)
+			<-ctx.Done()
+			return mcp.NewToolResultError(ctx.Err().Error()), nil
+		}
+		return mcp.NewToolResultJSON(map[string]any{"tool": toolName})
+	}
+
+	store := newMemoryPipeRunStore()
+	pipe, err := NewMCPPipeTool(log.Logger.Named("test_mcp_pipe_async_cancel"), invoker, PipeLimits{})
+	require.NoError(t, err)
+	pipe.WithRunStore(store)
+	cancelTool, err := NewMCPPipeCancelTool(pipe)
+	require.NoError(t, err)
+
+	ctx := asyncTestContext("hash")
+	result, err := pipe.Handle(ctx, mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"mode": "async",
+		"steps": []any{
+			map[string]any{"id": "first", "tool": "fast", "args": map[string]any{}},
+			map[string]any{"id": "wait", "tool": "slow", "args": map[string]any{}},
+			map[string]any{"id": "never", "tool": "fast", "args": map[string]any{}},
+		},
+		"continue_on_error": true,
+	}}})
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+	runID := result.StructuredContent.(map[string]any)["run_id"].(string)
+
+	<-entered
+	canceled, err := cancelTool.Handle(ctx, mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{"run_id": runID}}})
+	require.NoError(t, err)
+	require.False(t, canceled.IsError)
+	require.Equal(t, true, canceled.StructuredContent.(map[string]any)["cancel_requested"])
+
+	waitPipeRunFinished(t, store)
+
+	run, err := store.Get(ctx, "hash", runID)
+	require.NoError(t, err)
+	require.Equal(t, piperuns.StatusCanceled, run.Status)
+
+	var steps map[string]any
+	require.NoError(t, json.Unmarshal(run.Steps, &steps))
+	require.Contains(t, steps, "first")
+	require.Contains(t, steps, "wait")
+	require.NotContains(t, steps, "never")
+}
+
+// TestMCPPipeAsyncRequirements verifies async mode preconditions.
+func TestMCPPipeAsyncRequirements(t *testing.T) {
+	invoker := func(ctx context.Context, toolName string, args any) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultJSON(map[string]any{})
+	}
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"mode":  "async",
+		"steps": []any{map[string]any{"id": "a", "tool": "web_search"}},
+	}}}
+
+	pipe, err := NewMCPPipeTool(log.Logger.Named("test_mcp_pipe_async_req"), invoker, PipeLimits{})
+	require.NoError(t, err)
+
+	result, err := pipe.Handle(asyncTestContext("hash"), req)
+	require.NoError(t, err)
+	require.True(t, result.IsError)
+	require.Contains(t, toolResultText(result), "not available")
+
+	_, err = NewMCPPipeStatusTool(pipe)
+	require.Error(t, err)
+
+	pipe.WithRunStore(newMemoryPipeRunStore())
+	result, err = pipe.Handle(context.Background(), req)
+	require.NoError(t, err)
+	require.True(t, result.IsError)
+	require.Contains(t, toolResultText(result), "authenticated")
+
+	result, err = pipe.Handle(asyncTestContext("hash"), mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"mode":  "later",
+		"steps": []any{map[string]any{"id": "a", "tool": "web_search"}},
+	}}})
+	require.NoError(t, err)
+	require.True(t, result.IsError)
+	require.Contains(t, toolResultText(result), "invalid mode")
+}
@@ -0,0 +1,456 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	errors "github.com/Laisky/errors/v2"
+	logSDK "github.com/Laisky/go-utils/v6/log"
+	"github.com/Laisky/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+// defaultForeachVar is the environment key bound to the current item when foreach.as is omitted.
+const defaultForeachVar = "item"
+
+// reservedPipeEnvKeys lists environment roots that loop variables must not shadow.
+var reservedPipeEnvKeys = map[string]struct{}{
+	"vars":  {},
+	"steps": {},
+	"last":  {},
+	"index": {},
+}
+
+// pipeCondition is a predicate evaluated against the pipeline environment.
+//
+// Exactly one of Ref, All, Any, or Not should be set. Ref is a path or
+// expression evaluated by evaluatePipeExpr and then compared using Op
+// (default "truthy"). A condition may also be written as a bare string, e.g.
+// "steps.search.ok" or "${length(steps.search.structured.results) > 0}",
+// which is shorthand for {"ref": expr}.
+type pipeCondition struct {
+	Ref   string          `json:"ref,omitempty"`
+	Op    string          `json:"op,omitempty"`
+	Value any             `json:"value,omitempty"`
+	All   []pipeCondition `json:"all,omitempty"`
+	Any   []pipeCondition `json:"any,omitempty"`
+	Not   *pipeCondition  `json:"not,omitempty"`
+}
+
+// UnmarshalJSON accepts either a condition object or a bare path string.
+func (c *pipeCondition) UnmarshalJSON(data []byte) error {
+	var path string
+	if err := json.Unmarshal(data, &path); err == nil {
+		*c = pipeCondition{Ref: trimPlaceholder(path)}
+		return nil
+	}
+
+	type rawCondition pipeCondition
+	var decoded rawCondition
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return errors.Wrap(err, "decode if condition")
+	}
+	*c = pipeCondition(decoded)
+	return nil
+}
+
+// pipeForeach fans a sub-step out over an array value.
+//
+// Items is resolved with resolveAny, so it accepts a literal array or a
+// {"$ref": "steps..."} reference. Each iteration runs Step with the current
+// element bound to As (default "item") and its position bound to "index".
+type pipeForeach struct {
+	Items any       `json:"items"`
+	As    string    `json:"as,omitempty"`
+	Step  *pipeStep `json:"step"`
+}
+
+// loopVar returns the environment key bound to the current item.
+func (f *pipeForeach) loopVar() string {
+	if f == nil {
+		return defaultForeachVar
+	}
+	if name := strings.TrimSpace(f.As); name != "" {
+		return name
+	}
+	return defaultForeachVar
+}
+
+// executeConditionalStep evaluates step.If and runs the matching branch.
+func (t *MCPPipeTool) executeConditionalStep(ctx context.Context, logger logSDK.Logger, step pipeStep, env map[string]any, depth int, counter *stepCounter) (map[string]any, error) {
+	startedAt := time.Now().UTC()
+	result := map[string]any{
+		"id":         step.ID,
+		"kind":       "if",
+		"started_at": startedAt.Format(time.RFC3339Nano),
+	}
+	finish := func(stepErr error) (map[string]any, error) {
+		result["duration_ms"] = time.Since(startedAt).Milliseconds()
+		result["ok"] = stepErr == nil
+		result["error"] = errorString(stepErr)
+		if stepErr != nil {
+			return result, errors.WithStack(stepErr)
+		}
+		return result, nil
+	}
+
+	matched, err := evaluateCondition(step.If, env)
+	if err != nil {
+		logger.Debug("mcp_pipe evaluate condition failed",
+			zap.String("step_id", step.ID),
+			zap.Error(err),
+		)
+		return finish(errors.Wrapf(err, "step %q: evaluate if", step.ID))
+	}
+	result["condition"] = matched
+
+	branchName := "then"
+	branch := step.Then
+	if !matched {
+		branchName = "else"
+		branch = step.Else
+	}
+	if branch == nil {
+		result["branch"] = ""
+		result["result"] = nil
+		return finish(nil)
+	}
+	result["branch"] = branchName
+
+	if err := validateStep(*branch); err != nil {
+		return finish(err)
+	}
+	if err := counter.increment(t.limits.MaxSteps); err != nil {
+		return finish(err)
+	}
+
+	branchResult, branchErr := t.executeStep(ctx, logger, *branch, env, depth, counter)
+	result["result"] = branchResult
+	return finish(branchErr)
+}
+
+// executeForeachStep runs step.Foreach.Step once per item, bounded by MaxParallel.
+func (t *MCPPipeTool) executeForeachStep(ctx context.Context, logger logSDK.Logger, step pipeStep, env map[string]any, depth int, counter *stepCounter) (map[string]any, error) {
+	startedAt := time.Now().UTC()
+	result := map[string]any{
+		"id":         step.ID,
+		"kind":       "foreach",
+		"started_at": startedAt.Format(time.RFC3339Nano),
+	}
+	finish := func(stepErr error) (map[string]any, error) {
+		result["duration_ms"] = time.Since(startedAt).Milliseconds()
+		result["ok"] = stepErr == nil
+		result["error"] = errorString(stepErr)
+		if stepErr != nil {
+			return result, errors.WithStack(stepErr)
+		}
+		return result, nil
+	}
+
+	loop := step.Foreach
+	resolved, err := resolveAny(loop.Items, env)
+	if err != nil {
+		return finish(errors.Wrapf(err, "step %q: resolve foreach items", step.ID))
+	}
+	items, ok := resolved.([]any)
+	if !ok {
+		if resolved != nil {
+			return finish(errors.Errorf("step %q: foreach items must resolve to an array, got %s", step.ID, jsonTypeName(resolved)))
+		}
+		items = []any{}
+	}
+	if err := validateStep(*loop.Step); err != nil {
+		return finish(err)
+	}
+
+	result["count"] = len(items)
+	results := make([]any, len(items))
+	errs := make([]error, len(items))
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(t.limits.MaxParallel)
+
+	var launchErr error
+	for i, item := range items {
+		if err := counter.increment(t.limits.MaxSteps); err != nil {
+			launchErr = err
+			break
+		}
+
+		iterEnv := loopPipeEnv(env, loop.loopVar(), item, i)
+		g.Go(func() error {
+			res, err := t.executeStep(gctx, logger, *loop.Step, iterEnv, depth, counter)
+			results[i] = res
+			errs[i] = err
+			return nil
+		})
+	}
+
+	_ = g.Wait()
+	result["results"] = results
+
+	if launchErr != nil {
+		return finish(launchErr)
+	}
+	for i, iterErr := range errs {
+		if iterErr != nil {
+			logger.Debug("mcp_pipe foreach iteration failed",
+				zap.String("step_id", step.ID),
+				zap.Int("index", i),
+				zap.Error(iterErr),
+			)
+			return finish(errors.Wrapf(iterErr, "foreach index %d", i))
+		}
+	}
+	return finish(nil)
+}
+
+// childPipeEnv builds the environment for a nested pipeline.
+// It keeps vars and any loop bindings, but starts with an empty steps map.
+func childPipeEnv(env map[string]any) map[string]any {
+	child := make(map[string]any, len(env))
+	for key, val := range env {
+		if key == "steps" || key == "last" {
+			continue
+		}
+		child[key] = val
+	}
+	child["steps"] = map[string]any{}
+	return child
+}
+
+// loopPipeEnv returns a shallow copy of env with the foreach bindings applied.
+//
+// The steps map is shared read-only with the parent; iterations never write to it.
+func loopPipeEnv(env map[string]any, name string, item any, index int) map[string]any {
+	iterEnv := make(map[string]any, len(env)+2)
+	for key, val := range env {
+		iterEnv[key] = val
+	}
+	iterEnv[name] = item
+	iterEnv["index"] = index
+	return iterEnv
+}
+
+// evaluateCondition evaluates a pipeCondition against env.
+func evaluateCondition(cond *pipeCondition, env map[string]any) (bool, error) {
+	if cond == nil {
+		return false, errors.New("condition cannot be empty")
+	}
+
+	switch {
+	case len(cond.All) > 0:
+		for i := range cond.All {
+			ok, err := evaluateCondition(&cond.All[i], env)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	case len(cond.Any) > 0:
+		for i := range cond.Any {
+			ok, err := evaluateCondition(&cond.Any[i], env)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				return true, nil
+			}
+		}
+		return false, nil
+	case cond.Not != nil:
+		ok, err := evaluateCondition(cond.Not, env)
+		return !ok, err
+	}
+
+	path := strings.TrimSpace(cond.Ref)
+	if path == "" {
+		return false, errors.New("condition requires ref, all, any, or not")
+	}
+
+	// A missing reference is not an error for predicates: it simply does not exist.
+	// Syntax and function errors still fail the step.
+	value, resolveErr := evaluatePipeExpr(path, env)
+	if resolveErr != nil && !isPipeRefError(resolveErr) {
+		return false, resolveErr
+	}
+	exists := resolveErr == nil
+
+	op := strings.ToLower(strings.TrimSpace(cond.Op))
+	switch op {
+	case "exists":
+		return exists, nil
+	case "not_exists":
+		return !exists, nil
+	case "", "truthy":
+		return exists && isTruthy(value), nil
+	case "falsy":
+		return !exists || !isTruthy(value), nil
+	case "empty":
+		return !exists || isEmptyValue(value), nil
+	case "not_empty":
+		return exists && !isEmptyValue(value), nil
+	}
+
+	operand, err := resolveAny(cond.Value, env)
+	if err != nil {
+		return false, errors.Wrap(err, "resolve condition value")
+	}
+
+	switch op {
+	case "eq":
+		return exists && valuesEqual(value, operand), nil
+	case "ne":
+		return !exists || !valuesEqual(value, operand), nil
+	case "gt", "gte", "lt", "lte":
+		if !exists {
+			return false, nil
+		}
+		cmp, ok := compareValues(value, operand)
+		if !ok {
+			return false, errors.Errorf("condition %q cannot compare %T with %T", op, value, operand)
+		}
+		switch op {
+		case "gt":
+			return cmp > 0, nil
+		case "gte":
+			return cmp >= 0, nil
+		case "lt":
+			return cmp < 0, nil
+		default:
+			return cmp <= 0, nil
+		}
+	case "contains":
+		return exists && containsValue(value, operand), nil
+	default:
+		return false, errors.Errorf("unsupported condition op %q", cond.Op)
+	}
+}
+
+// isTruthy reports whether a JSON-compatible value is considered true.
+func isTruthy(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case []any:
+		return len(v) > 0
+	case map[string]any:
+		return len(v) > 0
+	default:
+		if num, ok := toFloat(v); ok {
+			return num != 0
+		}
+		return true
+	}
+}
+
+// isEmptyValue reports whether value is nil, an empty string, or an empty collection.
+func isEmptyValue(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []any:
+		return len(v) == 0
+	case map[string]any:
+		return len(v) == 0
+	default:
+		return false
+	}
+}
+
+// valuesEqual compares two JSON-compatible values, treating numeric types as equal by value.
+func valuesEqual(left, right any) bool {
+	if lnum, ok := toFloat(left); ok {
+		if rnum, ok := toFloat(right); ok {
+			return lnum == rnum
+		}
+	}
+	return reflect.DeepEqual(normalizeJSONCompatible(left), normalizeJSONCompatible(right))
+}
+
+// compareValues orders two numbers or two strings. It returns false when they are not comparable.
+func compareValues(left, right any) (int, bool) {
+	if lnum, ok := toFloat(left); ok {
+		rnum, ok := toFloat(right)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case lnum < rnum:
+			return -1, true
+		case lnum > rnum:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+
+	lstr, lok := left.(string)
+	rstr, rok := right.(string)
+	if !lok || !rok {
+		return 0, false
+	}
+	return strings.Compare(lstr, rstr), true
+}
+
+// containsValue reports whether haystack contains needle as a substring, element, or key.
+func containsValue(haystack, needle any) bool {
+	switch v := haystack.(type) {
+	case string:
+		return strings.Contains(v, valueToString(needle))
+	case []any:
+		for _, item := range v {
+			if valuesEqual(item, needle) {
+				return true
+			}
+		}
+		return false
+	case map[string]any:
+		_, ok := v[valueToString(needle)]
+		return ok
+	default:
+		return false
+	}
+}
+
+// toFloat converts a numeric value to float64.
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		f, err := strconv.ParseFloat(v.String(), 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// trimPlaceholder strips an optional ${...} wrapper from a path.
+func trimPlaceholder(path string) string {
+	trimmed := strings.TrimSpace(path)
+	if strings.HasPrefix(trimmed, "${") && strings.HasSuffix(trimmed, "}") {
+		trimmed = strings.TrimSpace(trimmed[2 : len(trimmed)-1])
+	}
+	return trimmed
+}
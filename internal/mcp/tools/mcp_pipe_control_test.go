@@ -0,0 +1,237 @@
+package tools
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	mcp "github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Laisky/laisky-blog-graphql/library/log"
+)
+
+// TestMCPPipeIfElse verifies that conditional steps pick the branch matching the predicate.
+func TestMCPPipeIfElse(t *testing.T) {
+	var fetched atomic.Int32
+	invoker := func(ctx context.Context, toolName string, args any) (*mcp.CallToolResult, error) {
+		switch toolName {
+		case "web_search":
+			return mcp.NewToolResultJSON(map[string]any{"results": []any{}})
+		case "web_fetch":
+			fetched.Add(1)
+			return mcp.NewToolResultJSON(map[string]any{"content": "fetched"})
+		case "ask_user":
+			return mcp.NewToolResultJSON(map[string]any{"answer": "fallback"})
+		default:
+			return mcp.NewToolResultError("unknown tool"), nil
+		}
+	}
+
+	tool, err := NewMCPPipeTool(log.Logger.Named("test_mcp_pipe_if"), invoker, PipeLimits{MaxSteps: 10, MaxDepth: 2, MaxParallel: 4})
+	require.NoError(t, err)
+
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"steps": []any{
+			map[string]any{"id": "search", "tool": "web_search", "args": map[string]any{"query": "q"}},
+			map[string]any{
+				"id": "maybe",
+				"if": map[string]any{"ref": "steps.search.structured.results", "op": "not_empty"},
+				"then": map[string]any{"id": "fetch", "tool": "web_fetch", "args": map[string]any{
+					"url": map[string]any{"$ref": "steps.search.structured.results.0.url"},
+				}},
+				"else": map[string]any{"id": "ask", "tool": "ask_user", "args": map[string]any{"question": "no results"}},
+			},
+		},
+		"return": map[string]any{"$ref": "steps.maybe.result.structured.answer"},
+	}}}
+
+	result, handleErr := tool.Handle(context.Background(), req)
+	require.NoError(t, handleErr)
+	require.False(t, result.IsError)
+
+	structured := result.StructuredContent.(map[string]any)
+	require.Equal(t, "fallback", structured["result"])
+	require.Zero(t, fetched.Load())
+
+	steps := structured["steps"].(map[string]any)
+	maybe := steps["maybe"].(map[string]any)
+	require.Equal(t, "if", maybe["kind"])
+	require.Equal(t, false, maybe["condition"])
+	require.Equal(t, "else", maybe["branch"])
+}
+
+// TestMCPPipeIfWithoutElse verifies that a false predicate without else succeeds with a nil result.
+func TestMCPPipeIfWithoutElse(t *testing.T) {
+	invoker := func(ctx context.Context, toolName string, args any) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultError("should not run"), nil
+	}
+
+	tool, err := NewMCPPipeTool(log.Logger.Named("test_mcp_pipe_if_no_else"), invoker, PipeLimits{})
+	require.NoError(t, err)
+
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"vars": map[string]any{"count": 2},
+		"steps": []any{
+			map[string]any{
+				"id":   "guard",
+				"if":   map[string]any{"ref": "vars.count", "op": "gt", "value": 5},
+				"then": map[string]any{"id": "fetch", "tool": "web_fetch", "args": map[string]any{"url": "x"}},
+			},
+		},
+	}}}
+
+	result, handleErr := tool.Handle(context.Background(), req)
+	require.NoError(t, handleErr)
+	require.False(t, result.IsError)
+
+	structured := result.StructuredContent.(map[string]any)
+	last := structured["result"].(map[string]any)
+	require.Equal(t, false, last["condition"])
+	require.Equal(t, "", last["branch"])
+}
+
+// TestMCPPipeForeach verifies that foreach fans a step out over an array and keeps item order.
+func TestMCPPipeForeach(t *testing.T) {
+	invoker := func(ctx context.Context, toolName string, args any) (*mcp.CallToolResult, error) {
+		reqArgs := args.(map[string]any)
+		switch toolName {
+		case "web_search":
+			return mcp.NewToolResultJSON(map[string]any{"results": []any{
+				map[string]any{"url": "https://a"},
+				map[string]any{"url": "https://b"},
+				map[string]any{"url": "https://c"},
+			}})
+		case "web_fetch":
+			return mcp.NewToolResultJSON(map[string]any{"content": "content:" + reqArgs["url"].(string)})
+		default:
+			return mcp.NewToolResultError("unknown tool"), nil
+		}
+	}
+
+	tool, err := NewMCPPipeTool(log.Logger.Named("test_mcp_pipe_foreach"), invoker, PipeLimits{MaxSteps: 10, MaxDepth: 2, MaxParallel: 2})
+	require.NoError(t, err)
+
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"steps": []any{
+			map[string]any{"id": "search", "tool": "web_search", "args": map[string]any{"query": "q"}},
+			map[string]any{"id": "pages", "foreach": map[string]any{
+				"items": map[string]any{"$ref": "steps.search.structured.results"},
+				"as":    "hit",
+				"step":  map[string]any{"id": "fetch", "tool": "web_fetch", "args": map[string]any{"url": "${hit.url}"}},
+			}},
+		},
+		"return": map[string]any{"$ref": "steps.pages.results.2.structured.content"},
+	}}}
+
+	result, handleErr := tool.Handle(context.Background(), req)
+	require.NoError(t, handleErr)
+	require.False(t, result.IsError)
+
+	structured := result.StructuredContent.(map[string]any)
+	require.Equal(t, "content:https://c", structured["result"])
+
+	pages := structured["steps"].(map[string]any)["pages"].(map[string]any)
+	require.Equal(t, "foreach", pages["kind"])
+	require.EqualValues(t, 3, pages["count"])
+}
+
+// TestMCPPipeForeachMaxSteps verifies that foreach iterations count toward MaxSteps.
+func TestMCPPipeForeachMaxSteps(t *testing.T) {
+	var calls atomic.Int32
+	invoker := func(ctx context.Context, toolName string, args any) (*mcp.CallToolResult, error) {
+		calls.Add(1)
+		return mcp.NewToolResultJSON(map[string]any{"ok": true})
+	}
+
+	tool, err := NewMCPPipeTool(log.Logger.Named("test_mcp_pipe_foreach_limit"), invoker, PipeLimits{MaxSteps: 3, MaxDepth: 2, MaxParallel: 4})
+	require.NoError(t, err)
+
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"vars": map[string]any{"urls": []any{"a", "b", "c", "d"}},
+		"steps": []any{
+			map[string]any{"id": "pages", "foreach": map[string]any{
+				"items": map[string]any{"$ref": "vars.urls"},
+				"step":  map[string]any{"id": "fetch", "tool": "web_fetch", "args": map[string]any{"url": "${item}"}},
+			}},
+		},
+	}}}
+
+	result, handleErr := tool.Handle(context.Background(), req)
+	require.NoError(t, handleErr)
+	require.True(t, result.IsError)
+
+	structured := result.StructuredContent.(map[string]any)
+	require.Contains(t, structured["error"], "maximum step limit")
+	require.EqualValues(t, 2, calls.Load())
+}
+
+// TestValidateStepControlFlow verifies shape validation for if and foreach steps.
+func TestValidateStepControlFlow(t *testing.T) {
+	t.Parallel()
+
+	child := &pipeStep{ID: "c", Tool: "web_fetch"}
+	cases := []struct {
+		name    string
+		step    pipeStep
+		wantErr string
+	}{
+		{"if without then", pipeStep{ID: "a", If: &pipeCondition{Ref: "vars.x"}}, "requires then"},
+		{"else without if", pipeStep{ID: "a", Tool: "web_fetch", Else: child}, "require if"},
+		{"if and tool", pipeStep{ID: "a", Tool: "web_fetch", If: &pipeCondition{Ref: "vars.x"}, Then: child}, "exactly one"},
+		{"foreach without step", pipeStep{ID: "a", Foreach: &pipeForeach{Items: []any{}}}, "requires step"},
+		{"foreach reserved as", pipeStep{ID: "a", Foreach: &pipeForeach{Items: []any{}, As: "steps", Step: child}}, "reserved"},
+		{"valid if", pipeStep{ID: "a", If: &pipeCondition{Ref: "vars.x"}, Then: child}, ""},
+		{"valid foreach", pipeStep{ID: "a", Foreach: &pipeForeach{Items: []any{}, Step: child}}, ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := validateStep(tc.step)
+			if tc.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tc.wantErr)
+		})
+	}
+}
+
+// TestEvaluateCondition covers the supported predicate operators.
+func TestEvaluateCondition(t *testing.T) {
+	t.Parallel()
+
+	env := map[string]any{
+		"vars": map[string]any{"n": float64(3), "s": "hello world", "list": []any{"a", "b"}, "empty": ""},
+	}
+	cases := []struct {
+		name string
+		cond pipeCondition
+		want bool
+	}{
+		{"truthy default", pipeCondition{Ref: "vars.s"}, true},
+		{"falsy empty string", pipeCondition{Ref: "vars.empty", Op: "falsy"}, true},
+		{"exists missing", pipeCondition{Ref: "vars.missing", Op: "exists"}, false},
+		{"not_exists missing", pipeCondition{Ref: "vars.missing", Op: "not_exists"}, true},
+		{"eq number", pipeCondition{Ref: "vars.n", Op: "eq", Value: 3}, true},
+		{"gte number", pipeCondition{Ref: "vars.n", Op: "gte", Value: 4}, false},
+		{"contains substring", pipeCondition{Ref: "vars.s", Op: "contains", Value: "world"}, true},
+		{"contains element", pipeCondition{Ref: "vars.list", Op: "contains", Value: "b"}, true},
+		{"all", pipeCondition{All: []pipeCondition{{Ref: "vars.s"}, {Ref: "vars.n", Op: "lt", Value: 10}}}, true},
+		{"any", pipeCondition{Any: []pipeCondition{{Ref: "vars.empty"}, {Ref: "vars.missing"}}}, false},
+		{"not", pipeCondition{Not: &pipeCondition{Ref: "vars.missing"}}, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := evaluateCondition(&tc.cond, env)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+
+	_, err := evaluateCondition(&pipeCondition{Ref: "vars.s", Op: "gt", Value: 1}, env)
+	require.Error(t, err)
+}
@@ -0,0 +1,1346 @@
+package tools
+
+import (
+	"encoding/json"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	errors "github.com/Laisky/errors/v2"
+)
+
+// mcp_pipe expression language.
+//
+// Placeholders (${...}), $ref values, and if predicates are evaluated with a
+// small JMESPath/jq-flavoured language. It is intentionally side-effect free:
+// there are no loops, assignments, or recursion, so evaluation cost is bounded
+// by the size of the input data.
+//
+//	steps.search.structured.results[0].url      field access and indexing
+//	steps.search.structured.results[:3].url     slice projection
+//	steps.search.structured.results[].url       flatten projection
+//	results[?score > `0.5`].url                 filter projection (@ is the element)
+//	steps.a.text | upper(@)                     pipe: the left value becomes @
+//	{url: url, title: title}, [a, b]            multi-select hash and list
+//	default(vars.limit, `10`)                   fallback for missing or null values
+//	length(x) > 0 && !contains(x, 'foo')        comparisons and boolean logic
+//
+// String literals use single quotes, JSON literals use backticks, and double
+// quotes delimit identifiers that contain special characters. $ always refers
+// to the pipeline environment root.
+const (
+	pipeExprMaxLength = 4096
+	pipeExprMaxDepth  = 64
+)
+
+// plainPathStopChars lists characters that switch a placeholder from the
+// legacy dotted-path resolver to the expression parser.
+const plainPathStopChars = "[]()|'\"`?!=<>&{}@$,*: \t\r\n"
+
+// pipeRefError reports a reference that could not be resolved, e.g. a missing
+// key, a non-object segment, or an out-of-range index. Predicates and default()
+// treat it as "value does not exist" rather than as a hard failure.
+type pipeRefError struct {
+	msg string
+}
+
+// Error implements error.
+func (e *pipeRefError) Error() string {
+	return e.msg
+}
+
+// newPipeRefError constructs a pipeRefError.
+func newPipeRefError(msg string) error {
+	return &pipeRefError{msg: msg}
+}
+
+// isPipeRefError reports whether err is (or wraps) a pipeRefError.
+func isPipeRefError(err error) bool {
+	var target *pipeRefError
+	return errors.As(err, &target)
+}
+
+// evaluatePipeExpr evaluates an interpolation expression against env.
+//
+// Plain dotted paths keep the original resolvePath semantics; anything else is
+// parsed as an expression.
+func evaluatePipeExpr(src string, env map[string]any) (any, error) {
+	text := strings.TrimSpace(src)
+	if text == "" {
+		return nil, errors.New("empty reference path")
+	}
+	if !strings.ContainsAny(text, plainPathStopChars) {
+		return resolvePath(text, env)
+	}
+
+	node, err := parsePipeExpr(text)
+	if err != nil {
+		return nil, err
+	}
+	value, err := node.eval(&exprContext{root: env}, env, false)
+	if err != nil {
+		return nil, errors.Wrapf(err, "evaluate expression %q", text)
+	}
+	return value, nil
+}
+
+// parsePipeExpr parses src into an expression tree.
+func parsePipeExpr(src string) (exprNode, error) {
+	if len(src) > pipeExprMaxLength {
+		return nil, errors.Errorf("expression exceeds %d characters", pipeExprMaxLength)
+	}
+
+	tokens, err := lexPipeExpr(src)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse expression %q", src)
+	}
+
+	p := &exprParser{tokens: tokens}
+	node, err := p.parseExpr()
+	if err == nil && p.peek().kind != tokEOF {
+		err = p.unexpected()
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse expression %q", src)
+	}
+	return node, nil
+}
+
+// ---------------------------------------------------------------------------
+// lexer
+// ---------------------------------------------------------------------------
+
+type exprTokenKind int
+
+const (
+	tokEOF exprTokenKind = iota
+	tokIdent
+	tokQuotedIdent
+	tokString
+	tokNumber
+	tokLiteral
+	tokPunct
+)
+
+// exprToken is a lexed expression token.
+type exprToken struct {
+	kind  exprTokenKind
+	text  string
+	value any
+	pos   int
+}
+
+// exprPuncts lists punctuation tokens, longest first so that "==" wins over "=".
+var exprPuncts = []string{"||", "&&", "==", "!=", "<=", ">=", ".", "[", "]", "(", ")", "{", "}", ",", ":", "|", "!", "<", ">", "?", "@", "$", "*"}
+
+// lexPipeExpr splits src into tokens.
+func lexPipeExpr(src string) ([]exprToken, error) {
+	var tokens []exprToken
+	i := 0
+	for i < len(src) {
+		r, size := utf8.DecodeRuneInString(src[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r == '\'' || r == '"' || r == '`':
+			end, text, err := scanQuoted(src, i)
+			if err != nil {
+				return nil, err
+			}
+			tok := exprToken{text: text, pos: i}
+			switch r {
+			case '\'':
+				tok.kind = tokString
+				tok.value = text
+			case '"':
+				tok.kind = tokQuotedIdent
+			default:
+				var decoded any
+				if err := json.Unmarshal([]byte(text), &decoded); err != nil {
+					return nil, errors.Errorf("invalid JSON literal at %d", i)
+				}
+				tok.kind = tokLiteral
+				tok.value = decoded
+			}
+			tokens = append(tokens, tok)
+			i = end
+		case isDigit(r) || (r == '-' && i+1 < len(src) && isDigit(rune(src[i+1]))):
+			// A number right after "." is an array index, so "a.0.1" is two segments.
+			afterDot := len(tokens) > 0 && tokens[len(tokens)-1].kind == tokPunct && tokens[len(tokens)-1].text == "."
+			j := i + 1
+			for j < len(src) && isDigit(rune(src[j])) {
+				j++
+			}
+			if !afterDot && j+1 < len(src) && src[j] == '.' && isDigit(rune(src[j+1])) {
+				j++
+				for j < len(src) && isDigit(rune(src[j])) {
+					j++
+				}
+			}
+			num, err := strconv.ParseFloat(src[i:j], 64)
+			if err != nil {
+				return nil, errors.Errorf("invalid number %q at %d", src[i:j], i)
+			}
+			tokens = append(tokens, exprToken{kind: tokNumber, text: src[i:j], value: num, pos: i})
+			i = j
+		case r == '_' || unicode.IsLetter(r):
+			j := i
+			for j < len(src) {
+				rr, sz := utf8.DecodeRuneInString(src[j:])
+				if rr != '_' && rr != '-' && !unicode.IsLetter(rr) && !unicode.IsDigit(rr) {
+					break
+				}
+				j += sz
+			}
+			tokens = append(tokens, exprToken{kind: tokIdent, text: src[i:j], pos: i})
+			i = j
+		default:
+			matched := false
+			for _, p := range exprPuncts {
+				if strings.HasPrefix(src[i:], p) {
+					tokens = append(tokens, exprToken{kind: tokPunct, text: p, pos: i})
+					i += len(p)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, errors.Errorf("unexpected character %q at %d", r, i)
+			}
+		}
+	}
+	return append(tokens, exprToken{kind: tokEOF, pos: len(src)}), nil
+}
+
+// scanQuoted scans a quoted token starting at src[start]. A backslash escapes the quote character.
+func scanQuoted(src string, start int) (int, string, error) {
+	quote := src[start]
+	var b strings.Builder
+	for i := start + 1; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			if i+1 < len(src) && (src[i+1] == quote || src[i+1] == '\\') {
+				b.WriteByte(src[i+1])
+				i++
+				continue
+			}
+			b.WriteByte(src[i])
+		case quote:
+			return i + 1, b.String(), nil
+		default:
+			b.WriteByte(src[i])
+		}
+	}
+	return 0, "", errors.Errorf("unterminated %c at %d", quote, start)
+}
+
+// isDigit reports whether r is an ASCII digit.
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// ---------------------------------------------------------------------------
+// parser
+// ---------------------------------------------------------------------------
+
+// exprParser is a recursive-descent parser over lexed tokens.
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+	depth  int
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) peekAt(offset int) exprToken {
+	if p.pos+offset >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.pos+offset]
+}
+
+func (p *exprParser) next() exprToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// isPunct reports whether the next token is the punctuation text.
+func (p *exprParser) isPunct(text string) bool {
+	tok := p.peek()
+	return tok.kind == tokPunct && tok.text == text
+}
+
+func (p *exprParser) expect(text string) error {
+	if !p.isPunct(text) {
+		return errors.Errorf("expected %q at %d", text, p.peek().pos)
+	}
+	p.next()
+	return nil
+}
+
+func (p *exprParser) unexpected() error {
+	tok := p.peek()
+	if tok.kind == tokEOF {
+		return errors.New("unexpected end of expression")
+	}
+	return errors.Errorf("unexpected token %q at %d", tok.text, tok.pos)
+}
+
+// enter guards against pathological nesting.
+func (p *exprParser) enter() error {
+	p.depth++
+	if p.depth > pipeExprMaxDepth {
+		return errors.New("expression nesting too deep")
+	}
+	return nil
+}
+
+func (p *exprParser) leave() {
+	p.depth--
+}
+
+// parseExpr parses a full expression: or-expressions joined by "|".
+func (p *exprParser) parseExpr() (exprNode, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	left, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	for p.isPunct("|") {
+		p.next()
+		right, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		left = &pipeNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isPunct("||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicNode{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isPunct("&&") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicNode{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseNot() (exprNode, error) {
+	if p.isPunct("!") {
+		p.next()
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+	tok := p.peek()
+	if tok.kind == tokPunct {
+		switch tok.text {
+		case "==", "!=", "<", "<=", ">", ">=":
+			p.next()
+			right, err := p.parsePostfix()
+			if err != nil {
+				return nil, err
+			}
+			return &compareNode{op: tok.text, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parsePostfix() (exprNode, error) {
+	node, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	return p.parseChain(node)
+}
+
+// parseChain parses trailing ".field", ".0", ".{...}", ".[...]", and "[...]" operators.
+// A projection consumes the rest of the chain as its per-element expression.
+func (p *exprParser) parseChain(node exprNode) (exprNode, error) {
+	for {
+		switch {
+		case p.isPunct("."):
+			p.next()
+			tok := p.next()
+			switch tok.kind {
+			case tokIdent, tokQuotedIdent:
+				node = &subNode{left: node, right: &fieldNode{name: tok.text}}
+			case tokNumber:
+				idx, err := tokenIndex(tok)
+				if err != nil {
+					return nil, err
+				}
+				node = &subNode{left: node, right: &indexNode{index: idx}}
+			case tokPunct:
+				// ".{k: expr}" and ".[a, b]" select from the current value.
+				var selector exprNode
+				var err error
+				switch tok.text {
+				case "{":
+					selector, err = p.parseHash()
+				case "[":
+					var items []exprNode
+					items, err = p.parseList("]")
+					selector = &listNode{items: items}
+				default:
+					p.pos--
+					return nil, p.unexpected()
+				}
+				if err != nil {
+					return nil, err
+				}
+				node = &subNode{left: node, right: selector}
+			default:
+				p.pos--
+				return nil, p.unexpected()
+			}
+		case p.isPunct("["):
+			p.next()
+			projected, done, err := p.parseBracket(node)
+			if err != nil {
+				return nil, err
+			}
+			if done {
+				return projected, nil
+			}
+			node = projected
+		default:
+			return node, nil
+		}
+	}
+}
+
+// parseBracket parses the body of "[...]" applied to node. It reports done=true
+// when the result is a projection that already consumed the rest of the chain.
+func (p *exprParser) parseBracket(node exprNode) (exprNode, bool, error) {
+	switch {
+	case p.isPunct("]"):
+		p.next()
+		rhs, err := p.parseProjectionRHS()
+		return &projectionNode{left: node, right: rhs, flatten: true}, true, err
+	case p.isPunct("*") && p.peekAt(1).kind == tokPunct && p.peekAt(1).text == "]":
+		p.next()
+		p.next()
+		rhs, err := p.parseProjectionRHS()
+		return &projectionNode{left: node, right: rhs}, true, err
+	case p.isPunct("?"):
+		p.next()
+		filter, err := p.parseOr()
+		if err != nil {
+			return nil, false, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, false, err
+		}
+		rhs, err := p.parseProjectionRHS()
+		return &projectionNode{left: node, right: rhs, filter: filter}, true, err
+	}
+
+	var bounds [3]*int
+	slot := 0
+	isSlice := false
+	for {
+		tok := p.peek()
+		switch {
+		case tok.kind == tokNumber:
+			p.next()
+			idx, err := tokenIndex(tok)
+			if err != nil {
+				return nil, false, err
+			}
+			if bounds[slot] != nil {
+				return nil, false, p.unexpected()
+			}
+			bounds[slot] = &idx
+		case p.isPunct(":"):
+			p.next()
+			isSlice = true
+			slot++
+			if slot > 2 {
+				return nil, false, errors.Errorf("too many ':' in slice at %d", tok.pos)
+			}
+		case p.isPunct("]"):
+			p.next()
+			if !isSlice {
+				if bounds[0] == nil {
+					return nil, false, errors.Errorf("empty index at %d", tok.pos)
+				}
+				return &subNode{left: node, right: &indexNode{index: *bounds[0]}}, false, nil
+			}
+			if bounds[2] != nil && *bounds[2] == 0 {
+				return nil, false, errors.New("slice step cannot be 0")
+			}
+			sliced := &subNode{left: node, right: &sliceNode{start: bounds[0], stop: bounds[1], step: bounds[2]}}
+			rhs, err := p.parseProjectionRHS()
+			return &projectionNode{left: sliced, right: rhs}, true, err
+		default:
+			return nil, false, errors.Errorf("expected index, slice, [], [*] or [?filter] at %d", tok.pos)
+		}
+	}
+}
+
+// parseProjectionRHS parses the chain applied to each projected element.
+func (p *exprParser) parseProjectionRHS() (exprNode, error) {
+	if !p.isPunct(".") && !p.isPunct("[") {
+		return nil, nil //nolint:nilnil // no per-element expression means identity
+	}
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	return p.parseChain(currentNode{})
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	tok := p.peek()
+	switch tok.kind {
+	case tokEOF:
+		return nil, p.unexpected()
+	case tokIdent:
+		p.next()
+		if p.isPunct("(") {
+			return p.parseCall(tok)
+		}
+		switch tok.text {
+		case "true":
+			return literalNode{value: true}, nil
+		case "false":
+			return literalNode{value: false}, nil
+		case "null":
+			return literalNode{value: nil}, nil
+		}
+		return &fieldNode{name: tok.text}, nil
+	case tokQuotedIdent:
+		p.next()
+		return &fieldNode{name: tok.text}, nil
+	case tokString, tokNumber, tokLiteral:
+		p.next()
+		return literalNode{value: tok.value}, nil
+	case tokPunct:
+	}
+
+	switch tok.text {
+	case "@":
+		p.next()
+		return currentNode{}, nil
+	case "$":
+		p.next()
+		return rootNode{}, nil
+	case "(":
+		p.next()
+		inner, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	case "[":
+		// A leading bracket operator applies to the current value: "[:3]", "[0]", "[?x]".
+		next := p.peekAt(1)
+		if next.kind == tokNumber || (next.kind == tokPunct && (next.text == ":" || next.text == "]" || next.text == "*" || next.text == "?")) {
+			return currentNode{}, nil
+		}
+		p.next()
+		items, err := p.parseList("]")
+		if err != nil {
+			return nil, err
+		}
+		return &listNode{items: items}, nil
+	case "{":
+		p.next()
+		return p.parseHash()
+	}
+	return nil, p.unexpected()
+}
+
+// parseCall parses a function call after its name.
+func (p *exprParser) parseCall(name exprToken) (exprNode, error) {
+	fn, ok := pipeExprFuncs[name.text]
+	if !ok && name.text != "default" {
+		return nil, errors.Errorf("unknown function %q at %d", name.text, name.pos)
+	}
+	p.next() // "("
+	args, err := p.parseList(")")
+	if err != nil {
+		return nil, err
+	}
+	if name.text == "default" {
+		if len(args) != 2 {
+			return nil, errors.New("function default expects 2 arguments")
+		}
+		return &defaultNode{value: args[0], fallback: args[1]}, nil
+	}
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return nil, errors.Errorf("function %s: wrong number of arguments (%d)", name.text, len(args))
+	}
+	return &callNode{name: name.text, fn: fn, args: args}, nil
+}
+
+// parseList parses comma-separated expressions until the closing punctuation.
+func (p *exprParser) parseList(closing string) ([]exprNode, error) {
+	var items []exprNode
+	if p.isPunct(closing) {
+		p.next()
+		return items, nil
+	}
+	for {
+		item, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if p.isPunct(",") {
+			p.next()
+			continue
+		}
+		if err := p.expect(closing); err != nil {
+			return nil, err
+		}
+		return items, nil
+	}
+}
+
+// parseHash parses a multi-select hash body after "{".
+func (p *exprParser) parseHash() (exprNode, error) {
+	node := &hashNode{}
+	if p.isPunct("}") {
+		p.next()
+		return node, nil
+	}
+	for {
+		key := p.next()
+		if key.kind != tokIdent && key.kind != tokQuotedIdent && key.kind != tokString {
+			p.pos--
+			return nil, p.unexpected()
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		value, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		node.keys = append(node.keys, key.text)
+		node.values = append(node.values, value)
+		if p.isPunct(",") {
+			p.next()
+			continue
+		}
+		if err := p.expect("}"); err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+}
+
+// tokenIndex converts a number token into an integer index.
+func tokenIndex(tok exprToken) (int, error) {
+	num, _ := tok.value.(float64)
+	if num != math.Trunc(num) || math.Abs(num) > math.MaxInt32 {
+		return 0, errors.Errorf("invalid index %q at %d", tok.text, tok.pos)
+	}
+	return int(num), nil
+}
+
+// ---------------------------------------------------------------------------
+// evaluation
+// ---------------------------------------------------------------------------
+
+// exprContext carries evaluation-wide state.
+type exprContext struct {
+	root map[string]any
+}
+
+// exprNode is an evaluable expression.
+//
+// lenient is true inside projections and filters, where missing fields
+// evaluate to null instead of failing (JMESPath semantics). At the top level
+// lookups are strict so that typos in step IDs surface as errors.
+type exprNode interface {
+	eval(ctx *exprContext, current any, lenient bool) (any, error)
+}
+
+type currentNode struct{}
+
+func (currentNode) eval(_ *exprContext, current any, _ bool) (any, error) {
+	return current, nil
+}
+
+type rootNode struct{}
+
+func (rootNode) eval(ctx *exprContext, _ any, _ bool) (any, error) {
+	return ctx.root, nil
+}
+
+type literalNode struct {
+	value any
+}
+
+func (n literalNode) eval(*exprContext, any, bool) (any, error) {
+	return n.value, nil
+}
+
+type fieldNode struct {
+	name string
+}
+
+func (n *fieldNode) eval(_ *exprContext, current any, lenient bool) (any, error) {
+	m, ok := current.(map[string]any)
+	if !ok {
+		if lenient {
+			return nil, nil //nolint:nilnil // lenient lookups yield null
+		}
+		return nil, newPipeRefError("reference segment is not an object: " + n.name)
+	}
+	value, ok := m[n.name]
+	if !ok && !lenient {
+		return nil, newPipeRefError("reference not found: " + n.name)
+	}
+	return value, nil
+}
+
+type indexNode struct {
+	index int
+}
+
+func (n *indexNode) eval(_ *exprContext, current any, lenient bool) (any, error) {
+	arr, ok := current.([]any)
+	if !ok {
+		if lenient {
+			return nil, nil //nolint:nilnil // lenient lookups yield null
+		}
+		return nil, newPipeRefError("reference segment is not an array: " + strconv.Itoa(n.index))
+	}
+	idx := n.index
+	if idx < 0 {
+		idx += len(arr)
+	}
+	if idx < 0 || idx >= len(arr) {
+		if lenient {
+			return nil, nil //nolint:nilnil // lenient lookups yield null
+		}
+		return nil, newPipeRefError("reference index out of range: " + strconv.Itoa(n.index))
+	}
+	return arr[idx], nil
+}
+
+type sliceNode struct {
+	start, stop, step *int
+}
+
+func (n *sliceNode) eval(_ *exprContext, current any, lenient bool) (any, error) {
+	if s, ok := current.(string); ok {
+		runes := []rune(s)
+		items := make([]any, len(runes))
+		for i, r := range runes {
+			items[i] = string(r)
+		}
+		sliced := sliceValues(items, n.start, n.stop, n.step)
+		var b strings.Builder
+		for _, item := range sliced {
+			b.WriteString(item.(string))
+		}
+		return b.String(), nil
+	}
+
+	arr, ok := current.([]any)
+	if !ok {
+		if lenient {
+			return nil, nil //nolint:nilnil // lenient lookups yield null
+		}
+		return nil, newPipeRefError("slice target is not an array")
+	}
+	return sliceValues(arr, n.start, n.stop, n.step), nil
+}
+
+// sliceValues applies Python-style slice bounds to arr.
+func sliceValues(arr []any, start, stop, step *int) []any {
+	stepVal := 1
+	if step != nil {
+		stepVal = *step
+	}
+	length := len(arr)
+	clamp := func(v *int, def int) int {
+		if v == nil {
+			return def
+		}
+		idx := *v
+		if idx < 0 {
+			idx += length
+		}
+		if stepVal > 0 {
+			return min(max(idx, 0), length)
+		}
+		return min(max(idx, -1), length-1)
+	}
+
+	out := []any{}
+	if stepVal > 0 {
+		for i := clamp(start, 0); i < clamp(stop, length); i += stepVal {
+			out = append(out, arr[i])
+		}
+		return out
+	}
+	for i := clamp(start, length-1); i > clamp(stop, -1); i += stepVal {
+		out = append(out, arr[i])
+	}
+	return out
+}
+
+// subNode evaluates right with the result of left as the current value.
+type subNode struct {
+	left, right exprNode
+}
+
+func (n *subNode) eval(ctx *exprContext, current any, lenient bool) (any, error) {
+	left, err := n.left.eval(ctx, current, lenient)
+	if err != nil {
+		return nil, err
+	}
+	if left == nil && lenient {
+		return nil, nil //nolint:nilnil // lenient lookups yield null
+	}
+	return n.right.eval(ctx, left, lenient)
+}
+
+// projectionNode maps right over each element of left, dropping null results.
+type projectionNode struct {
+	left    exprNode
+	right   exprNode
+	filter  exprNode
+	flatten bool
+}
+
+func (n *projectionNode) eval(ctx *exprContext, current any, lenient bool) (any, error) {
+	left, err := n.left.eval(ctx, current, lenient)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []any
+	switch v := left.(type) {
+	case []any:
+		items = v
+	case string:
+		// A string slice ("vars.q[:2]") has nothing to project over.
+		if n.right == nil && n.filter == nil && !n.flatten {
+			return v, nil
+		}
+		return nil, projectionTypeError(lenient)
+	case map[string]any:
+		if n.flatten || n.filter != nil {
+			return nil, projectionTypeError(lenient)
+		}
+		// "[*]" over an object projects its values in key order.
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			items = append(items, v[key])
+		}
+	default:
+		return nil, projectionTypeError(lenient)
+	}
+
+	if n.flatten {
+		flat := make([]any, 0, len(items))
+		for _, item := range items {
+			if nested, ok := item.([]any); ok {
+				flat = append(flat, nested...)
+				continue
+			}
+			flat = append(flat, item)
+		}
+		items = flat
+	}
+
+	out := make([]any, 0, len(items))
+	for _, item := range items {
+		if n.filter != nil {
+			keep, err := n.filter.eval(ctx, item, true)
+			if err != nil {
+				return nil, err
+			}
+			if !isTruthy(keep) {
+				continue
+			}
+		}
+		value := item
+		if n.right != nil {
+			value, err = n.right.eval(ctx, item, true)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if value != nil {
+			out = append(out, value)
+		}
+	}
+	return out, nil
+}
+
+// projectionTypeError returns nil in lenient mode, or a reference error otherwise.
+func projectionTypeError(lenient bool) error {
+	if lenient {
+		return nil
+	}
+	return newPipeRefError("projection target is not an array")
+}
+
+// pipeNode evaluates right with the result of left as @.
+type pipeNode struct {
+	left, right exprNode
+}
+
+func (n *pipeNode) eval(ctx *exprContext, current any, lenient bool) (any, error) {
+	left, err := n.left.eval(ctx, current, lenient)
+	if err != nil {
+		return nil, err
+	}
+	return n.right.eval(ctx, left, lenient)
+}
+
+type logicNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *logicNode) eval(ctx *exprContext, current any, lenient bool) (any, error) {
+	left, err := n.left.eval(ctx, current, lenient)
+	if err != nil {
+		return nil, err
+	}
+	// Like JMESPath, && and || return operands rather than coercing to bool.
+	if n.op == "&&" && !isTruthy(left) {
+		return left, nil
+	}
+	if n.op == "||" && isTruthy(left) {
+		return left, nil
+	}
+	return n.right.eval(ctx, current, lenient)
+}
+
+type notNode struct {
+	operand exprNode
+}
+
+func (n *notNode) eval(ctx *exprContext, current any, lenient bool) (any, error) {
+	value, err := n.operand.eval(ctx, current, lenient)
+	if err != nil {
+		return nil, err
+	}
+	return !isTruthy(value), nil
+}
+
+type compareNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *compareNode) eval(ctx *exprContext, current any, lenient bool) (any, error) {
+	left, err := n.left.eval(ctx, current, lenient)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(ctx, current, lenient)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return valuesEqual(left, right), nil
+	case "!=":
+		return !valuesEqual(left, right), nil
+	}
+
+	// Ordering across incompatible types is false rather than an error, so
+	// filters over heterogeneous data behave predictably.
+	cmp, ok := compareValues(left, right)
+	if !ok {
+		return false, nil
+	}
+	switch n.op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+type listNode struct {
+	items []exprNode
+}
+
+func (n *listNode) eval(ctx *exprContext, current any, lenient bool) (any, error) {
+	out := make([]any, 0, len(n.items))
+	for _, item := range n.items {
+		value, err := item.eval(ctx, current, lenient)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, value)
+	}
+	return out, nil
+}
+
+type hashNode struct {
+	keys   []string
+	values []exprNode
+}
+
+func (n *hashNode) eval(ctx *exprContext, current any, lenient bool) (any, error) {
+	out := make(map[string]any, len(n.keys))
+	for i, key := range n.keys {
+		value, err := n.values[i].eval(ctx, current, lenient)
+		if err != nil {
+			return nil, err
+		}
+		out[key] = value
+	}
+	return out, nil
+}
+
+// defaultNode returns fallback when value is missing or null.
+type defaultNode struct {
+	value, fallback exprNode
+}
+
+func (n *defaultNode) eval(ctx *exprContext, current any, lenient bool) (any, error) {
+	value, err := n.value.eval(ctx, current, lenient)
+	if err != nil && !isPipeRefError(err) {
+		return nil, err
+	}
+	if err == nil && value != nil {
+		return value, nil
+	}
+	return n.fallback.eval(ctx, current, lenient)
+}
+
+type callNode struct {
+	name string
+	fn   pipeExprFunc
+	args []exprNode
+}
+
+func (n *callNode) eval(ctx *exprContext, current any, lenient bool) (any, error) {
+	args := make([]any, 0, len(n.args))
+	for _, arg := range n.args {
+		value, err := arg.eval(ctx, current, lenient)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, value)
+	}
+	out, err := n.fn.call(args)
+	if err != nil {
+		return nil, errors.Wrapf(err, "function %s", n.name)
+	}
+	return out, nil
+}
+
+// ---------------------------------------------------------------------------
+// functions
+// ---------------------------------------------------------------------------
+
+// pipeExprFunc describes a built-in function. maxArgs < 0 means variadic.
+type pipeExprFunc struct {
+	minArgs int
+	maxArgs int
+	call    func(args []any) (any, error)
+}
+
+// pipeExprFuncs is the built-in function table. default() is handled by the
+// parser because it evaluates its arguments lazily.
+var pipeExprFuncs = map[string]pipeExprFunc{
+	"length": {1, 1, func(args []any) (any, error) {
+		switch v := args[0].(type) {
+		case string:
+			return float64(utf8.RuneCountInString(v)), nil
+		case []any:
+			return float64(len(v)), nil
+		case map[string]any:
+			return float64(len(v)), nil
+		case nil:
+			return float64(0), nil
+		default:
+			return nil, typeMismatch("string, array or object", v)
+		}
+	}},
+	"upper": stringFunc(strings.ToUpper),
+	"lower": stringFunc(strings.ToLower),
+	"trim":  stringFunc(strings.TrimSpace),
+	"split": {2, 2, func(args []any) (any, error) {
+		s, sep, err := twoStrings(args)
+		if err != nil {
+			return nil, err
+		}
+		parts := strings.Split(s, sep)
+		out := make([]any, len(parts))
+		for i, part := range parts {
+			out[i] = part
+		}
+		return out, nil
+	}},
+	"join": {2, 2, func(args []any) (any, error) {
+		sep, ok := args[0].(string)
+		if !ok {
+			return nil, typeMismatch("string separator", args[0])
+		}
+		arr, ok := args[1].([]any)
+		if !ok {
+			return nil, typeMismatch("array", args[1])
+		}
+		parts := make([]string, 0, len(arr))
+		for _, item := range arr {
+			parts = append(parts, valueToString(item))
+		}
+		return strings.Join(parts, sep), nil
+	}},
+	"replace": {3, 3, func(args []any) (any, error) {
+		s, old, err := twoStrings(args[:2])
+		if err != nil {
+			return nil, err
+		}
+		repl, ok := args[2].(string)
+		if !ok {
+			return nil, typeMismatch("string", args[2])
+		}
+		return strings.ReplaceAll(s, old, repl), nil
+	}},
+	"contains": {2, 2, func(args []any) (any, error) {
+		return containsValue(args[0], args[1]), nil
+	}},
+	"starts_with": {2, 2, func(args []any) (any, error) {
+		s, prefix, err := twoStrings(args)
+		if err != nil {
+			return nil, err
+		}
+		return strings.HasPrefix(s, prefix), nil
+	}},
+	"ends_with": {2, 2, func(args []any) (any, error) {
+		s, suffix, err := twoStrings(args)
+		if err != nil {
+			return nil, err
+		}
+		return strings.HasSuffix(s, suffix), nil
+	}},
+	"truncate": {2, 2, func(args []any) (any, error) {
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, typeMismatch("string", args[0])
+		}
+		limit, ok := toFloat(args[1])
+		if !ok || limit < 0 {
+			return nil, typeMismatch("non-negative number", args[1])
+		}
+		n := int(limit)
+		for i := range s {
+			if n == 0 {
+				return s[:i], nil
+			}
+			n--
+		}
+		return s, nil
+	}},
+	"concat": {1, -1, func(args []any) (any, error) {
+		var b strings.Builder
+		for _, arg := range args {
+			b.WriteString(valueToString(arg))
+		}
+		return b.String(), nil
+	}},
+	"to_string": {1, 1, func(args []any) (any, error) {
+		return valueToString(args[0]), nil
+	}},
+	"to_number": {1, 1, func(args []any) (any, error) {
+		if num, ok := toFloat(args[0]); ok {
+			return num, nil
+		}
+		if s, ok := args[0].(string); ok {
+			if num, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+				return num, nil
+			}
+		}
+		return nil, nil //nolint:nilnil // JMESPath returns null for non-numeric input
+	}},
+	"keys": {1, 1, func(args []any) (any, error) {
+		m, ok := args[0].(map[string]any)
+		if !ok {
+			return nil, typeMismatch("object", args[0])
+		}
+		keys := mapKeys(m)
+		out := make([]any, len(keys))
+		for i, key := range keys {
+			out[i] = key
+		}
+		return out, nil
+	}},
+	"values": {1, 1, func(args []any) (any, error) {
+		m, ok := args[0].(map[string]any)
+		if !ok {
+			return nil, typeMismatch("object", args[0])
+		}
+		keys := mapKeys(m)
+		out := make([]any, len(keys))
+		for i, key := range keys {
+			out[i] = m[key]
+		}
+		return out, nil
+	}},
+	"first": {1, 1, func(args []any) (any, error) {
+		arr, ok := args[0].([]any)
+		if !ok {
+			return nil, typeMismatch("array", args[0])
+		}
+		if len(arr) == 0 {
+			return nil, nil //nolint:nilnil // first of empty array is null
+		}
+		return arr[0], nil
+	}},
+	"last": {1, 1, func(args []any) (any, error) {
+		arr, ok := args[0].([]any)
+		if !ok {
+			return nil, typeMismatch("array", args[0])
+		}
+		if len(arr) == 0 {
+			return nil, nil //nolint:nilnil // last of empty array is null
+		}
+		return arr[len(arr)-1], nil
+	}},
+	"unique": {1, 1, func(args []any) (any, error) {
+		arr, ok := args[0].([]any)
+		if !ok {
+			return nil, typeMismatch("array", args[0])
+		}
+		out := make([]any, 0, len(arr))
+		for _, item := range arr {
+			if !slices.ContainsFunc(out, func(seen any) bool { return valuesEqual(seen, item) }) {
+				out = append(out, item)
+			}
+		}
+		return out, nil
+	}},
+	"not_null": {1, -1, func(args []any) (any, error) {
+		for _, arg := range args {
+			if arg != nil {
+				return arg, nil
+			}
+		}
+		return nil, nil //nolint:nilnil // all arguments null
+	}},
+}
+
+// stringFunc adapts a string transform into a single-argument function.
+func stringFunc(transform func(string) string) pipeExprFunc {
+	return pipeExprFunc{1, 1, func(args []any) (any, error) {
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, typeMismatch("string", args[0])
+		}
+		return transform(s), nil
+	}}
+}
+
+// twoStrings asserts that both arguments are strings.
+func twoStrings(args []any) (string, string, error) {
+	a, ok := args[0].(string)
+	if !ok {
+		return "", "", typeMismatch("string", args[0])
+	}
+	b, ok := args[1].(string)
+	if !ok {
+		return "", "", typeMismatch("string", args[1])
+	}
+	return a, b, nil
+}
+
+// typeMismatch builds an argument type error.
+func typeMismatch(want string, got any) error {
+	return errors.Errorf("expected %s, got %s", want, jsonTypeName(got))
+}
+
+// jsonTypeName names the JSON type of a value for error messages.
+func jsonTypeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		if _, ok := toFloat(value); ok {
+			return "number"
+		}
+		return "unknown"
+	}
+}
@@ -0,0 +1,232 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	mcp "github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Laisky/laisky-blog-graphql/library/log"
+)
+
+// exprTestEnv returns a pipeline environment shaped like real step results.
+func exprTestEnv() map[string]any {
+	return map[string]any{
+		"vars": map[string]any{"q": "golang", "limit": float64(2), "tags": []any{"a", "b", "a"}},
+		"steps": map[string]any{
+			"search": map[string]any{
+				"ok": true,
+				"structured": map[string]any{
+					"results": []any{
+						map[string]any{"url": "https://a", "title": "Alpha", "score": 0.9},
+						map[string]any{"url": "https://b", "title": "Beta", "score": 0.4},
+						map[string]any{"url": "https://c", "title": "Gamma", "score": 0.7},
+						map[string]any{"title": "No URL", "score": 0.8},
+					},
+				},
+			},
+			"fetch-1": map[string]any{"text": "  Hello World  "},
+		},
+	}
+}
+
+// TestEvaluatePipeExpr covers the supported expression forms.
+func TestEvaluatePipeExpr(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		expr string
+		want any
+	}{
+		{"legacy dotted path", "steps.search.structured.results.0.url", "https://a"},
+		{"bracket index", "steps.search.structured.results[1].url", "https://b"},
+		{"negative index", "steps.search.structured.results[-1].title", "No URL"},
+		{"slice projection", "steps.search.structured.results[:3].url", []any{"https://a", "https://b", "https://c"}},
+		{"flatten projection drops nulls", "steps.search.structured.results[].url", []any{"https://a", "https://b", "https://c"}},
+		{"filter projection", "steps.search.structured.results[?score > `0.5`].title", []any{"Alpha", "Gamma", "No URL"}},
+		{"filter with root reference", "steps.search.structured.results[?title == $.vars.q]", []any{}},
+		{"pipe then slice", "steps.search.structured.results[].url | [:2]", []any{"https://a", "https://b"}},
+		{"pipe then index", "steps.search.structured.results[].url | [0]", "https://a"},
+		{"hyphenated step id", "steps.fetch-1.text | trim(@) | upper(@)", "HELLO WORLD"},
+		{"quoted identifier", `steps."fetch-1".text | trim(@)`, "Hello World"},
+		{"length comparison", "length(steps.search.structured.results) >= `4`", true},
+		{"join", "join(', ', steps.search.structured.results[:2].title)", "Alpha, Beta"},
+		{"split", "split('a,b', ',')", []any{"a", "b"}},
+		{"replace", "replace(vars.q, 'go', 'Go')", "Golang"},
+		{"contains and not", "contains(vars.q, 'go') && !starts_with(vars.q, 'x')", true},
+		{"default missing", "default(vars.missing, 'fallback')", "fallback"},
+		{"default present", "default(vars.limit, `10`)", float64(2)},
+		{"hash projection", "steps.search.structured.results[:2] | [].{url: url}", []any{map[string]any{"url": "https://a"}, map[string]any{"url": "https://b"}}},
+		{"multi-select list", "[vars.q, vars.limit]", []any{"golang", float64(2)}},
+		{"unique", "unique(vars.tags)", []any{"a", "b"}},
+		{"to_number", "to_number('3.5')", 3.5},
+		{"or returns operand", "default(vars.missing, null) || vars.q", "golang"},
+		{"truncate", "truncate(steps.search.structured.results[0].title, `3`)", "Alp"},
+		{"first and last", "[first(vars.tags), last(vars.tags)]", []any{"a", "a"}},
+		{"string slice", "vars.q[:2]", "go"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := evaluatePipeExpr(tc.expr, exprTestEnv())
+			require.NoError(t, err, "expression %q", tc.expr)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+// TestEvaluatePipeExprHash verifies multi-select hashes over a single element.
+func TestEvaluatePipeExprHash(t *testing.T) {
+	t.Parallel()
+
+	got, err := evaluatePipeExpr("steps.search.structured.results[0].{u: url, t: title}", exprTestEnv())
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"u": "https://a", "t": "Alpha"}, got)
+}
+
+// TestEvaluatePipeExprErrors verifies that syntax, reference, and type errors are reported.
+func TestEvaluatePipeExprErrors(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		expr    string
+		wantErr string
+		isRef   bool
+	}{
+		{"missing field", "steps.nope.structured", "reference not found: nope", true},
+		{"index out of range", "steps.search.structured.results[10]", "out of range", true},
+		{"unknown function", "shout(vars.q)", "unknown function", false},
+		{"bad arity", "upper(vars.q, vars.q)", "wrong number of arguments", false},
+		{"type mismatch", "upper(vars.limit)", "expected string, got number", false},
+		{"unterminated string", "upper('abc)", "unterminated", false},
+		{"trailing token", "vars.q vars.q", "unexpected token", false},
+		{"zero slice step", "vars.tags[::0]", "step cannot be 0", false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := evaluatePipeExpr(tc.expr, exprTestEnv())
+			require.ErrorContains(t, err, tc.wantErr)
+			require.Equal(t, tc.isRef, isPipeRefError(err))
+		})
+	}
+}
+
+// TestFindPlaceholders verifies brace-balanced, quote-aware placeholder scanning.
+func TestFindPlaceholders(t *testing.T) {
+	t.Parallel()
+
+	s := "a ${vars.q} b ${ {u: vars.q} } c ${join('}', vars.tags)} d ${} e ${unterminated"
+	matches := findPlaceholders(s)
+	got := make([]string, 0, len(matches))
+	for _, m := range matches {
+		got = append(got, s[m[0]:m[1]])
+	}
+	require.Equal(t, []string{"${vars.q}", "${ {u: vars.q} }", "${join('}', vars.tags)}"}, got)
+}
+
+// TestMCPPipeExpressionReturn verifies that spec.return can shape a compact payload with expressions.
+func TestMCPPipeExpressionReturn(t *testing.T) {
+	invoker := func(ctx context.Context, toolName string, args any) (*mcp.CallToolResult, error) {
+		reqArgs := args.(map[string]any)
+		switch toolName {
+		case "web_search":
+			return mcp.NewToolResultJSON(map[string]any{"results": []any{
+				map[string]any{"url": "https://a"},
+				map[string]any{"url": "https://b"},
+				map[string]any{"url": "https://c"},
+				map[string]any{"url": "https://d"},
+			}})
+		case "web_fetch":
+			return mcp.NewToolResultJSON(map[string]any{"content": "content:" + reqArgs["url"].(string)})
+		default:
+			return mcp.NewToolResultError("unknown tool"), nil
+		}
+	}
+
+	tool, err := NewMCPPipeTool(log.Logger.Named("test_mcp_pipe_expr"), invoker, PipeLimits{})
+	require.NoError(t, err)
+
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"vars": map[string]any{"q": "golang"},
+		"steps": []any{
+			map[string]any{"id": "search", "tool": "web_search", "args": map[string]any{"query": "${upper(vars.q)}"}},
+			map[string]any{"id": "pages", "foreach": map[string]any{
+				"items": map[string]any{"$ref": "steps.search.structured.results[:3].url"},
+				"step":  map[string]any{"id": "fetch", "tool": "web_fetch", "args": map[string]any{"url": "${item}"}},
+			}},
+		},
+		"return": map[string]any{
+			"count": map[string]any{"$ref": "length(steps.pages.results)"},
+			"pages": map[string]any{"$ref": "steps.pages.results[].structured.content"},
+		},
+	}}}
+
+	result, handleErr := tool.Handle(context.Background(), req)
+	require.NoError(t, handleErr)
+	require.False(t, result.IsError)
+
+	structured := result.StructuredContent.(map[string]any)
+	require.Equal(t, map[string]any{
+		"count": float64(3),
+		"pages": []any{"content:https://a", "content:https://b", "content:https://c"},
+	}, structured["result"])
+}
+
+// TestMCPPipeExpressionErrorNamesStep verifies that argument resolution errors name the failing step.
+func TestMCPPipeExpressionErrorNamesStep(t *testing.T) {
+	invoker := func(ctx context.Context, toolName string, args any) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultJSON(map[string]any{"results": []any{}})
+	}
+
+	tool, err := NewMCPPipeTool(log.Logger.Named("test_mcp_pipe_expr_err"), invoker, PipeLimits{})
+	require.NoError(t, err)
+
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"steps": []any{
+			map[string]any{"id": "search", "tool": "web_search", "args": map[string]any{"query": "q"}},
+			map[string]any{"id": "fetch", "tool": "web_fetch", "args": map[string]any{"url": "${steps.search.structured.results[0].url}"}},
+		},
+	}}}
+
+	result, handleErr := tool.Handle(context.Background(), req)
+	require.NoError(t, handleErr)
+	require.True(t, result.IsError)
+
+	structured := result.StructuredContent.(map[string]any)
+	require.Contains(t, structured["error"], `step "fetch": resolve args`)
+	require.Contains(t, structured["error"], "out of range")
+}
+
+// TestMCPPipeIfExpression verifies that string predicates accept expressions.
+func TestMCPPipeIfExpression(t *testing.T) {
+	invoker := func(ctx context.Context, toolName string, args any) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultJSON(map[string]any{"tool": toolName})
+	}
+
+	tool, err := NewMCPPipeTool(log.Logger.Named("test_mcp_pipe_if_expr"), invoker, PipeLimits{})
+	require.NoError(t, err)
+
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"vars": map[string]any{"urls": []any{"a", "b"}},
+		"steps": []any{
+			map[string]any{
+				"id":   "branch",
+				"if":   "${length(vars.urls) > `1` && contains(vars.urls, 'b')}",
+				"then": map[string]any{"id": "yes", "tool": "web_fetch", "args": map[string]any{}},
+				"else": map[string]any{"id": "no", "tool": "ask_user", "args": map[string]any{}},
+			},
+		},
+		"return": map[string]any{"$ref": "steps.branch.result.structured.tool"},
+	}}}
+
+	result, handleErr := tool.Handle(context.Background(), req)
+	require.NoError(t, handleErr)
+	require.False(t, result.IsError)
+	require.Equal(t, "web_fetch", result.StructuredContent.(map[string]any)["result"])
+}
@@ -0,0 +1,651 @@
+package tools
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	errors "github.com/Laisky/errors/v2"
+	mcp "github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/Laisky/laisky-blog-graphql/library/billing/oneapi"
+)
+
+// PipeToolInfo describes a tool that mcp_pipe steps may invoke.
+type PipeToolInfo struct {
+	// Definition is the tool's MCP metadata; its input schema is used to check step args.
+	Definition mcp.Tool
+	// Price is the base cost billed for each invocation, or 0 for free tools.
+	Price oneapi.Price
+	// ArgsPrice optionally returns the cost billed on top of Price for a call with args,
+	// such as the extra engines of a web_search fan-out. Args may hold unresolved references.
+	ArgsPrice func(args map[string]any) oneapi.Price
+}
+
+// PipeToolCatalog looks up an invocable tool by name.
+// It reports false when mcp_pipe cannot invoke the tool.
+type PipeToolCatalog func(name string) (PipeToolInfo, bool)
+
+// WithToolCatalog enables tool name, argument schema, and cost checks in dry runs.
+func (t *MCPPipeTool) WithToolCatalog(catalog PipeToolCatalog) *MCPPipeTool {
+	if t != nil {
+		t.catalog = catalog
+	}
+	return t
+}
+
+// pipeEstimate accumulates the static cost of a (sub-)pipeline.
+type pipeEstimate struct {
+	// steps counts the steps charged against MaxSteps on the expected path.
+	steps int
+	// cost is the expected cost: every step runs once, if takes the pricier
+	// branch, and a foreach over dynamic items runs once.
+	cost oneapi.Price
+	// maxCost additionally counts every retry and fallback.
+	maxCost oneapi.Price
+	// unbounded is set when a foreach over dynamic items makes maxCost unknown.
+	unbounded bool
+}
+
+// add accumulates other into e.
+func (e *pipeEstimate) add(other pipeEstimate) {
+	e.steps += other.steps
+	e.cost += other.cost
+	e.maxCost += other.maxCost
+	e.unbounded = e.unbounded || other.unbounded
+}
+
+// pipeScope describes what a step can reference when it runs.
+type pipeScope struct {
+	// vars holds the declared var names.
+	vars map[string]struct{}
+	// steps holds the step IDs already recorded in the steps environment.
+	steps map[string]struct{}
+	// unavailable maps step IDs of the same spec that are not recorded yet to the reason why.
+	unavailable map[string]string
+	// locals holds foreach bindings.
+	locals  map[string]struct{}
+	hasLast bool
+}
+
+// clone returns a deep copy of s.
+func (s pipeScope) clone() pipeScope {
+	return pipeScope{
+		vars:        s.vars,
+		steps:       maps.Clone(s.steps),
+		unavailable: maps.Clone(s.unavailable),
+		locals:      maps.Clone(s.locals),
+		hasLast:     s.hasLast,
+	}
+}
+
+// pipeRef is an environment path referenced by an expression.
+type pipeRef struct {
+	path []string
+	// optional is set when a missing value is tolerated, e.g. inside default().
+	optional bool
+}
+
+// pipePlanner statically validates a spec and builds its execution plan.
+type pipePlanner struct {
+	catalog  PipeToolCatalog
+	limits   PipeLimits
+	errs     []string
+	warnings []string
+}
+
+// dryRun validates spec without invoking any tool and returns the execution plan.
+func (t *MCPPipeTool) dryRun(spec *pipeSpec) (*mcp.CallToolResult, error) {
+	planner := &pipePlanner{catalog: t.catalog, limits: t.limits}
+
+	scope := pipeScope{vars: make(map[string]struct{}, len(spec.Vars))}
+	for name := range spec.Vars {
+		scope.vars[name] = struct{}{}
+	}
+	plan, estimate := planner.planSpec(spec, scope, 0)
+	if estimate.steps > t.limits.MaxSteps {
+		planner.errorf("pipeline is expected to run %d steps, exceeding the limit of %d", estimate.steps, t.limits.MaxSteps)
+	}
+
+	mode := spec.Mode
+	if mode == "" {
+		mode = pipeModeSync
+	}
+	payload := map[string]any{
+		"ok":                 len(planner.errs) == 0,
+		"dry_run":            true,
+		"mode":               mode,
+		"errors":             planner.errs,
+		"warnings":           planner.warnings,
+		"plan":               plan,
+		"estimated_steps":    estimate.steps,
+		"estimated_cost":     estimate.cost.Int(),
+		"estimated_cost_usd": priceUSD(estimate.cost),
+		"max_cost":           nil,
+		"max_cost_usd":       nil,
+		"costs_known":        t.catalog != nil,
+	}
+	if !estimate.unbounded {
+		payload["max_cost"] = estimate.maxCost.Int()
+		payload["max_cost_usd"] = priceUSD(estimate.maxCost)
+	}
+
+	result, err := mcp.NewToolResultJSON(payload)
+	if err != nil {
+		return mcp.NewToolResultError("failed to encode mcp_pipe dry run"), nil
+	}
+	result.IsError = len(planner.errs) > 0
+	return result, nil
+}
+
+// priceUSD converts a price in quota units to US dollars.
+func priceUSD(price oneapi.Price) float64 {
+	return float64(price) / float64(oneapi.USD(1))
+}
+
+// errorf records a validation error.
+func (p *pipePlanner) errorf(format string, args ...any) {
+	p.errs = append(p.errs, fmt.Sprintf(format, args...))
+}
+
+// warnf records a validation warning.
+func (p *pipePlanner) warnf(format string, args ...any) {
+	p.warnings = append(p.warnings, fmt.Sprintf(format, args...))
+}
+
+// planSpec plans the steps and return selector of spec.
+func (p *pipePlanner) planSpec(spec *pipeSpec, scope pipeScope, depth int) ([]map[string]any, pipeEstimate) {
+	var estimate pipeEstimate
+	if depth > p.limits.MaxDepth {
+		p.errorf("pipeline nesting too deep")
+		return nil, estimate
+	}
+	if depth > 0 && spec.Mode != "" && spec.Mode != pipeModeSync {
+		p.errorf("nested pipelines cannot set mode")
+	}
+	if len(spec.Steps) == 0 {
+		p.errorf("steps cannot be empty")
+	}
+
+	// Every step of this spec is unavailable until it has run.
+	scope.steps = map[string]struct{}{}
+	scope.unavailable = map[string]string{}
+	for _, step := range spec.Steps {
+		scope.unavailable[step.ID] = "is a later step"
+	}
+
+	plan := make([]map[string]any, 0, len(spec.Steps))
+	seen := map[string]struct{}{}
+	for _, step := range spec.Steps {
+		if _, ok := seen[step.ID]; ok {
+			p.errorf("duplicate step id: %s", step.ID)
+		}
+		seen[step.ID] = struct{}{}
+
+		stepScope := scope.clone()
+		stepScope.unavailable[step.ID] = "is the step itself (cycle)"
+		node, stepEstimate := p.planStep(step, stepScope, depth)
+		plan = append(plan, node)
+		estimate.add(stepEstimate)
+
+		delete(scope.unavailable, step.ID)
+		scope.steps[step.ID] = struct{}{}
+		scope.hasLast = true
+	}
+
+	if spec.Return != nil {
+		p.checkValue("return", spec.Return, scope, false)
+	}
+	return plan, estimate
+}
+
+// planStep validates one step and its sub-steps.
+func (p *pipePlanner) planStep(step pipeStep, scope pipeScope, depth int) (map[string]any, pipeEstimate) {
+	node := map[string]any{"id": step.ID}
+	estimate := pipeEstimate{steps: 1}
+	if err := validateStep(step); err != nil {
+		p.errorf("%s", err.Error())
+		node["kind"] = "invalid"
+		return node, estimate
+	}
+
+	where := fmt.Sprintf("step %q", step.ID)
+	switch {
+	case step.Tool != "":
+		node["kind"] = "tool"
+		node["tool"] = step.Tool
+		p.checkValue(where+" args", step.Args, scope, false)
+		price := p.checkTool(where, step)
+		estimate.cost = price
+		estimate.maxCost = price
+		node["estimated_cost"] = price.Int()
+	case step.If != nil:
+		node["kind"] = "if"
+		p.checkCondition(where+" if", step.If, scope)
+		thenNode, thenEstimate := p.planStep(*step.Then, p.innerScope(scope, step.ID), depth)
+		node["then"] = thenNode
+		branch := thenEstimate
+		if step.Else != nil {
+			elseNode, elseEstimate := p.planStep(*step.Else, p.innerScope(scope, step.ID), depth)
+			node["else"] = elseNode
+			branch.steps = max(branch.steps, elseEstimate.steps)
+			branch.cost = max(branch.cost, elseEstimate.cost)
+			branch.maxCost = max(branch.maxCost, elseEstimate.maxCost)
+			branch.unbounded = branch.unbounded || elseEstimate.unbounded
+		}
+		estimate.add(branch)
+	case step.Foreach != nil:
+		node["kind"] = "foreach"
+		iterations := p.checkForeachItems(where, step.Foreach.Items, scope)
+		loopScope := p.innerScope(scope, step.ID)
+		loopScope.locals[step.Foreach.loopVar()] = struct{}{}
+		loopScope.locals["index"] = struct{}{}
+		childNode, childEstimate := p.planStep(*step.Foreach.Step, loopScope, depth)
+		node["step"] = childNode
+		if iterations < 0 {
+			node["iterations"] = nil
+			childEstimate.maxCost = 0
+			childEstimate.unbounded = true
+			estimate.add(childEstimate)
+		} else {
+			node["iterations"] = iterations
+			estimate.add(pipeEstimate{
+				steps:     childEstimate.steps * iterations,
+				cost:      childEstimate.cost * oneapi.Price(iterations),
+				maxCost:   childEstimate.maxCost * oneapi.Price(iterations),
+				unbounded: childEstimate.unbounded,
+			})
+		}
+	case step.Pipe != nil:
+		node["kind"] = "pipe"
+		// Nested pipelines keep vars and loop bindings but start with empty steps.
+		childScope := pipeScope{vars: scope.vars, locals: maps.Clone(scope.locals)}
+		steps, childEstimate := p.planSpec(step.Pipe, childScope, depth+1)
+		node["steps"] = steps
+		estimate.add(childEstimate)
+	default:
+		node["kind"] = "parallel"
+		children := make([]map[string]any, 0, len(step.Parallel))
+		seen := map[string]struct{}{}
+		for _, child := range step.Parallel {
+			if _, ok := seen[child.ID]; ok {
+				p.errorf("duplicate parallel step id: %s", child.ID)
+			}
+			seen[child.ID] = struct{}{}
+
+			childScope := p.innerScope(scope, step.ID)
+			for _, sibling := range step.Parallel {
+				if sibling.ID != child.ID {
+					childScope.unavailable[sibling.ID] = "is a parallel sibling that runs concurrently"
+				}
+			}
+			childNode, childEstimate := p.planStep(child, childScope, depth)
+			children = append(children, childNode)
+			estimate.add(childEstimate)
+		}
+		node["children"] = children
+	}
+
+	if step.Retry != nil && step.Retry.Count > 0 {
+		node["retry"] = step.Retry.Count
+		estimate.maxCost *= oneapi.Price(1 + step.Retry.Count)
+	}
+	if step.Fallback != nil {
+		if step.Fallback.Step != nil {
+			fallbackNode, fallbackEstimate := p.planStep(*step.Fallback.Step, scope, depth)
+			node["fallback"] = fallbackNode
+			estimate.maxCost += fallbackEstimate.maxCost
+			estimate.unbounded = estimate.unbounded || fallbackEstimate.unbounded
+		} else {
+			if value, err := step.Fallback.value(); err == nil {
+				p.checkValue(where+" fallback value", value, scope, false)
+			}
+			node["fallback"] = map[string]any{"kind": "value"}
+		}
+	}
+	return node, estimate
+}
+
+// innerScope returns the scope for sub-steps of the step enclosingID,
+// which cannot reference the enclosing step's own result.
+func (p *pipePlanner) innerScope(scope pipeScope, enclosingID string) pipeScope {
+	inner := scope.clone()
+	inner.unavailable[enclosingID] = "is an enclosing step (cycle)"
+	if inner.locals == nil {
+		inner.locals = map[string]struct{}{}
+	}
+	return inner
+}
+
+// checkTool checks a tool step against the catalog and returns its price.
+func (p *pipePlanner) checkTool(where string, step pipeStep) oneapi.Price {
+	if p.catalog == nil {
+		return 0
+	}
+
+	info, ok := p.catalog(step.Tool)
+	if !ok {
+		p.errorf("%s: unknown tool: %s", where, step.Tool)
+		return 0
+	}
+	p.checkToolArgs(where, info.Definition, step.Args)
+	if info.ArgsPrice != nil {
+		return info.Price + info.ArgsPrice(step.Args)
+	}
+	return info.Price
+}
+
+// checkToolArgs checks step args against the tool's input schema.
+//
+// Required arguments must be present. Arguments whose value contains no
+// interpolation are also checked against the declared type and enum, since
+// their runtime value is already known.
+func (p *pipePlanner) checkToolArgs(where string, definition mcp.Tool, args map[string]any) {
+	schema := definition.InputSchema
+	for _, name := range schema.Required {
+		if _, ok := args[name]; !ok {
+			p.errorf("%s: missing required argument %q for tool %s", where, name, definition.Name)
+		}
+	}
+
+	for _, name := range mapKeys(args) {
+		rawProperty, ok := schema.Properties[name]
+		if !ok {
+			switch {
+			case schema.AdditionalProperties == false:
+				p.errorf("%s: tool %s does not accept argument %q", where, definition.Name, name)
+			case len(schema.Properties) > 0:
+				p.warnf("%s: argument %q is not declared by tool %s", where, name, definition.Name)
+			}
+			continue
+		}
+		property, ok := rawProperty.(map[string]any)
+		value := args[name]
+		if !ok || len(collectValueExprs(value)) > 0 {
+			continue
+		}
+		if err := checkSchemaValue(property, value); err != nil {
+			p.errorf("%s: argument %q for tool %s: %s", where, name, definition.Name, err.Error())
+		}
+	}
+}
+
+// checkSchemaValue checks a literal value against a JSON schema property's type and enum.
+func checkSchemaValue(property map[string]any, value any) error {
+	var types []string
+	switch v := property["type"].(type) {
+	case string:
+		types = []string{v}
+	case []string:
+		types = v
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+	}
+	if len(types) > 0 && !slices.ContainsFunc(types, func(typ string) bool { return schemaTypeMatches(typ, value) }) {
+		return errors.Errorf("expected %s, got %s", strings.Join(types, " or "), jsonTypeName(value))
+	}
+
+	var enum []any
+	switch v := property["enum"].(type) {
+	case []any:
+		enum = v
+	case []string:
+		for _, item := range v {
+			enum = append(enum, item)
+		}
+	}
+	if len(enum) > 0 && !slices.ContainsFunc(enum, func(allowed any) bool { return valuesEqual(allowed, value) }) {
+		return errors.Errorf("value %s is not one of %s", valueToString(value), valueToString(enum))
+	}
+	return nil
+}
+
+// schemaTypeMatches reports whether value is an instance of the JSON schema type typ.
+func schemaTypeMatches(typ string, value any) bool {
+	switch typ {
+	case "integer":
+		f, ok := toFloat(value)
+		return ok && f == float64(int64(f))
+	case "number":
+		_, ok := toFloat(value)
+		return ok
+	default:
+		return jsonTypeName(value) == typ
+	}
+}
+
+// checkForeachItems checks foreach items and returns the iteration count,
+// or -1 when it is only known at runtime.
+func (p *pipePlanner) checkForeachItems(where string, items any, scope pipeScope) int {
+	if len(collectValueExprs(items)) > 0 {
+		p.checkValue(where+" foreach items", items, scope, false)
+		return -1
+	}
+
+	literal, ok := items.([]any)
+	if !ok {
+		p.errorf("%s: foreach items must resolve to an array, got %s", where, jsonTypeName(items))
+		return 0
+	}
+	return len(literal)
+}
+
+// checkCondition checks every reference used by a condition.
+// Predicates treat missing values as false, so only step IDs must exist.
+func (p *pipePlanner) checkCondition(where string, cond *pipeCondition, scope pipeScope) {
+	for i := range cond.All {
+		p.checkCondition(where, &cond.All[i], scope)
+	}
+	for i := range cond.Any {
+		p.checkCondition(where, &cond.Any[i], scope)
+	}
+	if cond.Not != nil {
+		p.checkCondition(where, cond.Not, scope)
+	}
+	if ref := strings.TrimSpace(cond.Ref); ref != "" {
+		p.checkExpr(where, ref, scope, true)
+	}
+	if cond.Value != nil {
+		p.checkValue(where+" value", cond.Value, scope, false)
+	}
+}
+
+// checkValue checks every ${...} placeholder and $ref inside value.
+func (p *pipePlanner) checkValue(where string, value any, scope pipeScope, optional bool) {
+	for _, expr := range collectValueExprs(value) {
+		p.checkExpr(where, expr, scope, optional)
+	}
+}
+
+// checkExpr parses expr and checks the references it uses against scope.
+func (p *pipePlanner) checkExpr(where, expr string, scope pipeScope, optional bool) {
+	refs, err := exprRefs(expr)
+	if err != nil {
+		p.errorf("%s: %s", where, err.Error())
+		return
+	}
+	for _, ref := range refs {
+		if msg := scope.checkRef(ref, optional || ref.optional); msg != "" {
+			p.errorf("%s: reference %q: %s", where, expr, msg)
+		}
+	}
+}
+
+// checkRef returns why ref cannot resolve in scope, or "" if it may.
+// When optional is set, only references to impossible step IDs are reported.
+func (s pipeScope) checkRef(ref pipeRef, optional bool) string {
+	if len(ref.path) == 0 {
+		return ""
+	}
+
+	root := ref.path[0]
+	if _, ok := s.locals[root]; ok {
+		return ""
+	}
+	switch root {
+	case "steps":
+		if len(ref.path) < 2 {
+			return ""
+		}
+		id := ref.path[1]
+		if _, ok := s.steps[id]; ok {
+			return ""
+		}
+		if reason, ok := s.unavailable[id]; ok {
+			return fmt.Sprintf("step %q %s", id, reason)
+		}
+		return fmt.Sprintf("unknown step %q", id)
+	case "vars":
+		if optional || len(ref.path) < 2 {
+			return ""
+		}
+		if _, ok := s.vars[ref.path[1]]; !ok {
+			return fmt.Sprintf("unknown var %q", ref.path[1])
+		}
+	case "last":
+		if !optional && !s.hasLast {
+			return "last is not set before the first step"
+		}
+	default:
+		if !optional {
+			return fmt.Sprintf("unknown reference root %q", root)
+		}
+	}
+	return ""
+}
+
+// collectValueExprs returns the expressions of every ${...} placeholder and
+// $ref inside value, mirroring resolveAny.
+func collectValueExprs(value any) []string {
+	var out []string
+	var walk func(any)
+	walk = func(value any) {
+		switch v := value.(type) {
+		case string:
+			for _, m := range findPlaceholders(v) {
+				out = append(out, v[m[0]+2:m[1]-1])
+			}
+		case map[string]any:
+			if ref, ok := v["$ref"]; ok && len(v) == 1 {
+				if refStr, ok := ref.(string); ok {
+					out = append(out, refStr)
+				}
+				return
+			}
+			for _, key := range mapKeys(v) {
+				walk(v[key])
+			}
+		case []any:
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	walk(value)
+	return out
+}
+
+// exprRefs returns the environment paths an expression reads, mirroring evaluatePipeExpr.
+func exprRefs(src string) ([]pipeRef, error) {
+	text := strings.TrimSpace(src)
+	if text == "" {
+		return nil, errors.New("empty reference path")
+	}
+	if !strings.ContainsAny(text, plainPathStopChars) {
+		return []pipeRef{{path: strings.Split(text, ".")}}, nil
+	}
+
+	node, err := parsePipeExpr(text)
+	if err != nil {
+		return nil, err
+	}
+	var refs []pipeRef
+	collectExprRefs(node, true, false, &refs)
+	return refs, nil
+}
+
+// collectExprRefs appends the environment paths read by node to out.
+//
+// atRoot reports whether node is evaluated against the environment root, which
+// is the case at the top of an expression but not on the right of a chain,
+// pipe, or projection. optional is set where missing values are tolerated.
+func collectExprRefs(node exprNode, atRoot, optional bool, out *[]pipeRef) {
+	if path, ok := exprPath(node, atRoot); ok {
+		if len(path) > 0 {
+			*out = append(*out, pipeRef{path: path, optional: optional})
+		}
+		return
+	}
+
+	switch n := node.(type) {
+	case *subNode:
+		collectExprRefs(n.left, atRoot, optional, out)
+		collectExprRefs(n.right, false, optional, out)
+	case *pipeNode:
+		collectExprRefs(n.left, atRoot, optional, out)
+		collectExprRefs(n.right, false, optional, out)
+	case *projectionNode:
+		collectExprRefs(n.left, atRoot, optional, out)
+		// Projections evaluate leniently per element.
+		if n.filter != nil {
+			collectExprRefs(n.filter, false, true, out)
+		}
+		if n.right != nil {
+			collectExprRefs(n.right, false, true, out)
+		}
+	case *logicNode:
+		collectExprRefs(n.left, atRoot, optional, out)
+		collectExprRefs(n.right, atRoot, optional, out)
+	case *compareNode:
+		collectExprRefs(n.left, atRoot, optional, out)
+		collectExprRefs(n.right, atRoot, optional, out)
+	case *notNode:
+		collectExprRefs(n.operand, atRoot, optional, out)
+	case *listNode:
+		for _, item := range n.items {
+			collectExprRefs(item, atRoot, optional, out)
+		}
+	case *hashNode:
+		for _, value := range n.values {
+			collectExprRefs(value, atRoot, optional, out)
+		}
+	case *defaultNode:
+		collectExprRefs(n.value, atRoot, true, out)
+		collectExprRefs(n.fallback, atRoot, optional, out)
+	case *callNode:
+		for _, arg := range n.args {
+			collectExprRefs(arg, atRoot, optional, out)
+		}
+	}
+}
+
+// exprPath returns the static path of a field/index chain that starts at the
+// environment root, either implicitly (atRoot) or explicitly via $.
+func exprPath(node exprNode, atRoot bool) ([]string, bool) {
+	switch n := node.(type) {
+	case rootNode:
+		return []string{}, true
+	case *fieldNode:
+		if atRoot {
+			return []string{n.name}, true
+		}
+	case *subNode:
+		path, ok := exprPath(n.left, atRoot)
+		if !ok {
+			return nil, false
+		}
+		switch right := n.right.(type) {
+		case *fieldNode:
+			return append(path, right.name), true
+		case *indexNode:
+			return append(path, strconv.Itoa(right.index)), true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,201 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	mcp "github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Laisky/laisky-blog-graphql/library/billing/oneapi"
+	"github.com/Laisky/laisky-blog-graphql/library/log"
+	searchlib "github.com/Laisky/laisky-blog-graphql/library/search"
+)
+
+// newDryRunTestTool returns an mcp_pipe tool whose invoker fails the test and
+// whose catalog knows web_search (billed) and web_fetch (free).
+func newDryRunTestTool(t *testing.T) *MCPPipeTool {
+	t.Helper()
+	invoker := func(context.Context, string, any) (*mcp.CallToolResult, error) {
+		t.Fatal("dry run must not invoke tools")
+		return nil, nil //nolint:nilnil // unreachable
+	}
+	tool, err := NewMCPPipeTool(log.Logger.Named("test_mcp_pipe_plan"), invoker, PipeLimits{MaxSteps: 20, MaxDepth: 2, MaxParallel: 4})
+	require.NoError(t, err)
+
+	catalog := map[string]PipeToolInfo{
+		"web_search": {
+			Definition: mcp.NewTool("web_search",
+				mcp.WithString("query", mcp.Required()),
+				mcp.WithNumber("limit"),
+				mcp.WithString("engine", mcp.Enum("google", "bing")),
+				mcp.WithBoolean("fanout"),
+				mcp.WithNumber("max_engines"),
+			),
+			Price:     oneapi.PriceWebSearch,
+			ArgsPrice: WebSearchFanOutPrice,
+		},
+		"web_fetch": {Definition: mcp.NewTool("web_fetch", mcp.WithString("url", mcp.Required()))},
+	}
+	return tool.WithToolCatalog(func(name string) (PipeToolInfo, bool) {
+		info, ok := catalog[name]
+		return info, ok
+	})
+}
+
+// dryRun calls mcp_pipe with dry_run set and returns the decoded payload.
+func dryRun(t *testing.T, tool *MCPPipeTool, spec map[string]any) (*mcp.CallToolResult, map[string]any) {
+	t.Helper()
+	spec["dry_run"] = true
+	result, err := tool.Handle(context.Background(), mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: spec}})
+	require.NoError(t, err)
+	payload, ok := result.StructuredContent.(map[string]any)
+	require.True(t, ok)
+	return result, payload
+}
+
+// TestMCPPipeDryRunFanOutCost verifies that web_search fan-out engines are priced.
+func TestMCPPipeDryRunFanOutCost(t *testing.T) {
+	tool := newDryRunTestTool(t)
+
+	_, payload := dryRun(t, tool, map[string]any{
+		"vars": map[string]any{"wide": true},
+		"steps": []any{
+			map[string]any{"id": "a", "tool": "web_search", "args": map[string]any{"query": "x", "fanout": true, "max_engines": 3}},
+			map[string]any{"id": "b", "tool": "web_search", "args": map[string]any{"query": "x", "fanout": "${vars.wide}"}},
+			map[string]any{"id": "c", "tool": "web_search", "args": map[string]any{"query": "x", "fanout": false, "max_engines": 4}},
+		},
+	})
+	require.Empty(t, payload["errors"])
+	// a: 3 engines; b: a reference priced at the default engine count; c: 1 engine.
+	price := oneapi.PriceWebSearch.Int()
+	require.Equal(t, (3+searchlib.DefaultFanOutEngines+1)*price, payload["estimated_cost"])
+}
+
+// TestMCPPipeDryRunPlan verifies the plan and cost estimate of a valid spec.
+func TestMCPPipeDryRunPlan(t *testing.T) {
+	tool := newDryRunTestTool(t)
+
+	result, payload := dryRun(t, tool, map[string]any{
+		"vars": map[string]any{"q": "golang"},
+		"steps": []any{
+			map[string]any{"id": "search", "tool": "web_search", "args": map[string]any{"query": "${vars.q}", "limit": 3}, "retry": 2},
+			map[string]any{"id": "each", "foreach": map[string]any{
+				"items": []any{"a", "b", "c"},
+				"step":  map[string]any{"id": "s", "tool": "web_search", "args": map[string]any{"query": "${item} ${index}"}},
+			}},
+			map[string]any{
+				"id":   "check",
+				"if":   "${length(steps.search.structured.results) > `0`}",
+				"then": map[string]any{"id": "fetch", "tool": "web_fetch", "args": map[string]any{"url": "${steps.search.structured.results[0].url}"}},
+				"else": map[string]any{"id": "again", "tool": "web_search", "args": map[string]any{"query": "${last.text}"}},
+			},
+			map[string]any{"id": "nested", "pipe": map[string]any{"steps": []any{
+				map[string]any{"id": "inner", "tool": "web_fetch", "args": map[string]any{"url": "${vars.q}"}},
+			}}},
+		},
+		"return": map[string]any{"$ref": "steps.nested.result"},
+	})
+	require.False(t, result.IsError, payload["errors"])
+	require.Empty(t, payload["errors"])
+	require.Equal(t, true, payload["costs_known"])
+
+	// search + 3 foreach iterations + the pricier if branch; fetch steps are free.
+	price := oneapi.PriceWebSearch.Int()
+	require.Equal(t, 5*price, payload["estimated_cost"])
+	require.Equal(t, 7*price, payload["max_cost"])
+	require.Equal(t, 1+(1+3)+(1+1)+(1+1), payload["estimated_steps"])
+
+	plan, ok := payload["plan"].([]map[string]any)
+	require.True(t, ok)
+	require.Len(t, plan, 4)
+	require.Equal(t, "tool", plan[0]["kind"])
+	require.Equal(t, 2, plan[0]["retry"])
+	require.Equal(t, 3, plan[1]["iterations"])
+	require.Equal(t, "if", plan[2]["kind"])
+	require.Equal(t, "pipe", plan[3]["kind"])
+}
+
+// TestMCPPipeDryRunErrors verifies that all static errors are reported together.
+func TestMCPPipeDryRunErrors(t *testing.T) {
+	tool := newDryRunTestTool(t)
+
+	result, payload := dryRun(t, tool, map[string]any{
+		"steps": []any{
+			map[string]any{"id": "a", "tool": "web_serch", "args": map[string]any{"query": "x"}},
+			map[string]any{"id": "b", "tool": "web_search", "args": map[string]any{"query": "${steps.c.text}"}},
+			map[string]any{"id": "c", "tool": "web_search", "args": map[string]any{"query": "${steps.c.text}"}},
+			map[string]any{"id": "d", "tool": "web_search", "args": map[string]any{"query": "${steps.missing.text}", "limit": "3", "engine": "yahoo"}},
+			map[string]any{"id": "e", "parallel": []any{
+				map[string]any{"id": "p1", "tool": "web_fetch", "args": map[string]any{"url": "${steps.p2.text}"}},
+				map[string]any{"id": "p2", "tool": "web_fetch", "args": map[string]any{"url": "${vars.nope}"}},
+			}},
+			map[string]any{"id": "a", "tool": "web_fetch", "args": map[string]any{"url": "${upper(}"}},
+			map[string]any{"id": "f", "tool": "web_fetch", "args": map[string]any{}},
+		},
+	})
+	require.True(t, result.IsError)
+	require.Equal(t, []string{
+		`step "a": unknown tool: web_serch`,
+		`step "b" args: reference "steps.c.text": step "c" is a later step`,
+		`step "c" args: reference "steps.c.text": step "c" is the step itself (cycle)`,
+		`step "d" args: reference "steps.missing.text": unknown step "missing"`,
+		`step "d": argument "engine" for tool web_search: value yahoo is not one of ["google","bing"]`,
+		`step "d": argument "limit" for tool web_search: expected number, got string`,
+		`step "p1" args: reference "steps.p2.text": step "p2" is a parallel sibling that runs concurrently`,
+		`step "p2" args: reference "vars.nope": unknown var "nope"`,
+		`duplicate step id: a`,
+		`step "a" args: parse expression "upper(": unexpected end of expression`,
+		`step "f": missing required argument "url" for tool web_fetch`,
+	}, payload["errors"])
+}
+
+// TestMCPPipeDryRunReferences verifies scoping rules for last, loop bindings, and default().
+func TestMCPPipeDryRunReferences(t *testing.T) {
+	tool := newDryRunTestTool(t)
+
+	_, payload := dryRun(t, tool, map[string]any{
+		"steps": []any{
+			map[string]any{"id": "first", "tool": "web_fetch", "args": map[string]any{"url": "${last.text}"}},
+			map[string]any{"id": "loop", "foreach": map[string]any{
+				"items": map[string]any{"$ref": "steps.first.structured.links"},
+				"as":    "link",
+				"step":  map[string]any{"id": "get", "tool": "web_fetch", "args": map[string]any{"url": "${link}", "note": "${default(vars.extra, 'none')}"}},
+			}},
+			map[string]any{"id": "self", "foreach": map[string]any{
+				"items": []any{"x"},
+				"step":  map[string]any{"id": "inner", "tool": "web_fetch", "args": map[string]any{"url": "${steps.self.count}"}},
+			}},
+		},
+	})
+	require.Equal(t, []string{
+		`step "first" args: reference "last.text": last is not set before the first step`,
+		`step "inner" args: reference "steps.self.count": step "self" is an enclosing step (cycle)`,
+	}, payload["errors"])
+	require.Equal(t, []string{`step "get": argument "note" is not declared by tool web_fetch`}, payload["warnings"])
+	// Dynamic foreach items leave the worst-case cost unknown.
+	require.Nil(t, payload["max_cost"])
+}
+
+// TestMCPPipeDryRunWithoutCatalog verifies that dry runs still check structure and
+// references when no tool catalog is attached.
+func TestMCPPipeDryRunWithoutCatalog(t *testing.T) {
+	tool, err := NewMCPPipeTool(log.Logger.Named("test_mcp_pipe_plan"), func(context.Context, string, any) (*mcp.CallToolResult, error) {
+		t.Fatal("dry run must not invoke tools")
+		return nil, nil //nolint:nilnil // unreachable
+	}, PipeLimits{})
+	require.NoError(t, err)
+
+	result, err := tool.Handle(context.Background(), mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"spec":    `{"steps":[{"id":"a","tool":"anything"},{"id":"b","tool":"x","pipe":{"steps":[]}}]}`,
+		"mode":    "async",
+		"dry_run": true,
+	}}})
+	require.NoError(t, err)
+	require.True(t, result.IsError)
+
+	payload := result.StructuredContent.(map[string]any)
+	require.Equal(t, "async", payload["mode"])
+	require.Equal(t, false, payload["costs_known"])
+	require.Equal(t, []string{"step must have exactly one of tool, pipe, parallel, if, or foreach"}, payload["errors"])
+}
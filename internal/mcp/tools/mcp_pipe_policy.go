@@ -0,0 +1,378 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"maps"
+	"time"
+
+	errors "github.com/Laisky/errors/v2"
+	logSDK "github.com/Laisky/go-utils/v6/log"
+	"github.com/Laisky/zap"
+
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/ctxkeys"
+)
+
+const (
+	// maxPipeRetryCount caps the number of retries a single step may request.
+	maxPipeRetryCount = 5
+	// maxPipeStepTimeout caps the per-attempt timeout a single step may request.
+	maxPipeStepTimeout = 10 * time.Minute
+	// defaultPipeRetryBackoff is the delay before the first retry when backoff_ms is omitted.
+	defaultPipeRetryBackoff = 500 * time.Millisecond
+	// defaultPipeRetryMaxBackoff caps exponential backoff when max_backoff_ms is omitted.
+	defaultPipeRetryMaxBackoff = 30 * time.Second
+	// defaultPipeRetryMultiplier grows the delay between consecutive retries.
+	defaultPipeRetryMultiplier = 2.0
+)
+
+// pipeRetry configures how often a failed step is re-run and how long to wait in between.
+//
+// It accepts either an object or a bare integer shorthand for count.
+type pipeRetry struct {
+	Count        int     `json:"count"`
+	BackoffMs    int     `json:"backoff_ms,omitempty"`
+	MaxBackoffMs int     `json:"max_backoff_ms,omitempty"`
+	Multiplier   float64 `json:"multiplier,omitempty"`
+}
+
+// UnmarshalJSON accepts `3` as shorthand for `{"count": 3}`.
+func (r *pipeRetry) UnmarshalJSON(data []byte) error {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] != '{' {
+		var count int
+		if err := json.Unmarshal(trimmed, &count); err != nil {
+			return errors.Wrap(err, "retry must be an integer or object")
+		}
+		*r = pipeRetry{Count: count}
+		return nil
+	}
+
+	type alias pipeRetry
+	var decoded alias
+	if err := json.Unmarshal(trimmed, &decoded); err != nil {
+		return errors.Wrap(err, "decode retry")
+	}
+	*r = pipeRetry(decoded)
+	return nil
+}
+
+// delay returns the wait before the given retry, where retry 1 is the first re-run.
+func (r *pipeRetry) delay(retry int) time.Duration {
+	base := defaultPipeRetryBackoff
+	if r.BackoffMs > 0 {
+		base = time.Duration(r.BackoffMs) * time.Millisecond
+	}
+	maxDelay := defaultPipeRetryMaxBackoff
+	if r.MaxBackoffMs > 0 {
+		maxDelay = time.Duration(r.MaxBackoffMs) * time.Millisecond
+	}
+	multiplier := r.Multiplier
+	if multiplier < 1 {
+		multiplier = defaultPipeRetryMultiplier
+	}
+
+	d := float64(base)
+	for i := 1; i < retry; i++ {
+		d *= multiplier
+		if d >= float64(maxDelay) {
+			return maxDelay
+		}
+	}
+	return min(time.Duration(d), maxDelay)
+}
+
+// pipeFallback is used when a step still fails after its retries.
+// Exactly one of Step or Value must be set; Value may contain references.
+// Value is kept raw so that an explicit `"value": null` is told apart from no value.
+type pipeFallback struct {
+	Step  *pipeStep       `json:"step,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// value decodes the fallback value.
+func (f *pipeFallback) value() (any, error) {
+	var value any
+	if err := json.Unmarshal(f.Value, &value); err != nil {
+		return nil, errors.Wrap(err, "decode fallback value")
+	}
+	return value, nil
+}
+
+// validatePolicy validates the retry, timeout, and fallback settings of a step.
+func validatePolicy(step pipeStep) error {
+	if step.Retry != nil {
+		if step.Retry.Count < 0 || step.Retry.Count > maxPipeRetryCount {
+			return errors.Errorf("retry.count must be between 0 and %d on step: %s", maxPipeRetryCount, step.ID)
+		}
+		if step.Retry.BackoffMs < 0 || step.Retry.MaxBackoffMs < 0 || step.Retry.Multiplier < 0 {
+			return errors.New("retry backoff values cannot be negative on step: " + step.ID)
+		}
+	}
+	if step.TimeoutMs < 0 || time.Duration(step.TimeoutMs)*time.Millisecond > maxPipeStepTimeout {
+		return errors.Errorf("timeout_ms must be between 0 and %d on step: %s", maxPipeStepTimeout.Milliseconds(), step.ID)
+	}
+	if step.Fallback != nil {
+		if (step.Fallback.Step == nil) == (step.Fallback.Value == nil) {
+			return errors.New("fallback requires exactly one of step or value on step: " + step.ID)
+		}
+		if step.Fallback.Value != nil {
+			if _, err := step.Fallback.value(); err != nil {
+				return errors.Wrapf(err, "fallback of step %q", step.ID)
+			}
+		}
+		if step.Fallback.Step != nil {
+			if err := validateStep(*step.Fallback.Step); err != nil {
+				return errors.Wrapf(err, "fallback of step %q", step.ID)
+			}
+		}
+	}
+	return nil
+}
+
+// executeStep runs a step under its retry, timeout, and fallback policy and
+// returns a JSON-serializable result map.
+func (t *MCPPipeTool) executeStep(ctx context.Context, logger logSDK.Logger, step pipeStep, env map[string]any, depth int, counter *stepCounter) (map[string]any, error) {
+	if step.Retry == nil && step.TimeoutMs == 0 && step.Fallback == nil {
+		return t.executeStepOnce(withPipeStepAttempt(ctx, step.ID, 1, 1), logger, step, env, depth, counter)
+	}
+
+	maxAttempts := 1
+	if step.Retry != nil {
+		maxAttempts += step.Retry.Count
+	}
+
+	var (
+		result        map[string]any
+		err           error
+		attempts      int
+		attemptErrors []string
+	)
+	for attempts < maxAttempts {
+		if attempts > 0 {
+			if incErr := counter.increment(t.limits.MaxSteps); incErr != nil {
+				err = errors.WithStack(incErr)
+				attemptErrors = append(attemptErrors, err.Error())
+				break
+			}
+			delay := step.Retry.delay(attempts)
+			logger.Debug("mcp_pipe retrying step",
+				zap.String("step_id", step.ID),
+				zap.Int("attempt", attempts+1),
+				zap.Duration("backoff", delay),
+				zap.Error(err),
+			)
+			if waitErr := sleepContext(ctx, delay); waitErr != nil {
+				break
+			}
+		}
+		attempts++
+
+		// A retried parallel group only re-runs the children that did not succeed.
+		attemptStep, kept := step, map[string]any(nil)
+		if attempts > 1 && len(step.Parallel) > 0 {
+			attemptStep, kept = parallelRetryStep(step, result)
+		}
+		result, err = t.executeStepAttempt(withPipeStepAttempt(ctx, step.ID, attempts, maxAttempts), logger, attemptStep, env, depth, counter)
+		mergeParallelChildren(result, kept)
+		if err == nil {
+			break
+		}
+		attemptErrors = append(attemptErrors, err.Error())
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	if result == nil {
+		result = map[string]any{"id": step.ID, "ok": false, "error": errorString(err)}
+	}
+	if step.Retry != nil {
+		result["attempts"] = attempts
+		if len(attemptErrors) > 0 {
+			result["attempt_errors"] = attemptErrors
+		}
+	}
+	if err == nil || step.Fallback == nil || ctx.Err() != nil {
+		return result, err
+	}
+
+	return t.executeFallback(ctx, logger, step, env, depth, counter, result, err)
+}
+
+// parallelRetryStep narrows a parallel step to the children that did not succeed in
+// the previous attempt, and returns the results of the children that did.
+func parallelRetryStep(step pipeStep, previous map[string]any) (pipeStep, map[string]any) {
+	children, _ := previous["children"].(map[string]any)
+	if len(children) == 0 {
+		return step, nil
+	}
+
+	kept := map[string]any{}
+	pending := make([]pipeStep, 0, len(step.Parallel))
+	for _, child := range step.Parallel {
+		if res, ok := children[child.ID].(map[string]any); ok && res["ok"] == true {
+			kept[child.ID] = res
+			continue
+		}
+		pending = append(pending, child)
+	}
+	step.Parallel = pending
+	return step, kept
+}
+
+// mergeParallelChildren adds the kept child results of earlier attempts to result.
+func mergeParallelChildren(result, kept map[string]any) {
+	if result == nil || len(kept) == 0 {
+		return
+	}
+	children, _ := result["children"].(map[string]any)
+	if children == nil {
+		children = map[string]any{}
+		result["children"] = children
+	}
+	maps.Copy(children, kept)
+}
+
+// executeStepAttempt runs one attempt of a step, bounded by timeout_ms when set.
+func (t *MCPPipeTool) executeStepAttempt(ctx context.Context, logger logSDK.Logger, step pipeStep, env map[string]any, depth int, counter *stepCounter) (map[string]any, error) {
+	if step.TimeoutMs <= 0 {
+		return t.executeStepOnce(ctx, logger, step, env, depth, counter)
+	}
+
+	attemptCtx, cancel := context.WithTimeout(ctx, time.Duration(step.TimeoutMs)*time.Millisecond)
+	defer cancel()
+
+	result, err := t.executeStepOnce(attemptCtx, logger, step, env, depth, counter)
+	if err != nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		err = errors.Errorf("step %q timed out after %dms", step.ID, step.TimeoutMs)
+		if result != nil {
+			result["ok"] = false
+			result["error"] = err.Error()
+		}
+	}
+	return result, err
+}
+
+// executeFallback runs the fallback of a step whose attempts all failed.
+// The returned result replaces the primary result under the original step ID.
+func (t *MCPPipeTool) executeFallback(ctx context.Context, logger logSDK.Logger, step pipeStep, env map[string]any, depth int, counter *stepCounter, primary map[string]any, primaryErr error) (map[string]any, error) {
+	startedAt := time.Now().UTC()
+	logger.Debug("mcp_pipe running step fallback", zap.String("step_id", step.ID), zap.Error(primaryErr))
+
+	var (
+		result map[string]any
+		err    error
+		kind   string
+	)
+	if step.Fallback.Step != nil {
+		kind = "step"
+		if incErr := counter.increment(t.limits.MaxSteps); incErr != nil {
+			skipErr := errors.Wrapf(primaryErr, "fallback skipped (%s)", incErr.Error())
+			primary["error"] = skipErr.Error()
+			return primary, skipErr
+		}
+		fbCtx := context.WithValue(ctx, ctxkeys.PipeStepAttempt, PipeStepAttempt{
+			StepID:      step.Fallback.Step.ID,
+			Attempt:     1,
+			MaxAttempts: 1,
+			FallbackFor: step.ID,
+		})
+		result, err = t.executeStep(fbCtx, logger, *step.Fallback.Step, env, depth, counter)
+		if result == nil {
+			result = map[string]any{}
+		} else {
+			result = maps.Clone(result)
+		}
+		result["fallback_step_id"] = step.Fallback.Step.ID
+	} else {
+		kind = "value"
+		var value any
+		value, err = step.Fallback.value()
+		if err == nil {
+			value, err = resolveAny(value, env)
+		}
+		if err != nil {
+			err = errors.Wrapf(err, "step %q: resolve fallback value", step.ID)
+		}
+		result = map[string]any{
+			"kind":        "value",
+			"started_at":  startedAt.Format(time.RFC3339Nano),
+			"duration_ms": time.Since(startedAt).Milliseconds(),
+			"ok":          err == nil,
+			"error":       errorString(err),
+			"structured":  value,
+		}
+	}
+
+	result["id"] = step.ID
+	result["fallback_used"] = true
+	result["fallback"] = kind
+	result["primary_error"] = primaryErr.Error()
+	for _, key := range []string{"attempts", "attempt_errors"} {
+		if value, ok := primary[key]; ok {
+			result[key] = value
+		}
+	}
+
+	if err != nil {
+		combined := errors.Errorf("step %q failed (%s) and its fallback failed: %s", step.ID, primaryErr.Error(), err.Error())
+		result["ok"] = false
+		result["error"] = combined.Error()
+		return result, combined
+	}
+	return result, nil
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return errors.WithStack(ctx.Err())
+	case <-timer.C:
+		return nil
+	}
+}
+
+// PipeStepAttempt identifies the mcp_pipe step attempt that issued a tool call.
+// The MCP server adds it to call log parameters so retries and fallbacks are auditable.
+type PipeStepAttempt struct {
+	StepID      string
+	Attempt     int
+	MaxAttempts int
+	// FallbackFor is the ID of the failed step when this call runs as its fallback.
+	FallbackFor string
+}
+
+// LogFields returns the attempt as call log parameters.
+func (a PipeStepAttempt) LogFields() map[string]any {
+	fields := map[string]any{
+		"step_id":      a.StepID,
+		"attempt":      a.Attempt,
+		"max_attempts": a.MaxAttempts,
+		"fallback":     a.FallbackFor != "",
+	}
+	if a.FallbackFor != "" {
+		fields["fallback_for"] = a.FallbackFor
+	}
+	return fields
+}
+
+// PipeStepAttemptFromContext returns the mcp_pipe step attempt stored in ctx, if any.
+func PipeStepAttemptFromContext(ctx context.Context) (PipeStepAttempt, bool) {
+	attempt, ok := ctx.Value(ctxkeys.PipeStepAttempt).(PipeStepAttempt)
+	return attempt, ok
+}
+
+// withPipeStepAttempt records the current step attempt in ctx. Steps running
+// inside a fallback keep the fallback marker of their enclosing step.
+func withPipeStepAttempt(ctx context.Context, stepID string, attempt, maxAttempts int) context.Context {
+	info := PipeStepAttempt{StepID: stepID, Attempt: attempt, MaxAttempts: maxAttempts}
+	if parent, ok := PipeStepAttemptFromContext(ctx); ok {
+		info.FallbackFor = parent.FallbackFor
+	}
+	return context.WithValue(ctx, ctxkeys.PipeStepAttempt, info)
+}
@@ -0,0 +1,291 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	mcp "github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Laisky/laisky-blog-graphql/library/log"
+)
+
+// TestMCPPipeRetrySucceeds verifies that a flaky step is retried and reports its attempts.
+func TestMCPPipeRetrySucceeds(t *testing.T) {
+	var calls atomic.Int32
+	invoker := func(ctx context.Context, toolName string, args any) (*mcp.CallToolResult, error) {
+		attempt, ok := PipeStepAttemptFromContext(ctx)
+		require.True(t, ok)
+		require.Equal(t, "fetch", attempt.StepID)
+		require.Equal(t, int(calls.Load())+1, attempt.Attempt)
+
+		if calls.Add(1) < 3 {
+			return mcp.NewToolResultError("upstream timeout"), nil
+		}
+		return mcp.NewToolResultJSON(map[string]any{"content": "ok"})
+	}
+
+	tool, err := NewMCPPipeTool(log.Logger.Named("test_mcp_pipe_retry"), invoker, PipeLimits{})
+	require.NoError(t, err)
+
+	result, err := tool.Handle(context.Background(), mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"steps": []any{map[string]any{
+			"id": "fetch", "tool": "web_fetch", "args": map[string]any{"url": "x"},
+			"retry": map[string]any{"count": 3, "backoff_ms": 1},
+		}},
+	}}})
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+	require.EqualValues(t, 3, calls.Load())
+
+	fetch := result.StructuredContent.(map[string]any)["steps"].(map[string]any)["fetch"].(map[string]any)
+	require.Equal(t, true, fetch["ok"])
+	require.Equal(t, 3, fetch["attempts"])
+	require.Equal(t, []string{"upstream timeout", "upstream timeout"}, fetch["attempt_errors"])
+}
+
+// TestMCPPipeTimeoutFallbackValue verifies that timeouts fail an attempt and a literal fallback replaces the result.
+func TestMCPPipeTimeoutFallbackValue(t *testing.T) {
+	invoker := func(ctx context.Context, toolName string, args any) (*mcp.CallToolResult, error) {
+		if toolName == "web_fetch" {
+			<-ctx.Done()
+			return mcp.NewToolResultError(ctx.Err().Error()), nil
+		}
+		return mcp.NewToolResultJSON(map[string]any{"tool": toolName})
+	}
+
+	tool, err := NewMCPPipeTool(log.Logger.Named("test_mcp_pipe_timeout"), invoker, PipeLimits{})
+	require.NoError(t, err)
+
+	result, err := tool.Handle(context.Background(), mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"vars": map[string]any{"placeholder": "unavailable"},
+		"steps": []any{
+			map[string]any{
+				"id": "fetch", "tool": "web_fetch", "args": map[string]any{"url": "x"},
+				"timeout_ms": 20,
+				"retry":      1,
+				"fallback":   map[string]any{"value": map[string]any{"content": "${vars.placeholder}"}},
+			},
+			map[string]any{"id": "next", "tool": "extract_key_info", "args": map[string]any{"text": "${steps.fetch.structured.content}"}},
+		},
+		"return": map[string]any{"$ref": "steps.fetch.structured.content"},
+	}}})
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+
+	structured := result.StructuredContent.(map[string]any)
+	require.Equal(t, "unavailable", structured["result"])
+
+	fetch := structured["steps"].(map[string]any)["fetch"].(map[string]any)
+	require.Equal(t, true, fetch["fallback_used"])
+	require.Equal(t, "value", fetch["fallback"])
+	require.Equal(t, 2, fetch["attempts"])
+	require.Contains(t, fetch["primary_error"], `step "fetch" timed out after 20ms`)
+}
+
+// TestMCPPipeParallelRetryRerunsFailedChildren verifies a retried parallel group keeps
+// the children that already succeeded and only re-runs the failed ones.
+func TestMCPPipeParallelRetryRerunsFailedChildren(t *testing.T) {
+	var searchCalls, fetchCalls atomic.Int32
+	invoker := func(ctx context.Context, toolName string, args any) (*mcp.CallToolResult, error) {
+		if toolName == "web_search" {
+			searchCalls.Add(1)
+			return mcp.NewToolResultJSON(map[string]any{"content": "found"})
+		}
+		if fetchCalls.Add(1) < 2 {
+			return mcp.NewToolResultError("upstream timeout"), nil
+		}
+		return mcp.NewToolResultJSON(map[string]any{"content": "fetched"})
+	}
+
+	tool, err := NewMCPPipeTool(log.Logger.Named("test_mcp_pipe_parallel_retry"), invoker, PipeLimits{})
+	require.NoError(t, err)
+
+	result, err := tool.Handle(context.Background(), mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"steps": []any{map[string]any{
+			"id": "group",
+			"parallel": []any{
+				map[string]any{"id": "search", "tool": "web_search", "args": map[string]any{"query": "x"}},
+				map[string]any{"id": "fetch", "tool": "web_fetch", "args": map[string]any{"url": "x"}},
+			},
+			"retry": map[string]any{"count": 1, "backoff_ms": 1},
+		}},
+	}}})
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+	require.EqualValues(t, 1, searchCalls.Load())
+	require.EqualValues(t, 2, fetchCalls.Load())
+
+	group := result.StructuredContent.(map[string]any)["steps"].(map[string]any)["group"].(map[string]any)
+	require.Equal(t, true, group["ok"])
+	require.Equal(t, 2, group["attempts"])
+	children := group["children"].(map[string]any)
+	require.Len(t, children, 2)
+	require.Equal(t, true, children["search"].(map[string]any)["ok"])
+	require.Equal(t, true, children["fetch"].(map[string]any)["ok"])
+}
+
+// TestMCPPipeFallbackNullValue verifies an explicit null fallback value is accepted
+// and used as the step result.
+func TestMCPPipeFallbackNullValue(t *testing.T) {
+	invoker := func(ctx context.Context, toolName string, args any) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultError("blocked"), nil
+	}
+
+	tool, err := NewMCPPipeTool(log.Logger.Named("test_mcp_pipe_fallback_null"), invoker, PipeLimits{})
+	require.NoError(t, err)
+
+	var steps []any
+	require.NoError(t, json.Unmarshal([]byte(`[{"id": "fetch", "tool": "web_fetch", "fallback": {"value": null}}]`), &steps))
+	result, err := tool.Handle(context.Background(), mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"steps": steps,
+	}}})
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+
+	fetch := result.StructuredContent.(map[string]any)["steps"].(map[string]any)["fetch"].(map[string]any)
+	require.Equal(t, true, fetch["fallback_used"])
+	require.Contains(t, fetch, "structured")
+	require.Nil(t, fetch["structured"])
+}
+
+// TestMCPPipeFallbackStep verifies that a fallback step runs under the original step ID.
+func TestMCPPipeFallbackStep(t *testing.T) {
+	invoker := func(ctx context.Context, toolName string, args any) (*mcp.CallToolResult, error) {
+		attempt, _ := PipeStepAttemptFromContext(ctx)
+		switch toolName {
+		case "web_fetch":
+			require.Empty(t, attempt.FallbackFor)
+			return mcp.NewToolResultError("blocked"), nil
+		case "web_search":
+			require.Equal(t, "fetch", attempt.FallbackFor)
+			return mcp.NewToolResultJSON(map[string]any{"content": "from search"})
+		default:
+			return mcp.NewToolResultError("unknown tool"), nil
+		}
+	}
+
+	tool, err := NewMCPPipeTool(log.Logger.Named("test_mcp_pipe_fallback_step"), invoker, PipeLimits{})
+	require.NoError(t, err)
+
+	result, err := tool.Handle(context.Background(), mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"steps": []any{map[string]any{
+			"id": "fetch", "tool": "web_fetch", "args": map[string]any{"url": "x"},
+			"fallback": map[string]any{"step": map[string]any{"id": "search", "tool": "web_search", "args": map[string]any{"query": "x"}}},
+		}},
+		"return": map[string]any{"$ref": "steps.fetch.structured.content"},
+	}}})
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+
+	structured := result.StructuredContent.(map[string]any)
+	require.Equal(t, "from search", structured["result"])
+	fetch := structured["steps"].(map[string]any)["fetch"].(map[string]any)
+	require.Equal(t, "fetch", fetch["id"])
+	require.Equal(t, "web_search", fetch["tool"])
+	require.Equal(t, "search", fetch["fallback_step_id"])
+	require.Equal(t, "blocked", fetch["primary_error"])
+	require.NotContains(t, fetch, "attempts")
+}
+
+// TestMCPPipeFallbackFailure verifies that a failing fallback reports both errors.
+func TestMCPPipeFallbackFailure(t *testing.T) {
+	invoker := func(ctx context.Context, toolName string, args any) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultError(toolName + " down"), nil
+	}
+
+	tool, err := NewMCPPipeTool(log.Logger.Named("test_mcp_pipe_fallback_fail"), invoker, PipeLimits{})
+	require.NoError(t, err)
+
+	result, err := tool.Handle(context.Background(), mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"steps": []any{map[string]any{
+			"id": "fetch", "tool": "web_fetch",
+			"fallback": map[string]any{"step": map[string]any{"id": "search", "tool": "web_search"}},
+		}},
+	}}})
+	require.NoError(t, err)
+	require.True(t, result.IsError)
+
+	errMsg := result.StructuredContent.(map[string]any)["error"].(string)
+	require.Contains(t, errMsg, "web_fetch down")
+	require.Contains(t, errMsg, "web_search down")
+}
+
+// TestMCPPipeRetryCountsTowardMaxSteps verifies that retries cannot exceed MaxSteps.
+func TestMCPPipeRetryCountsTowardMaxSteps(t *testing.T) {
+	var calls atomic.Int32
+	invoker := func(ctx context.Context, toolName string, args any) (*mcp.CallToolResult, error) {
+		calls.Add(1)
+		return mcp.NewToolResultError("fail"), nil
+	}
+
+	tool, err := NewMCPPipeTool(log.Logger.Named("test_mcp_pipe_retry_limit"), invoker, PipeLimits{MaxSteps: 2})
+	require.NoError(t, err)
+
+	result, err := tool.Handle(context.Background(), mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{
+		"steps": []any{map[string]any{"id": "a", "tool": "web_fetch", "retry": map[string]any{"count": 5, "backoff_ms": 1}}},
+	}}})
+	require.NoError(t, err)
+	require.True(t, result.IsError)
+	require.EqualValues(t, 2, calls.Load())
+	require.Contains(t, result.StructuredContent.(map[string]any)["error"], "maximum step limit")
+}
+
+// TestPipeRetryDelay verifies exponential backoff with a cap.
+func TestPipeRetryDelay(t *testing.T) {
+	t.Parallel()
+
+	r := &pipeRetry{Count: 5, BackoffMs: 100, MaxBackoffMs: 350}
+	require.Equal(t, 100*time.Millisecond, r.delay(1))
+	require.Equal(t, 200*time.Millisecond, r.delay(2))
+	require.Equal(t, 350*time.Millisecond, r.delay(3))
+
+	require.Equal(t, defaultPipeRetryBackoff, (&pipeRetry{Count: 1}).delay(1))
+}
+
+// TestPipeRetryUnmarshal verifies the integer shorthand.
+func TestPipeRetryUnmarshal(t *testing.T) {
+	t.Parallel()
+
+	var step pipeStep
+	require.NoError(t, json.Unmarshal([]byte(`{"id":"a","tool":"x","retry":2}`), &step))
+	require.Equal(t, 2, step.Retry.Count)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"id":"a","tool":"x","retry":{"count":1,"backoff_ms":50}}`), &step))
+	require.Equal(t, pipeRetry{Count: 1, BackoffMs: 50}, *step.Retry)
+
+	require.Error(t, json.Unmarshal([]byte(`{"id":"a","tool":"x","retry":"often"}`), &step))
+}
+
+// TestValidateStepPolicy verifies retry, timeout, and fallback validation.
+func TestValidateStepPolicy(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		step    pipeStep
+		wantErr string
+	}{
+		{"retry too high", pipeStep{ID: "a", Tool: "x", Retry: &pipeRetry{Count: 6}}, "retry.count"},
+		{"negative backoff", pipeStep{ID: "a", Tool: "x", Retry: &pipeRetry{Count: 1, BackoffMs: -1}}, "cannot be negative"},
+		{"timeout too long", pipeStep{ID: "a", Tool: "x", TimeoutMs: 3_600_000}, "timeout_ms"},
+		{"empty fallback", pipeStep{ID: "a", Tool: "x", Fallback: &pipeFallback{}}, "exactly one of step or value"},
+		{"invalid fallback step", pipeStep{ID: "a", Tool: "x", Fallback: &pipeFallback{Step: &pipeStep{ID: "b"}}}, "fallback of step"},
+		{"valid", pipeStep{ID: "a", Tool: "x", Retry: &pipeRetry{Count: 2}, TimeoutMs: 1000, Fallback: &pipeFallback{Value: json.RawMessage(`"x"`)}}, ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := validateStep(tc.step)
+			if tc.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tc.wantErr)
+		})
+	}
+}
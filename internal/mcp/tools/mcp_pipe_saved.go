@@ -0,0 +1,146 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	errors "github.com/Laisky/errors/v2"
+	mcp "github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/userrequests"
+)
+
+// SavedPipelineToolPrefix prefixes the MCP tool names of saved pipelines so they
+// cannot collide with built-in tools.
+const SavedPipelineToolPrefix = "pipeline_"
+
+// SavedPipelineToolName returns the MCP tool name under which a saved pipeline is exposed.
+func SavedPipelineToolName(name string) string {
+	return SavedPipelineToolPrefix + name
+}
+
+// SavedPipelineNameFromTool returns the saved pipeline name for a tool name, if it is one.
+func SavedPipelineNameFromTool(toolName string) (string, bool) {
+	name, ok := strings.CutPrefix(toolName, SavedPipelineToolPrefix)
+	if !ok || name == "" {
+		return "", false
+	}
+	return name, true
+}
+
+// SavedPipelineDefinition returns the MCP metadata for a saved pipeline.
+// The input schema is derived from the declared vars.
+func SavedPipelineDefinition(pipeline *userrequests.SavedPipeline) mcp.Tool {
+	description := strings.TrimSpace(pipeline.Description)
+	if description == "" {
+		description = fmt.Sprintf("Saved mcp_pipe pipeline %q.", pipeline.Name)
+	}
+	description += " Runs a saved mcp_pipe pipeline; arguments are bound to its ${vars.*}."
+
+	tool := mcp.NewTool(SavedPipelineToolName(pipeline.Name), mcp.WithDescription(description))
+	for _, v := range pipeline.Vars {
+		property := map[string]any{"type": v.Type}
+		if v.Description != "" {
+			property["description"] = v.Description
+		}
+		if v.Default != nil {
+			property["default"] = v.Default
+		}
+		if v.Type == userrequests.SavedPipelineVarArray {
+			property["items"] = map[string]any{}
+		}
+		tool.InputSchema.Properties[v.Name] = property
+		if v.Required && v.Default == nil {
+			tool.InputSchema.Required = append(tool.InputSchema.Required, v.Name)
+		}
+	}
+
+	return tool
+}
+
+// BindSavedPipelineVars validates tool arguments against the declared vars and
+// returns the vars map for the pipeline, with defaults applied.
+func BindSavedPipelineVars(declared []userrequests.SavedPipelineVar, args map[string]any) (map[string]any, error) {
+	known := make(map[string]struct{}, len(declared))
+	vars := make(map[string]any, len(declared))
+	for _, v := range declared {
+		known[v.Name] = struct{}{}
+
+		value, ok := args[v.Name]
+		if !ok || value == nil {
+			if v.Default != nil {
+				vars[v.Name] = v.Default
+				continue
+			}
+			if v.Required {
+				return nil, errors.Errorf("missing required argument: %s", v.Name)
+			}
+			continue
+		}
+		if err := userrequests.CheckSavedPipelineVarType(v.Type, value); err != nil {
+			return nil, errors.Wrapf(err, "argument %s", v.Name)
+		}
+		vars[v.Name] = value
+	}
+
+	var unknown []string
+	for name := range args {
+		if _, ok := known[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, errors.Errorf("unknown arguments: %s", strings.Join(unknown, ", "))
+	}
+
+	return vars, nil
+}
+
+// ValidateSavedSpec checks that a stored spec is a well-formed pipeline.
+// It is registered as the userrequests pipeline spec validator.
+func (t *MCPPipeTool) ValidateSavedSpec(raw json.RawMessage, _ []userrequests.SavedPipelineVar) error {
+	spec, err := parsePipeSpecRaw(string(raw))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	switch spec.Mode {
+	case "", pipeModeSync, pipeModeAsync:
+	default:
+		return errors.Errorf("invalid mode %q: expected sync or async", spec.Mode)
+	}
+
+	seen := make(map[string]struct{}, len(spec.Steps))
+	for _, step := range spec.Steps {
+		if err := validateStep(step); err != nil {
+			return errors.WithStack(err)
+		}
+		if _, ok := seen[step.ID]; ok {
+			return errors.New("duplicate step id: " + step.ID)
+		}
+		seen[step.ID] = struct{}{}
+	}
+	return nil
+}
+
+// RunSaved executes a saved pipeline with the given tool arguments bound as vars.
+func (t *MCPPipeTool) RunSaved(ctx context.Context, pipeline *userrequests.SavedPipeline, args map[string]any) (*mcp.CallToolResult, error) {
+	vars, err := BindSavedPipelineVars(pipeline.Vars, args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	var spec map[string]any
+	if err := json.Unmarshal(pipeline.Spec, &spec); err != nil {
+		return mcp.NewToolResultError("saved pipeline spec is invalid: " + err.Error()), nil
+	}
+	spec["vars"] = vars
+
+	return t.Handle(ctx, mcp.CallToolRequest{Params: mcp.CallToolParams{
+		Name:      SavedPipelineToolName(pipeline.Name),
+		Arguments: map[string]any{"spec": spec},
+	}})
+}
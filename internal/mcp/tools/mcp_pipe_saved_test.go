@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	mcp "github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/userrequests"
+	"github.com/Laisky/laisky-blog-graphql/library/log"
+)
+
+func samplePipelineVars() []userrequests.SavedPipelineVar {
+	return []userrequests.SavedPipelineVar{
+		{Name: "query", Type: userrequests.SavedPipelineVarString, Description: "Search query", Required: true},
+		{Name: "limit", Type: userrequests.SavedPipelineVarInteger, Default: float64(3)},
+		{Name: "tags", Type: userrequests.SavedPipelineVarArray},
+	}
+}
+
+// TestSavedPipelineDefinition verifies that the tool schema is derived from the declared vars.
+func TestSavedPipelineDefinition(t *testing.T) {
+	t.Parallel()
+
+	def := SavedPipelineDefinition(&userrequests.SavedPipeline{Name: "digest", Vars: samplePipelineVars()})
+	require.Equal(t, "pipeline_digest", def.Name)
+	require.Contains(t, def.Description, `"digest"`)
+	require.Equal(t, []string{"query"}, def.InputSchema.Required)
+	require.Equal(t, map[string]any{"type": "string", "description": "Search query"}, def.InputSchema.Properties["query"])
+	require.Equal(t, float64(3), def.InputSchema.Properties["limit"].(map[string]any)["default"])
+	require.Contains(t, def.InputSchema.Properties["tags"], "items")
+
+	name, ok := SavedPipelineNameFromTool(def.Name)
+	require.True(t, ok)
+	require.Equal(t, "digest", name)
+	_, ok = SavedPipelineNameFromTool("mcp_pipe")
+	require.False(t, ok)
+}
+
+// TestBindSavedPipelineVars verifies defaults, required vars, type checks, and unknown arguments.
+func TestBindSavedPipelineVars(t *testing.T) {
+	t.Parallel()
+
+	vars, err := BindSavedPipelineVars(samplePipelineVars(), map[string]any{"query": "go"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"query": "go", "limit": float64(3)}, vars)
+
+	_, err = BindSavedPipelineVars(samplePipelineVars(), map[string]any{})
+	require.ErrorContains(t, err, "missing required argument: query")
+
+	_, err = BindSavedPipelineVars(samplePipelineVars(), map[string]any{"query": "go", "limit": "ten"})
+	require.ErrorContains(t, err, "argument limit")
+
+	_, err = BindSavedPipelineVars(samplePipelineVars(), map[string]any{"query": "go", "extra": 1, "another": 2})
+	require.ErrorContains(t, err, "unknown arguments: another, extra")
+}
+
+// TestMCPPipeRunSaved verifies that a saved pipeline runs with arguments bound as vars.
+func TestMCPPipeRunSaved(t *testing.T) {
+	invoker := func(ctx context.Context, toolName string, args any) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultJSON(map[string]any{"tool": toolName, "args": args})
+	}
+	pipe, err := NewMCPPipeTool(log.Logger.Named("test_mcp_pipe_saved"), invoker, PipeLimits{})
+	require.NoError(t, err)
+
+	pipeline := &userrequests.SavedPipeline{
+		Name: "digest",
+		Vars: samplePipelineVars(),
+		Spec: json.RawMessage(`{"steps":[{"id":"s","tool":"web_search","args":{"query":"${vars.query}","n":{"$ref":"vars.limit"}}}],"return":{"$ref":"steps.s.structured.args"}}`),
+	}
+
+	result, err := pipe.RunSaved(context.Background(), pipeline, map[string]any{"query": "golang"})
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+	require.Equal(t, map[string]any{"query": "golang", "n": float64(3)}, result.StructuredContent.(map[string]any)["result"])
+
+	result, err = pipe.RunSaved(context.Background(), pipeline, map[string]any{})
+	require.NoError(t, err)
+	require.True(t, result.IsError)
+	require.Contains(t, toolResultText(result), "missing required argument")
+}
+
+// TestMCPPipeValidateSavedSpec verifies spec validation used when pipelines are saved.
+func TestMCPPipeValidateSavedSpec(t *testing.T) {
+	t.Parallel()
+
+	pipe, err := NewMCPPipeTool(log.Logger.Named("test_mcp_pipe_saved_validate"), func(context.Context, string, any) (*mcp.CallToolResult, error) {
+		return nil, nil
+	}, PipeLimits{})
+	require.NoError(t, err)
+
+	require.NoError(t, pipe.ValidateSavedSpec(json.RawMessage(`{"steps":[{"id":"a","tool":"x","retry":2}]}`), nil))
+	require.ErrorContains(t, pipe.ValidateSavedSpec(json.RawMessage(`{"steps":[{"id":"a"}]}`), nil), "exactly one")
+	require.ErrorContains(t, pipe.ValidateSavedSpec(json.RawMessage(`{"steps":[{"id":"a","tool":"x"},{"id":"a","tool":"y"}]}`), nil), "duplicate step id")
+	require.ErrorContains(t, pipe.ValidateSavedSpec(json.RawMessage(`{"mode":"later","steps":[{"id":"a","tool":"x"}]}`), nil), "invalid mode")
+}
@@ -228,7 +228,9 @@ func mapFileErrorCodeToMemory(code files.ErrorCode) mcpmemory.ErrorCode {
 		files.ErrCodeQuotaExceeded,
 		files.ErrCodeAlreadyExists,
 		files.ErrCodeNotFound,
-		files.ErrCodeNotEmpty:
+		files.ErrCodeNotEmpty,
+		files.ErrCodeConflict,
+		files.ErrCodePreconditionFailed:
 		return mcpmemory.ErrCodeInvalidArgument
 	default:
 		return mcpmemory.ErrCodeInternal
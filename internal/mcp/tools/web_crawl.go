@@ -0,0 +1,322 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	errors "github.com/Laisky/errors/v2"
+	logSDK "github.com/Laisky/go-utils/v6/log"
+	"github.com/Laisky/zap"
+	"github.com/google/uuid"
+	mcp "github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/crawljobs"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/files"
+	searchlib "github.com/Laisky/laisky-blog-graphql/library/search"
+)
+
+const (
+	// DefaultWebCrawlMaxDepth is how many links away from the seeds a crawl follows by default.
+	DefaultWebCrawlMaxDepth = 2
+	// MaxWebCrawlDepth caps the link depth of a crawl.
+	MaxWebCrawlDepth = 5
+	// DefaultWebCrawlMaxPages is how many pages a crawl fetches by default.
+	DefaultWebCrawlMaxPages = 50
+	// MaxWebCrawlPages caps the pages fetched by a single crawl.
+	MaxWebCrawlPages = 500
+	// defaultWebCrawlTargetDir is the FileIO directory that receives crawled pages.
+	defaultWebCrawlTargetDir = "/crawl"
+)
+
+// errWebCrawlCanceled is the cancellation cause used when the owner cancels a crawl.
+var errWebCrawlCanceled = errors.New("crawl canceled")
+
+// CrawlJobStore persists web_crawl jobs and their checkpoints.
+type CrawlJobStore interface {
+	Create(ctx context.Context, apiKeyHash string, spec any) (*crawljobs.Job, error)
+	MarkRunning(ctx context.Context, jobID uuid.UUID) (bool, error)
+	Checkpoint(ctx context.Context, jobID uuid.UUID, state any) (bool, error)
+	Heartbeat(ctx context.Context, jobID uuid.UUID) (bool, error)
+	Finish(ctx context.Context, jobID uuid.UUID, status string, state any, errMsg string) error
+	Get(ctx context.Context, apiKeyHash string, jobID string) (*crawljobs.Job, error)
+	RequestCancel(ctx context.Context, apiKeyHash string, jobID string) (*crawljobs.Job, error)
+	Resume(ctx context.Context, apiKeyHash string, jobID string) (*crawljobs.Job, error)
+}
+
+// CrawlPageFetcher renders a page for web_crawl and reports the path that produced it.
+type CrawlPageFetcher func(ctx context.Context, url string, apiKey string) (html []byte, source string, err error)
+
+// CrawlFileWriter stores crawled pages, as implemented by FileService.
+type CrawlFileWriter interface {
+	Write(ctx context.Context, auth files.AuthContext, project, path, content, contentEncoding string, offset int64, mode files.WriteMode) (files.WriteResult, error)
+}
+
+// WebCrawlTool implements the web_crawl MCP tool.
+type WebCrawlTool struct {
+	store          CrawlJobStore
+	logger         logSDK.Logger
+	apiKeyProvider APIKeyProvider
+	billingChecker BillingChecker
+	pages          CrawlPageFetcher
+	// plain fetches robots.txt and sitemaps, which need no rendering.
+	plain searchlib.PlainHTTPFetcher
+	files CrawlFileWriter
+	// jobCancels maps job ids running in this process to their cancel functions.
+	jobCancels sync.Map
+	// maxCrawlDelay caps the robots.txt Crawl-delay honored between two pages of a host.
+	maxCrawlDelay time.Duration
+	// heartbeatInterval is how often a running job refreshes its liveness between checkpoints.
+	heartbeatInterval time.Duration
+}
+
+// webCrawlSpec is the persisted description of a crawl.
+type webCrawlSpec struct {
+	URL            string   `json:"url,omitempty"`
+	SitemapURL     string   `json:"sitemap_url,omitempty"`
+	Project        string   `json:"project"`
+	TargetDir      string   `json:"target_dir"`
+	MaxDepth       int      `json:"max_depth"`
+	MaxPages       int      `json:"max_pages"`
+	AllowedDomains []string `json:"allowed_domains"`
+}
+
+// NewWebCrawlTool constructs a WebCrawlTool with the provided dependencies.
+func NewWebCrawlTool(
+	store CrawlJobStore,
+	logger logSDK.Logger,
+	apiKeyProvider APIKeyProvider,
+	billingChecker BillingChecker,
+	pages CrawlPageFetcher,
+	plain searchlib.PlainHTTPFetcher,
+	fileWriter CrawlFileWriter,
+) (*WebCrawlTool, error) {
+	switch {
+	case store == nil:
+		return nil, errors.New("crawl job store is required")
+	case logger == nil:
+		return nil, errors.New("logger is required")
+	case apiKeyProvider == nil:
+		return nil, errors.New("api key provider is required")
+	case billingChecker == nil:
+		return nil, errors.New("billing checker is required")
+	case pages == nil:
+		return nil, errors.New("page fetcher is required")
+	case plain == nil:
+		return nil, errors.New("plain http fetcher is required")
+	case fileWriter == nil:
+		return nil, errors.New("file writer is required")
+	}
+
+	return &WebCrawlTool{
+		store:          store,
+		logger:         logger,
+		apiKeyProvider: apiKeyProvider,
+		billingChecker: billingChecker,
+		pages:          pages,
+		plain:          plain,
+		files:          fileWriter,
+		maxCrawlDelay:  5 * time.Second,
+		// Seeding and single page fetches can outlast crawljobs.DefaultStaleAfter
+		// without a checkpoint, so liveness is refreshed on a timer as well.
+		heartbeatInterval: 30 * time.Second,
+	}, nil
+}
+
+// Definition returns the MCP metadata describing the tool.
+func (t *WebCrawlTool) Definition() mcp.Tool {
+	return mcp.NewTool(
+		"web_crawl",
+		mcp.WithDescription("Crawl a website breadth-first from a seed URL or sitemap and save every page as Markdown into a "+
+			"FileIO project, where file_search indexes it. Use this to ingest a whole documentation site. The crawl runs in the "+
+			"background: it returns a job_id to poll with web_crawl_status, and a stopped crawl can be resumed with resume_job_id. "+
+			"robots.txt is respected and each fetched page is billed like web_fetch."),
+		mcp.WithString("url", mcp.Description("Seed page URL. Required unless sitemap_url or resume_job_id is set.")),
+		mcp.WithString("sitemap_url", mcp.Description("Sitemap or sitemap index whose pages seed the crawl.")),
+		mcp.WithString("project", mcp.Description("FileIO project that receives the pages. Required unless resume_job_id is set.")),
+		mcp.WithString("target_dir", mcp.Description("Directory for the pages, default /crawl. Pages are stored as <target_dir>/<host>/<path>.md.")),
+		mcp.WithNumber("max_depth", mcp.Description(fmt.Sprintf("Links to follow away from the seeds, default %d, max %d. 0 fetches only the seeds.", DefaultWebCrawlMaxDepth, MaxWebCrawlDepth))),
+		mcp.WithNumber("max_pages", mcp.Description(fmt.Sprintf("Maximum pages to fetch, default %d, max %d.", DefaultWebCrawlMaxPages, MaxWebCrawlPages))),
+		mcp.WithArray("allowed_domains",
+			mcp.Description("Hosts the crawl may visit, subdomains included. Defaults to the hosts of url and sitemap_url."),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("resume_job_id", mcp.Description("Resume a failed or canceled crawl from its last checkpoint instead of starting a new one.")),
+		mcp.WithReadOnlyHintAnnotation(false),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithIdempotentHintAnnotation(false),
+		mcp.WithOpenWorldHintAnnotation(true),
+	)
+}
+
+// Handle starts a new crawl job, or resumes a stopped one, and returns its id without waiting.
+func (t *WebCrawlTool) Handle(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	apiKeyHash, ok := pipeAPIKeyHash(ctx)
+	if !ok {
+		return mcp.NewToolResultError("authorization required"), nil
+	}
+	apiKey := t.apiKeyProvider(ctx)
+	if apiKey == "" {
+		return mcp.NewToolResultError("missing authorization bearer token"), nil
+	}
+	if _, ok := fileAuthFromContext(ctx); !ok {
+		return mcp.NewToolResultError("missing authorization"), nil
+	}
+
+	if resumeID := strings.TrimSpace(readStringArg(req, "resume_job_id")); resumeID != "" {
+		return t.resume(ctx, apiKey, apiKeyHash, resumeID)
+	}
+
+	spec, err := parseWebCrawlSpec(req)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	job, err := t.store.Create(ctx, apiKeyHash, spec)
+	if err != nil {
+		if errors.Is(err, crawljobs.ErrActiveJobLimit) {
+			return mcp.NewToolResultError(fmt.Sprintf("too many active crawls (limit %d); wait for a crawl to finish or cancel one", crawljobs.MaxActiveJobsPerKey)), nil
+		}
+		t.logger.Error("web_crawl create job", zap.Error(err))
+		return mcp.NewToolResultError("failed to create crawl job"), nil
+	}
+
+	t.start(ctx, apiKey, job.ID, spec, &webCrawlState{})
+	return mcp.NewToolResultJSON(map[string]any{
+		"job_id": job.ID.String(),
+		"status": job.Status,
+	})
+}
+
+// resume restarts a failed or canceled job from its last checkpoint.
+func (t *WebCrawlTool) resume(ctx context.Context, apiKey, apiKeyHash, jobID string) (*mcp.CallToolResult, error) {
+	job, err := t.store.Resume(ctx, apiKeyHash, jobID)
+	switch {
+	case errors.Is(err, crawljobs.ErrJobNotFound):
+		return mcp.NewToolResultError("crawl job not found"), nil
+	case errors.Is(err, crawljobs.ErrJobNotResumable):
+		return mcp.NewToolResultError("crawl job is still running or already succeeded"), nil
+	case errors.Is(err, crawljobs.ErrActiveJobLimit):
+		return mcp.NewToolResultError(fmt.Sprintf("too many active crawls (limit %d); wait for a crawl to finish or cancel one", crawljobs.MaxActiveJobsPerKey)), nil
+	case err != nil:
+		t.logger.Error("web_crawl resume job", zap.String("job_id", jobID), zap.Error(err))
+		return mcp.NewToolResultError("failed to resume crawl job"), nil
+	}
+
+	spec := new(webCrawlSpec)
+	if err := json.Unmarshal(job.Spec, spec); err != nil {
+		t.logger.Error("web_crawl decode job spec", zap.String("job_id", jobID), zap.Error(err))
+		return mcp.NewToolResultError("failed to resume crawl job"), nil
+	}
+	state := new(webCrawlState)
+	if len(job.State) > 0 {
+		if err := json.Unmarshal(job.State, state); err != nil {
+			t.logger.Warn("web_crawl discard malformed checkpoint", zap.String("job_id", jobID), zap.Error(err))
+			state = new(webCrawlState)
+		}
+	}
+
+	t.start(ctx, apiKey, job.ID, spec, state)
+	return mcp.NewToolResultJSON(map[string]any{
+		"job_id":  job.ID.String(),
+		"status":  job.Status,
+		"resumed": true,
+	})
+}
+
+// start runs the job in the background. The run is detached from the request so it
+// survives client disconnects, while keeping request values such as the caller identity.
+func (t *WebCrawlTool) start(ctx context.Context, apiKey string, jobID uuid.UUID, spec *webCrawlSpec, state *webCrawlState) {
+	runCtx, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
+	t.jobCancels.Store(jobID.String(), cancel)
+	go t.runJob(runCtx, cancel, jobID, apiKey, spec, state)
+}
+
+// cancelLocalJob interrupts a job running in this process. It reports whether the job was found.
+func (t *WebCrawlTool) cancelLocalJob(jobID string) bool {
+	value, ok := t.jobCancels.Load(jobID)
+	if !ok {
+		return false
+	}
+	cancel, ok := value.(context.CancelCauseFunc)
+	if !ok {
+		return false
+	}
+	cancel(errWebCrawlCanceled)
+	return true
+}
+
+// parseWebCrawlSpec validates the arguments of a new crawl and applies defaults.
+func parseWebCrawlSpec(req mcp.CallToolRequest) (*webCrawlSpec, error) {
+	spec := &webCrawlSpec{
+		URL:        strings.TrimSpace(readStringArg(req, "url")),
+		SitemapURL: strings.TrimSpace(readStringArg(req, "sitemap_url")),
+		Project:    strings.TrimSpace(readStringArg(req, "project")),
+		TargetDir:  strings.TrimRight(strings.TrimSpace(readStringArg(req, "target_dir")), "/"),
+		MaxDepth:   readIntArgWithDefault(req, "max_depth", DefaultWebCrawlMaxDepth),
+		MaxPages:   readIntArgWithDefault(req, "max_pages", DefaultWebCrawlMaxPages),
+	}
+	if spec.URL == "" && spec.SitemapURL == "" {
+		return nil, errors.New("url or sitemap_url is required")
+	}
+	var seedHosts []string
+	for _, raw := range []string{spec.URL, spec.SitemapURL} {
+		if raw == "" {
+			continue
+		}
+		parsed, err := url.Parse(raw)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+			return nil, errors.Errorf("invalid url %q: only absolute http and https urls are allowed", raw)
+		}
+		seedHosts = append(seedHosts, strings.ToLower(parsed.Hostname()))
+	}
+
+	if err := files.ValidateProject(spec.Project); err != nil {
+		return nil, errors.Wrap(err, "invalid project")
+	}
+	if spec.TargetDir == "" {
+		spec.TargetDir = defaultWebCrawlTargetDir
+	}
+	if err := files.ValidatePath(spec.TargetDir); err != nil {
+		return nil, errors.Wrap(err, "invalid target_dir")
+	}
+	if spec.MaxDepth < 0 || spec.MaxDepth > MaxWebCrawlDepth {
+		return nil, errors.Errorf("max_depth must be between 0 and %d", MaxWebCrawlDepth)
+	}
+	if spec.MaxPages < 1 || spec.MaxPages > MaxWebCrawlPages {
+		return nil, errors.Errorf("max_pages must be between 1 and %d", MaxWebCrawlPages)
+	}
+
+	domains := req.GetStringSlice("allowed_domains", nil)
+	if len(domains) == 0 {
+		domains = seedHosts
+	}
+	seen := make(map[string]struct{}, len(domains))
+	for _, domain := range domains {
+		domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if domain == "" || strings.ContainsAny(domain, "/:") {
+			return nil, errors.Errorf("invalid allowed domain %q: use a bare host name", domain)
+		}
+		if _, dup := seen[domain]; dup {
+			continue
+		}
+		seen[domain] = struct{}{}
+		spec.AllowedDomains = append(spec.AllowedDomains, domain)
+	}
+	return spec, nil
+}
+
+// allows reports whether host is one of the allowed domains or a subdomain of one.
+func (s *webCrawlSpec) allows(host string) bool {
+	host = strings.ToLower(host)
+	for _, domain := range s.AllowedDomains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
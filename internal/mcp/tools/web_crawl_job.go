@@ -0,0 +1,647 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	errors "github.com/Laisky/errors/v2"
+	logSDK "github.com/Laisky/go-utils/v6/log"
+	"github.com/Laisky/zap"
+	"github.com/google/uuid"
+	mcp "github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/crawljobs"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/files"
+	"github.com/Laisky/laisky-blog-graphql/library/billing/oneapi"
+	searchlib "github.com/Laisky/laisky-blog-graphql/library/search"
+)
+
+const (
+	// webCrawlRobotsAgent is the product token matched against robots.txt groups.
+	webCrawlRobotsAgent = "laisky-mcp-web-fetch"
+	// maxWebCrawlFrontier caps the URLs queued by one crawl.
+	maxWebCrawlFrontier = 2000
+	// maxWebCrawlSitemaps caps the sitemap documents read while seeding a crawl.
+	maxWebCrawlSitemaps = 20
+	// maxWebCrawlFailures caps the per-page errors kept in the checkpoint.
+	maxWebCrawlFailures = 20
+	// maxWebCrawlPathLen mirrors the FileIO path length limit.
+	maxWebCrawlPathLen = 512
+
+	webCrawlSkipRobots       = "robots"
+	webCrawlSkipNotHTML      = "not_html"
+	webCrawlSkipFrontierFull = "frontier_full"
+)
+
+// webCrawlAssetExt matches links to files that are never HTML, which are skipped before they are billed.
+var webCrawlAssetExt = regexp.MustCompile(`(?i)\.(png|jpe?g|gif|webp|svg|ico|bmp|pdf|zip|gz|tgz|tar|rar|7z|exe|dmg|iso|mp3|mp4|webm|avi|mov|woff2?|ttf|otf|css|js|json|xml|rss)$`)
+
+// webCrawlState is the resumable progress of a crawl, stored at every checkpoint.
+type webCrawlState struct {
+	// Initialized is set once the seeds and sitemaps have been queued.
+	Initialized bool            `json:"initialized"`
+	Frontier    []webCrawlItem  `json:"frontier,omitempty"`
+	Seen        map[string]bool `json:"seen,omitempty"`
+
+	PagesFetched int               `json:"pages_fetched"`
+	PagesWritten int               `json:"pages_written"`
+	PagesFailed  int               `json:"pages_failed"`
+	Skipped      map[string]int    `json:"skipped,omitempty"`
+	Failures     []webCrawlFailure `json:"failures,omitempty"`
+	Pages        []webCrawlPage    `json:"pages,omitempty"`
+}
+
+// webCrawlItem is one queued URL and its link distance from the seeds.
+type webCrawlItem struct {
+	URL   string `json:"url"`
+	Depth int    `json:"depth"`
+}
+
+// webCrawlFailure records why a page could not be stored.
+type webCrawlFailure struct {
+	URL   string `json:"url"`
+	Error string `json:"error"`
+}
+
+// webCrawlPage records where a crawled page was written.
+type webCrawlPage struct {
+	URL    string `json:"url"`
+	Path   string `json:"path"`
+	Source string `json:"source,omitempty"`
+}
+
+// enqueue queues rawURL at depth unless it was already seen. It reports false when the frontier is full.
+func (s *webCrawlState) enqueue(rawURL string, depth int) bool {
+	key := searchlib.CanonicalURL(rawURL)
+	if s.Seen[key] {
+		return true
+	}
+	if len(s.Frontier) >= maxWebCrawlFrontier {
+		s.skip(webCrawlSkipFrontierFull)
+		return false
+	}
+	if s.Seen == nil {
+		s.Seen = make(map[string]bool)
+	}
+	s.Seen[key] = true
+	s.Frontier = append(s.Frontier, webCrawlItem{URL: rawURL, Depth: depth})
+	return true
+}
+
+// skip counts a URL that was dropped for reason.
+func (s *webCrawlState) skip(reason string) {
+	if s.Skipped == nil {
+		s.Skipped = make(map[string]int)
+	}
+	s.Skipped[reason]++
+}
+
+// fail counts a page that could not be stored and keeps the first few errors.
+func (s *webCrawlState) fail(pageURL string, err error) {
+	s.PagesFailed++
+	if len(s.Failures) < maxWebCrawlFailures {
+		s.Failures = append(s.Failures, webCrawlFailure{URL: pageURL, Error: err.Error()})
+	}
+}
+
+// webCrawlRun holds what one execution of a job needs besides its persisted state.
+type webCrawlRun struct {
+	jobID  uuid.UUID
+	apiKey string
+	spec   *webCrawlSpec
+	state  *webCrawlState
+	auth   files.AuthContext
+	logger logSDK.Logger
+	// robots caches the parsed robots.txt of every host visited in this run.
+	robots map[string]*searchlib.RobotsRules
+	// lastFetch is when each host was last fetched, used to honor Crawl-delay.
+	lastFetch map[string]time.Time
+}
+
+// runJob executes a crawl and records its final status.
+func (t *WebCrawlTool) runJob(ctx context.Context, cancel context.CancelCauseFunc, jobID uuid.UUID, apiKey string, spec *webCrawlSpec, state *webCrawlState) {
+	logger := t.logger.With(zap.String("job_id", jobID.String()))
+	defer t.jobCancels.Delete(jobID.String())
+	defer cancel(nil)
+
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("web_crawl job panicked", zap.Any("panic", r))
+			if err := t.store.Finish(ctx, jobID, crawljobs.StatusFailed, state, fmt.Sprintf("internal error: %v", r)); err != nil {
+				logger.Error("web_crawl record panicked job", zap.Error(err))
+			}
+		}
+	}()
+
+	started, err := t.store.MarkRunning(ctx, jobID)
+	if err != nil {
+		logger.Error("web_crawl mark job running", zap.Error(err))
+		if finishErr := t.store.Finish(ctx, jobID, crawljobs.StatusFailed, state, "failed to start crawl"); finishErr != nil {
+			logger.Error("web_crawl record failed job", zap.Error(finishErr))
+		}
+		return
+	}
+	if !started {
+		logger.Debug("web_crawl job canceled before start")
+		return
+	}
+
+	auth, _ := fileAuthFromContext(ctx)
+	run := &webCrawlRun{
+		jobID:     jobID,
+		apiKey:    apiKey,
+		spec:      spec,
+		state:     state,
+		auth:      auth,
+		logger:    logger,
+		robots:    make(map[string]*searchlib.RobotsRules),
+		lastFetch: make(map[string]time.Time),
+	}
+	heartbeatDone := make(chan struct{})
+	go t.heartbeatJob(ctx, cancel, logger, jobID, heartbeatDone)
+	crawlErr := t.crawl(ctx, cancel, run)
+	close(heartbeatDone)
+
+	status := crawljobs.StatusSucceeded
+	errMsg := ""
+	switch {
+	case errors.Is(context.Cause(ctx), errWebCrawlCanceled):
+		status = crawljobs.StatusCanceled
+		errMsg = errWebCrawlCanceled.Error()
+	case crawlErr != nil:
+		status = crawljobs.StatusFailed
+		errMsg = crawlErr.Error()
+	}
+
+	if err := t.store.Finish(ctx, jobID, status, state, errMsg); err != nil {
+		logger.Error("web_crawl record finished job", zap.Error(err))
+		return
+	}
+	logger.Info("web_crawl job finished",
+		zap.String("status", status),
+		zap.Int("pages_fetched", state.PagesFetched),
+		zap.Int("pages_written", state.PagesWritten))
+}
+
+// heartbeatJob refreshes the liveness of job jobID until done is closed, and cancels
+// the run when its owner asked for it from another server instance.
+func (t *WebCrawlTool) heartbeatJob(ctx context.Context, cancel context.CancelCauseFunc, logger logSDK.Logger, jobID uuid.UUID, done <-chan struct{}) {
+	ticker := time.NewTicker(t.heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cancelRequested, err := t.store.Heartbeat(ctx, jobID)
+			if err != nil {
+				logger.Warn("web_crawl job heartbeat", zap.Error(err))
+				continue
+			}
+			if cancelRequested {
+				cancel(errWebCrawlCanceled)
+				return
+			}
+		}
+	}
+}
+
+// crawl visits the frontier breadth-first until it is empty or the page limit is reached.
+func (t *WebCrawlTool) crawl(ctx context.Context, cancel context.CancelCauseFunc, run *webCrawlRun) error {
+	state := run.state
+	if !state.Initialized {
+		if err := t.seed(ctx, run); err != nil {
+			return errors.WithStack(err)
+		}
+		state.Initialized = true
+		t.checkpoint(ctx, cancel, run)
+	}
+
+	for len(state.Frontier) > 0 && state.PagesFetched < run.spec.MaxPages {
+		if ctx.Err() != nil {
+			return errors.WithStack(context.Cause(ctx))
+		}
+
+		item := state.Frontier[0]
+		state.Frontier = state.Frontier[1:]
+		parsed, err := url.Parse(item.URL)
+		if err != nil {
+			state.fail(item.URL, errors.Wrap(err, "parse url"))
+			continue
+		}
+		rules := t.robotsFor(ctx, run, parsed)
+		if !rules.Allowed(item.URL) {
+			state.skip(webCrawlSkipRobots)
+			continue
+		}
+		if err := t.waitCrawlDelay(ctx, run, parsed.Host, rules); err != nil {
+			state.Frontier = append([]webCrawlItem{item}, state.Frontier...)
+			return errors.WithStack(err)
+		}
+
+		body, source, err := t.pages(ctx, item.URL, run.apiKey)
+		run.lastFetch[parsed.Host] = time.Now()
+		if err != nil {
+			// Pages that fail to fetch, including those blocked by the SSRF checks, are not billed.
+			run.logger.Debug("web_crawl fetch failed", zap.String("url", item.URL), zap.Error(err))
+			state.fail(item.URL, errors.Wrap(err, "fetch page"))
+			t.checkpoint(ctx, cancel, run)
+			continue
+		}
+
+		if err := t.billingChecker(ctx, run.apiKey, oneapi.PriceWebFetch, "web_crawl"); err != nil {
+			// Keep the page queued so that a resumed crawl fetches it again once billing succeeds.
+			state.Frontier = append([]webCrawlItem{item}, state.Frontier...)
+			return errors.Wrap(err, "billing check failed")
+		}
+		state.PagesFetched++
+
+		if err := t.visit(ctx, run, item, body, source); err != nil {
+			run.logger.Debug("web_crawl page failed", zap.String("url", item.URL), zap.Error(err))
+			state.fail(item.URL, err)
+		}
+		t.checkpoint(ctx, cancel, run)
+	}
+	return nil
+}
+
+// seed queues the start URL and every in-scope page listed by the sitemaps at depth 0.
+func (t *WebCrawlTool) seed(ctx context.Context, run *webCrawlRun) error {
+	spec, state := run.spec, run.state
+	if spec.URL != "" {
+		state.enqueue(spec.URL, 0)
+	}
+	if spec.SitemapURL == "" {
+		return nil
+	}
+
+	pending := []string{spec.SitemapURL}
+	visited := make(map[string]bool)
+	var firstErr error
+	for len(pending) > 0 && len(visited) < maxWebCrawlSitemaps {
+		sitemapURL := pending[0]
+		pending = pending[1:]
+		if visited[sitemapURL] {
+			continue
+		}
+		visited[sitemapURL] = true
+
+		body, _, err := t.plain(ctx, sitemapURL)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = errors.Wrapf(err, "fetch sitemap %s", sitemapURL)
+			}
+			continue
+		}
+		sitemap, err := searchlib.ParseSitemap(body)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = errors.Wrapf(err, "parse sitemap %s", sitemapURL)
+			}
+			continue
+		}
+		pending = append(pending, sitemap.Sitemaps...)
+		for _, pageURL := range sitemap.URLs {
+			if !t.inScope(spec, pageURL) {
+				continue
+			}
+			if !state.enqueue(pageURL, 0) {
+				return nil
+			}
+		}
+	}
+
+	// A broken sitemap is only fatal when nothing else can seed the crawl.
+	if len(state.Frontier) == 0 && firstErr != nil {
+		return firstErr
+	}
+	if firstErr != nil {
+		run.logger.Warn("web_crawl read sitemap", zap.Error(firstErr))
+	}
+	return nil
+}
+
+// visit queues the links of one fetched page and writes it to FileIO.
+func (t *WebCrawlTool) visit(ctx context.Context, run *webCrawlRun, item webCrawlItem, body []byte, source string) error {
+	contentType := http.DetectContentType(body)
+	if !strings.HasPrefix(contentType, "text/html") {
+		run.state.skip(webCrawlSkipNotHTML)
+		return nil
+	}
+
+	if item.Depth < run.spec.MaxDepth {
+		links, err := searchlib.ExtractPageLinks(item.URL, body)
+		if err != nil {
+			return errors.Wrap(err, "extract links")
+		}
+		for _, link := range links {
+			if !t.inScope(run.spec, link.URL) {
+				continue
+			}
+			if !run.state.enqueue(link.URL, item.Depth+1) {
+				break
+			}
+		}
+	}
+
+	markdown, err := searchlib.HTMLToMarkdown(item.URL, body)
+	if err != nil {
+		return errors.Wrap(err, "convert page to markdown")
+	}
+	content := fmt.Sprintf("---\nsource_url: %s\nfetched_at: %s\n---\n\n%s\n",
+		strconv.Quote(item.URL), strconv.Quote(time.Now().UTC().Format(time.RFC3339)), markdown)
+
+	filePath := webCrawlFilePath(run.spec.TargetDir, item.URL)
+	if _, err := t.files.Write(ctx, run.auth, run.spec.Project, filePath, content, "utf-8", 0, files.WriteModeTruncate); err != nil {
+		return errors.Wrapf(err, "write %s", filePath)
+	}
+	run.state.PagesWritten++
+	run.state.Pages = append(run.state.Pages, webCrawlPage{URL: item.URL, Path: filePath, Source: source})
+	return nil
+}
+
+// checkpoint persists the crawl state and cancels the run when its owner asked for it.
+func (t *WebCrawlTool) checkpoint(ctx context.Context, cancel context.CancelCauseFunc, run *webCrawlRun) {
+	cancelRequested, err := t.store.Checkpoint(ctx, run.jobID, run.state)
+	if err != nil {
+		run.logger.Warn("web_crawl save checkpoint", zap.Error(err))
+		return
+	}
+	if cancelRequested {
+		cancel(errWebCrawlCanceled)
+	}
+}
+
+// inScope reports whether rawURL is an http(s) page on an allowed domain.
+func (t *WebCrawlTool) inScope(spec *webCrawlSpec, rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return false
+	}
+	if webCrawlAssetExt.MatchString(parsed.Path) {
+		return false
+	}
+	return spec.allows(parsed.Hostname())
+}
+
+// robotsFor returns the robots.txt rules of pageURL's host, fetching them once per run.
+// Hosts whose robots.txt cannot be read are crawled without restrictions.
+func (t *WebCrawlTool) robotsFor(ctx context.Context, run *webCrawlRun, pageURL *url.URL) *searchlib.RobotsRules {
+	origin := pageURL.Scheme + "://" + pageURL.Host
+	if rules, ok := run.robots[origin]; ok {
+		return rules
+	}
+
+	var rules *searchlib.RobotsRules
+	body, _, err := t.plain(ctx, origin+"/robots.txt")
+	if err != nil {
+		run.logger.Debug("web_crawl robots.txt unavailable", zap.String("origin", origin), zap.Error(err))
+	} else {
+		rules = searchlib.ParseRobotsTxt(body, webCrawlRobotsAgent)
+	}
+	run.robots[origin] = rules
+	return rules
+}
+
+// waitCrawlDelay sleeps until the host's Crawl-delay has elapsed since its last fetch.
+func (t *WebCrawlTool) waitCrawlDelay(ctx context.Context, run *webCrawlRun, host string, rules *searchlib.RobotsRules) error {
+	last, ok := run.lastFetch[host]
+	if !ok || rules == nil {
+		return nil
+	}
+	delay := min(rules.CrawlDelay, t.maxCrawlDelay)
+	wait := time.Until(last.Add(delay))
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-timer.C:
+		return nil
+	}
+}
+
+// webCrawlPathSegment matches the characters FileIO does not accept in paths.
+var webCrawlPathSegment = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// webCrawlFilePath maps pageURL to <targetDir>/<host>/<path>.md. Directory URLs
+// become index.md, common page extensions are dropped, and URLs with a query get a
+// hash suffix so that they do not overwrite each other.
+func webCrawlFilePath(targetDir, pageURL string) string {
+	parsed, err := url.Parse(pageURL)
+	if err != nil {
+		return targetDir + "/" + shortHash(pageURL) + ".md"
+	}
+
+	host := webCrawlPathSegment.ReplaceAllString(strings.ToLower(parsed.Host), "_")
+	pagePath := parsed.Path
+	if pagePath == "" || strings.HasSuffix(pagePath, "/") {
+		pagePath += "index"
+	}
+	switch strings.ToLower(path.Ext(pagePath)) {
+	case ".html", ".htm", ".php", ".asp", ".aspx":
+		pagePath = strings.TrimSuffix(pagePath, path.Ext(pagePath))
+	}
+
+	segments := []string{targetDir, host}
+	for _, segment := range strings.Split(pagePath, "/") {
+		segment = strings.Trim(webCrawlPathSegment.ReplaceAllString(segment, "-"), "-")
+		switch segment {
+		case "":
+			continue
+		case ".", "..":
+			segment = "_"
+		}
+		segments = append(segments, segment)
+	}
+	name := strings.Join(segments, "/")
+	if parsed.RawQuery != "" {
+		name += "-" + shortHash(parsed.RawQuery)
+	}
+
+	const ext = ".md"
+	if len(name)+len(ext) > maxWebCrawlPathLen {
+		suffix := "-" + shortHash(pageURL)
+		name = strings.TrimRight(name[:maxWebCrawlPathLen-len(ext)-len(suffix)], "/-.") + suffix
+	}
+	name += ext
+	if files.ValidatePath(name) != nil {
+		return targetDir + "/" + host + "/" + shortHash(pageURL) + ext
+	}
+	return name
+}
+
+// shortHash returns a short stable digest of value for use in file names.
+func shortHash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])[:10]
+}
+
+// webCrawlJobPayload converts a persisted job into the JSON shape returned by the companion tools.
+func webCrawlJobPayload(job *crawljobs.Job) map[string]any {
+	payload := map[string]any{
+		"job_id":           job.ID.String(),
+		"status":           job.Status,
+		"done":             job.Terminal(),
+		"ok":               job.Status == crawljobs.StatusSucceeded,
+		"resumable":        job.Resumable(),
+		"error":            job.ErrorMessage,
+		"cancel_requested": job.CancelRequested,
+		"created_at":       job.CreatedAt.UTC().Format(time.RFC3339Nano),
+		"updated_at":       job.UpdatedAt.UTC().Format(time.RFC3339Nano),
+		"spec":             decodeRunJSON(job.Spec, map[string]any{}),
+	}
+	if job.StartedAt != nil {
+		payload["started_at"] = job.StartedAt.UTC().Format(time.RFC3339Nano)
+	}
+	if job.FinishedAt != nil {
+		payload["finished_at"] = job.FinishedAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	state := new(webCrawlState)
+	if len(job.State) > 0 {
+		_ = json.Unmarshal(job.State, state)
+	}
+	payload["progress"] = map[string]any{
+		"pages_fetched":  state.PagesFetched,
+		"pages_written":  state.PagesWritten,
+		"pages_failed":   state.PagesFailed,
+		"queued":         len(state.Frontier),
+		"skipped":        state.Skipped,
+		"billed_credits": state.PagesFetched * oneapi.PriceWebFetch.Int(),
+	}
+	if len(state.Failures) > 0 {
+		payload["failures"] = state.Failures
+	}
+	if len(state.Pages) > 0 {
+		payload["pages"] = state.Pages
+	}
+	return payload
+}
+
+// WebCrawlStatusTool implements the web_crawl_status MCP tool.
+type WebCrawlStatusTool struct {
+	crawl *WebCrawlTool
+}
+
+// NewWebCrawlStatusTool constructs a WebCrawlStatusTool backed by crawl's job store.
+func NewWebCrawlStatusTool(crawl *WebCrawlTool) (*WebCrawlStatusTool, error) {
+	if crawl == nil {
+		return nil, errors.New("web_crawl tool is required")
+	}
+	return &WebCrawlStatusTool{crawl: crawl}, nil
+}
+
+// Definition returns the MCP metadata describing the tool.
+func (t *WebCrawlStatusTool) Definition() mcp.Tool {
+	return mcp.NewTool(
+		"web_crawl_status",
+		mcp.WithDescription("Get the status and progress of a web_crawl job: pages fetched and written, the FileIO paths of the stored pages, skipped URLs, errors, and credits billed."),
+		mcp.WithString(
+			"job_id",
+			mcp.Required(),
+			mcp.Description("Job identifier returned by web_crawl."),
+		),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithIdempotentHintAnnotation(true),
+		mcp.WithOpenWorldHintAnnotation(false),
+	)
+}
+
+// Handle returns the current state of a crawl owned by the caller.
+func (t *WebCrawlStatusTool) Handle(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	apiKeyHash, ok := pipeAPIKeyHash(ctx)
+	if !ok {
+		return mcp.NewToolResultError("authorization required"), nil
+	}
+	jobID := strings.TrimSpace(readStringArg(req, "job_id"))
+	if jobID == "" {
+		return mcp.NewToolResultError("job_id is required"), nil
+	}
+
+	job, err := t.crawl.store.Get(ctx, apiKeyHash, jobID)
+	if err != nil {
+		if errors.Is(err, crawljobs.ErrJobNotFound) {
+			return mcp.NewToolResultError("crawl job not found"), nil
+		}
+		t.crawl.logger.Error("web_crawl_status load job", zap.String("job_id", jobID), zap.Error(err))
+		return mcp.NewToolResultError("failed to load crawl job"), nil
+	}
+
+	return mcp.NewToolResultJSON(webCrawlJobPayload(job))
+}
+
+// WebCrawlCancelTool implements the web_crawl_cancel MCP tool.
+type WebCrawlCancelTool struct {
+	crawl *WebCrawlTool
+}
+
+// NewWebCrawlCancelTool constructs a WebCrawlCancelTool backed by crawl's job store.
+func NewWebCrawlCancelTool(crawl *WebCrawlTool) (*WebCrawlCancelTool, error) {
+	if crawl == nil {
+		return nil, errors.New("web_crawl tool is required")
+	}
+	return &WebCrawlCancelTool{crawl: crawl}, nil
+}
+
+// Definition returns the MCP metadata describing the tool.
+func (t *WebCrawlCancelTool) Definition() mcp.Tool {
+	return mcp.NewTool(
+		"web_crawl_cancel",
+		mcp.WithDescription("Cancel a web_crawl job. Pages already written stay in FileIO, and the job can be resumed later with web_crawl resume_job_id."),
+		mcp.WithString(
+			"job_id",
+			mcp.Required(),
+			mcp.Description("Job identifier returned by web_crawl."),
+		),
+		mcp.WithReadOnlyHintAnnotation(false),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithIdempotentHintAnnotation(true),
+		mcp.WithOpenWorldHintAnnotation(false),
+	)
+}
+
+// Handle requests cancellation of a crawl owned by the caller.
+func (t *WebCrawlCancelTool) Handle(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	apiKeyHash, ok := pipeAPIKeyHash(ctx)
+	if !ok {
+		return mcp.NewToolResultError("authorization required"), nil
+	}
+	jobID := strings.TrimSpace(readStringArg(req, "job_id"))
+	if jobID == "" {
+		return mcp.NewToolResultError("job_id is required"), nil
+	}
+
+	job, err := t.crawl.store.RequestCancel(ctx, apiKeyHash, jobID)
+	if err != nil {
+		if errors.Is(err, crawljobs.ErrJobNotFound) {
+			return mcp.NewToolResultError("crawl job not found"), nil
+		}
+		t.crawl.logger.Error("web_crawl_cancel request cancel", zap.String("job_id", jobID), zap.Error(err))
+		return mcp.NewToolResultError("failed to cancel crawl job"), nil
+	}
+
+	// Jobs owned by this instance stop immediately; others stop at their next checkpoint.
+	if job.CancelRequested && !job.Terminal() {
+		t.crawl.cancelLocalJob(job.ID.String())
+	}
+
+	return mcp.NewToolResultJSON(webCrawlJobPayload(job))
+}
@@ -0,0 +1,446 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	errors "github.com/Laisky/errors/v2"
+	"github.com/google/uuid"
+	mcp "github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/crawljobs"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/files"
+	"github.com/Laisky/laisky-blog-graphql/library/billing/oneapi"
+	"github.com/Laisky/laisky-blog-graphql/library/log"
+)
+
+// memoryCrawlJobStore is an in-memory CrawlJobStore for tests.
+type memoryCrawlJobStore struct {
+	mu         sync.Mutex
+	jobs       map[uuid.UUID]*crawljobs.Job
+	finished   chan uuid.UUID
+	heartbeats int
+}
+
+func newMemoryCrawlJobStore() *memoryCrawlJobStore {
+	return &memoryCrawlJobStore{jobs: map[uuid.UUID]*crawljobs.Job{}, finished: make(chan uuid.UUID, 8)}
+}
+
+func (s *memoryCrawlJobStore) Create(_ context.Context, apiKeyHash string, spec any) (*crawljobs.Job, error) {
+	payload, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	job := &crawljobs.Job{ID: uuid.New(), APIKeyHash: apiKeyHash, Status: crawljobs.StatusPending, Spec: payload, CreatedAt: now, UpdatedAt: now}
+	s.jobs[job.ID] = job
+	copied := *job
+	return &copied, nil
+}
+
+func (s *memoryCrawlJobStore) MarkRunning(_ context.Context, jobID uuid.UUID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job := s.jobs[jobID]
+	if job.Status != crawljobs.StatusPending || job.CancelRequested {
+		return false, nil
+	}
+	job.Status = crawljobs.StatusRunning
+	return true, nil
+}
+
+func (s *memoryCrawlJobStore) Checkpoint(_ context.Context, jobID uuid.UUID, state any) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job := s.jobs[jobID]
+	job.State, _ = json.Marshal(state)
+	return job.CancelRequested, nil
+}
+
+func (s *memoryCrawlJobStore) Heartbeat(_ context.Context, jobID uuid.UUID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.heartbeats++
+	return s.jobs[jobID].CancelRequested, nil
+}
+
+func (s *memoryCrawlJobStore) Finish(_ context.Context, jobID uuid.UUID, status string, state any, errMsg string) error {
+	s.mu.Lock()
+	job := s.jobs[jobID]
+	now := time.Now().UTC()
+	job.Status = status
+	job.State, _ = json.Marshal(state)
+	job.ErrorMessage = errMsg
+	job.FinishedAt = &now
+	s.mu.Unlock()
+	s.finished <- jobID
+	return nil
+}
+
+func (s *memoryCrawlJobStore) Get(_ context.Context, apiKeyHash string, jobID string) (*crawljobs.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, err := uuid.Parse(jobID)
+	if err != nil {
+		return nil, crawljobs.ErrJobNotFound
+	}
+	job, ok := s.jobs[id]
+	if !ok || job.APIKeyHash != apiKeyHash {
+		return nil, crawljobs.ErrJobNotFound
+	}
+	copied := *job
+	return &copied, nil
+}
+
+func (s *memoryCrawlJobStore) RequestCancel(ctx context.Context, apiKeyHash string, jobID string) (*crawljobs.Job, error) {
+	if _, err := s.Get(ctx, apiKeyHash, jobID); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	job := s.jobs[uuid.MustParse(jobID)]
+	if !job.Terminal() {
+		job.CancelRequested = true
+	}
+	s.mu.Unlock()
+	return s.Get(ctx, apiKeyHash, jobID)
+}
+
+func (s *memoryCrawlJobStore) Resume(ctx context.Context, apiKeyHash string, jobID string) (*crawljobs.Job, error) {
+	job, err := s.Get(ctx, apiKeyHash, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if !job.Resumable() {
+		return nil, crawljobs.ErrJobNotResumable
+	}
+	s.mu.Lock()
+	stored := s.jobs[job.ID]
+	stored.Status = crawljobs.StatusPending
+	stored.CancelRequested = false
+	stored.ErrorMessage = ""
+	stored.FinishedAt = nil
+	s.mu.Unlock()
+	return s.Get(ctx, apiKeyHash, jobID)
+}
+
+// memoryCrawlFiles records the pages written by a crawl.
+type memoryCrawlFiles struct {
+	mu    sync.Mutex
+	files map[string]string
+}
+
+func (f *memoryCrawlFiles) Write(_ context.Context, _ files.AuthContext, project, path, content, _ string, _ int64, mode files.WriteMode) (files.WriteResult, error) {
+	if mode != files.WriteModeTruncate {
+		return files.WriteResult{}, errors.Errorf("unexpected write mode %q", mode)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.files == nil {
+		f.files = map[string]string{}
+	}
+	f.files[project+":"+path] = content
+	return files.WriteResult{BytesWritten: int64(len(content))}, nil
+}
+
+// crawlTestSite serves fixed pages and counts how often each one is rendered.
+type crawlTestSite struct {
+	mu      sync.Mutex
+	pages   map[string]string
+	plain   map[string]string
+	fetched []string
+	// delay slows every page fetch down.
+	delay time.Duration
+}
+
+func (s *crawlTestSite) fetchPage(_ context.Context, url, _ string) ([]byte, string, error) {
+	time.Sleep(s.delay)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetched = append(s.fetched, url)
+	body, ok := s.pages[url]
+	if !ok {
+		return nil, "", errors.Errorf("not found: %s", url)
+	}
+	return []byte(body), webFetchSourceCrawler, nil
+}
+
+func (s *crawlTestSite) fetchPlain(_ context.Context, url string) ([]byte, string, error) {
+	body, ok := s.plain[url]
+	if !ok {
+		return nil, "", errors.Errorf("not found: %s", url)
+	}
+	return []byte(body), "text/plain", nil
+}
+
+func crawlTestPage(title, body string) string {
+	return "<html><head><title>" + title + "</title></head><body><article><p>" + body + "</p></article></body></html>"
+}
+
+func newCrawlTestTool(t *testing.T, site *crawlTestSite, billing BillingChecker) (*WebCrawlTool, *memoryCrawlJobStore, *memoryCrawlFiles) {
+	t.Helper()
+	store := newMemoryCrawlJobStore()
+	written := &memoryCrawlFiles{}
+	if billing == nil {
+		billing = func(context.Context, string, oneapi.Price, string) error { return nil }
+	}
+	tool, err := NewWebCrawlTool(store, log.Logger.Named("web_crawl_test"),
+		func(context.Context) string { return "sk-test" }, billing,
+		site.fetchPage, site.fetchPlain, written)
+	require.NoError(t, err)
+	return tool, store, written
+}
+
+func waitCrawlJobFinished(t *testing.T, store *memoryCrawlJobStore) {
+	t.Helper()
+	select {
+	case <-store.finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("crawl job did not finish")
+	}
+}
+
+func callCrawlTool(t *testing.T, handle func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error), args map[string]any) map[string]any {
+	t.Helper()
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = args
+	result, err := handle(asyncTestContext("hash"), req)
+	require.NoError(t, err)
+	require.False(t, result.IsError, "unexpected tool error: %v", result.Content)
+	payload := map[string]any{}
+	require.NoError(t, json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &payload))
+	return payload
+}
+
+// TestWebCrawlFollowsLinksWithinLimits verifies depth, domain, robots.txt and asset filtering.
+func TestWebCrawlFollowsLinksWithinLimits(t *testing.T) {
+	site := &crawlTestSite{
+		pages: map[string]string{
+			"https://docs.example.test/": `<html><head><title>Home</title></head><body><article><p>Welcome.</p>
+				<a href="/guide.html">Guide</a> <a href="/private/keys">Keys</a> <a href="/logo.png">Logo</a>
+				<a href="https://other.example.org/">Elsewhere</a> <a href="https://api.docs.example.test/ref">Ref</a></article></body></html>`,
+			"https://docs.example.test/guide.html": `<html><head><title>Guide</title></head><body><article><p>Read this.</p>
+				<a href="/guide/deep">Deep</a></article></body></html>`,
+			"https://api.docs.example.test/ref": crawlTestPage("Ref", "Reference."),
+		},
+		plain: map[string]string{
+			"https://docs.example.test/robots.txt": "User-agent: *\nDisallow: /private/\n",
+		},
+	}
+	tool, store, written := newCrawlTestTool(t, site, nil)
+
+	started := callCrawlTool(t, tool.Handle, map[string]any{
+		"url":       "https://docs.example.test/",
+		"project":   "docs",
+		"max_depth": 1,
+	})
+	waitCrawlJobFinished(t, store)
+
+	status, err := NewWebCrawlStatusTool(tool)
+	require.NoError(t, err)
+	payload := callCrawlTool(t, status.Handle, map[string]any{"job_id": started["job_id"]})
+	require.Equal(t, crawljobs.StatusSucceeded, payload["status"])
+	progress := payload["progress"].(map[string]any)
+	require.EqualValues(t, 3, progress["pages_fetched"])
+	require.EqualValues(t, 3, progress["pages_written"])
+	require.EqualValues(t, 3*oneapi.PriceWebFetch.Int(), progress["billed_credits"])
+	require.EqualValues(t, map[string]any{"robots": float64(1)}, progress["skipped"])
+
+	require.ElementsMatch(t, []string{
+		"https://docs.example.test/",
+		"https://docs.example.test/guide.html",
+		"https://api.docs.example.test/ref",
+	}, site.fetched)
+	require.Contains(t, written.files, "docs:/crawl/docs.example.test/index.md")
+	require.Contains(t, written.files, "docs:/crawl/api.docs.example.test/ref.md")
+	guide := written.files["docs:/crawl/docs.example.test/guide.md"]
+	require.Contains(t, guide, `source_url: "https://docs.example.test/guide.html"`)
+	require.Contains(t, guide, "# Guide")
+	require.Contains(t, guide, "Read this.")
+}
+
+// TestWebCrawlHeartbeatsDuringSlowFetches verifies a job refreshes its liveness while
+// a single page fetch outlasts the heartbeat interval, before any checkpoint.
+func TestWebCrawlHeartbeatsDuringSlowFetches(t *testing.T) {
+	site := &crawlTestSite{
+		pages: map[string]string{"https://docs.example.test/": crawlTestPage("Home", "Welcome.")},
+		delay: 100 * time.Millisecond,
+	}
+	tool, store, _ := newCrawlTestTool(t, site, nil)
+	tool.heartbeatInterval = 10 * time.Millisecond
+
+	callCrawlTool(t, tool.Handle, map[string]any{"url": "https://docs.example.test/", "project": "docs"})
+	waitCrawlJobFinished(t, store)
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	require.GreaterOrEqual(t, store.heartbeats, 3)
+}
+
+// TestWebCrawlDoesNotBillFailedFetches verifies that pages that cannot be fetched cost nothing.
+func TestWebCrawlDoesNotBillFailedFetches(t *testing.T) {
+	site := &crawlTestSite{pages: map[string]string{
+		"https://docs.example.test/": `<html><body><article><p>Home.</p><a href="/missing">Missing</a></article></body></html>`,
+	}}
+	var (
+		mu     sync.Mutex
+		billed int
+	)
+	billing := func(context.Context, string, oneapi.Price, string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		billed++
+		return nil
+	}
+	tool, store, _ := newCrawlTestTool(t, site, billing)
+
+	started := callCrawlTool(t, tool.Handle, map[string]any{"url": "https://docs.example.test/", "project": "docs"})
+	waitCrawlJobFinished(t, store)
+
+	status, err := NewWebCrawlStatusTool(tool)
+	require.NoError(t, err)
+	progress := callCrawlTool(t, status.Handle, map[string]any{"job_id": started["job_id"]})["progress"].(map[string]any)
+	require.EqualValues(t, 1, progress["pages_fetched"])
+	require.EqualValues(t, 1, progress["pages_failed"])
+	require.EqualValues(t, oneapi.PriceWebFetch.Int(), progress["billed_credits"])
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, 1, billed)
+}
+
+// TestWebCrawlResumesAfterBillingFailure verifies that a failed crawl keeps its checkpoint and continues on resume.
+func TestWebCrawlResumesAfterBillingFailure(t *testing.T) {
+	site := &crawlTestSite{pages: map[string]string{
+		"https://docs.example.test/":  `<html><body><article><p>Home.</p><a href="/a">A</a><a href="/b">B</a><a href="/c">C</a></article></body></html>`,
+		"https://docs.example.test/a": crawlTestPage("A", "Page A."),
+		"https://docs.example.test/b": crawlTestPage("B", "Page B."),
+		"https://docs.example.test/c": crawlTestPage("C", "Page C."),
+	}}
+	var mu sync.Mutex
+	budget := 2
+	billing := func(context.Context, string, oneapi.Price, string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if budget == 0 {
+			return errors.New("insufficient quota")
+		}
+		budget--
+		return nil
+	}
+	tool, store, written := newCrawlTestTool(t, site, billing)
+
+	started := callCrawlTool(t, tool.Handle, map[string]any{
+		"url":       "https://docs.example.test/",
+		"project":   "docs",
+		"max_pages": 3,
+	})
+	waitCrawlJobFinished(t, store)
+
+	job, err := store.Get(context.Background(), "hash", started["job_id"].(string))
+	require.NoError(t, err)
+	require.Equal(t, crawljobs.StatusFailed, job.Status)
+	require.Contains(t, job.ErrorMessage, "insufficient quota")
+	require.Len(t, written.files, 2)
+
+	mu.Lock()
+	budget = 10
+	mu.Unlock()
+	resumed := callCrawlTool(t, tool.Handle, map[string]any{"resume_job_id": started["job_id"]})
+	require.Equal(t, true, resumed["resumed"])
+	waitCrawlJobFinished(t, store)
+
+	job, err = store.Get(context.Background(), "hash", started["job_id"].(string))
+	require.NoError(t, err)
+	require.Equal(t, crawljobs.StatusSucceeded, job.Status)
+	require.Len(t, written.files, 3)
+	// Pages are billed after they are fetched, so the page whose billing failed is fetched again on resume.
+	require.Equal(t, []string{
+		"https://docs.example.test/",
+		"https://docs.example.test/a",
+		"https://docs.example.test/b",
+		"https://docs.example.test/b",
+	}, site.fetched)
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"resume_job_id": started["job_id"]}
+	result, err := tool.Handle(asyncTestContext("hash"), req)
+	require.NoError(t, err)
+	require.True(t, result.IsError)
+}
+
+// TestWebCrawlSitemapSeeds verifies that sitemap entries seed the crawl and nested sitemaps are followed.
+func TestWebCrawlSitemapSeeds(t *testing.T) {
+	site := &crawlTestSite{
+		pages: map[string]string{
+			"https://docs.example.test/one": crawlTestPage("One", "First."),
+			"https://docs.example.test/two": crawlTestPage("Two", "Second."),
+		},
+		plain: map[string]string{
+			"https://docs.example.test/sitemap.xml": `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+				<sitemap><loc>https://docs.example.test/pages.xml</loc></sitemap></sitemapindex>`,
+			"https://docs.example.test/pages.xml": `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+				<url><loc>https://docs.example.test/one</loc></url>
+				<url><loc>https://docs.example.test/two</loc></url>
+				<url><loc>https://other.example.org/three</loc></url></urlset>`,
+		},
+	}
+	tool, store, written := newCrawlTestTool(t, site, nil)
+
+	callCrawlTool(t, tool.Handle, map[string]any{
+		"sitemap_url": "https://docs.example.test/sitemap.xml",
+		"project":     "docs",
+		"target_dir":  "/kb/",
+		"max_depth":   0,
+	})
+	waitCrawlJobFinished(t, store)
+
+	require.ElementsMatch(t, []string{"https://docs.example.test/one", "https://docs.example.test/two"}, site.fetched)
+	require.Contains(t, written.files, "docs:/kb/docs.example.test/one.md")
+	require.Contains(t, written.files, "docs:/kb/docs.example.test/two.md")
+}
+
+// TestWebCrawlRejectsInvalidArguments covers argument validation.
+func TestWebCrawlRejectsInvalidArguments(t *testing.T) {
+	tool, _, _ := newCrawlTestTool(t, &crawlTestSite{}, nil)
+	cases := []map[string]any{
+		{"project": "docs"},
+		{"url": "ftp://docs.example.test/", "project": "docs"},
+		{"url": "https://docs.example.test/", "project": "../docs"},
+		{"url": "https://docs.example.test/", "project": "docs", "max_depth": MaxWebCrawlDepth + 1},
+		{"url": "https://docs.example.test/", "project": "docs", "max_pages": 0},
+		{"url": "https://docs.example.test/", "project": "docs", "allowed_domains": []any{"https://docs.example.test"}},
+	}
+	for _, args := range cases {
+		req := mcp.CallToolRequest{}
+		req.Params.Arguments = args
+		result, err := tool.Handle(asyncTestContext("hash"), req)
+		require.NoError(t, err)
+		require.True(t, result.IsError, "expected error for %v", args)
+	}
+}
+
+func TestWebCrawlFilePath(t *testing.T) {
+	cases := map[string]string{
+		"https://docs.example.test":               "/crawl/docs.example.test/index.md",
+		"https://docs.example.test/guide/":        "/crawl/docs.example.test/guide/index.md",
+		"https://docs.example.test/a/b.html":      "/crawl/docs.example.test/a/b.md",
+		"https://docs.example.test:8443/x y/z":    "/crawl/docs.example.test_8443/x-y/z.md",
+		"https://docs.example.test/../etc/passwd": "/crawl/docs.example.test/_/etc/passwd.md",
+	}
+	for pageURL, want := range cases {
+		require.Equal(t, want, webCrawlFilePath("/crawl", pageURL), pageURL)
+	}
+
+	withQuery := webCrawlFilePath("/crawl", "https://docs.example.test/search?q=go")
+	require.Regexp(t, `^/crawl/docs\.example\.test/search-[0-9a-f]{10}\.md$`, withQuery)
+
+	long := webCrawlFilePath("/crawl", "https://docs.example.test/"+strings.Repeat("a", 600))
+	require.LessOrEqual(t, len(long), maxWebCrawlPathLen)
+	require.NoError(t, files.ValidatePath(long))
+}
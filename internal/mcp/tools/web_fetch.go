@@ -2,6 +2,7 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/url"
@@ -15,11 +16,32 @@ import (
 
 	"github.com/Laisky/laisky-blog-graphql/library/billing/oneapi"
 	rlibs "github.com/Laisky/laisky-blog-graphql/library/db/redis"
+	searchlib "github.com/Laisky/laisky-blog-graphql/library/search"
+)
+
+const (
+	// webFetchExtractionTTL is how long structured extractions stay cached.
+	webFetchExtractionTTL = time.Hour
+	// DefaultWebFetchCrawlerDeadline is how long web_fetch waits for the crawler before using the fallback.
+	DefaultWebFetchCrawlerDeadline = 20 * time.Second
+
+	// webFetchSourceCrawler and webFetchSourceFallback report which path produced a page.
+	webFetchSourceCrawler  = "crawler"
+	webFetchSourceFallback = "fallback"
 )
 
 // DynamicFetcher retrieves rendered HTML content for a given URL.
 type DynamicFetcher func(ctx context.Context, store *rlibs.DB, url string, apiKey string, outputMarkdown bool) ([]byte, error)
 
+// FallbackFetcher renders a page in-process when the crawler does not answer.
+type FallbackFetcher func(ctx context.Context, url string, outputMarkdown bool) ([]byte, error)
+
+// ExtractionCache stores structured web_fetch extractions by cache key.
+type ExtractionCache interface {
+	GetWebFetchExtraction(ctx context.Context, cacheKey string) (val string, found bool, err error)
+	SetWebFetchExtraction(ctx context.Context, cacheKey, val string, ttl time.Duration) error
+}
+
 // WebFetchTool implements the web_fetch MCP tool.
 type WebFetchTool struct {
 	store          *rlibs.DB
@@ -27,6 +49,19 @@ type WebFetchTool struct {
 	apiKeyProvider APIKeyProvider
 	billingChecker BillingChecker
 	fetcher        DynamicFetcher
+	cache          ExtractionCache
+	// fallback, when set, serves pages the crawler does not return within crawlerDeadline.
+	fallback        FallbackFetcher
+	crawlerDeadline time.Duration
+}
+
+// webFetchExtractResponse is the web_fetch payload when extract is set.
+type webFetchExtractResponse struct {
+	*searchlib.ExtractResult
+	// Cached is true when the extraction was served from the cache.
+	Cached bool `json:"cached"`
+	// Source is the path that fetched the page, empty when Cached is true.
+	Source string `json:"source,omitempty"`
 }
 
 // NewWebFetchTool constructs a WebFetchTool with the provided dependencies.
@@ -53,14 +88,29 @@ func NewWebFetchTool(store *rlibs.DB, logger logSDK.Logger, apiKeyProvider APIKe
 		apiKeyProvider: apiKeyProvider,
 		billingChecker: billingChecker,
 		fetcher:        fetcher,
+		cache:          store,
 	}, nil
 }
 
+// WithFallback attaches an in-process fetcher used when the crawler does not answer
+// within crawlerDeadline. A non-positive deadline selects DefaultWebFetchCrawlerDeadline.
+func (t *WebFetchTool) WithFallback(fallback FallbackFetcher, crawlerDeadline time.Duration) *WebFetchTool {
+	if t != nil {
+		if crawlerDeadline <= 0 {
+			crawlerDeadline = DefaultWebFetchCrawlerDeadline
+		}
+		t.fallback = fallback
+		t.crawlerDeadline = crawlerDeadline
+	}
+	return t
+}
+
 // Definition returns the MCP metadata describing the tool.
 func (t *WebFetchTool) Definition() mcp.Tool {
 	return mcp.NewTool(
 		"web_fetch",
-		mcp.WithDescription("Fetch and render dynamic web content by URL. Downloads a web page and returns its content as HTML or Markdown. Use this to retrieve, read, or scrape a specific webpage."),
+		mcp.WithDescription("Fetch and render dynamic web content by URL. Downloads a web page and returns its content as HTML or Markdown. Use this to retrieve, read, or scrape a specific webpage. "+
+			"The response source is \"crawler\" for a headless browser render or \"fallback\" for a plain HTTP fetch without scripts."),
 		mcp.WithString(
 			"url",
 			mcp.Required(),
@@ -71,6 +121,14 @@ func (t *WebFetchTool) Definition() mcp.Tool {
 			mcp.Description("Whether to return Markdown instead of raw HTML."),
 			mcp.DefaultBool(true),
 		),
+		mcp.WithAny(
+			"extract",
+			mcp.Description("Return structured data instead of the page body. Either a list of CSS or XPath selectors, "+
+				"returning the matches of each selector, or a JSON schema whose properties carry an \"x-selector\" "+
+				"(CSS or XPath, relative to the enclosing object or array item). CSS selectors may end with ::attr(name) "+
+				"or ::text; XPath may end with /@name or /text(). The result also includes page metadata: title, "+
+				"canonical URL, OpenGraph tags, publish date and outbound links."),
+		),
 		mcp.WithReadOnlyHintAnnotation(true),
 		mcp.WithIdempotentHintAnnotation(true),
 		mcp.WithOpenWorldHintAnnotation(true),
@@ -89,6 +147,13 @@ func (t *WebFetchTool) Handle(ctx context.Context, req mcp.CallToolRequest) (*mc
 		return mcp.NewToolResultError("url cannot be empty"), nil
 	}
 
+	var extractSpec *searchlib.ExtractSpec
+	if raw, ok := req.GetArguments()["extract"]; ok && raw != nil {
+		if extractSpec, err = searchlib.ParseExtractSpec(raw); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("invalid extract: %v", err)), nil
+		}
+	}
+
 	if err := validateFetchURL(urlValue); err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("invalid url: %v", err)), nil
 	}
@@ -122,12 +187,17 @@ func (t *WebFetchTool) Handle(ctx context.Context, req mcp.CallToolRequest) (*mc
 		zap.Bool("output_markdown", outputMarkdown),
 	)
 
-	content, err := t.fetcher(ctx, t.store, urlValue, apiKey, outputMarkdown)
+	if extractSpec != nil {
+		return t.handleExtract(ctx, urlValue, apiKey, extractSpec)
+	}
+
+	content, source, err := t.fetch(ctx, urlValue, apiKey, outputMarkdown)
 	if err != nil {
 		t.logger.Error("web_fetch failed",
 			zap.Error(err),
 			zap.String("url", logURL),
 			zap.Bool("output_markdown", outputMarkdown),
+			zap.String("source", source),
 			zap.Duration("duration", time.Since(start)),
 		)
 		return mcp.NewToolResultError(fmt.Sprintf("fetch failed: %v", err)), nil
@@ -136,12 +206,14 @@ func (t *WebFetchTool) Handle(ctx context.Context, req mcp.CallToolRequest) (*mc
 	t.logger.Debug("web_fetch completed",
 		zap.String("url", logURL),
 		zap.Bool("output_markdown", outputMarkdown),
+		zap.String("source", source),
 		zap.Duration("duration", time.Since(start)),
 		zap.Int("content_len", len(content)),
 	)
 
 	payload := map[string]any{
 		"content": string(content),
+		"source":  source,
 	}
 
 	toolResult, err := mcp.NewToolResultJSON(payload)
@@ -153,6 +225,116 @@ func (t *WebFetchTool) Handle(ctx context.Context, req mcp.CallToolRequest) (*mc
 	return toolResult, nil
 }
 
+// handleExtract fetches urlValue as HTML and returns only the data selected by spec.
+// Extractions are cached by URL and spec, so repeated calls skip the crawler.
+func (t *WebFetchTool) handleExtract(ctx context.Context, urlValue, apiKey string, spec *searchlib.ExtractSpec) (*mcp.CallToolResult, error) {
+	start := time.Now().UTC()
+	logURL := sanitizeURLForLog(urlValue)
+	cacheKey, err := spec.CacheKey(urlValue)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid extract: %v", err)), nil
+	}
+
+	if cached, found, err := t.cache.GetWebFetchExtraction(ctx, cacheKey); err != nil {
+		t.logger.Warn("web_fetch extraction cache lookup failed", zap.Error(err), zap.String("url", logURL))
+	} else if found {
+		response := webFetchExtractResponse{ExtractResult: new(searchlib.ExtractResult), Cached: true}
+		if err := json.Unmarshal([]byte(cached), response.ExtractResult); err == nil {
+			t.logger.Debug("web_fetch extraction served from cache", zap.String("url", logURL))
+			return t.encodeExtractResponse(response)
+		}
+		t.logger.Warn("web_fetch discarded malformed cached extraction", zap.String("url", logURL))
+	}
+
+	content, source, err := t.fetch(ctx, urlValue, apiKey, false)
+	if err != nil {
+		t.logger.Error("web_fetch failed",
+			zap.Error(err),
+			zap.String("url", logURL),
+			zap.Bool("extract", true),
+			zap.String("source", source),
+			zap.Duration("duration", time.Since(start)),
+		)
+		return mcp.NewToolResultError(fmt.Sprintf("fetch failed: %v", err)), nil
+	}
+
+	result, err := searchlib.ExtractStructuredData(urlValue, content, spec)
+	if err != nil {
+		t.logger.Warn("web_fetch extraction failed", zap.Error(err), zap.String("url", logURL))
+		return mcp.NewToolResultError(fmt.Sprintf("extract failed: %v", err)), nil
+	}
+
+	if encoded, err := json.Marshal(result); err != nil {
+		t.logger.Warn("encode extraction for cache", zap.Error(err))
+	} else if err := t.cache.SetWebFetchExtraction(ctx, cacheKey, string(encoded), webFetchExtractionTTL); err != nil {
+		t.logger.Warn("web_fetch extraction cache store failed", zap.Error(err), zap.String("url", logURL))
+	}
+
+	t.logger.Debug("web_fetch extraction completed",
+		zap.String("url", logURL),
+		zap.Duration("duration", time.Since(start)),
+		zap.Int("content_len", len(content)),
+	)
+	return t.encodeExtractResponse(webFetchExtractResponse{ExtractResult: result, Source: source})
+}
+
+// FetchPage retrieves the rendered HTML of urlValue for other tools, such as web_crawl.
+// It applies the same address checks and crawler fallback as web_fetch, but does not bill.
+// It returns the page with the path that produced it.
+func (t *WebFetchTool) FetchPage(ctx context.Context, urlValue, apiKey string) ([]byte, string, error) {
+	if err := validateFetchURL(urlValue); err != nil {
+		return nil, "", errors.Wrap(err, "invalid url")
+	}
+	content, source, err := t.fetch(ctx, urlValue, apiKey, false)
+	if err != nil {
+		return nil, source, errors.WithStack(err)
+	}
+	return content, source, nil
+}
+
+// fetch retrieves urlValue through the crawler and returns the content with the path that produced it.
+//
+// With a fallback attached, the crawler gets crawlerDeadline to answer. When it cannot be
+// reached or does not answer in time, the page is fetched in-process instead. A page the
+// crawler rejected is not retried, since the fallback renders strictly less than the crawler.
+func (t *WebFetchTool) fetch(ctx context.Context, urlValue, apiKey string, outputMarkdown bool) ([]byte, string, error) {
+	if t.fallback == nil {
+		content, err := t.fetcher(ctx, t.store, urlValue, apiKey, outputMarkdown)
+		return content, webFetchSourceCrawler, err
+	}
+
+	crawlerCtx, cancel := context.WithTimeout(ctx, t.crawlerDeadline)
+	content, err := t.fetcher(crawlerCtx, t.store, urlValue, apiKey, outputMarkdown)
+	cancel()
+	if err == nil {
+		return content, webFetchSourceCrawler, nil
+	}
+	if errors.Is(err, searchlib.ErrCrawlerTaskFailed) || ctx.Err() != nil {
+		return nil, webFetchSourceCrawler, err
+	}
+
+	t.logger.Warn("web_fetch crawler unavailable, using fallback",
+		zap.Error(err),
+		zap.String("url", sanitizeURLForLog(urlValue)),
+		zap.Duration("crawler_deadline", t.crawlerDeadline),
+	)
+	content, fallbackErr := t.fallback(ctx, urlValue, outputMarkdown)
+	if fallbackErr != nil {
+		return nil, webFetchSourceFallback, errors.Wrapf(fallbackErr, "crawler: %v; fallback", err)
+	}
+	return content, webFetchSourceFallback, nil
+}
+
+// encodeExtractResponse renders an extraction as the tool result.
+func (t *WebFetchTool) encodeExtractResponse(response webFetchExtractResponse) (*mcp.CallToolResult, error) {
+	toolResult, err := mcp.NewToolResultJSON(response)
+	if err != nil {
+		t.logger.Error("encode web_fetch extraction", zap.Error(err))
+		return mcp.NewToolResultError("failed to encode web_fetch response"), nil
+	}
+	return toolResult, nil
+}
+
 // resolveOutputMarkdownArg returns whether web_fetch should return markdown.
 // It defaults to true and only returns false when the caller explicitly provides
 // a false-like value for output_markdown.
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/Laisky/errors/v2"
 	mcp "github.com/mark3labs/mcp-go/mcp"
@@ -12,6 +13,7 @@ import (
 	"github.com/Laisky/laisky-blog-graphql/library/billing/oneapi"
 	rlibs "github.com/Laisky/laisky-blog-graphql/library/db/redis"
 	"github.com/Laisky/laisky-blog-graphql/library/log"
+	searchlib "github.com/Laisky/laisky-blog-graphql/library/search"
 )
 
 func TestWebFetchHandleMissingAPIKey(t *testing.T) {
@@ -329,6 +331,181 @@ func TestWebFetchHandleBlocksSSRF(t *testing.T) {
 	}
 }
 
+func TestWebFetchHandleExtractCachesBySpec(t *testing.T) {
+	var fetchCalls int
+	tool := mustWebFetchTool(t,
+		func(context.Context) string { return "token" },
+		func(context.Context, string, oneapi.Price, string) error { return nil },
+		func(_ context.Context, _ *rlibs.DB, _ string, _ string, outputMarkdown bool) ([]byte, error) {
+			fetchCalls++
+			require.False(t, outputMarkdown)
+			return []byte(`<html><head><title>Docs</title></head><body><h1>Hello</h1><a href="https://example.org/">x</a></body></html>`), nil
+		},
+	)
+	cache := newMemoryExtractionCache()
+	tool.cache = cache
+
+	call := func(extract any) map[string]any {
+		req := mcp.CallToolRequest{
+			Params: mcp.CallToolParams{
+				// A literal public address keeps URL validation off the network.
+				Arguments: map[string]any{"url": "https://203.0.113.10/docs", "extract": extract},
+			},
+		}
+		result, err := tool.Handle(context.Background(), req)
+		require.NoError(t, err)
+		require.False(t, result.IsError)
+
+		payload := make(map[string]any)
+		require.NoError(t, json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &payload))
+		return payload
+	}
+
+	first := call([]any{"h1"})
+	require.Equal(t, false, first["cached"])
+	require.NotContains(t, first, "content")
+	require.Equal(t, map[string]any{"h1": []any{"Hello"}}, first["data"])
+	metadata := first["metadata"].(map[string]any)
+	require.Equal(t, "Docs", metadata["title"])
+	require.Equal(t, "https://203.0.113.10/docs", metadata["canonical_url"])
+	require.Len(t, metadata["links"], 1)
+
+	second := call([]any{"h1"})
+	require.Equal(t, true, second["cached"])
+	require.Equal(t, first["data"], second["data"])
+	require.Equal(t, 1, fetchCalls)
+
+	// A different spec is a different cache entry.
+	call([]any{"title"})
+	require.Equal(t, 2, fetchCalls)
+	require.Len(t, cache.items, 2)
+}
+
+func TestWebFetchHandleExtractInvalidSpec(t *testing.T) {
+	tool := mustWebFetchTool(t,
+		func(context.Context) string { return "token" },
+		func(context.Context, string, oneapi.Price, string) error {
+			t.Fatal("billing must not run for an invalid spec")
+			return nil
+		},
+		func(context.Context, *rlibs.DB, string, string, bool) ([]byte, error) { return nil, nil },
+	)
+
+	req := mcp.CallToolRequest{
+		Params: mcp.CallToolParams{
+			Arguments: map[string]any{"url": "https://example.com", "extract": []any{"div[["}},
+		},
+	}
+	result, err := tool.Handle(context.Background(), req)
+	require.NoError(t, err)
+	require.True(t, result.IsError)
+	require.Contains(t, result.Content[0].(mcp.TextContent).Text, "invalid extract")
+}
+
+func TestWebFetchHandleFallsBackWhenCrawlerSilent(t *testing.T) {
+	var fallbackCalls int
+	tool := mustWebFetchTool(t,
+		func(context.Context) string { return "token" },
+		func(context.Context, string, oneapi.Price, string) error { return nil },
+		func(ctx context.Context, _ *rlibs.DB, _ string, _ string, _ bool) ([]byte, error) {
+			<-ctx.Done()
+			return nil, errors.Wrap(ctx.Err(), "get task result")
+		},
+	).WithFallback(func(_ context.Context, url string, outputMarkdown bool) ([]byte, error) {
+		fallbackCalls++
+		require.Equal(t, "https://203.0.113.10/post", url)
+		require.True(t, outputMarkdown)
+		return []byte("# Post"), nil
+	}, 10*time.Millisecond)
+
+	req := mcp.CallToolRequest{
+		Params: mcp.CallToolParams{
+			Arguments: map[string]any{"url": "https://203.0.113.10/post"},
+		},
+	}
+	result, err := tool.Handle(context.Background(), req)
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+	require.Equal(t, 1, fallbackCalls)
+
+	payload := make(map[string]any)
+	require.NoError(t, json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &payload))
+	require.Equal(t, "# Post", payload["content"])
+	require.Equal(t, "fallback", payload["source"])
+}
+
+func TestWebFetchHandleFallbackSelection(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		crawlerErr     error
+		wantSource     string
+		wantFallback   int
+		wantErrContain string
+	}{
+		{name: "crawler success", wantSource: "crawler"},
+		{name: "task failed", crawlerErr: errors.Wrap(searchlib.ErrCrawlerTaskFailed, "404"), wantErrContain: "crawler task failed"},
+		{name: "crawler unreachable", crawlerErr: errors.New("connection refused"), wantSource: "fallback", wantFallback: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var fallbackCalls int
+			tool := mustWebFetchTool(t,
+				func(context.Context) string { return "token" },
+				func(context.Context, string, oneapi.Price, string) error { return nil },
+				func(context.Context, *rlibs.DB, string, string, bool) ([]byte, error) {
+					if tc.crawlerErr != nil {
+						return nil, tc.crawlerErr
+					}
+					return []byte(`<html><body><h1>Hi</h1></body></html>`), nil
+				},
+			).WithFallback(func(context.Context, string, bool) ([]byte, error) {
+				fallbackCalls++
+				return []byte(`<html><body><h1>Hi</h1></body></html>`), nil
+			}, 0)
+			tool.cache = newMemoryExtractionCache()
+
+			req := mcp.CallToolRequest{
+				Params: mcp.CallToolParams{
+					Arguments: map[string]any{"url": "https://203.0.113.10/post", "extract": []any{"h1"}},
+				},
+			}
+			result, err := tool.Handle(context.Background(), req)
+			require.NoError(t, err)
+			require.Equal(t, tc.wantFallback, fallbackCalls)
+			text := result.Content[0].(mcp.TextContent).Text
+			if tc.wantErrContain != "" {
+				require.True(t, result.IsError)
+				require.Contains(t, text, tc.wantErrContain)
+				return
+			}
+
+			require.False(t, result.IsError, text)
+			payload := make(map[string]any)
+			require.NoError(t, json.Unmarshal([]byte(text), &payload))
+			require.Equal(t, tc.wantSource, payload["source"])
+			require.Equal(t, map[string]any{"h1": []any{"Hi"}}, payload["data"])
+		})
+	}
+}
+
+// memoryExtractionCache is an in-memory ExtractionCache.
+type memoryExtractionCache struct {
+	items map[string]string
+}
+
+func newMemoryExtractionCache() *memoryExtractionCache {
+	return &memoryExtractionCache{items: make(map[string]string)}
+}
+
+func (c *memoryExtractionCache) GetWebFetchExtraction(_ context.Context, cacheKey string) (string, bool, error) {
+	val, ok := c.items[cacheKey]
+	return val, ok, nil
+}
+
+func (c *memoryExtractionCache) SetWebFetchExtraction(_ context.Context, cacheKey, val string, _ time.Duration) error {
+	c.items[cacheKey] = val
+	return nil
+}
+
 func mustWebFetchTool(t *testing.T, keyProvider APIKeyProvider, billing BillingChecker, fetcher DynamicFetcher) *WebFetchTool {
 	t.Helper()
 
@@ -3,6 +3,7 @@ package tools
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -66,6 +67,17 @@ func (t *WebSearchTool) Definition() mcp.Tool {
 			mcp.Required(),
 			mcp.Description("Plain text search query."),
 		),
+		mcp.WithBoolean(
+			"fanout",
+			mcp.Description("Query several search engines in parallel and fuse their rankings. Each result lists the engines that returned it. Billed once per engine that returned results."),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithNumber(
+			"max_engines",
+			mcp.Description(fmt.Sprintf("Maximum number of engines queried when fanout is true (1-%d, default %d).", searchlib.MaxFanOutEngines, searchlib.DefaultFanOutEngines)),
+			mcp.Min(1),
+			mcp.Max(searchlib.MaxFanOutEngines),
+		),
 		mcp.WithReadOnlyHintAnnotation(true),
 		mcp.WithIdempotentHintAnnotation(true),
 		mcp.WithOpenWorldHintAnnotation(true),
@@ -84,8 +96,19 @@ func (t *WebSearchTool) Handle(ctx context.Context, req mcp.CallToolRequest) (*m
 		return mcp.NewToolResultError("query cannot be empty"), nil
 	}
 
+	fanOut := req.GetBool("fanout", false)
+	var fanOutProvider searchlib.FanOutProvider
+	if fanOut {
+		provider, ok := t.searchProvider.(searchlib.FanOutProvider)
+		if !ok {
+			return mcp.NewToolResultError("fanout search is not supported by the configured search provider"), nil
+		}
+		fanOutProvider = provider
+	}
+	maxEngines := searchlib.ClampFanOutEngines(req.GetInt("max_engines", 0))
+
 	start := time.Now().UTC()
-	t.logger.Debug("web_search started", zap.Int("query_len", len(query)))
+	t.logger.Debug("web_search started", zap.Int("query_len", len(query)), zap.Bool("fanout", fanOut))
 
 	apiKey := t.apiKeyProvider(ctx)
 	if apiKey == "" {
@@ -102,12 +125,25 @@ func (t *WebSearchTool) Handle(ctx context.Context, req mcp.CallToolRequest) (*m
 
 	t.logger.Debug("web_search billing check passed", zap.Int("query_len", len(query)))
 
-	output, err := t.searchProvider.Search(ctx, query)
+	var output *searchlib.SearchOutput
+	if fanOut {
+		output, err = fanOutProvider.SearchFanOut(ctx, query, maxEngines)
+	} else {
+		output, err = t.searchProvider.Search(ctx, query)
+	}
 	if err != nil {
 		t.logger.Error("web_search failed", zap.Error(err), zap.Int("query_len", len(query)))
 		return mcp.NewToolResultError(fmt.Sprintf("search failed: %v", err)), nil
 	}
 
+	// The upfront check covers the first engine; every further contributing engine is billed after the search.
+	if extraEngines := len(output.Engines) - 1; extraEngines > 0 {
+		if err := t.billingChecker(ctx, apiKey, oneapi.PriceWebSearch*oneapi.Price(extraEngines), "web_search"); err != nil {
+			t.logger.Warn("web_search fanout billing denied", zap.Error(err), zap.Strings("engines", output.Engines))
+			return mcp.NewToolResultError(fmt.Sprintf("billing check failed: %v", err)), nil
+		}
+	}
+
 	t.logger.Debug("web_search completed",
 		zap.Int("query_len", len(query)),
 		zap.Int("results_count", len(output.Items)),
@@ -118,6 +154,7 @@ func (t *WebSearchTool) Handle(ctx context.Context, req mcp.CallToolRequest) (*m
 
 	response := searchlib.SimplifiedSearchResult{
 		Results: output.Items,
+		Engines: output.Engines,
 	}
 
 	toolResult, err := mcp.NewToolResultJSON(response)
@@ -128,3 +165,32 @@ func (t *WebSearchTool) Handle(ctx context.Context, req mcp.CallToolRequest) (*m
 
 	return toolResult, nil
 }
+
+// WebSearchFanOutPrice returns what a web_search call with args may be billed beyond
+// its base price: one search per extra engine of a fan-out. Arguments only known at
+// run time, such as references, are priced at their maximum.
+func WebSearchFanOutPrice(args map[string]any) oneapi.Price {
+	switch fanOut := args["fanout"].(type) {
+	case bool:
+		if !fanOut {
+			return 0
+		}
+	case string:
+		if enabled, err := strconv.ParseBool(fanOut); err == nil && !enabled {
+			return 0
+		}
+	default:
+		return 0
+	}
+
+	engines := searchlib.MaxFanOutEngines
+	switch value := args["max_engines"].(type) {
+	case nil:
+		engines = searchlib.DefaultFanOutEngines
+	case float64:
+		engines = searchlib.ClampFanOutEngines(int(value))
+	case int:
+		engines = searchlib.ClampFanOutEngines(value)
+	}
+	return oneapi.PriceWebSearch * oneapi.Price(engines-1)
+}
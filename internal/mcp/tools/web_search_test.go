@@ -145,6 +145,80 @@ func TestWebSearchHandleSuccess(t *testing.T) {
 	require.Equal(t, "Snippet", payload.Results[0].Snippet)
 }
 
+func TestWebSearchHandleFanOutBillsContributingEngines(t *testing.T) {
+	provider := &stubFanOutSearchProvider{engines: []string{"google", "bing", "firecrawl"}}
+	var billed []oneapi.Price
+
+	tool := mustWebSearchTool(t,
+		func(context.Context) string { return "token" },
+		func(_ context.Context, _ string, price oneapi.Price, reason string) error {
+			require.Equal(t, "web_search", reason)
+			billed = append(billed, price)
+			return nil
+		},
+		provider,
+	)
+
+	req := mcp.CallToolRequest{
+		Params: mcp.CallToolParams{
+			Arguments: map[string]any{
+				"query":       "golang",
+				"fanout":      true,
+				"max_engines": 9,
+			},
+		},
+	}
+
+	result, err := tool.Handle(context.Background(), req)
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+	require.Equal(t, searchlib.MaxFanOutEngines, provider.maxEngines)
+	require.Equal(t, []oneapi.Price{oneapi.PriceWebSearch, 2 * oneapi.PriceWebSearch}, billed)
+
+	textContent, ok := result.Content[0].(mcp.TextContent)
+	require.True(t, ok)
+	var payload searchlib.SimplifiedSearchResult
+	require.NoError(t, json.Unmarshal([]byte(textContent.Text), &payload))
+	require.Equal(t, []string{"google", "bing", "firecrawl"}, payload.Engines)
+	require.Equal(t, []string{"google", "bing"}, payload.Results[0].Engines)
+}
+
+func TestWebSearchHandleFanOutUnsupported(t *testing.T) {
+	tool := mustWebSearchTool(t,
+		func(context.Context) string { return "token" },
+		func(context.Context, string, oneapi.Price, string) error { return nil },
+		&stubSearchProvider{},
+	)
+
+	req := mcp.CallToolRequest{
+		Params: mcp.CallToolParams{
+			Arguments: map[string]any{"query": "golang", "fanout": true},
+		},
+	}
+
+	result, err := tool.Handle(context.Background(), req)
+	require.NoError(t, err)
+	require.True(t, result.IsError)
+}
+
+type stubFanOutSearchProvider struct {
+	stubSearchProvider
+	engines    []string
+	maxEngines int
+}
+
+func (s *stubFanOutSearchProvider) SearchFanOut(_ context.Context, _ string, maxEngines int) (*searchlib.SearchOutput, error) {
+	s.maxEngines = maxEngines
+	return &searchlib.SearchOutput{
+		Items: []searchlib.SearchResultItem{
+			{URL: "https://example.com", Name: "Example", Engines: []string{"google", "bing"}},
+		},
+		EngineName: "google,bing,firecrawl",
+		EngineType: searchlib.EngineTypeFanOut,
+		Engines:    s.engines,
+	}, nil
+}
+
 type stubSearchProvider struct {
 	items []searchlib.SearchResultItem
 	err   error
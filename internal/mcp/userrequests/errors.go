@@ -15,6 +15,14 @@ var (
 	ErrSavedCommandNotFound = errors.New("saved command not found")
 	// ErrSavedCommandLimitReached indicates the user has reached the maximum number of saved commands.
 	ErrSavedCommandLimitReached = errors.New("saved command limit reached")
+	// ErrSavedPipelineNotFound is returned when a referenced saved pipeline cannot be located for the authenticated user.
+	ErrSavedPipelineNotFound = errors.New("saved pipeline not found")
+	// ErrSavedPipelineLimitReached indicates the user has reached the maximum number of saved pipelines.
+	ErrSavedPipelineLimitReached = errors.New("saved pipeline limit reached")
+	// ErrSavedPipelineNameTaken indicates the user already has a saved pipeline with the requested name.
+	ErrSavedPipelineNameTaken = errors.New("saved pipeline name already in use")
+	// ErrInvalidSavedPipeline indicates the saved pipeline name, vars, or spec did not pass validation.
+	ErrInvalidSavedPipeline = errors.New("invalid saved pipeline")
 	// ErrInvalidSearchQuery indicates the search query did not pass validation.
 	ErrInvalidSearchQuery = errors.New("invalid search query")
 	// ErrInvalidCursor indicates the cursor parameter did not pass validation.
@@ -19,7 +19,7 @@ import (
 	mcpauth "github.com/Laisky/laisky-blog-graphql/internal/mcp/auth"
 )
 
-// NewCombinedHTTPHandler creates a handler that routes both user requests and saved commands APIs.
+// NewCombinedHTTPHandler creates a handler that routes user requests, saved commands, and saved pipelines APIs.
 // This avoids route conflicts in Gin by handling all paths under /api/* in a single handler.
 // holdManager may be nil if the hold feature is not enabled.
 func NewCombinedHTTPHandler(service *Service, holdManager *HoldManager, logger logSDK.Logger, availableToolsProvider func() []string) http.Handler {
@@ -30,9 +30,10 @@ func NewCombinedHTTPHandler(service *Service, holdManager *HoldManager, logger l
 // imageManager may be nil; when nil, image endpoints return 415 feature_disabled.
 func NewCombinedHTTPHandlerWithImages(service *Service, holdManager *HoldManager, imageManager *ImageManager, logger logSDK.Logger, availableToolsProvider func() []string) http.Handler {
 	handler := &combinedHTTPHandler{
-		requestsHandler:     &httpHandler{service: service, holdManager: holdManager, imageManager: imageManager, logger: logger},
-		savedCommandHandler: &savedCommandsHTTPHandler{service: service, logger: logger},
-		preferencesHandler:  &preferencesHTTPHandler{service: service, logger: logger, availableToolsProvider: availableToolsProvider},
+		requestsHandler:      &httpHandler{service: service, holdManager: holdManager, imageManager: imageManager, logger: logger},
+		savedCommandHandler:  &savedCommandsHTTPHandler{service: service, logger: logger},
+		savedPipelineHandler: &savedPipelinesHTTPHandler{service: service, logger: logger},
+		preferencesHandler:   &preferencesHTTPHandler{service: service, logger: logger, availableToolsProvider: availableToolsProvider},
 	}
 	if holdManager != nil {
 		handler.holdHandler = &holdHTTPHandler{holdManager: holdManager, logger: logger}
@@ -41,10 +42,11 @@ func NewCombinedHTTPHandlerWithImages(service *Service, holdManager *HoldManager
 }
 
 type combinedHTTPHandler struct {
-	requestsHandler     *httpHandler
-	savedCommandHandler *savedCommandsHTTPHandler
-	holdHandler         *holdHTTPHandler
-	preferencesHandler  *preferencesHTTPHandler
+	requestsHandler      *httpHandler
+	savedCommandHandler  *savedCommandsHTTPHandler
+	savedPipelineHandler *savedPipelinesHTTPHandler
+	holdHandler          *holdHTTPHandler
+	preferencesHandler   *preferencesHTTPHandler
 }
 
 // ServeHTTP routes requests to the appropriate handler based on the URL path.
@@ -52,6 +54,8 @@ func (h *combinedHTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	switch {
 	case strings.HasPrefix(r.URL.Path, "/api/saved-commands"):
 		h.savedCommandHandler.ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, savedPipelinesAPIPath):
+		h.savedPipelineHandler.ServeHTTP(w, r)
 	case strings.HasPrefix(r.URL.Path, "/api/hold"):
 		if h.holdHandler == nil {
 			http.Error(w, "hold feature not enabled", http.StatusNotFound)
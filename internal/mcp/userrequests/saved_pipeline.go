@@ -0,0 +1,103 @@
+package userrequests
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxSavedPipelinesPerUser is the maximum number of saved pipelines a single user can store.
+	MaxSavedPipelinesPerUser = 50
+	// MaxSavedPipelineNameLength is the maximum length allowed for a saved pipeline's name.
+	MaxSavedPipelineNameLength = 48
+	// MaxSavedPipelineDescriptionLength is the maximum length allowed for a saved pipeline's description.
+	MaxSavedPipelineDescriptionLength = 1000
+	// MaxSavedPipelineSpecLength is the maximum size in bytes of a saved pipeline's encoded spec.
+	MaxSavedPipelineSpecLength = 64 * 1024
+	// MaxSavedPipelineVars is the maximum number of input variables a saved pipeline can declare.
+	MaxSavedPipelineVars = 32
+)
+
+// Supported input variable types for saved pipelines. They map directly to JSON schema types.
+const (
+	SavedPipelineVarString  = "string"
+	SavedPipelineVarNumber  = "number"
+	SavedPipelineVarInteger = "integer"
+	SavedPipelineVarBoolean = "boolean"
+	SavedPipelineVarObject  = "object"
+	SavedPipelineVarArray   = "array"
+)
+
+// SavedPipelineVar declares one input variable of a saved pipeline.
+// Callers supply it as a tool argument and the pipeline reads it as ${vars.<name>}.
+type SavedPipelineVar struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Default     any    `json:"default,omitempty"`
+}
+
+// SavedPipeline represents a named mcp_pipe spec stored by a user and exposed as an MCP tool.
+type SavedPipeline struct {
+	ID           uuid.UUID
+	Name         string
+	Description  string
+	Vars         []SavedPipelineVar
+	Spec         json.RawMessage
+	APIKeyHash   string
+	KeySuffix    string
+	UserIdentity string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// TableName specifies the database table name for saved pipelines.
+func (SavedPipeline) TableName() string {
+	return "mcp_saved_pipelines"
+}
+
+// SavedPipelineInput carries the user-editable fields of a saved pipeline.
+// Nil fields are left unchanged on update.
+type SavedPipelineInput struct {
+	Name        *string             `json:"name,omitempty"`
+	Description *string             `json:"description,omitempty"`
+	Vars        *[]SavedPipelineVar `json:"vars,omitempty"`
+	Spec        json.RawMessage     `json:"spec,omitempty"`
+}
+
+// PipelineSpecValidator checks that a spec is a valid mcp_pipe pipeline for the declared vars.
+type PipelineSpecValidator func(spec json.RawMessage, vars []SavedPipelineVar) error
+
+// SavedPipelineChangeListener is notified after a saved pipeline was updated or deleted.
+type SavedPipelineChangeListener func(id uuid.UUID)
+
+// SavedPipelineDTO is the data transfer object for saved pipeline API responses.
+type SavedPipelineDTO struct {
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Vars        []SavedPipelineVar `json:"vars"`
+	Spec        json.RawMessage    `json:"spec"`
+	CreatedAt   string             `json:"created_at"`
+	UpdatedAt   string             `json:"updated_at"`
+}
+
+// ToDTO converts a SavedPipeline model to its DTO representation.
+func (p *SavedPipeline) ToDTO() SavedPipelineDTO {
+	vars := p.Vars
+	if vars == nil {
+		vars = []SavedPipelineVar{}
+	}
+	return SavedPipelineDTO{
+		ID:          p.ID.String(),
+		Name:        p.Name,
+		Description: p.Description,
+		Vars:        vars,
+		Spec:        p.Spec,
+		CreatedAt:   p.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   p.UpdatedAt.Format(time.RFC3339),
+	}
+}
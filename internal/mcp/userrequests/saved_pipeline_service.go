@@ -0,0 +1,520 @@
+package userrequests
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"math"
+	"regexp"
+	"strings"
+
+	errors "github.com/Laisky/errors/v2"
+	gutils "github.com/Laisky/go-utils/v6"
+	"github.com/Laisky/zap"
+	"github.com/google/uuid"
+
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/askuser"
+)
+
+var (
+	// savedPipelineNamePattern restricts pipeline names to identifiers that are safe inside MCP tool names.
+	savedPipelineNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+	// savedPipelineVarPattern restricts var names to identifiers addressable as ${vars.<name>}.
+	savedPipelineVarPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,63}$`)
+)
+
+const savedPipelineColumns = `id, name, description, vars, spec, api_key_hash, key_suffix, user_identity, created_at, updated_at`
+
+// WithPipelineSpecValidator registers a validator that saved pipeline specs must pass before they are stored.
+// It must be called before the service starts handling requests.
+func (s *Service) WithPipelineSpecValidator(validator PipelineSpecValidator) {
+	if s == nil {
+		return
+	}
+	s.pipelineSpecValidator = validator
+}
+
+// WithSavedPipelineChangeListener registers a listener notified after a saved pipeline
+// was updated or deleted, so that caches derived from it can be dropped.
+// It must be called before the service starts handling requests.
+func (s *Service) WithSavedPipelineChangeListener(listener SavedPipelineChangeListener) {
+	if s == nil || listener == nil {
+		return
+	}
+	s.pipelineChangeListeners = append(s.pipelineChangeListeners, listener)
+}
+
+// notifySavedPipelineChanged calls the registered change listeners for id.
+func (s *Service) notifySavedPipelineChanged(id uuid.UUID) {
+	for _, listener := range s.pipelineChangeListeners {
+		listener(id)
+	}
+}
+
+// CreateSavedPipeline stores a new saved pipeline for the authenticated user.
+// Name and Spec are required; Description and Vars are optional.
+func (s *Service) CreateSavedPipeline(ctx context.Context, auth *askuser.AuthorizationContext, input SavedPipelineInput) (*SavedPipeline, error) {
+	if auth == nil {
+		return nil, ErrInvalidAuthorization
+	}
+	if input.Name == nil {
+		return nil, errors.Wrap(ErrInvalidSavedPipeline, "name is required")
+	}
+
+	pipeline := &SavedPipeline{
+		Name: strings.TrimSpace(*input.Name),
+		Spec: input.Spec,
+		Vars: []SavedPipelineVar{},
+	}
+	if input.Description != nil {
+		pipeline.Description = strings.TrimSpace(*input.Description)
+	}
+	if input.Vars != nil {
+		pipeline.Vars = *input.Vars
+	}
+	if err := s.validateSavedPipeline(pipeline); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var count int64
+	if err := s.queryRowContext(ctx,
+		`SELECT COUNT(1) FROM mcp_saved_pipelines WHERE api_key_hash = ?`,
+		auth.APIKeyHash,
+	).Scan(&count); err != nil {
+		return nil, errors.Wrap(err, "count saved pipelines")
+	}
+	if count >= MaxSavedPipelinesPerUser {
+		return nil, ErrSavedPipelineLimitReached
+	}
+	if err := s.ensureSavedPipelineNameFree(ctx, auth, pipeline.Name, uuid.Nil); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	now := s.clock()
+	pipeline.ID = gutils.UUID7Bytes()
+	pipeline.APIKeyHash = auth.APIKeyHash
+	pipeline.KeySuffix = auth.KeySuffix
+	pipeline.UserIdentity = auth.UserIdentity
+	pipeline.CreatedAt = now
+	pipeline.UpdatedAt = now
+
+	varsJSON, err := json.Marshal(pipeline.Vars)
+	if err != nil {
+		return nil, errors.Wrap(err, "encode saved pipeline vars")
+	}
+
+	if _, err := s.execContext(ctx,
+		`INSERT INTO mcp_saved_pipelines
+		 (`+savedPipelineColumns+`)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		pipeline.ID.String(),
+		pipeline.Name,
+		pipeline.Description,
+		string(varsJSON),
+		string(pipeline.Spec),
+		pipeline.APIKeyHash,
+		pipeline.KeySuffix,
+		pipeline.UserIdentity,
+		pipeline.CreatedAt,
+		pipeline.UpdatedAt,
+	); err != nil {
+		// A concurrent create can take the name after ensureSavedPipelineNameFree.
+		if isUniqueConstraintError(err) {
+			return nil, ErrSavedPipelineNameTaken
+		}
+		return nil, errors.Wrap(err, "create saved pipeline")
+	}
+
+	s.log().Info("saved pipeline created",
+		zap.String("pipeline_id", pipeline.ID.String()),
+		zap.String("user", auth.UserIdentity),
+		zap.String("name", pipeline.Name),
+	)
+
+	return pipeline, nil
+}
+
+// ListSavedPipelines returns all saved pipelines for the authenticated user, ordered by name.
+func (s *Service) ListSavedPipelines(ctx context.Context, auth *askuser.AuthorizationContext) ([]SavedPipeline, error) {
+	if auth == nil {
+		return nil, ErrInvalidAuthorization
+	}
+
+	rows, err := s.queryContext(ctx,
+		`SELECT `+savedPipelineColumns+`
+		 FROM mcp_saved_pipelines
+		 WHERE api_key_hash = ?
+		 ORDER BY name ASC
+		 LIMIT ?`,
+		auth.APIKeyHash,
+		MaxSavedPipelinesPerUser,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "list saved pipelines")
+	}
+
+	pipelines, err := scanSavedPipelineRows(rows)
+	if err != nil {
+		return nil, errors.Wrap(err, "scan saved pipelines")
+	}
+
+	return pipelines, nil
+}
+
+// GetSavedPipeline returns one saved pipeline belonging to the authenticated user.
+func (s *Service) GetSavedPipeline(ctx context.Context, auth *askuser.AuthorizationContext, id uuid.UUID) (*SavedPipeline, error) {
+	if auth == nil {
+		return nil, ErrInvalidAuthorization
+	}
+
+	pipeline, err := scanSavedPipelineRow(s.queryRowContext(ctx,
+		`SELECT `+savedPipelineColumns+`
+		 FROM mcp_saved_pipelines
+		 WHERE id = ? AND api_key_hash = ?
+		 LIMIT 1`,
+		id.String(),
+		auth.APIKeyHash,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrSavedPipelineNotFound
+		}
+		return nil, errors.Wrap(err, "get saved pipeline")
+	}
+
+	return pipeline, nil
+}
+
+// GetSavedPipelineByName returns the authenticated user's saved pipeline with the given name.
+func (s *Service) GetSavedPipelineByName(ctx context.Context, auth *askuser.AuthorizationContext, name string) (*SavedPipeline, error) {
+	if auth == nil {
+		return nil, ErrInvalidAuthorization
+	}
+
+	pipeline, err := scanSavedPipelineRow(s.queryRowContext(ctx,
+		`SELECT `+savedPipelineColumns+`
+		 FROM mcp_saved_pipelines
+		 WHERE api_key_hash = ? AND name = ?
+		 LIMIT 1`,
+		auth.APIKeyHash,
+		strings.TrimSpace(name),
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrSavedPipelineNotFound
+		}
+		return nil, errors.Wrap(err, "get saved pipeline by name")
+	}
+
+	return pipeline, nil
+}
+
+// UpdateSavedPipeline modifies an existing saved pipeline belonging to the authenticated user.
+// Only the non-nil fields of input are changed; the result is validated as a whole.
+func (s *Service) UpdateSavedPipeline(ctx context.Context, auth *askuser.AuthorizationContext, id uuid.UUID, input SavedPipelineInput) (*SavedPipeline, error) {
+	pipeline, err := s.GetSavedPipeline(ctx, auth, id)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if input.Name == nil && input.Description == nil && input.Vars == nil && input.Spec == nil {
+		return pipeline, nil
+	}
+	if input.Name != nil {
+		pipeline.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Description != nil {
+		pipeline.Description = strings.TrimSpace(*input.Description)
+	}
+	if input.Vars != nil {
+		pipeline.Vars = *input.Vars
+	}
+	if input.Spec != nil {
+		pipeline.Spec = input.Spec
+	}
+	if err := s.validateSavedPipeline(pipeline); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := s.ensureSavedPipelineNameFree(ctx, auth, pipeline.Name, id); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	varsJSON, err := json.Marshal(pipeline.Vars)
+	if err != nil {
+		return nil, errors.Wrap(err, "encode saved pipeline vars")
+	}
+	pipeline.UpdatedAt = s.clock()
+
+	if _, err := s.execContext(ctx,
+		`UPDATE mcp_saved_pipelines
+		 SET name = ?, description = ?, vars = ?, spec = ?, updated_at = ?
+		 WHERE id = ? AND api_key_hash = ?`,
+		pipeline.Name,
+		pipeline.Description,
+		string(varsJSON),
+		string(pipeline.Spec),
+		pipeline.UpdatedAt,
+		id.String(),
+		auth.APIKeyHash,
+	); err != nil {
+		if isUniqueConstraintError(err) {
+			return nil, ErrSavedPipelineNameTaken
+		}
+		return nil, errors.Wrap(err, "update saved pipeline")
+	}
+	s.notifySavedPipelineChanged(pipeline.ID)
+
+	s.log().Info("saved pipeline updated",
+		zap.String("pipeline_id", pipeline.ID.String()),
+		zap.String("user", auth.UserIdentity),
+		zap.String("name", pipeline.Name),
+	)
+
+	return pipeline, nil
+}
+
+// DeleteSavedPipeline removes a single saved pipeline belonging to the authenticated user.
+func (s *Service) DeleteSavedPipeline(ctx context.Context, auth *askuser.AuthorizationContext, id uuid.UUID) error {
+	if auth == nil {
+		return ErrInvalidAuthorization
+	}
+
+	result, err := s.execContext(ctx,
+		`DELETE FROM mcp_saved_pipelines WHERE id = ? AND api_key_hash = ?`,
+		id.String(),
+		auth.APIKeyHash,
+	)
+	if err != nil {
+		return errors.Wrap(err, "delete saved pipeline")
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "read deleted saved pipeline rows affected")
+	}
+	if rowsAffected == 0 {
+		return ErrSavedPipelineNotFound
+	}
+	s.notifySavedPipelineChanged(id)
+
+	s.log().Info("saved pipeline deleted",
+		zap.String("pipeline_id", id.String()),
+		zap.String("user", auth.UserIdentity),
+	)
+
+	return nil
+}
+
+// ensureSavedPipelineNameFree reports ErrSavedPipelineNameTaken when another pipeline of the user already uses name.
+func (s *Service) ensureSavedPipelineNameFree(ctx context.Context, auth *askuser.AuthorizationContext, name string, exceptID uuid.UUID) error {
+	var count int64
+	if err := s.queryRowContext(ctx,
+		`SELECT COUNT(1) FROM mcp_saved_pipelines WHERE api_key_hash = ? AND name = ? AND id <> ?`,
+		auth.APIKeyHash,
+		name,
+		exceptID.String(),
+	).Scan(&count); err != nil {
+		return errors.Wrap(err, "check saved pipeline name")
+	}
+	if count > 0 {
+		return ErrSavedPipelineNameTaken
+	}
+	return nil
+}
+
+// validateSavedPipeline checks the name, description, vars, and spec of a pipeline.
+// Errors wrap ErrInvalidSavedPipeline so callers can map them to client errors.
+func (s *Service) validateSavedPipeline(pipeline *SavedPipeline) error {
+	if pipeline.Name == "" || len(pipeline.Name) > MaxSavedPipelineNameLength || !savedPipelineNamePattern.MatchString(pipeline.Name) {
+		return errors.Wrapf(ErrInvalidSavedPipeline,
+			"name must start with a lowercase letter, contain only lowercase letters, digits, and underscores, and be at most %d characters", MaxSavedPipelineNameLength)
+	}
+	if len(pipeline.Description) > MaxSavedPipelineDescriptionLength {
+		return errors.Wrapf(ErrInvalidSavedPipeline, "description exceeds %d characters", MaxSavedPipelineDescriptionLength)
+	}
+
+	if len(pipeline.Vars) > MaxSavedPipelineVars {
+		return errors.Wrapf(ErrInvalidSavedPipeline, "at most %d vars can be declared", MaxSavedPipelineVars)
+	}
+	seen := make(map[string]struct{}, len(pipeline.Vars))
+	for i := range pipeline.Vars {
+		v := &pipeline.Vars[i]
+		v.Name = strings.TrimSpace(v.Name)
+		v.Description = strings.TrimSpace(v.Description)
+		if !savedPipelineVarPattern.MatchString(v.Name) {
+			return errors.Wrapf(ErrInvalidSavedPipeline, "invalid var name %q", v.Name)
+		}
+		if _, ok := seen[v.Name]; ok {
+			return errors.Wrapf(ErrInvalidSavedPipeline, "duplicate var %q", v.Name)
+		}
+		seen[v.Name] = struct{}{}
+
+		if v.Type == "" {
+			v.Type = SavedPipelineVarString
+		}
+		if v.Default != nil {
+			if err := CheckSavedPipelineVarType(v.Type, v.Default); err != nil {
+				return errors.Wrapf(ErrInvalidSavedPipeline, "default of var %q: %s", v.Name, err.Error())
+			}
+		} else if !isSavedPipelineVarType(v.Type) {
+			return errors.Wrapf(ErrInvalidSavedPipeline, "var %q has unsupported type %q", v.Name, v.Type)
+		}
+	}
+
+	if len(pipeline.Spec) == 0 {
+		return errors.Wrap(ErrInvalidSavedPipeline, "spec is required")
+	}
+	if len(pipeline.Spec) > MaxSavedPipelineSpecLength {
+		return errors.Wrapf(ErrInvalidSavedPipeline, "spec exceeds %d bytes", MaxSavedPipelineSpecLength)
+	}
+	var spec map[string]any
+	if err := json.Unmarshal(pipeline.Spec, &spec); err != nil {
+		return errors.Wrap(ErrInvalidSavedPipeline, "spec must be a JSON object")
+	}
+	if steps, ok := spec["steps"].([]any); !ok || len(steps) == 0 {
+		return errors.Wrap(ErrInvalidSavedPipeline, "spec must contain a non-empty steps array")
+	}
+	if _, ok := spec["vars"]; ok {
+		return errors.Wrap(ErrInvalidSavedPipeline, "spec cannot set vars; declare them as pipeline vars instead")
+	}
+
+	if s.pipelineSpecValidator != nil {
+		if err := s.pipelineSpecValidator(pipeline.Spec, pipeline.Vars); err != nil {
+			return errors.Wrapf(ErrInvalidSavedPipeline, "spec: %s", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// isSavedPipelineVarType reports whether typ is a supported saved pipeline var type.
+func isSavedPipelineVarType(typ string) bool {
+	switch typ {
+	case SavedPipelineVarString, SavedPipelineVarNumber, SavedPipelineVarInteger,
+		SavedPipelineVarBoolean, SavedPipelineVarObject, SavedPipelineVarArray:
+		return true
+	default:
+		return false
+	}
+}
+
+// CheckSavedPipelineVarType reports whether a decoded JSON value matches a declared var type.
+func CheckSavedPipelineVarType(typ string, value any) error {
+	ok := false
+	switch typ {
+	case SavedPipelineVarString:
+		_, ok = value.(string)
+	case SavedPipelineVarNumber:
+		_, ok = jsonNumber(value)
+	case SavedPipelineVarInteger:
+		f, isNumber := jsonNumber(value)
+		ok = isNumber && f == math.Trunc(f)
+	case SavedPipelineVarBoolean:
+		_, ok = value.(bool)
+	case SavedPipelineVarObject:
+		_, ok = value.(map[string]any)
+	case SavedPipelineVarArray:
+		_, ok = value.([]any)
+	default:
+		return errors.Errorf("unsupported type %q", typ)
+	}
+	if !ok {
+		return errors.Errorf("expected %s", typ)
+	}
+	return nil
+}
+
+// jsonNumber converts the numeric representations produced by JSON decoding or Go callers to float64.
+func jsonNumber(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// scanSavedPipelineRows reads saved pipeline rows into models.
+func scanSavedPipelineRows(rows *sql.Rows) ([]SavedPipeline, error) {
+	defer func() { _ = rows.Close() }()
+
+	items := make([]SavedPipeline, 0)
+	for rows.Next() {
+		item, err := scanSavedPipelineValues(rows.Scan)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		items = append(items, *item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate saved pipeline rows")
+	}
+
+	return items, nil
+}
+
+// scanSavedPipelineRow reads one saved pipeline row into a model.
+func scanSavedPipelineRow(row *sql.Row) (*SavedPipeline, error) {
+	item, err := scanSavedPipelineValues(row.Scan)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return item, nil
+}
+
+// scanSavedPipelineValues extracts a SavedPipeline from a scanner callback.
+func scanSavedPipelineValues(scanFn func(dest ...any) error) (*SavedPipeline, error) {
+	var (
+		idRaw        string
+		varsRaw      string
+		specRaw      string
+		createdAtRaw any
+		updatedAtRaw any
+		item         SavedPipeline
+	)
+	if err := scanFn(
+		&idRaw,
+		&item.Name,
+		&item.Description,
+		&varsRaw,
+		&specRaw,
+		&item.APIKeyHash,
+		&item.KeySuffix,
+		&item.UserIdentity,
+		&createdAtRaw,
+		&updatedAtRaw,
+	); err != nil {
+		return nil, errors.Wrap(err, "scan saved pipeline row")
+	}
+
+	parsedID, err := uuid.Parse(idRaw)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse saved pipeline id")
+	}
+	item.ID = parsedID
+
+	if err := json.Unmarshal([]byte(varsRaw), &item.Vars); err != nil {
+		return nil, errors.Wrap(err, "decode saved pipeline vars")
+	}
+	item.Spec = json.RawMessage(specRaw)
+
+	item.CreatedAt, err = parseSQLTime(createdAtRaw)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse saved pipeline created_at")
+	}
+	item.UpdatedAt, err = parseSQLTime(updatedAtRaw)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse saved pipeline updated_at")
+	}
+
+	return &item, nil
+}
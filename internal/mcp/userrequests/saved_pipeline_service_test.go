@@ -0,0 +1,205 @@
+package userrequests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	errors "github.com/Laisky/errors/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func strPtr(s string) *string { return &s }
+
+func newSavedPipelineTestService(t *testing.T) *Service {
+	t.Helper()
+	svc, err := NewService(newTestDB(t), nil, func() time.Time { return time.Now().UTC() }, Settings{RetentionDays: DefaultRetentionDays})
+	require.NoError(t, err)
+	return svc
+}
+
+// TestServiceSavedPipelineLifecycle verifies create, get, update, list, and delete for saved pipelines.
+func TestServiceSavedPipelineLifecycle(t *testing.T) {
+	svc := newSavedPipelineTestService(t)
+	auth := testAuth("hash-pipeline", "pipe")
+	other := testAuth("hash-pipeline-other", "othr")
+	ctx := context.Background()
+	var changed []uuid.UUID
+	svc.WithSavedPipelineChangeListener(func(id uuid.UUID) { changed = append(changed, id) })
+
+	vars := []SavedPipelineVar{
+		{Name: "query", Type: SavedPipelineVarString, Required: true},
+		{Name: "limit", Type: SavedPipelineVarInteger, Default: float64(3)},
+	}
+	created, err := svc.CreateSavedPipeline(ctx, auth, SavedPipelineInput{
+		Name:        strPtr("search_digest"),
+		Description: strPtr("  Search and summarize  "),
+		Vars:        &vars,
+		Spec:        json.RawMessage(`{"steps":[{"id":"s","tool":"web_search","args":{"query":"${vars.query}"}}]}`),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Search and summarize", created.Description)
+
+	byName, err := svc.GetSavedPipelineByName(ctx, auth, "search_digest")
+	require.NoError(t, err)
+	require.Equal(t, created.ID, byName.ID)
+	require.Equal(t, vars, byName.Vars)
+	require.JSONEq(t, string(created.Spec), string(byName.Spec))
+
+	_, err = svc.GetSavedPipelineByName(ctx, other, "search_digest")
+	require.ErrorIs(t, err, ErrSavedPipelineNotFound)
+
+	_, err = svc.CreateSavedPipeline(ctx, auth, SavedPipelineInput{
+		Name: strPtr("search_digest"),
+		Spec: json.RawMessage(`{"steps":[{"id":"a","tool":"x"}]}`),
+	})
+	require.ErrorIs(t, err, ErrSavedPipelineNameTaken)
+
+	// The same name is free for another API key.
+	_, err = svc.CreateSavedPipeline(ctx, other, SavedPipelineInput{
+		Name: strPtr("search_digest"),
+		Spec: json.RawMessage(`{"steps":[{"id":"a","tool":"x"}]}`),
+	})
+	require.NoError(t, err)
+
+	updated, err := svc.UpdateSavedPipeline(ctx, auth, created.ID, SavedPipelineInput{Name: strPtr("digest")})
+	require.NoError(t, err)
+	require.Equal(t, "digest", updated.Name)
+	require.Equal(t, vars, updated.Vars)
+	require.Equal(t, []uuid.UUID{created.ID}, changed)
+
+	listed, err := svc.ListSavedPipelines(ctx, auth)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	require.Equal(t, "digest", listed[0].Name)
+
+	require.NoError(t, svc.DeleteSavedPipeline(ctx, auth, created.ID))
+	require.ErrorIs(t, svc.DeleteSavedPipeline(ctx, auth, created.ID), ErrSavedPipelineNotFound)
+	require.Equal(t, []uuid.UUID{created.ID, created.ID}, changed)
+	_, err = svc.UpdateSavedPipeline(ctx, auth, uuid.New(), SavedPipelineInput{Name: strPtr("x")})
+	require.ErrorIs(t, err, ErrSavedPipelineNotFound)
+}
+
+// TestServiceSavedPipelineConcurrentName verifies a name taken by a concurrent create
+// after the pre-check is reported as ErrSavedPipelineNameTaken, not a storage error.
+func TestServiceSavedPipelineConcurrentName(t *testing.T) {
+	svc := newSavedPipelineTestService(t)
+	auth := testAuth("hash-pipeline-race", "race")
+	ctx := context.Background()
+
+	// The trigger plays the concurrent request: it stores the name right before our insert.
+	_, err := svc.db.ExecContext(ctx, `CREATE TRIGGER saved_pipeline_race BEFORE INSERT ON mcp_saved_pipelines
+		WHEN NEW.name = 'raced' AND NOT EXISTS (SELECT 1 FROM mcp_saved_pipelines WHERE api_key_hash = NEW.api_key_hash AND name = 'raced')
+		BEGIN
+			INSERT INTO mcp_saved_pipelines (`+savedPipelineColumns+`)
+			VALUES ('other-id', NEW.name, '', '[]', NEW.spec, NEW.api_key_hash, NEW.key_suffix, NEW.user_identity, NEW.created_at, NEW.updated_at);
+		END`)
+	require.NoError(t, err)
+	t.Cleanup(func() { _, _ = svc.db.ExecContext(ctx, `DROP TRIGGER IF EXISTS saved_pipeline_race`) })
+
+	_, err = svc.CreateSavedPipeline(ctx, auth, SavedPipelineInput{
+		Name: strPtr("raced"),
+		Spec: json.RawMessage(`{"steps":[{"id":"a","tool":"x"}]}`),
+	})
+	require.ErrorIs(t, err, ErrSavedPipelineNameTaken)
+}
+
+// TestServiceSavedPipelineValidation verifies that invalid names, vars, and specs are rejected.
+func TestServiceSavedPipelineValidation(t *testing.T) {
+	svc := newSavedPipelineTestService(t)
+	auth := testAuth("hash-pipeline-validation", "vald")
+	ctx := context.Background()
+	spec := json.RawMessage(`{"steps":[{"id":"a","tool":"x"}]}`)
+
+	cases := []struct {
+		name  string
+		input SavedPipelineInput
+		want  string
+	}{
+		{"missing name", SavedPipelineInput{Spec: spec}, "name is required"},
+		{"bad name", SavedPipelineInput{Name: strPtr("Has Space"), Spec: spec}, "name must start"},
+		{"missing spec", SavedPipelineInput{Name: strPtr("a")}, "spec is required"},
+		{"no steps", SavedPipelineInput{Name: strPtr("a"), Spec: json.RawMessage(`{"steps":[]}`)}, "non-empty steps"},
+		{"spec vars", SavedPipelineInput{Name: strPtr("a"), Spec: json.RawMessage(`{"vars":{},"steps":[{"id":"a","tool":"x"}]}`)}, "cannot set vars"},
+		{"bad var type", SavedPipelineInput{Name: strPtr("a"), Spec: spec, Vars: &[]SavedPipelineVar{{Name: "v", Type: "date"}}}, "unsupported type"},
+		{"duplicate var", SavedPipelineInput{Name: strPtr("a"), Spec: spec, Vars: &[]SavedPipelineVar{{Name: "v"}, {Name: "v"}}}, "duplicate var"},
+		{"bad default", SavedPipelineInput{Name: strPtr("a"), Spec: spec, Vars: &[]SavedPipelineVar{{Name: "v", Type: "integer", Default: 1.5}}}, "expected integer"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := svc.CreateSavedPipeline(ctx, auth, tc.input)
+			require.ErrorIs(t, err, ErrInvalidSavedPipeline)
+			require.ErrorContains(t, err, tc.want)
+		})
+	}
+
+	svc.WithPipelineSpecValidator(func(json.RawMessage, []SavedPipelineVar) error {
+		return errors.New("unknown tool x")
+	})
+	_, err := svc.CreateSavedPipeline(ctx, auth, SavedPipelineInput{Name: strPtr("a"), Spec: spec})
+	require.ErrorIs(t, err, ErrInvalidSavedPipeline)
+	require.ErrorContains(t, err, "unknown tool x")
+}
+
+// TestCheckSavedPipelineVarType verifies JSON value type checks for declared vars.
+func TestCheckSavedPipelineVarType(t *testing.T) {
+	require.NoError(t, CheckSavedPipelineVarType(SavedPipelineVarString, "x"))
+	require.NoError(t, CheckSavedPipelineVarType(SavedPipelineVarNumber, 1.5))
+	require.NoError(t, CheckSavedPipelineVarType(SavedPipelineVarInteger, float64(2)))
+	require.NoError(t, CheckSavedPipelineVarType(SavedPipelineVarInteger, 2))
+	require.NoError(t, CheckSavedPipelineVarType(SavedPipelineVarBoolean, true))
+	require.NoError(t, CheckSavedPipelineVarType(SavedPipelineVarObject, map[string]any{}))
+	require.NoError(t, CheckSavedPipelineVarType(SavedPipelineVarArray, []any{}))
+	require.Error(t, CheckSavedPipelineVarType(SavedPipelineVarInteger, 2.5))
+	require.Error(t, CheckSavedPipelineVarType(SavedPipelineVarString, 1.0))
+	require.Error(t, CheckSavedPipelineVarType("date", "2024-01-01"))
+}
+
+// TestSavedPipelinesHTTPHandler verifies the CRUD routes of the saved pipelines API.
+func TestSavedPipelinesHTTPHandler(t *testing.T) {
+	svc := newSavedPipelineTestService(t)
+	handler := NewCombinedHTTPHandler(svc, nil, nil, nil)
+	authHeader := "Bearer sk-saved-pipelines-http"
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", authHeader)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/api/saved-pipelines", `{"name":"fetch_page","vars":[{"name":"url","required":true}],"spec":{"steps":[{"id":"f","tool":"web_fetch","args":{"url":"${vars.url}"}}]}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var created struct {
+		Pipeline SavedPipelineDTO `json:"pipeline"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	require.Equal(t, "fetch_page", created.Pipeline.Name)
+	require.Equal(t, SavedPipelineVarString, created.Pipeline.Vars[0].Type)
+
+	rec = do(http.MethodPost, "/api/saved-pipelines", `{"name":"fetch_page","spec":{"steps":[{"id":"f","tool":"web_fetch"}]}}`)
+	require.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = do(http.MethodPost, "/api/saved-pipelines", `{"name":"bad","spec":{"steps":[]}}`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = do(http.MethodPut, "/api/saved-pipelines/"+created.Pipeline.ID, `{"description":"Fetch a page"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), "Fetch a page")
+
+	rec = do(http.MethodGet, "/api/saved-pipelines", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"fetch_page"`)
+
+	rec = do(http.MethodDelete, "/api/saved-pipelines/"+created.Pipeline.ID, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = do(http.MethodGet, "/api/saved-pipelines/"+created.Pipeline.ID, "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
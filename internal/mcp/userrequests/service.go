@@ -28,6 +28,10 @@ type Service struct {
 	clock     Clock
 	settings  Settings
 	useDollar bool
+	// pipelineSpecValidator optionally checks saved pipeline specs before they are stored.
+	pipelineSpecValidator PipelineSpecValidator
+	// pipelineChangeListeners are notified after saved pipelines change.
+	pipelineChangeListeners []SavedPipelineChangeListener
 }
 
 const (
@@ -109,6 +113,19 @@ func (s *Service) ensureSchema(ctx context.Context) error {
 			updated_at TIMESTAMPTZ NOT NULL
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_mcp_saved_commands_api_sort_created ON mcp_saved_commands (api_key_hash, sort_order, created_at)`,
+		`CREATE TABLE IF NOT EXISTS mcp_saved_pipelines (
+			id UUID PRIMARY KEY,
+			name VARCHAR(64) NOT NULL,
+			description TEXT NOT NULL,
+			vars TEXT NOT NULL,
+			spec TEXT NOT NULL,
+			api_key_hash CHAR(64) NOT NULL,
+			key_suffix VARCHAR(16) NOT NULL,
+			user_identity VARCHAR(255) NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_mcp_saved_pipelines_api_name ON mcp_saved_pipelines (api_key_hash, name)`,
 		`CREATE TABLE IF NOT EXISTS mcp_user_preferences (
 			api_key_hash CHAR(64) PRIMARY KEY,
 			key_suffix VARCHAR(16) NOT NULL,
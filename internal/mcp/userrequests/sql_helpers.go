@@ -5,6 +5,10 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+
+	errors "github.com/Laisky/errors/v2"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
 )
 
 // useDollarPlaceholders reports whether the SQL dialect expects $1-style bind placeholders.
@@ -52,3 +56,22 @@ func (s *Service) queryContext(ctx context.Context, query string, args ...any) (
 func (s *Service) queryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
 	return s.db.QueryRowContext(ctx, rebindQuery(query, s.useDollar), args...)
 }
+
+// isUniqueConstraintError reports whether err is a unique key conflict.
+func isUniqueConstraintError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23505"
+	}
+
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+	}
+
+	return false
+}
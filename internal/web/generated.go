@@ -220,7 +220,7 @@ type ComplexityRoot struct {
 		UserStartPasskeyRegistration  func(childComplexity int, label string) int
 		UserStartTOTPSetup            func(childComplexity int) int
 		WebFetch                      func(childComplexity int, url string) int
-		WebSearch                     func(childComplexity int, query string) int
+		WebSearch                     func(childComplexity int, query string, fanout *bool, maxEngines *int) int
 	}
 
 	OneapiQuota struct {
@@ -351,11 +351,13 @@ type ComplexityRoot struct {
 		CreatedAt  func(childComplexity int) int
 		EngineName func(childComplexity int) int
 		EngineType func(childComplexity int) int
+		Engines    func(childComplexity int) int
 		Query      func(childComplexity int) int
 		Results    func(childComplexity int) int
 	}
 
 	WebSearchResultItem struct {
+		Engines func(childComplexity int) int
 		Name    func(childComplexity int) int
 		Snippet func(childComplexity int) int
 		URL     func(childComplexity int) int
@@ -419,7 +421,7 @@ type MutationResolver interface {
 	UserDisableTotp(ctx context.Context, currentPassword string) (*models.SsoProfile, error)
 	BlogAmendPost(ctx context.Context, post models.NewBlogPost, language models.Language) (*model.Post, error)
 	ArweaveUpload(ctx context.Context, fileB64 string, contentType *string) (*dto.UploadResponse, error)
-	WebSearch(ctx context.Context, query string) (*search.SearchResult, error)
+	WebSearch(ctx context.Context, query string, fanout *bool, maxEngines *int) (*search.SearchResult, error)
 	WebFetch(ctx context.Context, url string) (*models.WebFetchResult, error)
 	TelegramMonitorAlert(ctx context.Context, typeArg string, token string, msg string) (*model3.AlertTypes, error)
 	AcquireLock(ctx context.Context, lockName string, durationSec int, isRenewal *bool) (bool, error)
@@ -1311,7 +1313,7 @@ func (e *executableSchema) Complexity(ctx context.Context, typeName, field strin
 			return 0, false
 		}
 
-		return e.complexity.Mutation.WebSearch(childComplexity, args["query"].(string)), true
+		return e.complexity.Mutation.WebSearch(childComplexity, args["query"].(string), args["fanout"].(*bool), args["max_engines"].(*int)), true
 
 	case "OneapiQuota.remain_quota":
 		if e.complexity.OneapiQuota.RemainQuota == nil {
@@ -1873,6 +1875,12 @@ func (e *executableSchema) Complexity(ctx context.Context, typeName, field strin
 		}
 
 		return e.complexity.WebSearchResult.EngineType(childComplexity), true
+	case "WebSearchResult.engines":
+		if e.complexity.WebSearchResult.Engines == nil {
+			break
+		}
+
+		return e.complexity.WebSearchResult.Engines(childComplexity), true
 	case "WebSearchResult.query":
 		if e.complexity.WebSearchResult.Query == nil {
 			break
@@ -1886,6 +1894,12 @@ func (e *executableSchema) Complexity(ctx context.Context, typeName, field strin
 
 		return e.complexity.WebSearchResult.Results(childComplexity), true
 
+	case "WebSearchResultItem.engines":
+		if e.complexity.WebSearchResultItem.Engines == nil {
+			break
+		}
+
+		return e.complexity.WebSearchResultItem.Engines(childComplexity), true
 	case "WebSearchResultItem.name":
 		if e.complexity.WebSearchResultItem.Name == nil {
 			break
@@ -2487,6 +2501,16 @@ func (ec *executionContext) field_Mutation_WebSearch_args(ctx context.Context, r
 		return nil, err
 	}
 	args["query"] = arg0
+	arg1, err := graphql.ProcessArgField(ctx, rawArgs, "fanout", ec.unmarshalOBoolean2ᚖbool)
+	if err != nil {
+		return nil, err
+	}
+	args["fanout"] = arg1
+	arg2, err := graphql.ProcessArgField(ctx, rawArgs, "max_engines", ec.unmarshalOInt2ᚖint)
+	if err != nil {
+		return nil, err
+	}
+	args["max_engines"] = arg2
 	return args, nil
 }
 
@@ -6558,7 +6582,7 @@ func (ec *executionContext) _Mutation_WebSearch(ctx context.Context, field graph
 		ec.fieldContext_Mutation_WebSearch,
 		func(ctx context.Context) (any, error) {
 			fc := graphql.GetFieldContext(ctx)
-			return ec.resolvers.Mutation().WebSearch(ctx, fc.Args["query"].(string))
+			return ec.resolvers.Mutation().WebSearch(ctx, fc.Args["query"].(string), fc.Args["fanout"].(*bool), fc.Args["max_engines"].(*int))
 		},
 		nil,
 		ec.marshalNWebSearchResult2ᚖgithubᚗcomᚋLaiskyᚋlaiskyᚑblogᚑgraphqlᚋlibraryᚋsearchᚐSearchResult,
@@ -6585,6 +6609,8 @@ func (ec *executionContext) fieldContext_Mutation_WebSearch(ctx context.Context,
 				return ec.fieldContext_WebSearchResult_engine_type(ctx, field)
 			case "results":
 				return ec.fieldContext_WebSearchResult_results(ctx, field)
+			case "engines":
+				return ec.fieldContext_WebSearchResult_engines(ctx, field)
 			}
 			return nil, fmt.Errorf("no field named %q was found under type WebSearchResult", field.Name)
 		},
@@ -9961,6 +9987,8 @@ func (ec *executionContext) fieldContext_WebSearchResult_results(_ context.Conte
 				return ec.fieldContext_WebSearchResultItem_url(ctx, field)
 			case "snippet":
 				return ec.fieldContext_WebSearchResultItem_snippet(ctx, field)
+			case "engines":
+				return ec.fieldContext_WebSearchResultItem_engines(ctx, field)
 			}
 			return nil, fmt.Errorf("no field named %q was found under type WebSearchResultItem", field.Name)
 		},
@@ -9968,6 +9996,35 @@ func (ec *executionContext) fieldContext_WebSearchResult_results(_ context.Conte
 	return fc, nil
 }
 
+func (ec *executionContext) _WebSearchResult_engines(ctx context.Context, field graphql.CollectedField, obj *search.SearchResult) (ret graphql.Marshaler) {
+	return graphql.ResolveField(
+		ctx,
+		ec.OperationContext,
+		field,
+		ec.fieldContext_WebSearchResult_engines,
+		func(ctx context.Context) (any, error) {
+			return obj.Engines, nil
+		},
+		nil,
+		ec.marshalOString2ᚕstringᚄ,
+		true,
+		false,
+	)
+}
+
+func (ec *executionContext) fieldContext_WebSearchResult_engines(_ context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+	fc = &graphql.FieldContext{
+		Object:     "WebSearchResult",
+		Field:      field,
+		IsMethod:   false,
+		IsResolver: false,
+		Child: func(ctx context.Context, field graphql.CollectedField) (*graphql.FieldContext, error) {
+			return nil, errors.New("field of type String does not have child fields")
+		},
+	}
+	return fc, nil
+}
+
 func (ec *executionContext) _WebSearchResultItem_name(ctx context.Context, field graphql.CollectedField, obj *search.SearchResultItem) (ret graphql.Marshaler) {
 	return graphql.ResolveField(
 		ctx,
@@ -10055,6 +10112,35 @@ func (ec *executionContext) fieldContext_WebSearchResultItem_snippet(_ context.C
 	return fc, nil
 }
 
+func (ec *executionContext) _WebSearchResultItem_engines(ctx context.Context, field graphql.CollectedField, obj *search.SearchResultItem) (ret graphql.Marshaler) {
+	return graphql.ResolveField(
+		ctx,
+		ec.OperationContext,
+		field,
+		ec.fieldContext_WebSearchResultItem_engines,
+		func(ctx context.Context) (any, error) {
+			return obj.Engines, nil
+		},
+		nil,
+		ec.marshalOString2ᚕstringᚄ,
+		true,
+		false,
+	)
+}
+
+func (ec *executionContext) fieldContext_WebSearchResultItem_engines(_ context.Context, field graphql.CollectedField) (fc *graphql.FieldContext, err error) {
+	fc = &graphql.FieldContext{
+		Object:     "WebSearchResultItem",
+		Field:      field,
+		IsMethod:   false,
+		IsResolver: false,
+		Child: func(ctx context.Context, field graphql.CollectedField) (*graphql.FieldContext, error) {
+			return nil, errors.New("field of type String does not have child fields")
+		},
+	}
+	return fc, nil
+}
+
 func (ec *executionContext) ___Directive_name(ctx context.Context, field graphql.CollectedField, obj *introspection.Directive) (ret graphql.Marshaler) {
 	return graphql.ResolveField(
 		ctx,
@@ -15248,6 +15334,8 @@ func (ec *executionContext) _WebSearchResult(ctx context.Context, sel ast.Select
 			if out.Values[i] == graphql.Null {
 				atomic.AddUint32(&out.Invalids, 1)
 			}
+		case "engines":
+			out.Values[i] = ec._WebSearchResult_engines(ctx, field, obj)
 		default:
 			panic("unknown field " + strconv.Quote(field.Name))
 		}
@@ -15297,6 +15385,8 @@ func (ec *executionContext) _WebSearchResultItem(ctx context.Context, sel ast.Se
 			if out.Values[i] == graphql.Null {
 				out.Invalids++
 			}
+		case "engines":
+			out.Values[i] = ec._WebSearchResultItem_engines(ctx, field, obj)
 		default:
 			panic("unknown field " + strconv.Quote(field.Name))
 		}
@@ -17051,6 +17141,24 @@ func (ec *executionContext) marshalOGeneralUser2ᚖgithubᚗcomᚋLaiskyᚋlaisk
 	return ec._GeneralUser(ctx, sel, v)
 }
 
+func (ec *executionContext) unmarshalOInt2ᚖint(ctx context.Context, v any) (*int, error) {
+	if v == nil {
+		return nil, nil
+	}
+	res, err := graphql.UnmarshalInt(v)
+	return &res, graphql.ErrorOnPath(ctx, err)
+}
+
+func (ec *executionContext) marshalOInt2ᚖint(ctx context.Context, sel ast.SelectionSet, v *int) graphql.Marshaler {
+	if v == nil {
+		return graphql.Null
+	}
+	_ = sel
+	_ = ctx
+	res := graphql.MarshalInt(*v)
+	return res
+}
+
 func (ec *executionContext) unmarshalOJSONString2ᚖgithubᚗcomᚋLaiskyᚋlaiskyᚑblogᚑgraphqlᚋlibraryᚐJSONString(ctx context.Context, v any) (*library.JSONString, error) {
 	if v == nil {
 		return nil, nil
@@ -7,8 +7,11 @@ import (
 	"github.com/Laisky/laisky-blog-graphql/internal/mcp"
 	"github.com/Laisky/laisky-blog-graphql/internal/mcp/askuser"
 	"github.com/Laisky/laisky-blog-graphql/internal/mcp/calllog"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/crawljobs"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/federation"
 	"github.com/Laisky/laisky-blog-graphql/internal/mcp/files"
 	mcpmemory "github.com/Laisky/laisky-blog-graphql/internal/mcp/memory"
+	"github.com/Laisky/laisky-blog-graphql/internal/mcp/piperuns"
 	"github.com/Laisky/laisky-blog-graphql/internal/mcp/rag"
 	mcptools "github.com/Laisky/laisky-blog-graphql/internal/mcp/tools"
 	"github.com/Laisky/laisky-blog-graphql/internal/mcp/userrequests"
@@ -20,6 +23,7 @@ import (
 	telegramDao "github.com/Laisky/laisky-blog-graphql/internal/web/telegram/dao"
 	telegramSvc "github.com/Laisky/laisky-blog-graphql/internal/web/telegram/service"
 	twitter "github.com/Laisky/laisky-blog-graphql/internal/web/twitter/controller"
+	"github.com/Laisky/laisky-blog-graphql/library/billing/oneapi"
 	rlibs "github.com/Laisky/laisky-blog-graphql/library/db/redis"
 	searchlib "github.com/Laisky/laisky-blog-graphql/library/search"
 )
@@ -42,6 +46,8 @@ type ResolverArgs struct {
 	Rdb                *rlibs.DB
 	AskUserService     *askuser.Service
 	CallLogService     *calllog.Service
+	PipeRunService     *piperuns.Service
+	CrawlJobService    *crawljobs.Service
 	UserRequestService *userrequests.Service
 	UserRequestImages  *userrequests.ImageManager
 	FilesService       *files.Service
@@ -49,7 +55,13 @@ type ResolverArgs struct {
 	MemoryService      *mcpmemory.Service
 	RAGService         *rag.Service
 	RAGSettings        rag.Settings
-	MCPToolsSettings   mcp.ToolsSettings
+	// Embedders holds the embedding providers shared by RAG, FileIO and find_tool.
+	Embedders        *rag.EmbedderRegistry
+	MCPToolsSettings mcp.ToolsSettings
+	MCPFederation    *federation.Manager
+	// BillingReporter charges the GraphQL resolvers and the MCP tools alike.
+	// Nil uses oneapi.CheckUserExternalBilling.
+	BillingReporter search.BillingReporter
 }
 
 // NewResolver new resolver. All sub-resolvers are eagerly built once so that
@@ -60,6 +72,9 @@ func NewResolver(args ResolverArgs) *Resolver {
 	if args.Rdb != nil {
 		general.ConfigureTaskStore(args.Rdb)
 	}
+	if args.BillingReporter == nil {
+		args.BillingReporter = oneapi.CheckUserExternalBilling
+	}
 	r := &Resolver{args: args}
 	// Fall back to a stub Telegram controller (with a nil service) when
 	// telegram init failed, so the TelegramAlertType/TelegramMonitorUser
@@ -118,6 +133,7 @@ func (r *Resolver) buildMutationResolver() *mutationResolver {
 				r.args.WebSearchProvider,
 				r.args.Rdb,
 				r.args.CallLogService,
+				r.args.BillingReporter,
 			),
 		},
 	}
@@ -185,26 +185,43 @@ func RunServer(addr string, resolver *Resolver) {
 		resolver.args.AskUserService != nil ||
 		resolver.args.UserRequestService != nil ||
 		resolver.args.RAGService != nil ||
-		resolver.args.MCPFileService != nil) {
+		resolver.args.MCPFileService != nil ||
+		resolver.args.MCPFederation != nil) {
 		mcpServer, err := mcp.NewServer(
 			resolver.args.WebSearchProvider,
 			resolver.args.AskUserService,
 			resolver.args.UserRequestService,
 			resolver.args.RAGService,
 			resolver.args.RAGSettings,
+			resolver.args.Embedders,
 			resolver.args.MCPFileService,
 			resolver.args.MemoryService,
 			resolver.args.Rdb,
 			resolver.args.CallLogService,
+			resolver.args.PipeRunService,
 			resolver.args.MCPToolsSettings,
 			log.Logger,
 		)
 		if err != nil {
 			log.Logger.Error("init mcp server", zap.Error(err))
 		} else {
+			mcpServer.WithBillingReporter(resolver.args.BillingReporter)
 			if resolver.args.UserRequestImages != nil {
 				mcpServer.AttachImageIssuer(resolver.args.UserRequestImages)
 			}
+			if resolver.args.MCPFederation != nil {
+				mcpServer.AttachFederation(resolver.args.MCPFederation)
+			}
+			if resolver.args.FilesService != nil && resolver.args.MCPToolsSettings.FileIOEnabled {
+				mcpServer.AttachFileResources(resolver.args.FilesService)
+				mcpServer.AttachFileGrep(resolver.args.FilesService)
+				mcpServer.AttachFileSnapshots(resolver.args.FilesService)
+				mcpServer.AttachFileIndexStatus(resolver.args.FilesService)
+			}
+			if resolver.args.CrawlJobService != nil && resolver.args.MCPToolsSettings.WebCrawlEnabled &&
+				resolver.args.MCPToolsSettings.FileIOEnabled {
+				mcpServer.AttachWebCrawl(resolver.args.CrawlJobService, resolver.args.MCPFileService)
+			}
 			mcpHandler := mcpServer.Handler()
 			rootHandler := func(ctx *gin.Context) {
 				if frontendSPA != nil && shouldServeFrontend(ctx.Request) {
@@ -0,0 +1,34 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/Laisky/errors/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// GetWebFetchExtraction gets a cached web_fetch extraction by its cache key.
+// found is false when nothing is cached under key.
+func (db *DB) GetWebFetchExtraction(ctx context.Context, cacheKey string) (val string, found bool, err error) {
+	key := KeyPrefixWebFetchExtraction + cacheKey
+	val, err = db.db.GetItem(ctx, key)
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, errors.Wrapf(err, "failed to get cached extraction by key `%s`", key)
+	}
+
+	return val, true, nil
+}
+
+// SetWebFetchExtraction caches a web_fetch extraction under its cache key for ttl.
+func (db *DB) SetWebFetchExtraction(ctx context.Context, cacheKey, val string, ttl time.Duration) error {
+	key := KeyPrefixWebFetchExtraction + cacheKey
+	if err := db.db.SetItem(ctx, key, val, ttl); err != nil {
+		return errors.Wrapf(err, "failed to cache extraction by key `%s`", key)
+	}
+
+	return nil
+}
@@ -17,4 +17,9 @@ const (
 	//
 	//  `KeyPrefixTaskHTMLCrawlerResult + <task_id>`
 	KeyPrefixTaskHTMLCrawlerResult = keyPrefixTask + "html_crawler/result/"
+
+	// KeyPrefixWebFetchExtraction is the key prefix for cached web_fetch extractions
+	//
+	//  `KeyPrefixWebFetchExtraction + <hash of url and extraction spec>`
+	KeyPrefixWebFetchExtraction = keyPrefix + "cache/web_fetch/extract/"
 )
@@ -10,12 +10,16 @@ type SearchResult struct {
 	EngineName string             `json:"engine_name"`
 	EngineType string             `json:"engine_type"`
 	Results    []SearchResultItem `json:"results"`
+	// Engines lists the engines that contributed results to a fan-out search.
+	Engines []string `json:"engines,omitempty"`
 }
 
 // SimplifiedSearchResult is a minimal response for MCP tools.
 // It only contains the essential results without auxiliary metadata.
 type SimplifiedSearchResult struct {
 	Results []SearchResultItem `json:"results"`
+	// Engines lists the engines that contributed results to a fan-out search.
+	Engines []string `json:"engines,omitempty"`
 }
 
 // SearchResultItem captures a single entry returned by a search provider.
@@ -23,4 +27,6 @@ type SearchResultItem struct {
 	URL     string `json:"url"`
 	Name    string `json:"name"`
 	Snippet string `json:"snippet"`
+	// Engines lists the engines that returned this item in a fan-out search.
+	Engines []string `json:"engines,omitempty"`
 }
@@ -0,0 +1,553 @@
+package search
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Laisky/errors/v2"
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	// MaxExtractSelectors caps the selectors accepted by one extraction spec.
+	MaxExtractSelectors = 50
+	// maxExtractSchemaDepth caps how deeply extraction schemas may nest.
+	maxExtractSchemaDepth = 8
+	// maxExtractValues caps the values returned for one selector or array property.
+	maxExtractValues = 200
+	// maxPageLinks caps the outbound links reported in page metadata.
+	maxPageLinks = 500
+
+	// ExtractSelectorKey is the schema keyword naming the CSS or XPath selector of a property.
+	ExtractSelectorKey = "x-selector"
+)
+
+// ExtractSpec describes which structured data to pull out of a page.
+// Exactly one of Selectors or Schema is set.
+type ExtractSpec struct {
+	// Selectors are CSS or XPath selectors whose matches are returned verbatim.
+	Selectors []string `json:"selectors,omitempty"`
+	// Schema is a JSON schema whose properties carry an x-selector keyword.
+	Schema map[string]any `json:"schema,omitempty"`
+}
+
+// PageLink is one outbound link of a page.
+type PageLink struct {
+	URL  string `json:"url"`
+	Text string `json:"text,omitempty"`
+	// External is true when the link points to another host.
+	External bool `json:"external"`
+}
+
+// PageMetadata summarizes a page independently of the extraction spec.
+type PageMetadata struct {
+	Title        string            `json:"title,omitempty"`
+	CanonicalURL string            `json:"canonical_url,omitempty"`
+	Description  string            `json:"description,omitempty"`
+	OpenGraph    map[string]string `json:"open_graph,omitempty"`
+	PublishedAt  string            `json:"published_at,omitempty"`
+	Links        []PageLink        `json:"links,omitempty"`
+}
+
+// ExtractResult is the structured data and metadata extracted from a page.
+type ExtractResult struct {
+	URL      string       `json:"url"`
+	Metadata PageMetadata `json:"metadata"`
+	// Data maps each selector to its matches, or holds the object described by the schema.
+	Data any `json:"data"`
+}
+
+// ParseExtractSpec builds an ExtractSpec from a tool argument.
+//
+// raw may be a list of selectors, a JSON schema object, an object with a "selectors"
+// or "schema" field, or a string holding any of these as JSON.
+func ParseExtractSpec(raw any) (*ExtractSpec, error) {
+	if text, ok := raw.(string); ok {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			return nil, errors.New("extract spec cannot be empty")
+		}
+		if err := json.Unmarshal([]byte(text), &raw); err != nil {
+			return nil, errors.Wrap(err, "extract spec must be a JSON schema or a list of selectors")
+		}
+	}
+
+	spec := new(ExtractSpec)
+	switch value := raw.(type) {
+	case []any:
+		for _, item := range value {
+			selector, ok := item.(string)
+			if !ok || strings.TrimSpace(selector) == "" {
+				return nil, errors.New("extract selectors must be non-empty strings")
+			}
+			spec.Selectors = append(spec.Selectors, strings.TrimSpace(selector))
+		}
+	case map[string]any:
+		if selectors, ok := value["selectors"]; ok {
+			return ParseExtractSpec(selectors)
+		}
+		if schema, ok := value["schema"].(map[string]any); ok {
+			spec.Schema = schema
+		} else {
+			spec.Schema = value
+		}
+	default:
+		return nil, errors.New("extract spec must be a JSON schema or a list of selectors")
+	}
+
+	if err := spec.validate(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return spec, nil
+}
+
+// validate checks selector syntax and schema shape before any page is fetched.
+func (s *ExtractSpec) validate() error {
+	if len(s.Selectors) == 0 && s.Schema == nil {
+		return errors.New("extract spec requires selectors or a schema")
+	}
+	if len(s.Selectors) > MaxExtractSelectors {
+		return errors.Errorf("extract spec accepts at most %d selectors", MaxExtractSelectors)
+	}
+	for _, selector := range s.Selectors {
+		if _, err := parseSelector(selector); err != nil {
+			return errors.Wrapf(err, "selector %q", selector)
+		}
+	}
+	if s.Schema != nil {
+		if err := validateExtractSchema(s.Schema, "$", 0); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// validateExtractSchema checks one schema node and its children.
+func validateExtractSchema(schema map[string]any, path string, depth int) error {
+	if depth > maxExtractSchemaDepth {
+		return errors.Errorf("%s: schema nests deeper than %d levels", path, maxExtractSchemaDepth)
+	}
+	if raw, ok := schema[ExtractSelectorKey]; ok {
+		selector, isString := raw.(string)
+		if !isString {
+			return errors.Errorf("%s: %s must be a string", path, ExtractSelectorKey)
+		}
+		if _, err := parseSelector(selector); err != nil {
+			return errors.Wrapf(err, "%s: selector %q", path, selector)
+		}
+	}
+
+	switch schemaType(schema) {
+	case "object":
+		properties, _ := schema["properties"].(map[string]any)
+		if len(properties) == 0 {
+			return errors.Errorf("%s: object schema requires properties", path)
+		}
+		for name, raw := range properties {
+			child, ok := raw.(map[string]any)
+			if !ok {
+				return errors.Errorf("%s.%s: property schema must be an object", path, name)
+			}
+			if err := validateExtractSchema(child, path+"."+name, depth+1); err != nil {
+				return err
+			}
+		}
+	case "array":
+		items, ok := schema["items"].(map[string]any)
+		if !ok {
+			items = map[string]any{"type": "string"}
+		}
+		if _, ok := schema[ExtractSelectorKey]; !ok {
+			return errors.Errorf("%s: array schema requires %s", path, ExtractSelectorKey)
+		}
+		return validateExtractSchema(items, path+"[]", depth+1)
+	case "string", "number", "integer", "boolean":
+	default:
+		return errors.Errorf("%s: unsupported schema type %q", path, schemaType(schema))
+	}
+	return nil
+}
+
+// CacheKey identifies the extraction of spec from pageURL.
+// Equal specs produce equal keys regardless of map ordering.
+func (s *ExtractSpec) CacheKey(pageURL string) (string, error) {
+	encoded, err := json.Marshal(s)
+	if err != nil {
+		return "", errors.Wrap(err, "encode extract spec")
+	}
+	sum := sha256.Sum256(append([]byte(strings.TrimSpace(pageURL)+"\n"), encoded...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ExtractStructuredData applies spec to the HTML of pageURL.
+func ExtractStructuredData(pageURL string, html []byte, spec *ExtractSpec) (*ExtractResult, error) {
+	if spec == nil {
+		return nil, errors.New("extract spec is required")
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(html))
+	if err != nil {
+		return nil, errors.Wrap(err, "parse html")
+	}
+	base, _ := url.Parse(strings.TrimSpace(pageURL))
+
+	result := &ExtractResult{
+		URL:      pageURL,
+		Metadata: extractPageMetadata(doc, base),
+	}
+	if spec.Schema != nil {
+		result.Data = extractSchemaValue(doc.Selection, spec.Schema)
+		return result, nil
+	}
+
+	data := make(map[string][]string, len(spec.Selectors))
+	for _, raw := range spec.Selectors {
+		selector, err := parseSelector(raw)
+		if err != nil {
+			return nil, errors.Wrapf(err, "selector %q", raw)
+		}
+		values := selector.values(doc.Selection)
+		if len(values) > maxExtractValues {
+			values = values[:maxExtractValues]
+		}
+		data[raw] = values
+	}
+	result.Data = data
+	return result, nil
+}
+
+// schemaType returns the declared type of a schema node, inferring object and array.
+func schemaType(schema map[string]any) string {
+	switch value := schema["type"].(type) {
+	case string:
+		return value
+	case []any:
+		// ["string", "null"] style unions pick their first non-null type.
+		for _, item := range value {
+			if name, ok := item.(string); ok && name != "null" {
+				return name
+			}
+		}
+	}
+	if _, ok := schema["properties"]; ok {
+		return "object"
+	}
+	if _, ok := schema["items"]; ok {
+		return "array"
+	}
+	return "string"
+}
+
+// extractSchemaValue evaluates a schema node within ctx.
+// Scalars without a match are nil so that callers can tell missing from empty.
+func extractSchemaValue(ctx *goquery.Selection, schema map[string]any) any {
+	var selector *extractSelector
+	if raw, ok := schema[ExtractSelectorKey].(string); ok {
+		selector, _ = parseSelector(raw)
+	}
+
+	switch schemaType(schema) {
+	case "object":
+		scope := ctx
+		if selector != nil {
+			scope = selector.find(ctx).First()
+			if scope.Length() == 0 {
+				return nil
+			}
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		out := make(map[string]any, len(properties))
+		for name, raw := range properties {
+			child, _ := raw.(map[string]any)
+			out[name] = extractSchemaValue(scope, child)
+		}
+		return out
+	case "array":
+		items, ok := schema["items"].(map[string]any)
+		if !ok {
+			items = map[string]any{"type": "string"}
+		}
+		out := make([]any, 0)
+		if selector == nil {
+			return out
+		}
+		itemType := schemaType(items)
+		if itemType == "object" || itemType == "array" {
+			selector.find(ctx).EachWithBreak(func(_ int, item *goquery.Selection) bool {
+				out = append(out, extractSchemaValue(item, items))
+				return len(out) < maxExtractValues
+			})
+			return out
+		}
+		for _, value := range selector.values(ctx) {
+			if coerced := coerceExtractValue(value, itemType); coerced != nil {
+				out = append(out, coerced)
+			}
+			if len(out) == maxExtractValues {
+				break
+			}
+		}
+		return out
+	default:
+		if selector == nil {
+			return nil
+		}
+		values := selector.values(ctx)
+		if len(values) == 0 {
+			return nil
+		}
+		return coerceExtractValue(values[0], schemaType(schema))
+	}
+}
+
+// numberPattern finds the first number in text such as "$1,299.00".
+var numberPattern = regexp.MustCompile(`-?\d[\d,]*(?:\.\d+)?`)
+
+// coerceExtractValue converts extracted text to a JSON schema scalar type.
+// It returns nil when the text does not represent the type.
+func coerceExtractValue(value, typ string) any {
+	switch typ {
+	case "number", "integer":
+		match := numberPattern.FindString(value)
+		if match == "" {
+			return nil
+		}
+		number, err := strconv.ParseFloat(strings.ReplaceAll(match, ",", ""), 64)
+		if err != nil {
+			return nil
+		}
+		if typ == "integer" {
+			return int64(number)
+		}
+		return number
+	case "boolean":
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "", "false", "0", "no", "off":
+			return false
+		default:
+			return true
+		}
+	default:
+		return value
+	}
+}
+
+// extractPageMetadata reads the title, canonical URL, OpenGraph tags, publish date and links.
+func extractPageMetadata(doc *goquery.Document, base *url.URL) PageMetadata {
+	meta := PageMetadata{
+		Title:     collapseSpace(doc.Find("head title").First().Text()),
+		OpenGraph: map[string]string{},
+	}
+
+	doc.Find("meta").Each(func(_ int, node *goquery.Selection) {
+		key, _ := node.Attr("property")
+		if key == "" {
+			key, _ = node.Attr("name")
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		content := strings.TrimSpace(node.AttrOr("content", ""))
+		if key == "" || content == "" {
+			return
+		}
+		switch {
+		case strings.HasPrefix(key, "og:"):
+			if _, exists := meta.OpenGraph[key]; !exists {
+				meta.OpenGraph[key] = content
+			}
+		case key == "description" && meta.Description == "":
+			meta.Description = content
+		}
+		if meta.PublishedAt == "" && isPublishDateMeta(key) {
+			meta.PublishedAt = normalizePublishDate(content)
+		}
+	})
+	if len(meta.OpenGraph) == 0 {
+		meta.OpenGraph = nil
+	}
+	if meta.Title == "" {
+		meta.Title = meta.OpenGraph["og:title"]
+	}
+	if meta.Description == "" {
+		meta.Description = meta.OpenGraph["og:description"]
+	}
+
+	if href, ok := doc.Find(`link[rel="canonical"]`).First().Attr("href"); ok {
+		meta.CanonicalURL = resolveLink(base, href)
+	}
+	if meta.CanonicalURL == "" && meta.OpenGraph["og:url"] != "" {
+		meta.CanonicalURL = resolveLink(base, meta.OpenGraph["og:url"])
+	}
+	if meta.CanonicalURL == "" && base != nil {
+		meta.CanonicalURL = base.String()
+	}
+
+	if meta.PublishedAt == "" {
+		meta.PublishedAt = publishDateFromDocument(doc)
+	}
+	meta.Links = extractPageLinks(doc, base)
+	return meta
+}
+
+// publishDateMetaKeys are meta property or name values that carry a publish date.
+var publishDateMetaKeys = map[string]struct{}{
+	"article:published_time": {},
+	"og:published_time":      {},
+	"date":                   {},
+	"pubdate":                {},
+	"publishdate":            {},
+	"publish-date":           {},
+	"publish_date":           {},
+	"dc.date":                {},
+	"dc.date.issued":         {},
+	"dcterms.created":        {},
+	"sailthru.date":          {},
+	"parsely-pub-date":       {},
+}
+
+// isPublishDateMeta reports whether a meta key carries a publish date.
+func isPublishDateMeta(key string) bool {
+	_, ok := publishDateMetaKeys[key]
+	return ok
+}
+
+// publishDateFromDocument looks for a publish date outside meta tags:
+// itemprop microdata, JSON-LD and the first <time datetime>.
+func publishDateFromDocument(doc *goquery.Document) string {
+	if value, ok := doc.Find(`[itemprop="datePublished"]`).First().Attr("content"); ok && value != "" {
+		return normalizePublishDate(value)
+	}
+	if value, ok := doc.Find(`[itemprop="datePublished"]`).First().Attr("datetime"); ok && value != "" {
+		return normalizePublishDate(value)
+	}
+
+	var published string
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(_ int, node *goquery.Selection) bool {
+		published = jsonLDPublishDate(node.Text())
+		return published == ""
+	})
+	if published != "" {
+		return normalizePublishDate(published)
+	}
+
+	if value, ok := doc.Find("time[datetime]").First().Attr("datetime"); ok {
+		return normalizePublishDate(value)
+	}
+	return ""
+}
+
+// jsonLDPublishDate returns the first datePublished found in a JSON-LD block.
+func jsonLDPublishDate(raw string) string {
+	var payload any
+	if err := json.Unmarshal([]byte(raw), &payload); err != nil {
+		return ""
+	}
+
+	var walk func(node any) string
+	walk = func(node any) string {
+		switch value := node.(type) {
+		case map[string]any:
+			if date, ok := value["datePublished"].(string); ok && date != "" {
+				return date
+			}
+			for _, child := range value {
+				if date := walk(child); date != "" {
+					return date
+				}
+			}
+		case []any:
+			for _, child := range value {
+				if date := walk(child); date != "" {
+					return date
+				}
+			}
+		}
+		return ""
+	}
+	return walk(payload)
+}
+
+// publishDateLayouts are the date formats normalized to RFC 3339.
+var publishDateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// normalizePublishDate converts recognized dates to RFC 3339 and keeps others verbatim.
+func normalizePublishDate(value string) string {
+	value = strings.TrimSpace(value)
+	for _, layout := range publishDateLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed.UTC().Format(time.RFC3339)
+		}
+	}
+	return value
+}
+
+// ExtractPageLinks returns the unique http(s) links of the HTML page at pageURL.
+func ExtractPageLinks(pageURL string, html []byte) ([]PageLink, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(html))
+	if err != nil {
+		return nil, errors.Wrap(err, "parse html")
+	}
+	base, _ := url.Parse(strings.TrimSpace(pageURL))
+	return extractPageLinks(doc, base), nil
+}
+
+// extractPageLinks returns the page's unique http(s) links, resolved against base.
+func extractPageLinks(doc *goquery.Document, base *url.URL) []PageLink {
+	var links []PageLink
+	seen := make(map[string]struct{})
+	if base != nil {
+		// Anchors back to the page itself are not outbound links.
+		page := *base
+		page.Fragment = ""
+		seen[page.String()] = struct{}{}
+	}
+	doc.Find("a[href]").EachWithBreak(func(_ int, node *goquery.Selection) bool {
+		resolved := resolveLink(base, node.AttrOr("href", ""))
+		parsed, err := url.Parse(resolved)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return true
+		}
+		parsed.Fragment = ""
+		resolved = parsed.String()
+		if _, dup := seen[resolved]; dup {
+			return true
+		}
+		seen[resolved] = struct{}{}
+
+		links = append(links, PageLink{
+			URL:      resolved,
+			Text:     collapseSpace(node.Text()),
+			External: base == nil || !strings.EqualFold(parsed.Hostname(), base.Hostname()),
+		})
+		return len(links) < maxPageLinks
+	})
+	return links
+}
+
+// resolveLink resolves href against base, returning href unchanged when either cannot be parsed.
+func resolveLink(base *url.URL, href string) string {
+	href = strings.TrimSpace(href)
+	ref, err := url.Parse(href)
+	if err != nil || base == nil {
+		return href
+	}
+	return base.ResolveReference(ref).String()
+}
+
+// collapseSpace trims text and collapses runs of whitespace into single spaces.
+func collapseSpace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,254 @@
+package search
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Laisky/errors/v2"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+// extractSelector locates nodes and reads their value.
+//
+// CSS selectors may end with ::text or ::attr(name). XPath selectors support the
+// subset agents use in practice: absolute, relative and descendant paths over element
+// names or *, predicates [n], [last()], [@a], [@a='v'], [contains(@a,'v')],
+// [starts-with(@a,'v')], [contains(text(),'v')] joined by "and", and a final
+// /@name or /text() step.
+type extractSelector struct {
+	// steps are applied in order; CSS selectors have a single descendant step.
+	steps []selectorStep
+	// absolute paths start from the document root instead of the context node.
+	absolute bool
+	// attr selects an attribute value instead of the text content.
+	attr string
+}
+
+// selectorStep matches css among the children or the descendants of the current nodes.
+type selectorStep struct {
+	descendant bool
+	css        string
+}
+
+var (
+	cssAttrSuffix      = regexp.MustCompile(`::attr\(\s*([^)\s]+)\s*\)$`)
+	xpathStepPattern   = regexp.MustCompile(`^(\*|[A-Za-z][\w.-]*)((?:\[[^\]]*\])*)$`)
+	xpathPredicate     = regexp.MustCompile(`\[([^\]]*)\]`)
+	xpathAttrEquals    = regexp.MustCompile(`^@([\w:.-]+)\s*=\s*(?:'([^']*)'|"([^"]*)")$`)
+	xpathAttrFunction  = regexp.MustCompile(`^(contains|starts-with)\(\s*@([\w:.-]+)\s*,\s*(?:'([^']*)'|"([^"]*)")\s*\)$`)
+	xpathTextContains  = regexp.MustCompile(`^contains\(\s*(?:text\(\)|\.)\s*,\s*(?:'([^']*)'|"([^"]*)")\s*\)$`)
+	xpathAttrName      = regexp.MustCompile(`^@([\w:.-]+)$`)
+	xpathPositionIndex = regexp.MustCompile(`^\d+$`)
+)
+
+// parseSelector parses a CSS or XPath selector.
+// Selectors starting with "/" or "./" or prefixed with "xpath:" are XPath; "css:" forces CSS.
+func parseSelector(raw string) (*extractSelector, error) {
+	selector := strings.TrimSpace(raw)
+	switch {
+	case selector == "":
+		return nil, errors.New("selector cannot be empty")
+	case strings.HasPrefix(selector, "xpath:"):
+		return parseXPathSelector(strings.TrimSpace(strings.TrimPrefix(selector, "xpath:")))
+	case strings.HasPrefix(selector, "css:"):
+		return parseCSSSelector(strings.TrimSpace(strings.TrimPrefix(selector, "css:")))
+	case strings.HasPrefix(selector, "/"), strings.HasPrefix(selector, "./"):
+		return parseXPathSelector(selector)
+	default:
+		return parseCSSSelector(selector)
+	}
+}
+
+// parseCSSSelector parses a CSS selector with an optional ::text or ::attr(name) suffix.
+func parseCSSSelector(selector string) (*extractSelector, error) {
+	parsed := new(extractSelector)
+	if match := cssAttrSuffix.FindStringSubmatch(selector); match != nil {
+		parsed.attr = match[1]
+		selector = strings.TrimSpace(strings.TrimSuffix(selector, match[0]))
+	} else {
+		selector = strings.TrimSpace(strings.TrimSuffix(selector, "::text"))
+	}
+	if _, err := cascadia.Compile(selector); err != nil {
+		return nil, errors.Wrap(err, "invalid css selector")
+	}
+	parsed.steps = []selectorStep{{descendant: true, css: selector}}
+	return parsed, nil
+}
+
+// parseXPathSelector translates the supported XPath subset into CSS steps.
+func parseXPathSelector(path string) (*extractSelector, error) {
+	parsed := new(extractSelector)
+	descendant := false
+	switch {
+	case strings.HasPrefix(path, ".//"):
+		path, descendant = path[3:], true
+	case strings.HasPrefix(path, "./"):
+		path = path[2:]
+	case strings.HasPrefix(path, "//"):
+		path, descendant = path[2:], true
+	case strings.HasPrefix(path, "/"):
+		path, parsed.absolute = path[1:], true
+	}
+
+	segments := splitXPath(path)
+	if len(segments) == 0 || segments[0] == "" {
+		return nil, errors.New("invalid xpath: missing element step")
+	}
+	for idx, segment := range segments {
+		last := idx == len(segments)-1
+		switch {
+		case segment == "":
+			// An empty segment comes from "//": the next step searches descendants.
+			descendant = true
+			continue
+		case last && segment == "text()":
+			return parsed, nil
+		case last && xpathAttrName.MatchString(segment):
+			parsed.attr = xpathAttrName.FindStringSubmatch(segment)[1]
+			return parsed, nil
+		}
+
+		css, err := xpathStepToCSS(segment)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		parsed.steps = append(parsed.steps, selectorStep{descendant: descendant, css: css})
+		descendant = false
+	}
+	if descendant {
+		return nil, errors.New("invalid xpath: trailing //")
+	}
+	return parsed, nil
+}
+
+// splitXPath splits a path on "/" outside predicates and quotes.
+func splitXPath(path string) []string {
+	var (
+		segments []string
+		current  strings.Builder
+		depth    int
+		quote    rune
+	)
+	for _, r := range path {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '[':
+			depth++
+		case r == ']':
+			depth--
+		case r == '/' && depth == 0:
+			segments = append(segments, strings.TrimSpace(current.String()))
+			current.Reset()
+			continue
+		}
+		current.WriteRune(r)
+	}
+	return append(segments, strings.TrimSpace(current.String()))
+}
+
+// xpathStepToCSS translates one XPath step such as div[@class='x'][2] into CSS.
+func xpathStepToCSS(step string) (string, error) {
+	match := xpathStepPattern.FindStringSubmatch(step)
+	if match == nil {
+		return "", errors.Errorf("unsupported xpath step %q", step)
+	}
+
+	var css strings.Builder
+	css.WriteString(match[1])
+	for _, predicate := range xpathPredicate.FindAllStringSubmatch(match[2], -1) {
+		for _, clause := range strings.Split(predicate[1], " and ") {
+			translated, err := xpathPredicateToCSS(strings.TrimSpace(clause))
+			if err != nil {
+				return "", errors.Wrapf(err, "xpath step %q", step)
+			}
+			css.WriteString(translated)
+		}
+	}
+	if _, err := cascadia.Compile(css.String()); err != nil {
+		return "", errors.Wrapf(err, "unsupported xpath step %q", step)
+	}
+	return css.String(), nil
+}
+
+// xpathPredicateToCSS translates one predicate clause into a CSS attribute or pseudo-class.
+func xpathPredicateToCSS(clause string) (string, error) {
+	switch {
+	case xpathPositionIndex.MatchString(clause):
+		return ":nth-of-type(" + clause + ")", nil
+	case clause == "last()":
+		return ":last-of-type", nil
+	case xpathAttrName.MatchString(clause):
+		return "[" + xpathAttrName.FindStringSubmatch(clause)[1] + "]", nil
+	case xpathAttrEquals.MatchString(clause):
+		match := xpathAttrEquals.FindStringSubmatch(clause)
+		return fmt.Sprintf("[%s=%q]", match[1], match[2]+match[3]), nil
+	case xpathAttrFunction.MatchString(clause):
+		match := xpathAttrFunction.FindStringSubmatch(clause)
+		operator := "*="
+		if match[1] == "starts-with" {
+			operator = "^="
+		}
+		return fmt.Sprintf("[%s%s%q]", match[2], operator, match[3]+match[4]), nil
+	case xpathTextContains.MatchString(clause):
+		match := xpathTextContains.FindStringSubmatch(clause)
+		return fmt.Sprintf(":contains(%q)", match[1]+match[2]), nil
+	default:
+		return "", errors.Errorf("unsupported xpath predicate [%s]", clause)
+	}
+}
+
+// find returns the nodes matched within ctx.
+func (s *extractSelector) find(ctx *goquery.Selection) *goquery.Selection {
+	current := ctx
+	if s.absolute {
+		current = documentRoot(ctx)
+	}
+	for _, step := range s.steps {
+		if step.descendant {
+			current = current.Find(step.css)
+		} else {
+			current = current.ChildrenFiltered(step.css)
+		}
+	}
+	return current
+}
+
+// values returns the attribute or collapsed text of every match, skipping empty values.
+func (s *extractSelector) values(ctx *goquery.Selection) []string {
+	var values []string
+	s.find(ctx).Each(func(_ int, node *goquery.Selection) {
+		value := ""
+		if s.attr != "" {
+			value = strings.TrimSpace(node.AttrOr(s.attr, ""))
+		} else {
+			value = collapseSpace(node.Text())
+		}
+		if value != "" {
+			values = append(values, value)
+		}
+	})
+	return values
+}
+
+// documentRoot returns the document node that contains ctx.
+func documentRoot(ctx *goquery.Selection) *goquery.Selection {
+	if ctx.Length() == 0 {
+		return ctx
+	}
+	node := ctx.Get(0)
+	for node.Parent != nil {
+		node = node.Parent
+	}
+	if node.Type != html.DocumentNode {
+		return ctx
+	}
+	return goquery.NewDocumentFromNode(node).Selection
+}
@@ -0,0 +1,135 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const extractTestPage = `<!doctype html>
+<html>
+<head>
+  <title> Widget   Review </title>
+  <link rel="canonical" href="/reviews/widget">
+  <meta property="og:title" content="Widget">
+  <meta property="og:image" content="https://cdn.example.com/widget.png">
+  <meta name="description" content="A widget review.">
+  <meta property="article:published_time" content="2025-03-04T05:06:07+08:00">
+</head>
+<body>
+  <h1>Widget</h1>
+  <span class="price">$1,299.50</span>
+  <ul id="specs">
+    <li class="spec"><b>Weight</b><i>2kg</i></li>
+    <li class="spec"><b>Color</b><i>Red</i></li>
+  </ul>
+  <a href="/about">About</a>
+  <a href="https://other.example.org/page#x">Elsewhere</a>
+  <a href="https://other.example.org/page">Duplicate</a>
+  <a href="#top">Top</a>
+  <a href="mailto:a@example.com">Mail</a>
+</body>
+</html>`
+
+func TestExtractStructuredDataSelectors(t *testing.T) {
+	spec, err := ParseExtractSpec([]any{"h1", ".price", "//li[2]/i/text()", "//a[contains(@href,'other')]/@href", "a::attr(href)"})
+	require.NoError(t, err)
+
+	result, err := ExtractStructuredData("https://www.example.com/reviews/widget?ref=1", []byte(extractTestPage), spec)
+	require.NoError(t, err)
+
+	data := result.Data.(map[string][]string)
+	require.Equal(t, []string{"Widget"}, data["h1"])
+	require.Equal(t, []string{"$1,299.50"}, data[".price"])
+	require.Equal(t, []string{"Red"}, data["//li[2]/i/text()"])
+	require.Equal(t, []string{"https://other.example.org/page#x", "https://other.example.org/page"}, data["//a[contains(@href,'other')]/@href"])
+	require.Len(t, data["a::attr(href)"], 5)
+
+	meta := result.Metadata
+	require.Equal(t, "Widget Review", meta.Title)
+	require.Equal(t, "https://www.example.com/reviews/widget", meta.CanonicalURL)
+	require.Equal(t, "A widget review.", meta.Description)
+	require.Equal(t, "https://cdn.example.com/widget.png", meta.OpenGraph["og:image"])
+	require.Equal(t, "2025-03-03T21:06:07Z", meta.PublishedAt)
+	require.Equal(t, []PageLink{
+		{URL: "https://www.example.com/about", Text: "About"},
+		{URL: "https://other.example.org/page", Text: "Elsewhere", External: true},
+	}, meta.Links)
+}
+
+func TestExtractStructuredDataSchema(t *testing.T) {
+	spec, err := ParseExtractSpec(`{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string", "x-selector": "h1"},
+			"price": {"type": "number", "x-selector": ".price"},
+			"missing": {"type": "string", "x-selector": ".absent"},
+			"specs": {
+				"type": "array",
+				"x-selector": "/html/body/ul[@id='specs']/li",
+				"items": {
+					"type": "object",
+					"properties": {
+						"label": {"type": "string", "x-selector": "./b"},
+						"value": {"type": "string", "x-selector": "i"}
+					}
+				}
+			}
+		}
+	}`)
+	require.NoError(t, err)
+
+	result, err := ExtractStructuredData("https://example.com/widget", []byte(extractTestPage), spec)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{
+		"name":    "Widget",
+		"price":   1299.5,
+		"missing": nil,
+		"specs": []any{
+			map[string]any{"label": "Weight", "value": "2kg"},
+			map[string]any{"label": "Color", "value": "Red"},
+		},
+	}, result.Data)
+}
+
+func TestParseExtractSpecRejectsInvalidSpecs(t *testing.T) {
+	for _, raw := range []any{
+		"",
+		42,
+		[]any{},
+		[]any{"div[["},
+		[]any{"//div[position()>1]"},
+		map[string]any{"type": "object"},
+		map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		map[string]any{"type": "object", "properties": map[string]any{"a": map[string]any{"type": "date"}}},
+	} {
+		_, err := ParseExtractSpec(raw)
+		require.Error(t, err, "%v", raw)
+	}
+}
+
+func TestExtractSpecCacheKey(t *testing.T) {
+	first, err := ParseExtractSpec(map[string]any{"type": "object", "properties": map[string]any{
+		"a": map[string]any{"x-selector": "h1"},
+		"b": map[string]any{"x-selector": "h2"},
+	}})
+	require.NoError(t, err)
+	second, err := ParseExtractSpec(`{"properties":{"b":{"x-selector":"h2"},"a":{"x-selector":"h1"}},"type":"object"}`)
+	require.NoError(t, err)
+
+	firstKey, err := first.CacheKey("https://example.com")
+	require.NoError(t, err)
+	secondKey, err := second.CacheKey("https://example.com")
+	require.NoError(t, err)
+	require.Equal(t, firstKey, secondKey)
+
+	otherURL, err := first.CacheKey("https://example.org")
+	require.NoError(t, err)
+	require.NotEqual(t, firstKey, otherURL)
+
+	selectors, err := ParseExtractSpec([]any{"h1"})
+	require.NoError(t, err)
+	selectorKey, err := selectors.CacheKey("https://example.com")
+	require.NoError(t, err)
+	require.NotEqual(t, firstKey, selectorKey)
+}
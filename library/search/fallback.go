@@ -0,0 +1,79 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Laisky/errors/v2"
+	"github.com/PuerkitoBio/goquery"
+)
+
+// PlainHTTPFetcher downloads pageURL without rendering it and returns the body
+// with its declared content type. Implementations are expected to guard against SSRF.
+type PlainHTTPFetcher func(ctx context.Context, pageURL string) (body []byte, contentType string, err error)
+
+// RenderPageWithoutBrowser fetches pageURL with fetch and renders it in-process.
+//
+// It is the fallback for FetchDynamicURLContent when the crawler is unavailable, so
+// scripts are not executed and pages built client-side may come back sparse. Bodies
+// that are not HTML are returned unchanged. HTML is returned as fetched unless
+// outputMarkdown is true, in which case the main content is located with readability
+// heuristics and converted to Markdown under the page title.
+func RenderPageWithoutBrowser(ctx context.Context, fetch PlainHTTPFetcher, pageURL string, outputMarkdown bool) ([]byte, error) {
+	if fetch == nil {
+		return nil, errors.New("plain http fetcher is required")
+	}
+	body, contentType, err := fetch(ctx, pageURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetch page")
+	}
+	if !outputMarkdown || !isHTMLContent(body, contentType) {
+		return body, nil
+	}
+
+	markdown, err := HTMLToMarkdown(pageURL, body)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return []byte(markdown), nil
+}
+
+// HTMLToMarkdown converts the main content of the HTML page at pageURL to Markdown.
+// The main content is located with readability heuristics and placed under the page title.
+func HTMLToMarkdown(pageURL string, body []byte) (string, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return "", errors.Wrap(err, "parse html")
+	}
+	base, _ := url.Parse(strings.TrimSpace(pageURL))
+	title := collapseSpace(doc.Find(`head meta[property="og:title"]`).AttrOr("content", ""))
+	if title == "" {
+		title = collapseSpace(doc.Find("head title").First().Text())
+	}
+
+	main := extractMainContent(doc)
+	if main.Length() == 0 {
+		return title, nil
+	}
+	markdown := htmlToMarkdown(base, main.Get(0))
+	if title != "" && !strings.HasPrefix(markdown, "# ") {
+		markdown = strings.TrimSpace("# " + title + "\n\n" + markdown)
+	}
+	return markdown, nil
+}
+
+// isHTMLContent reports whether body is HTML, trusting contentType when it is set.
+func isHTMLContent(body []byte, contentType string) bool {
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
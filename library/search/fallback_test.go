@@ -0,0 +1,73 @@
+package search
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Laisky/errors/v2"
+	"github.com/stretchr/testify/require"
+)
+
+const fallbackTestPage = `<html><head><title>Release notes</title></head><body>
+<nav><a href="/">Home</a> <a href="/blog">Blog</a></nav>
+<div class="sidebar"><p>Subscribe to our newsletter, it is short, useful and free.</p></div>
+<div id="content" class="post">
+  <h1>Release notes</h1>
+  <p>This release ships <strong>faster builds</strong>, an <em>improved</em> cache, and a <a href="/docs">new guide</a> for upgrades.</p>
+  <pre><code class="language-go">func main() {
+	run()
+}</code></pre>
+  <ul><li>Fixed crashes</li><li>New flags<ul><li>--fast</li></ul></li></ul>
+  <ol><li>Download</li><li>Install</li></ol>
+  <blockquote><p>Upgrade soon.</p></blockquote>
+  <table><tr><th>Name</th><th>Value</th></tr><tr><td>a|b</td><td>1</td></tr></table>
+  <img src="/shot.png" alt="Screenshot">
+</div>
+<footer>Copyright</footer>
+</body></html>`
+
+func TestRenderPageWithoutBrowserMarkdown(t *testing.T) {
+	fetch := func(_ context.Context, pageURL string) ([]byte, string, error) {
+		require.Equal(t, "https://example.com/blog/release", pageURL)
+		return []byte(fallbackTestPage), "text/html; charset=utf-8", nil
+	}
+
+	content, err := RenderPageWithoutBrowser(context.Background(), fetch, "https://example.com/blog/release", true)
+	require.NoError(t, err)
+	require.Equal(t, "# Release notes\n\n"+
+		"This release ships **faster builds**, an _improved_ cache, and a [new guide](https://example.com/docs) for upgrades.\n\n"+
+		"```go\nfunc main() {\n\trun()\n}\n```\n\n"+
+		"- Fixed crashes\n- New flags\n  - --fast\n\n"+
+		"1. Download\n2. Install\n\n"+
+		"> Upgrade soon.\n\n"+
+		"| Name | Value |\n| --- | --- |\n| a\\|b | 1 |\n\n"+
+		"![Screenshot](https://example.com/shot.png)", string(content))
+}
+
+func TestRenderPageWithoutBrowserPassthrough(t *testing.T) {
+	page := []byte(fallbackTestPage)
+	content, err := RenderPageWithoutBrowser(context.Background(), func(context.Context, string) ([]byte, string, error) {
+		return page, "text/html", nil
+	}, "https://example.com", false)
+	require.NoError(t, err)
+	require.Equal(t, page, content)
+
+	content, err = RenderPageWithoutBrowser(context.Background(), func(context.Context, string) ([]byte, string, error) {
+		return []byte(`{"a":1}`), "application/json", nil
+	}, "https://example.com/data.json", true)
+	require.NoError(t, err)
+	require.Equal(t, `{"a":1}`, string(content))
+
+	_, err = RenderPageWithoutBrowser(context.Background(), func(context.Context, string) ([]byte, string, error) {
+		return nil, "", errors.New("blocked")
+	}, "https://example.com", true)
+	require.ErrorContains(t, err, "blocked")
+}
+
+func TestRenderPageWithoutBrowserShortPageKeepsBody(t *testing.T) {
+	content, err := RenderPageWithoutBrowser(context.Background(), func(context.Context, string) ([]byte, string, error) {
+		return []byte(`<html><head><title>Tiny</title></head><body><p>Hello <b>world</b></p></body></html>`), "", nil
+	}, "https://example.com", true)
+	require.NoError(t, err)
+	require.Equal(t, "# Tiny\n\nHello **world**", string(content))
+}
@@ -0,0 +1,244 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Laisky/errors/v2"
+	gmw "github.com/Laisky/gin-middlewares/v7"
+	"github.com/Laisky/zap"
+)
+
+const (
+	// EngineTypeFanOut is reported as SearchOutput.EngineType for fused results.
+	EngineTypeFanOut = "fanout"
+	// DefaultFanOutEngines is how many engines a fan-out search queries when unspecified.
+	DefaultFanOutEngines = 3
+	// MaxFanOutEngines caps how many engines a single fan-out search may query.
+	MaxFanOutEngines = 4
+	// fanOutRRFK is the rank damping constant of reciprocal rank fusion.
+	fanOutRRFK = 60
+)
+
+// FanOutProvider is implemented by providers that can query several engines at once.
+type FanOutProvider interface {
+	// SearchFanOut queries up to maxEngines engines in parallel and fuses their results.
+	SearchFanOut(ctx context.Context, query string, maxEngines int) (*SearchOutput, error)
+}
+
+// ClampFanOutEngines normalizes a requested engine count into [1, MaxFanOutEngines].
+// Non-positive values select DefaultFanOutEngines.
+func ClampFanOutEngines(requested int) int {
+	switch {
+	case requested <= 0:
+		return DefaultFanOutEngines
+	case requested > MaxFanOutEngines:
+		return MaxFanOutEngines
+	default:
+		return requested
+	}
+}
+
+// fanOutResult is the outcome of one engine in a fan-out search.
+type fanOutResult struct {
+	engine Engine
+	items  []SearchResultItem
+	err    error
+}
+
+// SearchFanOut queries up to maxEngines engines of distinct types in parallel,
+// dedupes their items by canonical URL and orders them by reciprocal rank fusion.
+//
+// Engines are picked in tier and round-robin order, so the highest priority backends
+// are always part of the fan-out. Each engine is tried once; the search fails only
+// when every selected engine fails. The returned SearchOutput.Engines lists the engines
+// that contributed at least one item, and each item lists the engines that returned it.
+func (m *Manager) SearchFanOut(ctx context.Context, query string, maxEngines int) (*SearchOutput, error) {
+	trimmed := strings.TrimSpace(query)
+	if trimmed == "" {
+		return nil, errors.New("search query cannot be empty")
+	}
+
+	logger := m.logger
+	if ctx != nil {
+		if ctxLogger := gmw.GetLogger(ctx); ctxLogger != nil {
+			logger = ctxLogger.Named("search_manager")
+		}
+	}
+	if logger != nil {
+		logger = logger.With(zap.String("query", trimmed))
+	}
+
+	engines := m.fanOutEngines(ClampFanOutEngines(maxEngines))
+	results := make([]fanOutResult, len(engines))
+	var wg sync.WaitGroup
+	for idx, engine := range engines {
+		wg.Add(1)
+		go func(idx int, engine Engine) {
+			defer wg.Done()
+			items, err := engine.Search(ctx, trimmed)
+			results[idx] = fanOutResult{engine: engine, items: items, err: err}
+		}(idx, engine)
+	}
+	wg.Wait()
+
+	var (
+		succeeded      []fanOutResult
+		failureDetails []string
+	)
+	for _, result := range results {
+		if result.err != nil {
+			failureDetails = append(failureDetails, fmt.Sprintf("%s: %v", result.engine.Name(), result.err))
+			if logger != nil {
+				logger.Warn("fan-out search engine failed",
+					zap.String("engine", result.engine.Name()),
+					zap.Error(result.err),
+				)
+			}
+			continue
+		}
+		succeeded = append(succeeded, result)
+	}
+	if len(succeeded) == 0 {
+		return nil, errors.Errorf("fan-out search failed on all %d engine(s): %s",
+			len(engines), strings.Join(failureDetails, "; "))
+	}
+
+	items := fuseSearchResults(succeeded)
+	// Engines that answered without items add nothing to the fusion, so they are not
+	// reported as contributors and callers do not bill them.
+	contributors := make([]string, 0, len(succeeded))
+	for _, result := range succeeded {
+		if len(result.items) > 0 {
+			contributors = append(contributors, result.engine.Name())
+		}
+	}
+	engineName := strings.Join(contributors, ",")
+	if engineName == "" {
+		engineName = succeeded[0].engine.Name()
+	}
+	if logger != nil {
+		logger.Info("fan-out search succeeded",
+			zap.Strings("engines", contributors),
+			zap.Int("failed", len(failureDetails)),
+			zap.Int("results_count", len(items)),
+		)
+	}
+
+	return &SearchOutput{
+		Items:      items,
+		EngineName: engineName,
+		EngineType: EngineTypeFanOut,
+		Engines:    contributors,
+	}, nil
+}
+
+// fanOutEngines picks up to limit engines with distinct types in priority order.
+// Engines sharing a type hit the same backend, so querying more than one adds cost without recall.
+func (m *Manager) fanOutEngines(limit int) []Engine {
+	selected := make([]Engine, 0, limit)
+	seenTypes := make(map[string]struct{}, limit)
+	for tierIdx, tier := range m.tiers {
+		for _, engine := range m.roundRobinEngines(tierIdx, tier) {
+			if len(selected) >= limit {
+				return selected
+			}
+			if _, exists := seenTypes[engine.Type()]; exists {
+				continue
+			}
+			seenTypes[engine.Type()] = struct{}{}
+			selected = append(selected, engine)
+		}
+	}
+	return selected
+}
+
+// fusedItem accumulates the fused score of one canonical URL.
+type fusedItem struct {
+	item      SearchResultItem
+	score     float64
+	firstSeen int
+}
+
+// fuseSearchResults merges per-engine rankings with reciprocal rank fusion.
+// Items are deduped by canonical URL; the first engine to return a URL supplies its
+// name and snippet, and ties keep the order in which URLs were first seen.
+func fuseSearchResults(results []fanOutResult) []SearchResultItem {
+	byURL := make(map[string]*fusedItem)
+	var ordered []*fusedItem
+	for _, result := range results {
+		engineName := result.engine.Name()
+		seenInEngine := make(map[string]struct{}, len(result.items))
+		for rank, item := range result.items {
+			key := CanonicalURL(item.URL)
+			if key == "" {
+				continue
+			}
+			if _, dup := seenInEngine[key]; dup {
+				continue
+			}
+			seenInEngine[key] = struct{}{}
+
+			fused, ok := byURL[key]
+			if !ok {
+				fused = &fusedItem{item: item, firstSeen: len(ordered)}
+				fused.item.Engines = nil
+				byURL[key] = fused
+				ordered = append(ordered, fused)
+			}
+			if fused.item.Snippet == "" {
+				fused.item.Snippet = item.Snippet
+			}
+			fused.score += 1 / float64(fanOutRRFK+rank+1)
+			fused.item.Engines = append(fused.item.Engines, engineName)
+		}
+	}
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].score != ordered[j].score {
+			return ordered[i].score > ordered[j].score
+		}
+		return ordered[i].firstSeen < ordered[j].firstSeen
+	})
+
+	items := make([]SearchResultItem, 0, len(ordered))
+	for _, fused := range ordered {
+		items = append(items, fused.item)
+	}
+	return items
+}
+
+// CanonicalURL normalizes a result URL so that the same page returned by different
+// engines compares equal. It lowercases the scheme and host, drops a leading "www.",
+// default ports, fragments, trailing slashes and utm_* tracking parameters, and sorts
+// the query. Unparsable URLs are returned trimmed.
+func CanonicalURL(raw string) string {
+	trimmed := strings.TrimSpace(raw)
+	parsed, err := url.Parse(trimmed)
+	if err != nil || parsed.Host == "" {
+		return trimmed
+	}
+
+	scheme := strings.ToLower(parsed.Scheme)
+	host := strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+	if port := parsed.Port(); port != "" && !(scheme == "http" && port == "80") && !(scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+
+	query := parsed.Query()
+	for key := range query {
+		if strings.HasPrefix(strings.ToLower(key), "utm_") {
+			query.Del(key)
+		}
+	}
+
+	canonical := scheme + "://" + host + strings.TrimRight(parsed.EscapedPath(), "/")
+	if encoded := query.Encode(); encoded != "" {
+		canonical += "?" + encoded
+	}
+	return canonical
+}
@@ -0,0 +1,85 @@
+package search
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Laisky/errors/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManagerSearchFanOutFusesResults(t *testing.T) {
+	google := &testEngine{
+		name:       "google",
+		engineType: "google",
+		items: []SearchResultItem{
+			{URL: "https://example.com/a", Name: "A"},
+			{URL: "https://example.com/b", Name: "B"},
+		},
+	}
+	googleBackup := &testEngine{name: "google-backup", engineType: "google"}
+	bing := &testEngine{
+		name:       "bing",
+		engineType: "bing",
+		items: []SearchResultItem{
+			{URL: "https://www.example.com/b/?utm_source=bing#top", Name: "B from bing", Snippet: "b"},
+			{URL: "https://example.com/c", Name: "C"},
+		},
+	}
+	firecrawl := &testEngine{name: "firecrawl", engineType: "firecrawl", err: errors.New("quota")}
+
+	manager, err := NewManager([][]Engine{{google}, {googleBackup, bing}, {firecrawl}})
+	require.NoError(t, err)
+
+	output, err := manager.SearchFanOut(context.Background(), "golang", 3)
+	require.NoError(t, err)
+	require.Equal(t, EngineTypeFanOut, output.EngineType)
+	require.Equal(t, []string{"google", "bing"}, output.Engines)
+	require.Equal(t, 0, googleBackup.calls)
+	require.Equal(t, 1, firecrawl.calls)
+
+	require.Len(t, output.Items, 3)
+	require.Equal(t, "B", output.Items[0].Name)
+	require.Equal(t, "b", output.Items[0].Snippet)
+	require.Equal(t, []string{"google", "bing"}, output.Items[0].Engines)
+	require.Equal(t, "A", output.Items[1].Name)
+	require.Equal(t, []string{"google"}, output.Items[1].Engines)
+	require.Equal(t, "C", output.Items[2].Name)
+}
+
+func TestManagerSearchFanOutSkipsEmptyEngines(t *testing.T) {
+	google := &testEngine{name: "google", engineType: "google", items: []SearchResultItem{{URL: "https://example.com/a", Name: "A"}}}
+	bing := &testEngine{name: "bing", engineType: "bing"}
+
+	manager, err := NewManager([][]Engine{{google}, {bing}})
+	require.NoError(t, err)
+
+	output, err := manager.SearchFanOut(context.Background(), "golang", 2)
+	require.NoError(t, err)
+	require.Equal(t, 1, bing.calls)
+	require.Equal(t, []string{"google"}, output.Engines)
+	require.Equal(t, "google", output.EngineName)
+}
+
+func TestManagerSearchFanOutAllEnginesFail(t *testing.T) {
+	primary := &testEngine{name: "primary", engineType: "a", err: errors.New("timeout")}
+	secondary := &testEngine{name: "secondary", engineType: "b", err: errors.New("quota")}
+
+	manager, err := NewManager([][]Engine{{primary}, {secondary}})
+	require.NoError(t, err)
+
+	_, err = manager.SearchFanOut(context.Background(), "golang", 0)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "primary")
+	require.Contains(t, err.Error(), "secondary")
+}
+
+func TestCanonicalURL(t *testing.T) {
+	require.Equal(t, "https://example.com/docs?a=1&b=2",
+		CanonicalURL("HTTPS://WWW.Example.com:443/docs/?b=2&utm_medium=x&a=1#intro"))
+	require.Equal(t, "http://example.com:8080", CanonicalURL("http://example.com:8080/"))
+	require.Equal(t, "not a url", CanonicalURL(" not a url "))
+	require.Equal(t, 1, ClampFanOutEngines(1))
+	require.Equal(t, DefaultFanOutEngines, ClampFanOutEngines(0))
+	require.Equal(t, MaxFanOutEngines, ClampFanOutEngines(99))
+}
@@ -36,6 +36,8 @@ type SearchOutput struct {
 	Items      []SearchResultItem
 	EngineName string
 	EngineType string
+	// Engines lists the engines that contributed results to a fan-out search.
+	Engines []string
 }
 
 // Provider exposes the high level search capability used by resolvers and tools.
@@ -0,0 +1,310 @@
+package search
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+var (
+	markdownBlankLines = regexp.MustCompile(`\n{3,}`)
+	markdownWhitespace = regexp.MustCompile(`\s+`)
+	markdownCodeLang   = regexp.MustCompile(`(?:^|\s)(?:language|lang)-([\w+#.-]+)`)
+)
+
+// markdownWriter renders an HTML tree as CommonMark.
+//
+// Block elements are separated by blank lines, inline whitespace is collapsed as a
+// browser would, and relative link and image targets are resolved against base.
+type markdownWriter struct {
+	base *url.URL
+	out  strings.Builder
+}
+
+// htmlToMarkdown renders node and its descendants as Markdown.
+func htmlToMarkdown(base *url.URL, node *html.Node) string {
+	w := &markdownWriter{base: base}
+	w.node(node)
+	return w.String()
+}
+
+// String returns the rendered Markdown with blank line runs collapsed and trailing spaces trimmed.
+func (w *markdownWriter) String() string {
+	lines := strings.Split(w.out.String(), "\n")
+	for idx, line := range lines {
+		lines[idx] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimSpace(markdownBlankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+// render renders the children of node with a fresh writer sharing w's base URL.
+func (w *markdownWriter) render(node *html.Node) string {
+	return w.renderRaw(node).String()
+}
+
+// renderRaw renders the children of node without the final cleanup of String.
+func (w *markdownWriter) renderRaw(node *html.Node) *markdownWriter {
+	sub := &markdownWriter{base: w.base}
+	sub.children(node)
+	return sub
+}
+
+// renderInline renders the children of node as a single line.
+func (w *markdownWriter) renderInline(node *html.Node) string {
+	return collapseSpace(w.render(node))
+}
+
+// text writes inline text, dropping leading whitespace at the start of a line.
+func (w *markdownWriter) text(value string) {
+	if value == "" {
+		return
+	}
+	if last := w.lastByte(); last == 0 || last == '\n' || last == ' ' {
+		value = strings.TrimLeft(value, " ")
+	}
+	w.out.WriteString(value)
+}
+
+// lastByte returns the last byte written, or 0 before any output.
+func (w *markdownWriter) lastByte() byte {
+	if w.out.Len() == 0 {
+		return 0
+	}
+	return w.out.String()[w.out.Len()-1]
+}
+
+// block separates the next block from the previous one with a blank line.
+func (w *markdownWriter) block() {
+	if w.out.Len() > 0 {
+		w.out.WriteString("\n\n")
+	}
+}
+
+// children renders every child of node in order.
+func (w *markdownWriter) children(node *html.Node) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		w.node(child)
+	}
+}
+
+// inlineWrap writes the inline content of node between marker, keeping the surrounding spaces outside.
+func (w *markdownWriter) inlineWrap(node *html.Node, marker string) {
+	raw := w.renderRaw(node).out.String()
+	inner := collapseSpace(raw)
+	if inner == "" {
+		return
+	}
+	if strings.HasPrefix(raw, " ") {
+		w.text(" ")
+	}
+	w.text(marker + inner + marker)
+	if strings.HasSuffix(raw, " ") {
+		w.text(" ")
+	}
+}
+
+//nolint:gocognit,gocyclo // one case per supported element
+func (w *markdownWriter) node(node *html.Node) {
+	switch node.Type {
+	case html.TextNode:
+		w.text(markdownWhitespace.ReplaceAllString(node.Data, " "))
+		return
+	case html.DocumentNode:
+		w.children(node)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	switch node.Data {
+	case "head", "script", "style", "noscript", "template", "iframe", "svg", "canvas", "button", "input", "select", "textarea":
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		if heading := w.renderInline(node); heading != "" {
+			w.block()
+			w.out.WriteString(strings.Repeat("#", int(node.Data[1]-'0')) + " " + heading)
+			w.block()
+		}
+	case "p", "div", "section", "article", "main", "header", "footer", "figure", "figcaption", "details", "summary", "dl", "dd", "dt", "address":
+		w.block()
+		w.children(node)
+		w.block()
+	case "br":
+		w.out.WriteString("\n")
+	case "hr":
+		w.block()
+		w.out.WriteString("---")
+		w.block()
+	case "strong", "b":
+		w.inlineWrap(node, "**")
+	case "em", "i":
+		w.inlineWrap(node, "_")
+	case "del", "s", "strike":
+		w.inlineWrap(node, "~~")
+	case "code", "kbd", "samp":
+		if code := collapseSpace(nodeText(node)); code != "" {
+			fence := "`"
+			if strings.Contains(code, "`") {
+				fence = "``"
+			}
+			w.text(fence + code + fence)
+		}
+	case "pre":
+		w.block()
+		code := strings.Trim(nodeText(node), "\n")
+		fence := "```"
+		for strings.Contains(code, fence) {
+			fence += "`"
+		}
+		w.out.WriteString(fence + codeLanguage(node) + "\n" + code + "\n" + fence)
+		w.block()
+	case "a":
+		label := w.renderInline(node)
+		href := strings.TrimSpace(attrValue(node, "href"))
+		switch {
+		case label == "":
+		case href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:"):
+			w.text(label)
+		default:
+			w.text("[" + label + "](" + resolveLink(w.base, href) + ")")
+		}
+	case "img":
+		if src := strings.TrimSpace(attrValue(node, "src")); src != "" && !strings.HasPrefix(src, "data:") {
+			w.text("![" + collapseSpace(attrValue(node, "alt")) + "](" + resolveLink(w.base, src) + ")")
+		}
+	case "ul", "ol":
+		w.list(node)
+	case "blockquote":
+		quote := w.render(node)
+		if quote == "" {
+			return
+		}
+		w.block()
+		lines := strings.Split(quote, "\n")
+		for idx, line := range lines {
+			lines[idx] = strings.TrimRight("> "+line, " ")
+		}
+		w.out.WriteString(strings.Join(lines, "\n"))
+		w.block()
+	case "table":
+		w.table(node)
+	default:
+		w.children(node)
+	}
+}
+
+// list renders the <li> children of an <ul> or <ol>, indenting nested content under its marker.
+func (w *markdownWriter) list(node *html.Node) {
+	ordered := node.Data == "ol"
+	index := 1
+	var items []string
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode || child.Data != "li" {
+			continue
+		}
+		marker := "- "
+		if ordered {
+			marker = fmt.Sprintf("%d. ", index)
+			index++
+		}
+		content := markdownBlankLines.ReplaceAllString(w.render(child), "\n\n")
+		content = strings.ReplaceAll(content, "\n\n", "\n")
+		indent := strings.Repeat(" ", len(marker))
+		items = append(items, marker+strings.ReplaceAll(content, "\n", "\n"+indent))
+	}
+	if len(items) == 0 {
+		return
+	}
+	w.block()
+	w.out.WriteString(strings.Join(items, "\n"))
+	w.block()
+}
+
+// table renders a table as a GitHub-flavored Markdown table whose first row is the header.
+func (w *markdownWriter) table(node *html.Node) {
+	var rows [][]string
+	var walk func(*html.Node)
+	walk = func(current *html.Node) {
+		for child := current.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			switch child.Data {
+			case "thead", "tbody", "tfoot":
+				walk(child)
+			case "tr":
+				var cells []string
+				for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == html.ElementNode && (cell.Data == "td" || cell.Data == "th") {
+						cells = append(cells, strings.ReplaceAll(w.renderInline(cell), "|", `\|`))
+					}
+				}
+				if len(cells) > 0 {
+					rows = append(rows, cells)
+				}
+			}
+		}
+	}
+	walk(node)
+	if len(rows) == 0 {
+		return
+	}
+
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+	w.block()
+	for idx, row := range rows {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+		w.out.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if idx == 0 {
+			w.out.WriteString("|" + strings.Repeat(" --- |", columns) + "\n")
+		}
+	}
+	w.block()
+}
+
+// nodeText returns the raw text of node and its descendants, keeping whitespace.
+func nodeText(node *html.Node) string {
+	if node.Type == html.TextNode {
+		return node.Data
+	}
+	var text strings.Builder
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.Data == "br" {
+			text.WriteString("\n")
+			continue
+		}
+		text.WriteString(nodeText(child))
+	}
+	return text.String()
+}
+
+// codeLanguage reads a language-xxx class from a <pre> or its <code> child.
+func codeLanguage(pre *html.Node) string {
+	for _, node := range []*html.Node{pre, pre.FirstChild} {
+		if node == nil || node.Type != html.ElementNode {
+			continue
+		}
+		if match := markdownCodeLang.FindStringSubmatch(attrValue(node, "class")); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
+
+// attrValue returns the value of the named attribute of node.
+func attrValue(node *html.Node, name string) string {
+	for _, attr := range node.Attr {
+		if attr.Key == name {
+			return attr.Val
+		}
+	}
+	return ""
+}
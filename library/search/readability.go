@@ -0,0 +1,143 @@
+package search
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+const (
+	// readabilityMinParagraphLen is the shortest paragraph text that contributes to a candidate score.
+	readabilityMinParagraphLen = 25
+	// readabilityMinContentLen is the shortest main content accepted before falling back to the whole body.
+	readabilityMinContentLen = 140
+)
+
+var (
+	// readabilityNoiseTags never hold article content.
+	readabilityNoiseTags = "script,style,noscript,template,iframe,object,embed,svg,canvas,form,button,input,select,textarea,nav,aside,footer,dialog"
+	// readabilityUnlikely matches class or id names of page chrome.
+	readabilityUnlikely = regexp.MustCompile(`(?i)-ad-|ai2html|banner|breadcrumbs|combx|comment|community|cookie|cover-wrap|disqus|extra|footer|gdpr|header|legends|menu|related|remark|replies|rss|shoutbox|sidebar|skyscraper|social|sponsor|supplemental|ad-break|agegate|pagination|pager|popup|subscribe|yom-remote`)
+	// readabilityMaybe rescues unlikely-looking names that usually wrap content.
+	readabilityMaybe = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	// readabilityPositive and readabilityNegative adjust a candidate by its class and id.
+	readabilityPositive = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|post|text|blog|story`)
+	readabilityNegative = regexp.MustCompile(`(?i)-ad-|hidden|^hid$|banner|combx|comment|com-|contact|footer|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|widget`)
+)
+
+// extractMainContent returns the node that most likely holds the main content of doc.
+//
+// It follows the readability heuristics: page chrome is dropped, every paragraph adds
+// a score based on its length and comma count to its parent and half of it to its
+// grandparent, and the best candidate is discounted by its link density. When no
+// candidate holds enough text, the <article>, <main> or <body> element is returned.
+// doc is modified in place.
+func extractMainContent(doc *goquery.Document) *goquery.Selection {
+	doc.Find(readabilityNoiseTags).Remove()
+	doc.Find("*").Each(func(_ int, node *goquery.Selection) {
+		switch goquery.NodeName(node) {
+		case "html", "body", "article", "main":
+			return
+		}
+		names := node.AttrOr("class", "") + " " + node.AttrOr("id", "")
+		if readabilityUnlikely.MatchString(names) && !readabilityMaybe.MatchString(names) {
+			node.Remove()
+		}
+	})
+
+	var (
+		candidates []*html.Node
+		scores     = make(map[*html.Node]float64)
+	)
+	addScore := func(node *html.Node, score float64) {
+		if node == nil || node.Type != html.ElementNode {
+			return
+		}
+		if _, ok := scores[node]; !ok {
+			scores[node] = readabilityInitialScore(node)
+			candidates = append(candidates, node)
+		}
+		scores[node] += score
+	}
+	doc.Find("p,pre,td,blockquote").Each(func(_ int, paragraph *goquery.Selection) {
+		text := collapseSpace(paragraph.Text())
+		if len(text) < readabilityMinParagraphLen {
+			return
+		}
+		score := 1 + float64(strings.Count(text, ",")) + min(float64(len(text)/100), 3)
+		parent := paragraph.Get(0).Parent
+		addScore(parent, score)
+		if parent != nil {
+			addScore(parent.Parent, score/2)
+		}
+	})
+
+	var (
+		best      *html.Node
+		bestScore float64
+	)
+	for _, node := range candidates {
+		score := scores[node] * (1 - readabilityLinkDensity(doc.FindNodes(node)))
+		if best == nil || score > bestScore {
+			best, bestScore = node, score
+		}
+	}
+	if best != nil {
+		selection := doc.FindNodes(best)
+		if len(collapseSpace(selection.Text())) >= readabilityMinContentLen {
+			return selection
+		}
+	}
+
+	for _, fallback := range []string{"article", "main", "body"} {
+		if selection := doc.Find(fallback).First(); selection.Length() > 0 {
+			return selection
+		}
+	}
+	return doc.Selection
+}
+
+// readabilityInitialScore seeds a candidate by its tag and its class and id names.
+func readabilityInitialScore(node *html.Node) float64 {
+	var score float64
+	switch node.Data {
+	case "article":
+		score = 10
+	case "div", "section", "main":
+		score = 5
+	case "pre", "td", "blockquote":
+		score = 3
+	case "address", "ol", "ul", "dl", "dd", "dt", "li":
+		score = -3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		score = -5
+	}
+
+	for _, attr := range node.Attr {
+		if attr.Key != "class" && attr.Key != "id" {
+			continue
+		}
+		if readabilityNegative.MatchString(attr.Val) {
+			score -= 25
+		}
+		if readabilityPositive.MatchString(attr.Val) {
+			score += 25
+		}
+	}
+	return score
+}
+
+// readabilityLinkDensity returns the share of the text of node that sits inside links.
+func readabilityLinkDensity(node *goquery.Selection) float64 {
+	textLen := len(collapseSpace(node.Text()))
+	if textLen == 0 {
+		return 0
+	}
+	linkLen := 0
+	node.Find("a").Each(func(_ int, link *goquery.Selection) {
+		linkLen += len(collapseSpace(link.Text()))
+	})
+	return float64(linkLen) / float64(textLen)
+}
@@ -13,9 +13,9 @@ import (
 	rlibs "github.com/Laisky/laisky-blog-graphql/library/db/redis"
 )
 
-// type htmlToMarkdownConverter interface {
-// 	ConvertString(string) (string, error)
-// }
+// ErrCrawlerTaskFailed is returned when the remote crawler reports that it could not render a page.
+// It means the crawler is alive, so callers should not retry the page through a fallback fetcher.
+var ErrCrawlerTaskFailed = errors.New("crawler task failed")
 
 // FetchDynamicURLContent is a wrapper for submit & fetch dynamic url content.
 // When apiKey is not empty and outputMarkdown is true, it converts the fetched HTML body
@@ -99,7 +99,7 @@ func FetchDynamicURLContent(ctx context.Context, rdb *rlibs.DB, url, apiKey stri
 				}
 				logger.Debug("html crawler task failed", fields...)
 			}
-			return nil, errors.Errorf("task failed at %s for reason %q",
+			return nil, errors.Wrapf(ErrCrawlerTaskFailed, "task failed at %s for reason %q",
 				*task.FinishedAt, *task.FailedReason)
 		default:
 			if logger != nil {
@@ -0,0 +1,149 @@
+package search
+
+import (
+	"bufio"
+	"bytes"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RobotsRules are the robots.txt rules that apply to one user agent.
+// The zero value allows everything.
+type RobotsRules struct {
+	rules []robotsRule
+	// CrawlDelay is the delay between requests asked for by the site, zero when unset.
+	CrawlDelay time.Duration
+}
+
+// robotsRule is one Allow or Disallow line.
+type robotsRule struct {
+	allow bool
+	// length is the pattern length, which ranks overlapping matches.
+	length  int
+	matcher *regexp.Regexp
+}
+
+// newRobotsRule compiles a path pattern where "*" matches any run of characters
+// and a trailing "$" anchors the end of the path.
+func newRobotsRule(allow bool, pattern string) robotsRule {
+	anchored := strings.HasSuffix(pattern, "$")
+	literal := strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(literal, "*")
+	for idx, part := range parts {
+		parts[idx] = regexp.QuoteMeta(part)
+	}
+	expr := "^" + strings.Join(parts, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return robotsRule{allow: allow, length: len(pattern), matcher: regexp.MustCompile(expr)}
+}
+
+// robotsGroup is the rules following a run of User-agent lines.
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+// ParseRobotsTxt returns the rules of body that apply to userAgent.
+//
+// The group naming the longest product token contained in userAgent wins, falling
+// back to the "*" group. Rules follow RFC 9309: the longest matching pattern decides,
+// Allow wins ties, "*" matches any run of characters and a trailing "$" anchors the end.
+func ParseRobotsTxt(body []byte, userAgent string) *RobotsRules {
+	var (
+		groups       []*robotsGroup
+		current      *robotsGroup
+		lastWasAgent bool
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.IndexByte(line, '#'); idx >= 0 {
+			line = line[:idx]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if current == nil || !lastWasAgent {
+				current = &robotsGroup{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			lastWasAgent = true
+			continue
+		case "allow", "disallow":
+			// An empty Disallow allows everything, so it adds no rule.
+			if current != nil && value != "" {
+				current.rules = append(current.rules, newRobotsRule(key == "allow", value))
+			}
+		case "crawl-delay":
+			if seconds, err := strconv.ParseFloat(value, 64); current != nil && err == nil && seconds > 0 {
+				current.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		}
+		lastWasAgent = false
+	}
+
+	agent := strings.ToLower(userAgent)
+	var (
+		selected    *robotsGroup
+		selectedLen = -1
+	)
+	for _, group := range groups {
+		for _, name := range group.agents {
+			matchLen := -1
+			switch {
+			case name == "*":
+				matchLen = 0
+			case name != "" && strings.Contains(agent, name):
+				matchLen = len(name)
+			}
+			if matchLen > selectedLen {
+				selected, selectedLen = group, matchLen
+			}
+		}
+	}
+	if selected == nil {
+		return &RobotsRules{}
+	}
+	return &RobotsRules{rules: selected.rules, CrawlDelay: selected.crawlDelay}
+}
+
+// Allowed reports whether the path and query of rawURL may be crawled.
+func (r *RobotsRules) Allowed(rawURL string) bool {
+	if r == nil || len(r.rules) == 0 {
+		return true
+	}
+	target := "/"
+	if parsed, err := url.Parse(rawURL); err == nil {
+		target = parsed.EscapedPath()
+		if target == "" {
+			target = "/"
+		}
+		if parsed.RawQuery != "" {
+			target += "?" + parsed.RawQuery
+		}
+	}
+
+	allowed, matchedLen := true, -1
+	for _, rule := range r.rules {
+		if !rule.matcher.MatchString(target) {
+			continue
+		}
+		if rule.length > matchedLen || (rule.length == matchedLen && rule.allow) {
+			allowed, matchedLen = rule.allow, rule.length
+		}
+	}
+	return allowed
+}
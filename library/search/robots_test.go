@@ -0,0 +1,45 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const robotsTestFile = `# comments are ignored
+User-agent: *
+Disallow: /private
+Allow: /private/docs
+Disallow: /*.pdf$
+
+User-agent: other-bot
+User-agent: laisky-mcp
+Disallow: /
+Allow: /docs/
+Crawl-delay: 1.5
+`
+
+func TestParseRobotsTxtGroups(t *testing.T) {
+	generic := ParseRobotsTxt([]byte(robotsTestFile), "SomeBrowser/1.0")
+	require.True(t, generic.Allowed("https://example.com/"))
+	require.False(t, generic.Allowed("https://example.com/private/keys"))
+	require.True(t, generic.Allowed("https://example.com/private/docs/intro"))
+	require.False(t, generic.Allowed("https://example.com/files/report.pdf"))
+	require.True(t, generic.Allowed("https://example.com/files/report.pdf?download=1"))
+	require.Zero(t, generic.CrawlDelay)
+
+	specific := ParseRobotsTxt([]byte(robotsTestFile), "Mozilla/5.0 (compatible; laisky-mcp-web-fetch/1.0)")
+	require.False(t, specific.Allowed("https://example.com/blog"))
+	require.True(t, specific.Allowed("https://example.com/docs/intro"))
+	require.Equal(t, 1500*time.Millisecond, specific.CrawlDelay)
+}
+
+func TestParseRobotsTxtPermissiveDefaults(t *testing.T) {
+	require.True(t, ParseRobotsTxt(nil, "bot").Allowed("https://example.com/a"))
+	require.True(t, ParseRobotsTxt([]byte("User-agent: other\nDisallow: /"), "bot").Allowed("https://example.com/a"))
+	require.True(t, ParseRobotsTxt([]byte("User-agent: *\nDisallow:"), "bot").Allowed("https://example.com/a"))
+
+	var rules *RobotsRules
+	require.True(t, rules.Allowed("https://example.com/a"))
+}
@@ -0,0 +1,80 @@
+package search
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"io"
+	"strings"
+
+	"github.com/Laisky/errors/v2"
+)
+
+// maxSitemapBytes caps the decompressed size of one sitemap, as the sitemap protocol does.
+const maxSitemapBytes = 50 * 1024 * 1024
+
+// Sitemap is a parsed sitemap: either page URLs or, for a sitemap index, nested sitemaps.
+type Sitemap struct {
+	URLs     []string
+	Sitemaps []string
+}
+
+// sitemapDocument matches both <urlset> and <sitemapindex> documents.
+type sitemapDocument struct {
+	XMLName xml.Name
+	URLs    []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// ParseSitemap parses an XML sitemap or sitemap index, gzip-compressed or not.
+// Plain text sitemaps with one URL per line are accepted as well.
+func ParseSitemap(body []byte) (*Sitemap, error) {
+	if bytes.HasPrefix(body, []byte{0x1f, 0x8b}) {
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, errors.Wrap(err, "open gzip sitemap")
+		}
+		body, err = io.ReadAll(io.LimitReader(reader, maxSitemapBytes))
+		if err != nil {
+			return nil, errors.Wrap(err, "read gzip sitemap")
+		}
+	}
+
+	trimmed := bytes.TrimSpace(body)
+	if !bytes.HasPrefix(trimmed, []byte("<")) {
+		sitemap := new(Sitemap)
+		for _, line := range strings.Split(string(trimmed), "\n") {
+			if line = strings.TrimSpace(line); strings.HasPrefix(line, "http://") || strings.HasPrefix(line, "https://") {
+				sitemap.URLs = append(sitemap.URLs, line)
+			}
+		}
+		return sitemap, nil
+	}
+
+	var doc sitemapDocument
+	if err := xml.Unmarshal(trimmed, &doc); err != nil {
+		return nil, errors.Wrap(err, "parse sitemap xml")
+	}
+	switch doc.XMLName.Local {
+	case "urlset", "sitemapindex":
+	default:
+		return nil, errors.Errorf("unexpected sitemap root element <%s>", doc.XMLName.Local)
+	}
+
+	sitemap := new(Sitemap)
+	for _, entry := range doc.URLs {
+		if loc := strings.TrimSpace(entry.Loc); loc != "" {
+			sitemap.URLs = append(sitemap.URLs, loc)
+		}
+	}
+	for _, entry := range doc.Sitemaps {
+		if loc := strings.TrimSpace(entry.Loc); loc != "" {
+			sitemap.Sitemaps = append(sitemap.Sitemaps, loc)
+		}
+	}
+	return sitemap, nil
+}
@@ -0,0 +1,39 @@
+package search
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSitemap(t *testing.T) {
+	sitemap, err := ParseSitemap([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc> https://example.com/docs/a </loc><lastmod>2025-01-01</lastmod></url>
+  <url><loc>https://example.com/docs/b</loc></url>
+</urlset>`))
+	require.NoError(t, err)
+	require.Equal(t, []string{"https://example.com/docs/a", "https://example.com/docs/b"}, sitemap.URLs)
+	require.Empty(t, sitemap.Sitemaps)
+
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	_, err = writer.Write([]byte(`<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>https://example.com/sitemap-docs.xml</loc></sitemap>
+</sitemapindex>`))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	index, err := ParseSitemap(compressed.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, []string{"https://example.com/sitemap-docs.xml"}, index.Sitemaps)
+
+	text, err := ParseSitemap([]byte("https://example.com/a\n\nnot a url\nhttps://example.com/b\n"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"https://example.com/a", "https://example.com/b"}, text.URLs)
+
+	_, err = ParseSitemap([]byte(`<html><body>not a sitemap</body></html>`))
+	require.Error(t, err)
+}
@@ -23,6 +23,9 @@ const TOOL_OPTIONS: Array<{ label: string; value: string }> = [
   { label: 'file_rename', value: 'file_rename' },
   { label: 'file_list', value: 'file_list' },
   { label: 'file_search', value: 'file_search' },
+  { label: 'file_grep', value: 'file_grep' },
+  { label: 'file_snapshot', value: 'file_snapshot' },
+  { label: 'file_index_status', value: 'file_index_status' },
 ];
 const SORT_FIELDS: Array<{ label: string; value: string }> = [
   { label: 'Newest first', value: 'occurred_at' },